@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+func init() {
+	register("analyze", Analyze)
+}
+
+// Analyze runs the gap and execution overhead calculations over the PipelineRuns and TaskRuns exported to a
+// directory, like with oc get -o yaml, printing a row per PipelineRun and summary stats of the overhead
+func Analyze(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dir := flags.String("dir", "", "The directory holding the exported PipelineRun and TaskRun YAML or JSON files.")
+	gaps := flags.Bool("gaps", false, "Also print the individual gaps of each PipelineRun.")
+	since := flags.String("since", "", "Only PipelineRuns completed at or after this RFC3339 time, or UTC date, are analyzed.")
+	until := flags.String("until", "", "Only PipelineRuns completed before this RFC3339 time, or UTC date, are analyzed.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(*dir) == 0 {
+		return fmt.Errorf("analyze requires --dir")
+	}
+	collector.ApplySettings(Settings)
+	completed, err := parseWindow(*since, *until)
+	if err != nil {
+		return err
+	}
+	r, err := loadDir(ctx, *dir)
+	if err != nil {
+		return err
+	}
+	r.pipelineRuns = completedWithin(r.pipelineRuns, completed)
+	sort.Slice(r.pipelineRuns, func(i, j int) bool {
+		if r.pipelineRuns[i].Namespace != r.pipelineRuns[j].Namespace {
+			return r.pipelineRuns[i].Namespace < r.pipelineRuns[j].Namespace
+		}
+		return r.pipelineRuns[i].Name < r.pipelineRuns[j].Name
+	})
+
+	s := &overheadSummary{skipped: map[string]int{}}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tPIPELINE\tTASKRUNS\tDURATION(ms)\tGAPS(ms)\tOVERHEAD\tNOTE")
+	for _, pr := range r.pipelineRuns {
+		taskRuns := r.taskRunsOf(pr)
+		result := collector.CalculateGaps(pr, taskRuns)
+		s.add(result)
+		overhead, note := "-", result.Reason
+		if o, ok := result.Overhead(); ok {
+			overhead = fmt.Sprintf("%.4f", o)
+		} else if result.Calculated() {
+			note = "filtered"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.0f\t%.0f\t%s\t%s\n", pr.Namespace, pr.Name, pipelineName(pr, result),
+			len(taskRuns), result.Duration, result.Total, overhead, note)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if *gaps {
+		for _, pr := range r.pipelineRuns {
+			result := collector.CalculateGaps(pr, r.taskRunsOf(pr))
+			if len(result.Entries) == 0 {
+				continue
+			}
+			fmt.Fprintf(out, "\n%s/%s\n", pr.Namespace, pr.Name)
+			w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "COMPLETED\tUPCOMING\tGAP(ms)")
+			for _, entry := range result.Entries {
+				fmt.Fprintf(w, "%s\t%s\t%.0f\n", entry.Completed, entry.Upcoming, entry.Gap)
+			}
+			if err = w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintln(out)
+	s.print(out)
+	return nil
+}
+
+func pipelineName(pr *v1.PipelineRun, result collector.GapResult) string {
+	if len(result.Entries) > 0 {
+		return result.Entries[0].Pipeline
+	}
+	if name, ok := pr.Labels[pipeline.PipelineLabelKey]; ok {
+		return name
+	}
+	return "-"
+}
+
+// overheadSummary tallies the PipelineRuns analyzed and the distribution of their execution overhead
+type overheadSummary struct {
+	total     int
+	filtered  int
+	skipped   map[string]int
+	overheads []float64
+}
+
+func (s *overheadSummary) add(result collector.GapResult) {
+	s.total++
+	if !result.Calculated() {
+		s.skipped[result.Reason]++
+		return
+	}
+	overhead, ok := result.Overhead()
+	if !ok {
+		s.filtered++
+		return
+	}
+	s.overheads = append(s.overheads, overhead)
+}
+
+func (s *overheadSummary) print(out io.Writer) {
+	fmt.Fprintf(out, "PipelineRuns: %d, with overhead: %d, filtered: %d\n", s.total, len(s.overheads), s.filtered)
+	reasons := []string{}
+	for reason := range s.skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(out, "Skipped as %s: %d\n", reason, s.skipped[reason])
+	}
+	if len(s.overheads) == 0 {
+		return
+	}
+	sorted := append([]float64{}, s.overheads...)
+	sort.Float64s(sorted)
+	sum := 0.0
+	for _, o := range sorted {
+		sum = sum + o
+	}
+	fmt.Fprintf(out, "Overhead min: %.4f, mean: %.4f, p50: %.4f, p90: %.4f, max: %.4f\n", sorted[0], sum/float64(len(sorted)),
+		percentile(sorted, 0.5), percentile(sorted, 0.9), sorted[len(sorted)-1])
+}
+
+// percentile uses the nearest rank of the sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/pointer"
+)
+
+const analyzeListYaml = `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: tekton.dev/v1beta1
+  kind: PipelineRun
+  metadata:
+    name: build-1
+    namespace: test-namespace
+    creationTimestamp: "2023-06-01T10:00:00Z"
+    labels:
+      tekton.dev/pipeline: docker-build
+  spec:
+    pipelineRef:
+      name: docker-build
+  status:
+    startTime: "2023-06-01T10:00:00Z"
+    completionTime: "2023-06-01T10:10:00Z"
+    conditions:
+    - type: Succeeded
+      status: "True"
+      reason: Succeeded
+    childReferences:
+    - kind: TaskRun
+      name: build-1-clone
+      pipelineTaskName: clone
+    - kind: TaskRun
+      name: build-1-build
+      pipelineTaskName: build
+- apiVersion: tekton.dev/v1beta1
+  kind: TaskRun
+  metadata:
+    name: build-1-clone
+    namespace: test-namespace
+    creationTimestamp: "2023-06-01T10:00:01Z"
+    labels:
+      tekton.dev/pipelineRun: build-1
+      tekton.dev/pipelineTask: clone
+  status:
+    startTime: "2023-06-01T10:00:01Z"
+    completionTime: "2023-06-01T10:04:00Z"
+    conditions:
+    - type: Succeeded
+      status: "True"
+      reason: Succeeded
+- apiVersion: tekton.dev/v1beta1
+  kind: TaskRun
+  metadata:
+    name: build-1-build
+    namespace: test-namespace
+    creationTimestamp: "2023-06-01T10:04:03Z"
+    labels:
+      tekton.dev/pipelineRun: build-1
+      tekton.dev/pipelineTask: build
+  status:
+    startTime: "2023-06-01T10:04:03Z"
+    completionTime: "2023-06-01T10:10:00Z"
+    conditions:
+    - type: Succeeded
+      status: "True"
+      reason: Succeeded
+`
+
+const analyzeRunningYaml = `
+apiVersion: tekton.dev/v1
+kind: PipelineRun
+metadata:
+  name: build-2
+  namespace: test-namespace
+  creationTimestamp: "2023-06-01T11:00:00Z"
+status:
+  startTime: "2023-06-01T11:00:00Z"
+  conditions:
+  - type: Succeeded
+    status: Unknown
+    reason: Running
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ignored
+  namespace: test-namespace
+`
+
+func writeAnalyzeDir(t *testing.T) string {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "list.yaml"), []byte(analyzeListYaml), 0600))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "running.yml"), []byte(analyzeRunningYaml), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not yaml"), 0600))
+	return dir
+}
+
+func TestLoadDir(t *testing.T) {
+	r, err := loadDir(context.Background(), writeAnalyzeDir(t))
+	assert.NoError(t, err)
+	assert.Len(t, r.pipelineRuns, 2)
+	assert.Len(t, r.taskRuns, 2)
+	for _, pr := range r.pipelineRuns {
+		if pr.Name == "build-1" {
+			assert.Len(t, r.taskRunsOf(pr), 2)
+			continue
+		}
+		assert.Len(t, r.taskRunsOf(pr), 0)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := Analyze(context.Background(), []string{"--dir", writeAnalyzeDir(t), "--gaps"}, out)
+	assert.NoError(t, err)
+	assert.Regexp(t, `build-1\s+docker-build\s+2\s+600000\s+4000\s+0.0067`, out.String())
+	assert.Regexp(t, `build-2\s+-\s+0\s+0\s+0\s+-\s+not-finished`, out.String())
+	assert.Regexp(t, `clone\s+build\s+3000`, out.String())
+	assert.Contains(t, out.String(), "PipelineRuns: 2, with overhead: 1, filtered: 0")
+	assert.Contains(t, out.String(), "Skipped as not-finished: 1")
+	assert.Contains(t, out.String(), "p90: 0.0067")
+}
+
+func TestAnalyzeSettings(t *testing.T) {
+	defer func(s collector.Settings) {
+		Settings = s
+		collector.ApplySettings(s)
+	}(Settings)
+	// filtered with the exporter's FILTER_THRESHOLD, as the run is shorter
+	Settings = collector.Settings{FilterThreshold: pointer.Float64(900000)}
+	out := &bytes.Buffer{}
+	assert.NoError(t, Analyze(context.Background(), []string{"--dir", writeAnalyzeDir(t)}, out))
+	assert.Contains(t, out.String(), "PipelineRuns: 2, with overhead: 0, filtered: 1")
+}
+
+func TestAnalyzeWindow(t *testing.T) {
+	dir := writeAnalyzeDir(t)
+	out := &bytes.Buffer{}
+	assert.NoError(t, Analyze(context.Background(), []string{"--dir", dir, "--since", "2023-06-01", "--until", "2023-06-02"}, out))
+	// the running PipelineRun has not completed within the window
+	assert.Contains(t, out.String(), "PipelineRuns: 1, with overhead: 1, filtered: 0")
+	assert.NotContains(t, out.String(), "build-2")
+
+	out = &bytes.Buffer{}
+	assert.NoError(t, Analyze(context.Background(), []string{"--dir", dir, "--since", "2023-06-02"}, out))
+	assert.Contains(t, out.String(), "PipelineRuns: 0")
+
+	assert.Error(t, Analyze(context.Background(), []string{"--dir", dir, "--until", "yesterday"}, &bytes.Buffer{}))
+}
+
+func TestAnalyzeRequiresDir(t *testing.T) {
+	assert.Error(t, Analyze(context.Background(), []string{}, &bytes.Buffer{}))
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	assert.Equal(t, 5.0, percentile(sorted, 0.5))
+	assert.Equal(t, 9.0, percentile(sorted, 0.9))
+	assert.Equal(t, 1.0, percentile([]float64{1}, 0.9))
+}
@@ -0,0 +1,348 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+func init() {
+	register("capture", Capture)
+}
+
+const (
+	// CaptureNamespace is what the namespace of the captured objects is replaced with, matching the test fixtures
+	CaptureNamespace = "test-namespace"
+	// scrubbed replaces the values that could hold secrets
+	scrubbed = "scrubbed"
+)
+
+// Capture downloads a PipelineRun, its TaskRuns, and their pods, scrubbing what could hold secrets or identify the
+// tenant, and writes each of them as YAML to the output directory, for adding the run to the test fixtures; the files
+// can also be read back with analyze --dir
+func Capture(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("capture", flag.ContinueOnError)
+	flags.SetOutput(out)
+	namespace := ""
+	flags.StringVar(&namespace, "namespace", "", "The namespace of the PipelineRun.")
+	flags.StringVar(&namespace, "n", "", "Shorthand for --namespace.")
+	outDir := flags.String("out", "", "The directory the YAML is written to, created if needed.")
+	kubeconfig := flags.String("kubeconfig", "", "The kubeconfig file to use, like kubectl's.")
+	kubeContext := flags.String("context", "", "The name of the kubeconfig context to use.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// allow the flags after the name too, like kubectl
+	if flags.NArg() > 0 {
+		name := flags.Arg(0)
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return err
+		}
+		args = append([]string{name}, flags.Args()...)
+	} else {
+		args = flags.Args()
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("capture requires the name of one PipelineRun")
+	}
+	if len(namespace) == 0 {
+		return fmt.Errorf("capture requires --namespace")
+	}
+	if len(*outDir) == 0 {
+		return fmt.Errorf("capture requires --out")
+	}
+
+	c, err := clusterClient(*kubeconfig, *kubeContext)
+	if err != nil {
+		return err
+	}
+	objs, err := capture(ctx, c, namespace, args[0])
+	if err != nil {
+		return err
+	}
+	return writeCapture(objs, *outDir, out)
+}
+
+// capture gets the objects as unstructured, so they are written back as the cluster served them, in v1 or v1beta1
+func capture(ctx context.Context, c client.Client, namespace, name string) ([]*unstructured.Unstructured, error) {
+	version := "v1"
+	pr := &unstructured.Unstructured{}
+	pr.SetGroupVersionKind(schema.GroupVersionKind{Group: pipeline.GroupName, Version: version, Kind: "PipelineRun"})
+	err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, pr)
+	if meta.IsNoMatchError(err) {
+		version = "v1beta1"
+		pr.SetGroupVersionKind(schema.GroupVersionKind{Group: pipeline.GroupName, Version: version, Kind: "PipelineRun"})
+		err = c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, pr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	objs := []*unstructured.Unstructured{pr}
+
+	for _, gvk := range []schema.GroupVersionKind{
+		{Group: pipeline.GroupName, Version: version, Kind: "TaskRunList"},
+		{Version: "v1", Kind: "PodList"},
+	} {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk)
+		if err = c.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{pipeline.PipelineRunLabelKey: name}); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			objs = append(objs, &list.Items[i])
+		}
+	}
+
+	s := newScrubber(namespace)
+	for _, obj := range objs {
+		s.scrub(obj)
+	}
+	return objs, nil
+}
+
+func writeCapture(objs []*unstructured.Unstructured, dir string, out io.Writer) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		buf, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s.yaml", strings.ToLower(obj.GetKind()), obj.GetName()))
+		if err = os.WriteFile(path, buf, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, path)
+	}
+	return nil
+}
+
+// scrubber removes what could hold secrets, like parameter, result, environment, and annotation values, and
+// replaces what identifies the tenant or the cluster, like the namespace, uids, nodes, service accounts, images, and
+// secret and volume names, keeping the timestamps, conditions, names, and labels the collectors work off of
+type scrubber struct {
+	namespace string
+	// uids map the real uids to generated ones, so owner references still line up
+	uids map[string]string
+	// volumes map the real volume names to generated ones, so volume mounts still line up
+	volumes map[string]string
+}
+
+func newScrubber(namespace string) *scrubber {
+	return &scrubber{namespace: namespace, uids: map[string]string{}, volumes: map[string]string{}}
+}
+
+func (s *scrubber) volume(name string) string {
+	if len(name) == 0 {
+		return name
+	}
+	if _, ok := s.volumes[name]; !ok {
+		s.volumes[name] = fmt.Sprintf("volume-%d", len(s.volumes)+1)
+	}
+	return s.volumes[name]
+}
+
+// keepAnnotation is true for the annotations whose values the collectors read: tekton's own, the exporter's, and
+// the configured trigger time annotations; only the values of the others are scrubbed, as some collectors look
+// for the key alone
+func keepAnnotation(key string) bool {
+	for _, prefix := range []string{"tekton.dev/", "pipeline.tekton.dev/", "pipelineservice.appstudio.io/"} {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	for _, annotation := range Settings.TriggerTimeAnnotations {
+		if key == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *scrubber) uid(uid string) string {
+	if len(uid) == 0 {
+		return uid
+	}
+	if _, ok := s.uids[uid]; !ok {
+		s.uids[uid] = fmt.Sprintf("00000000-0000-0000-0000-%012d", len(s.uids)+1)
+	}
+	return s.uids[uid]
+}
+
+func (s *scrubber) scrub(obj *unstructured.Unstructured) {
+	obj.SetNamespace(CaptureNamespace)
+	obj.SetUID(types.UID(s.uid(string(obj.GetUID()))))
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	obj.SetSelfLink("")
+	owners := obj.GetOwnerReferences()
+	for i := range owners {
+		owners[i].UID = types.UID(s.uid(string(owners[i].UID)))
+	}
+	obj.SetOwnerReferences(owners)
+	annotations := obj.GetAnnotations()
+	delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+	for k := range annotations {
+		if !keepAnnotation(k) {
+			annotations[k] = scrubbed
+		}
+	}
+	obj.SetAnnotations(annotations)
+	labels := obj.GetLabels()
+	for k, v := range labels {
+		if v == s.namespace {
+			labels[k] = CaptureNamespace
+		}
+	}
+	obj.SetLabels(labels)
+
+	switch obj.GetKind() {
+	case "Pod":
+		unstructured.RemoveNestedField(obj.Object, "spec", "nodeName")
+		for _, field := range []string{"hostIP", "hostIPs", "podIP", "podIPs"} {
+			unstructured.RemoveNestedField(obj.Object, "status", field)
+		}
+		for _, field := range []string{"serviceAccountName", "serviceAccount"} {
+			scrubField(obj.Object, "spec", field)
+		}
+		scrubSlice(obj.Object, scrubName, "spec", "imagePullSecrets")
+		scrubSlice(obj.Object, func(volume map[string]interface{}) {
+			if name, ok := volume["name"].(string); ok {
+				volume["name"] = s.volume(name)
+			}
+			scrubVolumeSource(volume)
+		}, "spec", "volumes")
+		for _, containers := range []string{"initContainers", "containers", "ephemeralContainers"} {
+			scrubSlice(obj.Object, func(container map[string]interface{}) {
+				scrubSlice(container, scrubEnv, "env")
+				scrubSlice(container, func(from map[string]interface{}) {
+					scrubField(from, "secretRef", "name")
+					scrubField(from, "configMapRef", "name")
+				}, "envFrom")
+				scrubSlice(container, func(mount map[string]interface{}) {
+					if name, ok := mount["name"].(string); ok {
+						mount["name"] = s.volume(name)
+					}
+				}, "volumeMounts")
+				scrubField(container, "image")
+				delete(container, "args")
+			}, "spec", containers)
+		}
+		for _, statuses := range []string{"initContainerStatuses", "containerStatuses", "ephemeralContainerStatuses"} {
+			scrubSlice(obj.Object, scrubImage, "status", statuses)
+		}
+	default:
+		scrubSlice(obj.Object, scrubValue, "spec", "params")
+		// the v1beta1 and v1 places of the service accounts
+		scrubField(obj.Object, "spec", "serviceAccountName")
+		scrubField(obj.Object, "spec", "taskRunTemplate", "serviceAccountName")
+		scrubSlice(obj.Object, func(spec map[string]interface{}) {
+			scrubField(spec, "serviceAccountName")
+			scrubField(spec, "taskServiceAccountName")
+		}, "spec", "taskRunSpecs")
+		scrubSlice(obj.Object, scrubVolumeSource, "spec", "workspaces")
+		// the resolver params can point at private repositories, and the source recorded from them as well
+		scrubSlice(obj.Object, scrubValue, "spec", "pipelineRef", "params")
+		scrubSlice(obj.Object, scrubValue, "spec", "taskRef", "params")
+		unstructured.RemoveNestedField(obj.Object, "status", "provenance")
+		scrubSlice(obj.Object, scrubImage, "status", "steps")
+		scrubSlice(obj.Object, scrubImage, "status", "sidecars")
+		// the v1beta1 and v1 names of the results
+		for _, results := range []string{"pipelineResults", "results", "taskResults"} {
+			scrubSlice(obj.Object, scrubValue, "status", results)
+		}
+		// the step scripts can have credentials pasted in, and are not used by the collectors
+		scrubSteps(obj.Object, "status", "taskSpec", "steps")
+		scrubSteps(obj.Object, "spec", "taskSpec", "steps")
+		scrubSteps(obj.Object, "status", "taskSpec", "sidecars")
+		scrubSteps(obj.Object, "spec", "taskSpec", "sidecars")
+		for _, tasks := range []string{"tasks", "finally"} {
+			scrubSlice(obj.Object, func(task map[string]interface{}) {
+				scrubSlice(task, scrubValue, "params")
+				scrubSlice(task, scrubValue, "taskRef", "params")
+				scrubSteps(task, "taskSpec", "steps")
+				scrubSteps(task, "taskSpec", "sidecars")
+			}, "status", "pipelineSpec", tasks)
+		}
+	}
+}
+
+// scrubField replaces the string at the fields, if there is one
+func scrubField(obj map[string]interface{}, fields ...string) {
+	if _, found, err := unstructured.NestedString(obj, fields...); found && err == nil {
+		_ = unstructured.SetNestedField(obj, scrubbed, fields...)
+	}
+}
+
+func scrubName(m map[string]interface{}) {
+	scrubField(m, "name")
+}
+
+// scrubImage replaces the image and image id of a container, step, or sidecar, as they can name private registries
+func scrubImage(m map[string]interface{}) {
+	scrubField(m, "image")
+	scrubField(m, "imageID")
+}
+
+// scrubEnv replaces the value of an environment variable and the name of the secret or config map it comes from
+func scrubEnv(m map[string]interface{}) {
+	scrubValue(m)
+	scrubField(m, "valueFrom", "secretKeyRef", "name")
+	scrubField(m, "valueFrom", "configMapKeyRef", "name")
+}
+
+// scrubVolumeSource replaces the names of the secrets, config maps, and claims behind a pod volume or a workspace
+func scrubVolumeSource(m map[string]interface{}) {
+	scrubField(m, "secret", "secretName")
+	scrubField(m, "configMap", "name")
+	scrubField(m, "persistentVolumeClaim", "claimName")
+	scrubSlice(m, func(source map[string]interface{}) {
+		scrubField(source, "secret", "name")
+		scrubField(source, "configMap", "name")
+	}, "projected", "sources")
+}
+
+// scrubSlice calls scrub with each map of the slice at the fields, if there is one
+func scrubSlice(obj map[string]interface{}, scrub func(map[string]interface{}), fields ...string) {
+	items, found, err := unstructured.NestedSlice(obj, fields...)
+	if !found || err != nil {
+		return
+	}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			scrub(m)
+		}
+	}
+	_ = unstructured.SetNestedSlice(obj, items, fields...)
+}
+
+// scrubValue replaces the value of a param, result, or environment variable, leaving any valueFrom reference
+func scrubValue(m map[string]interface{}) {
+	if _, ok := m["value"]; ok {
+		m["value"] = scrubbed
+	}
+}
+
+func scrubSteps(obj map[string]interface{}, fields ...string) {
+	scrubSlice(obj, func(step map[string]interface{}) {
+		if _, ok := step["script"]; ok {
+			step["script"] = scrubbed
+		}
+		scrubSlice(step, scrubEnv, "env")
+		scrubField(step, "image")
+		delete(step, "args")
+	}, fields...)
+}
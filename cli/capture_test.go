@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCapture(t *testing.T) {
+	pr := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "build-1", Namespace: "tenant-a", UID: "real-pr-uid",
+			Labels: map[string]string{"appstudio.openshift.io/tenant": "tenant-a"},
+			Annotations: map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}",
+				"pipelinesascode.tekton.dev/repo-url":       "https://git.example.com/private-repo",
+				"pipelineservice.appstudio.io/trigger-time": "2023-01-01T00:00:00Z"}},
+		Spec: v1.PipelineRunSpec{Params: []v1.Param{{Name: "token", Value: *v1.NewStructuredValues("secret-token")}},
+			PipelineRef: &v1.PipelineRef{ResolverRef: v1.ResolverRef{Resolver: "git",
+				Params: []v1.Param{{Name: "url", Value: *v1.NewStructuredValues("https://git.example.com/private-repo")}}}},
+			TaskRunTemplate: v1.PipelineTaskRunTemplate{ServiceAccountName: "tenant-sa"},
+			Workspaces:      []v1.WorkspaceBinding{{Name: "auth", Secret: &corev1.SecretVolumeSource{SecretName: "git-credentials"}}}},
+	}
+	tr := &v1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "build-1-clone", Namespace: "tenant-a", UID: "real-tr-uid",
+			Labels:          map[string]string{"tekton.dev/pipelineRun": "build-1"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "PipelineRun", Name: "build-1", UID: "real-pr-uid"}}},
+	}
+	otherTR := &v1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "build-2-clone", Namespace: "tenant-a",
+			Labels: map[string]string{"tekton.dev/pipelineRun": "build-2"}},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "build-1-clone-pod", Namespace: "tenant-a",
+			Labels:          map[string]string{"tekton.dev/pipelineRun": "build-1"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "TaskRun", Name: "build-1-clone", UID: "real-tr-uid"}}},
+		Spec: corev1.PodSpec{NodeName: "worker-1", ServiceAccountName: "tenant-sa",
+			ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry-credentials"}},
+			Volumes: []corev1.Volume{{Name: "ws-credentials",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "git-credentials"}}}},
+			Containers: []corev1.Container{{Name: "step-clone", Image: "registry.example.com/private/clone:1",
+				Args:         []string{"--password", "hunter2"},
+				VolumeMounts: []corev1.VolumeMount{{Name: "ws-credentials", MountPath: "/workspace/auth"}},
+				Env: []corev1.EnvVar{{Name: "PASSWORD", Value: "hunter2"}, {Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "api-token"}, Key: "token"}}}}}}},
+		Status: corev1.PodStatus{PodIP: "10.0.0.1", HostIP: "10.0.1.1", ContainerStatuses: []corev1.ContainerStatus{{Name: "step-clone",
+			Image: "registry.example.com/private/clone:1", ImageID: "registry.example.com/private/clone@sha256:abc"}}},
+	}
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr, tr, otherTR, pod).Build()
+
+	objs, err := capture(context.Background(), c, "tenant-a", "build-1")
+	assert.NoError(t, err)
+	assert.Len(t, objs, 3)
+	for _, obj := range objs {
+		assert.Equal(t, CaptureNamespace, obj.GetNamespace())
+		assert.Empty(t, obj.GetResourceVersion())
+	}
+	assert.Equal(t, CaptureNamespace, objs[0].GetLabels()["appstudio.openshift.io/tenant"])
+	assert.NotContains(t, objs[0].GetAnnotations(), "kubectl.kubernetes.io/last-applied-configuration")
+	// the annotation keys stay, and the values the collectors read are kept
+	assert.Equal(t, "scrubbed", objs[0].GetAnnotations()["pipelinesascode.tekton.dev/repo-url"])
+	assert.Equal(t, "2023-01-01T00:00:00Z", objs[0].GetAnnotations()["pipelineservice.appstudio.io/trigger-time"])
+	// the volume mounts still line up after the volume names are replaced
+	volumes, _, _ := unstructured.NestedSlice(objs[2].Object, "spec", "volumes")
+	containers, _, _ := unstructured.NestedSlice(objs[2].Object, "spec", "containers")
+	mounts, _, _ := unstructured.NestedSlice(containers[0].(map[string]interface{}), "volumeMounts")
+	assert.Equal(t, volumes[0].(map[string]interface{})["name"], mounts[0].(map[string]interface{})["name"])
+	// the owner references still line up after the uids are replaced
+	assert.Equal(t, objs[0].GetUID(), objs[1].GetOwnerReferences()[0].UID)
+	assert.Equal(t, objs[1].GetUID(), objs[2].GetOwnerReferences()[0].UID)
+	assert.NotEqual(t, "real-pr-uid", string(objs[0].GetUID()))
+
+	dir := filepath.Join(t.TempDir(), "fixtures")
+	out := &bytes.Buffer{}
+	assert.NoError(t, writeCapture(objs, dir, out))
+	assert.Contains(t, out.String(), "pipelinerun-build-1.yaml")
+	var all []byte
+	for _, name := range []string{"pipelinerun-build-1.yaml", "taskrun-build-1-clone.yaml", "pod-build-1-clone-pod.yaml"} {
+		buf, err := os.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		all = append(all, buf...)
+	}
+	for _, leaked := range []string{"tenant-a", "secret-token", "hunter2", "worker-1", "10.0.0.1", "real-pr-uid", "real-tr-uid",
+		"private-repo", "tenant-sa", "git-credentials", "registry-credentials", "ws-credentials", "api-token",
+		"registry.example.com"} {
+		assert.NotContains(t, string(all), leaked)
+	}
+
+	// the captured runs can be read back like any exported YAML
+	r, err := loadDir(context.Background(), dir)
+	assert.NoError(t, err)
+	assert.Len(t, r.pipelineRuns, 1)
+	assert.Len(t, r.taskRunsOf(r.pipelineRuns[0]), 1)
+
+	_, err = capture(context.Background(), c, "tenant-a", "missing")
+	assert.Error(t, err)
+}
+
+func TestCaptureArgs(t *testing.T) {
+	assert.Error(t, Capture(context.Background(), []string{"-n", "test-namespace", "--out", "dir"}, &bytes.Buffer{}))
+	assert.Error(t, Capture(context.Background(), []string{"build-1", "--out", "dir"}, &bytes.Buffer{}))
+	assert.Error(t, Capture(context.Background(), []string{"build-1", "-n", "test-namespace"}, &bytes.Buffer{}))
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/rest"
+)
+
+// tektonCRDs are the CRDs the exporter watches
+var tektonCRDs = []string{"pipelineruns.tekton.dev", "taskruns.tekton.dev"}
+
+func init() {
+	register("check", Check)
+}
+
+// Check verifies the exporter is allowed to do everything it needs to, and that the Tekton CRDs are served, printing
+// what to fix otherwise; it is an error if anything but optional permissions is missing
+func Check(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(out)
+	readOnly := flags.Bool("read-only", false, "Check the permissions of the exporter's --read-only mode.")
+	delegatedAuth := flags.Bool("delegated-auth", false, "Check the permissions of the exporter's --metrics-auth, --debug-auth and --admin-auth.")
+	kubeconfig := flags.String("kubeconfig", "", "The kubeconfig file to use, like kubectl's.")
+	kubeContext := flags.String("context", "", "The name of the kubeconfig context to use.")
+	as := flags.String("as", "", "Username to impersonate, like the exporter's service account, system:serviceaccount:<namespace>:<name>.")
+	asGroups := stringsFlag{}
+	flags.Var(&asGroups, "as-group", "Group to impersonate; can be repeated, and requires --as.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(asGroups) > 0 && len(*as) == 0 {
+		return fmt.Errorf("--as-group requires --as")
+	}
+	cfg, err := restConfig(*kubeconfig, *kubeContext)
+	if err != nil {
+		return err
+	}
+	if len(*as) > 0 {
+		cfg.Impersonate = rest.ImpersonationConfig{UserName: *as, Groups: asGroups}
+	}
+	reviews, err := authorizationclient.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	crds, err := apiextensionsclient.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	access := collector.RequiredAccess(collector.WithSettings(Settings), collector.WithReadOnly(*readOnly), collector.WithDelegatedAuth(*delegatedAuth))
+	return check(ctx, reviews.SelfSubjectAccessReviews(), crds.CustomResourceDefinitions(), access, out)
+}
+
+func check(ctx context.Context, reviews authorizationclient.SelfSubjectAccessReviewInterface, crds apiextensionsclient.CustomResourceDefinitionInterface, access []collector.Access, out io.Writer) error {
+	problems := []string{}
+	warnings := []string{}
+
+	fmt.Fprintln(out, "Permissions:")
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, a := range access {
+		review, err := reviews.Create(ctx, &authorizationv1.SelfSubjectAccessReview{Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{Namespace: a.Namespace, Group: a.Group, Resource: a.Resource, Verb: a.Verb},
+		}}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to review %s: %w", a.String(), err)
+		}
+		result := "ok"
+		if !review.Status.Allowed {
+			result = "MISSING"
+			message := fmt.Sprintf("grant %s, used for %s", a.String(), a.Reason)
+			if a.Optional {
+				result = "missing"
+				warnings = append(warnings, message)
+			} else {
+				problems = append(problems, message)
+			}
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", result, a.String(), a.Reason)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "\nCRDs:")
+	for _, name := range tektonCRDs {
+		crd, err := crds.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			fmt.Fprintf(out, "  %s: not found\n", name)
+			problems = append(problems, fmt.Sprintf("install OpenShift Pipelines, or Tekton, as the %s CRD does not exist; the collectors will not start until it does", name))
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(out, "  %s: %s\n", name, err.Error())
+			problems = append(problems, fmt.Sprintf("make sure the %s CRD can be read: %s", name, err.Error()))
+			continue
+		}
+		served := []string{}
+		for _, v := range crd.Spec.Versions {
+			if v.Served {
+				served = append(served, v.Name)
+			}
+		}
+		watched := ""
+		for _, v := range []string{"v1", "v1beta1"} {
+			if contains(served, v) {
+				watched = v
+				break
+			}
+		}
+		fmt.Fprintf(out, "  %s: served %s, established %t, watched %s\n", name, strings.Join(served, ","), established(crd), watched)
+		switch {
+		case len(watched) == 0:
+			problems = append(problems, fmt.Sprintf("upgrade Tekton, as %s serves neither v1 nor v1beta1", name))
+		case !established(crd):
+			problems = append(problems, fmt.Sprintf("wait for the %s CRD to be established", name))
+		}
+	}
+
+	for _, warning := range warnings {
+		fmt.Fprintf(out, "\nWARNING: %s", warning)
+	}
+	for _, problem := range problems {
+		fmt.Fprintf(out, "\nERROR: %s", problem)
+	}
+	if len(warnings)+len(problems) > 0 {
+		fmt.Fprintln(out)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+	return nil
+}
+
+func established(crd *apiextensionsv1.CustomResourceDefinition) bool {
+	for _, c := range crd.Status.Conditions {
+		if c.Type == apiextensionsv1.Established {
+			return c.Status == apiextensionsv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func tektonCRD(name string, established bool, versions ...string) *apiextensionsv1.CustomResourceDefinition {
+	crd := &apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	for _, v := range versions {
+		crd.Spec.Versions = append(crd.Spec.Versions, apiextensionsv1.CustomResourceDefinitionVersion{Name: v, Served: true})
+	}
+	status := apiextensionsv1.ConditionFalse
+	if established {
+		status = apiextensionsv1.ConditionTrue
+	}
+	crd.Status.Conditions = []apiextensionsv1.CustomResourceDefinitionCondition{{Type: apiextensionsv1.Established, Status: status}}
+	return crd
+}
+
+// reviewsDenying allows everything but the verbs on the resources
+func reviewsDenying(denied map[string]string) *kubefake.Clientset {
+	kube := kubefake.NewSimpleClientset()
+	kube.PrependReactor("create", "selfsubjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = denied[attributes.Resource] != attributes.Verb
+		return true, review, nil
+	})
+	return kube
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	access := collector.RequiredAccess()
+	crds := apiextensionsfake.NewSimpleClientset(tektonCRD("pipelineruns.tekton.dev", true, "v1beta1", "v1"), tektonCRD("taskruns.tekton.dev", true, "v1beta1"))
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, check(ctx, reviewsDenying(nil).AuthorizationV1().SelfSubjectAccessReviews(), crds.ApiextensionsV1().CustomResourceDefinitions(), access, out))
+	assert.Contains(t, out.String(), "ok  patch pipelineruns.tekton.dev")
+	assert.Contains(t, out.String(), "pipelineruns.tekton.dev: served v1beta1,v1, established true, watched v1")
+	assert.Contains(t, out.String(), "taskruns.tekton.dev: served v1beta1, established true, watched v1beta1")
+	assert.NotContains(t, out.String(), "ERROR")
+
+	// a missing optional permission is only a warning
+	out = &bytes.Buffer{}
+	assert.NoError(t, check(ctx, reviewsDenying(map[string]string{"clusterversions": "get"}).AuthorizationV1().SelfSubjectAccessReviews(), crds.ApiextensionsV1().CustomResourceDefinitions(), access, out))
+	assert.Contains(t, out.String(), "WARNING: grant get clusterversions.config.openshift.io")
+
+	out = &bytes.Buffer{}
+	assert.Error(t, check(ctx, reviewsDenying(map[string]string{"pipelineruns": "patch"}).AuthorizationV1().SelfSubjectAccessReviews(), crds.ApiextensionsV1().CustomResourceDefinitions(), access, out))
+	assert.Contains(t, out.String(), "MISSING  patch pipelineruns.tekton.dev")
+	assert.Contains(t, out.String(), "ERROR: grant patch pipelineruns.tekton.dev, used for labeling throttled PipelineRuns, unless --read-only")
+
+	out = &bytes.Buffer{}
+	crds = apiextensionsfake.NewSimpleClientset(tektonCRD("pipelineruns.tekton.dev", false, "v1"))
+	assert.Error(t, check(ctx, reviewsDenying(nil).AuthorizationV1().SelfSubjectAccessReviews(), crds.ApiextensionsV1().CustomResourceDefinitions(), access, out))
+	assert.Contains(t, out.String(), "ERROR: wait for the pipelineruns.tekton.dev CRD to be established")
+	assert.Contains(t, out.String(), "ERROR: install OpenShift Pipelines, or Tekton, as the taskruns.tekton.dev CRD does not exist")
+}
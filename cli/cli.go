@@ -0,0 +1,83 @@
+/*
+ Copyright 2023 The Pipeline Service Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package cli holds the subcommands of the exporter binary, which run the collectors' calculations once and print
+// the results, vs. serving the metrics
+package cli
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// Settings are the collectors' tunables from the environment, set by the exporter binary before running a
+// subcommand, so the subcommands see the same configuration as the exporter would
+var Settings = collector.Settings{}
+
+// Command runs a subcommand with the arguments following its name, printing its results to out
+type Command func(ctx context.Context, args []string, out io.Writer) error
+
+var commands = map[string]Command{}
+
+// stringsFlag allows for a flag to be specified multiple times
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// register is called from the init of each subcommand's file
+func register(name string, cmd Command) {
+	commands[name] = cmd
+}
+
+// Lookup returns the subcommand with the name, if there is one
+func Lookup(name string) (Command, bool) {
+	cmd, ok := commands[name]
+	return cmd, ok
+}
+
+// Names are the names of the subcommands, sorted
+func Names() []string {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// restConfig is the config for the kubeconfig and context, with the same defaults as kubectl when they are empty
+func restConfig(kubeconfig, kubeContext string) (*rest.Config, error) {
+	if len(kubeconfig) == 0 {
+		return config.GetConfigWithContext(kubeContext)
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext}).ClientConfig()
+}
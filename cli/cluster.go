@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterClient is a client for the kubeconfig and context that knows the tekton and core types
+func clusterClient(kubeconfig, kubeContext string) (client.Client, error) {
+	cfg, err := restConfig(kubeconfig, kubeContext)
+	if err != nil {
+		return nil, err
+	}
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	_ = v1beta1.AddToScheme(scheme)
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
+
+// loadCluster lists the PipelineRuns and TaskRuns of the namespace, or all namespaces when empty, falling back to
+// v1beta1 on clusters not serving v1 like the exporter does
+func loadCluster(ctx context.Context, c client.Client, namespace string) (*runs, error) {
+	r := &runs{}
+	prs := &v1.PipelineRunList{}
+	err := c.List(ctx, prs, client.InNamespace(namespace))
+	if meta.IsNoMatchError(err) {
+		return loadClusterV1Beta1(ctx, c, namespace)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range prs.Items {
+		r.pipelineRuns = append(r.pipelineRuns, &prs.Items[i])
+	}
+	trs := &v1.TaskRunList{}
+	if err = c.List(ctx, trs, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range trs.Items {
+		r.taskRuns = append(r.taskRuns, &trs.Items[i])
+	}
+	return r, nil
+}
+
+func loadClusterV1Beta1(ctx context.Context, c client.Client, namespace string) (*runs, error) {
+	r := &runs{}
+	prs := &v1beta1.PipelineRunList{}
+	if err := c.List(ctx, prs, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range prs.Items {
+		pr := &v1.PipelineRun{}
+		if err := prs.Items[i].ConvertTo(ctx, pr); err != nil {
+			return nil, err
+		}
+		r.pipelineRuns = append(r.pipelineRuns, pr)
+	}
+	trs := &v1beta1.TaskRunList{}
+	if err := c.List(ctx, trs, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range trs.Items {
+		tr := &v1.TaskRun{}
+		if err := trs.Items[i].ConvertTo(ctx, tr); err != nil {
+			return nil, err
+		}
+		r.taskRuns = append(r.taskRuns, tr)
+	}
+	return r, nil
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func init() {
+	register("explain", Explain)
+}
+
+// Explain fetches a PipelineRun and its TaskRuns from the cluster, and prints what the collectors make of them: the
+// gaps, which collectors record their metrics and why not, any throttling, and the values that would be observed
+func Explain(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	flags.SetOutput(out)
+	namespace := ""
+	flags.StringVar(&namespace, "namespace", "", "The namespace of the PipelineRun.")
+	flags.StringVar(&namespace, "n", "", "Shorthand for --namespace.")
+	kubeconfig := flags.String("kubeconfig", "", "The kubeconfig file to use, like kubectl's.")
+	kubeContext := flags.String("context", "", "The name of the kubeconfig context to use.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// allow the flags after the name too, like kubectl
+	if flags.NArg() > 0 {
+		name := flags.Arg(0)
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return err
+		}
+		args = append([]string{name}, flags.Args()...)
+	} else {
+		args = flags.Args()
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("explain requires the name of one PipelineRun")
+	}
+	if len(namespace) == 0 {
+		return fmt.Errorf("explain requires --namespace")
+	}
+
+	c, err := clusterClient(*kubeconfig, *kubeContext)
+	if err != nil {
+		return err
+	}
+	return explain(ctx, c, namespace, args[0], out)
+}
+
+func explain(ctx context.Context, c client.Client, namespace, name string, out io.Writer) error {
+	collector.ApplySettings(Settings)
+	e, err := collector.Explain(ctx, c, namespace, name)
+	if err != nil {
+		return err
+	}
+	pr := e.PipelineRun
+	fmt.Fprintf(out, "PipelineRun %s/%s\n", pr.Namespace, pr.Name)
+	fmt.Fprintf(out, "  Status:     %s\n", conditionReason(pr.Status.GetCondition(apis.ConditionSucceeded)))
+	fmt.Fprintf(out, "  Created:    %s\n", timestamp(&pr.CreationTimestamp))
+	fmt.Fprintf(out, "  Started:    %s\n", timestamp(pr.Status.StartTime))
+	fmt.Fprintf(out, "  Completed:  %s\n", timestamp(pr.Status.CompletionTime))
+	throttledBy := "-"
+	if len(e.ThrottledBy) > 0 {
+		throttledBy = e.ThrottledBy
+	}
+	fmt.Fprintf(out, "  Throttled:  %s\n", throttledBy)
+
+	fmt.Fprintln(out, "\nTaskRuns:")
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tTASK\tCREATED\tSTARTED\tCOMPLETED\tSTATUS")
+	for _, tr := range e.TaskRuns {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", tr.Name, tr.Labels[pipeline.PipelineTaskLabelKey], timestamp(&tr.CreationTimestamp),
+			timestamp(tr.Status.StartTime), timestamp(tr.Status.CompletionTime), conditionReason(tr.Status.GetCondition(apis.ConditionSucceeded)))
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "\nGaps:")
+	if !e.Gaps.Calculated() {
+		fmt.Fprintf(out, "  not calculated: %s\n", e.Gaps.Reason)
+	} else {
+		w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "  COMPLETED\tUPCOMING\tGAP(ms)")
+		for _, entry := range e.Gaps.Entries {
+			fmt.Fprintf(w, "  %s\t%s\t%.0f\n", entry.Completed, entry.Upcoming, entry.Gap)
+		}
+		if err = w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  total %.0fms of %.0fms\n", e.Gaps.Total, e.Gaps.Duration)
+	}
+
+	fmt.Fprintln(out, "\nDecisions:")
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  COLLECTOR\tRUN\tRECORDED\tREASON")
+	for _, d := range e.Decisions {
+		fmt.Fprintf(w, "  %s\t%s\t%t\t%s\n", d.Collector, d.Run, d.Recorded, d.Reason)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "\nObservations:")
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  METRIC\tLABELS\tVALUE")
+	for _, o := range e.Observations {
+		fmt.Fprintf(w, "  %s\t%s\t%v\n", o.Metric, formatLabels(o.Labels), o.Value)
+	}
+	return w.Flush()
+}
+
+func timestamp(t *metav1.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func conditionReason(c *apis.Condition) string {
+	if c == nil {
+		return "-"
+	}
+	return c.Reason
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := []string{}
+	for name, value := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, value))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestExplainOutput(t *testing.T) {
+	r := &runs{}
+	assert.NoError(t, r.load(context.Background(), strings.NewReader(analyzeListYaml)))
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(r.pipelineRuns[0], r.taskRuns[0], r.taskRuns[1]).Build()
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, explain(context.Background(), c, "test-namespace", "build-1", out))
+	assert.Contains(t, out.String(), "PipelineRun test-namespace/build-1")
+	assert.Contains(t, out.String(), "Completed:  2023-06-01T10:10:00Z")
+	assert.Regexp(t, `build-1-clone\s+clone\s+2023-06-01T10:00:01Z`, out.String())
+	assert.Regexp(t, `clone\s+build\s+3000`, out.String())
+	assert.Contains(t, out.String(), "total 4000ms of 600000ms")
+	assert.Regexp(t, `overhead\s+build-1\s+true`, out.String())
+	assert.Contains(t, out.String(), "pipeline_service_execution_overhead_percentage")
+	assert.Regexp(t, `pipelinerun_gap_between_taskruns_milliseconds\s+\{namespace="test-namespace",phase="kickoff",status="succeded"\}\s+1000`, out.String())
+
+	assert.Error(t, explain(context.Background(), c, "test-namespace", "missing", out))
+
+	// the overhead is filtered with the exporter's FILTER_THRESHOLD, as the run is shorter
+	defer func(s collector.Settings) {
+		Settings = s
+		collector.ApplySettings(s)
+	}(Settings)
+	Settings = collector.Settings{FilterThreshold: pointer.Float64(900000)}
+	out = &bytes.Buffer{}
+	assert.NoError(t, explain(context.Background(), c, "test-namespace", "build-1", out))
+	assert.NotContains(t, out.String(), "pipeline_service_execution_overhead_percentage")
+}
+
+func TestExplainArgs(t *testing.T) {
+	assert.Error(t, Explain(context.Background(), []string{"-n", "test-namespace"}, &bytes.Buffer{}))
+	assert.Error(t, Explain(context.Background(), []string{"build-1"}, &bytes.Buffer{}))
+	assert.Error(t, Explain(context.Background(), []string{"build-1", "build-2", "-n", "test-namespace"}, &bytes.Buffer{}))
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, `{namespace="ns",status="failed"}`, formatLabels(map[string]string{"status": "failed", "namespace": "ns"}))
+	assert.Equal(t, "{}", formatLabels(map[string]string{}))
+}
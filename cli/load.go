@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+var decoder runtime.Decoder
+
+func init() {
+	scheme := runtime.NewScheme()
+	// the core types include List, which is what kubectl get -o yaml returns for more than one object
+	_ = corev1.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	_ = v1beta1.AddToScheme(scheme)
+	decoder = serializer.NewCodecFactory(scheme).UniversalDeserializer()
+}
+
+// runs are the PipelineRuns and TaskRuns read from exported YAML or JSON, converted to v1 like the tekton
+// conversion webhook would
+type runs struct {
+	pipelineRuns []*v1.PipelineRun
+	taskRuns     []*v1.TaskRun
+}
+
+// loadDir reads every .yaml, .yml, and .json file under dir; each file can hold any number of documents, each either
+// a PipelineRun, TaskRun, or a List of them, and any other kind is ignored
+func loadDir(ctx context.Context, dir string) (*runs, error) {
+	r := &runs{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err = r.load(ctx, f); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	})
+	return r, err
+}
+
+func (r *runs) load(ctx context.Context, in io.Reader) error {
+	reader := yaml.NewYAMLReader(bufio.NewReader(in))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		if err = r.add(ctx, doc); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *runs) add(ctx context.Context, doc []byte) error {
+	obj, _, err := decoder.Decode(doc, nil, nil)
+	if err != nil {
+		if runtime.IsNotRegisteredError(err) {
+			return nil
+		}
+		return err
+	}
+	switch o := obj.(type) {
+	case *corev1.List:
+		for _, item := range o.Items {
+			if err = r.add(ctx, item.Raw); err != nil {
+				return err
+			}
+		}
+	case *v1.PipelineRun:
+		r.pipelineRuns = append(r.pipelineRuns, o)
+	case *v1.TaskRun:
+		r.taskRuns = append(r.taskRuns, o)
+	case *v1beta1.PipelineRun:
+		pr := &v1.PipelineRun{}
+		if err = o.ConvertTo(ctx, pr); err != nil {
+			return err
+		}
+		r.pipelineRuns = append(r.pipelineRuns, pr)
+	case *v1beta1.TaskRun:
+		tr := &v1.TaskRun{}
+		if err = o.ConvertTo(ctx, tr); err != nil {
+			return err
+		}
+		r.taskRuns = append(r.taskRuns, tr)
+	}
+	return nil
+}
+
+// taskRunsOf are the loaded TaskRuns labeled as belonging to the PipelineRun
+func (r *runs) taskRunsOf(pr *v1.PipelineRun) []*v1.TaskRun {
+	taskRuns := []*v1.TaskRun{}
+	for _, tr := range r.taskRuns {
+		if tr.Namespace == pr.Namespace && tr.Labels[pipeline.PipelineRunLabelKey] == pr.Name {
+			taskRuns = append(taskRuns, tr)
+		}
+	}
+	return taskRuns
+}
+
+// auditEvent is the part of an API server audit event holding the PipelineRun or TaskRun, which is only there with the
+// RequestResponse audit level
+type auditEvent struct {
+	Stage     string `json:"stage"`
+	ObjectRef *struct {
+		APIGroup string `json:"apiGroup"`
+	} `json:"objectRef"`
+	ResponseObject json.RawMessage `json:"responseObject"`
+}
+
+// loadAudit reads API server audit events, one JSON object per line, keeping the tekton objects of the completed
+// responses
+func (r *runs) loadAudit(ctx context.Context, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	// PipelineRuns with many TaskRuns, or big results, do not fit the default line size
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		event := &auditEvent{}
+		if err := json.Unmarshal(line, event); err != nil {
+			return err
+		}
+		if event.Stage != "ResponseComplete" || event.ObjectRef == nil || event.ObjectRef.APIGroup != pipeline.GroupName || len(event.ResponseObject) == 0 {
+			continue
+		}
+		if err := r.add(ctx, event.ResponseObject); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// resultsRecord is a Tekton Results record, as returned by its REST API or tkn-results with -o json
+type resultsRecord struct {
+	Data *struct {
+		Type  string `json:"type"`
+		Value []byte `json:"value"`
+	} `json:"data"`
+}
+
+// loadResults reads a stream of Tekton Results records, or lists of them, keeping the PipelineRuns and TaskRuns
+func (r *runs) loadResults(ctx context.Context, in io.Reader) error {
+	decoder := json.NewDecoder(in)
+	for {
+		page := struct {
+			resultsRecord
+			Records []resultsRecord `json:"records"`
+		}{}
+		err := decoder.Decode(&page)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, record := range append(page.Records, page.resultsRecord) {
+			// the logs are records too
+			if record.Data == nil || !strings.HasPrefix(record.Data.Type, pipeline.GroupName+"/") {
+				continue
+			}
+			if err = r.add(ctx, record.Data.Value); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// latest collapses the versions of the same PipelineRun or TaskRun an archive can hold into the last one, or the
+// last completed one, assuming the archive is in chronological order
+func (r *runs) latest() {
+	prs := map[string]*v1.PipelineRun{}
+	prKeys := []string{}
+	for _, pr := range r.pipelineRuns {
+		key := pr.Namespace + "/" + pr.Name
+		previous, seen := prs[key]
+		if !seen {
+			prKeys = append(prKeys, key)
+		}
+		if !seen || !previous.IsDone() || pr.IsDone() {
+			prs[key] = pr
+		}
+	}
+	r.pipelineRuns = []*v1.PipelineRun{}
+	for _, key := range prKeys {
+		r.pipelineRuns = append(r.pipelineRuns, prs[key])
+	}
+
+	trs := map[string]*v1.TaskRun{}
+	trKeys := []string{}
+	for _, tr := range r.taskRuns {
+		key := tr.Namespace + "/" + tr.Name
+		previous, seen := trs[key]
+		if !seen {
+			trKeys = append(trKeys, key)
+		}
+		if !seen || !previous.IsDone() || tr.IsDone() {
+			trs[key] = tr
+		}
+	}
+	r.taskRuns = []*v1.TaskRun{}
+	for _, key := range trKeys {
+		r.taskRuns = append(r.taskRuns, trs[key])
+	}
+}
@@ -0,0 +1,304 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func init() {
+	register("metrics-diff", MetricsDiff)
+}
+
+// MetricsDiff compares the metrics of two exporters, scraped from their endpoints or read from text format dumps,
+// reporting added, removed, and renamed metrics, and the series and histogram buckets that changed, say to validate a
+// release before rolling it out across the fleet; like with top, the flags give the CA, client certificate, and token
+// to scrape endpoints behind TLS and authentication with
+func MetricsDiff(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("metrics-diff", flag.ContinueOnError)
+	flags.SetOutput(out)
+	oldSource := flags.String("old", "", "The metrics endpoint URL, or text format file, of the old exporter.")
+	newSource := flags.String("new", "", "The metrics endpoint URL, or text format file, of the new exporter.")
+	failOnBreaking := flags.Bool("fail-on-breaking", false, "Exit with an error if metrics or series were removed or renamed, or buckets changed.")
+	sf := addScraperFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(*oldSource) == 0 || len(*newSource) == 0 {
+		return fmt.Errorf("metrics-diff requires --old and --new")
+	}
+	s, err := sf.scraper()
+	if err != nil {
+		return err
+	}
+	for _, source := range []string{*oldSource, *newSource} {
+		if s.secured() && strings.HasPrefix(source, "http://") {
+			return fmt.Errorf("--token-file, --ca-file, --cert and --key need https endpoints")
+		}
+	}
+	oldFamilies, err := readMetrics(ctx, *oldSource, s)
+	if err != nil {
+		return err
+	}
+	newFamilies, err := readMetrics(ctx, *newSource, s)
+	if err != nil {
+		return err
+	}
+	d := diffMetrics(oldFamilies, newFamilies)
+	d.print(out)
+	if *failOnBreaking && d.breaking() {
+		return fmt.Errorf("breaking metrics changes found")
+	}
+	return nil
+}
+
+func readMetrics(ctx context.Context, source string, s *scraper) (map[string]*dto.MetricFamily, error) {
+	var in io.Reader
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		// the text format, as that is what the parser reads
+		req.Header.Set("Accept", string(expfmt.FmtText))
+		c := http.DefaultClient
+		if s != nil {
+			if err = s.authorize(req); err != nil {
+				return nil, err
+			}
+			c = s.client
+		}
+		rsp, err := c.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s returned status %d", source, rsp.StatusCode)
+		}
+		in = rsp.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return families, nil
+}
+
+// metricsDiff is what changed between two sets of metric families
+type metricsDiff struct {
+	added   []string
+	removed []string
+	// renamed maps the old names to the new ones
+	renamed     map[string]string
+	typeChanged []string
+	// addedSeries and removedSeries are by metric name
+	addedSeries   map[string][]string
+	removedSeries map[string][]string
+	// bucketChanges are by metric name
+	bucketChanges map[string]string
+}
+
+func diffMetrics(oldFamilies, newFamilies map[string]*dto.MetricFamily) *metricsDiff {
+	d := &metricsDiff{
+		renamed:       map[string]string{},
+		addedSeries:   map[string][]string{},
+		removedSeries: map[string][]string{},
+		bucketChanges: map[string]string{},
+	}
+	for _, name := range sortedNames(newFamilies) {
+		if _, ok := oldFamilies[name]; !ok {
+			d.added = append(d.added, name)
+		}
+	}
+	for _, name := range sortedNames(oldFamilies) {
+		newFamily, ok := newFamilies[name]
+		if !ok {
+			d.removed = append(d.removed, name)
+			continue
+		}
+		oldFamily := oldFamilies[name]
+		if oldFamily.GetType() != newFamily.GetType() {
+			d.typeChanged = append(d.typeChanged, fmt.Sprintf("%s: %s -> %s", name, oldFamily.GetType(), newFamily.GetType()))
+			continue
+		}
+		oldSeries, newSeries := seriesOf(oldFamily), seriesOf(newFamily)
+		for _, s := range sortedKeys(newSeries) {
+			if _, ok := oldSeries[s]; !ok {
+				d.addedSeries[name] = append(d.addedSeries[name], s)
+			}
+		}
+		for _, s := range sortedKeys(oldSeries) {
+			if _, ok := newSeries[s]; !ok {
+				d.removedSeries[name] = append(d.removedSeries[name], s)
+			}
+		}
+		if oldFamily.GetType() == dto.MetricType_HISTOGRAM {
+			oldBuckets, newBuckets := bucketsOf(oldFamily), bucketsOf(newFamily)
+			if len(oldBuckets) > 0 && len(newBuckets) > 0 && oldBuckets != newBuckets {
+				d.bucketChanges[name] = fmt.Sprintf("[%s] -> [%s]", oldBuckets, newBuckets)
+			}
+		}
+	}
+
+	// a removed metric is considered renamed to an added one with the same type and label names, if the old help
+	// points to it like the deprecated aliases do, or the help is the same
+	for _, oldName := range d.removed {
+		oldFamily := oldFamilies[oldName]
+		for _, newName := range d.added {
+			newFamily := newFamilies[newName]
+			if oldFamily.GetType() != newFamily.GetType() || labelNamesOf(oldFamily) != labelNamesOf(newFamily) {
+				continue
+			}
+			if strings.HasPrefix(oldFamily.GetHelp(), "DEPRECATED: use "+newName+".") || undeprecatedHelp(oldFamily.GetHelp()) == newFamily.GetHelp() {
+				d.renamed[oldName] = newName
+				break
+			}
+		}
+	}
+	return d
+}
+
+// undeprecatedHelp strips the prefix the deprecated aliases have in their help
+func undeprecatedHelp(help string) string {
+	if !strings.HasPrefix(help, "DEPRECATED: use ") {
+		return help
+	}
+	if _, rest, found := strings.Cut(help, ". "); found {
+		return rest
+	}
+	return help
+}
+
+func (d *metricsDiff) breaking() bool {
+	return len(d.removed) > 0 || len(d.typeChanged) > 0 || len(d.removedSeries) > 0 || len(d.bucketChanges) > 0
+}
+
+func (d *metricsDiff) print(out io.Writer) {
+	renamedTo := map[string]struct{}{}
+	for _, newName := range d.renamed {
+		renamedTo[newName] = struct{}{}
+	}
+	printSection(out, "Renamed:", func(out io.Writer) {
+		for _, oldName := range sortedKeys(d.renamed) {
+			fmt.Fprintf(out, "  %s -> %s\n", oldName, d.renamed[oldName])
+		}
+	}, len(d.renamed))
+	printSection(out, "Added:", func(out io.Writer) {
+		for _, name := range d.added {
+			if _, ok := renamedTo[name]; !ok {
+				fmt.Fprintf(out, "  %s\n", name)
+			}
+		}
+	}, len(d.added)-len(renamedTo))
+	printSection(out, "Removed:", func(out io.Writer) {
+		for _, name := range d.removed {
+			if _, ok := d.renamed[name]; !ok {
+				fmt.Fprintf(out, "  %s\n", name)
+			}
+		}
+	}, len(d.removed)-len(d.renamed))
+	printSection(out, "Type changes:", func(out io.Writer) {
+		for _, change := range d.typeChanged {
+			fmt.Fprintf(out, "  %s\n", change)
+		}
+	}, len(d.typeChanged))
+	printSection(out, "Bucket changes:", func(out io.Writer) {
+		for _, name := range sortedKeys(d.bucketChanges) {
+			fmt.Fprintf(out, "  %s: %s\n", name, d.bucketChanges[name])
+		}
+	}, len(d.bucketChanges))
+	printSection(out, "Added series:", func(out io.Writer) {
+		for _, name := range sortedKeys(d.addedSeries) {
+			for _, s := range d.addedSeries[name] {
+				fmt.Fprintf(out, "  %s%s\n", name, s)
+			}
+		}
+	}, len(d.addedSeries))
+	printSection(out, "Removed series:", func(out io.Writer) {
+		for _, name := range sortedKeys(d.removedSeries) {
+			for _, s := range d.removedSeries[name] {
+				fmt.Fprintf(out, "  %s%s\n", name, s)
+			}
+		}
+	}, len(d.removedSeries))
+}
+
+func printSection(out io.Writer, title string, body func(io.Writer), count int) {
+	if count == 0 {
+		return
+	}
+	fmt.Fprintln(out, title)
+	body(out)
+}
+
+// seriesOf are the label sets of the family, without the bucket and quantile labels of the samples
+func seriesOf(family *dto.MetricFamily) map[string]struct{} {
+	series := map[string]struct{}{}
+	for _, m := range family.GetMetric() {
+		labels := map[string]string{}
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		series[formatLabels(labels)] = struct{}{}
+	}
+	return series
+}
+
+func labelNamesOf(family *dto.MetricFamily) string {
+	names := map[string]struct{}{}
+	for _, m := range family.GetMetric() {
+		for _, label := range m.GetLabel() {
+			names[label.GetName()] = struct{}{}
+		}
+	}
+	return strings.Join(sortedKeys(names), ",")
+}
+
+func bucketsOf(family *dto.MetricFamily) string {
+	for _, m := range family.GetMetric() {
+		bounds := []string{}
+		for _, b := range m.GetHistogram().GetBucket() {
+			bounds = append(bounds, fmt.Sprintf("%v", b.GetUpperBound()))
+		}
+		return strings.Join(bounds, ",")
+	}
+	return ""
+}
+
+func sortedNames(families map[string]*dto.MetricFamily) []string {
+	names := []string{}
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const oldMetrics = `# HELP pipeline_service_execution_overhead_percentage Proportion of time elapsed.
+# TYPE pipeline_service_execution_overhead_percentage histogram
+pipeline_service_execution_overhead_percentage_bucket{namespace="ns",status="Succeeded",le="0.1"} 1
+pipeline_service_execution_overhead_percentage_bucket{namespace="ns",status="Succeeded",le="+Inf"} 1
+pipeline_service_execution_overhead_percentage_sum{namespace="ns",status="Succeeded"} 0.05
+pipeline_service_execution_overhead_percentage_count{namespace="ns",status="Succeeded"} 1
+# HELP pipelinerun_duration_scheduled_seconds Duration in seconds for a PipelineRun to be scheduled.
+# TYPE pipelinerun_duration_scheduled_seconds histogram
+pipelinerun_duration_scheduled_seconds_bucket{namespace="ns",le="1"} 1
+pipelinerun_duration_scheduled_seconds_bucket{namespace="ns",le="+Inf"} 1
+pipelinerun_duration_scheduled_seconds_sum{namespace="ns"} 0.5
+pipelinerun_duration_scheduled_seconds_count{namespace="ns"} 1
+# HELP pipeline_service_heartbeat Heartbeat.
+# TYPE pipeline_service_heartbeat gauge
+pipeline_service_heartbeat{collector="overhead"} 1
+pipeline_service_heartbeat{collector="taskrun-gaps"} 1
+# HELP pipeline_service_removed Removed.
+# TYPE pipeline_service_removed counter
+pipeline_service_removed 1
+`
+
+const newMetrics = `# HELP pipeline_service_execution_overhead_ratio Proportion of time elapsed.
+# TYPE pipeline_service_execution_overhead_ratio histogram
+pipeline_service_execution_overhead_ratio_bucket{namespace="ns",status="succeeded",le="0.1"} 1
+pipeline_service_execution_overhead_ratio_bucket{namespace="ns",status="succeeded",le="+Inf"} 1
+pipeline_service_execution_overhead_ratio_sum{namespace="ns",status="succeeded"} 0.05
+pipeline_service_execution_overhead_ratio_count{namespace="ns",status="succeeded"} 1
+# HELP pipelinerun_duration_scheduled_seconds Duration in seconds for a PipelineRun to be scheduled.
+# TYPE pipelinerun_duration_scheduled_seconds histogram
+pipelinerun_duration_scheduled_seconds_bucket{namespace="ns",le="1"} 1
+pipelinerun_duration_scheduled_seconds_bucket{namespace="ns",le="5"} 1
+pipelinerun_duration_scheduled_seconds_bucket{namespace="ns",le="+Inf"} 1
+pipelinerun_duration_scheduled_seconds_sum{namespace="ns"} 0.5
+pipelinerun_duration_scheduled_seconds_count{namespace="ns"} 1
+# HELP pipeline_service_heartbeat Heartbeat.
+# TYPE pipeline_service_heartbeat gauge
+pipeline_service_heartbeat{collector="overhead"} 1
+pipeline_service_heartbeat{collector="pollers"} 1
+# HELP pipeline_service_added Added.
+# TYPE pipeline_service_added gauge
+pipeline_service_added 1
+`
+
+func TestMetricsDiff(t *testing.T) {
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "old.txt")
+	assert.NoError(t, os.WriteFile(oldFile, []byte(oldMetrics), 0600))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, newMetrics)
+	}))
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, MetricsDiff(context.Background(), []string{"--old", oldFile, "--new", server.URL}, out))
+	report := out.String()
+	assert.Contains(t, report, "Renamed:\n  pipeline_service_execution_overhead_percentage -> pipeline_service_execution_overhead_ratio\n")
+	assert.Contains(t, report, "Added:\n  pipeline_service_added\n")
+	assert.Contains(t, report, "Removed:\n  pipeline_service_removed\n")
+	assert.Contains(t, report, "Bucket changes:\n  pipelinerun_duration_scheduled_seconds: [1,+Inf] -> [1,5,+Inf]\n")
+	assert.Contains(t, report, "Added series:\n  pipeline_service_heartbeat{collector=\"pollers\"}\n")
+	assert.Contains(t, report, "Removed series:\n  pipeline_service_heartbeat{collector=\"taskrun-gaps\"}\n")
+	assert.False(t, strings.Contains(report, "Type changes:"))
+
+	err := MetricsDiff(context.Background(), []string{"--old", oldFile, "--new", server.URL, "--fail-on-breaking"}, &bytes.Buffer{})
+	assert.Error(t, err)
+	out.Reset()
+	assert.NoError(t, MetricsDiff(context.Background(), []string{"--old", oldFile, "--new", oldFile, "--fail-on-breaking"}, out))
+	assert.Empty(t, out.String())
+}
+
+func TestMetricsDiffAuth(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, newMetrics)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "old.txt")
+	assert.NoError(t, os.WriteFile(oldFile, []byte(oldMetrics), 0600))
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("test-token\n"), 0600))
+
+	assert.ErrorContains(t, MetricsDiff(context.Background(), []string{"--old", oldFile, "--new", server.URL, "--ca-file", caFile}, &bytes.Buffer{}), "status 401")
+	out := &bytes.Buffer{}
+	assert.NoError(t, MetricsDiff(context.Background(), []string{"--old", oldFile, "--new", server.URL, "--ca-file", caFile, "--token-file", tokenFile}, out))
+	assert.Contains(t, out.String(), "Added:\n  pipeline_service_added\n")
+	// the token is not sent in the clear
+	assert.Error(t, MetricsDiff(context.Background(), []string{"--old", "http://localhost:9117/metrics", "--new", server.URL, "--token-file", tokenFile}, &bytes.Buffer{}))
+}
+
+func TestMetricsDiffDeprecatedRename(t *testing.T) {
+	deprecated := strings.Replace(oldMetrics, "HELP pipeline_service_execution_overhead_percentage Proportion",
+		"HELP pipeline_service_execution_overhead_percentage DEPRECATED: use pipeline_service_execution_overhead_ratio. Proportion", 1)
+	assert.Equal(t, "Proportion of time elapsed.", undeprecatedHelp("DEPRECATED: use x. Proportion of time elapsed."))
+	dir := t.TempDir()
+	oldFile, newFile := filepath.Join(dir, "old.txt"), filepath.Join(dir, "new.txt")
+	assert.NoError(t, os.WriteFile(oldFile, []byte(deprecated), 0600))
+	assert.NoError(t, os.WriteFile(newFile, []byte(newMetrics), 0600))
+	out := &bytes.Buffer{}
+	assert.NoError(t, MetricsDiff(context.Background(), []string{"--old", oldFile, "--new", newFile}, out))
+	assert.Contains(t, out.String(), "pipeline_service_execution_overhead_percentage -> pipeline_service_execution_overhead_ratio")
+	assert.Error(t, MetricsDiff(context.Background(), []string{"--old", oldFile}, out))
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+// The formats of the archives replay reads
+const (
+	ReplayFormatYAML    = "yaml"
+	ReplayFormatResults = "results"
+	ReplayFormatAudit   = "audit"
+)
+
+func init() {
+	register("replay", Replay)
+}
+
+// Replay records the run level metrics for the PipelineRuns and TaskRuns of an archive that completed within a time
+// range, and prints them in the Prometheus text format, for SLOs to be computed retroactively, say after an incident
+func Replay(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(out)
+	input := flags.String("input", "-", "The archive to read, - for stdin; with the yaml format, a directory is read like analyze does.")
+	format := flags.String("format", ReplayFormatYAML, "The format of the archive: yaml for exported objects, results for Tekton Results records, or audit for API server audit events at the RequestResponse level.")
+	since := flags.String("since", "", "Only runs completed at or after this RFC3339 time, or UTC date, are replayed.")
+	until := flags.String("until", "", "Only runs completed before this RFC3339 time, or UTC date, are replayed.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	w, err := parseWindow(*since, *until)
+	if err != nil {
+		return err
+	}
+	r, err := loadArchive(ctx, *input, *format)
+	if err != nil {
+		return err
+	}
+	r.latest()
+	// before the replayer, as the settings also shape the label names of its metrics
+	collector.ApplySettings(Settings)
+
+	registry := prometheus.NewRegistry()
+	replayer := collector.NewReplayer(registry)
+	defer replayer.Close()
+	for _, pr := range completedWithin(r.pipelineRuns, w) {
+		if pr.Status.CompletionTime != nil {
+			replayer.ObservePipelineRun(pr, r.taskRunsOf(pr))
+		}
+	}
+	for _, tr := range r.taskRuns {
+		if tr.Status.CompletionTime != nil && w.contains(tr.Status.CompletionTime.Time) {
+			replayer.ObserveTaskRun(tr)
+		}
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+	encoder := expfmt.NewEncoder(out, expfmt.FmtText)
+	for _, family := range families {
+		if err = encoder.Encode(family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadArchive(ctx context.Context, input, format string) (*runs, error) {
+	r := &runs{}
+	var in io.Reader = os.Stdin
+	if input != "-" {
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			if format != ReplayFormatYAML {
+				return nil, fmt.Errorf("only the %s format can be read from a directory", ReplayFormatYAML)
+			}
+			return loadDir(ctx, input)
+		}
+		f, err := os.Open(input)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	var err error
+	switch format {
+	case ReplayFormatYAML:
+		err = r.load(ctx, in)
+	case ReplayFormatResults:
+		err = r.loadResults(ctx, in)
+	case ReplayFormatAudit:
+		err = r.loadAudit(ctx, in)
+	default:
+		err = fmt.Errorf("unknown format %s", format)
+	}
+	return r, err
+}
+
+const windowDateLayout = "2006-01-02"
+
+// window is a time range, open ended when either end is zero
+type window struct {
+	since time.Time
+	until time.Time
+}
+
+// parseWindow parses the --since and --until flags, each an RFC3339 time or a date, which stands for its start in UTC,
+// so --since 2023-06-01 --until 2023-06-02 covers exactly that day
+func parseWindow(since, until string) (window, error) {
+	w := window{}
+	var err error
+	if w.since, err = parseWindowTime("--since", since); err != nil {
+		return w, err
+	}
+	if w.until, err = parseWindowTime("--until", until); err != nil {
+		return w, err
+	}
+	if !w.since.IsZero() && !w.until.IsZero() && !w.since.Before(w.until) {
+		return w, fmt.Errorf("--since must be before --until")
+	}
+	return w, nil
+}
+
+func parseWindowTime(flag, value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(windowDateLayout, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid %s, neither an RFC3339 time nor a %s date: %w", flag, windowDateLayout, err)
+	}
+	return t, nil
+}
+
+func (w window) bounded() bool {
+	return !w.since.IsZero() || !w.until.IsZero()
+}
+
+func (w window) contains(t time.Time) bool {
+	if !w.since.IsZero() && t.Before(w.since) {
+		return false
+	}
+	if !w.until.IsZero() && !t.Before(w.until) {
+		return false
+	}
+	return true
+}
+
+// completedWithin returns the PipelineRuns which completed within the window, all of them when it is not bounded
+func completedWithin(prs []*v1.PipelineRun, w window) []*v1.PipelineRun {
+	if !w.bounded() {
+		return prs
+	}
+	within := []*v1.PipelineRun{}
+	for _, pr := range prs {
+		if pr.Status.CompletionTime != nil && w.contains(pr.Status.CompletionTime.Time) {
+			within = append(within, pr)
+		}
+	}
+	return within
+}
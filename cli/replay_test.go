@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/pointer"
+)
+
+// archivedObjects are the runs of analyzeListYaml as v1 JSON, the way the archives hold them
+func archivedObjects(t *testing.T) [][]byte {
+	r := &runs{}
+	assert.NoError(t, r.load(context.Background(), strings.NewReader(analyzeListYaml)))
+	objects := [][]byte{}
+	for _, pr := range r.pipelineRuns {
+		pr.APIVersion, pr.Kind = "tekton.dev/v1", "PipelineRun"
+		raw, err := json.Marshal(pr)
+		assert.NoError(t, err)
+		objects = append(objects, raw)
+	}
+	for _, tr := range r.taskRuns {
+		tr.APIVersion, tr.Kind = "tekton.dev/v1", "TaskRun"
+		raw, err := json.Marshal(tr)
+		assert.NoError(t, err)
+		objects = append(objects, raw)
+	}
+	return objects
+}
+
+func replay(t *testing.T, content []byte, args ...string) string {
+	input := filepath.Join(t.TempDir(), "archive")
+	assert.NoError(t, os.WriteFile(input, content, 0600))
+	out := &bytes.Buffer{}
+	assert.NoError(t, Replay(context.Background(), append([]string{"--input", input}, args...), out))
+	return out.String()
+}
+
+func TestReplayFormats(t *testing.T) {
+	objects := archivedObjects(t)
+
+	records := []map[string]interface{}{}
+	for _, object := range objects {
+		records = append(records, map[string]interface{}{"data": map[string]interface{}{"type": "tekton.dev/v1.Run", "value": object}})
+	}
+	records = append(records, map[string]interface{}{"data": map[string]interface{}{"type": "results.tekton.dev/v1alpha2.Log", "value": []byte("log lines")}})
+	results, err := json.Marshal(map[string]interface{}{"records": records})
+	assert.NoError(t, err)
+
+	audit := &bytes.Buffer{}
+	for _, object := range objects {
+		for _, stage := range []string{"RequestReceived", "ResponseComplete"} {
+			event, err := json.Marshal(map[string]interface{}{"stage": stage, "objectRef": map[string]string{"apiGroup": "tekton.dev"},
+				"responseObject": json.RawMessage(object)})
+			assert.NoError(t, err)
+			audit.Write(append(event, '\n'))
+		}
+	}
+	audit.WriteString(`{"stage":"ResponseComplete","objectRef":{"apiGroup":"","resource":"pods"},"responseObject":{"kind":"Pod","apiVersion":"v1"}}` + "\n")
+
+	for _, test := range []struct {
+		name    string
+		content []byte
+		format  string
+	}{
+		{name: "yaml", content: []byte(analyzeListYaml), format: ReplayFormatYAML},
+		{name: "results", content: results, format: ReplayFormatResults},
+		{name: "audit", content: audit.Bytes(), format: ReplayFormatAudit},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			out := replay(t, test.content, "--format", test.format)
+			assert.Contains(t, out, `pipelinerun_duration_scheduled_seconds_count{namespace="test-namespace",status="succeded"} 1`)
+			assert.Contains(t, out, `taskrun_duration_scheduled_seconds_count{namespace="test-namespace",status="succeded"} 2`)
+			assert.Contains(t, out, `pipelinerun_gap_between_taskruns_milliseconds_sum{namespace="test-namespace",phase="kickoff",status="succeded"} 1000`)
+			assert.Contains(t, out, `pipelinerun_gap_between_taskruns_milliseconds_sum{namespace="test-namespace",phase="between-taskruns",status="succeded"} 3000`)
+			assert.Contains(t, out, `pipeline_service_execution_overhead_percentage_count{namespace="test-namespace",status="succeded"} 1`)
+		})
+	}
+}
+
+func TestReplayWindow(t *testing.T) {
+	out := replay(t, []byte(analyzeListYaml), "--since", "2023-06-01T10:05:00Z", "--until", "2023-06-01T11:00:00Z")
+	assert.Contains(t, out, `pipelinerun_duration_scheduled_seconds_count{namespace="test-namespace",status="succeded"} 1`)
+	// the clone TaskRun completed before the window
+	assert.Contains(t, out, `taskrun_duration_scheduled_seconds_count{namespace="test-namespace",status="succeded"} 1`)
+
+	out = replay(t, []byte(analyzeListYaml), "--until", "2023-06-01T10:05:00Z")
+	assert.NotContains(t, out, "pipelinerun_duration_scheduled_seconds_count")
+
+	assert.Error(t, Replay(context.Background(), []string{"--since", "yesterday"}, &bytes.Buffer{}))
+	assert.Error(t, Replay(context.Background(), []string{"--since", "2023-06-02T00:00:00Z", "--until", "2023-06-01T00:00:00Z"}, &bytes.Buffer{}))
+}
+
+func TestReplaySettings(t *testing.T) {
+	defer func(s collector.Settings) {
+		Settings = s
+		collector.ApplySettings(s)
+	}(Settings)
+	// the overhead is filtered with the exporter's FILTER_THRESHOLD, as the run is shorter
+	Settings = collector.Settings{FilterThreshold: pointer.Float64(900000)}
+	out := replay(t, []byte(analyzeListYaml))
+	assert.Contains(t, out, `pipelinerun_duration_scheduled_seconds_count{namespace="test-namespace",status="succeded"} 1`)
+	assert.NotContains(t, out, "pipeline_service_execution_overhead_percentage_count")
+}
+
+func TestWindowContains(t *testing.T) {
+	w, err := parseWindow("2023-06-01T10:00:00Z", "2023-06-01T11:00:00Z")
+	assert.NoError(t, err)
+	assert.True(t, w.contains(w.since))
+	assert.False(t, w.contains(w.until))
+	assert.False(t, w.contains(w.since.Add(-time.Second)))
+	assert.True(t, window{}.contains(time.Now()))
+	assert.False(t, window{}.bounded())
+
+	// a date is its start in UTC, so two consecutive dates cover exactly a day
+	w, err = parseWindow("2023-06-01", "2023-06-02")
+	assert.NoError(t, err)
+	assert.True(t, w.bounded())
+	assert.True(t, w.contains(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, w.contains(time.Date(2023, 6, 1, 23, 59, 59, 0, time.UTC)))
+	assert.False(t, w.contains(time.Date(2023, 6, 2, 0, 0, 0, 0, time.UTC)))
+	_, err = parseWindow("2023-06-01", "06/02/2023")
+	assert.ErrorContains(t, err, "invalid --until")
+}
+
+func TestLatest(t *testing.T) {
+	r := &runs{}
+	assert.NoError(t, r.load(context.Background(), strings.NewReader(analyzeListYaml)))
+	running := r.pipelineRuns[0].DeepCopy()
+	running.Status.Conditions = nil
+	// an older version of the same PipelineRun later in the archive does not replace the completed one
+	r.pipelineRuns = append(r.pipelineRuns, running)
+	r.taskRuns = append(r.taskRuns, r.taskRuns[0].DeepCopy())
+	r.latest()
+	assert.Len(t, r.pipelineRuns, 1)
+	assert.True(t, r.pipelineRuns[0].IsDone())
+	assert.Len(t, r.taskRuns, 2)
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+)
+
+func init() {
+	register("simulate", Simulate)
+}
+
+// tuning is a pair of the FILTER_THRESHOLD and ALERT_RATIO values
+type tuning struct {
+	threshold  float64
+	alertRatio float64
+}
+
+// outcome is what happens to the execution overhead of a PipelineRun under a tuning
+type outcome string
+
+const (
+	outcomeFiltered outcome = "filtered"
+	outcomeRecorded outcome = "recorded"
+	outcomeAlerted  outcome = "alerted"
+)
+
+func (t tuning) outcome(result collector.GapResult) outcome {
+	overhead, ok := result.OverheadAt(t.threshold)
+	switch {
+	case !ok:
+		return outcomeFiltered
+	case overhead >= t.alertRatio:
+		return outcomeAlerted
+	}
+	return outcomeRecorded
+}
+
+// Simulate compares how many recently completed PipelineRuns have their execution overhead filtered, recorded, or
+// at the alert level, under the current and proposed FILTER_THRESHOLD and ALERT_RATIO values; the current
+// FILTER_THRESHOLD is the one the exporter is configured with, from Settings
+func Simulate(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	current, proposed := tuning{}, tuning{}
+	flags.Float64Var(&current.threshold, "current-threshold", Settings.EffectiveFilterThreshold(), "The current FILTER_THRESHOLD, in milliseconds, where 0 filters nothing; the exporter's FILTER_THRESHOLD, or its default, when not set.")
+	flags.Float64Var(&proposed.threshold, "proposed-threshold", 0, "The proposed FILTER_THRESHOLD, in milliseconds, where 0 filters nothing; the current one when not set.")
+	flags.Float64Var(&current.alertRatio, "current-alert-ratio", collector.ALERT_RATIO, "The current execution overhead ratio alerted on.")
+	flags.Float64Var(&proposed.alertRatio, "proposed-alert-ratio", 0, "The proposed execution overhead ratio alerted on; the current one when not set.")
+	hours := flags.Int("hours", 24, "Only PipelineRuns completed within this many hours are simulated; 0 for all of them.")
+	since := flags.String("since", "", "Only PipelineRuns completed at or after this RFC3339 time, or UTC date, are simulated, vs. --hours.")
+	until := flags.String("until", "", "Only PipelineRuns completed before this RFC3339 time, or UTC date, are simulated, vs. --hours.")
+	input := flags.String("input", "", "An archive to read, like with replay, vs. listing the runs on the cluster.")
+	format := flags.String("format", ReplayFormatYAML, "The format of the --input archive, like with replay.")
+	namespace := ""
+	flags.StringVar(&namespace, "namespace", "", "Only list the runs of this namespace on the cluster, vs. all namespaces.")
+	flags.StringVar(&namespace, "n", "", "Shorthand for --namespace.")
+	kubeconfig := flags.String("kubeconfig", "", "The kubeconfig file to use, like kubectl's.")
+	kubeContext := flags.String("context", "", "The name of the kubeconfig context to use.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	w, err := parseWindow(*since, *until)
+	if err != nil {
+		return err
+	}
+	if !w.bounded() && *hours > 0 {
+		w.since = time.Now().Add(-time.Duration(*hours) * time.Hour)
+	}
+	// vs. a 0 sentinel, as a 0 ALERT_RATIO, alerting on every run, is a proposal of its own
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if !set["proposed-threshold"] {
+		proposed.threshold = current.threshold
+	}
+	if !set["proposed-alert-ratio"] {
+		proposed.alertRatio = current.alertRatio
+	}
+	// like the exporter, where 0 turns the filter off, vs. a negative threshold it refuses
+	if current.threshold < 0 || proposed.threshold < 0 {
+		return fmt.Errorf("the thresholds must not be negative; 0 filters nothing")
+	}
+	collector.ApplySettings(Settings)
+
+	var r *runs
+	if len(*input) > 0 {
+		r, err = loadArchive(ctx, *input, *format)
+		if err == nil {
+			r.latest()
+		}
+	} else {
+		c, cerr := clusterClient(*kubeconfig, *kubeContext)
+		if cerr != nil {
+			return cerr
+		}
+		r, err = loadCluster(ctx, c, namespace)
+	}
+	if err != nil {
+		return err
+	}
+	simulate(r, w, current, proposed, out)
+	return nil
+}
+
+func simulate(r *runs, w window, current, proposed tuning, out io.Writer) {
+	evaluated, skipped := 0, 0
+	counts := map[outcome][2]int{}
+	changed := []string{}
+	for _, pr := range r.pipelineRuns {
+		if pr.Status.CompletionTime == nil || !w.contains(pr.Status.CompletionTime.Time) {
+			continue
+		}
+		result := collector.CalculateGaps(pr, r.taskRunsOf(pr))
+		if !result.Calculated() || pr.Status.StartTime == nil {
+			skipped++
+			continue
+		}
+		evaluated++
+		before, after := current.outcome(result), proposed.outcome(result)
+		c := counts[before]
+		c[0]++
+		counts[before] = c
+		c = counts[after]
+		c[1]++
+		counts[after] = c
+		if before != after {
+			changed = append(changed, fmt.Sprintf("%s/%s\t%s\t%s", pr.Namespace, pr.Name, before, after))
+		}
+	}
+
+	fmt.Fprintf(out, "PipelineRuns: %d, skipped without gaps: %d\n\n", evaluated, skipped)
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tCURRENT\tPROPOSED")
+	fmt.Fprintf(tw, "FILTER_THRESHOLD\t%v\t%v\n", current.threshold, proposed.threshold)
+	fmt.Fprintf(tw, "ALERT_RATIO\t%v\t%v\n", current.alertRatio, proposed.alertRatio)
+	for _, o := range []outcome{outcomeFiltered, outcomeRecorded, outcomeAlerted} {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", o, counts[o][0], counts[o][1])
+	}
+	tw.Flush()
+
+	if len(changed) == 0 {
+		return
+	}
+	sort.Strings(changed)
+	fmt.Fprintln(out, "\nChanged:")
+	tw = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PIPELINERUN\tCURRENT\tPROPOSED")
+	for _, line := range changed {
+		fmt.Fprintln(tw, line)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSimulate(t *testing.T) {
+	r := &runs{}
+	assert.NoError(t, r.load(context.Background(), strings.NewReader(analyzeListYaml+"---\n"+analyzeRunningYaml)))
+	current := tuning{threshold: 300000, alertRatio: 0.05}
+
+	out := &bytes.Buffer{}
+	simulate(r, window{}, current, tuning{threshold: 900000, alertRatio: 0.05}, out)
+	assert.Contains(t, out.String(), "PipelineRuns: 1, skipped without gaps: 0")
+	assert.Regexp(t, `filtered\s+0\s+1`, out.String())
+	assert.Regexp(t, `recorded\s+1\s+0`, out.String())
+	assert.Regexp(t, `test-namespace/build-1\s+recorded\s+filtered`, out.String())
+
+	out = &bytes.Buffer{}
+	simulate(r, window{}, current, tuning{threshold: 300000, alertRatio: 0.005}, out)
+	assert.Regexp(t, `alerted\s+0\s+1`, out.String())
+
+	out = &bytes.Buffer{}
+	simulate(r, window{}, current, current, out)
+	assert.NotContains(t, out.String(), "Changed:")
+}
+
+func TestLoadCluster(t *testing.T) {
+	r := &runs{}
+	assert.NoError(t, r.load(context.Background(), strings.NewReader(analyzeListYaml)))
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(r.pipelineRuns[0], r.taskRuns[0], r.taskRuns[1]).Build()
+
+	loaded, err := loadCluster(context.Background(), c, "")
+	assert.NoError(t, err)
+	assert.Len(t, loaded.pipelineRuns, 1)
+	assert.Len(t, loaded.taskRuns, 2)
+	loaded, err = loadCluster(context.Background(), c, "other-namespace")
+	assert.NoError(t, err)
+	assert.Len(t, loaded.pipelineRuns, 0)
+}
+
+func TestSimulateFlags(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "archive")
+	assert.NoError(t, os.WriteFile(input, []byte(analyzeListYaml+"---\n"+analyzeRunningYaml), 0600))
+	defer func(s collector.Settings) {
+		Settings = s
+		collector.ApplySettings(s)
+	}(Settings)
+
+	// the current threshold is the one the exporter is configured with, and the proposed ones default to the current
+	Settings = collector.Settings{FilterThreshold: pointer.Float64(900000)}
+	out := &bytes.Buffer{}
+	assert.NoError(t, Simulate(context.Background(), []string{"--input", input, "--hours", "0"}, out))
+	assert.Regexp(t, `FILTER_THRESHOLD\s+900000\s+900000`, out.String())
+	assert.Regexp(t, `filtered\s+1\s+1`, out.String())
+
+	// a proposed alert ratio of 0 alerts on every run, vs. being taken as not set
+	out = &bytes.Buffer{}
+	assert.NoError(t, Simulate(context.Background(), []string{"--input", input, "--hours", "0", "--current-threshold", "300000", "--proposed-alert-ratio", "0"}, out))
+	assert.Regexp(t, `ALERT_RATIO\s+0.05\s+0\n`, out.String())
+	assert.Regexp(t, `alerted\s+0\s+1`, out.String())
+
+	// a proposed threshold of 0 filters nothing, vs. falling back to the default, and negative ones are refused
+	out = &bytes.Buffer{}
+	assert.NoError(t, Simulate(context.Background(), []string{"--input", input, "--hours", "0", "--proposed-threshold", "0"}, out))
+	assert.Regexp(t, `FILTER_THRESHOLD\s+900000\s+0\n`, out.String())
+	assert.Regexp(t, `filtered\s+1\s+0`, out.String())
+	assert.Error(t, Simulate(context.Background(), []string{"--input", input, "--hours", "0", "--proposed-threshold", "-1"}, &bytes.Buffer{}))
+}
@@ -0,0 +1,296 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/openshift-pipelines/pipeline-service-exporter/exporter"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func init() {
+	register("top", Top)
+}
+
+// clearScreen moves the cursor home and clears the terminal, like top does between refreshes
+const clearScreen = "\033[H\033[2J"
+
+// Top polls a running exporter's metrics endpoint and shows the per namespace overhead, throttling, and waiting
+// runs, along with the depth of the collectors' work queues, refreshed until interrupted; it only needs the
+// exporter's endpoint, say port forwarded, for clusters without Grafana access; when the endpoint is behind TLS and
+// authentication, like that of a kube-rbac-proxy sidecar, the flags give the CA, client certificate, and token to
+// scrape it with
+func Top(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("top", flag.ContinueOnError)
+	flags.SetOutput(out)
+	url := flags.String("url", "http://localhost:9117/metrics", "The metrics endpoint of the exporter.")
+	interval := flags.Duration("interval", 5*time.Second, "How often the metrics are refreshed.")
+	limit := flags.Int("limit", 20, "The number of namespaces shown, with the highest execution overhead first.")
+	once := flags.Bool("once", false, "Print a single refresh, without clearing the terminal, and exit.")
+	sf := addScraperFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	s, err := sf.scraper()
+	if err != nil {
+		return err
+	}
+	if s.secured() && !strings.HasPrefix(*url, "https://") {
+		return fmt.Errorf("--token-file, --ca-file, --cert and --key need an https --url")
+	}
+
+	var prev *topState
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		families, err := readMetrics(ctx, *url, s)
+		if err != nil {
+			return err
+		}
+		cur := newTopState(families, time.Now())
+		if !*once {
+			fmt.Fprint(out, clearScreen)
+		}
+		cur.print(prev, *limit, out)
+		if *once {
+			return nil
+		}
+		prev = cur
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// scraper scrapes an endpoint behind the TLS and authentication of the exporter's metrics listener
+type scraper struct {
+	client    *http.Client
+	tokenFile string
+}
+
+// scraperFlags are the flags of the subcommands scraping an exporter's metrics endpoint, for the TLS and
+// authentication of the endpoint
+type scraperFlags struct {
+	tokenFile *string
+	caFile    *string
+	certFile  *string
+	keyFile   *string
+}
+
+func addScraperFlags(flags *flag.FlagSet) *scraperFlags {
+	return &scraperFlags{
+		tokenFile: flags.String("token-file", "", "The file of the bearer token sent with every scrape, like a service account token, for an endpoint requiring authentication; re-read on every scrape, so rotated tokens are picked up."),
+		caFile:    flags.String("ca-file", "", "The PEM file of the CA the https endpoint's certificate is verified with, vs. the system's CAs."),
+		certFile:  flags.String("cert", "", "The PEM file of the client certificate, for an endpoint requiring client certificates; needs --key."),
+		keyFile:   flags.String("key", "", "The PEM file of the key of the client certificate; needs --cert."),
+	}
+}
+
+func (f *scraperFlags) scraper() (*scraper, error) {
+	return newScraper(*f.tokenFile, *f.caFile, *f.certFile, *f.keyFile)
+}
+
+func newScraper(tokenFile, caFile, certFile, keyFile string) (*scraper, error) {
+	s := &scraper{client: http.DefaultClient, tokenFile: tokenFile}
+	if len(caFile) == 0 && len(certFile) == 0 && len(keyFile) == 0 {
+		return s, nil
+	}
+	tlsConfig, err := exporter.ClientTLSConfig(caFile, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	s.client = &http.Client{Transport: transport}
+	return s, nil
+}
+
+// secured is whether the scrapes send credentials, or verify the endpoint with a CA of their own
+func (s *scraper) secured() bool {
+	return len(s.tokenFile) > 0 || s.client != http.DefaultClient
+}
+
+// authorize adds the bearer token to the scrape, read on every one as projected tokens are rotated
+func (s *scraper) authorize(req *http.Request) error {
+	if len(s.tokenFile) == 0 {
+		return nil
+	}
+	token, err := os.ReadFile(s.tokenFile)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	return nil
+}
+
+// topNamespace are the cumulative values of one namespace at a refresh
+type topNamespace struct {
+	runs              float64
+	executionSum      float64
+	schedulingRuns    float64
+	schedulingSum     float64
+	pvcThrottled      float64
+	podCreateWaiting  float64
+	kickoffNotStarted float64
+}
+
+type topState struct {
+	at         time.Time
+	namespaces map[string]*topNamespace
+	// queueDepth is the controller-runtime work queue depth, by controller
+	queueDepth map[string]float64
+}
+
+// the overhead metrics under both their legacy and stable names, so top works at any metric compat level
+var (
+	topExecutionMetrics  = []string{"pipeline_service_execution_overhead_percentage", "pipeline_service_execution_overhead_ratio"}
+	topSchedulingMetrics = []string{"pipeline_service_schedule_overhead_percentage", "pipeline_service_schedule_overhead_ratio"}
+)
+
+func newTopState(families map[string]*dto.MetricFamily, at time.Time) *topState {
+	s := &topState{at: at, namespaces: map[string]*topNamespace{}, queueDepth: map[string]float64{}}
+	for _, name := range topExecutionMetrics {
+		s.eachNamespace(families[name], func(ns *topNamespace, m *dto.Metric) {
+			ns.runs += float64(m.GetHistogram().GetSampleCount())
+			ns.executionSum += m.GetHistogram().GetSampleSum()
+		})
+		// at MetricCompatBoth the same observations are under both names
+		if _, ok := families[name]; ok {
+			break
+		}
+	}
+	for _, name := range topSchedulingMetrics {
+		s.eachNamespace(families[name], func(ns *topNamespace, m *dto.Metric) {
+			ns.schedulingRuns += float64(m.GetHistogram().GetSampleCount())
+			ns.schedulingSum += m.GetHistogram().GetSampleSum()
+		})
+		if _, ok := families[name]; ok {
+			break
+		}
+	}
+	s.eachNamespace(families["pipelinerun_failed_by_pvc_quota_count"], func(ns *topNamespace, m *dto.Metric) {
+		ns.pvcThrottled += m.GetGauge().GetValue()
+	})
+	s.eachNamespace(families["taskrun_pod_create_not_attempted_or_pending_count"], func(ns *topNamespace, m *dto.Metric) {
+		ns.podCreateWaiting += m.GetGauge().GetValue()
+	})
+	s.eachNamespace(families["pipelinerun_kickoff_not_attempted_count"], func(ns *topNamespace, m *dto.Metric) {
+		ns.kickoffNotStarted += m.GetGauge().GetValue()
+	})
+	for _, m := range families["workqueue_depth"].GetMetric() {
+		s.queueDepth[labelValue(m, "name")] += m.GetGauge().GetValue()
+	}
+	return s
+}
+
+func (s *topState) eachNamespace(family *dto.MetricFamily, f func(*topNamespace, *dto.Metric)) {
+	for _, m := range family.GetMetric() {
+		name := labelValue(m, collector.NS_LABEL)
+		ns, ok := s.namespaces[name]
+		if !ok {
+			ns = &topNamespace{}
+			s.namespaces[name] = ns
+		}
+		f(ns, m)
+	}
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, label := range m.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+// topRow is what is shown for a namespace
+type topRow struct {
+	namespace  string
+	newRuns    float64
+	execution  float64
+	scheduling float64
+	ns         *topNamespace
+}
+
+func (s *topState) rows(prev *topState) []topRow {
+	rows := []topRow{}
+	for name, ns := range s.namespaces {
+		row := topRow{namespace: name, ns: ns}
+		p := &topNamespace{}
+		if prev != nil {
+			if prevNs, ok := prev.namespaces[name]; ok {
+				p = prevNs
+			}
+			row.newRuns = ns.runs - p.runs
+		}
+		row.execution = mean(ns.runs, ns.executionSum, p.runs, p.executionSum)
+		row.scheduling = mean(ns.schedulingRuns, ns.schedulingSum, p.schedulingRuns, p.schedulingSum)
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].execution != rows[j].execution {
+			return rows[i].execution > rows[j].execution
+		}
+		return rows[i].namespace < rows[j].namespace
+	})
+	return rows
+}
+
+// mean is over the observations since the previous refresh, if there were any, and since the exporter started otherwise
+func mean(count, sum, prevCount, prevSum float64) float64 {
+	if count > prevCount {
+		return (sum - prevSum) / (count - prevCount)
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / count
+}
+
+func (s *topState) print(prev *topState, limit int, out io.Writer) {
+	since := "exporter start"
+	if prev != nil {
+		since = s.at.Sub(prev.at).Round(time.Second).String()
+	}
+	fmt.Fprintf(out, "pipeline-service-exporter top - %s, new runs over the last %s\n", s.at.Format(time.RFC3339), since)
+	queues := []string{}
+	for name := range s.queueDepth {
+		queues = append(queues, name)
+	}
+	sort.Strings(queues)
+	fmt.Fprint(out, "Queue depth:")
+	if len(queues) == 0 {
+		fmt.Fprint(out, " -")
+	}
+	for _, name := range queues {
+		fmt.Fprintf(out, " %s=%.0f", name, s.queueDepth[name])
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tRUNS\tNEW\tEXEC OVERHEAD\tSCHED OVERHEAD\tPVC THROTTLED\tPOD CREATE WAIT\tKICKOFF WAIT")
+	for i, row := range s.rows(prev) {
+		if limit > 0 && i >= limit {
+			break
+		}
+		fmt.Fprintf(w, "%s\t%.0f\t%.0f\t%.1f%%\t%.1f%%\t%.0f\t%.0f\t%.0f\n", row.namespace, row.ns.runs, row.newRuns,
+			row.execution*100, row.scheduling*100, row.ns.pvcThrottled, row.ns.podCreateWaiting, row.ns.kickoffNotStarted)
+	}
+	w.Flush()
+}
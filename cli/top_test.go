@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+const topMetrics = `# HELP pipeline_service_execution_overhead_percentage test
+# TYPE pipeline_service_execution_overhead_percentage histogram
+pipeline_service_execution_overhead_percentage_bucket{namespace="ns-a",status="succeded",le="+Inf"} %d
+pipeline_service_execution_overhead_percentage_sum{namespace="ns-a",status="succeded"} %f
+pipeline_service_execution_overhead_percentage_count{namespace="ns-a",status="succeded"} %d
+pipeline_service_execution_overhead_percentage_bucket{namespace="ns-b",status="succeded",le="+Inf"} 1
+pipeline_service_execution_overhead_percentage_sum{namespace="ns-b",status="succeded"} 0.3
+pipeline_service_execution_overhead_percentage_count{namespace="ns-b",status="succeded"} 1
+# HELP pipeline_service_schedule_overhead_percentage test
+# TYPE pipeline_service_schedule_overhead_percentage histogram
+pipeline_service_schedule_overhead_percentage_bucket{namespace="ns-a",status="succeded",le="+Inf"} 1
+pipeline_service_schedule_overhead_percentage_sum{namespace="ns-a",status="succeded"} 0.02
+pipeline_service_schedule_overhead_percentage_count{namespace="ns-a",status="succeded"} 1
+# HELP pipelinerun_failed_by_pvc_quota_count test
+# TYPE pipelinerun_failed_by_pvc_quota_count gauge
+pipelinerun_failed_by_pvc_quota_count{namespace="ns-b"} 2
+# HELP taskrun_pod_create_not_attempted_or_pending_count test
+# TYPE taskrun_pod_create_not_attempted_or_pending_count gauge
+taskrun_pod_create_not_attempted_or_pending_count{namespace="ns-c"} 3
+# HELP workqueue_depth test
+# TYPE workqueue_depth gauge
+workqueue_depth{name="pipelinerun"} 7
+`
+
+func parseTopState(t *testing.T, count int, sum float64, at time.Time) *topState {
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(strings.NewReader(fmt.Sprintf(topMetrics, count, sum, count)))
+	assert.NoError(t, err)
+	return newTopState(families, at)
+}
+
+func TestTopRows(t *testing.T) {
+	now := time.Now()
+	first := parseTopState(t, 2, 0.2, now)
+	rows := first.rows(nil)
+	assert.Len(t, rows, 3)
+	// sorted by execution overhead, averaged since the exporter started
+	assert.Equal(t, "ns-b", rows[0].namespace)
+	assert.InDelta(t, 0.3, rows[0].execution, 0.0001)
+	assert.Equal(t, "ns-a", rows[1].namespace)
+	assert.InDelta(t, 0.1, rows[1].execution, 0.0001)
+	assert.InDelta(t, 0.02, rows[1].scheduling, 0.0001)
+	assert.Equal(t, float64(3), rows[2].ns.podCreateWaiting)
+	assert.Equal(t, float64(7), first.queueDepth["pipelinerun"])
+
+	// the next refresh averages over the new runs only
+	second := parseTopState(t, 4, 1.2, now.Add(5*time.Second))
+	rows = second.rows(first)
+	assert.Equal(t, "ns-a", rows[0].namespace)
+	assert.Equal(t, float64(2), rows[0].newRuns)
+	assert.InDelta(t, 0.5, rows[0].execution, 0.0001)
+	assert.Equal(t, float64(0), rows[1].newRuns)
+	assert.InDelta(t, 0.3, rows[1].execution, 0.0001)
+
+	out := &bytes.Buffer{}
+	second.print(first, 1, out)
+	assert.Contains(t, out.String(), "new runs over the last 5s")
+	assert.Contains(t, out.String(), "Queue depth: pipelinerun=7")
+	assert.Regexp(t, `ns-a\s+4\s+2\s+50.0%\s+2.0%`, out.String())
+	assert.NotContains(t, out.String(), "ns-b")
+}
+
+func TestTopOnce(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, topMetrics, 1, 0.1, 1)
+	}))
+	defer server.Close()
+	out := &bytes.Buffer{}
+	assert.NoError(t, Top(context.Background(), []string{"--url", server.URL, "--once"}, out))
+	assert.NotContains(t, out.String(), clearScreen)
+	assert.Regexp(t, `ns-b\s+1\s+0\s+30.0%`, out.String())
+
+	assert.Error(t, Top(context.Background(), []string{"--interval", "0"}, out))
+}
+
+func TestTopAuth(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, topMetrics, 1, 0.1, 1)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("test-token\n"), 0600))
+	out := &bytes.Buffer{}
+
+	// the endpoint's certificate is not trusted without the CA, nor the scrape allowed without the token
+	assert.Error(t, Top(context.Background(), []string{"--url", server.URL, "--once"}, out))
+	assert.ErrorContains(t, Top(context.Background(), []string{"--url", server.URL, "--once", "--ca-file", caFile}, out), "status 401")
+
+	assert.NoError(t, Top(context.Background(), []string{"--url", server.URL, "--once", "--ca-file", caFile, "--token-file", tokenFile}, out))
+	assert.Regexp(t, `ns-b\s+1\s+0\s+30.0%`, out.String())
+
+	// the token is not sent in the clear
+	assert.Error(t, Top(context.Background(), []string{"--url", "http://localhost:9117/metrics", "--once", "--token-file", tokenFile}, out))
+	assert.Error(t, Top(context.Background(), []string{"--url", server.URL, "--once", "--cert", caFile}, out))
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"sigs.k8s.io/yaml"
+)
+
+func init() {
+	register("validate", Validate)
+}
+
+// Validate checks the exporter's settings, from a file vs. the environment, without a cluster, then prints the
+// effective configuration with the defaults filled in, so changes to the deployment's configuration can be gated
+// before they are rolled out; it fails if any setting would be ignored
+func Validate(ctx context.Context, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	configFile := flags.String("config", "", "A YAML file mapping the exporter's environment variables to their values, or a ConfigMap whose data does.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(*configFile) == 0 {
+		return fmt.Errorf("validate requires --config")
+	}
+	buf, err := os.ReadFile(*configFile)
+	if err != nil {
+		return err
+	}
+	env, err := parseConfig(buf)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *configFile, err)
+	}
+	return validate(env, out)
+}
+
+// parseConfig reads either a ConfigMap, whose data are the environment variables, like with envFrom, or a plain map
+// of them; YAML booleans and numbers are taken as they would be written in the environment
+func parseConfig(buf []byte) (map[string]string, error) {
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	if doc["kind"] == "ConfigMap" {
+		data, ok := doc["data"].(map[string]interface{})
+		if !ok && doc["data"] != nil {
+			return nil, fmt.Errorf("the ConfigMap data is not a map")
+		}
+		doc = data
+	}
+	env := map[string]string{}
+	for name, value := range doc {
+		switch v := value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("%s is not a string, boolean, or number", name)
+		case nil:
+			env[name] = ""
+		default:
+			env[name] = fmt.Sprint(v)
+		}
+	}
+	return env, nil
+}
+
+func validate(env map[string]string, out io.Writer) error {
+	getenv := func(name string) string {
+		return env[name]
+	}
+	s, problems := collector.ParseSettings(getenv)
+	problems = append(problems, s.Validate()...)
+	problems = append(problems, collector.ValidateRunLabels(getenv(collector.RunLabelsEnvName))...)
+
+	known := map[string]struct{}{}
+	for _, name := range collector.SettingsEnvNames() {
+		known[name] = struct{}{}
+	}
+	unknown := []string{}
+	for name := range env {
+		if _, ok := known[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, collector.SettingsProblem{EnvName: name, Warning: true, Message: "is not a setting of the exporter, so it is ignored"})
+	}
+
+	effective := s.EffectiveEnv()
+	runLabels := []string{}
+	for name, key := range collector.ParseObjectLabelProvider(getenv(collector.RunLabelsEnvName)) {
+		runLabels = append(runLabels, name+"="+key)
+	}
+	sort.Strings(runLabels)
+	effective[collector.RunLabelsEnvName] = strings.Join(runLabels, ",")
+	buf, err := yaml.Marshal(effective)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Effective configuration:")
+	fmt.Fprint(out, string(buf))
+
+	errors := 0
+	for _, problem := range problems {
+		if problem.Warning {
+			fmt.Fprintf(out, "\nWARNING: %s", problem.Error())
+		}
+	}
+	for _, problem := range problems {
+		if !problem.Warning {
+			errors++
+			fmt.Fprintf(out, "\nERROR: %s", problem.Error())
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Fprintln(out)
+	}
+	if errors > 0 {
+		return fmt.Errorf("%d problems found", errors)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfig(t *testing.T) {
+	env, err := parseConfig([]byte(`
+TENANT_LABEL_ENABLED: true
+FILTER_THRESHOLD: 600000
+CLUSTER_NAME: stone-stg-m01
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"TENANT_LABEL_ENABLED": "true", "FILTER_THRESHOLD": "600000", "CLUSTER_NAME": "stone-stg-m01"}, env)
+
+	env, err = parseConfig([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: exporter-config
+data:
+  REMEDIATION_ACTIONS: pvc-quota=annotate
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"REMEDIATION_ACTIONS": "pvc-quota=annotate"}, env)
+
+	_, err = parseConfig([]byte("RUN_LABELS:\n- app=foo\n"))
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.yaml")
+	assert.NoError(t, os.WriteFile(valid, []byte(`
+FILTER_THRESHOLD: "600000"
+RUN_LABELS: component=appstudio.openshift.io/component, application=appstudio.openshift.io/application
+TYPO_ENABLED: "true"
+`), 0600))
+	out := &bytes.Buffer{}
+	assert.NoError(t, Validate(context.Background(), []string{"--config", valid}, out))
+	assert.Contains(t, out.String(), "FILTER_THRESHOLD: \"600000\"")
+	assert.Contains(t, out.String(), "RUN_LABELS: application=appstudio.openshift.io/application,component=appstudio.openshift.io/component")
+	assert.Contains(t, out.String(), "TENANT_NAMESPACE_LABEL: appstudio.redhat.com/workspace_name")
+	assert.Contains(t, out.String(), "WARNING: TYPO_ENABLED: is not a setting of the exporter")
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	assert.NoError(t, os.WriteFile(invalid, []byte(`
+FILTER_THRESHOLD: "-5"
+REMEDIATION_ACTIONS: pvc-quota=webhook
+`), 0600))
+	out.Reset()
+	err := Validate(context.Background(), []string{"--config", invalid}, out)
+	assert.EqualError(t, err, "2 problems found")
+	assert.Contains(t, out.String(), "ERROR: FILTER_THRESHOLD")
+	assert.Contains(t, out.String(), "ERROR: REMEDIATION_WEBHOOK_URL")
+
+	assert.Error(t, Validate(context.Background(), []string{}, out))
+	assert.Error(t, Validate(context.Background(), []string{"--config", filepath.Join(dir, "missing.yaml")}, out))
+}
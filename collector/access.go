@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"strings"
+)
+
+// Access is a permission the exporter needs from the API server
+type Access struct {
+	Group    string
+	Resource string
+	Verb     string
+	// Namespace is where the permission is needed, a Role there being enough, or empty when cluster wide
+	Namespace string
+	// Reason is what the permission is used for
+	Reason string
+	// Optional permissions only turn off what they are used for when missing, vs. keep the exporter from working
+	Optional bool
+}
+
+func (a Access) String() string {
+	resource := a.Resource
+	if len(a.Group) > 0 {
+		resource += "." + a.Group
+	}
+	if len(a.Namespace) > 0 {
+		return a.Verb + " " + resource + " in " + a.Namespace
+	}
+	return a.Verb + " " + resource
+}
+
+func accessFor(group, resource, reason string, optional bool, verbs ...string) []Access {
+	access := []Access{}
+	for _, verb := range verbs {
+		access = append(access, Access{Group: group, Resource: resource, Verb: verb, Reason: reason, Optional: optional})
+	}
+	return access
+}
+
+// RequiredAccess lists the permissions the exporter needs when run with the options, like the ClusterRole of its
+// deployment has to grant
+func RequiredAccess(opts ...Option) []Access {
+	o := newOptions(opts...)
+	access := accessFor("apiextensions.k8s.io", "customresourcedefinitions", "checking the Tekton CRDs are established", false, "get")
+	access = append(access, accessFor("tekton.dev", "pipelineruns", "watching PipelineRuns", false, "get", "list", "watch")...)
+	access = append(access, accessFor("tekton.dev", "taskruns", "watching TaskRuns", false, "get", "list", "watch")...)
+	access = append(access, accessFor("", "pods", "watching the pods of TaskRuns", false, "get", "list", "watch")...)
+	if o.collectorSet().enabled(CollectorCustomRuns) {
+		access = append(access, accessFor("tekton.dev", "customruns", "the CustomRun collector", true, "get", "list", "watch")...)
+	}
+	if o.collectorSet().enabled(CollectorTektonConfig) {
+		for _, ns := range tektonConfigNamespaces(o.Settings) {
+			for _, a := range accessFor("", "configmaps", "the tekton-config collector", true, "get") {
+				a.Namespace = ns
+				access = append(access, a)
+			}
+		}
+	}
+	if o.collectorSet().enabled(CollectorPodPlacement) {
+		access = append(access, accessFor("", "nodes", "the zone and node pool of the pod-placement collector", true, "list", "watch")...)
+	}
+	if o.collectorSet().enabled(CollectorPullSecrets) && o.Settings.PullSecretAging {
+		access = append(access, accessFor("", "serviceaccounts", "the pipeline service accounts of the pull-secrets collector", false, "list")...)
+		access = append(access, accessFor("", "secrets", "the metadata of the pull secrets of the pull-secrets collector", false, "list")...)
+	}
+	if len(o.Settings.ClusterName) == 0 {
+		access = append(access, accessFor("config.openshift.io", "clusterversions", "the cluster label, unless "+ClusterNameEnvName+" is set", true, "get")...)
+	}
+	if o.collectorSet().enabled(CollectorNamespaceLifecycle) {
+		access = append(access, accessFor("", "namespaces", "dropping deleted namespaces from the namespace-lifecycle collector", true, "list", "watch")...)
+	}
+	if o.Settings.TenantLabel || o.Settings.TenantMetricsEndpoint {
+		access = append(access, accessFor("", "namespaces", "the tenant of each namespace", false, "get", "list", "watch")...)
+	}
+	if o.DelegatedAuth {
+		access = append(access, accessFor("authentication.k8s.io", "tokenreviews", "authenticating the callers of the listeners with --metrics-auth, --debug-auth or --admin-auth", false, "create")...)
+		access = append(access, accessFor("authorization.k8s.io", "subjectaccessreviews", "authorizing the callers of the listeners with --metrics-auth, --debug-auth or --admin-auth", false, "create")...)
+	}
+	if o.ReadOnly {
+		return access
+	}
+	access = append(access, accessFor("tekton.dev", "pipelineruns", "labeling throttled PipelineRuns, unless --read-only", false, "patch")...)
+	access = append(access, accessFor("", "events", "recording events, unless --read-only", true, "create", "patch")...)
+	if o.Settings.MarkerTTL > 0 {
+		access = append(access, accessFor("tekton.dev", "pipelineruns", "removing the exporter's markers past "+MarkerTTLEnvName, false, "patch")...)
+		access = append(access, accessFor("tekton.dev", "taskruns", "removing the exporter's markers past "+MarkerTTLEnvName, false, "patch")...)
+		access = append(access, accessFor("", "pods", "removing the exporter's markers past "+MarkerTTLEnvName, false, "patch")...)
+	}
+	if o.Settings.RemediationKillSwitch {
+		return access
+	}
+	for _, action := range remediationActions(o.Settings.RemediationActions) {
+		switch action {
+		case RemediationActionAnnotate:
+			access = append(access, accessFor("tekton.dev", "pipelineruns", "the annotate remediation action", false, "patch")...)
+			access = append(access, accessFor("tekton.dev", "taskruns", "the annotate remediation action", false, "patch")...)
+		case RemediationActionDeletePod:
+			access = append(access, accessFor("", "pods", "the delete-pod remediation action", false, "delete")...)
+		}
+	}
+	return access
+}
+
+// tektonConfigNamespaces are the namespaces the tekton-config collector reads the ConfigMaps of with the settings,
+// those of the control planes, or TEKTON_NAMESPACE without any
+func tektonConfigNamespaces(s Settings) []string {
+	namespaces := []string{}
+	seen := map[string]struct{}{}
+	for _, entry := range s.TektonControlPlanes {
+		plane, err := ParseTektonControlPlane(entry)
+		if _, dup := seen[plane.Namespace]; err != nil || dup {
+			continue
+		}
+		seen[plane.Namespace] = struct{}{}
+		namespaces = append(namespaces, plane.Namespace)
+	}
+	if len(namespaces) > 0 {
+		return namespaces
+	}
+	if len(s.TektonNamespace) > 0 {
+		return []string{s.TektonNamespace}
+	}
+	return []string{DefaultTektonNamespace}
+}
+
+// remediationActions are the distinct actions of the RemediationActions setting, in order, less those ignored for
+// not applying to their detector
+func remediationActions(setting string) []string {
+	actions := []string{}
+	seen := map[string]struct{}{}
+	for _, pair := range strings.Split(setting, ",") {
+		detector, action, found := strings.Cut(pair, "=")
+		action = strings.TrimSpace(action)
+		if _, dup := seen[action]; !found || dup || !actionApplies(strings.TrimSpace(detector), action) {
+			continue
+		}
+		seen[action] = struct{}{}
+		actions = append(actions, action)
+	}
+	return actions
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRequiredAccess(t *testing.T) {
+	names := func(access []Access) []string {
+		n := []string{}
+		for _, a := range access {
+			n = append(n, a.String())
+		}
+		return n
+	}
+
+	readOnly := names(RequiredAccess(WithReadOnly(true), WithSettings(Settings{ClusterName: "test-cluster"})))
+	assert.Contains(t, readOnly, "watch pipelineruns.tekton.dev")
+	assert.Contains(t, readOnly, "get customresourcedefinitions.apiextensions.k8s.io")
+	assert.NotContains(t, readOnly, "patch pipelineruns.tekton.dev")
+	assert.NotContains(t, readOnly, "get clusterversions.config.openshift.io")
+	assert.NotContains(t, readOnly, "get namespaces")
+	assert.Contains(t, readOnly, "watch namespaces")
+
+	all := names(RequiredAccess(WithSettings(Settings{TenantLabel: true, RemediationActions: "pod-create=delete-pod, kickoff=annotate,other=delete-pod"})))
+	assert.Contains(t, all, "patch pipelineruns.tekton.dev")
+	assert.Contains(t, all, "create events")
+	assert.Contains(t, all, "get clusterversions.config.openshift.io")
+	assert.Contains(t, all, "watch namespaces")
+	assert.Contains(t, all, "delete pods")
+	assert.Contains(t, all, "patch taskruns.tekton.dev")
+	assert.Contains(t, all, "get configmaps in "+DefaultTektonNamespace)
+	assert.NotContains(t, all, "get configmaps")
+	assert.Contains(t, all, "watch nodes")
+	assert.NotContains(t, all, "list secrets")
+	assert.NotContains(t, all, "patch pods")
+
+	markers := names(RequiredAccess(WithSettings(Settings{MarkerTTL: time.Hour, RemediationKillSwitch: true})))
+	assert.Contains(t, markers, "patch taskruns.tekton.dev")
+	assert.Contains(t, markers, "patch pods")
+	assert.NotContains(t, names(RequiredAccess(WithReadOnly(true), WithSettings(Settings{MarkerTTL: time.Hour}))), "patch pods")
+	assert.Contains(t, names(RequiredAccess(WithSettings(Settings{PullSecretAging: true}))), "list secrets")
+
+	planes := names(RequiredAccess(WithSettings(Settings{TektonNamespace: "tekton-pipelines", TektonControlPlanes: []string{"a=tekton-a/ns-a-.*", "b=tekton-b/ns-b-.*", "c=tekton-a/ns-c-.*"}})))
+	assert.Contains(t, planes, "get configmaps in tekton-a")
+	assert.Contains(t, planes, "get configmaps in tekton-b")
+	assert.NotContains(t, planes, "get configmaps in tekton-pipelines")
+
+	assert.NotContains(t, all, "create tokenreviews.authentication.k8s.io")
+	delegated := names(RequiredAccess(WithReadOnly(true), WithDelegatedAuth(true)))
+	assert.Contains(t, delegated, "create tokenreviews.authentication.k8s.io")
+	assert.Contains(t, delegated, "create subjectaccessreviews.authorization.k8s.io")
+
+	killed := names(RequiredAccess(WithSettings(Settings{RemediationActions: "pod-create=delete-pod", RemediationKillSwitch: true})))
+	assert.NotContains(t, killed, "delete pods")
+}
+
+func TestRemediationActions(t *testing.T) {
+	assert.Equal(t, []string{RemediationActionDeletePod, RemediationActionWebhook}, remediationActions("a=delete-pod, malformed, b = webhook,c=delete-pod"))
+	assert.Equal(t, []string{}, remediationActions(""))
+}
@@ -0,0 +1,283 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// AggregatesPath is where the daily aggregates are served as JSON on the admin listener
+	AggregatesPath = "/debug/aggregates"
+	// DefaultAggregateRetention is how long the daily aggregates are kept when WithAggregateStore is given no retention
+	DefaultAggregateRetention = 35 * 24 * time.Hour
+	// defaultAggregateDays is how many days AggregatesPath returns without the days query parameter
+	defaultAggregateDays = 7
+
+	aggregateDayFormat    = "2006-01-02"
+	aggregateFlushPeriod  = time.Minute
+	aggregateOpenTimeout  = 5 * time.Second
+	aggregatesBucket      = "aggregates"
+	aggregateKeySeparator = "/"
+)
+
+// AggregateHistogram is a snapshot of one day of an overhead histogram, with the same buckets as the metric, so the
+// trends survive Prometheus setups retaining less than the weeks they cover
+type AggregateHistogram struct {
+	Count uint64
+	Sum   float64
+	// Bounds are the upper bounds of the buckets, and Counts the cumulative number of observations in each
+	Bounds []float64
+	Counts []uint64
+}
+
+func newAggregateHistogram() AggregateHistogram {
+	return AggregateHistogram{Bounds: prometheus.DefBuckets, Counts: make([]uint64, len(prometheus.DefBuckets))}
+}
+
+func (h AggregateHistogram) clone() AggregateHistogram {
+	h.Counts = append([]uint64{}, h.Counts...)
+	return h
+}
+
+func (h *AggregateHistogram) observe(value float64) {
+	h.Count++
+	h.Sum += value
+	for i, bound := range h.Bounds {
+		if value <= bound {
+			h.Counts[i]++
+		}
+	}
+}
+
+// DailyAggregate is what the overhead collector made of the PipelineRuns of a namespace completing on a day, in UTC
+type DailyAggregate struct {
+	Namespace string
+	Day       string
+	// Runs are the completed PipelineRuns, and Recorded those with either overhead recorded
+	Runs               uint64
+	Recorded           uint64
+	ExecutionOverhead  AggregateHistogram
+	SchedulingOverhead AggregateHistogram
+}
+
+func aggregateKey(day, namespace string) string {
+	return day + aggregateKeySeparator + namespace
+}
+
+// aggregateStore keeps the daily aggregates in memory, persisting them to a bolt file every aggregateFlushPeriod and
+// when the manager stops, so they carry over restarts; days older than the retention are dropped on each flush
+type aggregateStore struct {
+	lock       sync.Mutex
+	db         *bolt.DB
+	retention  time.Duration
+	aggregates map[string]*DailyAggregate
+	dirty      map[string]struct{}
+}
+
+// openAggregateStore opens, or creates, the bolt file at the path, loading the aggregates within the retention; the
+// file is locked, so a second exporter pointed at it fails vs. corrupting it
+func openAggregateStore(path string, retention time.Duration) (*aggregateStore, error) {
+	if retention <= 0 {
+		retention = DefaultAggregateRetention
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: aggregateOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the aggregate store %s: %w", path, err)
+	}
+	s := &aggregateStore{db: db, retention: retention, aggregates: map[string]*DailyAggregate{}, dirty: map[string]struct{}{}}
+	oldest := s.oldestDay()
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(aggregatesBucket))
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			aggregate := &DailyAggregate{}
+			if err := json.Unmarshal(v, aggregate); err != nil {
+				controllerLog.Info(fmt.Sprintf("ignoring malformed aggregate %s: %s", string(k), err.Error()))
+				return nil
+			}
+			if aggregate.Day >= oldest {
+				s.aggregates[string(k)] = aggregate
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to load the aggregate store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *aggregateStore) oldestDay() string {
+	return exporterClock.Now().UTC().Add(-s.retention).Format(aggregateDayFormat)
+}
+
+// add counts the run in the aggregate of its namespace and completion day
+func (s *aggregateStore) add(run *RecentRun) {
+	if s == nil || run == nil {
+		return
+	}
+	completed := run.CompletionTime
+	if completed.IsZero() {
+		completed = run.ObservedTime
+	}
+	day := completed.UTC().Format(aggregateDayFormat)
+	key := aggregateKey(day, run.Namespace)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	aggregate, ok := s.aggregates[key]
+	if !ok {
+		aggregate = &DailyAggregate{
+			Namespace:          run.Namespace,
+			Day:                day,
+			ExecutionOverhead:  newAggregateHistogram(),
+			SchedulingOverhead: newAggregateHistogram(),
+		}
+		s.aggregates[key] = aggregate
+	}
+	aggregate.Runs++
+	if len(run.Observations) > 0 {
+		aggregate.Recorded++
+	}
+	for _, o := range run.Observations {
+		switch o.Metric {
+		case "pipeline_service_execution_overhead_percentage":
+			aggregate.ExecutionOverhead.observe(o.Value)
+		case "pipeline_service_schedule_overhead_percentage":
+			aggregate.SchedulingOverhead.observe(o.Value)
+		}
+	}
+	s.dirty[key] = struct{}{}
+}
+
+// flush writes the aggregates changed since the last flush, and drops those past the retention
+func (s *aggregateStore) flush() error {
+	oldest, pending, err := s.snapshot()
+	if err != nil {
+		return fmt.Errorf("unable to flush the aggregate store: %w", err)
+	}
+	// the write is done outside the lock, so the reconciles adding runs do not wait on the disk
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(aggregatesBucket))
+		// the keys start with the day, so the expired ones, including any left behind by an exporter with a longer
+		// retention, come first
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil && string(k) < oldest; k, _ = cursor.First() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		for key, value := range pending {
+			if err := bucket.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// the aggregates are written again on the next flush, with whatever was added to them since
+		s.lock.Lock()
+		for key := range pending {
+			if _, ok := s.aggregates[key]; ok {
+				s.dirty[key] = struct{}{}
+			}
+		}
+		s.lock.Unlock()
+		return fmt.Errorf("unable to flush the aggregate store: %w", err)
+	}
+	return nil
+}
+
+// snapshot drops the expired aggregates, and returns the oldest day kept, along with the marshalled aggregates changed
+// since the last flush, which are no longer marked dirty
+func (s *aggregateStore) snapshot() (string, map[string][]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	oldest := s.oldestDay()
+	for key, aggregate := range s.aggregates {
+		if aggregate.Day < oldest {
+			delete(s.aggregates, key)
+			delete(s.dirty, key)
+		}
+	}
+	pending := make(map[string][]byte, len(s.dirty))
+	for key := range s.dirty {
+		value, err := json.Marshal(s.aggregates[key])
+		if err != nil {
+			return "", nil, err
+		}
+		pending[key] = value
+	}
+	s.dirty = map[string]struct{}{}
+	return oldest, pending, nil
+}
+
+func (s *aggregateStore) Start(ctx context.Context) error {
+	ticker := exporterClock.NewTicker(aggregateFlushPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			err := s.flush()
+			s.db.Close()
+			return err
+		case <-ticker.C():
+			if err := s.flush(); err != nil {
+				controllerLog.Info(err.Error())
+			}
+		}
+	}
+}
+
+// list is the aggregates of the last days, for the namespace if set, oldest first
+func (s *aggregateStore) list(namespace string, days int) []DailyAggregate {
+	since := exporterClock.Now().UTC().AddDate(0, 0, 1-days).Format(aggregateDayFormat)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	aggregates := []DailyAggregate{}
+	for _, aggregate := range s.aggregates {
+		if aggregate.Day < since || (len(namespace) > 0 && aggregate.Namespace != namespace) {
+			continue
+		}
+		copied := *aggregate
+		copied.ExecutionOverhead = aggregate.ExecutionOverhead.clone()
+		copied.SchedulingOverhead = aggregate.SchedulingOverhead.clone()
+		aggregates = append(aggregates, copied)
+	}
+	sort.Slice(aggregates, func(i, j int) bool {
+		return aggregateKey(aggregates[i].Day, aggregates[i].Namespace) < aggregateKey(aggregates[j].Day, aggregates[j].Namespace)
+	})
+	return aggregates
+}
+
+// ServeHTTP returns the daily aggregates as JSON, oldest first, for the last days query parameter's number of days,
+// defaultAggregateDays when not set, optionally limited to those of the namespace query parameter
+func (s *aggregateStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	days := defaultAggregateDays
+	if setting := query.Get("days"); len(setting) > 0 {
+		n, err := strconv.Atoi(setting)
+		if err != nil || n <= 0 {
+			http.Error(w, "days must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s.list(query.Get("namespace"), days)); err != nil {
+		controllerLog.Info("unable to write the aggregates: " + err.Error())
+	}
+}
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	testclock "k8s.io/utils/clock/testing"
+)
+
+func TestAggregateStore(t *testing.T) {
+	now := time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC)
+	fakeClock := testclock.NewFakeClock(now)
+	defer setClock(fakeClock)()
+	path := filepath.Join(t.TempDir(), "aggregates.db")
+
+	s, err := openAggregateStore(path, 7*24*time.Hour)
+	assert.NoError(t, err)
+	s.add(&RecentRun{Namespace: "test-namespace", CompletionTime: now, Observations: []Observation{
+		{Metric: "pipeline_service_execution_overhead_percentage", Value: 0.02},
+		{Metric: "pipeline_service_schedule_overhead_percentage", Value: 0.5},
+	}})
+	s.add(&RecentRun{Namespace: "test-namespace", CompletionTime: now.Add(-time.Hour)})
+	s.add(&RecentRun{Namespace: "test-namespace", CompletionTime: now.AddDate(0, 0, -3)})
+	s.add(&RecentRun{Namespace: "other-namespace", ObservedTime: now})
+	assert.NoError(t, s.flush())
+	assert.NoError(t, s.db.Close())
+
+	// the aggregates carry over a restart
+	s, err = openAggregateStore(path, 7*24*time.Hour)
+	assert.NoError(t, err)
+	aggregates := s.list("test-namespace", 7)
+	assert.Len(t, aggregates, 2)
+	assert.Equal(t, "2023-03-07", aggregates[0].Day)
+	today := aggregates[1]
+	assert.Equal(t, "2023-03-10", today.Day)
+	assert.Equal(t, uint64(2), today.Runs)
+	assert.Equal(t, uint64(1), today.Recorded)
+	assert.Equal(t, uint64(1), today.ExecutionOverhead.Count)
+	assert.Equal(t, 0.02, today.ExecutionOverhead.Sum)
+	// 0.02 is over the .005 and .01 buckets, and under the .025 one
+	assert.Equal(t, []uint64{0, 0, 1}, today.ExecutionOverhead.Counts[:3])
+	assert.Equal(t, uint64(1), today.SchedulingOverhead.Count)
+	assert.Len(t, s.list("", 1), 2)
+
+	// the 2023-03-07 aggregate is dropped once past the retention
+	fakeClock.SetTime(now.AddDate(0, 0, 5))
+	s.add(&RecentRun{Namespace: "test-namespace", CompletionTime: fakeClock.Now()})
+	assert.NoError(t, s.flush())
+	assert.NoError(t, s.db.Close())
+	s, err = openAggregateStore(path, 7*24*time.Hour)
+	assert.NoError(t, err)
+	defer s.db.Close()
+	days := []string{}
+	for _, aggregate := range s.list("test-namespace", 30) {
+		days = append(days, aggregate.Day)
+	}
+	assert.Equal(t, []string{"2023-03-10", "2023-03-15"}, days)
+}
+
+func TestAggregateStore_FailedFlush(t *testing.T) {
+	now := time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC)
+	defer setClock(testclock.NewFakeClock(now))()
+	s, err := openAggregateStore(filepath.Join(t.TempDir(), "aggregates.db"), 7*24*time.Hour)
+	assert.NoError(t, err)
+	s.add(&RecentRun{Namespace: "test-namespace", CompletionTime: now})
+	assert.NoError(t, s.db.Close())
+
+	// the aggregates not written are still flushed the next time
+	assert.Error(t, s.flush())
+	assert.Len(t, s.dirty, 1)
+}
+
+func TestAggregateStore_ServeHTTP(t *testing.T) {
+	now := time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC)
+	defer setClock(testclock.NewFakeClock(now))()
+	s, err := openAggregateStore(filepath.Join(t.TempDir(), "aggregates.db"), 0)
+	assert.NoError(t, err)
+	defer s.db.Close()
+	s.add(&RecentRun{Namespace: "test-namespace", CompletionTime: now})
+	s.add(&RecentRun{Namespace: "test-namespace", CompletionTime: now.AddDate(0, 0, -10)})
+	s.add(&RecentRun{Namespace: "other-namespace", CompletionTime: now})
+
+	get := func(query string) (int, []DailyAggregate) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, AggregatesPath+query, nil))
+		aggregates := []DailyAggregate{}
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &aggregates))
+		}
+		return w.Code, aggregates
+	}
+	_, aggregates := get("")
+	assert.Len(t, aggregates, 2)
+	_, aggregates = get("?namespace=test-namespace&days=14")
+	assert.Len(t, aggregates, 2)
+	assert.Equal(t, "2023-02-28", aggregates[0].Day)
+	code, _ := get("?days=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	var unset *aggregateStore
+	unset.add(&RecentRun{Namespace: "test-namespace"})
+}
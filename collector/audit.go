@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	ACTION_LABEL = "action"
+
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+	AuditOutcomeSkipped = "skipped"
+)
+
+// AuditRecord is a change the exporter makes at runtime, to the cluster or to its own configuration, as needed by the
+// compliance requirements of production clusters; none of the values are ever secrets, like passwords or their hashes
+type AuditRecord struct {
+	// Who made the change, like the detector behind a remediation
+	Who string
+	// What the change is, like the remediation action
+	What string
+	// Target is what was changed, like namespace/name of an object, or the path of a file
+	Target string
+	// Old and New are the values before and after the change, empty when they do not apply
+	Old string
+	New string
+	// Outcome is one of AuditOutcomeSuccess, AuditOutcomeFailure, or AuditOutcomeSkipped
+	Outcome string
+	// Err is why the change failed, or was skipped
+	Err error
+}
+
+// auditTrail writes the audit records to their own log stream, the audit logger, vs. the controller's, so they can
+// be shipped and retained on their own, and counts them, so missing records can be alerted on; unlike the high volume
+// log categories, they are never sampled or rate limited
+type auditTrail struct {
+	lock    sync.RWMutex
+	log     logr.Logger
+	records *prometheus.CounterVec
+}
+
+var audits = &auditTrail{log: ctrl.Log.WithName("audit")}
+
+func NewAuditRecordsMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	records := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_audit_records_total",
+		Help: "Number of audit records written for the changes the exporter made at runtime, by action and outcome",
+	}, []string{ACTION_LABEL, OUTCOME_LABEL})
+	registerer.MustRegister(records)
+	return records
+}
+
+func (a *auditTrail) configure(log logr.Logger) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.log = log
+}
+
+func (a *auditTrail) enable(records *prometheus.CounterVec) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.records = records
+}
+
+func (a *auditTrail) record(rec AuditRecord) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	keysAndValues := []interface{}{"who", rec.Who, "what", rec.What, "target", rec.Target,
+		"when", exporterClock.Now().UTC().Format(time.RFC3339Nano), "old", rec.Old, "new", rec.New, "outcome", rec.Outcome}
+	if rec.Err != nil {
+		keysAndValues = append(keysAndValues, "error", rec.Err.Error())
+	}
+	a.log.Info("audit", keysAndValues...)
+	if a.records != nil {
+		a.records.With(prometheus.Labels{ACTION_LABEL: rec.What, OUTCOME_LABEL: rec.Outcome}).Inc()
+	}
+}
+
+// Audit writes rec to the audit log stream, and counts it once the collector is set up; it is for the components
+// embedding the collector to record their own runtime changes alongside the collector's
+func Audit(rec AuditRecord) {
+	audits.record(rec)
+}
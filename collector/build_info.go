@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/version"
+)
+
+const (
+	// ConfigProfileEnvName names the configuration the exporter is deployed with, like an overlay name, so clusters
+	// meant to be configured alike can be compared
+	ConfigProfileEnvName = "CONFIG_PROFILE"
+	// DefaultConfigProfile is the profile label value when ConfigProfileEnvName is not set
+	DefaultConfigProfile = "default"
+	COLLECTORS_LABEL     = "collectors"
+	PROFILE_LABEL        = "profile"
+)
+
+// collectorNames are all the collectors that can be selected with WithCollectors, in the order they are set up
+var collectorNames = []string{
+	CollectorOverhead,
+	CollectorTaskRunGaps,
+	CollectorPipelineRefWait,
+	CollectorPipelineRunScheduled,
+	CollectorPipelineRunPending,
+	CollectorPodCreateToComplete,
+	CollectorPodCreateToKubeletAck,
+	CollectorPodKubeletToContainer,
+	CollectorTaskRefWait,
+	CollectorTaskRunScheduled,
+	CollectorNamespaceLifecycle,
+	CollectorDuplicateRuns,
+	CollectorResourceVerification,
+	CollectorPodSecurity,
+	CollectorPodPlacement,
+	CollectorChildPropagation,
+	CollectorPipelineRunResults,
+	CollectorPodScheduled,
+	CollectorObservedGenerationLag,
+	CollectorPipelineRunQueue,
+	CollectorPollers,
+	CollectorCustomRuns,
+	CollectorTektonConfig,
+	CollectorPullSecrets,
+}
+
+// names are the selected collectors, in the order of collectorNames, leaving out any unknown names
+func (s collectorSet) names() []string {
+	names := []string{}
+	for _, name := range collectorNames {
+		if s.enabled(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// featureNames are the enabled optional features by name, the heartbeat's features bitmap followed by the settings
+// that change the label values or names of the metrics
+func featureNames(r *ExporterReconcile) []string {
+	features := heartbeatFeatures(r)
+	names := []string{}
+	for _, feature := range []struct {
+		name    string
+		enabled bool
+	}{
+		{name: "tenant-label", enabled: features&(1<<featureBitTenant) != 0},
+		{name: "tenant-endpoints", enabled: features&(1<<featureBitTenantEP) != 0},
+		{name: "federation-endpoint", enabled: features&(1<<featureBitFederate) != 0},
+		{name: "remediation", enabled: features&(1<<featureBitRemediate) != 0},
+		{name: "registered-detectors", enabled: features&(1<<featureBitDetectors) != 0},
+		{name: "read-only", enabled: features&(1<<featureBitReadOnly) != 0},
+		{name: "reason-status-labels", enabled: settings.ReasonStatusLabels},
+		{name: "stable-metric-names", enabled: settings.MetricCompatLevel >= MetricCompatBoth},
+		{name: "run-labels", enabled: len(withRunLabelNames([]string{})) > 0},
+		{name: "pull-secret-aging", enabled: settings.PullSecretAging},
+	} {
+		if feature.enabled {
+			names = append(names, feature.name)
+		}
+	}
+	return names
+}
+
+// NewBuildInfoMetric is version.NewCollector's build info, with labels for what the exporter is configured to do, so
+// configuration drift across the fleet can be found with a query; its value is always 1
+func NewBuildInfoMetric(registerer prometheus.Registerer, collectors collectorSet, r *ExporterReconcile) prometheus.Gauge {
+	profile := settings.ConfigProfile
+	if len(profile) == 0 {
+		profile = DefaultConfigProfile
+	}
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pipeline_service_exporter_build_info",
+		Help: "A metric with a constant '1' value labeled by the version, revision, branch, goversion, goos, and goarch the exporter was built from, the enabled collectors and optional features, and the configuration profile",
+		ConstLabels: prometheus.Labels{
+			"version":        version.Version,
+			"revision":       version.Revision,
+			"branch":         version.Branch,
+			"goversion":      version.GoVersion,
+			"goos":           version.GoOS,
+			"goarch":         version.GoArch,
+			COLLECTORS_LABEL: strings.Join(collectors.names(), ","),
+			FEATURES_LABEL:   strings.Join(featureNames(r), ","),
+			PROFILE_LABEL:    profile,
+		},
+	})
+	gauge.Set(1)
+	registerer.MustRegister(gauge)
+	return gauge
+}
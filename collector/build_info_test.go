@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectorSetNames(t *testing.T) {
+	assert.Equal(t, collectorNames, collectorSet(nil).names())
+	o := newOptions(WithCollectors(CollectorPollers, CollectorOverhead, "unknown"))
+	assert.Equal(t, []string{CollectorOverhead, CollectorPollers}, o.collectorSet().names())
+}
+
+func TestBuildInfo(t *testing.T) {
+	defer setSettings(Settings{ReasonStatusLabels: true, MetricCompatLevel: MetricCompatBoth})()
+	registry := prometheus.NewRegistry()
+	r := &ExporterReconcile{readOnly: true, remediations: map[string]*remediationTracker{}}
+	NewBuildInfoMetric(registry, newOptions(WithCollectors(CollectorOverhead, CollectorTaskRunGaps)).collectorSet(), r)
+
+	family := gatherFamilies(t, registry)["pipeline_service_exporter_build_info"]
+	assert.NotNil(t, family)
+	assert.Equal(t, float64(1), family.GetMetric()[0].GetGauge().GetValue())
+	labels := map[string]string{}
+	for _, label := range family.GetMetric()[0].GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+	assert.Equal(t, "overhead,taskrun-gaps", labels[COLLECTORS_LABEL])
+	assert.Equal(t, "read-only,reason-status-labels,stable-metric-names", labels[FEATURES_LABEL])
+	assert.Equal(t, DefaultConfigProfile, labels[PROFILE_LABEL])
+	assert.Contains(t, labels, "goversion")
+
+	defer setSettings(Settings{ConfigProfile: "member-cluster"})()
+	registry = prometheus.NewRegistry()
+	NewBuildInfoMetric(registry, nil, &ExporterReconcile{remediations: map[string]*remediationTracker{}})
+	for _, label := range gatherFamilies(t, registry)["pipeline_service_exporter_build_info"].GetMetric()[0].GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+	assert.Equal(t, "member-cluster", labels[PROFILE_LABEL])
+	assert.Empty(t, labels[FEATURES_LABEL])
+}
@@ -0,0 +1,224 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CacheAuditPath is where an audit of the cache is run on demand, with a POST, on the admin listener
+	CacheAuditPath = "/debug/cache-audit"
+
+	// cacheAuditPageSize is how many PipelineRuns each page of the live list holds
+	cacheAuditPageSize = 500
+	// cacheAuditInterval is how long after an audit the next one can be run on demand, as each lists every
+	// PipelineRun from the API server
+	cacheAuditInterval = time.Minute
+)
+
+// CacheDiscrepancy is a namespace whose PipelineRuns in the cache do not match those on the API server
+type CacheDiscrepancy struct {
+	Namespace string
+	Cached    int
+	Live      int
+}
+
+// cacheAudit compares the PipelineRuns per namespace in the informer cache with a paginated live list, once the cache
+// has synced on startup and on demand, catching a cache misconfigured, say with a label selector, to miss PipelineRuns
+// and silently blind the collectors; PipelineRuns created or deleted between the two lists show up as small, passing
+// discrepancies, while a misconfiguration shows up as a lasting one
+type cacheAudit struct {
+	cache       client.Reader
+	live        client.Reader
+	synced      func(context.Context) bool
+	discrepancy *prometheus.GaugeVec
+	lock        sync.Mutex
+	// lastAudit are the namespaces with a discrepancy in the most recent audit, so they are zeroed once it is gone
+	lastAudit map[string]struct{}
+	// audited is when the most recent audit ran, to hold back the next one on demand
+	audited time.Time
+}
+
+func NewCacheDiscrepancyMetric(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	discrepancy := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_exporter_cache_discrepancy",
+		Help: "The number of PipelineRuns in the namespace on the API server minus those in the exporter's cache, as of the most recent audit",
+	}, []string{NS_LABEL})
+	registerer.MustRegister(discrepancy)
+	return discrepancy
+}
+
+func newCacheAudit(cache, live client.Reader, synced func(context.Context) bool, discrepancy *prometheus.GaugeVec) *cacheAudit {
+	return &cacheAudit{cache: cache, live: live, synced: synced, discrepancy: discrepancy, lastAudit: map[string]struct{}{}}
+}
+
+// cachedCounts counts the PipelineRuns of the watched version in the cache, without converting them, nor copying them
+// out of the cache, as only their namespaces are read; listing their metadata instead would start an informer of its
+// own
+func (a *cacheAudit) cachedCounts(ctx context.Context, useV1Beta1 bool) (map[string]int, error) {
+	counts := map[string]int{}
+	if useV1Beta1 {
+		list := &v1beta1.PipelineRunList{}
+		if err := a.cache.List(ctx, list, client.UnsafeDisableDeepCopy); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			counts[list.Items[i].Namespace]++
+		}
+		return counts, nil
+	}
+	list := &v1.PipelineRunList{}
+	if err := a.cache.List(ctx, list, client.UnsafeDisableDeepCopy); err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		counts[list.Items[i].Namespace]++
+	}
+	return counts, nil
+}
+
+// liveCounts counts the PipelineRuns on the API server, listing only their metadata, a page at a time
+func (a *cacheAudit) liveCounts(ctx context.Context, useV1Beta1 bool) (map[string]int, error) {
+	gvk := v1.SchemeGroupVersion.WithKind("PipelineRunList")
+	if useV1Beta1 {
+		gvk = v1beta1.SchemeGroupVersion.WithKind("PipelineRunList")
+	}
+	counts := map[string]int{}
+	continueToken := ""
+	for {
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(gvk)
+		if err := a.live.List(ctx, list, client.Limit(cacheAuditPageSize), client.Continue(continueToken)); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			counts[list.Items[i].Namespace]++
+		}
+		continueToken = list.Continue
+		if len(continueToken) == 0 {
+			return counts, nil
+		}
+	}
+}
+
+// audit lists the PipelineRuns from the cache and the API server, setting the discrepancy of every namespace off
+func (a *cacheAudit) audit(ctx context.Context) ([]CacheDiscrepancy, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.auditLocked(ctx)
+}
+
+// auditOnDemand is audit, unless the most recent audit ran less than cacheAuditInterval ago, returning how long until
+// the next one can run then
+func (a *cacheAudit) auditOnDemand(ctx context.Context) ([]CacheDiscrepancy, time.Duration, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if wait := cacheAuditInterval - exporterClock.Since(a.audited); !a.audited.IsZero() && wait > 0 {
+		return nil, wait, nil
+	}
+	discrepancies, err := a.auditLocked(ctx)
+	return discrepancies, 0, err
+}
+
+// auditLocked is audit; the caller holds the lock
+func (a *cacheAudit) auditLocked(ctx context.Context) ([]CacheDiscrepancy, error) {
+	a.audited = exporterClock.Now()
+	// both lists are of the same version, even if the watched one changes in between
+	useV1Beta1 := watchV1Beta1()
+	cached, err := a.cachedCounts(ctx, useV1Beta1)
+	if err != nil {
+		return nil, fmt.Errorf("cache audit list of the cached pipelineruns failed: %w", err)
+	}
+	live, err := a.liveCounts(ctx, useV1Beta1)
+	if err != nil {
+		return nil, fmt.Errorf("cache audit list of the live pipelineruns failed: %w", err)
+	}
+	namespaces := map[string]struct{}{}
+	for ns := range cached {
+		namespaces[ns] = struct{}{}
+	}
+	for ns := range live {
+		namespaces[ns] = struct{}{}
+	}
+	discrepancies := []CacheDiscrepancy{}
+	for ns := range namespaces {
+		if cached[ns] != live[ns] {
+			discrepancies = append(discrepancies, CacheDiscrepancy{Namespace: ns, Cached: cached[ns], Live: live[ns]})
+		}
+	}
+	sort.Slice(discrepancies, func(i, j int) bool {
+		return discrepancies[i].Namespace < discrepancies[j].Namespace
+	})
+	// like the poll style detectors, namespaces are zeroed vs. deleted, to allow for history based searches
+	audited := map[string]struct{}{}
+	for _, d := range discrepancies {
+		a.discrepancy.With(prometheus.Labels{NS_LABEL: d.Namespace}).Set(float64(d.Live - d.Cached))
+		audited[d.Namespace] = struct{}{}
+	}
+	for ns := range a.lastAudit {
+		if _, ok := audited[ns]; !ok {
+			a.discrepancy.With(prometheus.Labels{NS_LABEL: ns}).Set(0)
+		}
+	}
+	a.lastAudit = audited
+	if len(discrepancies) > 0 {
+		controllerLog.Info(fmt.Sprintf("WARNING: the cache audit found %d namespaces whose cached pipelineruns do not match those on the API server, like %s with %d cached and %d live",
+			len(discrepancies), discrepancies[0].Namespace, discrepancies[0].Cached, discrepancies[0].Live))
+	}
+	return discrepancies, nil
+}
+
+// Start audits the cache once it has synced
+func (a *cacheAudit) Start(ctx context.Context) error {
+	if !a.synced(ctx) {
+		return nil
+	}
+	if _, err := a.audit(ctx); err != nil {
+		controllerLog.Info(err.Error())
+	}
+	return nil
+}
+
+// NeedLeaderElection is false, as every replica has a cache of its own
+func (a *cacheAudit) NeedLeaderElection() bool {
+	return false
+}
+
+// ServeHTTP runs an audit on a POST, returning the namespaces with a discrepancy as JSON, or a 429 when the most recent
+// audit ran less than cacheAuditInterval ago
+func (a *cacheAudit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed, as an audit lists every pipelinerun from the API server", http.StatusMethodNotAllowed)
+		return
+	}
+	discrepancies, wait, err := a.auditOnDemand(r.Context())
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, fmt.Sprintf("the cache was audited less than %s ago", cacheAuditInterval), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(discrepancies); err != nil {
+		controllerLog.Info("unable to write the cache audit: " + err.Error())
+	}
+}
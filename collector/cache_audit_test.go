@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCacheAudit(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	pipelineRun := func(ns, name string) client.Object {
+		return &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+	}
+	cache := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pipelineRun("test-namespace", "test-1"),
+		pipelineRun("test-namespace", "test-2")).Build()
+	live := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pipelineRun("test-namespace", "test-1"),
+		pipelineRun("test-namespace", "test-2"), pipelineRun("test-namespace-2", "test-1")).Build()
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	fakeClock := testclock.NewFakeClock(now)
+	defer setClock(fakeClock)()
+	synced := func(context.Context) bool { return true }
+	audit := newCacheAudit(cache, live, synced, NewCacheDiscrepancyMetric(prometheus.NewRegistry()))
+
+	assert.NoError(t, audit.Start(context.TODO()))
+	validateGaugeVec(t, audit.discrepancy, prometheus.Labels{NS_LABEL: "test-namespace-2"}, float64(1))
+	validateGaugeVec(t, audit.discrepancy, prometheus.Labels{NS_LABEL: "test-namespace"}, float64(0))
+
+	// on demand, once the cache has caught up, the discrepancy is zeroed
+	assert.NoError(t, cache.Create(context.TODO(), pipelineRun("test-namespace-2", "test-1")))
+	w := httptest.NewRecorder()
+	audit.ServeHTTP(w, httptest.NewRequest(http.MethodGet, CacheAuditPath, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	// not right after the previous audit, as each lists every PipelineRun
+	w = httptest.NewRecorder()
+	audit.ServeHTTP(w, httptest.NewRequest(http.MethodPost, CacheAuditPath, nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	fakeClock.SetTime(now.Add(cacheAuditInterval))
+	w = httptest.NewRecorder()
+	audit.ServeHTTP(w, httptest.NewRequest(http.MethodPost, CacheAuditPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+	validateGaugeVec(t, audit.discrepancy, prometheus.Labels{NS_LABEL: "test-namespace-2"}, float64(0))
+}
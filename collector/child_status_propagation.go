@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func NewChildStatusPropagationMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	propagation := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "pipelinerun_child_completion_propagation_milliseconds",
+		Help: "Duration in milliseconds between a taskrun completing and the status of its pipelinerun being updated with its completion",
+		// reminder: exponential buckets need a start value greater than 0
+		// the results in buckets of 100, 500, 2500, 12500, 62500, 312500 milliseconds
+		Buckets: prometheus.ExponentialBuckets(float64(100), float64(5), 6),
+	}, withTenantLabelName([]string{NS_LABEL}))
+	registerer.MustRegister(propagation)
+	return propagation
+}
+
+// childStatusPropagationFilter observes how long the completion of a TaskRun takes to show up in the status of its
+// PipelineRun, a suspected component of the execution overhead, as the next TaskRun is only created once the
+// PipelineRun reconciler has seen the completion; the tekton controller updates the message, and with it the last
+// transition time, of the PipelineRun's succeeded condition with the count of completed TaskRuns, so each TaskRun
+// completed since the prior transition is observed against the new one; nothing is reconciled
+type childStatusPropagationFilter struct {
+	client client.Client
+	metric *prometheus.HistogramVec
+}
+
+func (f *childStatusPropagationFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *childStatusPropagationFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *childStatusPropagationFilter) Update(e event.UpdateEvent) bool {
+	oldPR, okold := e.ObjectOld.(*v1.PipelineRun)
+	newPR, oknew := e.ObjectNew.(*v1.PipelineRun)
+	if !okold || !oknew {
+		return false
+	}
+	oldCondition := oldPR.Status.GetCondition(apis.ConditionSucceeded)
+	newCondition := newPR.Status.GetCondition(apis.ConditionSucceeded)
+	if oldCondition == nil || newCondition == nil {
+		return false
+	}
+	previous := oldCondition.LastTransitionTime.Inner.Time
+	propagated := newCondition.LastTransitionTime.Inner.Time
+	if !propagated.After(previous) {
+		return false
+	}
+	ctx := context.Background()
+	for _, kidRef := range newPR.Status.ChildReferences {
+		if kidRef.Kind != "TaskRun" {
+			continue
+		}
+		kid := &v1.TaskRun{}
+		err := f.client.Get(ctx, types.NamespacedName{Namespace: newPR.Namespace, Name: kidRef.Name}, kid)
+		if err != nil || kid.Status.CompletionTime == nil {
+			continue
+		}
+		completed := kid.Status.CompletionTime.Time
+		// completed before the prior transition, so already observed, or not yet propagated
+		if !completed.After(previous) || completed.After(propagated) {
+			continue
+		}
+		f.metric.With(withTenantLabel(map[string]string{NS_LABEL: newPR.Namespace}, newPR.Namespace)).Observe(float64(propagated.Sub(completed).Milliseconds()))
+	}
+	return false
+}
+
+func (f *childStatusPropagationFilter) Generic(event.GenericEvent) bool {
+	return false
+}
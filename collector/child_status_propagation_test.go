@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestChildStatusPropagationFilter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	taskRun := func(name string, completed *time.Time) *v1.TaskRun {
+		tr := &v1.TaskRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: name}}
+		if completed != nil {
+			tr.Status.CompletionTime = &metav1.Time{Time: *completed}
+		}
+		return tr
+	}
+	cloneDone := base.Add(time.Minute)
+	buildDone := base.Add(3 * time.Minute)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(taskRun("clone", &cloneDone), taskRun("build", &buildDone), taskRun("push", nil)).Build()
+	metric := NewChildStatusPropagationMetric(prometheus.NewRegistry())
+	filter := &childStatusPropagationFilter{client: c, metric: metric}
+	pr := func(transitioned time.Time) *v1.PipelineRun {
+		return &v1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"},
+			Status: v1.PipelineRunStatus{
+				Status: duckv1.Status{Conditions: duckv1.Conditions{{
+					Type:               apis.ConditionSucceeded,
+					Status:             corev1.ConditionUnknown,
+					LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(transitioned)},
+				}}},
+				PipelineRunStatusFields: v1.PipelineRunStatusFields{ChildReferences: []v1.ChildStatusReference{
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "clone"},
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "build"},
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "push"},
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "deleted"},
+				}},
+			},
+		}
+	}
+	started := pr(base)
+	cloneSeen := pr(cloneDone.Add(2 * time.Second))
+	buildSeen := pr(buildDone.Add(5 * time.Second))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: started, ObjectNew: cloneSeen}))
+	// no transition, nothing new to observe
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: cloneSeen, ObjectNew: cloneSeen}))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: cloneSeen, ObjectNew: buildSeen}))
+
+	observer, err := metric.GetMetricWith(prometheus.Labels{NS_LABEL: "test-namespace"})
+	assert.NoError(t, err)
+	m := &dto.Metric{}
+	assert.NoError(t, observer.(prometheus.Histogram).Write(m))
+	assert.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(7000), m.GetHistogram().GetSampleSum())
+}
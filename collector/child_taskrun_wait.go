@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	childWaitBaseDelay   = time.Second
+	childWaitMaxDelay    = 2 * time.Minute
+	childWaitMaxAttempts = 10
+
+	ChildWaitPending          = "pending"
+	ChildWaitNoTaskRunKids    = "no-taskrun-children"
+	ChildWaitAttemptsExceeded = "attempts-exceeded"
+)
+
+// childTaskRunWait bounds how long ReconcileOverhead requeues running PipelineRuns whose TaskRuns are not visible yet,
+// with exponential backoff and an attempt budget, and gives up early on PipelineRuns that will never produce
+// TaskRuns; any later update to the PipelineRun, like its first TaskRun showing up, still triggers a Reconcile
+type childTaskRunWait struct {
+	registerer *collectorRegisterer
+	lock       sync.Mutex
+	attempts   map[string]int
+	requeued   map[string]time.Time
+	gaveUp     *prometheus.CounterVec
+}
+
+func NewChildTaskRunWait(registerer prometheus.Registerer) *childTaskRunWait {
+	reg := newCollectorRegisterer(registerer)
+	gaveUp := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_child_taskrun_wait_abandoned_total",
+		Help: "Number of running PipelineRuns no longer requeued while waiting on their first TaskRun, by reason",
+	}, withControlPlaneLabelName([]string{NS_LABEL, REASON_LABEL}))
+	reg.MustRegister(gaveUp)
+	return &childTaskRunWait{registerer: reg, attempts: map[string]int{}, requeued: map[string]time.Time{}, gaveUp: gaveUp}
+}
+
+// Close unregisters the metrics of the collector
+func (w *childTaskRunWait) Close() {
+	w.registerer.Close()
+}
+
+// terminalReason returns why the PipelineRun will never produce TaskRuns as things stand, if that is the case
+func terminalReason(pr *v1.PipelineRun) string {
+	if pr.IsPending() {
+		return ChildWaitPending
+	}
+	// children like CustomRuns have shown up, but no TaskRuns
+	if len(pr.Status.ChildReferences) > 0 {
+		return ChildWaitNoTaskRunKids
+	}
+	return ""
+}
+
+func (w *childTaskRunWait) requeue(pr *v1.PipelineRun) reconcile.Result {
+	key := pr.Namespace + "/" + pr.Name
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	reason := terminalReason(pr)
+	attempts := w.attempts[key]
+	if len(reason) == 0 && attempts >= childWaitMaxAttempts {
+		reason = ChildWaitAttemptsExceeded
+	}
+	if len(reason) > 0 {
+		delete(w.attempts, key)
+		delete(w.requeued, key)
+		controllerLog.Info(fmt.Sprintf("no longer waiting on taskruns for pipelinerun %s:%s: %s", pr.Namespace, pr.Name, reason))
+		w.gaveUp.With(withControlPlaneLabel(map[string]string{NS_LABEL: pr.Namespace, REASON_LABEL: reason}, pr.Namespace)).Inc()
+		return reconcile.Result{}
+	}
+	w.attempts[key] = attempts + 1
+	w.requeued[key] = exporterClock.Now()
+	delay := childWaitBaseDelay << uint(attempts)
+	if delay > childWaitMaxDelay {
+		delay = childWaitMaxDelay
+	}
+	return reconcile.Result{RequeueAfter: delay}
+}
+
+func (w *childTaskRunWait) forget(pr *v1.PipelineRun) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	delete(w.attempts, pr.Namespace+"/"+pr.Name)
+	delete(w.requeued, pr.Namespace+"/"+pr.Name)
+}
+
+// prune drops the PipelineRuns deleted while waiting, whose delete event was missed
+func (w *childTaskRunWait) prune(now time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, key := range evictKeys(StoreChildTaskRunWait, w.requeued, now) {
+		delete(w.attempts, key)
+		delete(w.requeued, key)
+	}
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+	"time"
+)
+
+func TestChildTaskRunWaitBackoff(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr).Build()
+	r := buildReconciler(c, nil, nil)
+	defer r.Close()
+	ctx := context.TODO()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}}
+
+	expected := childWaitBaseDelay
+	for i := 0; i < childWaitMaxAttempts; i++ {
+		result, err := r.Reconcile(ctx, request)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, result.RequeueAfter)
+		expected = expected * 2
+		if expected > childWaitMaxDelay {
+			expected = childWaitMaxDelay
+		}
+	}
+	// the budget is spent
+	result, err := r.Reconcile(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+	validateCounterVec(t, r.childWait.gaveUp, prometheus.Labels{NS_LABEL: pr.Namespace, REASON_LABEL: ChildWaitAttemptsExceeded}, float64(1))
+	// and a later event starts over
+	result, err = r.Reconcile(ctx, request)
+	assert.NoError(t, err)
+	assert.Equal(t, childWaitBaseDelay, result.RequeueAfter)
+}
+
+func TestChildTaskRunWaitTerminal(t *testing.T) {
+	w := &childTaskRunWait{attempts: map[string]int{}, gaveUp: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_child_wait_abandoned_total", Help: "test"},
+		[]string{NS_LABEL, REASON_LABEL})}
+	pending := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"},
+		Spec:       v1.PipelineRunSpec{Status: v1.PipelineRunSpecStatusPending},
+	}
+	assert.Equal(t, reconcile.Result{}, w.requeue(pending))
+	validateCounterVec(t, w.gaveUp, prometheus.Labels{NS_LABEL: "test-namespace", REASON_LABEL: ChildWaitPending}, float64(1))
+
+	customRunsOnly := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-2"}}
+	customRunsOnly.Status.ChildReferences = []v1.ChildStatusReference{{TypeMeta: runtime.TypeMeta{Kind: "CustomRun"}, Name: "test-2-run"}}
+	assert.Equal(t, reconcile.Result{}, w.requeue(customRunsOnly))
+	validateCounterVec(t, w.gaveUp, prometheus.Labels{NS_LABEL: "test-namespace", REASON_LABEL: ChildWaitNoTaskRunKids}, float64(1))
+	assert.Len(t, w.attempts, 0)
+}
+
+func TestMergeResults(t *testing.T) {
+	assert.Equal(t, reconcile.Result{}, mergeResults(reconcile.Result{}, reconcile.Result{}))
+	assert.Equal(t, reconcile.Result{RequeueAfter: time.Second}, mergeResults(reconcile.Result{RequeueAfter: time.Second}, reconcile.Result{}))
+	assert.Equal(t, reconcile.Result{RequeueAfter: time.Second}, mergeResults(reconcile.Result{}, reconcile.Result{RequeueAfter: time.Second}))
+	assert.Equal(t, reconcile.Result{RequeueAfter: time.Second}, mergeResults(reconcile.Result{RequeueAfter: time.Minute}, reconcile.Result{RequeueAfter: time.Second}))
+	assert.Equal(t, reconcile.Result{Requeue: true}, mergeResults(reconcile.Result{Requeue: true}, reconcile.Result{}))
+}
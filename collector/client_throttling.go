@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/rest"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+const LIMIT_LABEL = "limit"
+
+// clientThrottleTracker is client-go's rate limiter latency metric, which every rest client observes how long each of
+// its requests waited on the client side rate limiter with, so it can be shown whether the exporter's own throttling
+// delays the freshness of the metrics on busy clusters
+type clientThrottleTracker struct {
+	lock  sync.RWMutex
+	waits *prometheus.HistogramVec
+	// next is the latency metric installed before the tracker, say by an embedder, which still sees every observation
+	next clientmetrics.LatencyMetric
+}
+
+var clientThrottles = &clientThrottleTracker{}
+
+func NewClientRateLimiterWaitMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	waits := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipeline_service_exporter_client_rate_limiter_wait_seconds",
+		Help:    "Duration in seconds API server requests waited on the client side rate limiter, by request verb",
+		Buckets: []float64{0.001, 0.01, 0.1, 0.5, 1, 2, 5, 10, 30, 60},
+	}, []string{VERB_LABEL})
+	registerer.MustRegister(waits)
+	return waits
+}
+
+// NewClientRateLimitMetric is the QPS and burst the manager's clients are limited to, to compare the waits with
+func NewClientRateLimitMetric(registerer prometheus.Registerer, cfg *rest.Config) *prometheus.GaugeVec {
+	limits := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_exporter_client_rate_limit",
+		Help: "The client side rate limit of the exporter's API server requests, as qps and burst",
+	}, []string{LIMIT_LABEL})
+	registerer.MustRegister(limits)
+	qps, burst := float64(cfg.QPS), float64(cfg.Burst)
+	if qps == 0 {
+		qps = float64(rest.DefaultQPS)
+	}
+	if burst == 0 {
+		burst = float64(rest.DefaultBurst)
+	}
+	limits.With(prometheus.Labels{LIMIT_LABEL: "qps"}).Set(qps)
+	limits.With(prometheus.Labels{LIMIT_LABEL: "burst"}).Set(burst)
+	return limits
+}
+
+// install wraps client-go's rate limiter latency metric with the tracker; client-go's Register only takes effect once,
+// and controller-runtime already calls it, so the metric is wrapped vs. replaced, and only the first time
+func (t *clientThrottleTracker) install() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if clientmetrics.RateLimiterLatency == clientmetrics.LatencyMetric(t) {
+		return
+	}
+	t.next = clientmetrics.RateLimiterLatency
+	clientmetrics.RateLimiterLatency = t
+}
+
+func (t *clientThrottleTracker) enable(waits *prometheus.HistogramVec) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.waits = waits
+}
+
+// Observe implements client-go's metrics.LatencyMetric; the URL is left out, as it would make for too many series
+func (t *clientThrottleTracker) Observe(ctx context.Context, verb string, u url.URL, latency time.Duration) {
+	t.lock.RLock()
+	waits, next := t.waits, t.next
+	t.lock.RUnlock()
+	if next != nil {
+		next.Observe(ctx, verb, u, latency)
+	}
+	if waits == nil {
+		return
+	}
+	waits.With(prometheus.Labels{VERB_LABEL: verb}).Observe(latency.Seconds())
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+func TestClientThrottles(t *testing.T) {
+	waits := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_rate_limiter_wait_seconds", Help: "test"}, []string{VERB_LABEL})
+	// nothing is observed until the metric is enabled
+	clientThrottles.Observe(context.Background(), "GET", url.URL{Path: "/api/v1/pods"}, time.Second)
+	validateHistogramVecZeroCount(t, waits, prometheus.Labels{VERB_LABEL: "GET"})
+
+	clientThrottles.enable(waits)
+	defer clientThrottles.enable(nil)
+	clientThrottles.Observe(context.Background(), "GET", url.URL{Path: "/api/v1/pods"}, 2*time.Second)
+	validateHistogramVec(t, waits, prometheus.Labels{VERB_LABEL: "GET"}, false)
+}
+
+type testLatencyMetric struct {
+	observed int
+}
+
+func (m *testLatencyMetric) Observe(context.Context, string, url.URL, time.Duration) {
+	m.observed++
+}
+
+func TestClientThrottlesInstall(t *testing.T) {
+	previous := clientmetrics.RateLimiterLatency
+	defer func() {
+		clientmetrics.RateLimiterLatency = previous
+		clientThrottles.next = nil
+	}()
+	embedder := &testLatencyMetric{}
+	clientmetrics.RateLimiterLatency = embedder
+	clientThrottles.install()
+	// installing again, say for another manager, does not wrap the tracker with itself
+	clientThrottles.install()
+	assert.Equal(t, clientmetrics.LatencyMetric(clientThrottles), clientmetrics.RateLimiterLatency)
+
+	// the metric installed before still sees the observations
+	clientmetrics.RateLimiterLatency.Observe(context.Background(), "GET", url.URL{Path: "/api/v1/pods"}, time.Second)
+	assert.Equal(t, 1, embedder.observed)
+}
+
+func TestClientRateLimitMetric(t *testing.T) {
+	limits := NewClientRateLimitMetric(prometheus.NewRegistry(), &rest.Config{QPS: 50, Burst: 100})
+	validateGaugeVec(t, limits, prometheus.Labels{LIMIT_LABEL: "qps"}, float64(50))
+	validateGaugeVec(t, limits, prometheus.Labels{LIMIT_LABEL: "burst"}, float64(100))
+
+	// client-go's defaults apply when they are not set
+	limits = NewClientRateLimitMetric(prometheus.NewRegistry(), &rest.Config{})
+	validateGaugeVec(t, limits, prometheus.Labels{LIMIT_LABEL: "qps"}, float64(rest.DefaultQPS))
+	validateGaugeVec(t, limits, prometheus.Labels{LIMIT_LABEL: "burst"}, float64(rest.DefaultBurst))
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// ClusterNameEnvName allows for explicitly naming the cluster, and takes precedence over the OpenShift ClusterVersion ID
+	ClusterNameEnvName = "CLUSTER_NAME"
+	CLUSTER_LABEL      = "cluster"
+)
+
+var (
+	// constLabels are added to every metric this exporter registers
+	constLabels = prometheus.Labels{}
+	// baseRegisterer is controller-runtime's registry, unless NewCollector was given another one
+	baseRegisterer prometheus.Registerer = metrics.Registry
+
+	clusterVersionGVK = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "ClusterVersion"}
+)
+
+// exporterRegisterer should be used by all the collectors in this package when registering their metrics, so that
+// any constant labels are applied to them
+func exporterRegisterer() prometheus.Registerer {
+	registerer := baseRegisterer
+	if metricSnapshots.enabled() {
+		registerer = &restoringRegisterer{registerer: registerer, snapshot: metricSnapshots}
+	}
+	if len(constLabels) == 0 {
+		return registerer
+	}
+	return prometheus.WrapRegistererWith(constLabels, registerer)
+}
+
+// discoverClusterIdentity returns the configured cluster name if set, or the ID from the OpenShift ClusterVersion
+// singleton; on non-OpenShift clusters, or if we are not allowed to get the ClusterVersion, the empty string is returned
+// and no cluster label is added
+func discoverClusterIdentity(ctx context.Context, c client.Client) string {
+	name := strings.TrimSpace(settings.ClusterName)
+	if len(name) > 0 {
+		return name
+	}
+	cv := &unstructured.Unstructured{}
+	cv.SetGroupVersionKind(clusterVersionGVK)
+	err := c.Get(ctx, types.NamespacedName{Name: "version"}, cv)
+	if err != nil {
+		controllerLog.Info(fmt.Sprintf("could not get the cluster version for a cluster label: %s", err.Error()))
+		return ""
+	}
+	id, _, err := unstructured.NestedString(cv.Object, "spec", "clusterID")
+	if err != nil {
+		controllerLog.Info(fmt.Sprintf("could not get the cluster ID from the cluster version: %s", err.Error()))
+		return ""
+	}
+	return id
+}
+
+func setClusterIdentity(id string) {
+	if len(id) == 0 {
+		delete(constLabels, CLUSTER_LABEL)
+		return
+	}
+	controllerLog.Info(fmt.Sprintf("adding %s=%s to all metrics", CLUSTER_LABEL, id))
+	constLabels[CLUSTER_LABEL] = id
+}
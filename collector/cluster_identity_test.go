@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"testing"
+)
+
+func TestDiscoverClusterIdentity(t *testing.T) {
+	defer setSettings(Settings{})()
+	ctx := context.TODO()
+	c := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()
+	// not openshift
+	assert.Equal(t, "", discoverClusterIdentity(ctx, c))
+
+	cv := &unstructured.Unstructured{}
+	cv.SetGroupVersionKind(clusterVersionGVK)
+	cv.SetName("version")
+	assert.NoError(t, unstructured.SetNestedField(cv.Object, "1234-abcd", "spec", "clusterID"))
+	assert.NoError(t, c.Create(ctx, cv))
+	assert.Equal(t, "1234-abcd", discoverClusterIdentity(ctx, c))
+
+	settings.ClusterName = "stone-prd-m01"
+	assert.Equal(t, "stone-prd-m01", discoverClusterIdentity(ctx, c))
+}
+
+func TestClusterIdentityLabel(t *testing.T) {
+	defer setClusterIdentity("")
+	setClusterIdentity("stone-prd-m01")
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_cluster_identity_gauge", Help: "test gauge"})
+	exporterRegisterer().MustRegister(gauge)
+	defer exporterRegisterer().Unregister(gauge)
+	families, err := metrics.Registry.Gather()
+	assert.NoError(t, err)
+	var found *dto.MetricFamily
+	for _, mf := range families {
+		if mf.GetName() == "test_cluster_identity_gauge" {
+			found = mf
+		}
+	}
+	assert.NotNil(t, found)
+	assert.Len(t, found.Metric[0].Label, 1)
+	assert.Equal(t, CLUSTER_LABEL, found.Metric[0].Label[0].GetName())
+	assert.Equal(t, "stone-prd-m01", found.Metric[0].Label[0].GetValue())
+}
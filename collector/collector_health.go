@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// CollectorFailureThresholdEnvName is how many panics or reconcile errors a collector may have within
+	// collectorFailureWindow before it is disabled, so a collector tripping over every object does not flood the logs
+	// and retries at the expense of the others; not set, or 0, never disables the collectors
+	CollectorFailureThresholdEnvName = "COLLECTOR_FAILURE_THRESHOLD"
+	// CollectorsPath is where the disabled collectors are listed, and re-enabled, on the admin listener
+	CollectorsPath = "/debug/collectors"
+
+	DisabledReasonPanics = "panics"
+	DisabledReasonErrors = "errors"
+
+	collectorFailureWindow = 10 * time.Minute
+	// collectorCoolOff is how long a collector disabled for its reconcile errors, which a brief API server outage
+	// causes as well, stays disabled before it is re-enabled on its own; those disabled for panics, which are bugs,
+	// stay disabled until re-enabled on the admin listener
+	collectorCoolOff = 10 * time.Minute
+)
+
+// DisabledCollector is a collector disabled by its failures
+type DisabledCollector struct {
+	Collector string
+	Reason    string
+	Since     time.Time
+}
+
+// collectorHealthTracker disables the collectors whose filters panic, or whose reconciles panic or fail, more than
+// the threshold within the window; a disabled collector's filter drops every event, and its metrics are unregistered,
+// so stale series are not served as if they were current, until it is re-enabled on the admin listener, or, when
+// disabled for errors, after the cool-off
+type collectorHealthTracker struct {
+	lock     sync.Mutex
+	failures map[string][]time.Time
+	disabled map[string]DisabledCollector
+	gauge    *prometheus.GaugeVec
+	// registerers are those of the metrics of each collector, suspended while it is disabled
+	registerers map[string][]*collectorRegisterer
+}
+
+var collectorHealth = &collectorHealthTracker{}
+
+func NewCollectorDisabledMetric(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	disabled := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_exporter_collector_disabled",
+		Help: "1 while a collector is disabled for failing more than the threshold, by collector and reason, 0 once it is re-enabled",
+	}, []string{COLLECTOR_LABEL, REASON_LABEL})
+	registerer.MustRegister(disabled)
+	return disabled
+}
+
+// enable starts tracking the failures with the gauge, or stops with nil, re-enabling every collector
+func (t *collectorHealthTracker) enable(gauge *prometheus.GaugeVec) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for name := range t.disabled {
+		for _, r := range t.registerers[name] {
+			r.resume()
+		}
+	}
+	t.gauge = gauge
+	t.failures = map[string][]time.Time{}
+	t.disabled = map[string]DisabledCollector{}
+	t.registerers = map[string][]*collectorRegisterer{}
+}
+
+// track has the metrics registered through the registerer unregistered while the collector is disabled
+func (t *collectorHealthTracker) track(name string, registerer *collectorRegisterer) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.registerers == nil {
+		t.registerers = map[string][]*collectorRegisterer{}
+	}
+	t.registerers[name] = append(t.registerers[name], registerer)
+}
+
+func (t *collectorHealthTracker) isDisabled(name string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	disabled, ok := t.disabled[name]
+	if ok && disabled.Reason == DisabledReasonErrors && exporterClock.Since(disabled.Since) >= collectorCoolOff {
+		t.reenableLocked(name, disabled)
+		controllerLog.Info(fmt.Sprintf("re-enabled collector %s after the %s cool-off", name, collectorCoolOff))
+		return false
+	}
+	return ok
+}
+
+// failed counts a failure of the collector, disabling it when its failures within the window reach the threshold
+func (t *collectorHealthTracker) failed(name, reason string) {
+	threshold := settings.CollectorFailureThreshold
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.gauge == nil || threshold <= 0 {
+		return
+	}
+	if _, ok := t.disabled[name]; ok {
+		return
+	}
+	now := exporterClock.Now()
+	recent := []time.Time{}
+	for _, at := range t.failures[name] {
+		if now.Sub(at) < collectorFailureWindow {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, now)
+	if len(recent) < threshold {
+		t.failures[name] = recent
+		return
+	}
+	delete(t.failures, name)
+	t.disabled[name] = DisabledCollector{Collector: name, Reason: reason, Since: now}
+	for _, r := range t.registerers[name] {
+		r.suspend()
+	}
+	t.gauge.With(prometheus.Labels{COLLECTOR_LABEL: name, REASON_LABEL: reason}).Set(1)
+	controllerLog.Info(fmt.Sprintf("WARNING: disabling collector %s after %d %s within %s; re-enable it with a POST to %s?enable=%s on the admin listener",
+		name, len(recent), reason, collectorFailureWindow, CollectorsPath, name))
+}
+
+// reenable turns the collector back on, with a clean slate of failures, returning false when it was not disabled
+func (t *collectorHealthTracker) reenable(name string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	disabled, ok := t.disabled[name]
+	if !ok {
+		return false
+	}
+	t.reenableLocked(name, disabled)
+	controllerLog.Info(fmt.Sprintf("re-enabled collector %s", name))
+	return true
+}
+
+// reenableLocked turns the disabled collector back on; the caller holds the lock
+func (t *collectorHealthTracker) reenableLocked(name string, disabled DisabledCollector) {
+	delete(t.disabled, name)
+	for _, r := range t.registerers[name] {
+		r.resume()
+	}
+	if t.gauge != nil {
+		t.gauge.With(prometheus.Labels{COLLECTOR_LABEL: name, REASON_LABEL: disabled.Reason}).Set(0)
+	}
+}
+
+func (t *collectorHealthTracker) list() []DisabledCollector {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	disabled := []DisabledCollector{}
+	for _, d := range t.disabled {
+		disabled = append(disabled, d)
+	}
+	sort.Slice(disabled, func(i, j int) bool {
+		return disabled[i].Collector < disabled[j].Collector
+	})
+	return disabled
+}
+
+// ServeHTTP returns the disabled collectors as JSON, re-enabling the one of the enable query parameter on a POST
+func (t *collectorHealthTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		name := r.URL.Query().Get("enable")
+		if len(name) == 0 {
+			http.Error(w, "the enable query parameter must name the collector to re-enable", http.StatusBadRequest)
+			return
+		}
+		if !t.reenable(name) {
+			http.Error(w, fmt.Sprintf("collector %s is not disabled", name), http.StatusNotFound)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "only GET and POST are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(t.list()); err != nil {
+		controllerLog.Info("unable to write the disabled collectors: " + err.Error())
+	}
+}
@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+func TestCollectorHealth(t *testing.T) {
+	defer setSettings(Settings{CollectorFailureThreshold: 2})()
+	gauge := NewCollectorDisabledMetric(prometheus.NewRegistry())
+	collectorHealth.enable(gauge)
+	defer collectorHealth.enable(nil)
+	registry := prometheus.NewRegistry()
+	placementReg := newCollectorRegisterer(registry)
+	collectorHealth.track(CollectorPodPlacement, placementReg)
+	placement := NewPodPlacementMetric(placementReg)
+	placement.With(prometheus.Labels{ZONE_LABEL: "zone-a", NODE_POOL_LABEL: "pool-a"}).Inc()
+	assert.Contains(t, gatherFamilies(t, registry), "pipeline_service_taskrun_pods_scheduled_total")
+	defer recoveredPanics.enable(nil)
+	enableTestPanics()
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	healthy := &countingFilter{name: CollectorTaskRunGaps, inner: &recordingPredicate{}}
+	f := &ExporterFilter{noReconcile: []predicate.Predicate{&countingFilter{name: CollectorPodPlacement, inner: &panickingPredicate{}}}, yesReconcile: []predicate.Predicate{healthy}}
+
+	// the panics of one collector disable it, once they reach the threshold, but not the others
+	f.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr})
+	assert.False(t, collectorHealth.isDisabled(CollectorPodPlacement))
+	assert.True(t, f.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	assert.True(t, collectorHealth.isDisabled(CollectorPodPlacement))
+	assert.False(t, collectorHealth.isDisabled(CollectorTaskRunGaps))
+	validateGaugeVec(t, gauge, prometheus.Labels{COLLECTOR_LABEL: CollectorPodPlacement, REASON_LABEL: DisabledReasonPanics}, float64(1))
+	// its series are no longer served
+	assert.Empty(t, gatherFamilies(t, registry))
+
+	// the events are dropped vs. panicking again
+	f.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr})
+
+	w := httptest.NewRecorder()
+	collectorHealth.ServeHTTP(w, httptest.NewRequest(http.MethodGet, CollectorsPath, nil))
+	disabled := []DisabledCollector{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &disabled))
+	assert.Len(t, disabled, 1)
+	assert.Equal(t, CollectorPodPlacement, disabled[0].Collector)
+
+	w = httptest.NewRecorder()
+	collectorHealth.ServeHTTP(w, httptest.NewRequest(http.MethodPost, CollectorsPath+"?enable="+CollectorTaskRunGaps, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = httptest.NewRecorder()
+	collectorHealth.ServeHTTP(w, httptest.NewRequest(http.MethodPost, CollectorsPath+"?enable="+CollectorPodPlacement, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, collectorHealth.isDisabled(CollectorPodPlacement))
+	validateGaugeVec(t, gauge, prometheus.Labels{COLLECTOR_LABEL: CollectorPodPlacement, REASON_LABEL: DisabledReasonPanics}, float64(0))
+	// and are served again once it is re-enabled
+	assert.Contains(t, gatherFamilies(t, registry), "pipeline_service_taskrun_pods_scheduled_total")
+}
+
+// createPanickingPredicate panics on its create events only
+type createPanickingPredicate struct {
+	recordingPredicate
+}
+
+func (p *createPanickingPredicate) Create(event.CreateEvent) bool {
+	var pr *v1.PipelineRun
+	return pr.IsDone()
+}
+
+func TestCollectorHealthAllEvents(t *testing.T) {
+	defer setSettings(Settings{CollectorFailureThreshold: 2})()
+	collectorHealth.enable(NewCollectorDisabledMetric(prometheus.NewRegistry()))
+	defer collectorHealth.enable(nil)
+	defer recoveredPanics.enable(nil)
+	enableTestPanics()
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	inner := &createPanickingPredicate{}
+	f := &ExporterFilter{noReconcile: []predicate.Predicate{&countingFilter{name: CollectorPodPlacement, inner: inner}}}
+
+	// the panics of the create events count as much as those of the updates
+	f.Create(event.CreateEvent{Object: pr})
+	f.Create(event.CreateEvent{Object: pr})
+	assert.True(t, collectorHealth.isDisabled(CollectorPodPlacement))
+	// and none of the events reach a disabled collector
+	f.Delete(event.DeleteEvent{Object: pr})
+	f.Generic(event.GenericEvent{Object: pr})
+	f.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr})
+	assert.Nil(t, inner.updated.ObjectNew)
+}
+
+func TestCollectorHealthCoolOff(t *testing.T) {
+	defer setSettings(Settings{CollectorFailureThreshold: 1})()
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	fakeClock := testclock.NewFakeClock(now)
+	defer setClock(fakeClock)()
+	collectorHealth.enable(NewCollectorDisabledMetric(prometheus.NewRegistry()))
+	defer collectorHealth.enable(nil)
+
+	// a collector disabled for its errors, as with a brief API server outage, is re-enabled after the cool-off
+	collectorHealth.failed(CollectorOverhead, DisabledReasonErrors)
+	collectorHealth.failed(CollectorPodPlacement, DisabledReasonPanics)
+	fakeClock.SetTime(now.Add(collectorCoolOff - time.Second))
+	assert.True(t, collectorHealth.isDisabled(CollectorOverhead))
+	fakeClock.SetTime(now.Add(collectorCoolOff))
+	assert.False(t, collectorHealth.isDisabled(CollectorOverhead))
+	// but not one disabled for its panics
+	assert.True(t, collectorHealth.isDisabled(CollectorPodPlacement))
+}
+
+func TestCollectorHealthLeaderOnly(t *testing.T) {
+	r := &ExporterReconcile{elected: make(chan struct{}), recentRuns: newRecentRunBuffer(1)}
+	mux := http.NewServeMux()
+	addAdminHandlers(mux, r)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, CollectorsPath+"?enable="+CollectorPodPlacement, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TektonControlPlanesEnvName is a comma separated list of <name>=<tekton namespace>/<namespace regex> entries, one
+	// for each of the tekton control planes of the cluster, like the one of OpenShift Pipelines and an upstream one;
+	// the tekton-config collector polls the ConfigMaps in each control plane's namespace, and the run level metrics of
+	// the runs in the namespaces matching its regex, in full, get its name as their control_plane label, the first
+	// matching entry winning; when set, TEKTON_NAMESPACE is not used
+	TektonControlPlanesEnvName = "TEKTON_CONTROL_PLANES"
+
+	CONTROL_PLANE_LABEL = "control_plane"
+)
+
+// TektonControlPlane is one of the tekton installations of the cluster, with the namespaces whose runs it reconciles
+type TektonControlPlane struct {
+	Name       string
+	Namespace  string
+	Namespaces *regexp.Regexp
+}
+
+// ParseTektonControlPlane parses a <name>=<tekton namespace>/<namespace regex> entry of TektonControlPlanesEnvName
+func ParseTektonControlPlane(entry string) (TektonControlPlane, error) {
+	nameAndRest := strings.SplitN(entry, "=", 2)
+	if len(nameAndRest) != 2 {
+		return TektonControlPlane{}, fmt.Errorf("%q is not a <name>=<tekton namespace>/<namespace regex> entry", entry)
+	}
+	name := strings.TrimSpace(nameAndRest[0])
+	if len(name) == 0 || !model.LabelValue(name).IsValid() {
+		return TektonControlPlane{}, fmt.Errorf("%q of %q is not a valid control plane name", name, entry)
+	}
+	// namespaces can not have a slash, while the regex can
+	nsAndRegex := strings.SplitN(nameAndRest[1], "/", 2)
+	if len(nsAndRegex) != 2 {
+		return TektonControlPlane{}, fmt.Errorf("%q is not a <name>=<tekton namespace>/<namespace regex> entry", entry)
+	}
+	ns := strings.TrimSpace(nsAndRegex[0])
+	if msgs := validation.IsDNS1123Label(ns); len(msgs) > 0 {
+		return TektonControlPlane{}, fmt.Errorf("%q of %q can not be a namespace: %s", ns, entry, strings.Join(msgs, ", "))
+	}
+	namespaces, err := regexp.Compile("^(?:" + strings.TrimSpace(nsAndRegex[1]) + ")$")
+	if err != nil {
+		return TektonControlPlane{}, fmt.Errorf("the namespace regex of %q is not valid: %s", entry, err.Error())
+	}
+	return TektonControlPlane{Name: name, Namespace: ns, Namespaces: namespaces}, nil
+}
+
+// tektonControlPlanes are the control planes of the settings, skipping the malformed entries and the repeated names;
+// none are configured when it is empty, and the single control plane is in tektonNamespace()
+func tektonControlPlanes() []TektonControlPlane {
+	planes := []TektonControlPlane{}
+	seen := map[string]struct{}{}
+	for _, entry := range settings.TektonControlPlanes {
+		plane, err := ParseTektonControlPlane(entry)
+		if err != nil {
+			controllerLog.Error(err, fmt.Sprintf("ignoring the %s entry", TektonControlPlanesEnvName))
+			continue
+		}
+		if _, dup := seen[plane.Name]; dup {
+			controllerLog.Info(fmt.Sprintf("ignoring the %s entry %q, as the control plane %s is already configured", TektonControlPlanesEnvName, entry, plane.Name))
+			continue
+		}
+		seen[plane.Name] = struct{}{}
+		planes = append(planes, plane)
+	}
+	return planes
+}
+
+// controlPlaneLabelProvider labels the runs with the name of the first control plane whose regex matches their
+// namespace, or the empty value when none does
+type controlPlaneLabelProvider []TektonControlPlane
+
+func (p controlPlaneLabelProvider) Labels(run client.Object) map[string]string {
+	return map[string]string{CONTROL_PLANE_LABEL: p.name(run.GetNamespace())}
+}
+
+func (p controlPlaneLabelProvider) name(ns string) string {
+	for _, plane := range p {
+		if plane.Namespaces.MatchString(ns) {
+			return plane.Name
+		}
+	}
+	return ""
+}
+
+// controlPlanes is configured with the options before any of the metrics are created, like tenants, since whether
+// control planes are configured dictates the label names of the pod, TaskRun and namespace level metrics
+var controlPlanes = &controlPlaneResolver{}
+
+type controlPlaneResolver struct {
+	lock   sync.RWMutex
+	planes controlPlaneLabelProvider
+}
+
+func (r *controlPlaneResolver) configure(planes []TektonControlPlane) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.planes = planes
+}
+
+// withControlPlaneLabelName should be used, after withTenantLabelName, when defining the label names of the metrics
+// of the pods, TaskRuns and namespaces, whose runs are not labelled by withRunLabelNames
+func withControlPlaneLabelName(labelNames []string) []string {
+	controlPlanes.lock.RLock()
+	defer controlPlanes.lock.RUnlock()
+	if len(controlPlanes.planes) == 0 {
+		return labelNames
+	}
+	return append(labelNames, CONTROL_PLANE_LABEL)
+}
+
+// withControlPlaneLabel should be used when building the labels to observe a metric whose label names came from
+// withControlPlaneLabelName
+func withControlPlaneLabel(labels map[string]string, ns string) map[string]string {
+	controlPlanes.lock.RLock()
+	defer controlPlanes.lock.RUnlock()
+	if len(controlPlanes.planes) == 0 {
+		return labels
+	}
+	labels[CONTROL_PLANE_LABEL] = controlPlanes.planes.name(ns)
+	return labels
+}
+
+// withControlPlaneRunLabel adds the control_plane label, when control planes are configured, to the label provider
+// and allowed names, like withRHTAPRunLabels
+func withControlPlaneRunLabel(provider LabelProvider, allowed []string, planes []TektonControlPlane) (LabelProvider, []string) {
+	if len(planes) == 0 {
+		return provider, allowed
+	}
+	// a label of the same name from the other providers is the one kept
+	var planeProvider LabelProvider = controlPlaneLabelProvider(planes)
+	if provider != nil {
+		planeProvider = combinedLabelProvider{planeProvider, provider}
+	}
+	return planeProvider, append(append([]string{}, allowed...), CONTROL_PLANE_LABEL)
+}
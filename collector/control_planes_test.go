@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestParseTektonControlPlane(t *testing.T) {
+	plane, err := ParseTektonControlPlane("upstream=tekton-pipelines/upstream-.*")
+	assert.NoError(t, err)
+	assert.Equal(t, "upstream", plane.Name)
+	assert.Equal(t, "tekton-pipelines", plane.Namespace)
+	// the regex matches the namespace in full
+	assert.True(t, plane.Namespaces.MatchString("upstream-team"))
+	assert.False(t, plane.Namespaces.MatchString("not-upstream-team"))
+
+	for _, entry := range []string{"upstream", "upstream=tekton-pipelines", "=tekton-pipelines/.*", "upstream=Tekton/.*", "upstream=tekton-pipelines/("} {
+		_, err = ParseTektonControlPlane(entry)
+		assert.Error(t, err, entry)
+	}
+}
+
+func TestControlPlanes(t *testing.T) {
+	defer setSettings(Settings{TektonControlPlanes: []string{"osp=openshift-pipelines/.*-tenant", "upstream=tekton-pipelines/upstream-.*",
+		"osp=openshift-pipelines/.*", "malformed"}})()
+	planes := tektonControlPlanes()
+	assert.Len(t, planes, 2)
+
+	provider, allowed := withControlPlaneRunLabel(nil, nil, planes)
+	assert.Equal(t, []string{CONTROL_PLANE_LABEL}, allowed)
+	run := func(ns string) *v1.PipelineRun {
+		return &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "test"}}
+	}
+	assert.Equal(t, "osp", provider.Labels(run("team-tenant"))[CONTROL_PLANE_LABEL])
+	assert.Equal(t, "upstream", provider.Labels(run("upstream-team"))[CONTROL_PLANE_LABEL])
+	assert.Equal(t, "", provider.Labels(run("other"))[CONTROL_PLANE_LABEL])
+
+	// the pod, TaskRun and namespace level metrics are labelled by the namespace too
+	controlPlanes.configure(planes)
+	defer controlPlanes.configure(nil)
+	waits := NewWaitingOnPodCreateAttemptCollector(prometheus.NewRegistry())
+	defer waits.Close()
+	waits.IncCollector("team-tenant")
+	waits.IncCollector("other")
+	validateGaugeVec(t, waits.waitPodCreate, prometheus.Labels{NS_LABEL: "team-tenant", CONTROL_PLANE_LABEL: "osp"}, float64(1))
+	validateGaugeVec(t, waits.waitPodCreate, prometheus.Labels{NS_LABEL: "other", CONTROL_PLANE_LABEL: ""}, float64(1))
+
+	// the ConfigMaps of each control plane are polled, and told apart
+	flags := func(ns, apiFields string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: ConfigMapFeatureFlags},
+			Data: map[string]string{"enable-api-fields": apiFields}}
+	}
+	cl := fake.NewClientBuilder().WithObjects(flags("openshift-pipelines", "stable"), flags("tekton-pipelines", "alpha")).Build()
+	c := NewTektonConfigCollector(prometheus.NewRegistry(), cl)
+	defer c.Close()
+	c.poll(context.TODO())
+	validateGaugeVec(t, c.values, prometheus.Labels{CONFIGMAP_LABEL: ConfigMapFeatureFlags, KEY_LABEL: "enable-api-fields", VALUE_LABEL: "stable", CONTROL_PLANE_LABEL: "osp"}, float64(1))
+	validateGaugeVec(t, c.values, prometheus.Labels{CONFIGMAP_LABEL: ConfigMapFeatureFlags, KEY_LABEL: "enable-api-fields", VALUE_LABEL: "alpha", CONTROL_PLANE_LABEL: "upstream"}, float64(1))
+
+	assert.NoError(t, cl.Update(context.TODO(), flags("tekton-pipelines", "beta")))
+	c.poll(context.TODO())
+	validateCounterVec(t, c.changes, prometheus.Labels{CONFIGMAP_LABEL: ConfigMapFeatureFlags, CONTROL_PLANE_LABEL: "upstream"}, float64(1))
+	validateGaugeVec(t, c.values, prometheus.Labels{CONFIGMAP_LABEL: ConfigMapFeatureFlags, KEY_LABEL: "enable-api-fields", VALUE_LABEL: "stable", CONTROL_PLANE_LABEL: "osp"}, float64(1))
+}
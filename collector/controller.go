@@ -6,19 +6,15 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
 	pipelinev1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
-	pipelinev1client "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/typed/pipeline/v1"
+	pipelinev1beta1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	corev1 "k8s.io/api/core/v1"
-	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/selection"
-	"k8s.io/apimachinery/pkg/util/wait"
 	k8sscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
 	"net/http"
-	_ "net/http/pprof"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -38,39 +34,23 @@ type PollCollector interface {
 	ZeroCollector(ns string)
 }
 
-func NewManager(cfg *rest.Config, options ctrl.Options, pprofPort string) (ctrl.Manager, error) {
-	// we have seen in testing that this path can get invoked prior to the PipelineRun CRD getting generated,
-	// and controller-runtime does not retry on missing CRDs.
-	// so we are going to wait on the CRDs existing before moving forward.
-	apiextensionsClient := apiextensionsclient.NewForConfigOrDie(cfg)
-	pipelineClient := pipelinev1client.NewForConfigOrDie(cfg)
-	if err := wait.PollImmediate(time.Second*5, time.Minute*5, func() (done bool, err error) {
-		_, err = apiextensionsClient.ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), "pipelineruns.tekton.dev", metav1.GetOptions{})
-		if err != nil {
-			controllerLog.Error(err, "get of pipelinerun CRD failed")
-			return false, nil
-		}
-		controllerLog.Info("get of pipelinerun CRD returned successfully")
-		// in addition to the CRD check we've got in several controller-runtime based RHTAP controllers, metrics-exporter
-		// recently saw some intermittent issues even after this when setting up of watches or lists timed out as tekton
-		// was still ramping up, and controller runtime would exit out of initialization.  For example:
-		// "Failed to watch *v1.TaskRun: the server is currently unable to handle the request (get taskruns.tekton.dev)"
-		// "Failed to watch *v1.PipelineRun: the server is currently unable to handle the request (get pipelineruns.tekton.dev)"
-		// "failed to list *v1.TaskRun: the server was unable to return a response in the time allotted, but may still be processing the request (get taskruns.tekton.dev)"
-		// "Failed to watch *v1.TaskRun: failed to list *v1.TaskRun: the server was unable to return a response in the time allotted, but may still be processing the request (get taskruns.tekton.dev)"
-		//
-		// So we now try to see a list return successfully before we move on to controller-runtime initialization
-		_, err = pipelineClient.PipelineRuns("").List(context.TODO(), metav1.ListOptions{})
-		if err != nil {
-			controllerLog.Error(err, "list of pipelineruns failed")
-			return false, nil
-		}
-		controllerLog.Info("list of pipelineruns returned successfully")
-		return true, nil
-	}); err != nil {
-		controllerLog.Error(err, "waiting for pipelinerun CRD to be created")
+func NewManager(cfg *rest.Config, options ctrl.Options, opts ...Option) (ctrl.Manager, error) {
+	// the settings, like the cluster name, are needed before the collectors are set up
+	newOptions(opts...).configure()
+
+	// the manager's client is not available until the manager is started, so we use a non-caching client for this one time get
+	directClient, err := client.New(cfg, client.Options{})
+	if err != nil {
 		return nil, err
 	}
+	setClusterIdentity(discoverClusterIdentity(context.TODO(), directClient))
+
+	// we have seen in testing that this path can get invoked prior to the PipelineRun CRD getting generated,
+	// and controller-runtime does not retry on missing CRDs.
+	// so if the CRDs do not exist yet, we defer starting the collectors until they do, vs. failing.
+	checker := newTektonCRDChecker(cfg)
+	crdsReady, _ := checker.ready(context.TODO())
+	waitingForCRD := NewWaitingForCRDMetric(exporterRegisterer())
 
 	options.Scheme = runtime.NewScheme()
 	if err := k8sscheme.AddToScheme(options.Scheme); err != nil {
@@ -79,9 +59,11 @@ func NewManager(cfg *rest.Config, options ctrl.Options, pprofPort string) (ctrl.
 	if err := pipelinev1.AddToScheme(options.Scheme); err != nil {
 		return nil, err
 	}
+	if err := pipelinev1beta1.AddToScheme(options.Scheme); err != nil {
+		return nil, err
+	}
 
 	var mgr ctrl.Manager
-	var err error
 	var labelReq *labels.Requirement
 	// only get/watch/cache pods with the tekton pipeline label
 	labelReq, err = labels.NewRequirement(pipeline.PipelineLabelKey, selection.Exists, []string{})
@@ -89,105 +71,432 @@ func NewManager(cfg *rest.Config, options ctrl.Options, pprofPort string) (ctrl.
 		return nil, err
 	}
 	podSelector := labels.NewSelector().Add(*labelReq)
+	// PipelineRuns and TaskRuns are cached without selectors, and which API version we watch may not be known yet
 	selectors := cache.SelectorsByObject{
-		&pipelinev1.PipelineRun{}: {},
-		&pipelinev1.TaskRun{}:     {},
 		&corev1.Pod{}: cache.ObjectSelector{
 			Label: podSelector,
 		},
 	}
 	cacheOptions := cache.Options{SelectorsByObject: selectors}
-	options.NewCache = cache.BuilderWithOptions(cacheOptions)
+	// only the cache's requests are counted, as those are the informers'
+	informerLists := NewInformerRequestsMetric(exporterRegisterer())
+	options.NewCache = func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		cacheCfg := rest.CopyConfig(config)
+		cacheCfg.Wrap(countInformerRequests(informerLists))
+		return cache.BuilderWithOptions(cacheOptions)(cacheCfg, opts)
+	}
 
-	mgr, err = ctrl.NewManager(cfg, options)
+	mgrCfg := rest.CopyConfig(cfg)
+	// client-go's rate limiter latency metric is process wide, so this covers any other clients as well
+	clientThrottles.enable(NewClientRateLimiterWaitMetric(exporterRegisterer()))
+	clientThrottles.install()
+	NewClientRateLimitMetric(exporterRegisterer(), mgrCfg)
+	mgr, err = ctrl.NewManager(mgrCfg, options)
 	if err != nil {
 		return nil, err
 	}
 
-	err = SetupController(mgr, pprofPort)
+	if crdsReady {
+		err = SetupController(mgr, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return mgr, nil
+	}
 
+	// the extra metrics handlers cannot be added once the manager has started
+	err = addMetricsHandlers(mgr)
+	if err != nil {
+		return nil, err
+	}
+	err = addRecovering(mgr, &deferredSetup{
+		ready:   checker.ready,
+		setup:   func() error { return setupControllers(mgr, opts...) },
+		waiting: waitingForCRD,
+	})
+	if err != nil {
+		return nil, err
+	}
 	return mgr, nil
 }
 
-type pprof struct {
-	port string
+func SetupController(mgr ctrl.Manager, opts ...Option) error {
+	err := addMetricsHandlers(mgr)
+	if err != nil {
+		return err
+	}
+	return setupControllers(mgr, opts...)
 }
 
-func (p *pprof) Start(ctx context.Context) error {
-	srv := &http.Server{Addr: ":" + p.port}
-	controllerLog.Info(fmt.Sprintf("starting ppprof on %s", p.port))
-	go func() {
-		err := srv.ListenAndServe()
-		if err != nil {
-			controllerLog.Info(fmt.Sprintf("pprof server err: %s", err.Error()))
+// MetricsExtraHandlers are the tenant and federation endpoints turned on by the settings, by path, which are served
+// next to the metrics; the manager serves them on its metrics listener, and embedders serving the metrics on a
+// listener of their own serve them there
+func MetricsExtraHandlers() map[string]http.Handler {
+	handlers := map[string]http.Handler{}
+	if settings.TenantMetricsEndpoint {
+		handlers[TenantMetricsPathPrefix] = newTenantMetricsHandler()
+	}
+	if settings.FederationEndpoint {
+		handlers[FederationPath] = newFederationHandler()
+	}
+	return handlers
+}
+
+func addMetricsHandlers(mgr ctrl.Manager) error {
+	for path, handler := range MetricsExtraHandlers() {
+		if err := mgr.AddMetricsExtraHandler(path, handler); err != nil {
+			return err
 		}
-	}()
-	<-ctx.Done()
-	controllerLog.Info("Shutting down pprof")
-	srv.Shutdown(ctx)
+	}
 	return nil
 }
 
-func SetupController(mgr ctrl.Manager, pprofPort string) error {
+func setupControllers(mgr ctrl.Manager, opts ...Option) error {
+	_, err := NewCollector(mgr, opts...)
+	return err
+}
+
+// Collector is the filter and reconciler behind the metrics, for callers of NewCollector that feed them events
+// from their own controllers
+type Collector struct {
+	// Predicate records the event only metrics on Update events, and returns true when the Reconciler needs to be called
+	Predicate    predicate.Predicate
+	Reconciler   *ExporterReconcile
+	registerer   *collectorRegisterer
+	nsLifecycle  *NamespaceLifecycleCollector
+	customRuns   *CustomRunCollector
+	tektonConfig *TektonConfigCollector
+	pullSecrets  *PullSecretCollector
+}
+
+// Close unregisters all the metrics of the collector, so that another one can be created with the same registerer;
+// it does not remove anything added to the manager
+func (c *Collector) Close() {
+	eventSkips.enable(nil)
+	filterDecisions.enable(nil)
+	recoveredPanics.enable(nil)
+	logLimits.enable(nil)
+	audits.enable(nil)
+	storeRetention.enable(nil, nil)
+	smoothedObservations.enable(nil)
+	collectorHealth.enable(nil)
+	apiWrites.enable(nil, 0)
+	c.registerer.Close()
+	c.Reconciler.Close()
+	if c.nsLifecycle != nil {
+		c.nsLifecycle.Close()
+	}
+	if c.customRuns != nil {
+		c.customRuns.Close()
+	}
+	if c.tektonConfig != nil {
+		c.tektonConfig.Close()
+	}
+	if c.pullSecrets != nil {
+		c.pullSecrets.Close()
+	}
+	metricSnapshots.configure("")
+}
+
+// NewCollector sets up the collectors on a manager, such that other components can embed these metrics vs. running
+// the exporter; like the exporter, it needs the Tekton CRDs to be established, and the PipelineRun, TaskRun, and
+// Pod types in the manager's scheme.  The registerer and logger are package wide, so there should only be one
+// collector per process.
+func NewCollector(mgr ctrl.Manager, opts ...Option) (*Collector, error) {
+	o := newOptions(opts...)
+	o.configure()
+	collectors := o.collectorSet()
+	// the metrics of the filters, and of the collector itself, vs. those of the reconciler
+	reg := newCollectorRegisterer(exporterRegisterer())
+	// before the collectors, which have their metrics tracked by it
+	collectorHealth.enable(NewCollectorDisabledMetric(reg))
+	// the metrics of each collector's filters are registered apart, so they are unregistered while it is disabled
+	collectorReg := func(name string) *collectorRegisterer {
+		r := newCollectorRegisterer(reg)
+		collectorHealth.track(name, r)
+		return r
+	}
+
+	// if we are watching v1beta1, this client converts to and from the v1 objects the rest of the exporter works with;
+	// its writes are held to the write budget
+	c := budgetWrites(exporterClient(mgr.GetClient()))
+	// needs to be configured before any of the metrics are created
+	tenants.configure(c)
+	if tenants.enabled {
+		if err := onNamespaceDelete(context.Background(), mgr.GetCache(), tenants.forget); err != nil {
+			return nil, err
+		}
+	}
+
 	exportFilter := &ExporterFilter{
 		noReconcile:  []predicate.Predicate{},
 		yesReconcile: []predicate.Predicate{},
 	}
 
 	// yesReconcile are metrics with non-empty Reconcile methods
-	exportFilter.yesReconcile = append(exportFilter.yesReconcile, &overheadGapEventFilter{client: mgr.GetClient()})
-	exportFilter.yesReconcile = append(exportFilter.yesReconcile, &taskRunGapEventFilter{})
+	if collectors.enabled(CollectorOverhead) {
+		exportFilter.yesReconcile = append(exportFilter.yesReconcile, o.collectorFilter(CollectorOverhead, &overheadGapEventFilter{client: c}))
+	}
+	if collectors.enabled(CollectorTaskRunGaps) {
+		exportFilter.yesReconcile = append(exportFilter.yesReconcile, o.collectorFilter(CollectorTaskRunGaps, &taskRunGapEventFilter{}))
+	}
 
 	// noReconcile are metrics with empty Reconcile methods
-	exportFilter.noReconcile = append(exportFilter.noReconcile, &pipelineRefWaitTimeFilter{waitDuration: NewPipelineReferenceWaitTimeMetric()})
-	exportFilter.noReconcile = append(exportFilter.noReconcile, &startTimeEventFilter{metric: NewPipelineRunScheduledMetric()})
-	exportFilter.noReconcile = append(exportFilter.noReconcile, NewPodCreateToCompleteFilter())
-	exportFilter.noReconcile = append(exportFilter.noReconcile, &createKubeletLatencyFilter{metric: NewPodCreateToKubeletDurationMetric()})
-	exportFilter.noReconcile = append(exportFilter.noReconcile, &kubeletContainerLatencyFilter{metric: NewPodKubeletToContainerStartDurationMetric()})
-	exportFilter.noReconcile = append(exportFilter.noReconcile, &taskRefWaitTimeFilter{waitDuration: NewTaskReferenceWaitTimeMetric()})
-	exportFilter.noReconcile = append(exportFilter.noReconcile, &trStartTimeEventFilter{metric: NewTaskRunScheduledMetric()})
-
-	r := buildReconciler(mgr.GetClient(), mgr.GetScheme(), mgr.GetEventRecorderFor("MetricsExporter"))
+	if collectors.enabled(CollectorPipelineRefWait) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPipelineRefWait, &pipelineRefWaitTimeFilter{
+			waitDuration:     NewPipelineReferenceWaitTimeMetric(collectorReg(CollectorPipelineRefWait)),
+			resolvingReasons: resolvingReasons(settings.ResolvingPipelineRefReasons, ReasonResolvingPipelineRef),
+		}))
+	}
+	if collectors.enabled(CollectorPipelineRunScheduled) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPipelineRunScheduled, &startTimeEventFilter{metric: NewPipelineRunScheduledMetric(collectorReg(CollectorPipelineRunScheduled))}))
+	}
+	if collectors.enabled(CollectorPipelineRunPending) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPipelineRunPending, &pipelineRunPendingFilter{metric: NewPipelineRunPendingMetric(collectorReg(CollectorPipelineRunPending))}))
+	}
+	if collectors.enabled(CollectorPodCreateToComplete) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPodCreateToComplete, NewPodCreateToCompleteFilter(collectorReg(CollectorPodCreateToComplete))))
+	}
+	if collectors.enabled(CollectorPodCreateToKubeletAck) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPodCreateToKubeletAck, &createKubeletLatencyFilter{metric: NewPodCreateToKubeletDurationMetric(collectorReg(CollectorPodCreateToKubeletAck))}))
+	}
+	if collectors.enabled(CollectorPodKubeletToContainer) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPodKubeletToContainer, &kubeletContainerLatencyFilter{metric: NewPodKubeletToContainerStartDurationMetric(collectorReg(CollectorPodKubeletToContainer))}))
+	}
+	if collectors.enabled(CollectorTaskRefWait) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorTaskRefWait, &taskRefWaitTimeFilter{
+			waitDuration:     NewTaskReferenceWaitTimeMetric(collectorReg(CollectorTaskRefWait)),
+			resolvingReasons: resolvingReasons(settings.ResolvingTaskRefReasons, pipelinev1.TaskRunReasonResolvingTaskRef),
+		}))
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorTaskRefWait, &stepActionRefWaitTimeFilter{
+			waitDuration: NewStepActionReferenceWaitTimeMetric(collectorReg(CollectorTaskRefWait)),
+		}))
+	}
+	if collectors.enabled(CollectorTaskRunScheduled) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorTaskRunScheduled, &trStartTimeEventFilter{metric: NewTaskRunScheduledMetric(collectorReg(CollectorTaskRunScheduled))}))
+	}
+	var nsLifecycle *NamespaceLifecycleCollector
+	if collectors.enabled(CollectorNamespaceLifecycle) {
+		nsLifecycle = NewNamespaceLifecycleCollector(exporterRegisterer())
+		collectorHealth.track(CollectorNamespaceLifecycle, nsLifecycle.registerer)
+		if err := onNamespaceDelete(context.Background(), mgr.GetCache(), nsLifecycle.forget); err != nil {
+			return nil, err
+		}
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorNamespaceLifecycle, &namespaceLifecycleFilter{collector: nsLifecycle}))
+	}
+	if collectors.enabled(CollectorDuplicateRuns) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorDuplicateRuns, &duplicateRunFilter{}))
+	}
+	if collectors.enabled(CollectorResourceVerification) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorResourceVerification, &resourceVerificationFilter{metric: NewResourceVerificationFailuresMetric(collectorReg(CollectorResourceVerification))}))
+	}
+	if collectors.enabled(CollectorPodSecurity) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPodSecurity, &podSecurityFilter{metric: NewPodSecurityRejectionsMetric(collectorReg(CollectorPodSecurity))}))
+	}
+	if collectors.enabled(CollectorPodPlacement) {
+		// registered up front, so the node informer syncs with the cache vs. on the first binding
+		if _, err := mgr.GetCache().GetInformer(context.Background(), nodeMetadata()); err != nil {
+			return nil, err
+		}
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPodPlacement, newPodPlacementFilter(NewPodPlacementMetric(collectorReg(CollectorPodPlacement)), mgr.GetCache())))
+	}
+	if collectors.enabled(CollectorChildPropagation) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorChildPropagation, &childStatusPropagationFilter{client: c, metric: NewChildStatusPropagationMetric(collectorReg(CollectorChildPropagation))}))
+	}
+	if collectors.enabled(CollectorPipelineRunResults) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPipelineRunResults, &pipelineRunResultsFilter{
+			results: NewPipelineRunResultsMetric(collectorReg(CollectorPipelineRunResults)),
+			size:    NewPipelineRunResultsSizeMetric(collectorReg(CollectorPipelineRunResults)),
+		}))
+	}
+	exportFilter.noReconcile = append(exportFilter.noReconcile, &observationLagFilter{metric: NewObservationLagMetric(reg)})
+
+	var r *ExporterReconcile
+	if o.ReadOnly {
+		controllerLog.Info("running in read-only mode; throttling is tracked in memory, and events and remediation are disabled")
+		r = buildReconciler(c, mgr.GetScheme(), nil)
+		r.readOnly = true
+		r.remediations = map[string]*remediationTracker{}
+	} else {
+		r = buildReconciler(c, mgr.GetScheme(), budgetEvents(mgr.GetEventRecorderFor("MetricsExporter")))
+	}
+	r.collectors = collectors
+	r.elected = mgr.Elected()
+	collectorHealth.track(CollectorOverhead, r.overheadCollector.registerer)
+	collectorHealth.track(CollectorTaskRunGaps, r.prGapCollector.registerer)
+	collectorHealth.track(CollectorPollers, r.waitPodCollector.registerer)
+	collectorHealth.track(CollectorPollers, r.waitPRKickoffCollector.registerer)
+	collectorHealth.track(CollectorObservedGenerationLag, r.generationLagCollector.registerer)
+	collectorHealth.track(CollectorPipelineRunQueue, r.queueCollector.registerer)
+	if collectors.enabled(CollectorPodScheduled) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPodScheduled, &podScheduledLatencyFilter{
+			metric: NewPodScheduledMetric(collectorReg(CollectorPodScheduled)),
+			filter: r.podCreateNamespaceFilter,
+		}))
+	}
+	if collectors.enabled(CollectorPollers) {
+		exportFilter.noReconcile = append(exportFilter.noReconcile, o.collectorFilter(CollectorPollers,
+			newPipelineRunTriggerLatencyFilter(r.waitPRKickoffCollector, r.pipelineRunKickoffNamespaceFilter)))
+	}
+	if o.GapExport != nil {
+		if err := o.GapExport.validate(); err != nil {
+			return nil, err
+		}
+		r.gapExport = newGapExporter(*o.GapExport, NewGapExportMetric(reg))
+		if err := addRecovering(mgr, r.gapExport); err != nil {
+			return nil, err
+		}
+	}
+	// the callers feeding their own controllers' events may not cache the PipelineRuns at all
+	if !o.SkipWatches {
+		r.cacheAudit = newCacheAudit(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetCache().WaitForCacheSync, NewCacheDiscrepancyMetric(reg))
+		if err := addRecovering(mgr, r.cacheAudit); err != nil {
+			return nil, err
+		}
+	}
+	// like the events and remediation, the markers are left alone in read-only mode
+	if settings.MarkerTTL > 0 && !o.ReadOnly {
+		if err := addRecovering(mgr, newMarkerJanitor(c, settings.MarkerTTL, settings.APIWriteBudget)); err != nil {
+			return nil, err
+		}
+	}
+	if o.TimingIngest {
+		if o.Admin == nil || o.Admin.Wrap == nil {
+			return nil, fmt.Errorf("the timing ingest needs the admin listener, with its callers authenticated")
+		}
+		r.timings = newTimingStore(NewExtendedDurationMetric(reg), NewIngestedTimingsMetric(reg))
+		exportFilter.noReconcile = append(exportFilter.noReconcile, &timingIngestFilter{store: r.timings})
+	}
+	if len(o.ExternalCollectors) > 0 {
+		runs := NewExternalCollectorRunsMetric(reg)
+		// the output of the external collectors is checked against the metrics registered when it can be gathered
+		registered, _ := baseRegisterer.(prometheus.Gatherer)
+		claims := newExternalNameClaims()
+		names := map[string]struct{}{}
+		for _, config := range o.ExternalCollectors {
+			if err := config.validate(); err != nil {
+				return nil, err
+			}
+			if _, dup := names[config.Name]; dup {
+				return nil, fmt.Errorf("there is more than one external collector named %s", config.Name)
+			}
+			names[config.Name] = struct{}{}
+			external := newExternalCollector(config, runs, registered, claims)
+			reg.MustRegister(external)
+			if err := addRecovering(mgr, external); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(o.AggregateStorePath) > 0 {
+		store, err := openAggregateStore(o.AggregateStorePath, o.AggregateRetention)
+		if err != nil {
+			return nil, err
+		}
+		r.aggregates = store
+		if err = addRecovering(mgr, store); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.MetricSnapshotPath) > 0 {
+		if err := addRecovering(mgr, metricSnapshots); err != nil {
+			return nil, err
+		}
+	}
+	duplicateRuns.recorder = r.eventRecorder
+	eventSkips.enable(NewSkippedEventsMetric(reg))
+	filterDecisions.enable(NewFilterDecisionsMetric(reg))
+	recoveredPanics.enable(NewRecoveredPanicsMetric(reg))
+	logLimits.enable(NewDroppedLogLinesMetric(reg))
+	audits.enable(NewAuditRecordsMetric(reg))
+	storeRetention.enable(NewStoreEvictionsMetric(reg), NewStoreEntriesMetric(reg))
+	apiWrites.enable(NewAPIWritesMetric(reg), settings.APIWriteBudget)
+	if settings.ObservationSmoothingWindow > 0 {
+		smoothedObservations.enable(NewObservationBacklogMetric(reg))
+		if err := addRecovering(mgr, smoothedObservations); err != nil {
+			return nil, err
+		}
+	}
 
-	err := ctrl.NewControllerManagedBy(mgr).For(&pipelinev1.PipelineRun{}).
-		WithOptions(controller.Options{MaxConcurrentReconciles: 32}).
-		WithEventFilter(exportFilter).
-		Complete(r)
+	var filter predicate.Predicate = exportFilter
+	if watchV1Beta1() {
+		filter = &v1beta1ConvertingFilter{inner: exportFilter}
+	}
+	filter = &recoveringFilter{inner: filter}
+	collector := &Collector{Predicate: filter, Reconciler: r, registerer: reg, nsLifecycle: nsLifecycle}
 
+	if collectors.enabled(CollectorPollers) {
+		err := addRecovering(mgr, r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if nsLifecycle != nil {
+		err := addRecovering(mgr, nsLifecycle)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if collectors.enabled(CollectorTektonConfig) {
+		collector.tektonConfig = NewTektonConfigCollector(exporterRegisterer(), mgr.GetAPIReader())
+		err := addRecovering(mgr, collector.tektonConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if collectors.enabled(CollectorPullSecrets) && settings.PullSecretAging {
+		collector.pullSecrets = NewPullSecretCollector(exporterRegisterer(), mgr.GetAPIReader())
+		err := addRecovering(mgr, collector.pullSecrets)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := addRecovering(mgr, &storePruner{client: r.client, childWait: r.childWait, timings: r.timings, nsLifecycle: nsLifecycle})
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	err = mgr.Add(r)
+	NewBuildInfoMetric(reg, collectors, r)
+	hb := &heartbeat{gauge: NewHeartbeatMetric(reg), informers: mgr.GetCache(), features: heartbeatFeatures(r)}
+	err = addRecovering(mgr, hb)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if len(pprofPort) > 0 {
-		pp := &pprof{port: pprofPort}
-		err = mgr.Add(pp)
+	for _, l := range o.listeners(r) {
+		err = addRecovering(mgr, l)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
-
-	err = ctrl.NewControllerManagedBy(mgr).For(&pipelinev1.TaskRun{}).
-		WithOptions(controller.Options{MaxConcurrentReconciles: 32}).
-		WithEventFilter(exportFilter).
-		Complete(r)
-
-	if err != nil {
-		return err
+	if o.SkipWatches {
+		return collector, nil
 	}
 
-	err = ctrl.NewControllerManagedBy(mgr).For(&corev1.Pod{}).
-		WithOptions(controller.Options{MaxConcurrentReconciles: 32}).
-		WithEventFilter(exportFilter).
-		Complete(r)
-
-	if err != nil {
-		return err
+	for _, obj := range []client.Object{watchedPipelineRun(), watchedTaskRun(), &corev1.Pod{}} {
+		err = ctrl.NewControllerManagedBy(mgr).For(obj).
+			WithOptions(controller.Options{MaxConcurrentReconciles: 32}).
+			WithEventFilter(filter).
+			Complete(r)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return nil
+	if collectors.enabled(CollectorCustomRuns) && customRunsServed(context.TODO(), mgr.GetAPIReader()) {
+		collector.customRuns = NewCustomRunCollector(exporterRegisterer(), mgr.GetClient())
+		err = addRecovering(mgr, collector.customRuns)
+		if err != nil {
+			return nil, err
+		}
+		// the durations are observed by the filter, so there is nothing to reconcile
+		err = ctrl.NewControllerManagedBy(mgr).For(&pipelinev1beta1.CustomRun{}).
+			WithEventFilter(&recoveringFilter{inner: &customRunDurationFilter{collector: collector.customRuns}}).
+			Complete(reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+				return reconcile.Result{}, nil
+			}))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return collector, nil
 }
 
 type ExporterFilter struct {
@@ -196,16 +505,31 @@ type ExporterFilter struct {
 	pvcFilter    predicate.Predicate
 }
 
-func (f *ExporterFilter) Generic(event.GenericEvent) bool {
-	return false
+// Generic, Create, and Delete pass the event on to every predicate like Update does, so the filter extensions and the
+// filter decision counts see them too; the built-in predicates all return false for them
+func (f *ExporterFilter) Generic(e event.GenericEvent) bool {
+	return f.each(e.Object, func(p predicate.Predicate) bool { return p.Generic(e) })
 }
 
-func (f *ExporterFilter) Create(event.CreateEvent) bool {
-	return false
+func (f *ExporterFilter) Create(e event.CreateEvent) bool {
+	return f.each(e.Object, func(p predicate.Predicate) bool { return p.Create(e) })
 }
 
-func (f *ExporterFilter) Delete(event.DeleteEvent) bool {
-	return false
+func (f *ExporterFilter) Delete(e event.DeleteEvent) bool {
+	return f.each(e.Object, func(p predicate.Predicate) bool { return p.Delete(e) })
+}
+
+// each calls every predicate with the event for obj, recovering each one's panics, and returns whether any of those
+// needing Reconcile accepted it
+func (f *ExporterFilter) each(obj client.Object, call func(predicate.Predicate) bool) bool {
+	for _, p := range f.noReconcile {
+		safePredicate(p, obj, func() bool { return call(p) })
+	}
+	callReconcile := false
+	for _, p := range f.yesReconcile {
+		callReconcile = safePredicate(p, obj, func() bool { return call(p) }) || callReconcile
+	}
+	return callReconcile
 }
 
 func (f ExporterFilter) Update(e event.UpdateEvent) bool {
@@ -215,11 +539,11 @@ func (f ExporterFilter) Update(e event.UpdateEvent) bool {
 	// is helpful and informative when working on this component.
 
 	for _, p := range f.noReconcile {
-		p.Update(e)
+		safeUpdate(p, e)
 	}
 	callReconcile := false
 	for _, p := range f.yesReconcile {
-		callReconcile = p.Update(e) || callReconcile
+		callReconcile = safeUpdate(p, e) || callReconcile
 	}
 	return callReconcile
 }
@@ -239,28 +563,73 @@ type ExporterReconcile struct {
 	waitPRKickoffCollector            *WaitingOnPipelineRunKickoffCollector
 	podCreateNamespaceFilter          map[string]struct{}
 	pipelineRunKickoffNamespaceFilter map[string]struct{}
+	registeredDetectors               []*registeredDetectorState
+	remediations                      map[string]*remediationTracker
+	flaggedByDetector                 map[string]map[string]struct{}
+	detectorSeverity                  map[string]string
+	stuckNSCollector                  *StuckNamespacesCollector
+	generationLagCollector            *ObservedGenerationLagCollector
+	queueCollector                    *PipelineRunQueueCollector
+	childWait                         *childTaskRunWait
+	reconcileMetrics                  *ReconcileMetricsCollector
+	recentRuns                        *recentRunBuffer
+	timings                           *timingStore
+	cacheAudit                        *cacheAudit
+	aggregates                        *aggregateStore
+	gapExport                         *gapExporter
+	// collectors are the ones selected with WithCollectors, or nil for all of them
+	collectors collectorSet
+	// elected is closed once this replica is the leader, or right away without leader election; nil is the leader too
+	elected <-chan struct{}
+	// readOnly means we do not patch or create any objects, and throttling is tracked in memory vs. with THROTTLED_LABEL
+	readOnly bool
 }
 
 func buildReconciler(client client.Client, scheme *runtime.Scheme, eventRecorder record.EventRecorder) *ExporterReconcile {
-	prTrGapCollector := NewPipelineRunTaskRunGapCollector()
+	prTrGapCollector := NewPipelineRunTaskRunGapCollector(exporterRegisterer())
 	r := &ExporterReconcile{
-		client:                   client,
-		scheme:                   scheme,
-		eventRecorder:            eventRecorder,
-		overheadCollector:        NewOverheadCollector(),
-		prGapCollector:           prTrGapCollector,
-		trGaps:                   prTrGapCollector.trGaps,
-		pvcNSCache:               map[string]struct{}{},
-		waitPodNSCache:           map[string]map[string]struct{}{},
-		waitPRKickoffCache:       map[string]map[string]struct{}{},
-		pvcCollector:             NewPVCThrottledCollector(),
-		waitPodCollector:         NewWaitingOnPodCreateAttemptCollector(),
-		waitPRKickoffCollector:   NewWaitingOnPipelineRunKickoffCollector(),
-		podCreateNamespaceFilter: podCreateNameSpaceFilter(),
+		client:                            client,
+		scheme:                            scheme,
+		eventRecorder:                     eventRecorder,
+		overheadCollector:                 NewOverheadCollector(exporterRegisterer()),
+		prGapCollector:                    prTrGapCollector,
+		trGaps:                            prTrGapCollector.trGaps,
+		pvcNSCache:                        map[string]struct{}{},
+		waitPodNSCache:                    map[string]map[string]struct{}{},
+		waitPRKickoffCache:                map[string]map[string]struct{}{},
+		pvcCollector:                      NewPVCThrottledCollector(exporterRegisterer()),
+		waitPodCollector:                  NewWaitingOnPodCreateAttemptCollector(exporterRegisterer()),
+		waitPRKickoffCollector:            NewWaitingOnPipelineRunKickoffCollector(exporterRegisterer()),
+		podCreateNamespaceFilter:          namespaceFilter(settings.PodCreateNamespaceFilter),
+		pipelineRunKickoffNamespaceFilter: namespaceFilter(settings.PipelineRunKickoffNamespaceFilter),
+		registeredDetectors:               registeredDetectorStates(),
+		remediations:                      remediationTrackers(),
+		flaggedByDetector:                 map[string]map[string]struct{}{},
+		detectorSeverity:                  detectorSeverities(),
+		stuckNSCollector:                  NewStuckNamespacesCollector(exporterRegisterer()),
+		generationLagCollector:            NewObservedGenerationLagCollector(exporterRegisterer()),
+		queueCollector:                    NewPipelineRunQueueCollector(exporterRegisterer()),
+		childWait:                         NewChildTaskRunWait(exporterRegisterer()),
+		reconcileMetrics:                  NewReconcileMetricsCollector(exporterRegisterer()),
+		recentRuns:                        newRecentRunBuffer(settings.RecentRuns),
 	}
 	return r
 }
 
+// Close unregisters the metrics of the reconciler's collectors
+func (r *ExporterReconcile) Close() {
+	r.overheadCollector.Close()
+	r.prGapCollector.Close()
+	r.pvcCollector.Close()
+	r.waitPodCollector.Close()
+	r.waitPRKickoffCollector.Close()
+	r.stuckNSCollector.Close()
+	r.generationLagCollector.Close()
+	r.queueCollector.Close()
+	r.childWait.Close()
+	r.reconcileMetrics.Close()
+}
+
 func innerReset(collector PollCollector, nsCache []string) {
 	// originally considered using pvcThrottle.Reset() but wanted to allow for history based searches from metrics
 	// console, so we are trying keeping track of namespaces; for now, not worried about history across exporter restart
@@ -273,14 +642,21 @@ func innerReset(collector PollCollector, nsCache []string) {
 func (r *ExporterReconcile) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 	// replace with golang errors.Join(errs ...error) when we go to golang 1.20
 	errorMsg := ""
-	//TODO if we start providing something other than the empty Result object, we'll need to build a list and handle non-standard results
-	result, err := r.ReconcileOverhead(ctx, request)
-	if err != nil {
-		errorMsg = fmt.Sprintf("%s\n%s", errorMsg, err.Error())
+	// only ReconcileOverhead provides something other than the empty Result object, when it waits on child TaskRuns
+	result := reconcile.Result{}
+	if r.collectors.enabled(CollectorOverhead) && !collectorHealth.isDisabled(CollectorOverhead) {
+		overheadResult, err := r.reconcileMetrics.observe(ctx, CollectorOverhead, request, safeReconcile(CollectorOverhead, r.ReconcileOverhead))
+		if err != nil {
+			errorMsg = fmt.Sprintf("%s\n%s", errorMsg, err.Error())
+		}
+		result = mergeResults(result, overheadResult)
 	}
-	result, err = r.ReconcilePipelineRunTaskRunGap(ctx, request)
-	if err != nil {
-		errorMsg = fmt.Sprintf("%s\n%s", errorMsg, err.Error())
+	if r.collectors.enabled(CollectorTaskRunGaps) && !collectorHealth.isDisabled(CollectorTaskRunGaps) {
+		gapResult, err := r.reconcileMetrics.observe(ctx, CollectorTaskRunGaps, request, safeReconcile(CollectorTaskRunGaps, r.ReconcilePipelineRunTaskRunGap))
+		if err != nil {
+			errorMsg = fmt.Sprintf("%s\n%s", errorMsg, err.Error())
+		}
+		result = mergeResults(result, gapResult)
 	}
 	if len(errorMsg) > 0 {
 		return result, fmt.Errorf("%s", errorMsg)
@@ -288,6 +664,15 @@ func (r *ExporterReconcile) Reconcile(ctx context.Context, request reconcile.Req
 	return result, nil
 }
 
+// mergeResults requeues if either Result does, with the sooner of the two delays
+func mergeResults(a, b reconcile.Result) reconcile.Result {
+	merged := reconcile.Result{Requeue: a.Requeue || b.Requeue, RequeueAfter: a.RequeueAfter}
+	if merged.RequeueAfter == 0 || (b.RequeueAfter > 0 && b.RequeueAfter < merged.RequeueAfter) {
+		merged.RequeueAfter = b.RequeueAfter
+	}
+	return merged
+}
+
 // Start - we do a long running runnable to reset the pvc metric in case we miss delete events, as controller relist does not duplicate
 // delete events like it can create/update events
 func (r *ExporterReconcile) Start(ctx context.Context) error {
@@ -296,13 +681,17 @@ func (r *ExporterReconcile) Start(ctx context.Context) error {
 	// golang cronjob schedule parser, and pulling the value; but if we end up changing it with
 	// some frequency, we'll start doing that
 	// side note: the wait interval for the polling style metrics in core tekton is 30 seconds at last check
-	eventTicker := time.NewTicker(2 * time.Minute)
+	eventTicker := exporterClock.NewTicker(2 * time.Minute)
 	for {
 		select {
-		case <-eventTicker.C:
+		case <-eventTicker.C():
 			r.resetPVCStats(ctx)
 			r.resetPodCreateAttemptedStats(ctx)
 			r.resetPipelineRunKickoffStats(ctx)
+			r.resetRegisteredDetectorStats(ctx)
+			r.rollupStuckNamespaces()
+			r.resetObservedGenerationLagStats(ctx)
+			r.resetPipelineRunQueueStats(ctx)
 		case <-ctx.Done():
 			controllerLog.Info("ReconcilePVCThrottled Runnable context is marked as done, exiting")
 			eventTicker.Stop()
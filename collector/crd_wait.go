@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	pipelinev1client "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/typed/pipeline/v1"
+	pipelinev1beta1client "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/typed/pipeline/v1beta1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+)
+
+var crdCheckInterval = 5 * time.Second
+
+func NewWaitingForCRDMetric(registerer prometheus.Registerer) prometheus.Gauge {
+	waiting := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "collector_waiting_for_crd",
+		Help: "Set to 1 while the Tekton CRDs are not yet established, in which case none of the PipelineRun, TaskRun, or Pod collectors have started",
+	})
+	registerer.MustRegister(waiting)
+	return waiting
+}
+
+type tektonCRDChecker struct {
+	apiextensionsClient   apiextensionsclient.Interface
+	pipelineClient        pipelinev1client.TektonV1Interface
+	pipelineV1Beta1Client pipelinev1beta1client.TektonV1beta1Interface
+}
+
+func newTektonCRDChecker(cfg *rest.Config) *tektonCRDChecker {
+	return &tektonCRDChecker{
+		apiextensionsClient:   apiextensionsclient.NewForConfigOrDie(cfg),
+		pipelineClient:        pipelinev1client.NewForConfigOrDie(cfg),
+		pipelineV1Beta1Client: pipelinev1beta1client.NewForConfigOrDie(cfg),
+	}
+}
+
+// ready returns true once the PipelineRun CRD exists and PipelineRuns can be listed, after recording which Tekton API
+// versions are served; like a wait.ConditionFunc, failures are logged and reported as not ready vs. returned
+func (c *tektonCRDChecker) ready(ctx context.Context) (bool, error) {
+	crd, err := c.apiextensionsClient.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, "pipelineruns.tekton.dev", metav1.GetOptions{})
+	if err != nil {
+		controllerLog.Error(err, "get of pipelinerun CRD failed")
+		return false, nil
+	}
+	controllerLog.Info("get of pipelinerun CRD returned successfully")
+	// in addition to the CRD check we've got in several controller-runtime based RHTAP controllers, metrics-exporter
+	// recently saw some intermittent issues even after this when setting up of watches or lists timed out as tekton
+	// was still ramping up, and controller runtime would exit out of initialization.  For example:
+	// "Failed to watch *v1.TaskRun: the server is currently unable to handle the request (get taskruns.tekton.dev)"
+	// "Failed to watch *v1.PipelineRun: the server is currently unable to handle the request (get pipelineruns.tekton.dev)"
+	// "failed to list *v1.TaskRun: the server was unable to return a response in the time allotted, but may still be processing the request (get taskruns.tekton.dev)"
+	// "Failed to watch *v1.TaskRun: failed to list *v1.TaskRun: the server was unable to return a response in the time allotted, but may still be processing the request (get taskruns.tekton.dev)"
+	//
+	// So we now try to see a list return successfully before we move on to controller-runtime initialization
+	// older OpenShift Pipelines releases do not serve v1 yet, in which case we fall back to v1beta1
+	served := servedVersions(crd)
+	if useV1Beta1(served) {
+		_, err = c.pipelineV1Beta1Client.PipelineRuns("").List(ctx, metav1.ListOptions{})
+	} else {
+		_, err = c.pipelineClient.PipelineRuns("").List(ctx, metav1.ListOptions{})
+	}
+	if err != nil {
+		controllerLog.Error(err, "list of pipelineruns failed")
+		return false, nil
+	}
+	controllerLog.Info("list of pipelineruns returned successfully")
+	tektonAPI.set(served)
+	if watchV1Beta1() {
+		controllerLog.Info("the v1 Tekton API is not served, watching v1beta1 PipelineRuns and TaskRuns instead")
+	}
+	return true, nil
+}
+
+// deferredSetup is a Runnable that waits, for as long as the manager runs, on the Tekton CRDs to be established,
+// say while the OpenShift Pipelines operator is still rolling out, and then starts the collectors; controller-runtime
+// starts controllers added to an already started manager, and their informers are created lazily
+type deferredSetup struct {
+	ready   wait.ConditionWithContextFunc
+	setup   func() error
+	waiting prometheus.Gauge
+}
+
+func (d *deferredSetup) Start(ctx context.Context) error {
+	d.waiting.Set(1)
+	controllerLog.Info("the Tekton CRDs are not established yet, deferring the start of the collectors")
+	err := wait.PollImmediateUntilWithContext(ctx, crdCheckInterval, d.ready)
+	if err != nil {
+		// only happens when the context is done
+		controllerLog.Info("deferred setup Runnable context is marked as done, exiting")
+		return nil
+	}
+	err = d.setup()
+	if err != nil {
+		return err
+	}
+	d.waiting.Set(0)
+	controllerLog.Info("the Tekton CRDs are established, the collectors have started")
+	return nil
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	pipelinefake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestTektonCRDChecker(t *testing.T) {
+	defer tektonAPI.set([]string{})
+	ctx := context.TODO()
+	apiextensionsClient := apiextensionsfake.NewSimpleClientset()
+	pipelineClient := pipelinefake.NewSimpleClientset()
+	checker := &tektonCRDChecker{
+		apiextensionsClient:   apiextensionsClient,
+		pipelineClient:        pipelineClient.TektonV1(),
+		pipelineV1Beta1Client: pipelineClient.TektonV1beta1(),
+	}
+
+	ready, err := checker.ready(ctx)
+	assert.NoError(t, err)
+	assert.False(t, ready)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "pipelineruns.tekton.dev"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{Name: "v1beta1", Served: true}},
+		},
+	}
+	_, err = apiextensionsClient.ApiextensionsV1().CustomResourceDefinitions().Create(ctx, crd, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	ready, err = checker.ready(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ready)
+	assert.Equal(t, []string{"v1beta1"}, tektonAPI.served())
+	assert.True(t, watchV1Beta1())
+}
+
+func TestDeferredSetup(t *testing.T) {
+	waiting := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_waiting_for_crd", Help: "test"})
+	checks := 0
+	setupCalled := false
+	d := &deferredSetup{
+		ready: func(context.Context) (bool, error) {
+			checks++
+			metric := &dto.Metric{}
+			assert.NoError(t, waiting.Write(metric))
+			assert.Equal(t, float64(1), metric.Gauge.GetValue())
+			return checks > 1, nil
+		},
+		setup: func() error {
+			setupCalled = true
+			return nil
+		},
+		waiting: waiting,
+	}
+	origInterval := crdCheckInterval
+	crdCheckInterval = 10 * time.Millisecond
+	defer func() { crdCheckInterval = origInterval }()
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	assert.NoError(t, d.Start(ctx))
+	assert.True(t, setupCalled)
+	metric := &dto.Metric{}
+	assert.NoError(t, waiting.Write(metric))
+	assert.Equal(t, float64(0), metric.Gauge.GetValue())
+
+	// if the manager stops before the CRDs show up, we just exit
+	setupCalled = false
+	d.ready = func(context.Context) (bool, error) { return false, nil }
+	ctx, cancel = context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, d.Start(ctx))
+	assert.False(t, setupCalled)
+}
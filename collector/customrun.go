@@ -0,0 +1,162 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	// CustomRunStuckAfterEnvName is a duration, like 1h, after which a CustomRun that is not done counts as stuck,
+	// DefaultCustomRunStuckAfter when not set
+	CustomRunStuckAfterEnvName = "CUSTOMRUN_STUCK_AFTER"
+	DefaultCustomRunStuckAfter = time.Hour
+
+	// CUSTOM_TASK_KIND_LABEL is the kind of the custom task a CustomRun runs, like Approval
+	CUSTOM_TASK_KIND_LABEL = "kind"
+)
+
+func customRunStuckAfter() time.Duration {
+	if settings.CustomRunStuckAfter <= 0 {
+		return DefaultCustomRunStuckAfter
+	}
+	return settings.CustomRunStuckAfter
+}
+
+// CustomRunCollector covers the CustomRuns of custom tasks, like approvals, which the PipelineRun and TaskRun
+// collectors do not see: how long they take from creation to done, and how many have not been done for longer
+// than CUSTOMRUN_STUCK_AFTER
+type CustomRunCollector struct {
+	registerer *collectorRegisterer
+	client     client.Client
+	duration   *prometheus.HistogramVec
+	stuck      *prometheus.GaugeVec
+}
+
+func NewCustomRunCollector(registerer prometheus.Registerer, c client.Client) *CustomRunCollector {
+	reg := newCollectorRegisterer(registerer)
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipeline_service_customrun_duration_seconds",
+		Help:    "Duration in seconds of CustomRuns from their creation to being done",
+		Buckets: []float64{1, 5, 30, 60, 300, 900, 3600, 4 * 3600, 24 * 3600},
+	}, withTenantLabelName([]string{NS_LABEL, CUSTOM_TASK_KIND_LABEL, STATUS_LABEL}))
+	stuck := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_stuck_customruns",
+		Help: "Number of CustomRuns not done for longer than the stuck threshold as of the most recent poll",
+	}, withTenantLabelName([]string{NS_LABEL, CUSTOM_TASK_KIND_LABEL}))
+	reg.MustRegister(duration, stuck)
+	return &CustomRunCollector{registerer: reg, client: c, duration: duration, stuck: stuck}
+}
+
+// Close unregisters the metrics of the collector
+func (c *CustomRunCollector) Close() {
+	c.registerer.Close()
+}
+
+// customRunsServed is false when the CustomRun CRD is not installed, as with Tekton before 0.43, or the exporter is
+// not allowed to list CustomRuns, in which case the collector is left off vs. its watch keeping the manager from
+// starting
+func customRunsServed(ctx context.Context, reader client.Reader) bool {
+	err := reader.List(ctx, &v1beta1.CustomRunList{}, client.Limit(1))
+	if err == nil {
+		return true
+	}
+	if meta.IsNoMatchError(err) {
+		controllerLog.Info("the CustomRun CRD is not installed, leaving the CustomRun collector off")
+		return false
+	}
+	controllerLog.Info(fmt.Sprintf("could not list CustomRuns, leaving the CustomRun collector off: %s", err.Error()))
+	return false
+}
+
+func customTaskKind(cr *v1beta1.CustomRun) string {
+	switch {
+	case cr.Spec.CustomRef != nil:
+		return string(cr.Spec.CustomRef.Kind)
+	case cr.Spec.CustomSpec != nil:
+		return cr.Spec.CustomSpec.Kind
+	}
+	return ""
+}
+
+func (c *CustomRunCollector) observe(cr *v1beta1.CustomRun) {
+	condition := cr.Status.GetCondition(apis.ConditionSucceeded)
+	if condition == nil {
+		return
+	}
+	done := condition.LastTransitionTime.Inner.Time
+	if cr.Status.CompletionTime != nil {
+		done = cr.Status.CompletionTime.Time
+	}
+	if done.Before(cr.CreationTimestamp.Time) {
+		return
+	}
+	labels := withTenantLabel(map[string]string{NS_LABEL: cr.Namespace, CUSTOM_TASK_KIND_LABEL: customTaskKind(cr), STATUS_LABEL: runStatus(condition)}, cr.Namespace)
+	c.duration.With(labels).Observe(done.Sub(cr.CreationTimestamp.Time).Seconds())
+}
+
+// rollupStuck recounts the CustomRuns not done for longer than the threshold
+func (c *CustomRunCollector) rollupStuck(ctx context.Context, now time.Time) error {
+	crs := &v1beta1.CustomRunList{}
+	if err := c.client.List(ctx, crs); err != nil {
+		return err
+	}
+	after := customRunStuckAfter()
+	c.stuck.Reset()
+	for i := range crs.Items {
+		cr := &crs.Items[i]
+		if cr.IsDone() || now.Sub(cr.CreationTimestamp.Time) <= after {
+			continue
+		}
+		c.stuck.With(withTenantLabel(map[string]string{NS_LABEL: cr.Namespace, CUSTOM_TASK_KIND_LABEL: customTaskKind(cr)}, cr.Namespace)).Inc()
+	}
+	return nil
+}
+
+func (c *CustomRunCollector) Start(ctx context.Context) error {
+	eventTicker := exporterClock.NewTicker(2 * time.Minute)
+	defer eventTicker.Stop()
+	for {
+		select {
+		case <-eventTicker.C():
+			if err := c.rollupStuck(ctx, exporterClock.Now()); err != nil {
+				controllerLog.Info(fmt.Sprintf("could not list the CustomRuns for the stuck count: %s", err.Error()))
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// customRunDurationFilter observes the duration of CustomRuns as they become done; nothing is reconciled
+type customRunDurationFilter struct {
+	collector *CustomRunCollector
+}
+
+func (f *customRunDurationFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *customRunDurationFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *customRunDurationFilter) Update(e event.UpdateEvent) bool {
+	oldCR, okold := e.ObjectOld.(*v1beta1.CustomRun)
+	newCR, oknew := e.ObjectNew.(*v1beta1.CustomRun)
+	if okold && oknew && !oldCR.IsDone() && newCR.IsDone() {
+		f.collector.observe(newCR)
+	}
+	return false
+}
+
+func (f *customRunDurationFilter) Generic(event.GenericEvent) bool {
+	return false
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func customRun(name string, created time.Time, status corev1.ConditionStatus) *v1beta1.CustomRun {
+	cr := &v1beta1.CustomRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: name, CreationTimestamp: metav1.NewTime(created)},
+		Spec:       v1beta1.CustomRunSpec{CustomRef: &v1beta1.TaskRef{APIVersion: "example.dev/v1", Kind: "Approval"}},
+	}
+	if len(status) > 0 {
+		cr.Status.Conditions = duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: status,
+			LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(created.Add(90 * time.Second))}}}
+	}
+	return cr
+}
+
+func TestCustomRunDuration(t *testing.T) {
+	c := NewCustomRunCollector(prometheus.NewRegistry(), nil)
+	defer c.Close()
+	filter := &customRunDurationFilter{collector: c}
+	created := time.Now().Add(-time.Hour)
+	running := customRun("test-1", created, corev1.ConditionUnknown)
+	done := customRun("test-1", created, corev1.ConditionTrue)
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: done}))
+	// only the transition to done is observed
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: done, ObjectNew: done}))
+	failed := customRun("test-2", created, corev1.ConditionFalse)
+	failed.Spec.CustomRef = nil
+	failed.Spec.CustomSpec = &v1beta1.EmbeddedCustomRunSpec{TypeMeta: runtime.TypeMeta{Kind: "Wait"}}
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: customRun("test-2", created, ""), ObjectNew: failed}))
+
+	observer, err := c.duration.GetMetricWith(prometheus.Labels{NS_LABEL: "test-namespace", CUSTOM_TASK_KIND_LABEL: "Approval", STATUS_LABEL: SUCCEEDED})
+	assert.NoError(t, err)
+	metric := &dto.Metric{}
+	assert.NoError(t, observer.(prometheus.Histogram).Write(metric))
+	assert.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(90), metric.GetHistogram().GetSampleSum())
+	validateHistogramVec(t, c.duration, prometheus.Labels{NS_LABEL: "test-namespace", CUSTOM_TASK_KIND_LABEL: "Wait", STATUS_LABEL: FAILED}, false)
+}
+
+func TestCustomRunStuck(t *testing.T) {
+	defer setSettings(Settings{CustomRunStuckAfter: 30 * time.Minute})()
+	scheme := runtime.NewScheme()
+	_ = v1beta1.AddToScheme(scheme)
+	now := time.Now().Truncate(time.Second)
+	objs := []*v1beta1.CustomRun{
+		customRun("stuck-1", now.Add(-time.Hour), corev1.ConditionUnknown),
+		customRun("stuck-2", now.Add(-time.Hour), ""),
+		customRun("recent", now.Add(-time.Minute), corev1.ConditionUnknown),
+		customRun("done", now.Add(-time.Hour), corev1.ConditionTrue),
+	}
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, obj := range objs {
+		builder = builder.WithObjects(obj)
+	}
+	cl := builder.Build()
+	assert.True(t, customRunsServed(context.TODO(), cl))
+	c := NewCustomRunCollector(prometheus.NewRegistry(), cl)
+	defer c.Close()
+	assert.NoError(t, c.rollupStuck(context.TODO(), now))
+	validateGaugeVec(t, c.stuck, prometheus.Labels{NS_LABEL: "test-namespace", CUSTOM_TASK_KIND_LABEL: "Approval"}, float64(2))
+
+	// the CustomRun type is not known, like without the CRD
+	assert.False(t, customRunsServed(context.TODO(), fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()))
+}
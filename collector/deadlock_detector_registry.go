@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	PodCreateAttemptDetectorName   = "pod-create-attempt"
+	PipelineRunKickoffDetectorName = "pipelinerun-kickoff"
+)
+
+// DeadlockDetector allows downstream distributions to plug their own stuck-state checks into the same poll loop
+// that drives the pod create attempt and pipelinerun kickoff gauges.  List is called once per poll interval, and
+// Deadlocked is then called for each object returned.  As with the built-in detectors, an object has to be flagged
+// in two consecutive scans before the PollCollector for its namespace is bumped.
+//
+// NOTE: List is handed the manager's client, so any types it retrieves need to be either in the manager's scheme
+// or retrieved as unstructured objects.
+type DeadlockDetector interface {
+	List(ctx context.Context, c client.Client) ([]client.Object, error)
+	Deadlocked(obj client.Object) bool
+}
+
+type detectorRegistration struct {
+	name      string
+	detector  DeadlockDetector
+	collector PollCollector
+}
+
+var (
+	detectorRegistryLock sync.Mutex
+	detectorRegistry     = []*detectorRegistration{}
+)
+
+// RegisterDetector adds a DeadlockDetector to the poll loop of any reconciler built after the call, so it should
+// be called before NewManager.  Like http.Handle, it panics if the name is empty, already in use, or collides with one
+// of the built-in detectors.
+func RegisterDetector(name string, d DeadlockDetector, pc PollCollector) {
+	detectorRegistryLock.Lock()
+	defer detectorRegistryLock.Unlock()
+	if len(name) == 0 {
+		panic("deadlock detector registered with an empty name")
+	}
+	if d == nil || pc == nil {
+		panic(fmt.Sprintf("deadlock detector %s registered with a nil detector or collector", name))
+	}
+	if builtinDetector(name) {
+		panic(fmt.Sprintf("deadlock detector name %s is reserved for a built-in detector", name))
+	}
+	for _, reg := range detectorRegistry {
+		if reg.name == name {
+			panic(fmt.Sprintf("deadlock detector %s already registered", name))
+		}
+	}
+	detectorRegistry = append(detectorRegistry, &detectorRegistration{name: name, detector: d, collector: pc})
+}
+
+func builtinDetector(name string) bool {
+	return name == PodCreateAttemptDetectorName || name == PipelineRunKickoffDetectorName || name == PVCQuotaDetectorName
+}
+
+// knownDetector is whether the name is a built-in or registered detector
+func knownDetector(name string) bool {
+	if builtinDetector(name) {
+		return true
+	}
+	detectorRegistryLock.Lock()
+	defer detectorRegistryLock.Unlock()
+	for _, reg := range detectorRegistry {
+		if reg.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type registeredDetectorState struct {
+	*detectorRegistration
+	cache map[string]map[string]struct{}
+}
+
+func registeredDetectorStates() []*registeredDetectorState {
+	detectorRegistryLock.Lock()
+	defer detectorRegistryLock.Unlock()
+	states := []*registeredDetectorState{}
+	for _, reg := range detectorRegistry {
+		states = append(states, &registeredDetectorState{detectorRegistration: reg, cache: map[string]map[string]struct{}{}})
+	}
+	return states
+}
+
+func (r *ExporterReconcile) resetRegisteredDetectorStats(ctx context.Context) {
+	for _, ds := range r.registeredDetectors {
+		cacheCopy := buildLastScanCopy(ds.collector, ds.cache)
+		ds.cache = map[string]map[string]struct{}{}
+
+		objs, err := ds.detector.List(ctx, r.client)
+		deadlockTracker := &DeadlockTracker{
+			collector:         ds.collector,
+			filter:            map[string]struct{}{},
+			flaggedNamespaces: map[string]struct{}{},
+			lastScan:          cacheCopy,
+			currentScan:       ds.cache,
+		}
+		remediation := r.remediations[ds.name]
+		if err == nil {
+			for _, obj := range objs {
+				o := obj
+				deadlockTracker.deadlocked = func() bool {
+					return ds.detector.Deadlocked(o)
+				}
+				deadlockTracker.flagged = func() {
+					remediation.remediate(ctx, r.client, o)
+				}
+				deadlockTracker.PerformDeadlockDetection(o.GetName(), o.GetNamespace())
+			}
+		} else {
+			controllerLog.Error(err, fmt.Sprintf("query for deadlock detector %s failed with an error", ds.name))
+		}
+		remediation.finishScan()
+		r.flaggedByDetector[ds.name] = deadlockTracker.flaggedNamespaces
+
+		zeroOutPriorHitNamespacesThatAreNowEmpty(ds.collector, cacheCopy, ds.cache)
+	}
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+type mockPollCollector struct {
+	counts map[string]float64
+}
+
+func (c *mockPollCollector) IncCollector(ns string) {
+	c.counts[ns] = c.counts[ns] + 1
+}
+
+func (c *mockPollCollector) ZeroCollector(ns string) {
+	c.counts[ns] = 0
+}
+
+// flags any pipelinerun with the "stuck" label
+type mockDetector struct{}
+
+func (d *mockDetector) List(ctx context.Context, c client.Client) ([]client.Object, error) {
+	prList := &v1.PipelineRunList{}
+	err := c.List(ctx, prList)
+	objs := []client.Object{}
+	for i := range prList.Items {
+		objs = append(objs, &prList.Items[i])
+	}
+	return objs, err
+}
+
+func (d *mockDetector) Deadlocked(obj client.Object) bool {
+	_, stuck := obj.GetLabels()["stuck"]
+	return stuck
+}
+
+func TestRegisterDetector(t *testing.T) {
+	defer func() { detectorRegistry = []*detectorRegistration{} }()
+	pc := &mockPollCollector{counts: map[string]float64{}}
+	RegisterDetector("mock", &mockDetector{}, pc)
+	assert.Panics(t, func() { RegisterDetector("mock", &mockDetector{}, pc) })
+	assert.Panics(t, func() { RegisterDetector(PodCreateAttemptDetectorName, &mockDetector{}, pc) })
+	assert.Panics(t, func() { RegisterDetector("", &mockDetector{}, pc) })
+	assert.Panics(t, func() { RegisterDetector("nil-collector", &mockDetector{}, nil) })
+
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	mockPipelineRuns := []*v1.PipelineRun{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1", Labels: map[string]string{"stuck": "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-2", Labels: map[string]string{"stuck": "true"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-3"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace-2", Name: "test-1"}},
+	}
+	for _, pr := range mockPipelineRuns {
+		assert.NoError(t, c.Create(ctx, pr))
+	}
+
+	reconciler := buildReconciler(c, nil, nil)
+	assert.Len(t, reconciler.registeredDetectors, 1)
+	// first scan only seeds the cache
+	reconciler.resetRegisteredDetectorStats(ctx)
+	assert.Equal(t, float64(0), pc.counts["test-namespace"])
+	// second scan bumps the repeats
+	reconciler.resetRegisteredDetectorStats(ctx)
+	assert.Equal(t, float64(2), pc.counts["test-namespace"])
+	assert.Equal(t, float64(0), pc.counts["test-namespace-2"])
+	// deletion, then another pass, should now be one
+	assert.NoError(t, c.Delete(ctx, mockPipelineRuns[0]))
+	reconciler.resetRegisteredDetectorStats(ctx)
+	assert.Equal(t, float64(1), pc.counts["test-namespace"])
+	reconciler.Close()
+}
@@ -0,0 +1,293 @@
+package collector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	PEER_CLUSTER_LABEL        = "peer_cluster"
+	DuplicatePipelineRun      = "DuplicatePipelineRun"
+	localClusterName          = "local"
+	duplicateRunPruneEvery    = 10 * time.Minute
+	duplicateRunUIDKeyPrefix  = "uid/"
+	duplicateRunSpecKeyPrefix = "spec/"
+)
+
+// duplicateRunSighting is what is kept of a PipelineRun seen in a cluster, vs. the whole object, as the sightings of
+// every PipelineRun in every member cluster are held
+type duplicateRunSighting struct {
+	namespace string
+	name      string
+	uid       types.UID
+	keys      []string
+	lastSeen  time.Time
+}
+
+// duplicateRunTracker remembers which clusters a PipelineRun has been observed in, keyed by both its UID and a hash of
+// its namespace/name and spec, so that a PipelineRun copied between member clusters during a tenant migration is flagged once,
+// allowing SLI queries to discount it; it is only enabled in multi-cluster mode, meaning peer clusters were added
+type duplicateRunTracker struct {
+	lock       sync.Mutex
+	enabled    bool
+	local      string
+	sightings  map[string]map[string]*duplicateRunSighting
+	flagged    map[string]struct{}
+	recorder   record.EventRecorder
+	duplicates *prometheus.CounterVec
+}
+
+var duplicateRuns = &duplicateRunTracker{
+	sightings: map[string]map[string]*duplicateRunSighting{},
+	flagged:   map[string]struct{}{},
+}
+
+func NewDuplicateRunsMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	labelNames := []string{NS_LABEL, PEER_CLUSTER_LABEL}
+	duplicates := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_duplicate_pipelineruns_total",
+		Help: "Number of PipelineRuns observed both in this cluster and in the given peer cluster, by UID or namespace/name and spec",
+	}, labelNames)
+	registerer.MustRegister(duplicates)
+	return duplicates
+}
+
+func (d *duplicateRunTracker) enable(local string, duplicates *prometheus.CounterVec) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.enabled = true
+	d.local = local
+	d.duplicates = duplicates
+}
+
+func (d *duplicateRunTracker) observeLocal(pr *v1.PipelineRun, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.observeLocked(d.local, pr, now)
+}
+
+// duplicateRunSpecHash identifies a PipelineRun copied to another cluster, where it gets a new UID, by its
+// namespace/name and spec, so that unrelated PipelineRuns which happen to reuse a name are not flagged; the spec's
+// status is left out, as a copy may be cancelled on its own
+func duplicateRunSpecHash(pr *v1.PipelineRun) string {
+	spec := pr.Spec
+	spec.Status = ""
+	raw, err := json.Marshal(&spec)
+	if err != nil {
+		raw = []byte{}
+	}
+	h := sha256.New()
+	h.Write([]byte(pr.Namespace + "/" + pr.Name + "/"))
+	h.Write(raw)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func duplicateRunKeys(pr *v1.PipelineRun) []string {
+	keys := []string{duplicateRunSpecKeyPrefix + duplicateRunSpecHash(pr)}
+	if len(pr.UID) > 0 {
+		keys = append(keys, duplicateRunUIDKeyPrefix+string(pr.UID))
+	}
+	return keys
+}
+
+// observe records the sighting of the PipelineRun in the named cluster; only duplicates between the local cluster and
+// a peer are flagged, since duplicates between two peers are flagged by the exporters running on them
+func (d *duplicateRunTracker) observe(clusterName string, pr *v1.PipelineRun, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.observeLocked(clusterName, pr, now)
+}
+
+func (d *duplicateRunTracker) observeLocked(clusterName string, pr *v1.PipelineRun, now time.Time) {
+	if !d.enabled {
+		return
+	}
+	keys := duplicateRunKeys(pr)
+	sighting := &duplicateRunSighting{namespace: pr.Namespace, name: pr.Name, uid: pr.UID, keys: keys, lastSeen: now}
+	for _, key := range keys {
+		byCluster, ok := d.sightings[key]
+		if !ok {
+			byCluster = map[string]*duplicateRunSighting{}
+			d.sightings[key] = byCluster
+		}
+		byCluster[clusterName] = sighting
+		if _, alreadyFlagged := d.flagged[key]; alreadyFlagged {
+			continue
+		}
+		local, seenLocally := byCluster[d.local]
+		if !seenLocally {
+			continue
+		}
+		for peer := range byCluster {
+			if peer == d.local {
+				continue
+			}
+			d.flagged[key] = struct{}{}
+			d.flagDuplicate(local, peer)
+			// both keys usually match for the same PipelineRun, so we only flag it once
+			for _, other := range local.keys {
+				d.flagged[other] = struct{}{}
+			}
+			break
+		}
+	}
+}
+
+func (d *duplicateRunTracker) flagDuplicate(local *duplicateRunSighting, peer string) {
+	controllerLog.Info(fmt.Sprintf("PipelineRun %s:%s is also present in cluster %s", local.namespace, local.name, peer))
+	d.duplicates.With(prometheus.Labels{NS_LABEL: local.namespace, PEER_CLUSTER_LABEL: peer}).Inc()
+	if d.recorder != nil {
+		pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: local.namespace, Name: local.name, UID: local.uid}}
+		d.recorder.Eventf(pr, corev1.EventTypeWarning, DuplicatePipelineRun, "PipelineRun is also present in cluster %s", peer)
+	}
+}
+
+func (d *duplicateRunTracker) prune(now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	// each cluster's sighting expires on its own, with the key only evicted once none are left
+	ttl := storeTTL()
+	expired := 0
+	lastSeen := map[string]time.Time{}
+	for key, byCluster := range d.sightings {
+		for clusterName, sighting := range byCluster {
+			if now.Sub(sighting.lastSeen) > ttl {
+				delete(byCluster, clusterName)
+				continue
+			}
+			if sighting.lastSeen.After(lastSeen[key]) {
+				lastSeen[key] = sighting.lastSeen
+			}
+		}
+		if len(byCluster) == 0 {
+			delete(d.sightings, key)
+			delete(d.flagged, key)
+			expired++
+		}
+	}
+	storeRetention.evicted(StoreDuplicateRuns, EvictionExpired, expired)
+	for _, key := range evictKeys(StoreDuplicateRuns, lastSeen, now) {
+		delete(d.sightings, key)
+		delete(d.flagged, key)
+	}
+}
+
+func (d *duplicateRunTracker) Start(ctx context.Context) error {
+	eventTicker := exporterClock.NewTicker(duplicateRunPruneEvery)
+	for {
+		select {
+		case <-eventTicker.C():
+			d.prune(exporterClock.Now())
+		case <-ctx.Done():
+			controllerLog.Info("duplicateRunTracker Runnable context is marked as done, exiting")
+			eventTicker.Stop()
+			return nil
+		}
+	}
+}
+
+type duplicateRunFilter struct {
+}
+
+func (f *duplicateRunFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *duplicateRunFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *duplicateRunFilter) Update(e event.UpdateEvent) bool {
+	pr, ok := e.ObjectNew.(*v1.PipelineRun)
+	if ok {
+		duplicateRuns.observeLocal(pr, exporterClock.Now())
+	}
+	return false
+}
+
+func (f *duplicateRunFilter) Generic(event.GenericEvent) bool {
+	return false
+}
+
+func peerHandler(peer string) handler.EventHandler {
+	observe := func(obj interface{}) {
+		defer recoverHandler("peer-"+peer, obj)
+		pr, ok := obj.(*v1.PipelineRun)
+		if ok {
+			duplicateRuns.observe(peer, pr, exporterClock.Now())
+		}
+	}
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			observe(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			observe(e.ObjectNew)
+		},
+	}
+}
+
+// AddPeerClusters turns on multi-cluster mode, where the PipelineRuns of the other member clusters, keyed by a name
+// for each cluster, are watched, only to detect PipelineRuns present in both this cluster and a peer
+func AddPeerClusters(mgr ctrl.Manager, peers map[string]*rest.Config) error {
+	if len(peers) == 0 {
+		return nil
+	}
+	local, ok := constLabels[CLUSTER_LABEL]
+	if !ok {
+		local = localClusterName
+	}
+	duplicateRuns.enable(local, NewDuplicateRunsMetric(exporterRegisterer()))
+	err := addRecovering(mgr, duplicateRuns)
+	if err != nil {
+		return err
+	}
+	for name, cfg := range peers {
+		peer, err := cluster.New(cfg, func(o *cluster.Options) {
+			o.Scheme = mgr.GetScheme()
+		})
+		if err != nil {
+			return err
+		}
+		err = mgr.Add(peer)
+		if err != nil {
+			return err
+		}
+		// the controller only exists to drive the watch; nothing is ever queued for reconciliation
+		c, err := controller.New("peer-"+name, mgr, controller.Options{
+			Reconciler: reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+				return reconcile.Result{}, nil
+			}),
+		})
+		if err != nil {
+			return err
+		}
+		err = c.Watch(source.NewKindWithCache(&v1.PipelineRun{}, peer.GetCache()), peerHandler(name))
+		if err != nil {
+			return err
+		}
+		controllerLog.Info(fmt.Sprintf("watching PipelineRuns in peer cluster %s for duplicates", name))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"testing"
+	"time"
+)
+
+func TestDuplicateRuns(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	duplicates := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_duplicate_pipelineruns_total", Help: "test"},
+		[]string{NS_LABEL, PEER_CLUSTER_LABEL})
+	tracker := &duplicateRunTracker{
+		sightings: map[string]map[string]*duplicateRunSighting{},
+		flagged:   map[string]struct{}{},
+		recorder:  recorder,
+	}
+	origTracker := duplicateRuns
+	duplicateRuns = tracker
+	defer func() { duplicateRuns = origTracker }()
+
+	now := time.Now()
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1", UID: "uid-1"}}
+	filter := &duplicateRunFilter{}
+
+	// nothing is tracked until multi-cluster mode is enabled
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	assert.Len(t, tracker.sightings, 0)
+
+	tracker.enable("member-1", duplicates)
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	assert.Len(t, tracker.sightings, 2)
+
+	// different namespace/name and UID in the peer is not a duplicate
+	other := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-2", UID: "uid-2"}}
+	tracker.observe("member-2", other, now)
+	assert.Len(t, recorder.Events, 0)
+
+	// nor is an unrelated PipelineRun that reuses the namespace/name
+	reused := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1", UID: "uid-4"},
+		Spec:       v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "other-pipeline"}},
+	}
+	tracker.observe("member-2", reused, now)
+	assert.Len(t, recorder.Events, 0)
+
+	// the migrated copy gets a new UID but keeps its namespace/name and spec, even when cancelled on its own
+	migrated := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1", UID: "uid-3"},
+		Spec:       v1.PipelineRunSpec{Status: v1.PipelineRunSpecStatusCancelled},
+	}
+	tracker.observe("member-2", migrated, now)
+	tracker.observe("member-2", migrated, now)
+	label := prometheus.Labels{NS_LABEL: "test-namespace", PEER_CLUSTER_LABEL: "member-2"}
+	validateCounterVec(t, duplicates, label, float64(1))
+	assert.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, DuplicatePipelineRun)
+
+	// sightings that are not refreshed age out
+	tracker.prune(now.Add(DefaultStoreTTL + time.Hour))
+	assert.Len(t, tracker.sightings, 0)
+	assert.Len(t, tracker.flagged, 0)
+}
@@ -0,0 +1,191 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ExplainFiltered is the Decision reason when a metric was calculated but left out, like overhead of a PipelineRun
+// under the FilterThreshold
+const ExplainFiltered = "filtered"
+
+// Decision is whether a collector records its metric for a run, and if not, why
+type Decision struct {
+	// Collector is one of the Collector names
+	Collector string
+	// Run is the name of the PipelineRun or TaskRun
+	Run string
+	// Recorded is true if the collector records its metric for the run
+	Recorded bool
+	// Reason is why the metric is not recorded, like one of the GapSkip, GapAbort, or SkipReason constants
+	Reason string
+}
+
+// Observation is a value a collector records
+type Observation struct {
+	Metric string
+	Labels map[string]string
+	Value  float64
+}
+
+// Explanation is what the collectors make of a PipelineRun and its TaskRuns, as of when it was fetched
+type Explanation struct {
+	PipelineRun *v1.PipelineRun
+	// TaskRuns are the child TaskRuns that still exist
+	TaskRuns []*v1.TaskRun
+	Gaps     GapResult
+	// ThrottledBy is the TaskRun the PipelineRun is, or was, throttled by, from its label or the current TaskRun status
+	ThrottledBy  string
+	Decisions    []Decision
+	Observations []Observation
+}
+
+// Explain fetches a PipelineRun and its TaskRuns, and goes through the calculations of the run level collectors for
+// them, without recording anything
+func Explain(ctx context.Context, oc client.Client, namespace, name string) (*Explanation, error) {
+	pr := &v1.PipelineRun{}
+	err := oc.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pr)
+	if meta.IsNoMatchError(err) {
+		oc = &v1beta1FallbackClient{Client: oc}
+		err = oc.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e := &Explanation{PipelineRun: pr, TaskRuns: []*v1.TaskRun{}, Decisions: []Decision{}, Observations: []Observation{}}
+	for _, kidRef := range pr.Status.ChildReferences {
+		if kidRef.Kind != "TaskRun" {
+			continue
+		}
+		tr := &v1.TaskRun{}
+		err = oc.Get(ctx, types.NamespacedName{Namespace: pr.Namespace, Name: kidRef.Name}, tr)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		e.TaskRuns = append(e.TaskRuns, tr)
+	}
+
+	if trName, labelled := pr.Labels[THROTTLED_LABEL]; labelled {
+		e.ThrottledBy = trName
+	} else if throttled, trName, err := isPipelineRunThrottled(pr, oc, ctx); err == nil && throttled {
+		e.ThrottledBy = trName
+	}
+
+	e.explainPipelineRun(ctx, oc)
+	for _, tr := range e.TaskRuns {
+		e.explainTaskRun(tr)
+	}
+	return e, nil
+}
+
+func (e *Explanation) decide(collector, run, reason string) {
+	e.Decisions = append(e.Decisions, Decision{Collector: collector, Run: run, Recorded: len(reason) == 0, Reason: reason})
+}
+
+func (e *Explanation) observe(metric string, labels map[string]string, value float64) {
+	e.Observations = append(e.Observations, Observation{Metric: metric, Labels: labels, Value: value})
+}
+
+func (e *Explanation) explainPipelineRun(ctx context.Context, oc client.Client) {
+	pr := e.PipelineRun
+	succeedCondition := pr.Status.GetCondition(apis.ConditionSucceeded)
+	if succeedCondition == nil || succeedCondition.IsUnknown() {
+		e.Gaps = GapResult{Entries: []GapEntry{}, Reason: GapSkipNotFinished}
+		for _, collector := range []string{CollectorPipelineRunScheduled, CollectorOverhead, CollectorTaskRunGaps} {
+			e.decide(collector, pr.Name, GapSkipNotFinished)
+		}
+		return
+	}
+	if pr.Status.StartTime == nil {
+		e.Gaps = GapResult{Entries: []GapEntry{}, Reason: SkipReasonMissingStartTime}
+		for _, collector := range []string{CollectorPipelineRunScheduled, CollectorOverhead, CollectorTaskRunGaps} {
+			e.decide(collector, pr.Name, SkipReasonMissingStartTime)
+		}
+		return
+	}
+	labels := withRunLabels(withTenantLabel(map[string]string{NS_LABEL: pr.Namespace, STATUS_LABEL: runStatus(succeedCondition)}, pr.Namespace), pr)
+
+	e.decide(CollectorPipelineRunScheduled, pr.Name, "")
+	e.observe("pipelinerun_duration_scheduled_seconds", labels, calculateScheduledDurationPipelineRun(pr))
+
+	// like the collectors, the sampling is decided before the TaskRuns are listed
+	if _, sampled := overheadSample(pr, labels[STATUS_LABEL]); !sampled {
+		e.Gaps = GapResult{Entries: []GapEntry{}, Reason: OverheadSkipSampledOut}
+		e.decide(CollectorTaskRunGaps, pr.Name, OverheadSkipSampledOut)
+		e.decide(CollectorOverhead, pr.Name, OverheadSkipSampledOut)
+		return
+	}
+	e.Gaps = AccumulateGaps(ctx, oc, pr)
+	if !e.Gaps.Calculated() {
+		e.decide(CollectorTaskRunGaps, pr.Name, e.Gaps.Reason)
+	} else {
+		e.decide(CollectorTaskRunGaps, pr.Name, "")
+		for _, gapEntry := range e.Gaps.Entries {
+			e.observe("pipelinerun_gap_between_taskruns_milliseconds", withRunLabels(withTenantLabel(map[string]string{
+				NS_LABEL:     pr.Namespace,
+				STATUS_LABEL: gapEntry.Status,
+				PHASE_LABEL:  gapEntry.Phase,
+			}, pr.Namespace), pr), gapEntry.Gap)
+		}
+		if maxGap, ok := maxGapEntry(e.Gaps.Entries); ok {
+			e.observe("pipelinerun_gap_max_milliseconds", withRunLabels(withTenantLabel(map[string]string{
+				NS_LABEL:     pr.Namespace,
+				STATUS_LABEL: maxGap.Status,
+			}, pr.Namespace), pr), maxGap.Gap)
+		}
+	}
+
+	// like ReconcileOverhead, the scheduling overhead is still recorded when the TaskRuns were deleted, or when the
+	// first TaskRun was throttled, without the time it was throttled
+	totalDuration := float64(pr.Status.CompletionTime.Time.Sub(pr.Status.StartTime.Time).Milliseconds())
+	if e.Gaps.Reason == GapSkipThrottled {
+		throttledDuration, ok := kickoffThrottledDuration(ctx, oc, pr)
+		if !ok || totalDuration <= throttledDuration {
+			e.decide(CollectorOverhead, pr.Name, e.Gaps.Reason)
+			return
+		}
+		totalDuration = totalDuration - throttledDuration
+	} else if !e.Gaps.Calculated() && e.Gaps.Reason != GapAbortTaskRunDeleted {
+		e.decide(CollectorOverhead, pr.Name, e.Gaps.Reason)
+		return
+	}
+	overhead, hasOverhead := e.Gaps.Overhead()
+	recorded := false
+	if hasOverhead {
+		e.observe("pipeline_service_execution_overhead_percentage", labels, overhead)
+		recorded = true
+	}
+	if _, overhead, ok := schedulingOverhead(pr, totalDuration); ok {
+		e.observe("pipeline_service_schedule_overhead_percentage", labels, overhead)
+		recorded = true
+	}
+	reason := ""
+	if !recorded {
+		reason = ExplainFiltered
+	}
+	e.decide(CollectorOverhead, pr.Name, reason)
+}
+
+func (e *Explanation) explainTaskRun(tr *v1.TaskRun) {
+	if !tr.IsDone() {
+		e.decide(CollectorTaskRunScheduled, tr.Name, GapSkipNotFinished)
+		return
+	}
+	if tr.Status.StartTime == nil {
+		e.decide(CollectorTaskRunScheduled, tr.Name, SkipReasonMissingStartTime)
+		return
+	}
+	e.decide(CollectorTaskRunScheduled, tr.Name, "")
+	succeedCondition := tr.Status.GetCondition(apis.ConditionSucceeded)
+	labels := withRunLabels(withTenantLabel(map[string]string{NS_LABEL: tr.Namespace, STATUS_LABEL: runStatus(succeedCondition)}, tr.Namespace), tr)
+	e.observe("taskrun_duration_scheduled_seconds", labels, calculateScheduledDurationTaskRun(tr))
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func explainedRuns(done bool) (*v1.PipelineRun, []*v1.TaskRun) {
+	base := time.Now()
+	at := func(tens int) *metav1.Time {
+		return &metav1.Time{Time: base.Add(time.Duration(tens) * 10 * time.Second)}
+	}
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1", CreationTimestamp: *at(0)},
+		Spec: v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "build-pipeline"}}}
+	pr.Status.StartTime = at(0)
+	taskRuns := []*v1.TaskRun{}
+	for i, name := range []string{"clone", "build"} {
+		tr := &v1.TaskRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1-" + name, CreationTimestamp: *at(i*40 + 1),
+			Labels: map[string]string{"tekton.dev/pipelineTask": name, "tekton.dev/pipelineRun": "test-1"}}}
+		tr.Status.StartTime = at(i*40 + 1)
+		tr.Status.CompletionTime = at(i*40 + 30)
+		tr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+		taskRuns = append(taskRuns, tr)
+		pr.Status.ChildReferences = append(pr.Status.ChildReferences, v1.ChildStatusReference{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: tr.Name})
+	}
+	if done {
+		pr.Status.CompletionTime = at(100)
+		pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+	} else {
+		pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionUnknown})
+	}
+	return pr, taskRuns
+}
+
+func TestExplain(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	pr, taskRuns := explainedRuns(true)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr, taskRuns[0], taskRuns[1]).Build()
+	ctx := context.TODO()
+
+	e, err := Explain(ctx, c, "test-namespace", "test-1")
+	assert.NoError(t, err)
+	assert.Len(t, e.TaskRuns, 2)
+	assert.True(t, e.Gaps.Calculated())
+	assert.Len(t, e.Gaps.Entries, 2)
+	assert.Empty(t, e.ThrottledBy)
+	for _, d := range e.Decisions {
+		assert.True(t, d.Recorded, d.Collector)
+	}
+	metrics := map[string]int{}
+	for _, o := range e.Observations {
+		metrics[o.Metric]++
+		assert.Equal(t, SUCCEEDED, o.Labels[STATUS_LABEL])
+	}
+	assert.Equal(t, map[string]int{
+		"pipelinerun_duration_scheduled_seconds":         1,
+		"pipelinerun_gap_between_taskruns_milliseconds":  2,
+		"pipelinerun_gap_max_milliseconds":               1,
+		"pipeline_service_execution_overhead_percentage": 1,
+		"pipeline_service_schedule_overhead_percentage":  1,
+		"taskrun_duration_scheduled_seconds":             2,
+	}, metrics)
+
+	_, err = Explain(ctx, c, "test-namespace", "missing")
+	assert.Error(t, err)
+}
+
+func TestExplainNotFinished(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	pr, taskRuns := explainedRuns(false)
+	pr.Labels = map[string]string{THROTTLED_LABEL: "test-1-build"}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr, taskRuns[0]).Build()
+
+	e, err := Explain(context.TODO(), c, "test-namespace", "test-1")
+	assert.NoError(t, err)
+	// the build TaskRun is gone
+	assert.Len(t, e.TaskRuns, 1)
+	assert.Equal(t, "test-1-build", e.ThrottledBy)
+	assert.Equal(t, GapSkipNotFinished, e.Gaps.Reason)
+	for _, d := range e.Decisions {
+		if d.Collector == CollectorTaskRunScheduled {
+			assert.True(t, d.Recorded)
+			continue
+		}
+		assert.False(t, d.Recorded, d.Collector)
+		assert.Equal(t, GapSkipNotFinished, d.Reason)
+	}
+	assert.Len(t, e.Observations, 1)
+}
@@ -0,0 +1,328 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	DefaultExternalCollectorInterval = time.Minute
+	DefaultExternalCollectorTimeout  = 30 * time.Second
+
+	ExternalCollectorResultOK     = "ok"
+	ExternalCollectorResultFailed = "failed"
+
+	// maxExternalCollectorOutput is the most a run may print on its stdout, a run printing more fails
+	maxExternalCollectorOutput = 16 << 20
+	// maxExternalCollectorErrors is how much of the stderr of a failed run is kept for the log
+	maxExternalCollectorErrors = 4 << 10
+)
+
+// ExternalCollectorConfig configures a collector run as a separate binary, so metrics specific to an organization,
+// like those of its release CRs, can be added without forking the exporter; every interval the command is run with a
+// JSON ExternalCollectorRequest on its stdin, and prints the metrics in the Prometheus text format on its stdout,
+// which are served with the exporter's until the next run succeeds
+type ExternalCollectorConfig struct {
+	// Name identifies the collector in the logs and the exporter's metrics about it
+	Name string
+	// Command is the binary and its arguments
+	Command []string
+	// Interval is how often the command is run, DefaultExternalCollectorInterval when 0
+	Interval time.Duration
+	// Timeout is how long a run may take before the command is killed, DefaultExternalCollectorTimeout when 0
+	Timeout time.Duration
+}
+
+// ExternalCollectorRequest is written to the stdin of the external collectors on every run
+type ExternalCollectorRequest struct {
+	// Cluster is the value of the cluster label of the exporter's metrics, empty when not set
+	Cluster string `json:"cluster"`
+	// Timestamp is when the run started, in RFC 3339 format
+	Timestamp string `json:"timestamp"`
+}
+
+func (c ExternalCollectorConfig) validate() error {
+	if len(c.Name) == 0 {
+		return fmt.Errorf("the external collector with command %q needs a name", strings.Join(c.Command, " "))
+	}
+	if len(c.Command) == 0 || len(c.Command[0]) == 0 {
+		return fmt.Errorf("the external collector %s needs a command", c.Name)
+	}
+	return nil
+}
+
+// ParseExternalCollector parses the <name>=<command> [<args>...] value of the --external-collector flag, where the
+// command and its arguments are separated by whitespace
+func ParseExternalCollector(value string) (ExternalCollectorConfig, error) {
+	name, command, _ := strings.Cut(value, "=")
+	config := ExternalCollectorConfig{Name: strings.TrimSpace(name), Command: strings.Fields(command)}
+	if err := config.validate(); err != nil {
+		return config, fmt.Errorf("--external-collector must be <name>=<command> [<args>...], not %q: %w", value, err)
+	}
+	return config, nil
+}
+
+func NewExternalCollectorRunsMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	runs := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_external_collector_runs_total",
+		Help: "Number of runs of each external collector, by whether their metrics were parsed or the run failed",
+	}, []string{COLLECTOR_LABEL, RESULT_LABEL})
+	registerer.MustRegister(runs)
+	return runs
+}
+
+// externalNameClaims are the names of the metric families the external collectors serve, with the name of the
+// collector serving each, so two of them printing the same new name can not both pass the check against the registry
+type externalNameClaims struct {
+	lock  sync.Mutex
+	names map[string]string
+}
+
+func newExternalNameClaims() *externalNameClaims {
+	return &externalNameClaims{names: map[string]string{}}
+}
+
+// cappedBuffer keeps what is written up to its limit, dropping the rest, so a command printing without end can not
+// exhaust the exporter's memory; the buffer is not embedded, as its ReadFrom would be used to copy the output instead
+// of Write
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
+
+// externalCollector runs the command of an external collector every interval, and serves the metrics of its most
+// recent successful run; it is an unchecked collector, as what the command prints is not known up front, so the output
+// of each run is checked before it is served instead, as a metric the registry rejects fails the whole scrape
+type externalCollector struct {
+	config ExternalCollectorConfig
+	runs   *prometheus.CounterVec
+	// registered gathers the metrics the output must not clash with, those of the exporter; nil skips the check, for
+	// registerers which can not be gathered
+	registered prometheus.Gatherer
+	// claims are shared by the external collectors, which must not clash with each other either
+	claims  *externalNameClaims
+	lock    sync.Mutex
+	metrics []prometheus.Metric
+	// names are the names of the metric families of metrics
+	names map[string]struct{}
+}
+
+func newExternalCollector(config ExternalCollectorConfig, runs *prometheus.CounterVec, registered prometheus.Gatherer, claims *externalNameClaims) *externalCollector {
+	if config.Interval <= 0 {
+		config.Interval = DefaultExternalCollectorInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultExternalCollectorTimeout
+	}
+	return &externalCollector{config: config, runs: runs, registered: registered, claims: claims, names: map[string]struct{}{}}
+}
+
+func (e *externalCollector) Describe(chan<- *prometheus.Desc) {
+}
+
+func (e *externalCollector) Collect(ch chan<- prometheus.Metric) {
+	e.lock.Lock()
+	metrics := e.metrics
+	e.lock.Unlock()
+	for _, metric := range metrics {
+		ch <- metric
+	}
+}
+
+// convert checks the parsed families can be served along with the registered ones, returning their metrics: the names
+// can not be those of the families registered by anything else, and every sample has to convert to a metric of a
+// distinct set of label values
+func (e *externalCollector) convert(parsed map[string]*dto.MetricFamily) ([]prometheus.Metric, map[string]struct{}, error) {
+	metrics := []prometheus.Metric{}
+	names := map[string]struct{}{}
+	for name, family := range parsed {
+		names[name] = struct{}{}
+		seen := map[uint64]struct{}{}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, pair := range m.GetLabel() {
+				// the registerer adds the constant labels, like cluster, to every metric
+				if _, constant := constLabels[pair.GetName()]; constant {
+					return nil, nil, fmt.Errorf("the metric %s printed by external collector %s has the %s label the exporter adds", name, e.config.Name, pair.GetName())
+				}
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			signature := model.LabelsToSignature(labels)
+			if _, dup := seen[signature]; dup {
+				return nil, nil, fmt.Errorf("the metric %s printed by external collector %s repeats the labels %v", name, e.config.Name, labels)
+			}
+			seen[signature] = struct{}{}
+			metric, err := constMetric(family, m)
+			if err != nil {
+				return nil, nil, fmt.Errorf("the metric %s printed by external collector %s is not valid: %w", name, e.config.Name, err)
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+	if err := e.claim(names); err != nil {
+		return nil, nil, err
+	}
+	return metrics, names, nil
+}
+
+// claim checks the names printed for the first time are not served by another external collector, or registered by
+// anything else, claiming them for this one, and releases those no longer printed; the registry is only gathered for
+// new names, as the names claimed by the prior runs were checked already
+func (e *externalCollector) claim(names map[string]struct{}) error {
+	e.lock.Lock()
+	own := e.names
+	e.lock.Unlock()
+	added := []string{}
+	for name := range names {
+		if _, ours := own[name]; !ours {
+			added = append(added, name)
+		}
+	}
+	e.claims.lock.Lock()
+	defer e.claims.lock.Unlock()
+	if len(added) > 0 {
+		for _, name := range added {
+			if other, claimed := e.claims.names[name]; claimed && other != e.config.Name {
+				return fmt.Errorf("the metric %s printed by external collector %s is already printed by external collector %s", name, e.config.Name, other)
+			}
+		}
+		if e.registered != nil {
+			// the families gathered despite an error are still checked against
+			registered, _ := e.registered.Gather()
+			for _, family := range registered {
+				if _, printed := names[family.GetName()]; printed {
+					if _, ours := own[family.GetName()]; !ours {
+						return fmt.Errorf("the metric %s printed by external collector %s is already registered", family.GetName(), e.config.Name)
+					}
+				}
+			}
+		}
+	}
+	for name := range own {
+		if _, printed := names[name]; !printed && e.claims.names[name] == e.config.Name {
+			delete(e.claims.names, name)
+		}
+	}
+	for _, name := range added {
+		e.claims.names[name] = e.config.Name
+	}
+	return nil
+}
+
+// constMetric converts a parsed sample back into a metric the registry can gather
+func constMetric(family *dto.MetricFamily, m *dto.Metric) (prometheus.Metric, error) {
+	names := []string{}
+	values := []string{}
+	for _, pair := range m.GetLabel() {
+		names = append(names, pair.GetName())
+		values = append(values, pair.GetValue())
+	}
+	desc := prometheus.NewDesc(family.GetName(), family.GetHelp(), names, nil)
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		return prometheus.NewConstMetric(desc, prometheus.CounterValue, m.GetCounter().GetValue(), values...)
+	case dto.MetricType_GAUGE:
+		return prometheus.NewConstMetric(desc, prometheus.GaugeValue, m.GetGauge().GetValue(), values...)
+	case dto.MetricType_HISTOGRAM:
+		buckets := map[float64]uint64{}
+		for _, b := range m.GetHistogram().GetBucket() {
+			buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+		}
+		return prometheus.NewConstHistogram(desc, m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum(), buckets, values...)
+	case dto.MetricType_SUMMARY:
+		quantiles := map[float64]float64{}
+		for _, q := range m.GetSummary().GetQuantile() {
+			quantiles[q.GetQuantile()] = q.GetValue()
+		}
+		return prometheus.NewConstSummary(desc, m.GetSummary().GetSampleCount(), m.GetSummary().GetSampleSum(), quantiles, values...)
+	default:
+		return prometheus.NewConstMetric(desc, prometheus.UntypedValue, m.GetUntyped().GetValue(), values...)
+	}
+}
+
+// run executes the command once, replacing the served metric families when its output parses; on failure the families
+// of the prior run are kept, so a flaky command does not make its metrics come and go
+func (e *externalCollector) run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+	request, err := json.Marshal(ExternalCollectorRequest{
+		Cluster:   constLabels[CLUSTER_LABEL],
+		Timestamp: exporterClock.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, e.config.Command[0], e.config.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(append(request, '\n'))
+	stdout := &cappedBuffer{limit: maxExternalCollectorOutput}
+	stderr := &cappedBuffer{limit: maxExternalCollectorErrors}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err = cmd.Run(); err != nil {
+		e.runs.With(prometheus.Labels{COLLECTOR_LABEL: e.config.Name, RESULT_LABEL: ExternalCollectorResultFailed}).Inc()
+		return fmt.Errorf("external collector %s failed: %w: %s", e.config.Name, err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.truncated {
+		e.runs.With(prometheus.Labels{COLLECTOR_LABEL: e.config.Name, RESULT_LABEL: ExternalCollectorResultFailed}).Inc()
+		return fmt.Errorf("external collector %s printed more than %d bytes", e.config.Name, maxExternalCollectorOutput)
+	}
+	parser := expfmt.TextParser{}
+	parsed, err := parser.TextToMetricFamilies(&stdout.buf)
+	if err != nil {
+		e.runs.With(prometheus.Labels{COLLECTOR_LABEL: e.config.Name, RESULT_LABEL: ExternalCollectorResultFailed}).Inc()
+		return fmt.Errorf("the output of external collector %s is not in the Prometheus text format: %w", e.config.Name, err)
+	}
+	metrics, names, err := e.convert(parsed)
+	if err != nil {
+		e.runs.With(prometheus.Labels{COLLECTOR_LABEL: e.config.Name, RESULT_LABEL: ExternalCollectorResultFailed}).Inc()
+		return err
+	}
+	e.lock.Lock()
+	e.metrics = metrics
+	e.names = names
+	e.lock.Unlock()
+	e.runs.With(prometheus.Labels{COLLECTOR_LABEL: e.config.Name, RESULT_LABEL: ExternalCollectorResultOK}).Inc()
+	return nil
+}
+
+func (e *externalCollector) Start(ctx context.Context) error {
+	ticker := exporterClock.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := e.run(ctx); err != nil {
+			controllerLog.Info(err.Error())
+		}
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
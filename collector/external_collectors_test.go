@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExternalCollector(t *testing.T) {
+	config, err := ParseExternalCollector("releases=/usr/local/bin/release-metrics --namespace konflux")
+	assert.NoError(t, err)
+	assert.Equal(t, "releases", config.Name)
+	assert.Equal(t, []string{"/usr/local/bin/release-metrics", "--namespace", "konflux"}, config.Command)
+
+	_, err = ParseExternalCollector("/usr/local/bin/release-metrics")
+	assert.Error(t, err)
+	_, err = ParseExternalCollector("releases=")
+	assert.Error(t, err)
+}
+
+func TestExternalCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	runs := NewExternalCollectorRunsMetric(registry)
+	output := `# HELP release_count Number of releases
+# TYPE release_count gauge
+release_count{namespace="test-namespace"} 3
+release_count{namespace="test-namespace-2"} 1
+`
+	// the request on stdin has to be read, so check it is there too
+	external := newExternalCollector(ExternalCollectorConfig{
+		Name:    "releases",
+		Command: []string{"sh", "-c", `grep -q '"cluster"' && printf '%s' "$0"`, output},
+	}, runs, registry, newExternalNameClaims())
+	registry.MustRegister(external)
+	ctx := context.TODO()
+	assert.NoError(t, external.run(ctx))
+	validateCounterVec(t, runs, prometheus.Labels{COLLECTOR_LABEL: "releases", RESULT_LABEL: ExternalCollectorResultOK}, float64(1))
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	found := false
+	for _, family := range families {
+		if family.GetName() != "release_count" {
+			continue
+		}
+		found = true
+		assert.Len(t, family.GetMetric(), 2)
+		assert.Equal(t, float64(3), family.GetMetric()[0].GetGauge().GetValue())
+	}
+	assert.True(t, found)
+
+	// a failed run keeps the metrics of the prior one
+	external.config.Command = []string{"sh", "-c", "echo 'not metrics {'"}
+	assert.Error(t, external.run(ctx))
+	external.config.Command = []string{"sh", "-c", "exit 1"}
+	assert.Error(t, external.run(ctx))
+	validateCounterVec(t, runs, prometheus.Labels{COLLECTOR_LABEL: "releases", RESULT_LABEL: ExternalCollectorResultFailed}, float64(2))
+	families, err = registry.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 2)
+
+	// output clashing with a registered metric, or repeating a series, is rejected vs. failing the scrape
+	external.config.Command = []string{"sh", "-c", `printf '%s' "$0"`, `# TYPE pipeline_service_exporter_external_collector_runs_total counter
+pipeline_service_exporter_external_collector_runs_total{collector="releases",result="ok"} 10
+`}
+	assert.Error(t, external.run(ctx))
+	external.config.Command = []string{"sh", "-c", `printf '%s' "$0"`, `# TYPE release_count gauge
+release_count{namespace="test-namespace"} 3
+release_count{namespace="test-namespace"} 4
+`}
+	assert.Error(t, external.run(ctx))
+	validateCounterVec(t, runs, prometheus.Labels{COLLECTOR_LABEL: "releases", RESULT_LABEL: ExternalCollectorResultFailed}, float64(4))
+	families, err = registry.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 2)
+
+	// the collector's own metrics of the prior run are not a clash
+	external.config.Command = []string{"sh", "-c", `printf '%s' "$0"`, output}
+	assert.NoError(t, external.run(ctx))
+
+	// another external collector can not print the same metric
+	other := newExternalCollector(ExternalCollectorConfig{
+		Name:    "other",
+		Command: []string{"sh", "-c", `printf '%s' "$0"`, output},
+	}, runs, registry, external.claims)
+	assert.ErrorContains(t, other.run(ctx), "already printed by external collector releases")
+	// until the first one stops printing it
+	external.config.Command = []string{"sh", "-c", "true"}
+	assert.NoError(t, external.run(ctx))
+	assert.NoError(t, other.run(ctx))
+
+	// a run printing without end fails, keeping the metrics of the prior run
+	other.config.Command = []string{"sh", "-c", fmt.Sprintf("head -c %d /dev/zero", maxExternalCollectorOutput+1)}
+	assert.ErrorContains(t, other.run(ctx), "printed more than")
+	assert.Contains(t, other.names, "release_count")
+}
+
+// countingGatherer counts the gathers of the registry
+type countingGatherer struct {
+	prometheus.Gatherer
+	gathers int
+}
+
+func (g *countingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.gathers++
+	return g.Gatherer.Gather()
+}
+
+func TestExternalCollectorGathers(t *testing.T) {
+	registry := &countingGatherer{Gatherer: prometheus.NewRegistry()}
+	runs := NewExternalCollectorRunsMetric(prometheus.NewRegistry())
+	external := newExternalCollector(ExternalCollectorConfig{
+		Name:    "releases",
+		Command: []string{"sh", "-c", "echo 'release_count 3'"},
+	}, runs, registry, newExternalNameClaims())
+	ctx := context.TODO()
+	// the registry is only gathered for the names printed for the first time
+	assert.NoError(t, external.run(ctx))
+	assert.NoError(t, external.run(ctx))
+	assert.Equal(t, 1, registry.gathers)
+	external.config.Command = []string{"sh", "-c", "echo 'release_count 3'; echo 'release_failures 1'"}
+	assert.NoError(t, external.run(ctx))
+	assert.Equal(t, 2, registry.gathers)
+}
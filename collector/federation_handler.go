@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// FederationEndpointEnvName turns on the /federate path on the metrics listener
+	FederationEndpointEnvName = "FEDERATION_ENDPOINT_ENABLED"
+	FederationPath            = "/federate"
+)
+
+// federatedMetrics are the metrics the RHTAP host cluster scrapes from each member cluster exporter; the namespace and
+// tenant labels are aggregated away, so only per-cluster series are shipped
+var federatedMetrics = map[string]struct{}{
+	"pipeline_service_execution_overhead_percentage":          {},
+	"pipeline_service_schedule_overhead_percentage":           {},
+	"pipelinerun_gap_between_taskruns_milliseconds":           {},
+	"pipelinerun_gap_max_milliseconds":                        {},
+	"pipelinerun_duration_scheduled_seconds":                  {},
+	"taskrun_duration_scheduled_seconds":                      {},
+	"pipeline_service_execution_overhead_ratio":               {},
+	"pipeline_service_schedule_overhead_ratio":                {},
+	"pipeline_service_execution_overhead_rollup":              {},
+	"pipeline_service_schedule_overhead_rollup":               {},
+	"pipeline_service_pipeline_daily_success_ratio":           {},
+	"pipeline_service_pipeline_daily_completions":             {},
+	"pipeline_service_pipelinerun_taskrun_gap_milliseconds":   {},
+	"pipeline_service_pipelinerun_scheduled_duration_seconds": {},
+	"pipeline_service_taskrun_scheduled_duration_seconds":     {},
+	"pipelinerun_failed_by_pvc_quota_count":                   {},
+	"pipeline_service_stuck_namespaces":                       {},
+	"pipeline_service_active_pipeline_namespaces":             {},
+	"pipeline_service_exporter_heartbeat_timestamp_seconds":   {},
+	"pipeline_service_exporter_build_info":                    {},
+}
+
+var federationDroppedLabels = map[string]struct{}{
+	NS_LABEL:     {},
+	TENANT_LABEL: {},
+}
+
+type federationGatherer struct {
+	gatherer prometheus.Gatherer
+}
+
+func (g *federationGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	rolledUp := []*dto.MetricFamily{}
+	for _, mf := range families {
+		if _, ok := federatedMetrics[mf.GetName()]; !ok {
+			continue
+		}
+		switch mf.GetType() {
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE, dto.MetricType_COUNTER:
+		default:
+			continue
+		}
+		rolledUp = append(rolledUp, rollupMetricFamily(mf))
+	}
+	return rolledUp, nil
+}
+
+func rollupMetricFamily(mf *dto.MetricFamily) *dto.MetricFamily {
+	groups := map[string]*dto.Metric{}
+	keys := []string{}
+	for _, m := range mf.Metric {
+		kept := []*dto.LabelPair{}
+		keyParts := []string{}
+		for _, lp := range m.Label {
+			if _, drop := federationDroppedLabels[lp.GetName()]; drop {
+				continue
+			}
+			kept = append(kept, lp)
+			keyParts = append(keyParts, lp.GetName()+"="+lp.GetValue())
+		}
+		key := strings.Join(keyParts, ",")
+		agg, ok := groups[key]
+		if !ok {
+			agg = &dto.Metric{Label: kept}
+			groups[key] = agg
+			keys = append(keys, key)
+		}
+		switch mf.GetType() {
+		case dto.MetricType_HISTOGRAM:
+			agg.Histogram = addHistograms(agg.Histogram, m.Histogram)
+		case dto.MetricType_GAUGE:
+			if agg.Gauge == nil {
+				agg.Gauge = &dto.Gauge{Value: proto.Float64(0)}
+			}
+			agg.Gauge.Value = proto.Float64(agg.Gauge.GetValue() + m.Gauge.GetValue())
+		case dto.MetricType_COUNTER:
+			if agg.Counter == nil {
+				agg.Counter = &dto.Counter{Value: proto.Float64(0)}
+			}
+			agg.Counter.Value = proto.Float64(agg.Counter.GetValue() + m.Counter.GetValue())
+		}
+	}
+	sort.Strings(keys)
+	metrics := []*dto.Metric{}
+	for _, key := range keys {
+		metrics = append(metrics, groups[key])
+	}
+	return &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type, Metric: metrics}
+}
+
+// addHistograms assumes both histograms have the same buckets, which is the case for series of the same metric
+func addHistograms(sum, h *dto.Histogram) *dto.Histogram {
+	if h == nil {
+		return sum
+	}
+	if sum == nil {
+		sum = &dto.Histogram{SampleCount: proto.Uint64(0), SampleSum: proto.Float64(0)}
+		for _, b := range h.Bucket {
+			sum.Bucket = append(sum.Bucket, &dto.Bucket{UpperBound: proto.Float64(b.GetUpperBound()), CumulativeCount: proto.Uint64(0)})
+		}
+	}
+	sum.SampleCount = proto.Uint64(sum.GetSampleCount() + h.GetSampleCount())
+	sum.SampleSum = proto.Float64(sum.GetSampleSum() + h.GetSampleSum())
+	for i, b := range h.Bucket {
+		if i >= len(sum.Bucket) {
+			break
+		}
+		sum.Bucket[i].CumulativeCount = proto.Uint64(sum.Bucket[i].GetCumulativeCount() + b.GetCumulativeCount())
+	}
+	return sum
+}
+
+func newFederationHandler() http.Handler {
+	return promhttp.HandlerFor(&federationGatherer{gatherer: ServedGatherer}, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.HTTPErrorOnError,
+	})
+}
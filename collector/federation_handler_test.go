@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFederationGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	gaps := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipelinerun_gap_between_taskruns_milliseconds",
+		Help:    "test",
+		Buckets: []float64{100, 500},
+	}, []string{NS_LABEL, STATUS_LABEL, TENANT_LABEL})
+	pvc := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "pipelinerun_failed_by_pvc_quota_count", Help: "test"}, []string{NS_LABEL})
+	notFederated := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_not_federated", Help: "test"}, []string{NS_LABEL})
+	registry.MustRegister(gaps, pvc, notFederated)
+	gaps.With(prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED, TENANT_LABEL: "workspace-1"}).Observe(50)
+	gaps.With(prometheus.Labels{NS_LABEL: "test-namespace-2", STATUS_LABEL: SUCCEEDED, TENANT_LABEL: "workspace-2"}).Observe(300)
+	gaps.With(prometheus.Labels{NS_LABEL: "test-namespace-2", STATUS_LABEL: FAILED, TENANT_LABEL: "workspace-2"}).Observe(1000)
+	pvc.With(prometheus.Labels{NS_LABEL: "test-namespace"}).Set(2)
+	pvc.With(prometheus.Labels{NS_LABEL: "test-namespace-2"}).Set(3)
+	notFederated.With(prometheus.Labels{NS_LABEL: "test-namespace"}).Set(1)
+
+	families, err := (&federationGatherer{gatherer: registry}).Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 2)
+	for _, mf := range families {
+		switch mf.GetName() {
+		case "pipelinerun_gap_between_taskruns_milliseconds":
+			assert.Len(t, mf.Metric, 2)
+			for _, m := range mf.Metric {
+				assert.Len(t, m.Label, 1)
+				assert.Equal(t, STATUS_LABEL, m.Label[0].GetName())
+				if m.Label[0].GetValue() == SUCCEEDED {
+					assert.Equal(t, uint64(2), m.Histogram.GetSampleCount())
+					assert.Equal(t, float64(350), m.Histogram.GetSampleSum())
+					assert.Equal(t, uint64(1), m.Histogram.Bucket[0].GetCumulativeCount())
+					assert.Equal(t, uint64(2), m.Histogram.Bucket[1].GetCumulativeCount())
+				} else {
+					assert.Equal(t, uint64(1), m.Histogram.GetSampleCount())
+					assert.Equal(t, uint64(0), m.Histogram.Bucket[1].GetCumulativeCount())
+				}
+			}
+		case "pipelinerun_failed_by_pvc_quota_count":
+			assert.Len(t, mf.Metric, 1)
+			assert.Len(t, mf.Metric[0].Label, 0)
+			assert.Equal(t, float64(5), mf.Metric[0].Gauge.GetValue())
+		default:
+			t.Errorf("unexpected metric %s", mf.GetName())
+		}
+	}
+}
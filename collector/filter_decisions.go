@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	DECISION_LABEL = "decision"
+
+	DecisionAccepted = "accepted"
+	DecisionRejected = "rejected"
+)
+
+// filterDecisionTracker counts what each collector's filter returns, so the churn each of them processes, and any
+// change in how much of it they let through, say after a Tekton upgrade, can be seen
+type filterDecisionTracker struct {
+	lock      sync.RWMutex
+	decisions *prometheus.CounterVec
+}
+
+var filterDecisions = &filterDecisionTracker{}
+
+func NewFilterDecisionsMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	decisions := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_filter_events_total",
+		Help: "Number of events processed by each collector's filter, by whether the filter accepted or rejected them",
+	}, []string{FILTER_LABEL, DECISION_LABEL})
+	registerer.MustRegister(decisions)
+	return decisions
+}
+
+func (t *filterDecisionTracker) enable(decisions *prometheus.CounterVec) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.decisions = decisions
+}
+
+func (t *filterDecisionTracker) record(filter string, accepted bool) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if t.decisions == nil {
+		return accepted
+	}
+	decision := DecisionRejected
+	if accepted {
+		decision = DecisionAccepted
+	}
+	t.decisions.With(map[string]string{FILTER_LABEL: filter, DECISION_LABEL: decision}).Inc()
+	return accepted
+}
+
+// countingFilter records the decisions of a collector's filter; as the collectors without a reconcile record their
+// metrics in their filter, their filters always reject, so for them only the total churn is of interest
+type countingFilter struct {
+	name  string
+	inner predicate.Predicate
+}
+
+func (f *countingFilter) Create(e event.CreateEvent) bool {
+	return f.guard(func() bool { return f.inner.Create(e) })
+}
+
+func (f *countingFilter) Delete(e event.DeleteEvent) bool {
+	return f.guard(func() bool { return f.inner.Delete(e) })
+}
+
+func (f *countingFilter) Update(e event.UpdateEvent) bool {
+	return f.guard(func() bool { return f.inner.Update(e) })
+}
+
+func (f *countingFilter) Generic(e event.GenericEvent) bool {
+	return f.guard(func() bool { return f.inner.Generic(e) })
+}
+
+// guard drops the events while the collector is disabled, and counts a panic against the collector's health before
+// passing it on to be recovered, as safePredicate only knows the filter by type
+func (f *countingFilter) guard(decide func() bool) bool {
+	if collectorHealth.isDisabled(f.name) {
+		return false
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			collectorHealth.failed(f.name, DisabledReasonPanics)
+			panic(r)
+		}
+	}()
+	return filterDecisions.record(f.name, decide())
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+func TestCountingFilter(t *testing.T) {
+	decisions := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_filter_events_total", Help: "test"},
+		[]string{FILTER_LABEL, DECISION_LABEL})
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	o := newOptions()
+	accepting := o.collectorFilter(CollectorOverhead, predicate.NewPredicateFuncs(func(obj client.Object) bool { return true }))
+	rejecting := o.collectorFilter(CollectorTaskRunGaps, predicate.Not(predicate.NewPredicateFuncs(func(obj client.Object) bool { return true })))
+
+	// nothing is counted until the metric is enabled
+	assert.True(t, accepting.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	filterDecisions.enable(decisions)
+	defer filterDecisions.enable(nil)
+
+	assert.True(t, accepting.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	assert.True(t, accepting.Create(event.CreateEvent{Object: pr}))
+	assert.False(t, rejecting.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	assert.False(t, rejecting.Delete(event.DeleteEvent{Object: pr}))
+	assert.False(t, rejecting.Generic(event.GenericEvent{Object: pr}))
+	validateCounterVec(t, decisions, prometheus.Labels{FILTER_LABEL: CollectorOverhead, DECISION_LABEL: DecisionAccepted}, float64(2))
+	validateCounterVec(t, decisions, prometheus.Labels{FILTER_LABEL: CollectorTaskRunGaps, DECISION_LABEL: DecisionRejected}, float64(3))
+	validateCounterVec(t, decisions, prometheus.Labels{FILTER_LABEL: CollectorOverhead, DECISION_LABEL: DecisionRejected}, float64(0))
+}
+
+func TestExporterFilterDelegates(t *testing.T) {
+	decisions := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_filter_events_total", Help: "test"},
+		[]string{FILTER_LABEL, DECISION_LABEL})
+	filterDecisions.enable(decisions)
+	defer filterDecisions.enable(nil)
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	o := newOptions()
+	f := &ExporterFilter{
+		noReconcile: []predicate.Predicate{o.collectorFilter(CollectorPipelineRunPending,
+			predicate.NewPredicateFuncs(func(obj client.Object) bool { return true }))},
+		yesReconcile: []predicate.Predicate{o.collectorFilter(CollectorOverhead, predicate.Funcs{
+			CreateFunc:  func(event.CreateEvent) bool { return true },
+			DeleteFunc:  func(event.DeleteEvent) bool { panic("test") },
+			GenericFunc: func(event.GenericEvent) bool { return false },
+		})},
+	}
+
+	// the create, delete, and generic events reach the predicates, only those needing Reconcile decide the result,
+	// and a panic of one predicate is recovered without a decision
+	assert.True(t, f.Create(event.CreateEvent{Object: pr}))
+	assert.False(t, f.Delete(event.DeleteEvent{Object: pr}))
+	assert.False(t, f.Generic(event.GenericEvent{Object: pr}))
+	validateCounterVec(t, decisions, prometheus.Labels{FILTER_LABEL: CollectorPipelineRunPending, DECISION_LABEL: DecisionAccepted}, float64(3))
+	validateCounterVec(t, decisions, prometheus.Labels{FILTER_LABEL: CollectorOverhead, DECISION_LABEL: DecisionAccepted}, float64(1))
+	validateCounterVec(t, decisions, prometheus.Labels{FILTER_LABEL: CollectorOverhead, DECISION_LABEL: DecisionRejected}, float64(1))
+}
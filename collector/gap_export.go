@@ -0,0 +1,227 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DefaultGapExportInterval   = 5 * time.Minute
+	DefaultGapExportMaxRecords = 10000
+	// gapExportMaxBuffered is how many records are held while uploads fail, before the oldest are dropped
+	gapExportMaxBuffered = 10 * DefaultGapExportMaxRecords
+	gapExportTimeout     = time.Minute
+
+	RESULT_LABEL           = "result"
+	GapExportResultWritten = "written"
+	GapExportResultDropped = "dropped"
+)
+
+// GapExportConfig configures the sink writing the gap breakdown of every completed PipelineRun to S3 compatible
+// storage, as JSON lines objects under <Prefix>/<cluster>/<date>/, for offline analysis
+type GapExportConfig struct {
+	// Endpoint is the S3 compatible endpoint, like https://s3.us-east-1.amazonaws.com; the bucket is addressed in the
+	// path, which all S3 compatible stores support
+	Endpoint string
+	Bucket   string
+	// Prefix is prepended to the object keys when set
+	Prefix string
+	// Region is what the requests are signed for
+	Region      string
+	Credentials aws.CredentialsProvider
+	// Interval is how often the records are written, DefaultGapExportInterval when 0
+	Interval time.Duration
+	// MaxRecords is how many records an object holds, with the records written early once reached,
+	// DefaultGapExportMaxRecords when 0
+	MaxRecords int
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// GapRecord is a line of the exported objects
+type GapRecord struct {
+	Cluster string
+	RecentRun
+}
+
+// gapExporter buffers the records of the completed PipelineRuns, writing them every interval, when the buffer holds
+// an object's worth, and when the manager stops; records whose upload failed are retried with the next ones
+type gapExporter struct {
+	config  GapExportConfig
+	signer  *v4.Signer
+	lock    sync.Mutex
+	records []GapRecord
+	full    chan struct{}
+	results *prometheus.CounterVec
+	// written numbers the objects, so those written within the same clock tick get different keys
+	written int
+}
+
+func NewGapExportMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	results := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_gap_export_records_total",
+		Help: "Number of PipelineRun gap records written to object storage, or dropped after failed uploads filled the buffer",
+	}, []string{RESULT_LABEL})
+	registerer.MustRegister(results)
+	return results
+}
+
+func (c GapExportConfig) validate() error {
+	missing := []string{}
+	if len(c.Endpoint) == 0 {
+		missing = append(missing, "endpoint")
+	}
+	if len(c.Bucket) == 0 {
+		missing = append(missing, "bucket")
+	}
+	if len(c.Region) == 0 {
+		missing = append(missing, "region")
+	}
+	if c.Credentials == nil {
+		missing = append(missing, "credentials")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the gap export needs the %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func newGapExporter(config GapExportConfig, results *prometheus.CounterVec) *gapExporter {
+	if config.Interval <= 0 {
+		config.Interval = DefaultGapExportInterval
+	}
+	if config.MaxRecords <= 0 {
+		config.MaxRecords = DefaultGapExportMaxRecords
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	return &gapExporter{config: config, signer: v4.NewSigner(), full: make(chan struct{}, 1), results: results}
+}
+
+func (e *gapExporter) add(run *RecentRun) {
+	if e == nil || run == nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.records = append(e.records, GapRecord{Cluster: constLabels[CLUSTER_LABEL], RecentRun: *run})
+	if over := len(e.records) - gapExportMaxBuffered; over > 0 {
+		e.records = e.records[over:]
+		e.results.With(prometheus.Labels{RESULT_LABEL: GapExportResultDropped}).Add(float64(over))
+	}
+	if len(e.records) >= e.config.MaxRecords {
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// objectKey is where the records go, keyed by cluster and the UTC date they were written on
+func (e *gapExporter) objectKey(now time.Time) string {
+	e.written++
+	cluster := constLabels[CLUSTER_LABEL]
+	if len(cluster) == 0 {
+		cluster = "unknown"
+	}
+	parts := []string{cluster, now.UTC().Format("2006-01-02"), fmt.Sprintf("%d-%d.jsonl", now.UnixNano(), e.written)}
+	if len(e.config.Prefix) > 0 {
+		parts = append([]string{e.config.Prefix}, parts...)
+	}
+	return strings.Join(parts, "/")
+}
+
+// flush writes the buffered records, an object at a time, putting those not written back in front of any added since
+func (e *gapExporter) flush(ctx context.Context) error {
+	e.lock.Lock()
+	records := e.records
+	e.records = nil
+	e.lock.Unlock()
+	for len(records) > 0 {
+		batch := records
+		if len(batch) > e.config.MaxRecords {
+			batch = batch[:e.config.MaxRecords]
+		}
+		if err := e.put(ctx, e.objectKey(exporterClock.Now()), batch); err != nil {
+			e.lock.Lock()
+			e.records = append(records, e.records...)
+			e.lock.Unlock()
+			return err
+		}
+		e.results.With(prometheus.Labels{RESULT_LABEL: GapExportResultWritten}).Add(float64(len(batch)))
+		records = records[len(batch):]
+	}
+	return nil
+}
+
+func (e *gapExporter) put(ctx context.Context, key string, records []GapRecord) error {
+	body := &bytes.Buffer{}
+	encoder := json.NewEncoder(body)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, gapExportTimeout)
+	defer cancel()
+	objectURL := e.config.Endpoint + "/" + url.PathEscape(e.config.Bucket) + "/" + (&url.URL{Path: key}).EscapedPath()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	hash := sha256.Sum256(body.Bytes())
+	payloadHash := hex.EncodeToString(hash[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	creds, err := e.config.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get the credentials for the gap export: %w", err)
+	}
+	if err = e.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", e.config.Region, exporterClock.Now()); err != nil {
+		return err
+	}
+	resp, err := e.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("writing %s to bucket %s failed with status %s", key, e.config.Bucket, resp.Status)
+	}
+	return nil
+}
+
+func (e *gapExporter) Start(ctx context.Context) error {
+	ticker := exporterClock.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+		case <-e.full:
+		case <-ctx.Done():
+			// the manager's context is done, so the last records get their own
+			flushCtx, cancel := context.WithTimeout(context.Background(), gapExportTimeout)
+			defer cancel()
+			return e.flush(flushCtx)
+		}
+		if err := e.flush(ctx); err != nil {
+			controllerLog.Info(fmt.Sprintf("gap export failed, retrying with the next records: %s", err.Error()))
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	testclock "k8s.io/utils/clock/testing"
+)
+
+type fakeBucket struct {
+	lock    sync.Mutex
+	fail    bool
+	objects map[string][]GapRecord
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if r.Method != http.MethodPut || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") || b.fail {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	records := []GapRecord{}
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		record := GapRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		records = append(records, record)
+	}
+	b.objects[r.URL.Path] = records
+}
+
+func TestGapExporter(t *testing.T) {
+	defer setClock(testclock.NewFakeClock(time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC)))()
+	constLabels[CLUSTER_LABEL] = "test-cluster"
+	defer delete(constLabels, CLUSTER_LABEL)
+	bucket := &fakeBucket{objects: map[string][]GapRecord{}}
+	server := httptest.NewServer(bucket)
+	defer server.Close()
+	config := GapExportConfig{Endpoint: server.URL + "/", Bucket: "gaps", Prefix: "/exports/", Region: "us-east-1", MaxRecords: 2,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}, nil
+		})}
+	assert.NoError(t, config.validate())
+	assert.ErrorContains(t, GapExportConfig{Endpoint: server.URL}.validate(), "bucket, region, credentials")
+	results := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_gap_export_records_total", Help: "test"}, []string{RESULT_LABEL})
+	e := newGapExporter(config, results)
+
+	// failed uploads are retried with the next records
+	bucket.fail = true
+	e.add(&RecentRun{Namespace: "test-namespace", Name: "test-1", Gaps: GapResult{Entries: []GapEntry{{Gap: 100}}}})
+	assert.Len(t, e.full, 0)
+	assert.Error(t, e.flush(context.TODO()))
+	bucket.fail = false
+	e.add(&RecentRun{Namespace: "test-namespace", Name: "test-2"})
+	e.add(&RecentRun{Namespace: "test-namespace", Name: "test-3"})
+	assert.Len(t, e.full, 1)
+	assert.NoError(t, e.flush(context.TODO()))
+	validateCounterVec(t, results, prometheus.Labels{RESULT_LABEL: GapExportResultWritten}, float64(3))
+	assert.Empty(t, e.records)
+
+	// an object per MaxRecords, keyed by cluster and date
+	names := []string{}
+	for path, records := range bucket.objects {
+		assert.True(t, strings.HasPrefix(path, "/gaps/exports/test-cluster/2023-03-10/"), path)
+		for _, record := range records {
+			assert.Equal(t, "test-cluster", record.Cluster)
+			names = append(names, record.Name)
+		}
+	}
+	assert.ElementsMatch(t, []string{"test-1", "test-2", "test-3"}, names)
+
+	var unset *gapExporter
+	unset.add(&RecentRun{Name: "test-1"})
+}
@@ -0,0 +1,243 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The reasons AccumulateGaps skips a PipelineRun without fetching its TaskRuns; the GapAbort reasons cover the
+// PipelineRuns whose TaskRuns could not be used
+const (
+	GapSkipNoTaskRuns  = "no-taskruns"
+	GapSkipNotFinished = "not-finished"
+	GapSkipThrottled   = "throttled"
+)
+
+// The phases of a gap; GapPhaseKickoff gaps are measured from the creation of the PipelineRun, so they are down to the
+// controller kicking off the PipelineRun and its first TaskRuns, while GapPhaseBetweenTaskRuns gaps are down to the
+// scheduling of a TaskRun after the ones it follows completed
+const (
+	GapPhaseKickoff         = "kickoff"
+	GapPhaseBetweenTaskRuns = "between-taskruns"
+)
+
+// GapEntry is one gap of a PipelineRun, the time between a TaskRun, or the PipelineRun itself, and the TaskRun that
+// followed it
+type GapEntry struct {
+	// Status is the status label value of the PipelineRun
+	Status string
+	// Pipeline is the pipeline reference of the PipelineRun
+	Pipeline string
+	// Completed is the task reference of the TaskRun the gap starts with its completion, or the pipeline reference
+	// when the gap starts with the creation of the PipelineRun
+	Completed string
+	// Upcoming is the task reference of the TaskRun the gap ends with its creation
+	Upcoming string
+	// Gap is the length of the gap in milliseconds
+	Gap float64
+	// Phase is GapPhaseKickoff for the gaps starting with the creation of the PipelineRun, GapPhaseBetweenTaskRuns
+	// otherwise
+	Phase string
+}
+
+// GapResult is the outcome of AccumulateGaps for one PipelineRun
+type GapResult struct {
+	// Entries are the gaps in the creation order of their upcoming TaskRuns
+	Entries []GapEntry
+	// Total is the sum of the gaps in milliseconds
+	Total float64
+	// Duration is the time from the start to the completion of the PipelineRun in milliseconds, 0 if it has no start time
+	Duration float64
+	// Reason is why the gaps were not calculated, one of the GapSkip or GapAbort constants, or empty if they were
+	Reason string
+}
+
+// Calculated is true if the gaps were calculated, even if there were none
+func (g GapResult) Calculated() bool {
+	return len(g.Reason) == 0
+}
+
+// Overhead is the execution overhead of the PipelineRun, the share of its duration spent in gaps, as recorded in
+// pipeline_service_execution_overhead_percentage; it is false if the gaps were not calculated, or if the overhead
+// is filtered because the PipelineRun was too short to be of concern
+func (g GapResult) Overhead() (float64, bool) {
+	return g.OverheadAt(settings.EffectiveFilterThreshold())
+}
+
+// OverheadAt is Overhead with a FilterThreshold other than the configured one, where 0 filters nothing, say to see
+// how a change to it would play out
+func (g GapResult) OverheadAt(threshold float64) (float64, bool) {
+	if !g.Calculated() || g.Duration <= 0 || filterAt(g.Total, g.Duration, threshold) {
+		return 0, false
+	}
+	return g.Total / g.Duration, true
+}
+
+// gapSkipReason is why a PipelineRun's gaps should not be calculated, or the empty string if they should
+func gapSkipReason(pr *v1.PipelineRun) string {
+	if len(pr.Status.ChildReferences) < 1 {
+		return GapSkipNoTaskRuns
+	}
+	// in case there are gaps between a pipelinerun being marked done but the complete timestamp is not set, with the
+	// understanding that the complete timestamp is not processed before any completed taskrun complete timestamps have been processed
+	if pr.Status.CompletionTime == nil {
+		return GapSkipNotFinished
+	}
+
+	// we've seen a few times now that quota/node throttling artificially inflates our execution overhead,
+	// vs. concurrency contention in our controller;
+	// with our separate throttle metrics, we can alert on those if need be; our controller runtime filter
+	// should prevent a Reconcile when this label is set, but just in case, let's check here as well
+	trName, throttled := inMemoryThrottles.throttledBy(pr)
+	if throttled {
+		controllerLog.Info(fmt.Sprintf("Skipping overhead for pipelinerun %s:%s because taskrun %s was throttled", pr.Namespace, pr.Name, trName))
+		return GapSkipThrottled
+	}
+	return ""
+}
+
+// AccumulateGaps fetches the TaskRuns of a completed PipelineRun and calculates its gaps the way the overhead and gap
+// metrics do
+func AccumulateGaps(ctx context.Context, oc client.Client, pr *v1.PipelineRun) GapResult {
+	if reason := gapSkipReason(pr); len(reason) > 0 {
+		return GapResult{Entries: []GapEntry{}, Reason: reason}
+	}
+	sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes, abortReason := sortTaskRunsForGapCalculations(pr, oc, ctx, nil)
+	if len(abortReason) > 0 {
+		return GapResult{Entries: []GapEntry{}, Reason: abortReason}
+	}
+	return accumulate(pr, calculateGaps(pr, sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes))
+}
+
+// CalculateGaps calculates the gaps of a completed PipelineRun from its TaskRuns, in any order, for callers that
+// already have them, like from a must-gather; unlike AccumulateGaps, it does not check the TaskRuns belong to the
+// PipelineRun, nor skip throttled PipelineRuns
+func CalculateGaps(pr *v1.PipelineRun, taskRuns []*v1.TaskRun) GapResult {
+	if pr.Status.CompletionTime == nil {
+		return GapResult{Entries: []GapEntry{}, Reason: GapSkipNotFinished}
+	}
+	if len(taskRuns) == 0 {
+		return GapResult{Entries: []GapEntry{}, Reason: GapSkipNoTaskRuns}
+	}
+	sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes := sortTaskRuns(taskRuns)
+	return accumulate(pr, calculateGaps(pr, sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes))
+}
+
+func accumulate(pr *v1.PipelineRun, gapEntries []GapEntry) GapResult {
+	result := GapResult{Entries: gapEntries}
+	if pr.Status.StartTime != nil {
+		result.Duration = float64(pr.Status.CompletionTime.Time.Sub(pr.Status.StartTime.Time).Milliseconds())
+	}
+	for _, gapEntry := range gapEntries {
+		result.Total = result.Total + gapEntry.Gap
+	}
+	return result
+}
+
+// maxGapEntry is the longest of the gaps, false if there are none
+func maxGapEntry(gapEntries []GapEntry) (GapEntry, bool) {
+	if len(gapEntries) == 0 {
+		return GapEntry{}, false
+	}
+	maxGap := gapEntries[0]
+	for _, gapEntry := range gapEntries[1:] {
+		if gapEntry.Gap > maxGap.Gap {
+			maxGap = gapEntry
+		}
+	}
+	return maxGap, true
+}
+
+func calculateGaps(pr *v1.PipelineRun, sortedTaskRunsByCreateTimes []*v1.TaskRun, reverseOrderSortedTaskRunsByCompletionTimes []*v1.TaskRun) []GapEntry {
+	gapEntries := []GapEntry{}
+	prRef := pipelineRunPipelineRef(pr)
+	log := limitLog(controllerLog, LogCategoryGapCalculation)
+	for index, tr := range sortedTaskRunsByCreateTimes {
+		succeedCondition := pr.Status.GetCondition(apis.ConditionSucceeded)
+		if succeedCondition == nil {
+			log.Info(fmt.Sprintf("WARNING: pipielinerun %s:%s marked done but has nil succeed condition", pr.Namespace, pr.Name))
+			continue
+		}
+		if succeedCondition.IsUnknown() {
+			log.Info(fmt.Sprintf("WARNING: pipielinerun %s:%s marked done but has unknown succeed condition", pr.Namespace, pr.Name))
+			continue
+		}
+		gapEntry := GapEntry{}
+		gapEntry.Status = runStatus(succeedCondition)
+		gapEntry.Pipeline = prRef
+
+		if index == 0 {
+			// our first task is simple, just work off of the pipelinerun
+			gapEntry.Gap = float64(tr.CreationTimestamp.Time.Sub(pr.CreationTimestamp.Time).Milliseconds())
+			gapEntry.Completed = prRef
+			gapEntry.Upcoming = taskRef(tr.Labels)
+			gapEntry.Phase = GapPhaseKickoff
+			gapEntries = append(gapEntries, gapEntry)
+			log.V(6).Info(fmt.Sprintf("first task %s for pipeline %s has gap %v", taskRef(tr.Labels), prRef, gapEntry.Gap))
+			continue
+		}
+
+		firstKid := sortedTaskRunsByCreateTimes[0]
+
+		// so using the first taskrun completion time addresses sequential / chaining dependencies;
+		// for parallel, if the first taskrun's completion time is not after this taskrun's create time,
+		// that means parallel taskruns, and we work off of the pipelinerun; NOTE: this focuses on "top level" parallel task runs
+		// with absolutely no dependencies.  Once any sort of dependency is established, there are no more top level parallel taskruns.
+		if firstKid.Status.CompletionTime != nil && firstKid.Status.CompletionTime.Time.After(tr.CreationTimestamp.Time) {
+			log.V(4).Info(fmt.Sprintf("task %s considered parallel for pipeline %s", taskRef(tr.Labels), prRef))
+			gapEntry.Gap = float64(tr.CreationTimestamp.Time.Sub(pr.CreationTimestamp.Time).Milliseconds())
+			gapEntry.Completed = prRef
+			gapEntry.Upcoming = taskRef(tr.Labels)
+			gapEntry.Phase = GapPhaseKickoff
+			gapEntries = append(gapEntries, gapEntry)
+			continue
+		}
+
+		// Conversely, task run chains can run in parallel, and a taskrun can depend on multiple chains or threads of taskruns. We want to find the chain
+		// that finished last, but before we are created.  We traverse through our reverse sorted on completion time list to determine that.  But yes, we don't reproduce the DAG
+		// graph (there is no clean dependency import path in tekton for that) to confirm the edges.  This approximation is sufficient.
+
+		// get whatever completed first
+		timeToCalculateWith := time.Time{}
+		trToCalculateWith := &v1.TaskRun{}
+		completedID := prRef
+		phase := GapPhaseBetweenTaskRuns
+		if len(reverseOrderSortedTaskRunsByCompletionTimes) > 0 {
+			trToCalculateWith = reverseOrderSortedTaskRunsByCompletionTimes[len(reverseOrderSortedTaskRunsByCompletionTimes)-1]
+			completedID = taskRef(trToCalculateWith.Labels)
+			timeToCalculateWith = trToCalculateWith.Status.CompletionTime.Time
+		} else {
+			// if no taskruns completed, that means any taskruns created were created as part of the initial pipelinerun creation,
+			// so use the pipelinerun creation time
+			timeToCalculateWith = pr.CreationTimestamp.Time
+			phase = GapPhaseKickoff
+		}
+		for _, tr2 := range reverseOrderSortedTaskRunsByCompletionTimes {
+			if tr2.Name == tr.Name {
+				continue
+			}
+			log.V(8).Info(fmt.Sprintf("comparing candidate %s to current task %s", taskRef(tr2.Labels), taskRef(tr.Labels)))
+			if !tr2.Status.CompletionTime.Time.After(tr.CreationTimestamp.Time) {
+				log.V(8).Info(fmt.Sprintf("%s did not complete after so use it to compute gap for current task %s", taskRef(tr2.Labels), taskRef(tr.Labels)))
+				trToCalculateWith = tr2
+				completedID = taskRef(trToCalculateWith.Labels)
+				timeToCalculateWith = tr2.Status.CompletionTime.Time
+				phase = GapPhaseBetweenTaskRuns
+				break
+			}
+			log.V(8).Info(fmt.Sprintf("skipping %s as a gap candidate for current task %s is OK", taskRef(tr2.Labels), taskRef(tr.Labels)))
+		}
+		gapEntry.Gap = float64(tr.CreationTimestamp.Time.Sub(timeToCalculateWith).Milliseconds())
+		gapEntry.Completed = completedID
+		gapEntry.Upcoming = taskRef(tr.Labels)
+		gapEntry.Phase = phase
+		log.V(6).Info(fmt.Sprintf("gap entry completed %s upcoming %s gap %v", gapEntry.Completed, gapEntry.Upcoming, gapEntry.Gap))
+		gapEntries = append(gapEntries, gapEntry)
+	}
+	return gapEntries
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+	"time"
+)
+
+func TestCalculateGaps(t *testing.T) {
+	base := time.Now()
+	// in tens of seconds, so the PipelineRun is long enough for its overhead not to be filtered
+	at := func(tens int) *metav1.Time {
+		return &metav1.Time{Time: base.Add(time.Duration(tens) * 10 * time.Second)}
+	}
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1", CreationTimestamp: *at(0)},
+		Spec: v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "build-pipeline"}}}
+	pr.Status.StartTime = at(0)
+	pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+	taskRun := func(name string, created, completed int) *v1.TaskRun {
+		tr := &v1.TaskRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: name, CreationTimestamp: *at(created),
+			Labels: map[string]string{"tekton.dev/pipelineTask": name}}}
+		tr.Status.StartTime = at(created)
+		tr.Status.CompletionTime = at(completed)
+		return tr
+	}
+	// in any order
+	taskRuns := []*v1.TaskRun{taskRun("push", 65, 70), taskRun("clone", 1, 30), taskRun("build", 35, 60)}
+
+	result := CalculateGaps(pr, taskRuns)
+	assert.Equal(t, GapSkipNotFinished, result.Reason)
+	assert.False(t, result.Calculated())
+
+	pr.Status.CompletionTime = at(100)
+	result = CalculateGaps(pr, taskRuns)
+	assert.True(t, result.Calculated())
+	assert.Equal(t, []GapEntry{
+		{Status: SUCCEEDED, Pipeline: "build-pipeline", Completed: "build-pipeline", Upcoming: "clone", Gap: 10000, Phase: GapPhaseKickoff},
+		{Status: SUCCEEDED, Pipeline: "build-pipeline", Completed: "clone", Upcoming: "build", Gap: 50000, Phase: GapPhaseBetweenTaskRuns},
+		{Status: SUCCEEDED, Pipeline: "build-pipeline", Completed: "build", Upcoming: "push", Gap: 50000, Phase: GapPhaseBetweenTaskRuns},
+	}, result.Entries)
+	assert.Equal(t, float64(110000), result.Total)
+	maxGap, ok := maxGapEntry(result.Entries)
+	assert.True(t, ok)
+	assert.Equal(t, "build", maxGap.Upcoming)
+	_, ok = maxGapEntry(nil)
+	assert.False(t, ok)
+	assert.Equal(t, float64(1000000), result.Duration)
+	overhead, ok := result.Overhead()
+	assert.True(t, ok)
+	assert.Equal(t, 0.11, overhead)
+
+	// as they would be with a threshold above their duration
+	_, ok = result.OverheadAt(2000000)
+	assert.False(t, ok)
+
+	// short PipelineRuns are filtered
+	pr.Status.CompletionTime = at(20)
+	_, ok = CalculateGaps(pr, taskRuns).Overhead()
+	assert.False(t, ok)
+
+	assert.Equal(t, GapSkipNoTaskRuns, CalculateGaps(pr, nil).Reason)
+}
+
+func TestAccumulateGapsReasons(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	assert.Equal(t, GapSkipNoTaskRuns, AccumulateGaps(ctx, c, pr).Reason)
+	pr.Status.ChildReferences = []v1.ChildStatusReference{{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "test-1-tr"}}
+	assert.Equal(t, GapSkipNotFinished, AccumulateGaps(ctx, c, pr).Reason)
+	pr.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	assert.Equal(t, GapAbortTaskRunDeleted, AccumulateGaps(ctx, c, pr).Reason)
+}
@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/version"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	VERSION_LABEL       = "version"
+	TEKTON_API_LABEL    = "tekton_api_versions"
+	FEATURES_LABEL      = "features"
+	WATCHES_LABEL       = "watches"
+	WatchesSynced       = "synced"
+	WatchesNotSynced    = "not-synced"
+	heartbeatInterval   = 30 * time.Second
+	heartbeatSyncWait   = time.Second
+	featureBitTenant    = 0
+	featureBitTenantEP  = 1
+	featureBitFederate  = 2
+	featureBitRemediate = 3
+	featureBitDetectors = 4
+	featureBitReadOnly  = 5
+)
+
+func servedVersions(crd *apiextensionsv1.CustomResourceDefinition) []string {
+	served := []string{}
+	if crd == nil {
+		return served
+	}
+	for _, v := range crd.Spec.Versions {
+		if v.Served {
+			served = append(served, v.Name)
+		}
+	}
+	return served
+}
+
+type informerSource interface {
+	GetInformer(ctx context.Context, obj client.Object) (cache.Informer, error)
+}
+
+// heartbeat is a Runnable that regularly sets a gauge to the current unix time, with labels that allow the
+// fleet dashboards to find member clusters running stale versions, unexpected features, or degraded watches
+type heartbeat struct {
+	gauge     *prometheus.GaugeVec
+	informers informerSource
+	features  uint64
+	// last holds the labels of the series set by the prior beat
+	last prometheus.Labels
+}
+
+func NewHeartbeatMetric(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	labelNames := []string{VERSION_LABEL, TEKTON_API_LABEL, FEATURES_LABEL, WATCHES_LABEL}
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_exporter_heartbeat_timestamp_seconds",
+		Help: "Unix time of the exporter's last heartbeat, with labels for its version, the Tekton API versions served, the bitmap of enabled optional features, and whether its watches are synced",
+	}, labelNames)
+	registerer.MustRegister(gauge)
+	return gauge
+}
+
+// heartbeatFeatures is a bitmap where bit 0 is the tenant label, 1 the tenant endpoints, 2 the federation endpoint,
+// 3 remediation, 4 registered detectors, and 5 read-only mode
+func heartbeatFeatures(r *ExporterReconcile) uint64 {
+	features := uint64(0)
+	if tenants.enabled {
+		features |= 1 << featureBitTenant
+	}
+	if settings.TenantMetricsEndpoint {
+		features |= 1 << featureBitTenantEP
+	}
+	if settings.FederationEndpoint {
+		features |= 1 << featureBitFederate
+	}
+	if len(r.remediations) > 0 {
+		features |= 1 << featureBitRemediate
+	}
+	if len(r.registeredDetectors) > 0 {
+		features |= 1 << featureBitDetectors
+	}
+	if r.readOnly {
+		features |= 1 << featureBitReadOnly
+	}
+	return features
+}
+
+func (h *heartbeat) watchStatus(ctx context.Context) string {
+	// once the cache is started, GetInformer waits for the informer to sync, so the wait is bounded, else a watch
+	// that does not sync would either stall the heartbeat or be reported as synced once it finally did
+	syncCtx, cancel := context.WithTimeout(ctx, heartbeatSyncWait)
+	defer cancel()
+	for _, obj := range []client.Object{watchedPipelineRun(), watchedTaskRun(), &corev1.Pod{}} {
+		informer, err := h.informers.GetInformer(syncCtx, obj)
+		if err != nil || !informer.HasSynced() {
+			return WatchesNotSynced
+		}
+	}
+	return WatchesSynced
+}
+
+func (h *heartbeat) beat(ctx context.Context) {
+	labels := map[string]string{
+		VERSION_LABEL:    version.Version,
+		TEKTON_API_LABEL: strings.Join(tektonAPI.served(), ","),
+		FEATURES_LABEL:   fmt.Sprintf("%d", h.features),
+		WATCHES_LABEL:    h.watchStatus(ctx),
+	}
+	h.gauge.With(labels).Set(float64(exporterClock.Now().Unix()))
+	// only one series at a time, so a change in watch status does not leave a stale series behind; the prior series
+	// is deleted after the new one is set, vs. a Reset that would leave a scrape in between with no heartbeat at all
+	if h.last != nil && !sameLabels(h.last, labels) {
+		h.gauge.Delete(h.last)
+	}
+	h.last = labels
+}
+
+func (h *heartbeat) Start(ctx context.Context) error {
+	h.beat(ctx)
+	ticker := exporterClock.NewTicker(heartbeatInterval)
+	for {
+		select {
+		case <-ticker.C():
+			h.beat(ctx)
+		case <-ctx.Done():
+			controllerLog.Info("heartbeat Runnable context is marked as done, exiting")
+			ticker.Stop()
+			return nil
+		}
+	}
+}
+
+func sameLabels(a, b prometheus.Labels) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sscheme "k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"testing"
+	"time"
+)
+
+func TestServedVersions(t *testing.T) {
+	assert.Len(t, servedVersions(nil), 0)
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1", Served: true},
+				{Name: "v1beta1", Served: true},
+				{Name: "v1alpha1", Served: false},
+			},
+		},
+	}
+	assert.Equal(t, []string{"v1", "v1beta1"}, servedVersions(crd))
+}
+
+func TestHeartbeat(t *testing.T) {
+	defer tektonAPI.set([]string{})
+	tektonAPI.set([]string{"v1", "v1beta1"})
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	_ = k8sscheme.AddToScheme(scheme)
+	informers := &informertest.FakeInformers{Scheme: scheme}
+	r := &ExporterReconcile{readOnly: true, remediations: map[string]*remediationTracker{}}
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_heartbeat", Help: "test"},
+		[]string{VERSION_LABEL, TEKTON_API_LABEL, FEATURES_LABEL, WATCHES_LABEL})
+	hb := &heartbeat{gauge: gauge, informers: informers, features: heartbeatFeatures(r)}
+	ctx := context.TODO()
+
+	hb.beat(ctx)
+	labels := prometheus.Labels{VERSION_LABEL: "", TEKTON_API_LABEL: "v1,v1beta1", FEATURES_LABEL: "32", WATCHES_LABEL: WatchesNotSynced}
+	validateGaugeVecGreaterThanZero(t, gauge, labels)
+
+	for _, obj := range []runtime.Object{&v1.PipelineRun{}, &v1.TaskRun{}, &corev1.Pod{}} {
+		informer, err := informers.FakeInformerFor(obj)
+		assert.NoError(t, err)
+		informer.Synced = true
+	}
+	fakeClock := clocktesting.NewFakeClock(time.Unix(1700000000, 0))
+	defer setClock(fakeClock)()
+	hb.beat(ctx)
+	labels[WATCHES_LABEL] = WatchesSynced
+	validateGaugeVec(t, gauge, labels, float64(1700000000))
+	// the prior not-synced series is gone
+	count := make(chan prometheus.Metric, 10)
+	gauge.Collect(count)
+	close(count)
+	assert.Len(t, count, 1)
+}
+
+func validateGaugeVecGreaterThanZero(t *testing.T, g *prometheus.GaugeVec, labels prometheus.Labels) {
+	gauge, err := g.GetMetricWith(labels)
+	assert.NoError(t, err)
+	assert.NotNil(t, gauge)
+	m := &dto.Metric{}
+	assert.NoError(t, gauge.Write(m))
+	assert.Greater(t, m.GetGauge().GetValue(), float64(0))
+}
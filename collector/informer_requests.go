@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	RESOURCE_LABEL = "resource"
+	VERB_LABEL     = "verb"
+)
+
+// informerResources are the resources the informers of the manager's cache list and watch
+var informerResources = map[string]struct{}{
+	"pipelineruns": {},
+	"taskruns":     {},
+	"pods":         {},
+	"namespaces":   {},
+}
+
+func NewInformerRequestsMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_informer_lists_total",
+		Help: "Number of list requests of the informers for the resources the exporter caches; beyond the first of each at startup, they are relists",
+	}, []string{RESOURCE_LABEL})
+	registerer.MustRegister(requests)
+	return requests
+}
+
+// informerRequestCounter is a rest.Config WrapTransport counting the list requests of the informers, as client-go's
+// reflector metrics are no-ops, and relist storms are otherwise only visible in debug logs; it only wraps the config
+// of the manager's cache, so the lists of the exporter's clients and pollers are not counted, and the watch requests,
+// the informers' other collection GETs, are left out as well
+type informerRequestCounter struct {
+	requests *prometheus.CounterVec
+	next     http.RoundTripper
+}
+
+func countInformerRequests(requests *prometheus.CounterVec) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &informerRequestCounter{requests: requests, next: rt}
+	}
+}
+
+func (c *informerRequestCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet {
+		if resource, ok := collectionResource(req.URL.Path); ok && !watchRequest(req) {
+			c.requests.With(prometheus.Labels{RESOURCE_LABEL: resource}).Inc()
+		}
+	}
+	return c.next.RoundTrip(req)
+}
+
+func watchRequest(req *http.Request) bool {
+	watch := req.URL.Query().Get("watch")
+	return watch == "true" || watch == "1"
+}
+
+// collectionResource returns the resource of a request for a whole collection, cluster wide or in a namespace, if it
+// is one of the informerResources
+func collectionResource(path string) (string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(segments) > 2 && segments[0] == "api":
+		segments = segments[2:]
+	case len(segments) > 3 && segments[0] == "apis":
+		segments = segments[3:]
+	default:
+		return "", false
+	}
+	if len(segments) > 2 && segments[0] == "namespaces" {
+		segments = segments[2:]
+	}
+	if len(segments) != 1 {
+		return "", false
+	}
+	_, ok := informerResources[segments[0]]
+	return segments[0], ok
+}
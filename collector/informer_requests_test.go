@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionResource(t *testing.T) {
+	for _, test := range []struct {
+		path     string
+		resource string
+		ok       bool
+	}{
+		{path: "/api/v1/pods", resource: "pods", ok: true},
+		{path: "/api/v1/namespaces/test-namespace/pods", resource: "pods", ok: true},
+		{path: "/api/v1/namespaces", resource: "namespaces", ok: true},
+		{path: "/apis/tekton.dev/v1/pipelineruns", resource: "pipelineruns", ok: true},
+		{path: "/apis/tekton.dev/v1beta1/namespaces/test-namespace/taskruns", resource: "taskruns", ok: true},
+		{path: "/api/v1/namespaces/test-namespace", resource: "", ok: false},
+		{path: "/api/v1/namespaces/test-namespace/pods/test-pod", resource: "", ok: false},
+		{path: "/apis/config.openshift.io/v1/clusterversions", resource: "clusterversions", ok: false},
+		{path: "/healthz", resource: "", ok: false},
+	} {
+		resource, ok := collectionResource(test.path)
+		assert.Equal(t, test.ok, ok, test.path)
+		if ok {
+			assert.Equal(t, test.resource, resource, test.path)
+		}
+	}
+}
+
+func TestInformerRequestCounter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_informer_requests_total", Help: "test"},
+		[]string{RESOURCE_LABEL})
+	c := &http.Client{Transport: countInformerRequests(requests)(http.DefaultTransport)}
+	for _, path := range []string{
+		"/apis/tekton.dev/v1/pipelineruns?limit=500",
+		"/apis/tekton.dev/v1/pipelineruns?watch=true&resourceVersion=1",
+		"/apis/tekton.dev/v1/pipelineruns?watch=1&resourceVersion=2",
+		"/api/v1/namespaces/test-namespace/pods/test-pod",
+	} {
+		rsp, err := c.Get(server.URL + path)
+		assert.NoError(t, err)
+		rsp.Body.Close()
+	}
+	// the watches are not counted, only the list
+	validateCounterVec(t, requests, prometheus.Labels{RESOURCE_LABEL: "pipelineruns"}, float64(1))
+	validateCounterVec(t, requests, prometheus.Labels{RESOURCE_LABEL: "pods"}, float64(0))
+}
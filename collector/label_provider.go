@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RunLabelsEnvName is a comma separated list of label=key pairs, adding the label to the run level metrics with
+	// the value of the key's label, or else annotation, on the PipelineRun or TaskRun
+	RunLabelsEnvName = "RUN_LABELS"
+)
+
+// LabelProvider derives additional label values from the PipelineRun or TaskRun a run level metric is observed for,
+// like the scheduling duration, gap, and overhead metrics; only the label names allowed when the provider is set are
+// kept, and those not returned get the empty value, so the cardinality stays under the control of the deployment
+type LabelProvider interface {
+	Labels(run client.Object) map[string]string
+}
+
+// ObjectLabelProvider maps each label name to the key of the run's label, or else annotation, holding its value
+type ObjectLabelProvider map[string]string
+
+func (p ObjectLabelProvider) Labels(run client.Object) map[string]string {
+	labels := map[string]string{}
+	for name, key := range p {
+		if value, ok := run.GetLabels()[key]; ok {
+			labels[name] = value
+			continue
+		}
+		labels[name] = run.GetAnnotations()[key]
+	}
+	return labels
+}
+
+// ParseObjectLabelProvider parses a comma separated list of label=key pairs, skipping malformed entries
+func ParseObjectLabelProvider(setting string) ObjectLabelProvider {
+	p := ObjectLabelProvider{}
+	for _, entry := range strings.Split(setting, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		name, key, found := strings.Cut(entry, "=")
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		if !found || len(name) == 0 || len(key) == 0 {
+			controllerLog.Info(fmt.Sprintf("ignoring malformed %s entry %q", RunLabelsEnvName, entry))
+			continue
+		}
+		p[name] = key
+	}
+	return p
+}
+
+// runLabels is configured before any of the metrics are created, like tenants, as the allowed label names are part
+// of the label names of the run level metrics
+var runLabels = &runLabelResolver{}
+
+type runLabelResolver struct {
+	lock     sync.RWMutex
+	provider LabelProvider
+	names    []string
+}
+
+// reservedLabelNames are the labels the run level metrics already have, or may have
+var reservedLabelNames = map[string]struct{}{
+	NS_LABEL:      {},
+	STATUS_LABEL:  {},
+	TENANT_LABEL:  {},
+	CLUSTER_LABEL: {},
+	"le":          {},
+}
+
+func (r *runLabelResolver) configure(provider LabelProvider, allowed []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.provider = nil
+	r.names = []string{}
+	if provider == nil {
+		return
+	}
+	seen := map[string]struct{}{}
+	for _, name := range allowed {
+		_, reserved := reservedLabelNames[name]
+		_, dup := seen[name]
+		if reserved || dup || !model.LabelName(name).IsValid() {
+			controllerLog.Info(fmt.Sprintf("ignoring run label %q, as it is not a valid label name or is already used", name))
+			continue
+		}
+		seen[name] = struct{}{}
+		r.names = append(r.names, name)
+	}
+	if len(r.names) > 0 {
+		r.provider = provider
+	}
+}
+
+// withRunLabelNames should be used, after withTenantLabelName, when defining the label names of any run level metric
+func withRunLabelNames(labelNames []string) []string {
+	runLabels.lock.RLock()
+	defer runLabels.lock.RUnlock()
+	return append(labelNames, runLabels.names...)
+}
+
+// withRunLabels should be used when building the labels to observe a metric whose label names came from withRunLabelNames
+func withRunLabels(labels map[string]string, run client.Object) map[string]string {
+	runLabels.lock.RLock()
+	defer runLabels.lock.RUnlock()
+	if runLabels.provider == nil {
+		return labels
+	}
+	provided := runLabels.provider.Labels(run)
+	for _, name := range runLabels.names {
+		labels[name] = provided[name]
+	}
+	return labels
+}
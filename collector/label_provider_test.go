@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"testing"
+)
+
+func TestParseObjectLabelProvider(t *testing.T) {
+	p := ParseObjectLabelProvider(" application=appstudio.openshift.io/application, component = appstudio.openshift.io/component,garbage,=foo,")
+	assert.Equal(t, ObjectLabelProvider{
+		"application": "appstudio.openshift.io/application",
+		"component":   "appstudio.openshift.io/component",
+	}, p)
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+		Labels:      map[string]string{"appstudio.openshift.io/application": "app-1"},
+		Annotations: map[string]string{"appstudio.openshift.io/application": "app-2", "appstudio.openshift.io/component": "comp-1"},
+	}}
+	// labels win over annotations
+	assert.Equal(t, map[string]string{"application": "app-1", "component": "comp-1"}, p.Labels(pr))
+}
+
+func TestRunLabels(t *testing.T) {
+	defer runLabels.configure(nil, nil)
+	p := ObjectLabelProvider{"application": "appstudio.openshift.io/application", "component": "appstudio.openshift.io/component"}
+	runLabels.configure(p, nil)
+	assert.Equal(t, []string{NS_LABEL}, withRunLabelNames([]string{NS_LABEL}))
+
+	// only the allowed, valid, and unused label names are added; component is not allowed
+	runLabels.configure(p, []string{"application", NS_LABEL, "le", "not-valid", "application"})
+	labelNames := withRunLabelNames([]string{NS_LABEL, STATUS_LABEL})
+	assert.Equal(t, []string{NS_LABEL, STATUS_LABEL, "application"}, labelNames)
+
+	metric := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_run_labels_scheduled", Help: "test"}, labelNames)
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1",
+		Labels: map[string]string{"appstudio.openshift.io/application": "app-1", "appstudio.openshift.io/component": "comp-1"}}}
+	pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+	bumpPipelineRunScheduledDuration(float64(1), pr, metric)
+	validateHistogramVec(t, metric, prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED, "application": "app-1"}, false)
+
+	// runs without the label get the empty value
+	pr.Labels = nil
+	bumpPipelineRunScheduledDuration(float64(1), pr, metric)
+	validateHistogramVec(t, metric, prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED, "application": ""}, false)
+}
@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// Listener is where one of the exporter's own HTTP surfaces, the debug or admin one, binds, and how it is secured, so
+// each can be kept to localhost, or put behind TLS and authentication, independently of the metrics
+type Listener struct {
+	// Address is where the listener binds, like localhost:6060
+	Address string
+	// TLSConfig serves the listener over TLS vs. plain HTTP when set
+	TLSConfig *tls.Config
+	// Wrap wraps the handler of the listener when set, say to authenticate and authorize its callers
+	Wrap func(http.Handler) http.Handler
+	// Server builds the http.Server of the handler and TLSConfig when set, with its timeouts and limits, and any
+	// HTTP/2 added to the TLS config; an http.Server with only a ReadHeaderTimeout of 30s otherwise
+	Server func(handler http.Handler, tlsConfig *tls.Config) (*http.Server, error)
+}
+
+// debugHandler serves pprof, registered on a mux of its own vs. the default mux, so nothing else a dependency
+// registers with the default mux ends up on the debug listener
+func debugHandler() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// addAdminHandlers adds the JSON APIs over the recent runs, the disabled collectors, and the cache audit, and the daily
+// aggregates when they are persisted
+func addAdminHandlers(mux *http.ServeMux, r *ExporterReconcile) {
+	mux.Handle(RecentRunsPath, r.recentRuns)
+	// the collectors are only disabled on the leader, where they run
+	mux.Handle(CollectorsPath, leaderOnly(r.elected, collectorHealth))
+	if r.cacheAudit != nil {
+		mux.Handle(CacheAuditPath, r.cacheAudit)
+	}
+	if r.aggregates != nil {
+		mux.Handle(AggregatesPath, r.aggregates)
+	}
+}
+
+// listener is the runnable serving a Listener
+type listener struct {
+	name   string
+	config Listener
+	mux    *http.ServeMux
+}
+
+func (l *listener) handler() http.Handler {
+	if l.config.Wrap == nil {
+		return l.mux
+	}
+	return l.config.Wrap(l.mux)
+}
+
+func (l *listener) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", l.config.Address)
+	if err != nil {
+		return fmt.Errorf("unable to bind the %s listener: %w", l.name, err)
+	}
+	return l.serve(ctx, ln)
+}
+
+func (l *listener) serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{Handler: l.handler(), ReadHeaderTimeout: 30 * time.Second, TLSConfig: l.config.TLSConfig}
+	if l.config.Server != nil {
+		var err error
+		if srv, err = l.config.Server(l.handler(), l.config.TLSConfig); err != nil {
+			return fmt.Errorf("unable to set up the %s listener: %w", l.name, err)
+		}
+	}
+	if srv.TLSConfig != nil {
+		ln = tls.NewListener(ln, srv.TLSConfig)
+	}
+	controllerLog.Info(fmt.Sprintf("starting the %s listener on %s", l.name, ln.Addr().String()))
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			controllerLog.Info(fmt.Sprintf("%s server err: %s", l.name, err.Error()))
+		}
+	}()
+	<-ctx.Done()
+	controllerLog.Info(fmt.Sprintf("Shutting down the %s listener", l.name))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// NeedLeaderElection is false, so every replica serves its own debug and admin surfaces
+func (l *listener) NeedLeaderElection() bool {
+	return false
+}
+
+// leaderOnly serves next on the leader replica only, answering the others with a 503 so the caller retries, say through
+// the Service, until it reaches the leader, for the handlers whose state only the leader keeps
+func leaderOnly(elected <-chan struct{}, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if elected != nil {
+			select {
+			case <-elected:
+			default:
+				http.Error(w, "this replica is not the leader", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// listeners are the debug and admin listeners of the options; the admin APIs stay on the debug listener when the
+// admin one is not set, as they were served with pprof before the two were split, but the ingestion of external
+// timings, which writes into the metrics, is only ever served on the admin listener
+func (o *Options) listeners(r *ExporterReconcile) []*listener {
+	listeners := []*listener{}
+	debug := o.debugListener()
+	var debugMux *http.ServeMux
+	if debug != nil {
+		debugMux = debugHandler()
+		listeners = append(listeners, &listener{name: "debug", config: *debug, mux: debugMux})
+	}
+	switch {
+	case o.Admin != nil:
+		adminMux := http.NewServeMux()
+		addAdminHandlers(adminMux, r)
+		if r.timings != nil {
+			// the milestones are merged with the completions only the leader's filters see
+			adminMux.Handle(IngestTimingsPath, leaderOnly(r.elected, r.timings))
+		}
+		listeners = append(listeners, &listener{name: "admin", config: *o.Admin, mux: adminMux})
+	case debugMux != nil:
+		addAdminHandlers(debugMux, r)
+	}
+	return listeners
+}
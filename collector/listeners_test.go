@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func get(h http.Handler, path string) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Code
+}
+
+func TestListeners(t *testing.T) {
+	// nothing registered with the default mux is served
+	http.HandleFunc("/debug/default-mux", func(w http.ResponseWriter, _ *http.Request) {})
+	r := &ExporterReconcile{recentRuns: newRecentRunBuffer(1)}
+
+	assert.Empty(t, newOptions().listeners(r))
+
+	// the admin APIs stay with pprof without an admin listener
+	listeners := newOptions(WithPprofPort("6000")).listeners(r)
+	assert.Len(t, listeners, 1)
+	assert.Equal(t, ":6000", listeners[0].config.Address)
+	debug := listeners[0].handler()
+	assert.Equal(t, http.StatusOK, get(debug, "/debug/pprof/"))
+	assert.Equal(t, http.StatusOK, get(debug, RecentRunsPath))
+	assert.Equal(t, http.StatusNotFound, get(debug, AggregatesPath))
+	assert.Equal(t, http.StatusNotFound, get(debug, "/debug/default-mux"))
+
+	// the timings are only ingested on the admin listener
+	r.timings = newTimingStore(nil, nil)
+	debug = newOptions(WithPprofPort("6000")).listeners(r)[0].handler()
+	assert.Equal(t, http.StatusNotFound, get(debug, IngestTimingsPath))
+	admin := newOptions(WithAdminListener(Listener{Address: "localhost:6061"})).listeners(r)[0].handler()
+	assert.Equal(t, http.StatusMethodNotAllowed, get(admin, IngestTimingsPath))
+	r.timings = nil
+
+	wrapped := 0
+	listeners = newOptions(WithPprofPort("6000"), WithDebugListener(Listener{Address: "localhost:6060"}), WithAdminListener(Listener{
+		Address: ":6061",
+		Wrap: func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				wrapped++
+				next.ServeHTTP(w, r)
+			})
+		},
+	})).listeners(r)
+	assert.Len(t, listeners, 2)
+	assert.Equal(t, "localhost:6060", listeners[0].config.Address)
+	debug, admin = listeners[0].handler(), listeners[1].handler()
+	assert.Equal(t, http.StatusOK, get(debug, "/debug/pprof/"))
+	assert.Equal(t, http.StatusNotFound, get(debug, RecentRunsPath))
+	assert.Equal(t, 0, wrapped)
+	assert.Equal(t, http.StatusNotFound, get(admin, "/debug/pprof/"))
+	assert.Equal(t, http.StatusOK, get(admin, RecentRunsPath))
+	assert.Equal(t, 2, wrapped)
+
+	// the admin listener on its own
+	listeners = newOptions(WithAdminListener(Listener{Address: "localhost:6061"})).listeners(r)
+	assert.Len(t, listeners, 1)
+	assert.Equal(t, "admin", listeners[0].name)
+}
+
+func TestListenerServe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	l := &listener{name: "debug", mux: debugHandler()}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- l.serve(ctx, ln) }()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/debug/pprof/cmdline")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.False(t, l.NeedLeaderElection())
+
+	// the server of the listener comes with its timeouts
+	ln, err = net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	built := make(chan *http.Server, 1)
+	l.config.Server = func(handler http.Handler, tlsConfig *tls.Config) (*http.Server, error) {
+		srv := &http.Server{Handler: handler, TLSConfig: tlsConfig, WriteTimeout: time.Minute}
+		built <- srv
+		return srv, nil
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() { done <- l.serve(ctx, ln) }()
+	resp, err = http.Get("http://" + ln.Addr().String() + "/debug/pprof/cmdline")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	assert.Equal(t, time.Minute, (<-built).WriteTimeout)
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,198 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	// LogRateLimitsEnvName is a comma separated list of <log category>=<lines per second>[/<burst>] pairs, rate
+	// limiting the category's log lines with a token bucket; the burst defaults to the lines per second, rounded up
+	LogRateLimitsEnvName = "LOG_RATE_LIMITS"
+	// LogSamplingEnvName is a comma separated list of <log category>=<n> pairs, only logging one in every n of the
+	// category's log lines, before any rate limit
+	LogSamplingEnvName = "LOG_SAMPLING"
+
+	CATEGORY_LABEL = "category"
+
+	// LogCategoryGapCalculation are the per TaskRun logs of the gap calculation, mostly at V(4) to V(8)
+	LogCategoryGapCalculation = "gap-calculation"
+	// LogCategoryOverheadAlert are the gap breakdowns logged for PipelineRuns with alert level execution overhead
+	LogCategoryOverheadAlert = "overhead-alert"
+	// LogCategorySkippedEvents are the warnings logged for skipped events
+	LogCategorySkippedEvents = "skipped-events"
+)
+
+var logCategories = []string{LogCategoryGapCalculation, LogCategoryOverheadAlert, LogCategorySkippedEvents}
+
+func knownLogCategory(category string) bool {
+	for _, known := range logCategories {
+		if known == category {
+			return true
+		}
+	}
+	return false
+}
+
+// categoryLimit is the sampling and rate limit of one log category; either may be unset
+type categoryLimit struct {
+	sample  uint64
+	seen    uint64
+	limiter flowcontrol.RateLimiter
+}
+
+// parseLogLimits parses the LogRateLimitsEnvName and LogSamplingEnvName settings, leaving out malformed entries
+func parseLogLimits(rateLimits, sampling string) (map[string]*categoryLimit, []SettingsProblem) {
+	limits := map[string]*categoryLimit{}
+	problems := []SettingsProblem{}
+	limitFor := func(category string) *categoryLimit {
+		if _, ok := limits[category]; !ok {
+			limits[category] = &categoryLimit{}
+		}
+		return limits[category]
+	}
+	for _, entry := range splitEntries(rateLimits) {
+		category, limit, found := strings.Cut(entry, "=")
+		category, limit = strings.TrimSpace(category), strings.TrimSpace(limit)
+		qpsSetting, burstSetting, hasBurst := strings.Cut(limit, "/")
+		qps, err := strconv.ParseFloat(strings.TrimSpace(qpsSetting), 32)
+		if !found || len(category) == 0 || err != nil || qps <= 0 {
+			problems = append(problems, SettingsProblem{EnvName: LogRateLimitsEnvName,
+				Message: fmt.Sprintf("ignoring malformed entry %q, it must be category=lines per second[/burst]", entry)})
+			continue
+		}
+		burst := int(qps)
+		if float64(burst) < qps {
+			burst++
+		}
+		if hasBurst {
+			burst, err = strconv.Atoi(strings.TrimSpace(burstSetting))
+			if err != nil || burst <= 0 {
+				problems = append(problems, SettingsProblem{EnvName: LogRateLimitsEnvName,
+					Message: fmt.Sprintf("ignoring malformed entry %q, the burst must be a positive integer", entry)})
+				continue
+			}
+		}
+		if !knownLogCategory(category) {
+			problems = append(problems, SettingsProblem{EnvName: LogRateLimitsEnvName, Warning: true,
+				Message: fmt.Sprintf("%s is not a log category, it must be one of %s", category, strings.Join(logCategories, ", "))})
+		}
+		limitFor(category).limiter = flowcontrol.NewTokenBucketRateLimiter(float32(qps), burst)
+	}
+	for _, entry := range splitEntries(sampling) {
+		category, n, found := strings.Cut(entry, "=")
+		category = strings.TrimSpace(category)
+		sample, err := strconv.ParseUint(strings.TrimSpace(n), 10, 64)
+		if !found || len(category) == 0 || err != nil || sample == 0 {
+			problems = append(problems, SettingsProblem{EnvName: LogSamplingEnvName,
+				Message: fmt.Sprintf("ignoring malformed entry %q, it must be category=n, with n a positive integer", entry)})
+			continue
+		}
+		if !knownLogCategory(category) {
+			problems = append(problems, SettingsProblem{EnvName: LogSamplingEnvName, Warning: true,
+				Message: fmt.Sprintf("%s is not a log category, it must be one of %s", category, strings.Join(logCategories, ", "))})
+		}
+		limitFor(category).sample = sample
+	}
+	return limits, problems
+}
+
+// logLimiter samples and rate limits the high volume log categories, so turning up the verbosity in production does not
+// flood the log store; the dropped lines are counted, so it is clear when the logs are incomplete
+type logLimiter struct {
+	lock    sync.Mutex
+	limits  map[string]*categoryLimit
+	dropped *prometheus.CounterVec
+}
+
+var logLimits = &logLimiter{limits: map[string]*categoryLimit{}}
+
+func NewDroppedLogLinesMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_log_lines_dropped_total",
+		Help: "Number of log lines dropped by the sampling or rate limit of their category",
+	}, []string{CATEGORY_LABEL})
+	registerer.MustRegister(dropped)
+	return dropped
+}
+
+// configure replaces the limits, logging any problems with the settings
+func (l *logLimiter) configure(rateLimits, sampling string) {
+	limits, problems := parseLogLimits(rateLimits, sampling)
+	for _, problem := range problems {
+		controllerLog.Info(problem.Error())
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.limits = limits
+}
+
+func (l *logLimiter) enable(dropped *prometheus.CounterVec) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.dropped = dropped
+}
+
+// allow is whether the next log line of the category is logged, counting it as dropped if not
+func (l *logLimiter) allow(category string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	limit, ok := l.limits[category]
+	if !ok {
+		return true
+	}
+	allowed := true
+	if limit.sample > 1 {
+		allowed = limit.seen%limit.sample == 0
+		limit.seen++
+	}
+	if allowed && limit.limiter != nil {
+		allowed = limit.limiter.TryAccept()
+	}
+	if !allowed && l.dropped != nil {
+		l.dropped.With(map[string]string{CATEGORY_LABEL: category}).Inc()
+	}
+	return allowed
+}
+
+// limitedLogSink only passes on the info lines its category allows; as the logger only calls Info when the level is
+// enabled, lines above the verbosity do not use up the rate limit
+type limitedLogSink struct {
+	logr.LogSink
+	category string
+}
+
+func (s *limitedLogSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	if !logLimits.allow(s.category) {
+		return
+	}
+	s.LogSink.Info(level, msg, keysAndValues...)
+}
+
+func (s *limitedLogSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &limitedLogSink{LogSink: s.LogSink.WithValues(keysAndValues...), category: s.category}
+}
+
+func (s *limitedLogSink) WithName(name string) logr.LogSink {
+	return &limitedLogSink{LogSink: s.LogSink.WithName(name), category: s.category}
+}
+
+// limitLog is the logger with the info lines sampled and rate limited as configured for the category; errors are
+// always logged
+func limitLog(log logr.Logger, category string) logr.Logger {
+	sink := log.GetSink()
+	if sink == nil {
+		return log
+	}
+	// so the caller of Info is reported vs. limitedLogSink
+	if withCallDepth, ok := sink.(logr.CallDepthLogSink); ok {
+		sink = withCallDepth.WithCallDepth(1)
+	}
+	return log.WithSink(&limitedLogSink{LogSink: sink, category: category})
+}
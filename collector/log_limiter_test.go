@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogLimits(t *testing.T) {
+	limits, problems := parseLogLimits("gap-calculation=5/20, overhead-alert=0.5", "gap-calculation=10")
+	assert.Empty(t, problems)
+	assert.Len(t, limits, 2)
+	assert.Equal(t, uint64(10), limits[LogCategoryGapCalculation].sample)
+	assert.NotNil(t, limits[LogCategoryGapCalculation].limiter)
+	assert.Equal(t, uint64(0), limits[LogCategoryOverheadAlert].sample)
+
+	limits, problems = parseLogLimits("gap-calculation=fast,overhead-alert=1/none,other=1", "skipped-events=0,missing")
+	byName := problemsOf(problems)
+	// the malformed rate and burst, and the unknown category warning
+	assert.Len(t, byName[LogRateLimitsEnvName], 3)
+	assert.True(t, byName[LogRateLimitsEnvName][2].Warning)
+	assert.Len(t, byName[LogSamplingEnvName], 2)
+	assert.Len(t, limits, 1)
+}
+
+func TestLogLimiter(t *testing.T) {
+	defer logLimits.configure("", "")
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_log_lines_dropped_total", Help: "test"},
+		[]string{CATEGORY_LABEL})
+	logLimits.enable(dropped)
+	defer logLimits.enable(nil)
+
+	// one in every 3 lines, with the first logged
+	logLimits.configure("", "gap-calculation=3")
+	allowed := []bool{}
+	for i := 0; i < 6; i++ {
+		allowed = append(allowed, logLimits.allow(LogCategoryGapCalculation))
+	}
+	assert.Equal(t, []bool{true, false, false, true, false, false}, allowed)
+	validateCounterVec(t, dropped, prometheus.Labels{CATEGORY_LABEL: LogCategoryGapCalculation}, float64(4))
+	assert.True(t, logLimits.allow(LogCategoryOverheadAlert))
+
+	// a burst of 2, refilled far slower than the test runs
+	logLimits.configure("overhead-alert=0.001/2", "")
+	lines := []string{}
+	log := limitLog(funcr.New(func(prefix, args string) { lines = append(lines, args) }, funcr.Options{}), LogCategoryOverheadAlert)
+	for i := 0; i < 4; i++ {
+		log.WithValues("line", i).Info("alert")
+	}
+	log.Error(nil, "errors are not limited")
+	assert.Len(t, lines, 3)
+	validateCounterVec(t, dropped, prometheus.Labels{CATEGORY_LABEL: LogCategoryOverheadAlert}, float64(2))
+}
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// MarkerTTLEnvName is how long after their creation the completed objects keep the labels and annotations the
+	// exporter wrote on them, before the janitor removes them; not set, or 0, never removes them
+	MarkerTTLEnvName = "MARKER_TTL"
+
+	// markerSweepEvery is how often the janitor looks for the markers past the TTL
+	markerSweepEvery = time.Hour
+
+	// markerPatchesPerSweep caps the objects a sweep cleans, the backlog of the first sweep is cleaned over the
+	// next ones
+	markerPatchesPerSweep = 500
+)
+
+// exporterMarker is a label or annotation the exporter writes on the objects of the tenants
+type exporterMarker struct {
+	key   string
+	label bool
+}
+
+var (
+	throttledMarker = exporterMarker{key: THROTTLED_LABEL, label: true}
+	deadlockMarker  = exporterMarker{key: DEADLOCK_DETECTED_ANNOTATION}
+)
+
+// markerJanitor removes the markers the exporter wrote from the objects which completed, and were created longer
+// than the TTL ago, so they do not linger on the tenants' objects after the feature which wrote them was turned off,
+// or the exporter rolled back; the markers of the objects still running are left alone, as the collectors and the
+// remediation still go by them
+type markerJanitor struct {
+	client client.Client
+	ttl    time.Duration
+	// pace holds the patches to half of the API write budget, so the throttled label patches keep the other half;
+	// nil without a budget
+	pace flowcontrol.RateLimiter
+}
+
+func newMarkerJanitor(c client.Client, ttl time.Duration, writeBudget int) *markerJanitor {
+	j := &markerJanitor{client: c, ttl: ttl}
+	if writeBudget > 0 {
+		j.pace = flowcontrol.NewTokenBucketRateLimiter(float32(writeBudget)/120, 1)
+	}
+	return j
+}
+
+// sweep removes the markers past the TTL from at most markerPatchesPerSweep objects, returning how many it cleaned
+func (j *markerJanitor) sweep(ctx context.Context, now time.Time) int {
+	cleaned := 0
+	stopped := false
+	// try cleans obj, unless the sweep is over its cap, or was stopped by the write budget or the context
+	try := func(obj client.Object, markers ...exporterMarker) {
+		if stopped || cleaned >= markerPatchesPerSweep {
+			return
+		}
+		done, err := j.clean(ctx, obj, now, markers...)
+		if done {
+			cleaned++
+		}
+		stopped = err != nil
+	}
+	prList := &v1.PipelineRunList{}
+	if err := j.client.List(ctx, prList); err != nil {
+		controllerLog.Error(err, "pipeline run query for the marker janitor failed with an error")
+	}
+	for i := range prList.Items {
+		pr := &prList.Items[i]
+		if pr.IsDone() {
+			try(pr, throttledMarker, deadlockMarker)
+		}
+	}
+	trList := &v1.TaskRunList{}
+	if err := j.client.List(ctx, trList); err != nil {
+		controllerLog.Error(err, "task run query for the marker janitor failed with an error")
+	}
+	for i := range trList.Items {
+		tr := &trList.Items[i]
+		if tr.IsDone() {
+			try(tr, deadlockMarker)
+		}
+	}
+	podList := &corev1.PodList{}
+	if err := j.client.List(ctx, podList); err != nil {
+		controllerLog.Error(err, "pod query for the marker janitor failed with an error")
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			try(pod, deadlockMarker)
+		}
+	}
+	return cleaned
+}
+
+// clean removes the markers from obj, when it was created longer than the TTL ago, with one patch, auditing it; the
+// error is only returned when the sweep should stop, on a denial of the write budget or the context ending, without an
+// audit record, as nothing was attempted
+func (j *markerJanitor) clean(ctx context.Context, obj client.Object, now time.Time, markers ...exporterMarker) (bool, error) {
+	if now.Sub(obj.GetCreationTimestamp().Time) <= j.ttl {
+		return false, nil
+	}
+	changed := obj.DeepCopyObject().(client.Object)
+	labels := changed.GetLabels()
+	annotations := changed.GetAnnotations()
+	removed := map[string]string{}
+	for _, m := range markers {
+		values := annotations
+		if m.label {
+			values = labels
+		}
+		if value, ok := values[m.key]; ok {
+			removed[m.key] = value
+			delete(values, m.key)
+		}
+	}
+	if len(removed) == 0 {
+		return false, nil
+	}
+	if j.pace != nil {
+		if err := j.pace.Wait(ctx); err != nil {
+			return false, err
+		}
+	}
+	changed.SetLabels(labels)
+	changed.SetAnnotations(annotations)
+	rec := AuditRecord{
+		Who:    "janitor/markers",
+		What:   "remove-markers",
+		Target: fmt.Sprintf("%s %s/%s uid %s", resourceOf(obj), obj.GetNamespace(), obj.GetName(), obj.GetUID()),
+		Old:    fmt.Sprintf("%v", removed),
+	}
+	err := j.client.Patch(ctx, changed, client.MergeFrom(obj))
+	if writeBudgetExceeded(err) {
+		// the next sweep picks up where this one stopped
+		return false, err
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		// the next sweep tries again
+		rec.Outcome, rec.Err = AuditOutcomeFailure, err
+		Audit(rec)
+		return false, nil
+	}
+	rec.Outcome = AuditOutcomeSuccess
+	Audit(rec)
+	return err == nil, nil
+}
+
+// Start sweeps right away, for the markers left behind while the exporter was down or rolled back, then every hour
+func (j *markerJanitor) Start(ctx context.Context) error {
+	ticker := exporterClock.NewTicker(markerSweepEvery)
+	defer ticker.Stop()
+	for {
+		if cleaned := j.sweep(ctx, exporterClock.Now()); cleaned > 0 {
+			controllerLog.Info(fmt.Sprintf("the marker janitor removed the exporter's markers from %d objects", cleaned))
+		}
+		select {
+		case <-ticker.C():
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// NeedLeaderElection is true, as the replicas would otherwise patch the same objects
+func (j *markerJanitor) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMarkerJanitor(t *testing.T) {
+	lines, _ := captureAudits(t)
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+	now := time.Now()
+	pipelineRun := func(name string, created time.Time, done bool) *v1.PipelineRun {
+		pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "test-namespace",
+			Name:              name,
+			CreationTimestamp: metav1.Time{Time: created},
+			Labels:            map[string]string{THROTTLED_LABEL: "test-taskrun", "tenant-label": "kept"},
+			Annotations:       map[string]string{DEADLOCK_DETECTED_ANNOTATION: PipelineRunKickoffDetectorName},
+		}}
+		if done {
+			pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+		}
+		return pr
+	}
+	old := pipelineRun("old", now.Add(-48*time.Hour), true)
+	recent := pipelineRun("recent", now.Add(-time.Hour), true)
+	running := pipelineRun("running", now.Add(-48*time.Hour), false)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "old-pod",
+		CreationTimestamp: metav1.Time{Time: now.Add(-48 * time.Hour)},
+		Annotations:       map[string]string{DEADLOCK_DETECTED_ANNOTATION: PodCreateAttemptDetectorName}},
+		Status: corev1.PodStatus{Phase: corev1.PodFailed}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(old, recent, running, pod).Build()
+	ctx := context.TODO()
+	janitor := newMarkerJanitor(c, 24*time.Hour, 0)
+
+	assert.Equal(t, 2, janitor.sweep(ctx, now))
+	assert.Len(t, *lines, 2)
+	assert.Contains(t, (*lines)[0], `"target"="PipelineRun test-namespace/old uid `)
+	got := &v1.PipelineRun{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(old), got))
+	assert.NotContains(t, got.Labels, THROTTLED_LABEL)
+	assert.NotContains(t, got.Annotations, DEADLOCK_DETECTED_ANNOTATION)
+	assert.Equal(t, "kept", got.Labels["tenant-label"])
+	gotPod := &corev1.Pod{}
+	assert.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pod), gotPod))
+	assert.NotContains(t, gotPod.Annotations, DEADLOCK_DETECTED_ANNOTATION)
+	// the recent and the running PipelineRuns keep their markers
+	for _, pr := range []*v1.PipelineRun{recent, running} {
+		assert.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pr), got))
+		assert.Contains(t, got.Labels, THROTTLED_LABEL)
+	}
+
+	// with the markers gone, there is nothing left to clean
+	assert.Equal(t, 0, janitor.sweep(ctx, now))
+}
+
+func TestMarkerJanitorLimits(t *testing.T) {
+	lines, _ := captureAudits(t)
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	now := time.Now()
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for i := 0; i < markerPatchesPerSweep+3; i++ {
+		builder = builder.WithObjects(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: fmt.Sprintf("pod-%d", i),
+			CreationTimestamp: metav1.Time{Time: now.Add(-48 * time.Hour)},
+			Annotations:       map[string]string{DEADLOCK_DETECTED_ANNOTATION: PodCreateAttemptDetectorName}},
+			Status: corev1.PodStatus{Phase: corev1.PodFailed}})
+	}
+	c := budgetWrites(builder.Build())
+	ctx := context.TODO()
+	janitor := newMarkerJanitor(c, 24*time.Hour, 0)
+
+	// a sweep over the write budget stops, without failure records, and the next one picks up where it stopped
+	apiWrites.enable(NewAPIWritesMetric(prometheus.NewRegistry()), 2)
+	defer apiWrites.enable(nil, 0)
+	assert.Equal(t, 2, janitor.sweep(ctx, now))
+	assert.Len(t, *lines, 2)
+
+	// a sweep cleans at most markerPatchesPerSweep objects, leaving the rest to the next sweep
+	apiWrites.enable(nil, 0)
+	assert.Equal(t, markerPatchesPerSweep, janitor.sweep(ctx, now))
+	assert.Equal(t, 1, janitor.sweep(ctx, now))
+}
@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// The metric compat levels; metrics are only renamed, or have their label values changed, under a new stable name,
+// with the legacy name published alongside it at MetricCompatBoth, so dashboards and alerts can be migrated before
+// the legacy name is dropped
+const (
+	// MetricCompatLegacy only publishes the legacy names and label values
+	MetricCompatLegacy = 0
+	// MetricCompatBoth publishes both the legacy and the stable names, the legacy ones marked deprecated in their help
+	MetricCompatBoth = 1
+	// MetricCompatStable only publishes the stable names and label values
+	MetricCompatStable = 2
+
+	// SUCCEEDED_STABLE is the status label value of successful runs on the stable metric names
+	SUCCEEDED_STABLE = "succeeded"
+)
+
+// stableLabelValues are the label values fixed on the stable metric names
+var stableLabelValues = map[string]map[string]string{
+	STATUS_LABEL: {SUCCEEDED: SUCCEEDED_STABLE},
+}
+
+// deprecatedHelp marks the help of a metric with a stable name as deprecated, when both names are published
+func deprecatedHelp(help, stableName string) string {
+	if settings.MetricCompatLevel != MetricCompatBoth {
+		return help
+	}
+	return fmt.Sprintf("DEPRECATED: use %s. %s", stableName, help)
+}
+
+// aliasedCollector publishes the metrics of a collector under its legacy name, its stable name, or both
+type aliasedCollector struct {
+	legacy prometheus.Collector
+	stable *prometheus.Desc
+	level  int
+}
+
+// withStableName should be used when registering a metric that has been renamed; labelNames are its variable labels
+func withStableName(legacy prometheus.Collector, stableName, help string, labelNames []string) prometheus.Collector {
+	if settings.MetricCompatLevel <= MetricCompatLegacy {
+		return legacy
+	}
+	return &aliasedCollector{
+		legacy: legacy,
+		stable: prometheus.NewDesc(stableName, help, labelNames, nil),
+		level:  settings.MetricCompatLevel,
+	}
+}
+
+func (a *aliasedCollector) Describe(ch chan<- *prometheus.Desc) {
+	if a.level < MetricCompatStable {
+		a.legacy.Describe(ch)
+	}
+	ch <- a.stable
+}
+
+func (a *aliasedCollector) Collect(ch chan<- prometheus.Metric) {
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		a.legacy.Collect(metrics)
+		close(metrics)
+	}()
+	for m := range metrics {
+		if a.level < MetricCompatStable {
+			ch <- m
+		}
+		ch <- &stableMetric{desc: a.stable, legacy: m}
+	}
+}
+
+type stableMetric struct {
+	desc   *prometheus.Desc
+	legacy prometheus.Metric
+}
+
+func (m *stableMetric) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+func (m *stableMetric) Write(out *dto.Metric) error {
+	err := m.legacy.Write(out)
+	if err != nil {
+		return err
+	}
+	// the label pairs are shared with the legacy metric, so they are copied vs. changed in place
+	labels := make([]*dto.LabelPair, 0, len(out.Label))
+	for _, label := range out.Label {
+		value := label.GetValue()
+		if stable, ok := stableLabelValues[label.GetName()][value]; ok {
+			value = stable
+		}
+		labels = append(labels, &dto.LabelPair{Name: proto.String(label.GetName()), Value: proto.String(value)})
+	}
+	out.Label = labels
+	return nil
+}
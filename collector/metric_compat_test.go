@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func gatherFamilies(t *testing.T, registry *prometheus.Registry) map[string]*dto.MetricFamily {
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	byName := map[string]*dto.MetricFamily{}
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	return byName
+}
+
+func statusLabel(family *dto.MetricFamily) string {
+	for _, label := range family.GetMetric()[0].GetLabel() {
+		if label.GetName() == STATUS_LABEL {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+func TestMetricCompatLevels(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		level      int
+		legacy     bool
+		stable     bool
+		deprecated bool
+	}{
+		{name: "legacy", level: MetricCompatLegacy, legacy: true},
+		{name: "both", level: MetricCompatBoth, legacy: true, stable: true, deprecated: true},
+		{name: "stable", level: MetricCompatStable, stable: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			defer setSettings(Settings{MetricCompatLevel: test.level})()
+			registry := prometheus.NewRegistry()
+			c := NewOverheadCollector(registry)
+			c.execution.With(prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED}).Observe(0.1)
+
+			families := gatherFamilies(t, registry)
+			legacy, hasLegacy := families["pipeline_service_execution_overhead_percentage"]
+			stable, hasStable := families["pipeline_service_execution_overhead_ratio"]
+			assert.Equal(t, test.legacy, hasLegacy)
+			assert.Equal(t, test.stable, hasStable)
+			if hasLegacy {
+				assert.Equal(t, test.deprecated, strings.HasPrefix(legacy.GetHelp(), "DEPRECATED: use pipeline_service_execution_overhead_ratio."))
+				assert.Equal(t, SUCCEEDED, statusLabel(legacy))
+			}
+			if hasStable {
+				assert.False(t, strings.HasPrefix(stable.GetHelp(), "DEPRECATED"))
+				assert.Equal(t, SUCCEEDED_STABLE, statusLabel(stable))
+				assert.Equal(t, uint64(1), stable.GetMetric()[0].GetHistogram().GetSampleCount())
+			}
+
+			c.Close()
+			assert.Len(t, gatherFamilies(t, registry), 0)
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// snapshotSeries is the state of a series of a counter, gauge or histogram at shutdown
+type snapshotSeries struct {
+	Desc      string
+	Labels    map[string]string
+	Counter   *float64           `json:",omitempty"`
+	Gauge     *float64           `json:",omitempty"`
+	Histogram *snapshotHistogram `json:",omitempty"`
+}
+
+type snapshotHistogram struct {
+	Count   uint64
+	Sum     float64
+	Bounds  []float64
+	Buckets []uint64
+}
+
+type snapshotFile struct {
+	Taken time.Time
+	// Generation goes up by one with each restore, so a file written by an exporter older than the last restore, like
+	// the one being replaced in a rolling update, is told apart from the one restored and rejected
+	Generation uint64
+	Series     []*snapshotSeries
+}
+
+const (
+	// snapshotEvery is how often the snapshot is written while running, so a crash or OOM kill loses at most that
+	// much vs. everything since the previous shutdown
+	snapshotEvery = time.Minute
+	// restoredSuffix is appended to the snapshot once restored, so it is not restored a second time on top of counters
+	// that already moved when the exporter exits before writing its own
+	restoredSuffix = ".restored"
+)
+
+// metricSnapshot restores the counters and histograms of the exporter from the file last written, so
+// they carry on from their previous values vs. resetting on every deployment; a restored counter or histogram series
+// is the restored value plus whatever is observed since, while a restored gauge series is only reported until its
+// collector reports any series of that gauge, as gauges describe the current state
+type metricSnapshot struct {
+	lock sync.Mutex
+	path string
+	// generation is the one written in the snapshot, one past the restored one
+	generation uint64
+	// restored are the series read at startup, by descriptor and seriesKey
+	restored map[string]map[string]*snapshotSeries
+	// collectors are those registered while the snapshot is enabled, by the IDs of their descriptors
+	collectors map[string]*restoringCollector
+}
+
+var metricSnapshots = &metricSnapshot{}
+
+// configure reads the snapshot at path, once per path, with an empty path turning the snapshot off; a missing,
+// unreadable or stale snapshot is not an error, as the metrics then start from zero like without a snapshot
+func (s *metricSnapshot) configure(path string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if path == s.path {
+		return
+	}
+	s.path = path
+	s.generation = 1
+	s.restored = map[string]map[string]*snapshotSeries{}
+	s.collectors = map[string]*restoringCollector{}
+	if len(path) == 0 {
+		return
+	}
+	// the last one restored tells which generation the file must be past
+	last, err := readSnapshot(path + restoredSuffix)
+	if err == nil {
+		s.generation = last.Generation + 1
+	}
+	file, err := readSnapshot(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err == nil && last != nil && file.Generation <= last.Generation {
+		err = fmt.Errorf("its generation %d was already restored", file.Generation)
+	}
+	if err == nil {
+		// renamed before restoring, as a snapshot we could not set aside could be restored twice
+		err = os.Rename(path, path+restoredSuffix)
+	}
+	if err != nil {
+		controllerLog.Info(fmt.Sprintf("metrics start from zero, as the snapshot %s could not be restored: %s", path, err.Error()))
+		return
+	}
+	s.generation = file.Generation + 1
+	for _, series := range file.Series {
+		if _, ok := s.restored[series.Desc]; !ok {
+			s.restored[series.Desc] = map[string]*snapshotSeries{}
+		}
+		s.restored[series.Desc][seriesKey(series.Desc, series.Labels)] = series
+	}
+	controllerLog.Info(fmt.Sprintf("restored %d series from the metric snapshot taken at %s", len(file.Series), file.Taken.Format(time.RFC3339)))
+}
+
+func readSnapshot(path string) (*snapshotFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &snapshotFile{}
+	if err = json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (s *metricSnapshot) enabled() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.path) > 0
+}
+
+func seriesKey(desc string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	key := desc
+	for _, name := range names {
+		key += fmt.Sprintf(",%s=%q", name, labels[name])
+	}
+	return key
+}
+
+func labelsOf(m *dto.Metric) map[string]string {
+	labels := map[string]string{}
+	for _, pair := range m.GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	return labels
+}
+
+func describe(c prometheus.Collector) []*prometheus.Desc {
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	descs := []*prometheus.Desc{}
+	for desc := range ch {
+		descs = append(descs, desc)
+	}
+	return descs
+}
+
+func descriptorsKey(descs []*prometheus.Desc) string {
+	ids := []string{}
+	for _, desc := range descs {
+		ids = append(ids, desc.String())
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, "\n")
+}
+
+// restoringRegisterer wraps the collectors registered through it in a restoringCollector
+type restoringRegisterer struct {
+	registerer prometheus.Registerer
+	snapshot   *metricSnapshot
+}
+
+func (r *restoringRegisterer) Register(c prometheus.Collector) error {
+	rc := &restoringCollector{inner: c, snapshot: r.snapshot, descs: map[string]*prometheus.Desc{}}
+	descs := describe(c)
+	for _, desc := range descs {
+		rc.descs[desc.String()] = desc
+	}
+	if err := r.registerer.Register(rc); err != nil {
+		return err
+	}
+	r.snapshot.lock.Lock()
+	defer r.snapshot.lock.Unlock()
+	r.snapshot.collectors[descriptorsKey(descs)] = rc
+	return nil
+}
+
+func (r *restoringRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister goes by the descriptors, like the registry does, as the wrapping registerers unregister a new wrapper
+// of the collector vs. the one registered
+func (r *restoringRegisterer) Unregister(c prometheus.Collector) bool {
+	descs := describe(c)
+	r.snapshot.lock.Lock()
+	delete(r.snapshot.collectors, descriptorsKey(descs))
+	r.snapshot.lock.Unlock()
+	return r.registerer.Unregister(&restoringCollector{inner: c})
+}
+
+// restoringCollector reports the metrics of the collector it wraps with the restored series applied
+type restoringCollector struct {
+	inner    prometheus.Collector
+	snapshot *metricSnapshot
+	// descs are the descriptors of the inner collector, by their string form, which is what the snapshot is keyed by
+	descs map[string]*prometheus.Desc
+}
+
+func (c *restoringCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.inner.Describe(ch)
+}
+
+func (c *restoringCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.collect() {
+		ch <- m
+	}
+}
+
+// restoredMetric is a series with the restored values applied
+type restoredMetric struct {
+	desc   *prometheus.Desc
+	metric *dto.Metric
+}
+
+func (m *restoredMetric) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+func (m *restoredMetric) Write(out *dto.Metric) error {
+	*out = *m.metric
+	return nil
+}
+
+func (c *restoringCollector) collect() []prometheus.Metric {
+	inner := make(chan prometheus.Metric)
+	go func() {
+		c.inner.Collect(inner)
+		close(inner)
+	}()
+	live := []prometheus.Metric{}
+	for m := range inner {
+		live = append(live, m)
+	}
+
+	c.snapshot.lock.Lock()
+	defer c.snapshot.lock.Unlock()
+	metrics := []prometheus.Metric{}
+	seen := map[string]struct{}{}
+	liveDescs := map[string]struct{}{}
+	for _, m := range live {
+		metric := &dto.Metric{}
+		if err := m.Write(metric); err != nil {
+			metrics = append(metrics, m)
+			continue
+		}
+		desc := m.Desc().String()
+		liveDescs[desc] = struct{}{}
+		key := seriesKey(desc, labelsOf(metric))
+		seen[key] = struct{}{}
+		restored, ok := c.snapshot.restored[desc][key]
+		if !ok || !addRestored(metric, restored) {
+			metrics = append(metrics, m)
+			continue
+		}
+		metrics = append(metrics, &restoredMetric{desc: m.Desc(), metric: metric})
+	}
+	for id, desc := range c.descs {
+		_, isLive := liveDescs[id]
+		for key, restored := range c.snapshot.restored[id] {
+			if isLive && restored.Gauge != nil {
+				// the collector has taken over the gauge
+				delete(c.snapshot.restored[id], key)
+				continue
+			}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			metrics = append(metrics, &restoredMetric{desc: desc, metric: restored.metric()})
+		}
+	}
+	return metrics
+}
+
+// addRestored adds the restored counter or histogram values to the live ones, returning false when they do not match,
+// like when the histogram buckets changed, or for gauges, which are not added up
+func addRestored(metric *dto.Metric, restored *snapshotSeries) bool {
+	switch {
+	case metric.Counter != nil && restored.Counter != nil:
+		metric.Counter.Value = proto.Float64(metric.Counter.GetValue() + *restored.Counter)
+		return true
+	case metric.Histogram != nil && restored.Histogram != nil:
+		h := metric.Histogram
+		if len(h.Bucket) != len(restored.Histogram.Bounds) {
+			return false
+		}
+		for i, b := range h.Bucket {
+			if b.GetUpperBound() != restored.Histogram.Bounds[i] {
+				return false
+			}
+		}
+		for i, b := range h.Bucket {
+			b.CumulativeCount = proto.Uint64(b.GetCumulativeCount() + restored.Histogram.Buckets[i])
+		}
+		h.SampleCount = proto.Uint64(h.GetSampleCount() + restored.Histogram.Count)
+		h.SampleSum = proto.Float64(h.GetSampleSum() + restored.Histogram.Sum)
+		return true
+	}
+	return false
+}
+
+func (s *snapshotSeries) metric() *dto.Metric {
+	names := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metric := &dto.Metric{}
+	for _, name := range names {
+		metric.Label = append(metric.Label, &dto.LabelPair{Name: proto.String(name), Value: proto.String(s.Labels[name])})
+	}
+	switch {
+	case s.Counter != nil:
+		metric.Counter = &dto.Counter{Value: proto.Float64(*s.Counter)}
+	case s.Gauge != nil:
+		metric.Gauge = &dto.Gauge{Value: proto.Float64(*s.Gauge)}
+	case s.Histogram != nil:
+		metric.Histogram = &dto.Histogram{SampleCount: proto.Uint64(s.Histogram.Count), SampleSum: proto.Float64(s.Histogram.Sum)}
+		for i, bound := range s.Histogram.Bounds {
+			metric.Histogram.Bucket = append(metric.Histogram.Bucket, &dto.Bucket{UpperBound: proto.Float64(bound), CumulativeCount: proto.Uint64(s.Histogram.Buckets[i])})
+		}
+	}
+	return metric
+}
+
+func newSnapshotSeries(desc string, metric *dto.Metric) *snapshotSeries {
+	series := &snapshotSeries{Desc: desc, Labels: labelsOf(metric)}
+	switch {
+	case metric.Counter != nil:
+		series.Counter = proto.Float64(metric.Counter.GetValue())
+	case metric.Gauge != nil:
+		series.Gauge = proto.Float64(metric.Gauge.GetValue())
+	case metric.Histogram != nil:
+		series.Histogram = &snapshotHistogram{Count: metric.Histogram.GetSampleCount(), Sum: metric.Histogram.GetSampleSum()}
+		for _, b := range metric.Histogram.Bucket {
+			series.Histogram.Bounds = append(series.Histogram.Bounds, b.GetUpperBound())
+			series.Histogram.Buckets = append(series.Histogram.Buckets, b.GetCumulativeCount())
+		}
+	default:
+		return nil
+	}
+	return series
+}
+
+// save writes the series of the registered collectors, as reported, so with any restored values, to the snapshot file,
+// replacing it only once fully written
+func (s *metricSnapshot) save() error {
+	s.lock.Lock()
+	path := s.path
+	generation := s.generation
+	collectors := make([]*restoringCollector, 0, len(s.collectors))
+	for _, c := range s.collectors {
+		collectors = append(collectors, c)
+	}
+	s.lock.Unlock()
+	if len(path) == 0 {
+		return nil
+	}
+	file := snapshotFile{Taken: exporterClock.Now(), Generation: generation}
+	for _, c := range collectors {
+		for _, m := range c.collect() {
+			metric := &dto.Metric{}
+			if err := m.Write(metric); err != nil {
+				continue
+			}
+			if series := newSnapshotSeries(m.Desc().String(), metric); series != nil {
+				file.Series = append(file.Series, series)
+			}
+		}
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Start writes the snapshot every snapshotEvery, and once the manager stops
+func (s *metricSnapshot) Start(ctx context.Context) error {
+	ticker := exporterClock.NewTicker(snapshotEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			if err := s.save(); err != nil {
+				controllerLog.Info(fmt.Sprintf("unable to write the metric snapshot: %s", err.Error()))
+			}
+		case <-ctx.Done():
+			if err := s.save(); err != nil {
+				return fmt.Errorf("unable to write the metric snapshot: %w", err)
+			}
+			return nil
+		}
+	}
+}
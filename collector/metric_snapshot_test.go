@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+type snapshotMetrics struct {
+	runs     *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	active   *prometheus.GaugeVec
+}
+
+func newSnapshotMetrics(registerer prometheus.Registerer) *snapshotMetrics {
+	m := &snapshotMetrics{
+		runs:     prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_runs_total", Help: "test"}, []string{NS_LABEL}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_duration", Help: "test", Buckets: []float64{1, 10}}, []string{NS_LABEL}),
+		active:   prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_active", Help: "test"}, []string{NS_LABEL}),
+	}
+	registerer.MustRegister(m.runs, m.duration, m.active)
+	return m
+}
+
+func restoringRegistry(path string) (*prometheus.Registry, prometheus.Registerer, *metricSnapshot) {
+	snapshot := &metricSnapshot{}
+	snapshot.configure(path)
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels{CLUSTER_LABEL: "test-cluster"},
+		&restoringRegisterer{registerer: registry, snapshot: snapshot})
+	return registry, registerer, snapshot
+}
+
+func TestMetricSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	registry, registerer, snapshot := restoringRegistry(path)
+	m := newSnapshotMetrics(registerer)
+	m.runs.With(prometheus.Labels{NS_LABEL: "ns-1"}).Add(3)
+	m.runs.With(prometheus.Labels{NS_LABEL: "ns-2"}).Inc()
+	m.duration.With(prometheus.Labels{NS_LABEL: "ns-1"}).Observe(5)
+	m.active.With(prometheus.Labels{NS_LABEL: "ns-1"}).Set(2)
+	assert.NoError(t, snapshot.save())
+	// unregistering goes by the descriptors, through the wrapping registerer
+	assert.True(t, registerer.Unregister(m.runs))
+	assert.Len(t, snapshot.collectors, 2)
+	assert.Len(t, gatherFamilies(t, registry), 2)
+
+	// after a restart, the series are there before anything is observed, and the snapshot is set aside
+	registry, registerer, snapshot = restoringRegistry(path)
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, path+restoredSuffix)
+	assert.Equal(t, uint64(2), snapshot.generation)
+	m = newSnapshotMetrics(registerer)
+	families := gatherFamilies(t, registry)
+	runs := families["test_runs_total"].GetMetric()
+	assert.Len(t, runs, 2)
+	assert.Equal(t, float64(3), runs[0].GetCounter().GetValue())
+	assert.Equal(t, "test-cluster", runs[0].GetLabel()[0].GetValue())
+	assert.Equal(t, float64(2), families["test_active"].GetMetric()[0].GetGauge().GetValue())
+
+	// and carry on from the restored values
+	m.runs.With(prometheus.Labels{NS_LABEL: "ns-1"}).Inc()
+	m.duration.With(prometheus.Labels{NS_LABEL: "ns-1"}).Observe(0.5)
+	validateCounterVec(t, m.runs, prometheus.Labels{NS_LABEL: "ns-1"}, float64(1))
+	families = gatherFamilies(t, registry)
+	runs = families["test_runs_total"].GetMetric()
+	assert.Len(t, runs, 2)
+	assert.Equal(t, float64(4), runs[0].GetCounter().GetValue())
+	assert.Equal(t, float64(1), runs[1].GetCounter().GetValue())
+	h := families["test_duration"].GetMetric()[0].GetHistogram()
+	assert.Equal(t, uint64(2), h.GetSampleCount())
+	assert.Equal(t, 5.5, h.GetSampleSum())
+	assert.Equal(t, uint64(1), h.GetBucket()[0].GetCumulativeCount())
+	assert.Equal(t, uint64(2), h.GetBucket()[1].GetCumulativeCount())
+
+	// the gauge collector takes over once it reports any series
+	m.active.With(prometheus.Labels{NS_LABEL: "ns-2"}).Set(1)
+	active := gatherFamilies(t, registry)["test_active"].GetMetric()
+	assert.Len(t, active, 1)
+	assert.Equal(t, "ns-2", active[0].GetLabel()[1].GetValue())
+}
+
+func TestMetricSnapshotRestoredOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	_, registerer, snapshot := restoringRegistry(path)
+	m := newSnapshotMetrics(registerer)
+	m.runs.With(prometheus.Labels{NS_LABEL: "ns-1"}).Add(3)
+	assert.NoError(t, snapshot.save())
+	stale, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	registry, registerer, _ := restoringRegistry(path)
+	newSnapshotMetrics(registerer)
+	assert.Len(t, gatherFamilies(t, registry)["test_runs_total"].GetMetric(), 1)
+
+	// exiting before writing a snapshot of our own, the restored one is not restored again
+	registry, registerer, _ = restoringRegistry(path)
+	newSnapshotMetrics(registerer)
+	assert.Empty(t, gatherFamilies(t, registry))
+
+	// nor is one written by an exporter older than the restore
+	assert.NoError(t, os.WriteFile(path, stale, 0600))
+	registry, registerer, snapshot = restoringRegistry(path)
+	newSnapshotMetrics(registerer)
+	assert.Empty(t, gatherFamilies(t, registry))
+	assert.Equal(t, uint64(2), snapshot.generation)
+
+	// while ours, written since, is
+	m = newSnapshotMetrics(prometheus.WrapRegistererWith(prometheus.Labels{CLUSTER_LABEL: "test-cluster"}, &restoringRegisterer{registerer: prometheus.NewRegistry(), snapshot: snapshot}))
+	m.runs.With(prometheus.Labels{NS_LABEL: "ns-1"}).Add(2)
+	assert.NoError(t, snapshot.save())
+	registry, registerer, _ = restoringRegistry(path)
+	newSnapshotMetrics(registerer)
+	assert.Equal(t, float64(2), gatherFamilies(t, registry)["test_runs_total"].GetMetric()[0].GetCounter().GetValue())
+}
+
+func TestMetricSnapshotUnreadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{not json"), 0600))
+	registry, registerer, snapshot := restoringRegistry(path)
+	newSnapshotMetrics(registerer)
+	assert.Empty(t, gatherFamilies(t, registry))
+	assert.True(t, snapshot.enabled())
+
+	// an empty path turns it off
+	snapshot.configure("")
+	assert.False(t, snapshot.enabled())
+	assert.NoError(t, snapshot.save())
+}
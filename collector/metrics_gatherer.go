@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// bridgedGatherer gathers controller-runtime's registry, where the collectors and the manager register, along with
+// the default prometheus registry, where client libraries and embedders tend to register, so a metric is scraped
+// whichever of the two it was registered with; the families of the second already gathered from the first, like the
+// go and process metrics both registries may have, are left out vs. failing the scrape as duplicates
+type bridgedGatherer struct {
+	primary   prometheus.Gatherer
+	secondary prometheus.Gatherer
+
+	lock sync.Mutex
+	// dropped are the names of the families of the second left out while they differ from those of the first, logged
+	// once each
+	dropped map[string]struct{}
+}
+
+// ServedGatherer is what the metrics endpoint, the tenant and federation endpoints, and the textfile serve
+var ServedGatherer prometheus.Gatherer = &bridgedGatherer{primary: metrics.Registry, secondary: prometheus.DefaultGatherer}
+
+func (g *bridgedGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.primary.Gather()
+	if g.secondary == nil || g.secondary == g.primary {
+		return families, err
+	}
+	errs := prometheus.MultiError{}
+	errs.Append(err)
+	// like the families of the first, those of the second may be partial on an error, and are still served
+	more, err := g.secondary.Gather()
+	errs.Append(err)
+	gathered := map[string]*dto.MetricFamily{}
+	for _, f := range families {
+		gathered[f.GetName()] = f
+	}
+	bridged := false
+	for _, f := range more {
+		if first, ok := gathered[f.GetName()]; ok {
+			g.drop(first, f)
+			continue
+		}
+		families = append(families, f)
+		bridged = true
+	}
+	// the families are gathered sorted by name
+	if bridged {
+		sort.Slice(families, func(i, j int) bool {
+			return families[i].GetName() < families[j].GetName()
+		})
+	}
+	return families, errs.MaybeUnwrap()
+}
+
+// drop logs the family of the second registry left out for the one of the first, once, when it is not the same
+// metric, by its type or label names, as the series it has are then missing from the scrapes
+func (g *bridgedGatherer) drop(first, second *dto.MetricFamily) {
+	if first.GetType() == second.GetType() && familyLabelNames(first) == familyLabelNames(second) {
+		return
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if _, ok := g.dropped[second.GetName()]; ok {
+		return
+	}
+	if g.dropped == nil {
+		g.dropped = map[string]struct{}{}
+	}
+	g.dropped[second.GetName()] = struct{}{}
+	controllerLog.Info("a metric of the default prometheus registry is left out of the scrapes, as a different one of the same name is registered with controller-runtime's",
+		"name", second.GetName(), "type", second.GetType().String(), "labels", familyLabelNames(second),
+		"registered type", first.GetType().String(), "registered labels", familyLabelNames(first))
+}
+
+// familyLabelNames are the sorted label names of the series of the family
+func familyLabelNames(f *dto.MetricFamily) string {
+	names := map[string]struct{}{}
+	for _, m := range f.GetMetric() {
+		for _, l := range m.GetLabel() {
+			names[l.GetName()] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
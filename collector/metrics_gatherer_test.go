@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBridgedGatherer(t *testing.T) {
+	primary := prometheus.NewRegistry()
+	secondary := prometheus.NewRegistry()
+	fromPrimary := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_primary_total", Help: "test"})
+	fromSecondary := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_secondary_total", Help: "test"})
+	primary.MustRegister(fromPrimary, collectors.NewGoCollector())
+	// both registries having the go metrics does not fail the gather
+	secondary.MustRegister(fromSecondary, collectors.NewGoCollector())
+	g := &bridgedGatherer{primary: primary, secondary: secondary}
+
+	families, err := g.Gather()
+	assert.NoError(t, err)
+	names := []string{}
+	counts := map[string]int{}
+	for _, f := range families {
+		names = append(names, f.GetName())
+		counts[f.GetName()]++
+	}
+	assert.Contains(t, names, "test_primary_total")
+	assert.Contains(t, names, "test_secondary_total")
+	assert.Equal(t, 1, counts["go_goroutines"])
+	assert.IsNonDecreasing(t, names)
+	assert.Empty(t, g.dropped)
+
+	// a different metric of the same name is left out too, and remembered to be logged once
+	clash := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_primary_total", Help: "test"}, []string{"other"})
+	clash.WithLabelValues("test").Inc()
+	secondary.MustRegister(clash)
+	families, err = g.Gather()
+	assert.NoError(t, err)
+	counts = map[string]int{}
+	for _, f := range families {
+		counts[f.GetName()]++
+	}
+	assert.Equal(t, 1, counts["test_primary_total"])
+	assert.Contains(t, g.dropped, "test_primary_total")
+	assert.NotContains(t, g.dropped, "go_goroutines")
+
+	// the same registry is gathered once
+	g = &bridgedGatherer{primary: primary, secondary: primary}
+	families, err = g.Gather()
+	assert.NoError(t, err)
+	for _, f := range families {
+		assert.NotEqual(t, "test_secondary_total", f.GetName())
+	}
+}
@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	// ActiveNamespaceWindowEnvName is a duration, like 24h, after which a namespace that has not produced a
+	// PipelineRun is no longer counted as active
+	ActiveNamespaceWindowEnvName = "ACTIVE_NAMESPACE_WINDOW"
+	defaultActiveNamespaceWindow = 7 * 24 * time.Hour
+)
+
+type namespaceActivity struct {
+	first time.Time
+	last  time.Time
+}
+
+// NamespaceLifecycleCollector tracks when each namespace first and last created a PipelineRun, as seen by the
+// PipelineRun watch, so that onboarding and offboarding of namespaces across the fleet can be followed; history
+// does not survive an exporter restart, at which point the relist of existing PipelineRuns seeds it again, and is
+// dropped when the namespace is deleted
+type NamespaceLifecycleCollector struct {
+	registerer       *collectorRegisterer
+	lock             sync.Mutex
+	activity         map[string]*namespaceActivity
+	window           time.Duration
+	firstPipelineRun *prometheus.GaugeVec
+	lastPipelineRun  *prometheus.GaugeVec
+	activeNamespaces *prometheus.GaugeVec
+}
+
+func activeNamespaceWindow() time.Duration {
+	if settings.ActiveNamespaceWindow <= 0 {
+		return defaultActiveNamespaceWindow
+	}
+	return settings.ActiveNamespaceWindow
+}
+
+func NewNamespaceLifecycleCollector(registerer prometheus.Registerer) *NamespaceLifecycleCollector {
+	reg := newCollectorRegisterer(registerer)
+	labelNames := withTenantLabelName([]string{NS_LABEL})
+	firstPipelineRun := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_namespace_first_pipelinerun_timestamp_seconds",
+		Help: "Unix time of the creation of the earliest PipelineRun seen in the namespace",
+	}, labelNames)
+	lastPipelineRun := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_namespace_last_pipelinerun_timestamp_seconds",
+		Help: "Unix time of the creation of the latest PipelineRun seen in the namespace",
+	}, labelNames)
+	activeNamespaces := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_active_pipeline_namespaces",
+		Help: "Number of namespaces that have created a PipelineRun within the active namespace window",
+	}, withTenantLabelName([]string{}))
+	reg.MustRegister(firstPipelineRun, lastPipelineRun, activeNamespaces)
+	return &NamespaceLifecycleCollector{
+		registerer:       reg,
+		activity:         map[string]*namespaceActivity{},
+		window:           activeNamespaceWindow(),
+		firstPipelineRun: firstPipelineRun,
+		lastPipelineRun:  lastPipelineRun,
+		activeNamespaces: activeNamespaces,
+	}
+}
+
+// Close unregisters the metrics of the collector
+func (c *NamespaceLifecycleCollector) Close() {
+	c.registerer.Close()
+}
+
+func (c *NamespaceLifecycleCollector) observe(pr *v1.PipelineRun) {
+	created := pr.CreationTimestamp.Time
+	if created.IsZero() {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	a, ok := c.activity[pr.Namespace]
+	if !ok {
+		a = &namespaceActivity{first: created, last: created}
+		c.activity[pr.Namespace] = a
+	}
+	if created.Before(a.first) {
+		a.first = created
+	}
+	if created.After(a.last) {
+		a.last = created
+	}
+	labels := withTenantLabel(map[string]string{NS_LABEL: pr.Namespace}, pr.Namespace)
+	c.firstPipelineRun.With(labels).Set(float64(a.first.Unix()))
+	c.lastPipelineRun.With(labels).Set(float64(a.last.Unix()))
+}
+
+// forget drops the activity and series of a deleted namespace; its series are matched on the namespace alone, as the
+// tenant of a deleted namespace may no longer resolve
+func (c *NamespaceLifecycleCollector) forget(ns string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.activity, ns)
+	c.firstPipelineRun.DeletePartialMatch(prometheus.Labels{NS_LABEL: ns})
+	c.lastPipelineRun.DeletePartialMatch(prometheus.Labels{NS_LABEL: ns})
+}
+
+// prune drops the namespaces without a PipelineRun for longer than the TTL, or the active namespace window if
+// longer, so those still counted as active are kept, and those over the size limit, for when the delete of the
+// namespace was missed; a namespace dropped while it still exists starts its history over on its next PipelineRun
+func (c *NamespaceLifecycleCollector) prune(now time.Time) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ttl := storeTTL()
+	if c.window > ttl {
+		ttl = c.window
+	}
+	last := make(map[string]time.Time, len(c.activity))
+	for ns, a := range c.activity {
+		last[ns] = a.last
+	}
+	for _, ns := range evictKeysAfter(StoreNamespaces, last, now, ttl) {
+		delete(c.activity, ns)
+		c.firstPipelineRun.DeletePartialMatch(prometheus.Labels{NS_LABEL: ns})
+		c.lastPipelineRun.DeletePartialMatch(prometheus.Labels{NS_LABEL: ns})
+	}
+}
+
+// rollupActiveNamespaces recomputes the active namespace counts; when the tenant label is enabled, there is a series
+// per tenant, whose sum is the count for the cluster
+func (c *NamespaceLifecycleCollector) rollupActiveNamespaces(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.activeNamespaces.Reset()
+	if !tenants.enabled {
+		c.activeNamespaces.With(map[string]string{}).Set(0)
+	}
+	for ns, a := range c.activity {
+		if now.Sub(a.last) > c.window {
+			continue
+		}
+		c.activeNamespaces.With(withTenantLabel(map[string]string{}, ns)).Inc()
+	}
+}
+
+func (c *NamespaceLifecycleCollector) Start(ctx context.Context) error {
+	eventTicker := exporterClock.NewTicker(2 * time.Minute)
+	for {
+		select {
+		case <-eventTicker.C():
+			c.rollupActiveNamespaces(exporterClock.Now())
+		case <-ctx.Done():
+			controllerLog.Info("NamespaceLifecycleCollector Runnable context is marked as done, exiting")
+			eventTicker.Stop()
+			return nil
+		}
+	}
+}
+
+type namespaceLifecycleFilter struct {
+	collector *NamespaceLifecycleCollector
+}
+
+func (f *namespaceLifecycleFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *namespaceLifecycleFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *namespaceLifecycleFilter) Update(e event.UpdateEvent) bool {
+	pr, ok := e.ObjectNew.(*v1.PipelineRun)
+	if ok {
+		f.collector.observe(pr)
+	}
+	return false
+}
+
+func (f *namespaceLifecycleFilter) Generic(event.GenericEvent) bool {
+	return false
+}
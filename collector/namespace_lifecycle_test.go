@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"testing"
+	"time"
+)
+
+func TestNamespaceLifecycle(t *testing.T) {
+	c := NewNamespaceLifecycleCollector(exporterRegisterer())
+	defer c.Close()
+	assert.Equal(t, defaultActiveNamespaceWindow, c.window)
+	filter := &namespaceLifecycleFilter{collector: c}
+	now := time.Now()
+	for _, tc := range []struct {
+		ns      string
+		created time.Time
+	}{
+		{ns: "test-namespace", created: now.Add(-2 * time.Hour)},
+		{ns: "test-namespace", created: now.Add(-10 * 24 * time.Hour)},
+		{ns: "test-namespace", created: now.Add(-1 * time.Hour)},
+		{ns: "test-namespace-2", created: now.Add(-8 * 24 * time.Hour)},
+	} {
+		pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: tc.ns, Name: "test", CreationTimestamp: metav1.NewTime(tc.created)}}
+		assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	}
+
+	label := prometheus.Labels{NS_LABEL: "test-namespace"}
+	validateGaugeVec(t, c.firstPipelineRun, label, float64(now.Add(-10*24*time.Hour).Unix()))
+	validateGaugeVec(t, c.lastPipelineRun, label, float64(now.Add(-1*time.Hour).Unix()))
+
+	// test-namespace-2 has offboarded
+	c.rollupActiveNamespaces(now)
+	validateGaugeVec(t, c.activeNamespaces, prometheus.Labels{}, float64(1))
+	c.rollupActiveNamespaces(now.Add(7 * 24 * time.Hour))
+	validateGaugeVec(t, c.activeNamespaces, prometheus.Labels{}, float64(0))
+
+	// deleting a namespace drops its activity and series
+	c.forget("test-namespace")
+	assert.NotContains(t, c.activity, "test-namespace")
+	assert.Contains(t, c.activity, "test-namespace-2")
+	assert.Equal(t, 1, testutil.CollectAndCount(c.firstPipelineRun))
+	assert.Equal(t, 1, testutil.CollectAndCount(c.lastPipelineRun))
+}
+
+func TestNamespaceLifecycleStart(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	defer setClock(fakeClock)()
+	c := NewNamespaceLifecycleCollector(exporterRegisterer())
+	defer c.Close()
+	filter := &namespaceLifecycleFilter{collector: c}
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test", CreationTimestamp: metav1.NewTime(fakeClock.Now())}}
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		assert.NoError(t, c.Start(ctx))
+		close(done)
+	}()
+	activeNamespaces := func(expected float64) func() bool {
+		return func() bool {
+			gauge, err := c.activeNamespaces.GetMetricWith(prometheus.Labels{})
+			if err != nil {
+				return false
+			}
+			m := &dto.Metric{}
+			return gauge.Write(m) == nil && m.GetGauge().GetValue() == expected
+		}
+	}
+	// the rollup only happens on the poll interval
+	assert.Eventually(t, fakeClock.HasWaiters, time.Second, time.Millisecond)
+	fakeClock.Step(2 * time.Minute)
+	assert.Eventually(t, activeNamespaces(1), time.Second, time.Millisecond)
+	// a week later, the namespace is no longer active
+	fakeClock.Step(defaultActiveNamespaceWindow)
+	assert.Eventually(t, activeNamespaces(0), time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const SkipReasonMissingCompletionTime = "missing-completion-time"
+
+// observationLagFilter records how long after a PipelineRun completed the exporter saw it complete, which bounds how
+// fresh any of the run level metrics can be; like the heartbeat, it describes the exporter, so it is always on
+type observationLagFilter struct {
+	metric prometheus.Histogram
+}
+
+func NewObservationLagMetric(registerer prometheus.Registerer) prometheus.Histogram {
+	lag := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "pipeline_service_exporter_observation_lag_seconds",
+		Help: "Duration in seconds between the completion time of a PipelineRun and when the exporter observed its completion",
+		// the results in buckets of 0.1, 0.25, 0.625, 1.5625, 3.90625, 9.765625, 24.4140625, 61.03515625, 152.587890625, 381.4697265625 seconds
+		Buckets: prometheus.ExponentialBuckets(0.1, 2.5, 10),
+	})
+	registerer.MustRegister(lag)
+	return lag
+}
+
+func (f *observationLagFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *observationLagFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *observationLagFilter) Update(e event.UpdateEvent) bool {
+	oldPR, okold := e.ObjectOld.(*v1.PipelineRun)
+	newPR, oknew := e.ObjectNew.(*v1.PipelineRun)
+	// only the transition to done, as runs already done when the exporter starts were completed before it could see them
+	if !okold || !oknew || oldPR.IsDone() || !newPR.IsDone() {
+		return false
+	}
+	if newPR.Status.CompletionTime == nil {
+		eventSkips.skip("observation-lag", SkipReasonMissingCompletionTime, newPR)
+		return false
+	}
+	lag := exporterClock.Since(newPR.Status.CompletionTime.Time).Seconds()
+	// the completion time comes from the Tekton controller's clock, so any skew with ours can make the lag negative
+	if lag < 0 {
+		lag = 0
+	}
+	f.metric.Observe(lag)
+	return false
+}
+
+func (f *observationLagFilter) Generic(event.GenericEvent) bool {
+	return false
+}
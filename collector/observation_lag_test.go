@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func donePipelineRun(completionTime *metav1.Time) *v1.PipelineRun {
+	return &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"},
+		Status: v1.PipelineRunStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{{Type: "Succeeded", Status: corev1.ConditionTrue}},
+			},
+			PipelineRunStatusFields: v1.PipelineRunStatusFields{CompletionTime: completionTime},
+		},
+	}
+}
+
+func histogramOf(t *testing.T, h prometheus.Histogram) *dto.Histogram {
+	m := &dto.Metric{}
+	assert.NoError(t, h.Write(m))
+	return m.GetHistogram()
+}
+
+func TestObservationLagFilter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	defer setClock(clocktesting.NewFakeClock(now))()
+	lag := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_observation_lag_seconds", Help: "test"})
+	filter := &observationLagFilter{metric: lag}
+	running := &v1.PipelineRun{}
+	done := donePipelineRun(&metav1.Time{Time: now.Add(-5 * time.Second)})
+
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: running}))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: done, ObjectNew: done}))
+	assert.Equal(t, uint64(0), histogramOf(t, lag).GetSampleCount())
+
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: done}))
+	assert.Equal(t, uint64(1), histogramOf(t, lag).GetSampleCount())
+	assert.Equal(t, float64(5), histogramOf(t, lag).GetSampleSum())
+
+	// a completion time ahead of our clock counts as no lag
+	ahead := donePipelineRun(&metav1.Time{Time: now.Add(time.Second)})
+	filter.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: ahead})
+	assert.Equal(t, uint64(2), histogramOf(t, lag).GetSampleCount())
+	assert.Equal(t, float64(5), histogramOf(t, lag).GetSampleSum())
+
+	skipped := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_skipped_events_total", Help: "test"},
+		[]string{FILTER_LABEL, REASON_LABEL})
+	eventSkips.enable(skipped)
+	defer eventSkips.enable(nil)
+	filter.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: donePipelineRun(nil)})
+	assert.Equal(t, uint64(2), histogramOf(t, lag).GetSampleCount())
+	validateCounterVec(t, skipped, prometheus.Labels{FILTER_LABEL: "observation-lag", REASON_LABEL: SkipReasonMissingCompletionTime}, float64(1))
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"hash/fnv"
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+const (
+	// ObservationSampleRateEnvName is the share, above 0 and up to 1, of the successful PipelineRuns whose overhead and
+	// gaps are observed, for clusters running so many PipelineRuns that observing all of them is unnecessary; failed
+	// PipelineRuns are always observed
+	ObservationSampleRateEnvName = "OBSERVATION_SAMPLE_RATE"
+	// OverheadSkipSampledOut is why the overhead of a PipelineRun left out by the sampling is not recorded
+	OverheadSkipSampledOut = "sampled-out"
+
+	sampleBuckets = 10000
+)
+
+func samplingEnabled() bool {
+	return settings.ObservationSampleRate > 0 && settings.ObservationSampleRate < 1
+}
+
+// NewSampleWeightMetric is the sum of the sample weights of the PipelineRuns a collector observed, the number of
+// completed PipelineRuns they stand for, so its histogram counts can be scaled back up without a weight label doubling
+// their series; it is only registered when sampling
+func NewSampleWeightMetric(registerer prometheus.Registerer, name, help string) *prometheus.CounterVec {
+	if !samplingEnabled() {
+		return nil
+	}
+	weights := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, []string{NS_LABEL})
+	registerer.MustRegister(weights)
+	return weights
+}
+
+// addSampleWeight adds the weight of an observed PipelineRun, if sampling
+func addSampleWeight(weights *prometheus.CounterVec, pr *v1.PipelineRun, weight float64) {
+	if weights == nil {
+		return
+	}
+	weights.With(map[string]string{NS_LABEL: pr.Namespace}).Add(weight)
+}
+
+// overheadSample is whether the overhead and gaps of the completed PipelineRun are observed, and the number of
+// PipelineRuns it stands for; the decision only needs the PipelineRun, so it is made before its TaskRuns are listed,
+// and hashes the UID, so it is the same each time the PipelineRun is reconciled or explained, and for every collector
+func overheadSample(pr *v1.PipelineRun, status string) (float64, bool) {
+	if !samplingEnabled() || status != SUCCEEDED {
+		return 1, true
+	}
+	key := string(pr.UID)
+	if len(key) == 0 {
+		key = pr.Namespace + "/" + pr.Name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	sampled := float64(h.Sum32()%sampleBuckets) < settings.ObservationSampleRate*sampleBuckets
+	return math.Round(1000/settings.ObservationSampleRate) / 1000, sampled
+}
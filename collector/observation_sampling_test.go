@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestOverheadSample(t *testing.T) {
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1", UID: "uid-1"}}
+	weight, sampled := overheadSample(pr, SUCCEEDED)
+	assert.True(t, sampled)
+	assert.Equal(t, float64(1), weight)
+	// nothing to scale by when every PipelineRun is observed
+	assert.Nil(t, NewSampleWeightMetric(prometheus.NewRegistry(), "test_sample_weight_total", "test"))
+
+	defer setSettings(Settings{ObservationSampleRate: 0.1})()
+	count := 0
+	for i := 0; i < 2000; i++ {
+		pr.UID = types.UID(fmt.Sprintf("uid-%d", i))
+		weight, sampled = overheadSample(pr, SUCCEEDED)
+		assert.Equal(t, float64(10), weight)
+		if sampled {
+			count++
+		}
+		// the same every time
+		_, again := overheadSample(pr, SUCCEEDED)
+		assert.Equal(t, sampled, again)
+		// failed runs are always observed
+		weight, sampled = overheadSample(pr, FAILED)
+		assert.True(t, sampled)
+		assert.Equal(t, float64(1), weight)
+	}
+	assert.InDelta(t, 200, count, 50)
+
+	weights := NewSampleWeightMetric(prometheus.NewRegistry(), "test_sample_weight_total", "test")
+	addSampleWeight(weights, pr, 10)
+	addSampleWeight(weights, pr, 1)
+	validateCounterVec(t, weights, prometheus.Labels{NS_LABEL: "test-namespace"}, float64(11))
+}
+
+func TestReconcileOverhead_SampledOut(t *testing.T) {
+	defer setSettings(Settings{ObservationSampleRate: 0.1})()
+	// the sample weight counters are only registered when sampling, so they can not share a registry with those of
+	// other tests
+	defer func() { baseRegisterer = metrics.Registry }()
+	baseRegisterer = prometheus.NewRegistry()
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	pr, taskRuns := explainedRuns(true)
+	// long enough for the gaps to be below the alert level
+	pr.Status.CompletionTime = &metav1.Time{Time: pr.Status.StartTime.Add(10000 * time.Second)}
+	for i := 0; ; i++ {
+		pr.UID = types.UID(fmt.Sprintf("uid-%d", i))
+		if _, sampled := overheadSample(pr, SUCCEEDED); !sampled {
+			break
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr, taskRuns[0], taskRuns[1]).Build()
+	overheadReconciler := buildReconciler(c, nil, nil)
+	defer overheadReconciler.Close()
+	ctx := context.TODO()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}}
+	_, err := overheadReconciler.ReconcileOverhead(ctx, request)
+	assert.NoError(t, err)
+	validateCounterVec(t, overheadReconciler.overheadCollector.skipped, prometheus.Labels{NS_LABEL: "test-namespace", REASON_LABEL: OverheadSkipSampledOut}, float64(1))
+	runs := overheadReconciler.recentRuns.list("test-namespace", "test-1")
+	assert.Len(t, runs, 1)
+	assert.Empty(t, runs[0].Observations)
+	assert.Equal(t, OverheadSkipSampledOut, runs[0].Decisions[0].Reason)
+	// the decision is made before the TaskRuns are listed, so nothing past it sees the PipelineRun
+	assert.Empty(t, overheadReconciler.overheadCollector.executionRollup.samples)
+	validateCounterVec(t, overheadReconciler.overheadCollector.sampleWeights, prometheus.Labels{NS_LABEL: "test-namespace"}, float64(0))
+
+	// the gap collector samples out the same PipelineRun
+	_, err = overheadReconciler.ReconcilePipelineRunTaskRunGap(ctx, request)
+	assert.NoError(t, err)
+	validateCounterVec(t, overheadReconciler.prGapCollector.gapAborts, prometheus.Labels{NS_LABEL: "test-namespace", REASON_LABEL: OverheadSkipSampledOut}, float64(1))
+
+	e, err := Explain(ctx, c, "test-namespace", "test-1")
+	assert.NoError(t, err)
+	for _, d := range e.Decisions {
+		if d.Collector == CollectorOverhead || d.Collector == CollectorTaskRunGaps {
+			assert.Equal(t, OverheadSkipSampledOut, d.Reason)
+		}
+	}
+
+	// a sampled PipelineRun adds its weight
+	for i := 0; ; i++ {
+		pr.UID = types.UID(fmt.Sprintf("uid-%d", i))
+		if _, sampled := overheadSample(pr, SUCCEEDED); sampled {
+			break
+		}
+	}
+	assert.NoError(t, c.Delete(ctx, pr.DeepCopy()))
+	pr.ResourceVersion = ""
+	assert.NoError(t, c.Create(ctx, pr))
+	_, err = overheadReconciler.ReconcileOverhead(ctx, request)
+	assert.NoError(t, err)
+	validateCounterVec(t, overheadReconciler.overheadCollector.sampleWeights, prometheus.Labels{NS_LABEL: "test-namespace"}, float64(10))
+}
@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// ObservationSmoothingWindowEnvName is how long the overhead and gap observations of the PipelineRuns completing
+	// in a burst, like a batch retrigger, are spread over, so the rates of the histograms do not spike with them; it
+	// only shifts when the observations are made, as the series are created right away, so the size of the scrapes
+	// and of any remote write downstream are the same; not set, or 0, observes them right away
+	ObservationSmoothingWindowEnvName = "OBSERVATION_SMOOTHING_WINDOW"
+
+	smoothingTick = time.Second
+	// maxObservationBacklog bounds the queue, observing anything over it right away vs. holding it in memory
+	maxObservationBacklog = 10000
+)
+
+// pendingObservation is an observation held back by the smoothing
+type pendingObservation struct {
+	observer prometheus.Observer
+	value    float64
+	queued   time.Time
+}
+
+// observationSmoother queues the observations of the completed PipelineRuns, releasing a share of the backlog every
+// tick such that a burst is observed over the smoothing window, and anything queued for longer than the window right
+// away, so nothing is held back for much longer than the window however many are queued; the queue is only flushed on
+// a clean stop, so what is queued is lost on a crash, or when the leader is lost
+type observationSmoother struct {
+	lock    sync.Mutex
+	queue   []pendingObservation
+	backlog prometheus.Gauge
+}
+
+var smoothedObservations = &observationSmoother{}
+
+func smoothingWindow() time.Duration {
+	return settings.ObservationSmoothingWindow
+}
+
+func NewObservationBacklogMetric(registerer prometheus.Registerer) prometheus.Gauge {
+	backlog := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pipeline_service_exporter_observation_backlog",
+		Help: "Number of overhead and gap observations queued by the smoothing of bursts of completed PipelineRuns",
+	})
+	registerer.MustRegister(backlog)
+	return backlog
+}
+
+// enable turns the smoothing on with the backlog gauge, when the window is set, or off with nil, dropping anything
+// still queued
+func (s *observationSmoother) enable(backlog prometheus.Gauge) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.backlog = backlog
+	s.queue = nil
+}
+
+// observe queues the observation when smoothing, and the queue is not full, else observes it right away
+func (s *observationSmoother) observe(observer prometheus.Observer, value float64) {
+	s.lock.Lock()
+	if s.backlog == nil || smoothingWindow() <= 0 || len(s.queue) >= maxObservationBacklog {
+		s.lock.Unlock()
+		observer.Observe(value)
+		return
+	}
+	s.queue = append(s.queue, pendingObservation{observer: observer, value: value, queued: exporterClock.Now()})
+	s.backlog.Set(float64(len(s.queue)))
+	s.lock.Unlock()
+}
+
+// release observes the tick's share of the backlog, and everything queued a window or more ago
+func (s *observationSmoother) release(now time.Time) {
+	s.lock.Lock()
+	window := smoothingWindow()
+	n := len(s.queue)
+	if window > 0 {
+		n = int(math.Ceil(float64(len(s.queue)) * float64(smoothingTick) / float64(window)))
+	}
+	for n < len(s.queue) && now.Sub(s.queue[n].queued) >= window {
+		n++
+	}
+	if n > len(s.queue) {
+		n = len(s.queue)
+	}
+	released := s.queue[:n]
+	s.queue = s.queue[n:]
+	if s.backlog != nil {
+		s.backlog.Set(float64(len(s.queue)))
+	}
+	s.lock.Unlock()
+	for _, pending := range released {
+		pending.observer.Observe(pending.value)
+	}
+}
+
+// flush observes the whole backlog, so nothing queued is lost when the manager stops
+func (s *observationSmoother) flush() {
+	s.lock.Lock()
+	released := s.queue
+	s.queue = nil
+	if s.backlog != nil {
+		s.backlog.Set(0)
+	}
+	s.lock.Unlock()
+	for _, pending := range released {
+		pending.observer.Observe(pending.value)
+	}
+}
+
+func (s *observationSmoother) Start(ctx context.Context) error {
+	ticker := exporterClock.NewTicker(smoothingTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			s.release(exporterClock.Now())
+		case <-ctx.Done():
+			s.flush()
+			return nil
+		}
+	}
+}
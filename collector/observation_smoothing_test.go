@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObservationSmoothing(t *testing.T) {
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_histogram"})
+	count := func() uint64 {
+		m := &dto.Metric{}
+		assert.NoError(t, histogram.Write(m))
+		return m.GetHistogram().GetSampleCount()
+	}
+	smoother := &observationSmoother{}
+
+	// not enabled, so observed right away
+	smoother.observe(histogram, 1)
+	assert.Equal(t, uint64(1), count())
+
+	defer setSettings(Settings{ObservationSmoothingWindow: 10 * time.Second})()
+	backlog := NewObservationBacklogMetric(prometheus.NewRegistry())
+	smoother.enable(backlog)
+	for i := 0; i < 100; i++ {
+		smoother.observe(histogram, 1)
+	}
+	assert.Equal(t, uint64(1), count())
+	m := &dto.Metric{}
+	assert.NoError(t, backlog.Write(m))
+	assert.Equal(t, float64(100), m.GetGauge().GetValue())
+
+	// a tenth of the backlog every second over the 10 second window
+	now := exporterClock.Now()
+	smoother.release(now)
+	assert.Equal(t, uint64(11), count())
+	smoother.release(now.Add(smoothingTick))
+	assert.Equal(t, uint64(20), count())
+
+	// anything queued a window ago is released, whatever the share
+	smoother.release(now.Add(10 * time.Second))
+	assert.Equal(t, uint64(101), count())
+	assert.NoError(t, backlog.Write(m))
+	assert.Equal(t, float64(0), m.GetGauge().GetValue())
+
+	smoother.observe(histogram, 1)
+	smoother.flush()
+	assert.Equal(t, uint64(102), count())
+
+	// over the bound, the observations are made right away
+	for i := 0; i < maxObservationBacklog+1; i++ {
+		smoother.observe(histogram, 1)
+	}
+	assert.Equal(t, uint64(103), count())
+	assert.Len(t, smoother.queue, maxObservationBacklog)
+}
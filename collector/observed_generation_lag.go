@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ObservedGenerationLagCollector publishes how many live PipelineRuns of each namespace the tekton controller is
+// behind on across scans, with a status.observedGeneration still trailing the metadata.generation they had the scan
+// before; unlike the overhead metrics, which need the PipelineRuns to complete, it shows a controller falling behind
+// while it happens, without counting the PipelineRuns created just before a scan, which always trail by one
+type ObservedGenerationLagCollector struct {
+	registerer *collectorRegisterer
+	lagging    *prometheus.GaugeVec
+	// lastScan are the namespaces set in the most recent scan, so those without lagging PipelineRuns since are zeroed
+	lastScan map[string]struct{}
+	// behind are the generations of the PipelineRuns that trailed in the most recent scan, by UID, so only the live
+	// lagging PipelineRuns are kept
+	behind map[types.UID]int64
+}
+
+func NewObservedGenerationLagCollector(registerer prometheus.Registerer) *ObservedGenerationLagCollector {
+	reg := newCollectorRegisterer(registerer)
+	lagging := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_pipelinerun_observed_generation_lagging_count",
+		Help: "Number of live PipelineRuns in the namespace whose status.observedGeneration still trails the metadata.generation they had the scan before, as of the most recent scan",
+	}, withTenantLabelName([]string{NS_LABEL}))
+	reg.MustRegister(lagging)
+	return &ObservedGenerationLagCollector{registerer: reg, lagging: lagging, lastScan: map[string]struct{}{}, behind: map[types.UID]int64{}}
+}
+
+// Close unregisters the metrics of the collector
+func (c *ObservedGenerationLagCollector) Close() {
+	c.registerer.Close()
+}
+
+// observedGenerationLag is how many generations the tekton controller has yet to observe of the PipelineRun, where a
+// PipelineRun the controller has not seen at all yet has an observed generation of 0
+func observedGenerationLag(pr *v1.PipelineRun) int64 {
+	lag := pr.Generation - pr.Status.ObservedGeneration
+	if lag < 0 {
+		return 0
+	}
+	return lag
+}
+
+func (c *ObservedGenerationLagCollector) scan(prs []v1.PipelineRun) {
+	counts := map[string]int{}
+	behind := map[types.UID]int64{}
+	for i := range prs {
+		pr := &prs[i]
+		if pr.IsDone() || observedGenerationLag(pr) == 0 {
+			continue
+		}
+		behind[pr.UID] = pr.Generation
+		if previous, ok := c.behind[pr.UID]; ok && pr.Status.ObservedGeneration < previous {
+			counts[pr.Namespace]++
+		}
+	}
+	c.behind = behind
+	// like the poll style detectors, namespaces are zeroed vs. deleted, to allow for history based searches
+	for ns := range c.lastScan {
+		if _, ok := counts[ns]; !ok {
+			c.lagging.With(withTenantLabel(map[string]string{NS_LABEL: ns}, ns)).Set(0)
+		}
+	}
+	c.lastScan = map[string]struct{}{}
+	for ns, count := range counts {
+		c.lagging.With(withTenantLabel(map[string]string{NS_LABEL: ns}, ns)).Set(float64(count))
+		c.lastScan[ns] = struct{}{}
+	}
+}
+
+func (r *ExporterReconcile) resetObservedGenerationLagStats(ctx context.Context) {
+	if !r.collectors.enabled(CollectorObservedGenerationLag) || collectorHealth.isDisabled(CollectorObservedGenerationLag) {
+		return
+	}
+	prList := &v1.PipelineRunList{}
+	err := r.client.List(ctx, prList)
+	if err != nil {
+		controllerLog.Error(err, "pipeline run query for observed generation lag failed with an error")
+		return
+	}
+	r.generationLagCollector.scan(prList.Items)
+}
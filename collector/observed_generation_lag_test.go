@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestObservedGenerationLag(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	pipelineRun := func(ns, name string, generation, observed int64, done bool) *v1.PipelineRun {
+		pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, UID: types.UID(name), Generation: generation}}
+		pr.Status.Status = duckv1.Status{ObservedGeneration: observed}
+		if done {
+			pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+		}
+		assert.NoError(t, c.Create(ctx, pr))
+		return pr
+	}
+	pipelineRun("test-namespace", "caught-up", 1, 1, false)
+	behind := pipelineRun("test-namespace", "behind", 3, 1, false)
+	pipelineRun("test-namespace", "done", 5, 1, true)
+	unseen := pipelineRun("test-namespace-2", "unseen", 1, 0, false)
+
+	// a single scan behind is not lagging, as the PipelineRuns created just before a scan always are
+	reconciler := buildReconciler(c, nil, nil)
+	reconciler.resetObservedGenerationLagStats(ctx)
+	assert.Equal(t, 0, testutil.CollectAndCount(reconciler.generationLagCollector.lagging))
+
+	reconciler.resetObservedGenerationLagStats(ctx)
+	validateGaugeVec(t, reconciler.generationLagCollector.lagging, prometheus.Labels{NS_LABEL: "test-namespace"}, float64(1))
+	validateGaugeVec(t, reconciler.generationLagCollector.lagging, prometheus.Labels{NS_LABEL: "test-namespace-2"}, float64(1))
+
+	// catching up to the generation of the scan before is not lagging, even with a newer generation to observe
+	behind.Generation, behind.Status.ObservedGeneration = 4, 3
+	assert.NoError(t, c.Update(ctx, behind))
+	// namespaces without lagging PipelineRuns are zeroed
+	assert.NoError(t, c.Delete(ctx, unseen))
+	reconciler.resetObservedGenerationLagStats(ctx)
+	validateGaugeVec(t, reconciler.generationLagCollector.lagging, prometheus.Labels{NS_LABEL: "test-namespace"}, float64(0))
+	validateGaugeVec(t, reconciler.generationLagCollector.lagging, prometheus.Labels{NS_LABEL: "test-namespace-2"}, float64(0))
+	assert.Len(t, reconciler.generationLagCollector.behind, 1)
+
+	// a disabled collector does not scan
+	reconciler.collectors = collectorSet{CollectorPollers: {}}
+	reconciler.resetObservedGenerationLagStats(ctx)
+	assert.Len(t, reconciler.generationLagCollector.behind, 1)
+	reconciler.Close()
+}
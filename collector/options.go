@@ -0,0 +1,424 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// The names of the collectors that can be selected with WithCollectors; the heartbeat and the skipped event metrics
+// are always on, as they describe the collectors themselves
+const (
+	CollectorOverhead              = "overhead"
+	CollectorTaskRunGaps           = "taskrun-gaps"
+	CollectorPipelineRunScheduled  = "pipelinerun-scheduled"
+	CollectorPipelineRunPending    = "pipelinerun-pending"
+	CollectorTaskRunScheduled      = "taskrun-scheduled"
+	CollectorPipelineRefWait       = "pipeline-ref-wait"
+	CollectorTaskRefWait           = "task-ref-wait"
+	CollectorPodCreateToComplete   = "pod-create-to-complete"
+	CollectorPodCreateToKubeletAck = "pod-create-to-kubelet-ack"
+	CollectorPodKubeletToContainer = "pod-kubelet-to-container-start"
+	CollectorNamespaceLifecycle    = "namespace-lifecycle"
+	CollectorDuplicateRuns         = "duplicate-runs"
+	CollectorCustomRuns            = "customruns"
+	CollectorResourceVerification  = "resource-verification"
+	CollectorPodSecurity           = "pod-security"
+	CollectorPodPlacement          = "pod-placement"
+	CollectorChildPropagation      = "child-status-propagation"
+	CollectorTektonConfig          = "tekton-config"
+	CollectorPipelineRunResults    = "pipelinerun-results"
+	CollectorPodScheduled          = "pod-scheduled"
+	CollectorObservedGenerationLag = "observed-generation-lag"
+	CollectorPipelineRunQueue      = "pipelinerun-queue"
+	// CollectorPullSecrets is only started when PullSecretAging is set, as it reads the metadata of secrets
+	CollectorPullSecrets = "pull-secrets"
+	// CollectorPollers covers the metrics maintained by polling vs. events, like the throttled and stuck namespace gauges
+	CollectorPollers = "pollers"
+)
+
+// Settings are the tunables of the collectors; the zero value is the default behavior, and the exporter binary
+// binds each of them to the environment variable named by the corresponding EnvName constant
+type Settings struct {
+	// ClusterName is the cluster label value; when empty, the OpenShift ClusterVersion ID is used if available
+	ClusterName string
+	// TenantLabel adds a tenant label to the per namespace metrics
+	TenantLabel bool
+	// TenantNamespaceLabel is the namespace label holding the tenant, DEFAULT_TENANT_NS_LABEL when empty
+	TenantNamespaceLabel string
+	// TenantMetricsEndpoint serves the per tenant subsets of the metrics under TenantMetricsPathPrefix
+	TenantMetricsEndpoint bool
+	// FederationEndpoint serves the federation subset of the metrics under FederationPath
+	FederationEndpoint bool
+	// ActiveNamespaceWindow is how recent a PipelineRun has to be for its namespace to count as active, 168h when 0
+	ActiveNamespaceWindow time.Duration
+	// RemediationActions is a comma separated list of detector=action pairs
+	RemediationActions string
+	// RemediationWebhookURL is where the webhook remediation action posts to
+	RemediationWebhookURL string
+	// RemediationKillSwitch stops all remediation, while still logging what would have been done
+	RemediationKillSwitch bool
+	// DetectorSeverities is a comma separated list of detector=severity pairs
+	DetectorSeverities string
+	// PodCreateNamespaceFilter are namespaces left out of the pod create attempt deadlock detection
+	PodCreateNamespaceFilter []string
+	// PipelineRunKickoffNamespaceFilter are namespaces left out of the PipelineRun kickoff deadlock detection
+	PipelineRunKickoffNamespaceFilter []string
+	// TriggerTimeAnnotations are annotations, in addition to TRIGGER_TIME_ANNOTATION, holding when the external
+	// trigger of a PipelineRun was received, as an RFC 3339 time or unix seconds
+	TriggerTimeAnnotations []string
+	// QueueAnnotations mark the pending PipelineRuns held back by a concurrency limiter, as annotation=value, or just the
+	// annotation for any value, DefaultQueueAnnotations when empty
+	QueueAnnotations []string
+	// ResolvingPipelineRefReasons are condition reasons, in addition to ReasonResolvingPipelineRef, meaning the
+	// pipeline reference is still being resolved
+	ResolvingPipelineRefReasons []string
+	// ResolvingTaskRefReasons are condition reasons, in addition to the tekton constant, meaning the task reference
+	// is still being resolved
+	ResolvingTaskRefReasons []string
+	// ThrottleLabelServerSideApply applies the throttled label vs. patching it
+	ThrottleLabelServerSideApply bool
+	// ReasonStatusLabels gives cancelled, timed out, and stopped runs their own status label values
+	ReasonStatusLabels bool
+	// MetricCompatLevel picks between the legacy and stable names of renamed metrics, MetricCompatLegacy when 0
+	MetricCompatLevel int
+	// ConfigProfile names the configuration the exporter is deployed with, for the build info metric, DefaultConfigProfile
+	// when empty
+	ConfigProfile string
+	// LogRateLimits is a comma separated list of category=lines per second[/burst] pairs
+	LogRateLimits string
+	// LogSampling is a comma separated list of category=n pairs, logging one in every n lines of the category
+	LogSampling string
+	// RecentRuns is how many completed PipelineRuns to keep the overhead results for, DefaultRecentRuns when 0
+	RecentRuns int
+	// ObservationSampleRate is the share of the successful PipelineRuns whose overhead and gaps are observed; 0 or 1
+	// observes all of them
+	ObservationSampleRate float64
+	// ObservationSmoothingWindow is how long the overhead and gap observations of a burst of completed PipelineRuns
+	// are spread over, which shifts when they are observed, not how many series are scraped; 0 observes them right away
+	ObservationSmoothingWindow time.Duration
+	// CollectorFailureThreshold is how many panics or reconcile errors a collector may have within 10 minutes before it
+	// is disabled; 0 never disables the collectors
+	CollectorFailureThreshold int
+	// APIWriteBudget is how many writes a minute the exporter issues to the API server; 0 does not limit them
+	APIWriteBudget int
+	// MarkerTTL is how long after their creation the completed objects keep the exporter's labels and annotations;
+	// 0 never removes them
+	MarkerTTL time.Duration
+	// StoreTTL is how long the entries of the in-memory stores are kept after their last update, DefaultStoreTTL when 0
+	StoreTTL time.Duration
+	// StoreMaxEntries is how many entries each in-memory store keeps, DefaultStoreMaxEntries when 0
+	StoreMaxEntries int
+	// CustomRunStuckAfter is how long a CustomRun can run before it counts as stuck, DefaultCustomRunStuckAfter when 0
+	CustomRunStuckAfter time.Duration
+	// TektonNamespace is where the tekton controller and its ConfigMaps are, DefaultTektonNamespace when empty
+	TektonNamespace string
+	// TektonControlPlanes are the <name>=<tekton namespace>/<namespace regex> entries of the tekton control planes,
+	// in place of TektonNamespace, when the cluster has more than one
+	TektonControlPlanes []string
+	// NodePoolLabel is the node label holding the node pool of the pod placement metric, the first of
+	// DefaultNodePoolLabels the node has when empty
+	NodePoolLabel string
+	// PullSecretAging turns on the pull-secrets collector
+	PullSecretAging bool
+	// PipelineServiceAccounts are the service accounts whose pull secrets are tracked, DefaultPipelineServiceAccounts when empty
+	PipelineServiceAccounts []string
+	// PullSecretExpiryWarning is how long before its expiry a pull secret counts as expiring, DefaultPullSecretExpiryWarning when 0
+	PullSecretExpiryWarning time.Duration
+	// RHTAPRunLabels are the RHTAP run labels, of RHTAPRunLabelNames, added to the run level metrics
+	RHTAPRunLabels []string
+	// FilterThreshold is the total duration in milliseconds under which overhead is not recorded, DEFAULT_THRESHOLD when
+	// nil; 0 turns the filter off
+	FilterThreshold *float64
+}
+
+// EffectiveFilterThreshold is the FilterThreshold the overhead is filtered with
+func (s Settings) EffectiveFilterThreshold() float64 {
+	if s.FilterThreshold == nil {
+		return DEFAULT_THRESHOLD
+	}
+	return *s.FilterThreshold
+}
+
+// settings are package wide like the registerer and logger, as they are read all over the collectors
+var settings = Settings{}
+
+// exporterClock is where the pollers and trackers get the current time and their tickers from
+var exporterClock clock.WithTicker = clock.RealClock{}
+
+// Options configures NewCollector; the zero value, or no Option, matches what the exporter binary runs with
+type Options struct {
+	// Registerer is where the metrics are registered, with any cluster label applied; defaults to controller-runtime's registry
+	Registerer prometheus.Registerer
+	// Logger is used for everything not logged from a reconcile context; defaults to controller-runtime's logger
+	Logger *logr.Logger
+	// Collectors selects a subset of the collectors by name; all of them are started when empty
+	Collectors []string
+	// ReadOnly tracks throttling in memory vs. with a label, and disables events and remediation
+	ReadOnly bool
+	// DelegatedAuth is whether the metrics, debug or admin listener authenticates its callers with TokenReviews and
+	// authorizes them with SubjectAccessReviews; it only adds those to RequiredAccess, the listeners are the exporter's
+	DelegatedAuth bool
+	// PprofPort starts a pprof endpoint on the port when set, unless Debug is set
+	PprofPort string
+	// Debug serves pprof when set
+	Debug *Listener
+	// Admin serves the recent runs and daily aggregates APIs when set; they are served with pprof when not
+	Admin *Listener
+	// AggregateStorePath persists daily per namespace overhead aggregates to a bolt file at the path when set
+	AggregateStorePath string
+	// AggregateRetention is how long the daily aggregates are kept, DefaultAggregateRetention when 0
+	AggregateRetention time.Duration
+	// MetricSnapshotPath restores the counters and histograms from the file at the path on startup, and writes them to
+	// it periodically and on shutdown, when set
+	MetricSnapshotPath string
+	// GapExport writes the gap breakdown of every completed PipelineRun to S3 compatible storage when set
+	GapExport *GapExportConfig
+	// ExternalCollectors are run on their intervals, with the metrics they print served with the exporter's
+	ExternalCollectors []ExternalCollectorConfig
+	// TimingIngest accepts the milestones of PipelineRuns from external systems on the admin listener when set
+	TimingIngest bool
+	// Settings are the tunables of the collectors
+	Settings Settings
+	// Clock drives the pollers and any expiration of tracked state; defaults to the real clock, with
+	// k8s.io/utils/clock/testing's FakeClock available for deterministic tests
+	Clock clock.WithTicker
+	// LabelProvider adds labels to the run level metrics, limited to the RunLabels names
+	LabelProvider LabelProvider
+	// RunLabels are the label names LabelProvider is allowed to add
+	RunLabels []string
+	// FilterExtensions are combined with the built-in event filters of the named collectors, in order
+	FilterExtensions []FilterExtension
+	// SkipWatches builds the filter and reconciler without adding controllers for them to the manager, for callers that
+	// feed their own controllers' events to them
+	SkipWatches bool
+}
+
+type Option func(*Options)
+
+// FilterExtension combines a predicate with the built-in event filter of a collector, say to leave out the runs of
+// some service accounts; the predicate sees the v1 objects, even when the exporter watches v1beta1
+type FilterExtension struct {
+	// Collector is the name of the collector whose filter is extended
+	Collector string
+	// Predicate is the additional predicate
+	Predicate predicate.Predicate
+	// Or lets events through when either the built-in filter or the predicate does, vs. when both do; as the
+	// collectors without a reconcile record their metrics in their filter, Or only adds events for the overhead and
+	// gap collectors
+	Or bool
+}
+
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(o *Options) {
+		o.Registerer = registerer
+	}
+}
+
+func WithLogger(logger logr.Logger) Option {
+	return func(o *Options) {
+		o.Logger = &logger
+	}
+}
+
+func WithCollectors(names ...string) Option {
+	return func(o *Options) {
+		o.Collectors = append(o.Collectors, names...)
+	}
+}
+
+func WithReadOnly(readOnly bool) Option {
+	return func(o *Options) {
+		o.ReadOnly = readOnly
+	}
+}
+
+func WithDelegatedAuth(delegatedAuth bool) Option {
+	return func(o *Options) {
+		o.DelegatedAuth = delegatedAuth
+	}
+}
+
+func WithPprofPort(port string) Option {
+	return func(o *Options) {
+		o.PprofPort = port
+	}
+}
+
+// WithDebugListener serves pprof on the listener, in place of any WithPprofPort
+func WithDebugListener(l Listener) Option {
+	return func(o *Options) {
+		o.Debug = &l
+	}
+}
+
+// WithAdminListener serves the recent runs and daily aggregates APIs on the listener, vs. with pprof
+func WithAdminListener(l Listener) Option {
+	return func(o *Options) {
+		o.Admin = &l
+	}
+}
+
+func (o *Options) debugListener() *Listener {
+	if o.Debug != nil {
+		return o.Debug
+	}
+	if len(o.PprofPort) > 0 {
+		return &Listener{Address: ":" + o.PprofPort}
+	}
+	return nil
+}
+
+// WithAggregateStore persists daily per namespace overhead aggregates across restarts, served under AggregatesPath
+// on the admin listener
+func WithAggregateStore(path string, retention time.Duration) Option {
+	return func(o *Options) {
+		o.AggregateStorePath = path
+		o.AggregateRetention = retention
+	}
+}
+
+// WithMetricSnapshot carries the counters and histograms over restarts, through the file at path
+func WithMetricSnapshot(path string) Option {
+	return func(o *Options) {
+		o.MetricSnapshotPath = path
+	}
+}
+
+// WithGapExport writes the gap breakdown of every completed PipelineRun to S3 compatible storage
+func WithGapExport(config GapExportConfig) Option {
+	return func(o *Options) {
+		o.GapExport = &config
+	}
+}
+
+// WithExternalCollectors adds collectors run as separate binaries
+func WithExternalCollectors(configs ...ExternalCollectorConfig) Option {
+	return func(o *Options) {
+		o.ExternalCollectors = append(o.ExternalCollectors, configs...)
+	}
+}
+
+// WithTimingIngest accepts the milestones of PipelineRuns posted by external systems under IngestTimingsPath on the
+// admin listener, merged into their extended durations
+func WithTimingIngest() Option {
+	return func(o *Options) {
+		o.TimingIngest = true
+	}
+}
+
+func WithSettings(s Settings) Option {
+	return func(o *Options) {
+		o.Settings = s
+	}
+}
+
+func WithClock(c clock.WithTicker) Option {
+	return func(o *Options) {
+		o.Clock = c
+	}
+}
+
+// WithLabelProvider adds the allowed labels, with the values from the provider, to the run level metrics
+func WithLabelProvider(p LabelProvider, allowed ...string) Option {
+	return func(o *Options) {
+		o.LabelProvider = p
+		o.RunLabels = allowed
+	}
+}
+
+// WithFilterAnd only lets events through the named collector's filter that the predicate also lets through; the
+// predicate is evaluated first, so the built-in filter does not record anything for events it rejects
+func WithFilterAnd(collector string, p predicate.Predicate) Option {
+	return func(o *Options) {
+		o.FilterExtensions = append(o.FilterExtensions, FilterExtension{Collector: collector, Predicate: p})
+	}
+}
+
+// WithFilterOr also lets events through the named collector's filter that the predicate lets through
+func WithFilterOr(collector string, p predicate.Predicate) Option {
+	return func(o *Options) {
+		o.FilterExtensions = append(o.FilterExtensions, FilterExtension{Collector: collector, Predicate: p, Or: true})
+	}
+}
+
+func WithoutWatches() Option {
+	return func(o *Options) {
+		o.SkipWatches = true
+	}
+}
+
+func newOptions(opts ...Option) *Options {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// configure applies the package wide options, which need to be in place before any metrics are created
+func (o *Options) configure() {
+	settings = o.Settings
+	if o.Registerer != nil {
+		baseRegisterer = o.Registerer
+	}
+	if o.Logger != nil {
+		controllerLog = o.Logger.WithName("controller")
+		audits.configure(o.Logger.WithName("audit"))
+	}
+	if o.Clock != nil {
+		exporterClock = o.Clock
+	}
+	metricSnapshots.configure(o.MetricSnapshotPath)
+	provider, allowed := withRHTAPRunLabels(o.LabelProvider, o.RunLabels, settings.RHTAPRunLabels)
+	planes := tektonControlPlanes()
+	controlPlanes.configure(planes)
+	runLabels.configure(withControlPlaneRunLabel(provider, allowed, planes))
+	logLimits.configure(settings.LogRateLimits, settings.LogSampling)
+}
+
+// collectorSet is the set of selected collector names, where nil means all of them
+type collectorSet map[string]struct{}
+
+func (o *Options) collectorSet() collectorSet {
+	if len(o.Collectors) == 0 {
+		return nil
+	}
+	set := collectorSet{}
+	for _, name := range o.Collectors {
+		set[name] = struct{}{}
+	}
+	return set
+}
+
+func (s collectorSet) enabled(name string) bool {
+	if s == nil {
+		return true
+	}
+	_, ok := s[name]
+	return ok
+}
+
+// collectorFilter is the filter of the named collector, with any extensions, counting its decisions
+func (o *Options) collectorFilter(name string, builtin predicate.Predicate) predicate.Predicate {
+	return &countingFilter{name: name, inner: o.extendFilter(name, builtin)}
+}
+
+// extendFilter combines the built-in filter of the named collector with any extensions for it
+func (o *Options) extendFilter(name string, builtin predicate.Predicate) predicate.Predicate {
+	extended := builtin
+	for _, ext := range o.FilterExtensions {
+		if ext.Collector != name || ext.Predicate == nil {
+			continue
+		}
+		if ext.Or {
+			extended = predicate.Or(extended, ext.Predicate)
+			continue
+		}
+		extended = predicate.And(ext.Predicate, extended)
+	}
+	return extended
+}
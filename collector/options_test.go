@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	o := newOptions()
+	assert.Nil(t, o.collectorSet())
+	assert.True(t, o.collectorSet().enabled(CollectorOverhead))
+	assert.False(t, o.ReadOnly)
+
+	o = newOptions(WithCollectors(CollectorTaskRunGaps), WithCollectors(CollectorPollers), WithReadOnly(true), WithPprofPort("6000"), WithoutWatches())
+	set := o.collectorSet()
+	assert.True(t, set.enabled(CollectorTaskRunGaps))
+	assert.True(t, set.enabled(CollectorPollers))
+	assert.False(t, set.enabled(CollectorOverhead))
+	assert.True(t, o.ReadOnly)
+	assert.Equal(t, "6000", o.PprofPort)
+	assert.True(t, o.SkipWatches)
+}
+
+func TestOptionsRegisterer(t *testing.T) {
+	defer func() { baseRegisterer = metrics.Registry }()
+	registry := prometheus.NewRegistry()
+	newOptions(WithRegisterer(registry)).configure()
+	skipped := NewSkippedEventsMetric(exporterRegisterer())
+	skipped.With(map[string]string{FILTER_LABEL: "test", REASON_LABEL: SkipReasonPanic}).Inc()
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 1)
+	assert.Equal(t, "pipeline_service_exporter_skipped_events_total", families[0].GetName())
+	// and nothing went to controller-runtime's registry, so registering there again is fine
+	sameName := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_skipped_events_total",
+		Help: "test",
+	}, []string{FILTER_LABEL, REASON_LABEL})
+	assert.NoError(t, metrics.Registry.Register(sameName))
+	metrics.Registry.Unregister(sameName)
+}
+
+func TestReconcileSelectedCollectors(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr).Build()
+	r := buildReconciler(c, nil, nil)
+	defer r.Close()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}}
+
+	// the running PipelineRun without TaskRuns is only requeued by the overhead reconcile
+	result, err := r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, childWaitBaseDelay, result.RequeueAfter)
+	r.childWait.forget(pr)
+
+	r.collectors = newOptions(WithCollectors(CollectorTaskRunGaps)).collectorSet()
+	result, err = r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+}
+
+func TestExtendFilter(t *testing.T) {
+	notBot := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		pr, ok := obj.(*v1.PipelineRun)
+		return !ok || pr.Spec.TaskRunTemplate.ServiceAccountName != "bot"
+	})
+	builtin := &recordingPredicate{}
+	o := newOptions()
+	assert.Equal(t, builtin, o.extendFilter(CollectorOverhead, builtin))
+
+	o = newOptions(WithFilterAnd(CollectorOverhead, notBot))
+	// other collectors are left alone
+	assert.Equal(t, builtin, o.extendFilter(CollectorTaskRunGaps, builtin))
+	extended := o.extendFilter(CollectorOverhead, builtin)
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	pr.Spec.TaskRunTemplate.ServiceAccountName = "bot"
+	assert.False(t, extended.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	// the built-in filter never saw the event
+	assert.Nil(t, builtin.updated.ObjectNew)
+	pr.Spec.TaskRunTemplate.ServiceAccountName = "pipeline"
+	assert.True(t, extended.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	assert.Equal(t, pr, builtin.updated.ObjectNew)
+
+	// or lets through what the built-in filter would not
+	o = newOptions(WithFilterOr(CollectorTaskRunGaps, predicate.NewPredicateFuncs(func(client.Object) bool { return true })))
+	assert.True(t, o.extendFilter(CollectorTaskRunGaps, predicate.Not(builtin)).Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
@@ -13,15 +14,42 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 type OverheadCollector struct {
-	execution  *prometheus.HistogramVec
-	scheduling *prometheus.HistogramVec
+	registerer    *collectorRegisterer
+	execution     *prometheus.HistogramVec
+	scheduling    *prometheus.HistogramVec
+	patchFailures *prometheus.CounterVec
+	gapIncomplete *prometheus.CounterVec
+	skipped       *prometheus.CounterVec
+	// filterOutcomes counts which branch of filter each overhead hits, to see how much of the workload is deemed
+	// simple user pipelines under the FilterThreshold
+	filterOutcomes *prometheus.CounterVec
+	// alerts counts the PipelineRuns with alert level execution overhead, whose gaps are otherwise only logged
+	alerts *prometheus.CounterVec
+	// executionRollup and schedulingRollup are the cluster wide quantiles of the overheads over the last RollupWindow
+	executionRollup  *overheadRollup
+	schedulingRollup *overheadRollup
+	// successRate is the per pipeline share of the PipelineRuns completed in the last SuccessRateWindow that succeeded
+	successRate *pipelineSuccessRate
+	// sampleWeights is the number of PipelineRuns the observed overheads stand for, nil unless sampling
+	sampleWeights *prometheus.CounterVec
 }
 
+// OverheadSkipBelowThreshold is why the execution overhead of a PipelineRun is not recorded when it ran for less than
+// the FilterThreshold; the other reasons are the GapSkip and GapAbort constants, and SkipReasonMissingStartTime
+const OverheadSkipBelowThreshold = "below-threshold"
+
+// OVERHEAD_LABEL is which overhead, execution or scheduling, a filter outcome is for
+const OVERHEAD_LABEL = "overhead"
+
+const (
+	OverheadExecution  = "execution"
+	OverheadScheduling = "scheduling"
+)
+
 type ReconcileOverhead struct {
 	client        client.Client
 	scheme        *runtime.Scheme
@@ -48,16 +76,17 @@ func (f *overheadGapEventFilter) Update(e event.UpdateEvent) bool {
 	// calculate when the pipelinerun transtions to done, and then compare the kinds; note - do not need to check for cancel,
 	// as eventually those PRs will be marked done once any running TRs are done
 	if okold && oknew {
-		_, throttled := newPR.Labels[THROTTLED_LABEL]
+		// NOTE: confirmed that the succeeded condition is marked done and the completion timestamp is set at the same time;
+		// throttled pipelineruns are reconciled as well, as their scheduling overhead may still be recorded
+		if !oldPR.IsDone() && newPR.IsDone() {
+			return true
+		}
+		_, throttled := inMemoryThrottles.throttledBy(newPR)
 		// if this pipelinerun endured throttling while running, given the requeue'ing the pipeline controller unfortunately entails,
 		// we are punting on calculating overhead at this time
 		if throttled {
 			return false
 		}
-		// NOTE: confirmed that the succeeded condition is marked done and the completion timestamp is set at the same time
-		if !oldPR.IsDone() && newPR.IsDone() {
-			return true
-		}
 		// checking here to bypass the throttle check
 		if oldPR.IsDone() && newPR.IsDone() {
 			return false
@@ -88,41 +117,70 @@ func (f *overheadGapEventFilter) Update(e event.UpdateEvent) bool {
 func (f *overheadGapEventFilter) Generic(event.GenericEvent) bool {
 	return false
 }
-func NewOverheadCollector() *OverheadCollector {
-	labelNames := []string{NS_LABEL, STATUS_LABEL}
+func NewOverheadCollector(registerer prometheus.Registerer) *OverheadCollector {
+	reg := newCollectorRegisterer(registerer)
+	labelNames := withRunLabelNames(withTenantLabelName([]string{NS_LABEL, STATUS_LABEL}))
+	executionMetricHelp := "Proportion of time elapsed between the completion of a TaskRun and the start of the next TaskRun within a PipelineRun to the total duration of successful PipelineRuns"
 	executionMetric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "pipeline_service_execution_overhead_percentage",
-		Help:    "Proportion of time elapsed between the completion of a TaskRun and the start of the next TaskRun within a PipelineRun to the total duration of successful PipelineRuns",
+		Help:    deprecatedHelp(executionMetricHelp, "pipeline_service_execution_overhead_ratio"),
 		Buckets: prometheus.DefBuckets,
 	}, labelNames)
+	schedulingMetricHelp := "Proportion of time elapsed waiting for the pipeline controller to receive create events compared to the total duration of successful PipelineRuns"
 	schedulingMetric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "pipeline_service_schedule_overhead_percentage",
-		Help:    "Proportion of time elapsed waiting for the pipeline controller to receive create events compared to the total duration of successful PipelineRuns",
+		Help:    deprecatedHelp(schedulingMetricHelp, "pipeline_service_schedule_overhead_ratio"),
 		Buckets: prometheus.DefBuckets,
 	}, labelNames)
-	collector := &OverheadCollector{execution: executionMetric, scheduling: schedulingMetric}
-	metrics.Registry.MustRegister(executionMetric, schedulingMetric)
+	patchFailuresMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_throttle_label_patch_failures_total",
+		Help: "Number of PipelineRuns whose throttled label could not be patched after retries; their throttling is then only tracked in memory",
+	}, []string{NS_LABEL})
+	gapIncompleteMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_gap_calculation_incomplete_total",
+		Help: "Number of completed PipelineRuns whose TaskRuns were deleted before their overhead was calculated, so only their scheduling overhead was recorded",
+	}, []string{NS_LABEL})
+	skippedMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_overhead_calculations_skipped_total",
+		Help: "Number of completed PipelineRuns whose execution overhead was not recorded, by reason",
+	}, []string{NS_LABEL, REASON_LABEL})
+	filterOutcomesMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_overhead_filter_outcomes_total",
+		Help: "Number of completed PipelineRuns by overhead and whether it was filtered for a short total duration, was zero, or was recorded",
+	}, []string{OVERHEAD_LABEL, OUTCOME_LABEL})
+	alertsMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_overhead_alerts_total",
+		Help: "Number of completed PipelineRuns whose execution overhead reached the alert level",
+	}, []string{NS_LABEL, PIPELINE_LABEL})
+	executionRollup := newOverheadRollup("pipeline_service_execution_overhead_rollup",
+		"Cluster wide quantiles of the execution overhead of the PipelineRuns completed in the last 5 minutes")
+	schedulingRollup := newOverheadRollup("pipeline_service_schedule_overhead_rollup",
+		"Cluster wide quantiles of the scheduling overhead of the PipelineRuns completed in the last 5 minutes")
+	successRate := newPipelineSuccessRate()
+	sampleWeights := NewSampleWeightMetric(reg, "pipeline_service_overhead_sample_weight_total",
+		"Sum of the sample weights of the PipelineRuns whose overhead was observed, the number of completed PipelineRuns they stand for when sampling")
+	collector := &OverheadCollector{registerer: reg, execution: executionMetric, scheduling: schedulingMetric, patchFailures: patchFailuresMetric, gapIncomplete: gapIncompleteMetric, skipped: skippedMetric,
+		filterOutcomes: filterOutcomesMetric, alerts: alertsMetric, executionRollup: executionRollup, schedulingRollup: schedulingRollup, successRate: successRate, sampleWeights: sampleWeights}
+	reg.MustRegister(withStableName(executionMetric, "pipeline_service_execution_overhead_ratio", executionMetricHelp, labelNames),
+		withStableName(schedulingMetric, "pipeline_service_schedule_overhead_ratio", schedulingMetricHelp, labelNames),
+		patchFailuresMetric, gapIncompleteMetric, skippedMetric, filterOutcomesMetric, alertsMetric, executionRollup, schedulingRollup, successRate)
 	return collector
 }
 
-func accumulateGaps(pr *v1.PipelineRun, oc client.Client, ctx context.Context) (float64, []GapEntry, bool) {
-	if skipPipelineRun(pr) {
-		return float64(0), []GapEntry{}, false
-	}
-	gapTotal := float64(0)
-
-	sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes, abort := sortTaskRunsForGapCalculations(pr, oc, ctx)
-
-	if abort {
-		return float64(0), []GapEntry{}, false
-	}
+// skip counts a completed PipelineRun whose execution overhead is not recorded, so a sudden rise in, say, deleted
+// TaskRuns can be alerted on vs. just leaving the overhead histogram with fewer samples
+func (c *OverheadCollector) skip(pr *v1.PipelineRun, reason string) {
+	c.skipped.With(map[string]string{NS_LABEL: pr.Namespace, REASON_LABEL: reason}).Inc()
+}
 
-	gapEntries := calculateGaps(ctx, pr, oc, sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes)
-	for _, gapEntry := range gapEntries {
-		gapTotal = gapTotal + gapEntry.gap
-	}
+// filtered counts the branch of filter an overhead hits
+func (c *OverheadCollector) filtered(overhead string, numerator, denominator float64) {
+	c.filterOutcomes.With(map[string]string{OVERHEAD_LABEL: overhead, OUTCOME_LABEL: filterOutcome(numerator, denominator)}).Inc()
+}
 
-	return gapTotal, gapEntries, !abort
+// Close unregisters the metrics of the collector
+func (c *OverheadCollector) Close() {
+	c.registerer.Close()
 }
 
 func (r *ExporterReconcile) ReconcileOverhead(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
@@ -138,52 +196,140 @@ func (r *ExporterReconcile) ReconcileOverhead(ctx context.Context, request recon
 	}
 	if err != nil {
 		log.V(4).Info(fmt.Sprintf("ignoring deleted pipelinerun %q", request.NamespacedName))
+		r.childWait.forget(&v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: request.Namespace, Name: request.Name}})
 		return reconcile.Result{}, nil
 	}
 	succeedCondition := pr.Status.GetCondition(apis.ConditionSucceeded)
 	if succeedCondition != nil && !succeedCondition.IsUnknown() {
-		gapTotal, gapEntries, foundGaps := accumulateGaps(pr, r.client, ctx)
-		if foundGaps {
-			status := SUCCEEDED
-			if succeedCondition.IsFalse() {
-				status = FAILED
+		r.childWait.forget(pr)
+		run := newRecentRun(pr, succeedCondition)
+		defer r.recentRuns.add(run)
+		defer r.aggregates.add(run)
+		defer r.gapExport.add(run)
+		r.overheadCollector.successRate.observe(pr.Namespace, pipelineRunPipelineRef(pr), runStatus(succeedCondition))
+		if startTimeMissing("overhead", pr, pr.Status.StartTime) {
+			r.overheadCollector.skip(pr, SkipReasonMissingStartTime)
+			run.decide(SkipReasonMissingStartTime)
+			return reconcile.Result{}, nil
+		}
+		// sampling is decided before the TaskRuns are listed, as that listing is what sampling saves
+		weight, sampled := overheadSample(pr, runStatus(succeedCondition))
+		if !sampled {
+			log.V(4).Info(fmt.Sprintf("sampling out overhead metrics for %s", request.NamespacedName.String()))
+			r.overheadCollector.skip(pr, OverheadSkipSampledOut)
+			run.Gaps = GapResult{Entries: []GapEntry{}, Reason: OverheadSkipSampledOut}
+			run.decide(OverheadSkipSampledOut)
+			return reconcile.Result{}, nil
+		}
+		gaps := AccumulateGaps(ctx, r.client, pr)
+		run.Gaps = gaps
+		if !gaps.Calculated() {
+			r.overheadCollector.skip(pr, gaps.Reason)
+		}
+		if gaps.Calculated() {
+			labels := withRunLabels(withTenantLabel(map[string]string{NS_LABEL: pr.Namespace, STATUS_LABEL: runStatus(succeedCondition)}, pr.Namespace), pr)
+			totalDuration := gaps.Duration
+			overhead, ok := gaps.Overhead()
+			if gaps.Duration > 0 {
+				r.overheadCollector.filtered(OverheadExecution, gaps.Total, totalDuration)
 			}
-			labels := map[string]string{NS_LABEL: pr.Namespace, STATUS_LABEL: status}
-			totalDuration := float64(pr.Status.CompletionTime.Time.Sub(pr.Status.StartTime.Time).Milliseconds())
-			if !filter(gapTotal, totalDuration) {
-				overhead := gapTotal / totalDuration
+			if ok {
+				// the rollups see every PipelineRun, as they are cluster wide vs. per namespace series
+				r.overheadCollector.executionRollup.observe(overhead)
+			}
+			switch {
+			case ok:
 				log.V(4).Info(fmt.Sprintf("registering execution metric for %s with gap %v and total %v and overhead %v",
-					request.NamespacedName.String(), gapTotal, totalDuration, overhead))
+					request.NamespacedName.String(), gaps.Total, totalDuration, overhead))
 				if overhead >= ALERT_RATIO {
-					dbgStr := fmt.Sprintf("PipelineRun %s:%s has alert level execution overhead with a value of %v where gapTotal %v and totalDuration %v and individual gaps: \n", pr.Namespace, pr.Name, overhead, gapTotal, totalDuration)
-					for _, ge := range gapEntries {
-						s := fmt.Sprintf("  start %s end %s status %s gap %v\n", ge.completed, ge.upcoming, ge.status, ge.gap)
+					dbgStr := fmt.Sprintf("PipelineRun %s:%s has alert level execution overhead with a value of %v where gapTotal %v and totalDuration %v and individual gaps: \n", pr.Namespace, pr.Name, overhead, gaps.Total, totalDuration)
+					for _, ge := range gaps.Entries {
+						s := fmt.Sprintf("  start %s end %s status %s gap %v\n", ge.Completed, ge.Upcoming, ge.Status, ge.Gap)
 						dbgStr = dbgStr + s
 					}
-					log.Info(dbgStr)
+					limitLog(log, LogCategoryOverheadAlert).Info(dbgStr)
+					r.overheadCollector.alerts.With(map[string]string{NS_LABEL: pr.Namespace, PIPELINE_LABEL: pipelineRunPipelineRef(pr)}).Inc()
 				}
-				r.overheadCollector.execution.With(labels).Observe(overhead)
-			} else {
+				smoothedObservations.observe(r.overheadCollector.execution.With(labels), overhead)
+				run.observe("pipeline_service_execution_overhead_percentage", labels, overhead)
+			default:
 				log.V(4).Info(fmt.Sprintf("filtering execution metric for %s with gap %v and total %v",
-					request.NamespacedName.String(), gapTotal, totalDuration))
+					request.NamespacedName.String(), gaps.Total, totalDuration))
+				r.overheadCollector.skip(pr, OverheadSkipBelowThreshold)
 			}
-			scheduleDuration := calculateScheduledDuration(pr.CreationTimestamp.Time, pr.Status.StartTime.Time)
-			if !filter(scheduleDuration, totalDuration) {
-				overhead := scheduleDuration / totalDuration
-				log.V(4).Info(fmt.Sprintf("registering scheduling metric for %s with gap %v and total %v and overhead %v",
-					request.NamespacedName.String(), scheduleDuration, totalDuration, overhead))
-				r.overheadCollector.scheduling.With(labels).Observe(overhead)
-			} else {
-				log.V(4).Info(fmt.Sprintf("filtering scheduling metric for %s with gap %v and total %v",
-					request.NamespacedName.String(), scheduleDuration, totalDuration))
+			r.observeSchedulingOverhead(ctx, pr, labels, totalDuration, run)
+		} else if gaps.Reason == GapAbortTaskRunDeleted {
+			// with the TaskRuns pruned, the PipelineRun alone still gives us the scheduling overhead
+			log.V(4).Info(fmt.Sprintf("taskruns of %s were deleted, only registering the scheduling metric", request.NamespacedName.String()))
+			r.overheadCollector.gapIncomplete.With(map[string]string{NS_LABEL: pr.Namespace}).Inc()
+			labels := withRunLabels(withTenantLabel(map[string]string{NS_LABEL: pr.Namespace, STATUS_LABEL: runStatus(succeedCondition)}, pr.Namespace), pr)
+			totalDuration := float64(pr.Status.CompletionTime.Time.Sub(pr.Status.StartTime.Time).Milliseconds())
+			r.observeSchedulingOverhead(ctx, pr, labels, totalDuration, run)
+		} else if gaps.Reason == GapSkipThrottled {
+			// when the first TaskRun was throttled at creation, the PipelineRun is otherwise healthy, so rather than
+			// discarding it, its scheduling overhead is recorded against its duration without the throttling
+			if throttledDuration, ok := kickoffThrottledDuration(ctx, r.client, pr); ok {
+				totalDuration := float64(pr.Status.CompletionTime.Time.Sub(pr.Status.StartTime.Time).Milliseconds()) - throttledDuration
+				log.V(4).Info(fmt.Sprintf("first taskrun of %s was throttled for %v, only registering the scheduling metric with total %v",
+					request.NamespacedName.String(), throttledDuration, totalDuration))
+				labels := withRunLabels(withTenantLabel(map[string]string{NS_LABEL: pr.Namespace, STATUS_LABEL: runStatus(succeedCondition)}, pr.Namespace), pr)
+				if totalDuration > 0 {
+					r.observeSchedulingOverhead(ctx, pr, labels, totalDuration, run)
+				}
 			}
 		}
+		// like Explain, the PipelineRun counts as recorded if either of its overheads is
+		switch {
+		case len(run.Observations) > 0:
+			addSampleWeight(r.overheadCollector.sampleWeights, pr, weight)
+			run.decide("")
+		case !gaps.Calculated():
+			run.decide(gaps.Reason)
+		default:
+			run.decide(ExplainFiltered)
+		}
 	} else {
 		if !isPipelineRunGoing(pr, r.client, ctx) {
-			return reconcile.Result{Requeue: true}, nil
+			return r.childWait.requeue(pr), nil
 		}
+		r.childWait.forget(pr)
 		// if still running, we set the label here instead of in the filter so we can retry on error if need be
-		return reconcile.Result{}, tagPipelineRunsWithTaskRunsGettingThrottled(pr, r.client, ctx)
+		if r.readOnly {
+			return reconcile.Result{}, trackPipelineRunsWithTaskRunsGettingThrottled(pr, r.client, ctx)
+		}
+		err = tagPipelineRunsWithTaskRunsGettingThrottled(pr, r.client, ctx, r.overheadCollector.patchFailures)
+		if writeBudgetExceeded(err) {
+			return reconcile.Result{RequeueAfter: writeBudgetRequeue()}, nil
+		}
+		return reconcile.Result{}, err
 	}
 	return reconcile.Result{}, nil
 }
+
+// schedulingOverhead is the share of a PipelineRun's duration spent waiting to be started, false if it is filtered
+func schedulingOverhead(pr *v1.PipelineRun, totalDuration float64) (float64, float64, bool) {
+	scheduleDuration := calculateScheduledDuration(pr.CreationTimestamp.Time, pr.Status.StartTime.Time)
+	if filter(scheduleDuration, totalDuration) {
+		return scheduleDuration, 0, false
+	}
+	return scheduleDuration, scheduleDuration / totalDuration, true
+}
+
+func (r *ExporterReconcile) observeSchedulingOverhead(ctx context.Context, pr *v1.PipelineRun, labels map[string]string, totalDuration float64, run *RecentRun) {
+	log := log.FromContext(ctx)
+	scheduleDuration, overhead, ok := schedulingOverhead(pr, totalDuration)
+	r.overheadCollector.filtered(OverheadScheduling, scheduleDuration, totalDuration)
+	if ok {
+		r.overheadCollector.schedulingRollup.observe(overhead)
+	}
+	switch {
+	case ok:
+		log.V(4).Info(fmt.Sprintf("registering scheduling metric for %s:%s with gap %v and total %v and overhead %v",
+			pr.Namespace, pr.Name, scheduleDuration, totalDuration, overhead))
+		smoothedObservations.observe(r.overheadCollector.scheduling.With(labels), overhead)
+		run.observe("pipeline_service_schedule_overhead_percentage", labels, overhead)
+	default:
+		log.V(4).Info(fmt.Sprintf("filtering scheduling metric for %s:%s with gap %v and total %v",
+			pr.Namespace, pr.Name, scheduleDuration, totalDuration))
+	}
+}
@@ -188,6 +188,7 @@ func TestOverheadGapEventFilter_Update(t *testing.T) {
 					},
 				},
 			},
+			expectedRC: true,
 		},
 		{
 			name:  "just done succeed",
@@ -343,7 +344,9 @@ func TestReconcileOverhead_Reconcile(t *testing.T) {
 		assert.Equal(t, *metric.Histogram.SampleCount, uint64(0))
 
 	}
-	unregisterStats(overheadReconciler)
+	validateCounterVec(t, overheadReconciler.overheadCollector.filterOutcomes, prometheus.Labels{OVERHEAD_LABEL: OverheadScheduling, OUTCOME_LABEL: FilterOutcomeZeroOverhead}, float64(1))
+	validateCounterVec(t, overheadReconciler.overheadCollector.filterOutcomes, prometheus.Labels{OVERHEAD_LABEL: OverheadScheduling, OUTCOME_LABEL: FilterOutcomeShortDuration}, float64(len(prs)-1))
+	overheadReconciler.Close()
 }
 
 func TestReconcileOverhead_Reconcile_MissingTaskRuns(t *testing.T) {
@@ -362,7 +365,8 @@ func TestReconcileOverhead_Reconcile_MissingTaskRuns(t *testing.T) {
 	if err != nil {
 		t.Fatalf(fmt.Sprintf("%s", err.Error()))
 	}
-	// but in this test we make sure no stats are generated if the taskruns are missing
+	// but in this test we make sure no execution stats are generated if the taskruns are missing, only scheduling stats
+	// from the pipelinerun alone
 
 	ctx := context.TODO()
 	for _, prv1beta1 := range prs {
@@ -381,9 +385,107 @@ func TestReconcileOverhead_Reconcile_MissingTaskRuns(t *testing.T) {
 		_, err = overheadReconciler.Reconcile(ctx, request)
 		label := prometheus.Labels{NS_LABEL: pr.Namespace, STATUS_LABEL: SUCCEEDED}
 		validateHistogramVecZeroCount(t, overheadReconciler.overheadCollector.execution, label)
+		validateCounterVec(t, overheadReconciler.overheadCollector.gapIncomplete, prometheus.Labels{NS_LABEL: pr.Namespace}, float64(1))
+		validateCounterVec(t, overheadReconciler.overheadCollector.skipped, prometheus.Labels{NS_LABEL: pr.Namespace, REASON_LABEL: GapAbortTaskRunDeleted}, float64(1))
 	}
-	unregisterStats(overheadReconciler)
+	// the other pipelinerun ran for less than the filter threshold
+	label := prometheus.Labels{NS_LABEL: "test-rhtap-95-tenant", STATUS_LABEL: SUCCEEDED}
+	validateHistogramVec(t, overheadReconciler.overheadCollector.scheduling, label, false)
+	overheadReconciler.Close()
+
+}
 
+func TestReconcileOverhead_Reconcile_Skipped(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	now := time.Now().UTC()
+	done := duckv1.Status{Conditions: duckv1.Conditions{{Type: "Succeeded", Status: corev1.ConditionTrue}}}
+	prs := []*v1.PipelineRun{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "no-start-time", Namespace: "test-namespace", CreationTimestamp: metav1.NewTime(now)},
+			Status:     v1.PipelineRunStatus{Status: done},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "no-taskruns", Namespace: "test-namespace", CreationTimestamp: metav1.NewTime(now)},
+			Status: v1.PipelineRunStatus{
+				Status: done,
+				PipelineRunStatusFields: v1.PipelineRunStatusFields{
+					StartTime:      &metav1.Time{Time: now},
+					CompletionTime: &metav1.Time{Time: now.Add(time.Minute)},
+				},
+			},
+		},
+	}
+	objs := []client.Object{}
+	for _, pr := range prs {
+		objs = append(objs, pr)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	overheadReconciler := buildReconciler(c, nil, nil)
+	ctx := context.TODO()
+	for _, pr := range prs {
+		_, err := overheadReconciler.ReconcileOverhead(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}})
+		assert.NoError(t, err)
+	}
+	validateCounterVec(t, overheadReconciler.overheadCollector.skipped, prometheus.Labels{NS_LABEL: "test-namespace", REASON_LABEL: SkipReasonMissingStartTime}, float64(1))
+	validateCounterVec(t, overheadReconciler.overheadCollector.skipped, prometheus.Labels{NS_LABEL: "test-namespace", REASON_LABEL: GapSkipNoTaskRuns}, float64(1))
+	overheadReconciler.Close()
+}
+
+func TestReconcileOverhead_Reconcile_FirstTaskRunThrottled(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	_ = corev1.AddToScheme(scheme)
+	now := time.Now().UTC()
+	done := duckv1.Status{Conditions: duckv1.Conditions{{Type: "Succeeded", Status: corev1.ConditionTrue}}}
+	taskRun := func(name string, created, completed time.Duration) *v1.TaskRun {
+		return &v1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-namespace", CreationTimestamp: metav1.NewTime(now.Add(created))},
+			Status: v1.TaskRunStatus{
+				Status: done,
+				TaskRunStatusFields: v1.TaskRunStatusFields{
+					PodName:        name + "-pod",
+					StartTime:      &metav1.Time{Time: now.Add(created)},
+					CompletionTime: &metav1.Time{Time: now.Add(completed)},
+				},
+			},
+		}
+	}
+	pr := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pipelinerun", Namespace: "test-namespace", CreationTimestamp: metav1.NewTime(now),
+			Labels: map[string]string{THROTTLED_LABEL: "test-taskrun-1"}},
+		Status: v1.PipelineRunStatus{
+			Status: done,
+			PipelineRunStatusFields: v1.PipelineRunStatusFields{
+				ChildReferences: []v1.ChildStatusReference{
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "test-taskrun-1"},
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "test-taskrun-2"},
+				},
+				StartTime:      &metav1.Time{Time: now.Add(5 * time.Second)},
+				CompletionTime: &metav1.Time{Time: now.Add(10*time.Minute + 5*time.Second)},
+			},
+		},
+	}
+	// the first TaskRun waited 2 minutes on quota for its pod
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-taskrun-1-pod", Namespace: "test-namespace",
+		CreationTimestamp: metav1.NewTime(now.Add(6*time.Second + 2*time.Minute))}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr, pod,
+		taskRun("test-taskrun-1", 6*time.Second, 5*time.Minute), taskRun("test-taskrun-2", 5*time.Minute+time.Second, 10*time.Minute)).Build()
+	overheadReconciler := buildReconciler(c, nil, nil)
+	_, err := overheadReconciler.ReconcileOverhead(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}})
+	assert.NoError(t, err)
+
+	label := prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED}
+	validateHistogramVecZeroCount(t, overheadReconciler.overheadCollector.execution, label)
+	observer, err := overheadReconciler.overheadCollector.scheduling.GetMetricWith(label)
+	assert.NoError(t, err)
+	metric := &dto.Metric{}
+	observer.(prometheus.Histogram).Write(metric)
+	assert.Equal(t, uint64(1), metric.Histogram.GetSampleCount())
+	// 5 seconds waiting to be started out of the 10 minutes without the 2 minutes throttled
+	assert.Equal(t, float64(5000)/float64(480000), metric.Histogram.GetSampleSum())
+	validateCounterVec(t, overheadReconciler.overheadCollector.skipped, prometheus.Labels{NS_LABEL: "test-namespace", REASON_LABEL: GapSkipThrottled}, float64(1))
+	overheadReconciler.Close()
 }
 
 func TestReconcileOverhead_Reconcile_MockWithHighOverhead(t *testing.T) {
@@ -533,7 +635,8 @@ func TestReconcileOverhead_Reconcile_MockWithHighOverhead(t *testing.T) {
 
 	label := prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED}
 	validateHistogramVec(t, overheadReconciler.overheadCollector.execution, label, false)
-	unregisterStats(overheadReconciler)
+	validateCounterVec(t, overheadReconciler.overheadCollector.alerts, prometheus.Labels{NS_LABEL: "test-namespace", PIPELINE_LABEL: "test-pipeline"}, float64(1))
+	overheadReconciler.Close()
 }
 
 func TestReconcileOverhead_Reconcile_MockWithHighOverheadButThrottled(t *testing.T) {
@@ -687,6 +790,6 @@ func TestReconcileOverhead_Reconcile_MockWithHighOverheadButThrottled(t *testing
 
 	label := prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED}
 	validateHistogramVecZeroCount(t, overheadReconciler.overheadCollector.execution, label)
-	unregisterStats(overheadReconciler)
+	overheadReconciler.Close()
 
 }
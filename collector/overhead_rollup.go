@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	QUANTILE_LABEL = "quantile"
+	// RollupWindow is how far back the cluster wide overhead quantiles look
+	RollupWindow = 5 * time.Minute
+)
+
+var rollupQuantiles = []float64{0.5, 0.95}
+
+type rollupSample struct {
+	at    time.Time
+	value float64
+}
+
+// overheadRollup publishes cluster wide quantiles of an overhead over the last RollupWindow as gauges, for consumers
+// that can not run histogram_quantile over thousands of per namespace series; the quantiles are exact, computed from
+// the samples in the window when scraped, and no series are published when the window is empty
+type overheadRollup struct {
+	lock    sync.Mutex
+	desc    *prometheus.Desc
+	samples []rollupSample
+}
+
+func newOverheadRollup(name, help string) *overheadRollup {
+	return &overheadRollup{desc: prometheus.NewDesc(name, help, []string{QUANTILE_LABEL}, nil)}
+}
+
+func (r *overheadRollup) observe(value float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := exporterClock.Now()
+	r.prune(now)
+	r.samples = append(r.samples, rollupSample{at: now, value: value})
+}
+
+// prune drops the samples older than the window; they are appended in time order, so those are the first ones
+func (r *overheadRollup) prune(now time.Time) {
+	oldest := now.Add(-RollupWindow)
+	i := 0
+	for i < len(r.samples) && r.samples[i].at.Before(oldest) {
+		i++
+	}
+	r.samples = r.samples[i:]
+}
+
+func (r *overheadRollup) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.desc
+}
+
+func (r *overheadRollup) Collect(ch chan<- prometheus.Metric) {
+	r.lock.Lock()
+	r.prune(exporterClock.Now())
+	values := make([]float64, 0, len(r.samples))
+	for _, s := range r.samples {
+		values = append(values, s.value)
+	}
+	r.lock.Unlock()
+	if len(values) == 0 {
+		return
+	}
+	sort.Float64s(values)
+	for _, q := range rollupQuantiles {
+		ch <- prometheus.MustNewConstMetric(r.desc, prometheus.GaugeValue, quantile(values, q), strconv.FormatFloat(q, 'f', -1, 64))
+	}
+}
+
+// quantile is the nearest rank quantile of the sorted values
+func quantile(sorted []float64, q float64) float64 {
+	rank := int(math.Ceil(q * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	testclock "k8s.io/utils/clock/testing"
+)
+
+func TestOverheadRollup(t *testing.T) {
+	fakeClock := testclock.NewFakeClock(time.Now())
+	defer setClock(fakeClock)()
+	registry := prometheus.NewPedanticRegistry()
+	rollup := newOverheadRollup("test_overhead_rollup", "test")
+	registry.MustRegister(rollup)
+
+	// nothing is published for an empty window
+	assert.Empty(t, gatherFamilies(t, registry))
+
+	// samples that will have left the window
+	for i := 0; i < 10; i++ {
+		rollup.observe(0.9)
+	}
+	fakeClock.Step(RollupWindow)
+	for i := 1; i <= 20; i++ {
+		rollup.observe(float64(i) / 100)
+	}
+	fakeClock.Step(time.Second)
+	families := gatherFamilies(t, registry)
+	quantiles := map[string]float64{}
+	for _, m := range families["test_overhead_rollup"].GetMetric() {
+		quantiles[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+	}
+	assert.Equal(t, map[string]float64{"0.5": 0.1, "0.95": 0.19}, quantiles)
+
+	fakeClock.Step(RollupWindow)
+	assert.Empty(t, gatherFamilies(t, registry))
+}
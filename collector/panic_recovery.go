@@ -0,0 +1,180 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const HANDLER_LABEL = "handler"
+
+// panicTracker counts the panics recovered from the filters, reconciles, runnables, and event handlers, which would
+// otherwise take down every collector in the manager over a single malformed object
+type panicTracker struct {
+	lock   sync.Mutex
+	panics *prometheus.CounterVec
+}
+
+var recoveredPanics = &panicTracker{}
+
+func NewRecoveredPanicsMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	panics := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_recovered_panics_total",
+		Help: "Number of panics recovered from the event filters, reconciles, runnables, and event handlers, by the one that panicked",
+	}, []string{HANDLER_LABEL})
+	registerer.MustRegister(panics)
+	return panics
+}
+
+func (p *panicTracker) enable(panics *prometheus.CounterVec) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.panics = panics
+}
+
+// recovered logs the object the handler panicked on, with the stack, and counts the panic
+func (p *panicTracker) recovered(handler, ns, name string, r interface{}) {
+	if len(ns) == 0 && len(name) == 0 {
+		// the runnables do not panic on any one object
+		controllerLog.Info(fmt.Sprintf("WARNING: %s panicked: %v\n%s", handler, r, debug.Stack()))
+	} else {
+		controllerLog.Info(fmt.Sprintf("WARNING: %s panicked on %s:%s: %v\n%s", handler, ns, name, r, debug.Stack()))
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.panics == nil {
+		return
+	}
+	p.panics.With(map[string]string{HANDLER_LABEL: handler}).Inc()
+}
+
+func objectKey(obj client.Object) (string, string) {
+	if obj == nil {
+		return "", ""
+	}
+	return obj.GetNamespace(), obj.GetName()
+}
+
+// recoveringFilter is the outermost filter, so that a panic anywhere in the filters, say when converting a v1beta1
+// object, drops the event vs. the exporter; ExporterFilter also recovers around each of its predicates, so the others
+// still see the event
+type recoveringFilter struct {
+	inner predicate.Predicate
+}
+
+func (f *recoveringFilter) recover(obj client.Object, result *bool) {
+	if r := recover(); r != nil {
+		ns, name := objectKey(obj)
+		recoveredPanics.recovered(fmt.Sprintf("%T", f.inner), ns, name, r)
+		*result = false
+	}
+}
+
+func (f *recoveringFilter) Create(e event.CreateEvent) (result bool) {
+	defer f.recover(e.Object, &result)
+	return f.inner.Create(e)
+}
+
+func (f *recoveringFilter) Delete(e event.DeleteEvent) (result bool) {
+	defer f.recover(e.Object, &result)
+	return f.inner.Delete(e)
+}
+
+func (f *recoveringFilter) Update(e event.UpdateEvent) (result bool) {
+	defer f.recover(e.ObjectNew, &result)
+	return f.inner.Update(e)
+}
+
+func (f *recoveringFilter) Generic(e event.GenericEvent) (result bool) {
+	defer f.recover(e.Object, &result)
+	return f.inner.Generic(e)
+}
+
+// safeReconcile keeps the reconcile of one collector panicking from taking down the exporter, or from keeping the
+// other collectors from reconciling the object; like controller-runtime's RecoverPanic, the panic is returned as an
+// error, so the object is retried with backoff in case the panic was down to a transient state; both panics and errors
+// count against the collector's health
+func safeReconcile(name string, reconcileFunc func(context.Context, reconcile.Request) (reconcile.Result, error)) func(context.Context, reconcile.Request) (reconcile.Result, error) {
+	return func(ctx context.Context, request reconcile.Request) (result reconcile.Result, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				recoveredPanics.recovered(name, request.Namespace, request.Name, r)
+				collectorHealth.failed(name, DisabledReasonPanics)
+				result, err = reconcile.Result{}, fmt.Errorf("panic: %v [recovered]", r)
+				return
+			}
+			if err != nil {
+				collectorHealth.failed(name, DisabledReasonErrors)
+			}
+		}()
+		return reconcileFunc(ctx, request)
+	}
+}
+
+// runnableRestartDelay is how long a runnable that panicked waits before it is started again
+var runnableRestartDelay = 30 * time.Second
+
+// recoveringRunnable starts a runnable of the manager, like a poller, janitor, or listener, again after it panicked,
+// vs. the panic taking down the exporter; the runnables keep their state in their own structs, so a restart picks up
+// where the last run left off; a runnable returning, with or without an error, is left to the manager as before
+type recoveringRunnable struct {
+	inner manager.Runnable
+}
+
+// addRecovering adds the runnable to the manager, recovering its panics
+func addRecovering(mgr manager.Manager, runnable manager.Runnable) error {
+	return mgr.Add(&recoveringRunnable{inner: runnable})
+}
+
+func (r *recoveringRunnable) Start(ctx context.Context) error {
+	for {
+		panicked, err := r.start(ctx)
+		if !panicked {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-exporterClock.After(runnableRestartDelay):
+		}
+	}
+}
+
+func (r *recoveringRunnable) start(ctx context.Context) (panicked bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			recoveredPanics.recovered(fmt.Sprintf("%T", r.inner), "", "", p)
+			panicked = true
+		}
+	}()
+	return false, r.inner.Start(ctx)
+}
+
+// NeedLeaderElection is that of the runnable, with the manager's default of true when it does not say
+func (r *recoveringRunnable) NeedLeaderElection() bool {
+	if l, ok := r.inner.(manager.LeaderElectionRunnable); ok {
+		return l.NeedLeaderElection()
+	}
+	return true
+}
+
+// recoverHandler is deferred by the informer event handlers, which, unlike the filters, are called by client-go's
+// informers directly
+func recoverHandler(handler string, obj interface{}) {
+	if r := recover(); r != nil {
+		ns, name := "", ""
+		if o, ok := obj.(client.Object); ok {
+			ns, name = objectKey(o)
+		}
+		recoveredPanics.recovered(handler, ns, name, r)
+	}
+}
@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func enableTestPanics() *prometheus.CounterVec {
+	panics := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_recovered_panics_total", Help: "test"},
+		[]string{HANDLER_LABEL})
+	recoveredPanics.enable(panics)
+	return panics
+}
+
+func TestRecoveringFilter(t *testing.T) {
+	panics := enableTestPanics()
+	defer recoveredPanics.enable(nil)
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	f := &recoveringFilter{inner: &panickingPredicate{}}
+	assert.False(t, f.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	assert.False(t, f.Create(event.CreateEvent{Object: pr}))
+	validateCounterVec(t, panics, prometheus.Labels{HANDLER_LABEL: "*collector.panickingPredicate"}, float64(1))
+
+	// within the ExporterFilter, the panic is recovered around the predicate, so the others still see the event
+	recorder := &recordingPredicate{}
+	f = &recoveringFilter{inner: &ExporterFilter{
+		noReconcile:  []predicate.Predicate{&panickingPredicate{}},
+		yesReconcile: []predicate.Predicate{recorder},
+	}}
+	assert.True(t, f.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	assert.Equal(t, pr, recorder.updated.ObjectNew)
+	validateCounterVec(t, panics, prometheus.Labels{HANDLER_LABEL: "*collector.panickingPredicate"}, float64(2))
+}
+
+func TestSafeReconcile(t *testing.T) {
+	panics := enableTestPanics()
+	defer recoveredPanics.enable(nil)
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "test-1"}}
+	result, err := safeReconcile(CollectorOverhead, func(context.Context, reconcile.Request) (reconcile.Result, error) {
+		var pr *v1.PipelineRun
+		return reconcile.Result{Requeue: pr.IsDone()}, nil
+	})(context.TODO(), request)
+	assert.ErrorContains(t, err, "[recovered]")
+	assert.Equal(t, reconcile.Result{}, result)
+	validateCounterVec(t, panics, prometheus.Labels{HANDLER_LABEL: CollectorOverhead}, float64(1))
+
+	result, err = safeReconcile(CollectorOverhead, func(context.Context, reconcile.Request) (reconcile.Result, error) {
+		return reconcile.Result{Requeue: true}, nil
+	})(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.True(t, result.Requeue)
+	validateCounterVec(t, panics, prometheus.Labels{HANDLER_LABEL: CollectorOverhead}, float64(1))
+}
+
+// panickingRunnable panics on its first start, then returns
+type panickingRunnable struct {
+	starts int
+}
+
+func (r *panickingRunnable) Start(context.Context) error {
+	r.starts++
+	if r.starts == 1 {
+		var pr *v1.PipelineRun
+		_ = pr.IsDone()
+	}
+	return nil
+}
+
+func (r *panickingRunnable) NeedLeaderElection() bool {
+	return false
+}
+
+func TestRecoveringRunnable(t *testing.T) {
+	panics := enableTestPanics()
+	defer recoveredPanics.enable(nil)
+	delay := runnableRestartDelay
+	runnableRestartDelay = 0
+	defer func() { runnableRestartDelay = delay }()
+
+	// the runnable is started again after its panic
+	inner := &panickingRunnable{}
+	r := &recoveringRunnable{inner: inner}
+	assert.NoError(t, r.Start(context.Background()))
+	assert.Equal(t, 2, inner.starts)
+	validateCounterVec(t, panics, prometheus.Labels{HANDLER_LABEL: "*collector.panickingRunnable"}, float64(1))
+	assert.False(t, r.NeedLeaderElection())
+	// the manager's default applies to runnables without a say
+	assert.True(t, (&recoveringRunnable{inner: &storePruner{}}).NeedLeaderElection())
+
+	// a stopped exporter does not restart it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runnableRestartDelay = time.Hour
+	inner = &panickingRunnable{}
+	assert.NoError(t, (&recoveringRunnable{inner: inner}).Start(ctx))
+	assert.Equal(t, 1, inner.starts)
+}
+
+func TestRecoverHandler(t *testing.T) {
+	panics := enableTestPanics()
+	defer recoveredPanics.enable(nil)
+	assert.NotPanics(t, func() {
+		defer recoverHandler("namespace-delete", &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-namespace"}})
+		panic("test")
+	})
+	validateCounterVec(t, panics, prometheus.Labels{HANDLER_LABEL: "namespace-delete"}, float64(1))
+}
@@ -6,19 +6,17 @@ import (
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	"knative.dev/pkg/apis"
-	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
-func NewPipelineReferenceWaitTimeMetric() *prometheus.HistogramVec {
-	labelNames := []string{NS_LABEL}
+func NewPipelineReferenceWaitTimeMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	labelNames := withControlPlaneLabelName([]string{NS_LABEL})
 	waitMetric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "pipelinerun_pipeline_resolution_wait_milliseconds",
 		Help:    "Duration in milliseconds for a resolution request for a pipeline reference needed by a pipelinerun to be recognized as complete by the pipelinerun reconciler in the tekton controller. ",
 		Buckets: prometheus.ExponentialBuckets(float64(100), float64(5), 6),
 	}, labelNames)
-	metrics.Registry.MustRegister(waitMetric)
+	registerer.MustRegister(waitMetric)
 	return waitMetric
 }
 
@@ -29,6 +27,7 @@ type pipelineRefWaitTimeFilter struct {
 	// message so the condition should not change on any multiple calls.  That said, we'll add a log that captures that, and
 	// if it is occuring, we'll need to track the original transition time either via state in this filter, or as a label/annotation
 	// on the pipelinerun
+	resolvingReasons map[string]struct{}
 }
 
 func (f *pipelineRefWaitTimeFilter) Create(event.CreateEvent) bool {
@@ -54,7 +53,7 @@ func (f *pipelineRefWaitTimeFilter) Update(e event.UpdateEvent) bool {
 		if !oldPR.IsDone() && newPR.IsDone() {
 			// if we did not use some sort of resolve, set metric to 0
 			if newPR.Spec.PipelineRef == nil {
-				labels := map[string]string{NS_LABEL: newPR.Namespace}
+				labels := withControlPlaneLabel(map[string]string{NS_LABEL: newPR.Namespace}, newPR.Namespace)
 				f.waitDuration.With(labels).Observe(float64(0))
 			}
 		}
@@ -65,24 +64,22 @@ func (f *pipelineRefWaitTimeFilter) Update(e event.UpdateEvent) bool {
 		if oldSucceedCondtition == nil {
 			return false
 		}
-		oldReason := oldSucceedCondtition.Reason
-		newReason := newSucceedCondition.Reason
-		// wrt direct string reference, waiting for tag/release with constant moved to the api package
-		if oldReason == "ResolvingPipelineRef" && newReason != "ResolvingPipelineRef" {
-			labels := map[string]string{NS_LABEL: newPR.Namespace}
+		oldResolving := isResolvingReason(f.resolvingReasons, ReasonResolvingPipelineRef, oldSucceedCondtition.Reason)
+		newResolving := isResolvingReason(f.resolvingReasons, ReasonResolvingPipelineRef, newSucceedCondition.Reason)
+		if oldResolving && !newResolving {
+			labels := withControlPlaneLabel(map[string]string{NS_LABEL: newPR.Namespace}, newPR.Namespace)
 			originalTime := oldSucceedCondtition.LastTransitionTime.Inner
 			f.waitDuration.With(labels).Observe(float64(newSucceedCondition.LastTransitionTime.Inner.Sub(originalTime.Time).Milliseconds()))
 			return false
 		}
-		// wrt direct string reference, waiting for tag/release with constant moved to the api package;
-		// otherwise, per current examination of Tekton code, we should not see any updates in transition time
+		// per current examination of Tekton code, we should not see any updates in transition time
 		// if multiple SetCondition calls are made, as the Reason/Message fields should not change for resolving refs,
 		// but if that changes, this log should be a warning;
 		// after running with the log below for a few weeks, the difference has only ever been 1 second, so coverting to debug
 		// log from info log
-		if oldReason == "ResolvingPipelineRef" && newReason == "ResolvingPipelineRef" &&
+		if oldResolving && newResolving &&
 			!oldSucceedCondtition.LastTransitionTime.Inner.Equal(&newSucceedCondition.LastTransitionTime.Inner) {
-			ctrl.Log.V(6).Info(fmt.Sprintf("WARNING resolving condition for pipelinerun %s:%s changed from %#v to %#v",
+			controllerLog.V(6).Info(fmt.Sprintf("WARNING resolving condition for pipelinerun %s:%s changed from %#v to %#v",
 				newPR.Namespace,
 				newPR.Name,
 				oldSucceedCondtition,
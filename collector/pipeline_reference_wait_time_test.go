@@ -16,7 +16,7 @@ import (
 )
 
 func TestPipelineRefWaitTimeFilter_Update(t *testing.T) {
-	filter := &pipelineRefWaitTimeFilter{waitDuration: NewPipelineReferenceWaitTimeMetric()}
+	filter := &pipelineRefWaitTimeFilter{waitDuration: NewPipelineReferenceWaitTimeMetric(prometheus.NewRegistry())}
 	now := time.Now()
 	for _, tc := range []struct {
 		name                  string
@@ -52,7 +52,7 @@ func TestPipelineRefWaitTimeFilter_Update(t *testing.T) {
 						{
 							Type:               apis.ConditionSucceeded,
 							Status:             corev1.ConditionUnknown,
-							Reason:             "ResolvingPipelineRef", // waiting for tag/release with constant moved to the api package
+							Reason:             ReasonResolvingPipelineRef,
 							LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(now)},
 						},
 					}},
@@ -64,7 +64,7 @@ func TestPipelineRefWaitTimeFilter_Update(t *testing.T) {
 						{
 							Type:               apis.ConditionSucceeded,
 							Status:             corev1.ConditionUnknown,
-							Reason:             "ResolvingPipelineRef", // waiting for tag/release with constant moved to the api package
+							Reason:             ReasonResolvingPipelineRef,
 							LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(now)},
 						},
 					}},
@@ -79,7 +79,7 @@ func TestPipelineRefWaitTimeFilter_Update(t *testing.T) {
 						{
 							Type:               apis.ConditionSucceeded,
 							Status:             corev1.ConditionUnknown,
-							Reason:             "ResolvingPipelineRef", // waiting for tag/release with constant moved to the api package
+							Reason:             ReasonResolvingPipelineRef,
 							LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(now)},
 						},
 					}},
@@ -91,7 +91,7 @@ func TestPipelineRefWaitTimeFilter_Update(t *testing.T) {
 						{
 							Type:               apis.ConditionSucceeded,
 							Status:             corev1.ConditionUnknown,
-							Reason:             "ResolvingPipelineRef", // waiting for tag/release with constant moved to the api package
+							Reason:             ReasonResolvingPipelineRef,
 							LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(now.Add(1 * time.Second))},
 						},
 					}},
@@ -107,7 +107,7 @@ func TestPipelineRefWaitTimeFilter_Update(t *testing.T) {
 						{
 							Type:               apis.ConditionSucceeded,
 							Status:             corev1.ConditionUnknown,
-							Reason:             "ResolvingPipelineRef", // waiting for tag/release with constant moved to the api package
+							Reason:             ReasonResolvingPipelineRef,
 							LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(now)},
 						},
 					}},
@@ -119,7 +119,7 @@ func TestPipelineRefWaitTimeFilter_Update(t *testing.T) {
 						{
 							Type:               apis.ConditionSucceeded,
 							Status:             corev1.ConditionUnknown,
-							Reason:             "ResolvingPipelineRef", // waiting for tag/release with constant moved to the api package
+							Reason:             ReasonResolvingPipelineRef,
 							LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(now.Add(1 * time.Second))},
 						},
 					}},
@@ -153,3 +153,36 @@ func TestPipelineRefWaitTimeFilter_Update(t *testing.T) {
 		}
 	}
 }
+
+func TestPipelineRefWaitTimeFilter_ConfiguredReasons(t *testing.T) {
+	filter := &pipelineRefWaitTimeFilter{
+		waitDuration:     prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_pipeline_resolution_wait", Help: "test"}, []string{NS_LABEL}),
+		resolvingReasons: resolvingReasons([]string{"ResolvingPipelineReference"}, ReasonResolvingPipelineRef),
+	}
+	assert.Len(t, filter.resolvingReasons, 2)
+	now := time.Now()
+	running := func(reason string, transition time.Time) *v1.PipelineRun {
+		return &v1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"},
+			Status: v1.PipelineRunStatus{
+				Status: duckv1.Status{Conditions: duckv1.Conditions{
+					{
+						Type:               apis.ConditionSucceeded,
+						Status:             corev1.ConditionUnknown,
+						Reason:             reason,
+						LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(transition)},
+					},
+				}},
+			},
+		}
+	}
+	labels := prometheus.Labels{NS_LABEL: "test-namespace"}
+	// a reason we were not configured with is not a resolution wait
+	filter.Update(event.UpdateEvent{ObjectOld: running("ResolvingPipelineSomethingElse", now), ObjectNew: running(v1.PipelineRunReasonRunning.String(), now.Add(time.Second))})
+	validateHistogramVecZeroCount(t, filter.waitDuration, labels)
+	// switching between resolving reasons is still waiting
+	filter.Update(event.UpdateEvent{ObjectOld: running(ReasonResolvingPipelineRef, now), ObjectNew: running("ResolvingPipelineReference", now.Add(time.Second))})
+	validateHistogramVecZeroCount(t, filter.waitDuration, labels)
+	filter.Update(event.UpdateEvent{ObjectOld: running("ResolvingPipelineReference", now), ObjectNew: running(v1.PipelineRunReasonRunning.String(), now.Add(time.Second))})
+	validateHistogramVec(t, filter.waitDuration, labels, false)
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	PIPELINE_LABEL = "pipeline"
+	// SuccessRateWindow is how far back the per pipeline success ratios look
+	SuccessRateWindow = 24 * time.Hour
+	// successRateSlot is the resolution of the window; completions are counted per slot, so the memory used only
+	// depends on the number of pipelines
+	successRateSlot = time.Hour
+)
+
+// successRateKey is a pipeline, as pipelines of the same name in different namespaces are different pipelines
+type successRateKey struct {
+	namespace string
+	pipeline  string
+}
+
+// successRateSlots are the completions of a pipeline in each slot of the window, by the start of the slot and status
+type successRateSlots map[time.Time]map[string]int
+
+// pipelineSuccessRate publishes, per namespace and pipeline reference, the share of the PipelineRuns completed in the
+// last SuccessRateWindow that succeeded, along with the completions by status it is derived from, so product owners get
+// a reliability number per pipeline without recording rules; pipelines without completions in the window are dropped.
+// The completions are only held in memory, so after a restart the window starts over, and covers only the completions
+// seen since until it fills up again
+type pipelineSuccessRate struct {
+	lock        sync.Mutex
+	ratio       *prometheus.Desc
+	completions *prometheus.Desc
+	pipelines   map[successRateKey]successRateSlots
+}
+
+func newPipelineSuccessRate() *pipelineSuccessRate {
+	return &pipelineSuccessRate{
+		ratio: prometheus.NewDesc("pipeline_service_pipeline_daily_success_ratio",
+			"Share of the PipelineRuns of a pipeline completed in the last 24 hours that succeeded", []string{NS_LABEL, PIPELINE_LABEL}, nil),
+		completions: prometheus.NewDesc("pipeline_service_pipeline_daily_completions",
+			"Number of PipelineRuns of a pipeline completed in the last 24 hours, by status", []string{NS_LABEL, PIPELINE_LABEL, STATUS_LABEL}, nil),
+		pipelines: map[successRateKey]successRateSlots{},
+	}
+}
+
+// observe counts a completion; being a new metric, the status takes its stable value, so successful runs are
+// "succeeded" vs. the legacy "succeded"
+func (r *pipelineSuccessRate) observe(namespace, pipeline, status string) {
+	if stable, ok := stableLabelValues[STATUS_LABEL][status]; ok {
+		status = stable
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := exporterClock.Now()
+	slot := now.Truncate(successRateSlot)
+	key := successRateKey{namespace: namespace, pipeline: pipeline}
+	slots, ok := r.pipelines[key]
+	if !ok {
+		slots = successRateSlots{}
+		r.pipelines[key] = slots
+	}
+	// the pipeline's own slots are pruned here, so they stay bounded without scrapes
+	slots.prune(now)
+	if _, ok = slots[slot]; !ok {
+		slots[slot] = map[string]int{}
+	}
+	slots[slot][status]++
+}
+
+// prune drops the slots that ended before the window
+func (s successRateSlots) prune(now time.Time) {
+	oldest := now.Add(-SuccessRateWindow)
+	for slot := range s {
+		if !slot.Add(successRateSlot).After(oldest) {
+			delete(s, slot)
+		}
+	}
+}
+
+// prune drops the expired slots of every pipeline, and the pipelines left without any
+func (r *pipelineSuccessRate) prune(now time.Time) {
+	for key, slots := range r.pipelines {
+		slots.prune(now)
+		if len(slots) == 0 {
+			delete(r.pipelines, key)
+		}
+	}
+}
+
+func (r *pipelineSuccessRate) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.ratio
+	ch <- r.completions
+}
+
+func (r *pipelineSuccessRate) Collect(ch chan<- prometheus.Metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.prune(exporterClock.Now())
+	for key, slots := range r.pipelines {
+		byStatus := map[string]int{}
+		total := 0
+		for _, counts := range slots {
+			for status, count := range counts {
+				byStatus[status] += count
+				total += count
+			}
+		}
+		for status, count := range byStatus {
+			ch <- prometheus.MustNewConstMetric(r.completions, prometheus.GaugeValue, float64(count), key.namespace, key.pipeline, status)
+		}
+		ch <- prometheus.MustNewConstMetric(r.ratio, prometheus.GaugeValue, float64(byStatus[SUCCEEDED_STABLE])/float64(total), key.namespace, key.pipeline)
+	}
+}
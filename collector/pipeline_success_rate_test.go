@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	testclock "k8s.io/utils/clock/testing"
+)
+
+func gaugesByLabels(family *dto.MetricFamily) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range family.GetMetric() {
+		key := ""
+		for _, label := range m.GetLabel() {
+			key += label.GetValue() + "/"
+		}
+		values[key] = m.GetGauge().GetValue()
+	}
+	return values
+}
+
+func TestPipelineSuccessRate(t *testing.T) {
+	fakeClock := testclock.NewFakeClock(time.Date(2023, 6, 1, 10, 30, 0, 0, time.UTC))
+	defer setClock(fakeClock)()
+	registry := prometheus.NewPedanticRegistry()
+	rate := newPipelineSuccessRate()
+	registry.MustRegister(rate)
+	assert.Empty(t, gatherFamilies(t, registry))
+
+	// completions that will have left the window
+	rate.observe("test-namespace", "docker-build", FAILED)
+	rate.observe("test-namespace", "docker-build", FAILED)
+	fakeClock.Step(time.Hour)
+	for i := 0; i < 3; i++ {
+		rate.observe("test-namespace", "docker-build", SUCCEEDED)
+	}
+	rate.observe("test-namespace", "docker-build", FAILED)
+	rate.observe("test-namespace", "fbc-build", SUCCEEDED)
+	// the same pipeline in another namespace is another pipeline
+	rate.observe("test-namespace-2", "fbc-build", FAILED)
+	families := gatherFamilies(t, registry)
+	assert.Equal(t, map[string]float64{"test-namespace/docker-build/": 0.5, "test-namespace/fbc-build/": 1, "test-namespace-2/fbc-build/": 0},
+		gaugesByLabels(families["pipeline_service_pipeline_daily_success_ratio"]))
+	assert.Equal(t, map[string]float64{"test-namespace/docker-build/failed/": 3, "test-namespace/docker-build/succeeded/": 3,
+		"test-namespace/fbc-build/succeeded/": 1, "test-namespace-2/fbc-build/failed/": 1},
+		gaugesByLabels(families["pipeline_service_pipeline_daily_completions"]))
+
+	fakeClock.Step(SuccessRateWindow)
+	families = gatherFamilies(t, registry)
+	assert.Equal(t, map[string]float64{"test-namespace/docker-build/": 0.75, "test-namespace/fbc-build/": 1, "test-namespace-2/fbc-build/": 0},
+		gaugesByLabels(families["pipeline_service_pipeline_daily_success_ratio"]))
+
+	fakeClock.Step(successRateSlot)
+	assert.Empty(t, gatherFamilies(t, registry))
+	assert.Empty(t, rate.pipelines)
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	"knative.dev/pkg/apis"
 	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 type PipelineRunScheduledCollector struct {
@@ -13,28 +12,26 @@ type PipelineRunScheduledCollector struct {
 	prSchedNameLabel  bool
 }
 
-func NewPipelineRunScheduledMetric() *prometheus.HistogramVec {
-	labelNames := []string{NS_LABEL, STATUS_LABEL}
+func NewPipelineRunScheduledMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	labelNames := withRunLabelNames(withTenantLabelName([]string{NS_LABEL, STATUS_LABEL}))
+	durationScheduledHelp := "Duration in seconds for a PipelineRun to be 'scheduled', meaning it has been received by the Tekton controller.  This is an indication of how quickly create events from the API server are arriving to the Tekton controller."
 	durationScheduled := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "pipelinerun_duration_scheduled_seconds",
-		Help: "Duration in seconds for a PipelineRun to be 'scheduled', meaning it has been received by the Tekton controller.  This is an indication of how quickly create events from the API server are arriving to the Tekton controller.",
+		Help: deprecatedHelp(durationScheduledHelp, "pipeline_service_pipelinerun_scheduled_duration_seconds"),
 		// reminder: exponential buckets need a start value greater than 0
 		// the results in buckets of 0.1, 0.5, 2.5, 12.5, 62.5, 312.5 seconds
 		Buckets: prometheus.ExponentialBuckets(0.1, 5, 6),
 	}, labelNames)
 
-	metrics.Registry.MustRegister(durationScheduled)
+	registerer.MustRegister(withStableName(durationScheduled, "pipeline_service_pipelinerun_scheduled_duration_seconds", durationScheduledHelp, labelNames))
 
 	return durationScheduled
 }
 
 func bumpPipelineRunScheduledDuration(scheduleDuration float64, pr *v1.PipelineRun, metric *prometheus.HistogramVec) {
 	succeededCondition := pr.Status.GetCondition(apis.ConditionSucceeded)
-	status := SUCCEEDED
-	if succeededCondition.IsFalse() {
-		status = FAILED
-	}
-	labels := map[string]string{NS_LABEL: pr.Namespace, STATUS_LABEL: status}
+	status := runStatus(succeededCondition)
+	labels := withRunLabels(withTenantLabel(map[string]string{NS_LABEL: pr.Namespace, STATUS_LABEL: status}, pr.Namespace), pr)
 	metric.With(labels).Observe(scheduleDuration)
 }
 
@@ -60,6 +57,9 @@ func (f *startTimeEventFilter) Update(e event.UpdateEvent) bool {
 	newPR, oknew := e.ObjectNew.(*v1.PipelineRun)
 	if okold && oknew {
 		if !oldPR.IsDone() && newPR.IsDone() {
+			if startTimeMissing("pipelinerun-scheduled", newPR, newPR.Status.StartTime) {
+				return false
+			}
 			bumpPipelineRunScheduledDuration(calculateScheduledDurationPipelineRun(newPR), newPR, f.metric)
 			return false
 		}
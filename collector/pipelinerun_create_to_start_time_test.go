@@ -10,7 +10,6 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"testing"
 	"time"
 )
@@ -76,12 +75,11 @@ func TestPipelineRunStartTimeEventFilter_Update(t *testing.T) {
 			ObjectOld: tc.oldPR,
 			ObjectNew: tc.newPR,
 		}
-		filter.metric = NewPipelineRunScheduledMetric()
+		filter.metric = NewPipelineRunScheduledMetric(prometheus.NewRegistry())
 		rc := filter.Update(ev)
 		if rc != tc.expectedRC {
 			t.Errorf(fmt.Sprintf("tc %s expected %v but got %v", tc.name, tc.expectedRC, rc))
 		}
-		metrics.Registry.Unregister(filter.metric)
 	}
 
 }
@@ -170,11 +168,10 @@ func TestPipelineRunScheduleCollection(t *testing.T) {
 		},
 	}
 	for _, pr := range mockPipelineRuns {
-		metric := NewPipelineRunScheduledMetric()
+		metric := NewPipelineRunScheduledMetric(prometheus.NewRegistry())
 		label := prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED}
 		bumpPipelineRunScheduledDuration(calculateScheduledDurationPipelineRun(pr), pr, metric)
 		validateHistogramVec(t, metric, label, false)
-		metrics.Registry.Unregister(metric)
 	}
 
 }
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func NewPipelineRunPendingMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	pending := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipeline_service_pipelinerun_pending_duration_seconds",
+		Help:    "Duration in seconds PipelineRuns were held in the PipelineRunPending state, from their creation until they were allowed to start or were done",
+		Buckets: []float64{1, 10, 60, 300, 900, 3600, 4 * 3600, 24 * 3600},
+	}, withControlPlaneLabelName(withTenantLabelName([]string{NS_LABEL})))
+	registerer.MustRegister(pending)
+	return pending
+}
+
+// pipelineRunPending is true while the tekton controller holds the PipelineRun, because of its spec.status of
+// PipelineRunPending; the spec can be cleared before the controller gets to it, so the condition is what counts
+func pipelineRunPending(pr *v1.PipelineRun) bool {
+	condition := pr.Status.GetCondition(apis.ConditionSucceeded)
+	return condition != nil && condition.Reason == v1.PipelineRunReasonPending.String()
+}
+
+// pendingEnd is when the PipelineRun left the pending state, its start time, or the transition time of its condition
+// when it was cancelled while pending
+func pendingEnd(pr *v1.PipelineRun) time.Time {
+	if pr.Status.StartTime != nil {
+		return pr.Status.StartTime.Time
+	}
+	condition := pr.Status.GetCondition(apis.ConditionSucceeded)
+	if condition == nil {
+		return time.Time{}
+	}
+	return condition.LastTransitionTime.Inner.Time
+}
+
+// pipelineRunPendingFilter observes how long PipelineRuns were pending when they leave the pending state, so the
+// queueing built on spec.status can be told from the create to start time of the tekton controller; nothing is reconciled
+type pipelineRunPendingFilter struct {
+	metric *prometheus.HistogramVec
+}
+
+func (f *pipelineRunPendingFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *pipelineRunPendingFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *pipelineRunPendingFilter) Update(e event.UpdateEvent) bool {
+	oldPR, okold := e.ObjectOld.(*v1.PipelineRun)
+	newPR, oknew := e.ObjectNew.(*v1.PipelineRun)
+	if !okold || !oknew || !pipelineRunPending(oldPR) || pipelineRunPending(newPR) {
+		return false
+	}
+	end := pendingEnd(newPR)
+	if end.Before(newPR.CreationTimestamp.Time) {
+		return false
+	}
+	f.metric.With(withControlPlaneLabel(withTenantLabel(map[string]string{NS_LABEL: newPR.Namespace}, newPR.Namespace), newPR.Namespace)).Observe(end.Sub(newPR.CreationTimestamp.Time).Seconds())
+	return false
+}
+
+func (f *pipelineRunPendingFilter) Generic(event.GenericEvent) bool {
+	return false
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestPipelineRunPendingFilter(t *testing.T) {
+	metric := NewPipelineRunPendingMetric(prometheus.NewRegistry())
+	filter := &pipelineRunPendingFilter{metric: metric}
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	pr := func(name string, status corev1.ConditionStatus, reason string, started *time.Time) *v1.PipelineRun {
+		run := &v1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: name, CreationTimestamp: metav1.NewTime(created)},
+			Status: v1.PipelineRunStatus{Status: duckv1.Status{Conditions: duckv1.Conditions{{
+				Type:               apis.ConditionSucceeded,
+				Status:             status,
+				Reason:             reason,
+				LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(created.Add(30 * time.Second))},
+			}}}},
+		}
+		if started != nil {
+			run.Status.StartTime = &metav1.Time{Time: *started}
+		}
+		return run
+	}
+	started := created.Add(2 * time.Minute)
+	pending := pr("test-1", corev1.ConditionUnknown, v1.PipelineRunReasonPending.String(), nil)
+	// the spec was cleared, but the tekton controller has not started the run yet
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pending, ObjectNew: pending}))
+	validateHistogramVecZeroCount(t, metric, prometheus.Labels{NS_LABEL: "test-namespace"})
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pending, ObjectNew: pr("test-1", corev1.ConditionUnknown, v1.PipelineRunReasonRunning.String(), &started)}))
+	// cancelled while pending
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pr("test-2", corev1.ConditionUnknown, v1.PipelineRunReasonPending.String(), nil),
+		ObjectNew: pr("test-2", corev1.ConditionFalse, v1.PipelineRunReasonCancelled.String(), nil)}))
+	// never pending
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pr("test-3", corev1.ConditionUnknown, v1.PipelineRunReasonRunning.String(), &started),
+		ObjectNew: pr("test-3", corev1.ConditionTrue, v1.PipelineRunReasonSuccessful.String(), &started)}))
+
+	observer, err := metric.GetMetricWith(prometheus.Labels{NS_LABEL: "test-namespace"})
+	assert.NoError(t, err)
+	m := &dto.Metric{}
+	assert.NoError(t, observer.(prometheus.Histogram).Write(m))
+	assert.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(150), m.GetHistogram().GetSampleSum())
+}
@@ -0,0 +1,212 @@
+package collector
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+)
+
+const (
+	// QueueAnnotationsEnvName is a comma separated list of annotations, in place of DefaultQueueAnnotations, marking
+	// the pending PipelineRuns held back by a concurrency limiter, as annotation=value, or just the annotation to match
+	// any value
+	QueueAnnotationsEnvName = "PIPELINERUN_QUEUE_ANNOTATIONS"
+
+	// PAC_REPOSITORY_LABEL is the Pipelines as Code Repository of the PipelineRun, which its concurrency limit is per
+	PAC_REPOSITORY_LABEL = "pipelinesascode.tekton.dev/repository"
+
+	// queueEstimateRuns is how many of the most recently completed PipelineRuns of a namespace the wait is estimated from
+	queueEstimateRuns = 10
+)
+
+// DefaultQueueAnnotations are the annotations of the concurrency limiter of Pipelines as Code, which creates the
+// PipelineRuns over the limit as pending, with their state annotation set to queued; the annotation is on all of its
+// PipelineRuns, started or completed too, so only the queued value matches
+var DefaultQueueAnnotations = []string{"pipelinesascode.tekton.dev/state=queued"}
+
+func queueAnnotations() []string {
+	if len(settings.QueueAnnotations) == 0 {
+		return DefaultQueueAnnotations
+	}
+	return settings.QueueAnnotations
+}
+
+// PipelineRunQueueCollector publishes how many PipelineRuns of each namespace wait behind a concurrency limiter, and
+// roughly how long the last of them will wait, so tenants can see why their PipelineRun has not started; the estimate
+// assumes the queue drains as fast as the namespace's running PipelineRuns complete, each taking as long as the
+// namespace's recent PipelineRuns took on average
+type PipelineRunQueueCollector struct {
+	registerer    *collectorRegisterer
+	length        *prometheus.GaugeVec
+	estimatedWait *prometheus.GaugeVec
+	// lastScan are the namespaces set in the most recent scan, so those without queued PipelineRuns since are zeroed
+	lastScan map[string]struct{}
+}
+
+func NewPipelineRunQueueCollector(registerer prometheus.Registerer) *PipelineRunQueueCollector {
+	reg := newCollectorRegisterer(registerer)
+	length := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_pipelinerun_queue_length",
+		Help: "Number of pending PipelineRuns in the namespace held back by a concurrency limiter, as of the most recent scan",
+	}, withTenantLabelName([]string{NS_LABEL}))
+	estimatedWait := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_pipelinerun_queue_estimated_wait_seconds",
+		Help: "Estimated seconds until the last PipelineRun queued in the namespace starts, from the durations of its recent PipelineRuns, as of the most recent scan",
+	}, withTenantLabelName([]string{NS_LABEL}))
+	reg.MustRegister(length, estimatedWait)
+	return &PipelineRunQueueCollector{registerer: reg, length: length, estimatedWait: estimatedWait, lastScan: map[string]struct{}{}}
+}
+
+// Close unregisters the metrics of the collector
+func (c *PipelineRunQueueCollector) Close() {
+	c.registerer.Close()
+}
+
+// pipelineRunQueued is true for a pending PipelineRun carrying one of the queue annotations, with its value when one
+// is given; spec.status is checked as well as the condition, as the limiter creates the PipelineRun pending before the
+// tekton controller has seen it
+func pipelineRunQueued(pr *v1.PipelineRun) bool {
+	if !pipelineRunPending(pr) && pr.Spec.Status != v1.PipelineRunSpecStatusPending {
+		return false
+	}
+	for _, entry := range queueAnnotations() {
+		annotation, value, withValue := strings.Cut(entry, "=")
+		if actual, ok := pr.Annotations[annotation]; ok && (!withValue || actual == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// queueKey is what a concurrency limit applies to: the Pipelines as Code Repository of the PipelineRuns, or the
+// namespace, with an empty repository, for those of no Repository
+type queueKey struct {
+	namespace  string
+	repository string
+}
+
+func pipelineRunQueueKey(pr *v1.PipelineRun) queueKey {
+	repository, ok := pr.Labels[PAC_REPOSITORY_LABEL]
+	if !ok {
+		repository = pr.Annotations[PAC_REPOSITORY_LABEL]
+	}
+	return queueKey{namespace: pr.Namespace, repository: repository}
+}
+
+// namespaceQueue is what the estimate of a queue needs
+type namespaceQueue struct {
+	queued    int
+	running   int
+	durations []completedDuration
+}
+
+type completedDuration struct {
+	completed time.Time
+	duration  time.Duration
+}
+
+// estimatedWait is how long the last queued PipelineRun waits if the queue drains by the running PipelineRuns, at
+// least one, each taking the mean duration of the recent ones; false when there are no completed PipelineRuns to go by
+func (q *namespaceQueue) estimatedWait() (time.Duration, bool) {
+	if len(q.durations) == 0 {
+		return 0, false
+	}
+	sort.Slice(q.durations, func(i, j int) bool {
+		return q.durations[i].completed.After(q.durations[j].completed)
+	})
+	recent := q.durations
+	if len(recent) > queueEstimateRuns {
+		recent = recent[:queueEstimateRuns]
+	}
+	total := time.Duration(0)
+	for _, d := range recent {
+		total += d.duration
+	}
+	mean := total / time.Duration(len(recent))
+	slots := q.running
+	if slots < 1 {
+		slots = 1
+	}
+	rounds := math.Ceil(float64(q.queued) / float64(slots))
+	return time.Duration(rounds * float64(mean)), true
+}
+
+// scan estimates each queue apart, as the limits of Pipelines as Code are per Repository, publishing the namespace's
+// queued PipelineRuns in total, and the longest wait of its queues
+func (c *PipelineRunQueueCollector) scan(prs []v1.PipelineRun) {
+	queues := map[queueKey]*namespaceQueue{}
+	queue := func(key queueKey) *namespaceQueue {
+		q, ok := queues[key]
+		if !ok {
+			q = &namespaceQueue{}
+			queues[key] = q
+		}
+		return q
+	}
+	for i := range prs {
+		pr := &prs[i]
+		switch {
+		case pipelineRunQueued(pr):
+			queue(pipelineRunQueueKey(pr)).queued++
+		case pr.IsDone():
+			if pr.Status.StartTime != nil && pr.Status.CompletionTime != nil && pr.Status.CompletionTime.After(pr.Status.StartTime.Time) {
+				q := queue(pipelineRunQueueKey(pr))
+				q.durations = append(q.durations, completedDuration{
+					completed: pr.Status.CompletionTime.Time,
+					duration:  pr.Status.CompletionTime.Sub(pr.Status.StartTime.Time),
+				})
+			}
+		case pr.Status.StartTime != nil:
+			queue(pipelineRunQueueKey(pr)).running++
+		}
+	}
+	queued := map[string]int{}
+	waits := map[string]time.Duration{}
+	for key, q := range queues {
+		if q.queued == 0 {
+			continue
+		}
+		queued[key.namespace] += q.queued
+		if wait, ok := q.estimatedWait(); ok && wait >= waits[key.namespace] {
+			waits[key.namespace] = wait
+		}
+	}
+	scanned := map[string]struct{}{}
+	for ns, count := range queued {
+		labels := withTenantLabel(map[string]string{NS_LABEL: ns}, ns)
+		c.length.With(labels).Set(float64(count))
+		if wait, ok := waits[ns]; ok {
+			c.estimatedWait.With(labels).Set(wait.Seconds())
+		} else {
+			c.estimatedWait.Delete(labels)
+		}
+		scanned[ns] = struct{}{}
+	}
+	// like the poll style detectors, namespaces are zeroed vs. deleted, to allow for history based searches
+	for ns := range c.lastScan {
+		if _, ok := scanned[ns]; !ok {
+			labels := withTenantLabel(map[string]string{NS_LABEL: ns}, ns)
+			c.length.With(labels).Set(0)
+			c.estimatedWait.With(labels).Set(0)
+		}
+	}
+	c.lastScan = scanned
+}
+
+func (r *ExporterReconcile) resetPipelineRunQueueStats(ctx context.Context) {
+	if !r.collectors.enabled(CollectorPipelineRunQueue) || collectorHealth.isDisabled(CollectorPipelineRunQueue) {
+		return
+	}
+	prList := &v1.PipelineRunList{}
+	err := r.client.List(ctx, prList)
+	if err != nil {
+		controllerLog.Error(err, "pipeline run query for the concurrency queues failed with an error")
+		return
+	}
+	r.queueCollector.scan(prList.Items)
+}
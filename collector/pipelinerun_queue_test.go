@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPipelineRunQueue(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	now := time.Now().Truncate(time.Second)
+	pipelineRun := func(ns, name string, started, completed *time.Time, queued bool) *v1.PipelineRun {
+		pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, Labels: map[string]string{PAC_REPOSITORY_LABEL: "repo"}}}
+		if queued {
+			pr.Annotations = map[string]string{"pipelinesascode.tekton.dev/state": "queued"}
+			pr.Spec.Status = v1.PipelineRunSpecStatusPending
+		}
+		if started != nil {
+			pr.Status.StartTime = &metav1.Time{Time: *started}
+		}
+		if completed != nil {
+			pr.Status.CompletionTime = &metav1.Time{Time: *completed}
+			pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+		}
+		assert.NoError(t, c.Create(ctx, pr))
+		return pr
+	}
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	// two recent runs of 4 and 6 minutes, so 5 minutes on average
+	pipelineRun("test-namespace", "done-1", at(-20*time.Minute), at(-16*time.Minute), false)
+	pipelineRun("test-namespace", "done-2", at(-10*time.Minute), at(-4*time.Minute), false)
+	pipelineRun("test-namespace", "running-1", at(-time.Minute), nil, false)
+	pipelineRun("test-namespace", "running-2", at(-time.Minute), nil, false)
+	for _, name := range []string{"queued-1", "queued-2", "queued-3"} {
+		pipelineRun("test-namespace", name, nil, nil, true)
+	}
+	// pending without the queue annotation, so not held by a concurrency limiter
+	pending := pipelineRun("test-namespace-2", "pending", nil, nil, false)
+	pending.Spec.Status = v1.PipelineRunSpecStatusPending
+	assert.NoError(t, c.Update(ctx, pending))
+	// pending with the state annotation of Pipelines as Code, but started rather than queued
+	started := pipelineRun("test-namespace-2", "started", nil, nil, true)
+	started.Annotations["pipelinesascode.tekton.dev/state"] = "started"
+	assert.NoError(t, c.Update(ctx, started))
+	// another Repository of the namespace, with its own limit, and one long run
+	other := pipelineRun("test-namespace", "other-done", at(-60*time.Minute), at(-30*time.Minute), false)
+	other.Labels[PAC_REPOSITORY_LABEL] = "other-repo"
+	assert.NoError(t, c.Update(ctx, other))
+	unknown := pipelineRun("test-namespace-3", "queued-1", nil, nil, true)
+
+	reconciler := buildReconciler(c, nil, nil)
+	reconciler.resetPipelineRunQueueStats(ctx)
+	labels := prometheus.Labels{NS_LABEL: "test-namespace"}
+	validateGaugeVec(t, reconciler.queueCollector.length, labels, float64(3))
+	// 3 queued behind 2 running is 2 rounds of 5 minutes, the run of the other Repository left out
+	validateGaugeVec(t, reconciler.queueCollector.estimatedWait, labels, float64(600))
+	validateGaugeVec(t, reconciler.queueCollector.length, prometheus.Labels{NS_LABEL: "test-namespace-3"}, float64(1))
+	// without completed PipelineRuns to go by, there is no estimate
+	assert.Equal(t, 1, testutil.CollectAndCount(reconciler.queueCollector.estimatedWait))
+	// nothing queued in test-namespace-2
+	assert.Equal(t, 2, testutil.CollectAndCount(reconciler.queueCollector.length))
+
+	// a queue of the other Repository, with its own longer estimate, sums to the namespace's length, and its wait is
+	// the longest: 1 queued behind no running, so at least one, is 1 round of 30 minutes
+	queued := pipelineRun("test-namespace", "other-queued", nil, nil, true)
+	queued.Labels[PAC_REPOSITORY_LABEL] = "other-repo"
+	assert.NoError(t, c.Update(ctx, queued))
+	reconciler.resetPipelineRunQueueStats(ctx)
+	validateGaugeVec(t, reconciler.queueCollector.length, labels, float64(4))
+	validateGaugeVec(t, reconciler.queueCollector.estimatedWait, labels, float64(1800))
+
+	// namespaces whose queue drained are zeroed
+	assert.NoError(t, c.Delete(ctx, unknown))
+	reconciler.resetPipelineRunQueueStats(ctx)
+	validateGaugeVec(t, reconciler.queueCollector.length, prometheus.Labels{NS_LABEL: "test-namespace-3"}, float64(0))
+
+	// a disabled collector does not scan
+	reconciler.collectors = collectorSet{CollectorPollers: {}}
+	assert.NoError(t, c.Delete(ctx, queued))
+	reconciler.resetPipelineRunQueueStats(ctx)
+	validateGaugeVec(t, reconciler.queueCollector.length, labels, float64(4))
+	reconciler.Close()
+}
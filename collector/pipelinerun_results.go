@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"encoding/json"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// resultsSizeBuckets go from a fraction of the 4096 bytes of a termination message, which bounds the results of a
+// TaskRun, up to the 1.5MiB etcd object limit the status of a PipelineRun runs into
+var resultsSizeBuckets = []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576}
+
+func NewPipelineRunResultsMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	results := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipeline_service_pipelinerun_results",
+		Help:    "Number of results in the status of completed PipelineRuns, by pipeline",
+		Buckets: []float64{0, 1, 2, 5, 10, 20, 50},
+	}, []string{PIPELINE_LABEL})
+	registerer.MustRegister(results)
+	return results
+}
+
+func NewPipelineRunResultsSizeMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	size := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipeline_service_pipelinerun_results_size_bytes",
+		Help:    "Size in bytes of the results in the status of completed PipelineRuns, as serialized, by pipeline",
+		Buckets: resultsSizeBuckets,
+	}, []string{PIPELINE_LABEL})
+	registerer.MustRegister(size)
+	return size
+}
+
+// resultsSize is how many bytes the results add to the PipelineRun's status, as serialized
+func resultsSize(results []v1.PipelineRunResult) int {
+	if len(results) == 0 {
+		return 0
+	}
+	data, err := json.Marshal(results)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// pipelineRunResultsFilter observes the count and size of the results of the PipelineRuns as they become done, per
+// pipeline reference, as oversized results bloat the status, and get runs rejected by webhooks and the API server,
+// so the pipelines growing towards the limits can be alerted on before their runs start failing
+type pipelineRunResultsFilter struct {
+	results *prometheus.HistogramVec
+	size    *prometheus.HistogramVec
+}
+
+func (f *pipelineRunResultsFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *pipelineRunResultsFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *pipelineRunResultsFilter) Update(e event.UpdateEvent) bool {
+	oldPR, okOld := e.ObjectOld.(*v1.PipelineRun)
+	newPR, okNew := e.ObjectNew.(*v1.PipelineRun)
+	if !okOld || !okNew || oldPR.IsDone() || !newPR.IsDone() {
+		return false
+	}
+	labels := prometheus.Labels{PIPELINE_LABEL: pipelineRunPipelineRef(newPR)}
+	f.results.With(labels).Observe(float64(len(newPR.Status.Results)))
+	f.size.With(labels).Observe(float64(resultsSize(newPR.Status.Results)))
+	return false
+}
+
+func (f *pipelineRunResultsFilter) Generic(event.GenericEvent) bool {
+	return false
+}
@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestPipelineRunResultsFilter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	f := &pipelineRunResultsFilter{results: NewPipelineRunResultsMetric(registry), size: NewPipelineRunResultsSizeMetric(registry)}
+	running := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"},
+		Spec: v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "docker-build"}}}
+	done := running.DeepCopy()
+	done.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+	done.Status.Results = []v1.PipelineRunResult{
+		{Name: "IMAGE_URL", Value: *v1.NewStructuredValues("quay.io/test/image:latest")},
+		{Name: "IMAGE_DIGEST", Value: *v1.NewStructuredValues("sha256:0123456789abcdef")},
+	}
+
+	assert.False(t, f.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: done}))
+	// only the transition to done is observed
+	assert.False(t, f.Update(event.UpdateEvent{ObjectOld: done, ObjectNew: done}))
+	assert.False(t, f.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: running}))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(f.results))
+	labels := prometheus.Labels{PIPELINE_LABEL: "docker-build"}
+	m := &dto.Metric{}
+	assert.NoError(t, f.results.With(labels).(prometheus.Histogram).Write(m))
+	assert.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(2), m.GetHistogram().GetSampleSum())
+	m = &dto.Metric{}
+	assert.NoError(t, f.size.With(labels).(prometheus.Histogram).Write(m))
+	assert.Equal(t, float64(resultsSize(done.Status.Results)), m.GetHistogram().GetSampleSum())
+	assert.Greater(t, m.GetHistogram().GetSampleSum(), float64(0))
+	assert.Equal(t, 0, resultsSize(nil))
+}
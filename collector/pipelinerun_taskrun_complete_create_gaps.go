@@ -9,35 +9,72 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	"knative.dev/pkg/apis"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// PHASE_LABEL is the GapPhase constant of a gap, so the kickoff of a PipelineRun by the controller is not lumped in
+// with the scheduling of the TaskRuns in the middle of it
+const PHASE_LABEL = "phase"
+
 type PipelineRunTaskRunGapCollector struct {
-	trGaps *prometheus.HistogramVec
+	registerer *collectorRegisterer
+	trGaps     *prometheus.HistogramVec
+	// maxGaps is the longest single gap of each PipelineRun, as a long stall amid a long PipelineRun barely moves its
+	// execution overhead
+	maxGaps   *prometheus.HistogramVec
+	gapAborts *prometheus.CounterVec
+	// sampleWeights is the number of PipelineRuns the observed gaps stand for, nil unless sampling
+	sampleWeights *prometheus.CounterVec
 }
 
-func NewPipelineRunTaskRunGapCollector() *PipelineRunTaskRunGapCollector {
-	labelNames := []string{NS_LABEL, STATUS_LABEL}
+func NewPipelineRunTaskRunGapCollector(registerer prometheus.Registerer) *PipelineRunTaskRunGapCollector {
+	reg := newCollectorRegisterer(registerer)
+	labelNames := withRunLabelNames(withTenantLabelName([]string{NS_LABEL, STATUS_LABEL, PHASE_LABEL}))
+	trGapsHelp := "Duration in milliseconds between a taskrun completing and the next taskrun being created within a pipelinerun.  For a pipelinerun's first taskrun, the duration is the time between that taskrun's creation and the pipelinerun's creation, with a phase label of kickoff vs. between-taskruns."
 	trGaps := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "pipelinerun_gap_between_taskruns_milliseconds",
-		Help: "Duration in milliseconds between a taskrun completing and the next taskrun being created within a pipelinerun.  For a pipelinerun's first taskrun, the duration is the time between that taskrun's creation and the pipelinerun's creation.",
+		Help: deprecatedHelp(trGapsHelp, "pipeline_service_pipelinerun_taskrun_gap_milliseconds"),
 		// reminder: exponential buckets need a start value greater than 0
 		// the results in buckets of 100, 500, 2500, 12500, 62500, 312500 milliseconds
 		Buckets: prometheus.ExponentialBuckets(float64(100), float64(5), 6),
 	}, labelNames)
 
+	maxGapLabelNames := withRunLabelNames(withTenantLabelName([]string{NS_LABEL, STATUS_LABEL}))
+	maxGaps := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "pipelinerun_gap_max_milliseconds",
+		Help: "Duration in milliseconds of the longest gap between taskruns within a pipelinerun, including the gaps from the pipelinerun's creation.",
+		// the same buckets as the individual gaps
+		Buckets: prometheus.ExponentialBuckets(float64(100), float64(5), 6),
+	}, maxGapLabelNames)
+
+	// the overhead reconcile also sorts the TaskRuns of the same PipelineRun, but we only count aborts here
+	gapAborts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipelinerun_gap_calculation_aborts_total",
+		Help: "Number of times the gaps of a PipelineRun were not calculated, because of inconsistent TaskRun data, or because it had no TaskRuns, no completion time, or a throttled TaskRun, by reason",
+	}, withControlPlaneLabelName([]string{NS_LABEL, REASON_LABEL}))
+
 	pipelineRunTaskRunGapCollector := &PipelineRunTaskRunGapCollector{
-		trGaps: trGaps,
+		registerer: reg,
+		trGaps:     trGaps,
+		maxGaps:    maxGaps,
+		gapAborts:  gapAborts,
+		sampleWeights: NewSampleWeightMetric(reg, "pipelinerun_gap_sample_weight_total",
+			"Sum of the sample weights of the PipelineRuns whose gaps were observed, the number of completed PipelineRuns they stand for when sampling"),
 	}
-	metrics.Registry.MustRegister(trGaps)
+	reg.MustRegister(withStableName(trGaps, "pipeline_service_pipelinerun_taskrun_gap_milliseconds", trGapsHelp, labelNames), maxGaps, gapAborts)
 
 	return pipelineRunTaskRunGapCollector
 }
 
+// Close unregisters the metrics of the collector
+func (c *PipelineRunTaskRunGapCollector) Close() {
+	c.registerer.Close()
+}
+
 type ReconcilePipelineRunTaskRunGap struct {
 	client        client.Client
 	scheme        *runtime.Scheme
@@ -95,28 +132,51 @@ func (r *ExporterReconcile) ReconcilePipelineRunTaskRunGap(ctx context.Context,
 	// based on our WithEventFilter we should only be getting called with the start time is set
 	log.V(8).Info(fmt.Sprintf("recording taskrun gap for %q", request.NamespacedName))
 	r.prGapCollector.bumpGapDuration(pr, r.client, ctx)
+	// this is the last reconcile to care about any in-memory throttling, as ReconcileOverhead is called before us
+	if pr.IsDone() {
+		inMemoryThrottles.forget(pr)
+	}
 	return reconcile.Result{}, nil
 }
 
 func (c *PipelineRunTaskRunGapCollector) bumpGapDuration(pr *v1.PipelineRun, oc client.Client, ctx context.Context) {
-	if skipPipelineRun(pr) {
+	if reason := gapSkipReason(pr); len(reason) > 0 {
+		bumpGapAbort(c.gapAborts, pr, reason)
+		return
+	}
+	// like the overhead, the gaps are sampled before the TaskRuns are listed
+	weight, sampled := overheadSample(pr, runStatus(pr.Status.GetCondition(apis.ConditionSucceeded)))
+	if !sampled {
+		bumpGapAbort(c.gapAborts, pr, OverheadSkipSampledOut)
 		return
 	}
 
-	sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes, abort := sortTaskRunsForGapCalculations(pr, oc, ctx)
+	sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes, abortReason := sortTaskRunsForGapCalculations(pr, oc, ctx, c.gapAborts)
 
-	if abort {
+	if len(abortReason) > 0 {
 		return
 	}
 
-	gapEntries := calculateGaps(ctx, pr, oc, sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes)
+	gapEntries := calculateGaps(pr, sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes)
+	c.observeGaps(pr, gapEntries)
+	if len(gapEntries) > 0 {
+		addSampleWeight(c.sampleWeights, pr, weight)
+	}
+}
+
+func (c *PipelineRunTaskRunGapCollector) observeGaps(pr *v1.PipelineRun, gapEntries []GapEntry) {
 	for _, gapEntry := range gapEntries {
-		labels := map[string]string{
+		labels := withRunLabels(withTenantLabel(map[string]string{
 			NS_LABEL:     pr.Namespace,
-			STATUS_LABEL: gapEntry.status,
-		}
-		c.trGaps.With(labels).Observe(gapEntry.gap)
+			STATUS_LABEL: gapEntry.Status,
+			PHASE_LABEL:  gapEntry.Phase,
+		}, pr.Namespace), pr)
+		smoothedObservations.observe(c.trGaps.With(labels), gapEntry.Gap)
+	}
+	if maxGap, ok := maxGapEntry(gapEntries); ok {
+		smoothedObservations.observe(c.maxGaps.With(withRunLabels(withTenantLabel(map[string]string{
+			NS_LABEL:     pr.Namespace,
+			STATUS_LABEL: maxGap.Status,
+		}, pr.Namespace), pr)), maxGap.Gap)
 	}
-
-	return
 }
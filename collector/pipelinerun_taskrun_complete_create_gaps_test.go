@@ -67,7 +67,7 @@ func TestPipelineRunGapCollection(t *testing.T) {
 			},
 		}
 		_, err = gapReconciler.Reconcile(ctx, request)
-		label := prometheus.Labels{NS_LABEL: pr.Namespace, STATUS_LABEL: SUCCEEDED}
+		label := prometheus.Labels{NS_LABEL: pr.Namespace, STATUS_LABEL: SUCCEEDED, PHASE_LABEL: GapPhaseKickoff}
 		validateHistogramVec(t, gapReconciler.prGapCollector.trGaps, label, true)
 	}
 
@@ -198,9 +198,10 @@ func TestPipelineRunGapCollection(t *testing.T) {
 		_, err = gapReconciler.Reconcile(ctx, request)
 	}
 
-	label := prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED}
+	label := prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED, PHASE_LABEL: GapPhaseKickoff}
 	validateHistogramVec(t, gapReconciler.prGapCollector.trGaps, label, false)
-	unregisterStats(gapReconciler)
+	validateHistogramVec(t, gapReconciler.prGapCollector.maxGaps, prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED}, false)
+	gapReconciler.Close()
 
 }
 
@@ -238,10 +239,23 @@ func TestPipelineRunGapCollection_MissingTaskRuns(t *testing.T) {
 			},
 		}
 		_, err = gapReconciler.Reconcile(ctx, request)
-		label := prometheus.Labels{NS_LABEL: pr.Namespace, STATUS_LABEL: SUCCEEDED}
+		label := prometheus.Labels{NS_LABEL: pr.Namespace, STATUS_LABEL: SUCCEEDED, PHASE_LABEL: GapPhaseKickoff}
 		validateHistogramVecZeroCount(t, gapReconciler.prGapCollector.trGaps, label)
 	}
-	unregisterStats(gapReconciler)
+	gapReconciler.Close()
+}
+
+func TestPipelineRunGapCollection_Skipped(t *testing.T) {
+	collector := NewPipelineRunTaskRunGapCollector(prometheus.NewRegistry())
+	defer collector.Close()
+	c := fake.NewClientBuilder().Build()
+	// a PipelineRun without TaskRuns, or without its completion time, is counted vs. silently skipped
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	collector.bumpGapDuration(pr, c, context.TODO())
+	pr.Status.ChildReferences = []v1.ChildStatusReference{{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "test-1-build"}}
+	collector.bumpGapDuration(pr, c, context.TODO())
+	validateCounterVec(t, collector.gapAborts, prometheus.Labels{NS_LABEL: "test-namespace", REASON_LABEL: GapSkipNoTaskRuns}, float64(1))
+	validateCounterVec(t, collector.gapAborts, prometheus.Labels{NS_LABEL: "test-namespace", REASON_LABEL: GapSkipNotFinished}, float64(1))
 }
 
 func TestTaskRunGapEventFilter_Update(t *testing.T) {
@@ -4,11 +4,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
-func NewPodCreateToCompleteMetric() *prometheus.HistogramVec {
-	labelNames := []string{NS_LABEL}
+func NewPodCreateToCompleteMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	labelNames := withControlPlaneLabelName(withTenantLabelName([]string{NS_LABEL}))
 	c2cMetric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "tekton_pods_create_to_complete_seconds",
 		Help: "Since tekton's duration are only from start time to completion, we provide a create time to completion for comparisons and potential alerting",
@@ -16,13 +15,13 @@ func NewPodCreateToCompleteMetric() *prometheus.HistogramVec {
 		// the results in buckets of 0.1, 0.5, 2.5, 12.5, 62.5, 312.5 seconds
 		Buckets: prometheus.ExponentialBuckets(0.1, 5, 6),
 	}, labelNames)
-	metrics.Registry.MustRegister(c2cMetric)
+	registerer.MustRegister(c2cMetric)
 	return c2cMetric
 }
 
-func NewPodCreateToCompleteFilter() *podCreateToCompleteFilter {
+func NewPodCreateToCompleteFilter(registerer prometheus.Registerer) *podCreateToCompleteFilter {
 	return &podCreateToCompleteFilter{
-		duration: NewPodCreateToCompleteMetric(),
+		duration: NewPodCreateToCompleteMetric(registerer),
 	}
 }
 
@@ -66,7 +65,7 @@ func (f *podCreateToCompleteFilter) Update(e event.UpdateEvent) bool {
 
 		// if first transition when old pod still had non-terminated containers, but the new pod does not, process
 		if oldTerminatedState == nil && newTerminatedState != nil {
-			labels := map[string]string{NS_LABEL: newpod.Namespace}
+			labels := withControlPlaneLabel(withTenantLabel(map[string]string{NS_LABEL: newpod.Namespace}, newpod.Namespace), newpod.Namespace)
 			// we've seen in staging, especially with errors and short durations, and corroborated by comments I see in tekton,
 			// where it is conceivable node times are not synchronized, when controller has been scheduled to other nodes than the pods, weird timestamps, etc.
 			// so we check
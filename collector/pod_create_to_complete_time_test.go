@@ -13,7 +13,7 @@ import (
 )
 
 func TestPodCreateToCompleteFilter_Update(t *testing.T) {
-	filter := NewPodCreateToCompleteFilter()
+	filter := NewPodCreateToCompleteFilter(prometheus.NewRegistry())
 	now := time.Now()
 	for _, tc := range []struct {
 		name                  string
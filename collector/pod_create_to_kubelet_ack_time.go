@@ -4,7 +4,6 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 /*
@@ -32,14 +31,14 @@ So,
 is "perhaps" the sum of those two
 */
 
-func NewPodCreateToKubeletDurationMetric() *prometheus.HistogramVec {
-	labelNames := []string{NS_LABEL}
+func NewPodCreateToKubeletDurationMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	labelNames := withControlPlaneLabelName([]string{NS_LABEL})
 	metric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "taskrun_pod_duration_kubelet_acknowledged_milliseconds",
 		Help:    "Duration in milliseconds between the pod creation time and pod start time, where the pod start time is set once the kubelet has acknowledged the pod, but has not yet pulled its images.",
 		Buckets: prometheus.ExponentialBuckets(float64(100), float64(5), 6),
 	}, labelNames)
-	metrics.Registry.MustRegister(metric)
+	registerer.MustRegister(metric)
 	return metric
 }
 
@@ -65,7 +64,7 @@ func (f *createKubeletLatencyFilter) Update(e event.UpdateEvent) bool {
 	newpod, oknew := e.ObjectNew.(*corev1.Pod)
 	if okold && oknew {
 		if oldpod.Status.StartTime == nil && newpod.Status.StartTime != nil {
-			labels := map[string]string{NS_LABEL: newpod.Namespace}
+			labels := withControlPlaneLabel(map[string]string{NS_LABEL: newpod.Namespace}, newpod.Namespace)
 			f.metric.With(labels).Observe(calculateTaskRunPodCreatedToKubeletAcceptsAndStartTimeSetDuration(newpod))
 			return false
 		}
@@ -12,7 +12,7 @@ import (
 
 func TestCreateKubeletLatencyFilter_Update(t *testing.T) {
 	filter := &createKubeletLatencyFilter{
-		metric: NewPodCreateToKubeletDurationMetric(),
+		metric: NewPodCreateToKubeletDurationMetric(prometheus.NewRegistry()),
 	}
 	for _, tc := range []struct {
 		name           string
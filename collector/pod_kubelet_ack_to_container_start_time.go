@@ -5,7 +5,6 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 /*
@@ -33,14 +32,14 @@ So,
 is "perhaps" the sum of those two
 */
 
-func NewPodKubeletToContainerStartDurationMetric() *prometheus.HistogramVec {
-	labelNames := []string{NS_LABEL}
+func NewPodKubeletToContainerStartDurationMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	labelNames := withControlPlaneLabelName([]string{NS_LABEL})
 	metric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "taskrun_pod_duration_kubelet_to_container_start_milliseconds",
 		Help:    "Duration in milliseconds between the pod start time and the first container to start. This should include any overhead to pull container images, plus any kubelet to linux scheduling overhead.",
 		Buckets: prometheus.ExponentialBuckets(float64(100), float64(5), 6),
 	}, labelNames)
-	metrics.Registry.MustRegister(metric)
+	registerer.MustRegister(metric)
 	return metric
 }
 
@@ -69,7 +68,7 @@ func (f *kubeletContainerLatencyFilter) Update(e event.UpdateEvent) bool {
 	oldpod, okold := e.ObjectOld.(*corev1.Pod)
 	newpod, oknew := e.ObjectNew.(*corev1.Pod)
 	if okold && oknew {
-		labels := map[string]string{NS_LABEL: newpod.Namespace}
+		labels := withControlPlaneLabel(map[string]string{NS_LABEL: newpod.Namespace}, newpod.Namespace)
 
 		if oldpod.Status.StartTime == nil && newpod.Status.StartTime == nil {
 			return false
@@ -12,7 +12,7 @@ import (
 
 func TestKubeletContainerLatencyFilter_Update(t *testing.T) {
 	filter := &kubeletContainerLatencyFilter{
-		metric: NewPodKubeletToContainerStartDurationMetric(),
+		metric: NewPodKubeletToContainerStartDurationMetric(prometheus.NewRegistry()),
 	}
 	for _, tc := range []struct {
 		name           string
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/lru"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	// NodePoolLabelEnvName is the node label whose value is the node pool of the node_pool label, like
+	// cloud.google.com/gke-nodepool; when not set, the first of DefaultNodePoolLabels the node has is used
+	NodePoolLabelEnvName = "NODE_POOL_LABEL"
+
+	ZONE_LABEL      = "zone"
+	NODE_POOL_LABEL = "node_pool"
+	// PlacementUnknown is the zone or node pool of nodes that cannot be read, or do not have the label
+	PlacementUnknown = "unknown"
+
+	// maxPlacedNodes bounds the nodes whose zone and pool are remembered, as autoscaled nodes come and go
+	maxPlacedNodes = 5000
+)
+
+// DefaultNodePoolLabels are the node pool labels of the managed Kubernetes offerings and of Karpenter, none of which
+// is standard; nodes with none of them, like those of OpenShift's machine sets, are in the unknown pool
+var DefaultNodePoolLabels = []string{
+	"cloud.google.com/gke-nodepool",
+	"eks.amazonaws.com/nodegroup",
+	"kubernetes.azure.com/agentpool",
+	"karpenter.sh/nodepool",
+}
+
+func nodePoolLabels() []string {
+	if len(settings.NodePoolLabel) == 0 {
+		return DefaultNodePoolLabels
+	}
+	return []string{settings.NodePoolLabel}
+}
+
+// nodeMetadata is what the pod placement reads of a node, through a metadata only informer
+func nodeMetadata() *metav1.PartialObjectMetadata {
+	node := &metav1.PartialObjectMetadata{}
+	node.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Node"))
+	return node
+}
+
+func NewPodPlacementMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	scheduled := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_taskrun_pods_scheduled_total",
+		Help: "Number of TaskRun pods scheduled to a node, by the zone and node pool of the node",
+	}, withControlPlaneLabelName([]string{ZONE_LABEL, NODE_POOL_LABEL}))
+	registerer.MustRegister(scheduled)
+	return scheduled
+}
+
+type nodePlacement struct {
+	zone string
+	pool string
+}
+
+// podPlacementFilter counts the TaskRun pods as they are bound to a node, so the scheduler packing the load onto one
+// zone can be seen; the zone and pool of a node are read from its labels the first time a pod lands on it, from the
+// metadata only informer of the nodes, and remembered for the most recently used nodes; nodes that cannot be read are
+// counted as unknown
+type podPlacementFilter struct {
+	metric     *prometheus.CounterVec
+	reader     client.Reader
+	poolLabels []string
+	nodes      *lru.Cache
+}
+
+// newPodPlacementFilter reads the nodes with the reader, which should be the manager's cache, with the informer of
+// nodeMetadata registered, so binding events do not wait on the API server
+func newPodPlacementFilter(metric *prometheus.CounterVec, reader client.Reader) *podPlacementFilter {
+	return &podPlacementFilter{metric: metric, reader: reader, poolLabels: nodePoolLabels(), nodes: lru.New(maxPlacedNodes)}
+}
+
+func (f *podPlacementFilter) placement(name string) nodePlacement {
+	if placement, ok := f.nodes.Get(name); ok {
+		return placement.(nodePlacement)
+	}
+	node := nodeMetadata()
+	if err := f.reader.Get(context.TODO(), types.NamespacedName{Name: name}, node); err != nil {
+		controllerLog.V(4).Info(fmt.Sprintf("could not get node %s for the pod placement: %s", name, err.Error()))
+		return nodePlacement{zone: PlacementUnknown, pool: PlacementUnknown}
+	}
+	placement := nodePlacement{zone: node.Labels[corev1.LabelTopologyZone], pool: PlacementUnknown}
+	if len(placement.zone) == 0 {
+		placement.zone = PlacementUnknown
+	}
+	for _, label := range f.poolLabels {
+		if pool := node.Labels[label]; len(pool) > 0 {
+			placement.pool = pool
+			break
+		}
+	}
+	f.nodes.Add(name, placement)
+	return placement
+}
+
+func (f *podPlacementFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *podPlacementFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *podPlacementFilter) Update(e event.UpdateEvent) bool {
+	oldPod, okold := e.ObjectOld.(*corev1.Pod)
+	newPod, oknew := e.ObjectNew.(*corev1.Pod)
+	if !okold || !oknew || len(oldPod.Spec.NodeName) > 0 || len(newPod.Spec.NodeName) == 0 {
+		return false
+	}
+	placement := f.placement(newPod.Spec.NodeName)
+	f.metric.With(withControlPlaneLabel(prometheus.Labels{ZONE_LABEL: placement.zone, NODE_POOL_LABEL: placement.pool}, newPod.Namespace)).Inc()
+	return false
+}
+
+func (f *podPlacementFilter) Generic(event.GenericEvent) bool {
+	return false
+}
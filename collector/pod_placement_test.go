@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestPodPlacementFilter(t *testing.T) {
+	defer setSettings(Settings{NodePoolLabel: "example.dev/pool"})()
+	node := func(name string, labels map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	cl := fake.NewClientBuilder().WithObjects(
+		node("node-1", map[string]string{corev1.LabelTopologyZone: "us-east-1a", "example.dev/pool": "ci"}),
+		node("node-2", map[string]string{corev1.LabelTopologyZone: "us-east-1b"}),
+	).Build()
+	metric := NewPodPlacementMetric(prometheus.NewRegistry())
+	filter := newPodPlacementFilter(metric, cl)
+	pod := func(nodeName string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1-pod"}, Spec: corev1.PodSpec{NodeName: nodeName}}
+	}
+
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pod(""), ObjectNew: pod("node-1")}))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pod(""), ObjectNew: pod("node-1")}))
+	// only the binding is counted
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pod("node-1"), ObjectNew: pod("node-1")}))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pod(""), ObjectNew: pod("node-2")}))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pod(""), ObjectNew: pod("gone")}))
+
+	validateCounterVec(t, metric, prometheus.Labels{ZONE_LABEL: "us-east-1a", NODE_POOL_LABEL: "ci"}, float64(2))
+	validateCounterVec(t, metric, prometheus.Labels{ZONE_LABEL: "us-east-1b", NODE_POOL_LABEL: PlacementUnknown}, float64(1))
+	validateCounterVec(t, metric, prometheus.Labels{ZONE_LABEL: PlacementUnknown, NODE_POOL_LABEL: PlacementUnknown}, float64(1))
+	// nodes that could not be read are tried again
+	assert.Equal(t, 2, filter.nodes.Len())
+}
+
+func TestPodPlacementFilter_DefaultPoolLabels(t *testing.T) {
+	defer setSettings(Settings{})()
+	cl := fake.NewClientBuilder().WithObjects(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{corev1.LabelTopologyZone: "us-east-1a", "eks.amazonaws.com/nodegroup": "ci", corev1.LabelInstanceTypeStable: "m5.xlarge"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2", Labels: map[string]string{corev1.LabelTopologyZone: "us-east-1a", corev1.LabelInstanceTypeStable: "m5.xlarge"}}},
+	).Build()
+	filter := newPodPlacementFilter(NewPodPlacementMetric(prometheus.NewRegistry()), cl)
+	// the instance type is not a pool
+	assert.Equal(t, nodePlacement{zone: "us-east-1a", pool: "ci"}, filter.placement("node-1"))
+	assert.Equal(t, nodePlacement{zone: "us-east-1a", pool: PlacementUnknown}, filter.placement("node-2"))
+}
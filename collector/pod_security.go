@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const LEVEL_LABEL = "level"
+
+// podSecurityPattern picks the enforcement level out of the pod creation error the tekton controller puts in the
+// condition message of the TaskRun, like `pods "x" is forbidden: violates PodSecurity "restricted:latest": ...`
+var podSecurityPattern = regexp.MustCompile(`violates PodSecurity "([a-z]+)(:[^"]*)?"`)
+
+func NewPodSecurityRejectionsMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	rejections := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_pod_security_rejections_total",
+		Help: "Number of TaskRuns that failed as the creation of their pod was rejected by the PodSecurity admission",
+	}, withTenantLabelName([]string{NS_LABEL, LEVEL_LABEL}))
+	registerer.MustRegister(rejections)
+	return rejections
+}
+
+// podSecurityLevel is the enforcement level of the namespace that rejected the pod of the TaskRun, empty when the
+// TaskRun did not fail for PodSecurity; the tekton controller fails the TaskRun with a CouldntGetTask reason for any
+// pod creation error up to 0.45, and PodCreationFailed after, so only the message is looked at
+func podSecurityLevel(condition *apis.Condition) string {
+	if condition == nil || !condition.IsFalse() {
+		return ""
+	}
+	match := podSecurityPattern.FindStringSubmatch(condition.Message)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// podSecurityFilter counts the TaskRuns whose pod was rejected by the PodSecurity admission as they become done, as
+// namespace label misconfigurations otherwise show up as inexplicable run failures
+type podSecurityFilter struct {
+	metric *prometheus.CounterVec
+}
+
+func (f *podSecurityFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *podSecurityFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *podSecurityFilter) Update(e event.UpdateEvent) bool {
+	oldTR, okold := e.ObjectOld.(*v1.TaskRun)
+	newTR, oknew := e.ObjectNew.(*v1.TaskRun)
+	if !okold || !oknew || oldTR.IsDone() || !newTR.IsDone() {
+		return false
+	}
+	level := podSecurityLevel(newTR.Status.GetCondition(apis.ConditionSucceeded))
+	if len(level) == 0 {
+		return false
+	}
+	f.metric.With(withTenantLabel(map[string]string{NS_LABEL: newTR.Namespace, LEVEL_LABEL: level}, newTR.Namespace)).Inc()
+	return false
+}
+
+func (f *podSecurityFilter) Generic(event.GenericEvent) bool {
+	return false
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const podSecurityMessage = `failed to create task run pod "test-1": pods "test-1-pod" is forbidden: violates PodSecurity "restricted:latest": ` +
+	`allowPrivilegeEscalation != false (container "step-build" must set securityContext.allowPrivilegeEscalation=false). Maybe invalid TaskSpec`
+
+func TestPodSecurityLevel(t *testing.T) {
+	failed := func(message string) *apis.Condition {
+		return &apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, Reason: "CouldntGetTask", Message: message}
+	}
+	assert.Equal(t, "restricted", podSecurityLevel(failed(podSecurityMessage)))
+	assert.Equal(t, "baseline", podSecurityLevel(failed(`pods "x" is forbidden: violates PodSecurity "baseline": host namespaces`)))
+	assert.Equal(t, "", podSecurityLevel(failed(`pods "x" is forbidden: exceeded quota`)))
+	assert.Equal(t, "", podSecurityLevel(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionUnknown, Message: podSecurityMessage}))
+	assert.Equal(t, "", podSecurityLevel(nil))
+}
+
+func TestPodSecurityFilter(t *testing.T) {
+	metric := NewPodSecurityRejectionsMetric(prometheus.NewRegistry())
+	filter := &podSecurityFilter{metric: metric}
+	tr := func(status corev1.ConditionStatus, message string) *v1.TaskRun {
+		return &v1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"},
+			Status: v1.TaskRunStatus{Status: duckv1.Status{Conditions: duckv1.Conditions{{
+				Type: apis.ConditionSucceeded, Status: status, Reason: "CouldntGetTask", Message: message}}}},
+		}
+	}
+	running := tr(corev1.ConditionUnknown, "")
+	rejected := tr(corev1.ConditionFalse, podSecurityMessage)
+
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: rejected}))
+	// only the transition to done is counted
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: rejected, ObjectNew: rejected}))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: tr(corev1.ConditionFalse, "Maybe missing or invalid Task")}))
+
+	validateCounterVec(t, metric, prometheus.Labels{NS_LABEL: "test-namespace", LEVEL_LABEL: "restricted"}, float64(1))
+}
@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// PullSecretAgingEnvName turns on the optional pull-secrets collector, which needs to list service accounts and
+	// get secrets across the cluster
+	PullSecretAgingEnvName = "PULL_SECRET_AGING"
+	// PipelineServiceAccountsEnvName is a comma separated list of the names of the service accounts the pipelines run
+	// as, whose image pull secrets are tracked, DefaultPipelineServiceAccounts when not set
+	PipelineServiceAccountsEnvName = "PIPELINE_SERVICE_ACCOUNTS"
+	// PullSecretExpiryWarningEnvName is a duration, like 72h, before the expiry of a pull secret that it counts as
+	// expiring, DefaultPullSecretExpiryWarning when not set
+	PullSecretExpiryWarningEnvName = "PULL_SECRET_EXPIRY_WARNING"
+	DefaultPullSecretExpiryWarning = 7 * 24 * time.Hour
+
+	// PULL_SECRET_EXPIRY_ANNOTATION is the RFC3339 time a pull secret expires at, set by whatever provisions the
+	// credentials, as docker config secrets do not say when their credentials expire
+	PULL_SECRET_EXPIRY_ANNOTATION = "pipelineservice.appstudio.io/expires-at"
+)
+
+// DefaultPipelineServiceAccounts are the service accounts OpenShift Pipelines and RHTAP run the pipelines as
+var DefaultPipelineServiceAccounts = []string{"pipeline", "appstudio-pipeline"}
+
+func pipelineServiceAccounts() []string {
+	if len(settings.PipelineServiceAccounts) == 0 {
+		return DefaultPipelineServiceAccounts
+	}
+	return settings.PipelineServiceAccounts
+}
+
+func pullSecretExpiryWarning() time.Duration {
+	if settings.PullSecretExpiryWarning <= 0 {
+		return DefaultPullSecretExpiryWarning
+	}
+	return settings.PullSecretExpiryWarning
+}
+
+// PullSecretCollector polls the image pull secrets linked to the pipeline service accounts, exposing per namespace
+// the age of the oldest one and how many expire soon, as expired pull secrets have caused fleet wide build failures;
+// only the metadata of the secrets is read, never their data.  Both the image pull secrets of the service accounts and
+// their secrets are tracked, as OpenShift links the generated pull secret through the latter
+type PullSecretCollector struct {
+	registerer *collectorRegisterer
+	reader     client.Reader
+	oldest     *prometheus.GaugeVec
+	expiring   *prometheus.GaugeVec
+	// published are the namespaces with series, so those no longer tracked are deleted after the others are set
+	published map[string]struct{}
+}
+
+func NewPullSecretCollector(registerer prometheus.Registerer, reader client.Reader) *PullSecretCollector {
+	reg := newCollectorRegisterer(registerer)
+	oldest := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_pull_secret_oldest_age_seconds",
+		Help: "Age in seconds of the oldest image pull secret linked to a pipeline service account of the namespace",
+	}, withTenantLabelName([]string{NS_LABEL}))
+	expiring := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_expiring_pull_secrets",
+		Help: "Number of image pull secrets linked to the pipeline service accounts of the namespace that have expired, or expire within the warning period",
+	}, withTenantLabelName([]string{NS_LABEL}))
+	reg.MustRegister(oldest, expiring)
+	return &PullSecretCollector{registerer: reg, reader: reader, oldest: oldest, expiring: expiring, published: map[string]struct{}{}}
+}
+
+// Close unregisters the metrics of the collector
+func (c *PullSecretCollector) Close() {
+	c.registerer.Close()
+}
+
+// expiring is whether the pull secret expires before the deadline, per its expiry annotation; secrets without the
+// annotation, or with one that does not parse, never do
+func pullSecretExpiring(secret *metav1.PartialObjectMetadata, deadline time.Time) bool {
+	expiresAt, ok := secret.Annotations[PULL_SECRET_EXPIRY_ANNOTATION]
+	if !ok {
+		return false
+	}
+	expiry, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		controllerLog.V(4).Info(fmt.Sprintf("ignoring the %s annotation %q of secret %s/%s: %s",
+			PULL_SECRET_EXPIRY_ANNOTATION, expiresAt, secret.Namespace, secret.Name, err.Error()))
+		return false
+	}
+	return expiry.Before(deadline)
+}
+
+// linkedSecrets are the secrets linked to the pipeline service accounts across the cluster
+func (c *PullSecretCollector) linkedSecrets(ctx context.Context) (map[types.NamespacedName]struct{}, error) {
+	linked := map[types.NamespacedName]struct{}{}
+	for _, name := range pipelineServiceAccounts() {
+		sas := &corev1.ServiceAccountList{}
+		if err := c.reader.List(ctx, sas, client.MatchingFieldsSelector{Selector: fields.OneTermEqualSelector("metadata.name", name)}); err != nil {
+			return nil, err
+		}
+		for _, sa := range sas.Items {
+			for _, ref := range sa.ImagePullSecrets {
+				linked[types.NamespacedName{Namespace: sa.Namespace, Name: ref.Name}] = struct{}{}
+			}
+			for _, ref := range sa.Secrets {
+				linked[types.NamespacedName{Namespace: sa.Namespace, Name: ref.Name}] = struct{}{}
+			}
+		}
+	}
+	return linked, nil
+}
+
+// rollup recomputes the gauges from the pipeline service accounts across the cluster, with a single list of the
+// metadata of the secrets vs. a get per secret; a linked secret that is not there is for the image pull failures to
+// show
+func (c *PullSecretCollector) rollup(ctx context.Context, now time.Time) error {
+	linked, err := c.linkedSecrets(ctx)
+	if err != nil {
+		return err
+	}
+	secrets := &metav1.PartialObjectMetadataList{}
+	secrets.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("SecretList"))
+	if err = c.reader.List(ctx, secrets); err != nil {
+		return err
+	}
+	oldest := map[string]time.Duration{}
+	expiring := map[string]int{}
+	deadline := now.Add(pullSecretExpiryWarning())
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if _, ok := linked[types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}]; !ok {
+			continue
+		}
+		if age := now.Sub(secret.CreationTimestamp.Time); age > oldest[secret.Namespace] {
+			oldest[secret.Namespace] = age
+		}
+		if pullSecretExpiring(secret, deadline) {
+			expiring[secret.Namespace]++
+		}
+	}
+	// set before deleting what is gone, vs. a reset, so a scrape in between does not miss the namespaces
+	for ns, age := range oldest {
+		labels := withTenantLabel(map[string]string{NS_LABEL: ns}, ns)
+		c.oldest.With(labels).Set(age.Seconds())
+		c.expiring.With(labels).Set(float64(expiring[ns]))
+	}
+	for ns := range c.published {
+		if _, ok := oldest[ns]; !ok {
+			c.oldest.DeletePartialMatch(prometheus.Labels{NS_LABEL: ns})
+			c.expiring.DeletePartialMatch(prometheus.Labels{NS_LABEL: ns})
+		}
+	}
+	c.published = map[string]struct{}{}
+	for ns := range oldest {
+		c.published[ns] = struct{}{}
+	}
+	return nil
+}
+
+func (c *PullSecretCollector) poll(ctx context.Context) {
+	if err := c.rollup(ctx, exporterClock.Now()); err != nil {
+		controllerLog.Info(fmt.Sprintf("could not list the pipeline service accounts or secrets for the pull secret aging: %s", err.Error()))
+	}
+}
+
+func (c *PullSecretCollector) Start(ctx context.Context) error {
+	c.poll(ctx)
+	eventTicker := exporterClock.NewTicker(10 * time.Minute)
+	defer eventTicker.Stop()
+	for {
+		select {
+		case <-eventTicker.C():
+			c.poll(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
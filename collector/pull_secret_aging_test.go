@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPullSecretCollector(t *testing.T) {
+	defer setSettings(Settings{PullSecretAging: true, PullSecretExpiryWarning: 72 * time.Hour})()
+	now := time.Now().Truncate(time.Second)
+	sa := func(ns, name string, secrets ...string) *corev1.ServiceAccount {
+		account := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name}}
+		for _, secret := range secrets {
+			account.ImagePullSecrets = append(account.ImagePullSecrets, corev1.LocalObjectReference{Name: secret})
+		}
+		return account
+	}
+	secret := func(ns, name string, age time.Duration, expiresAt string) *corev1.Secret {
+		s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, CreationTimestamp: metav1.NewTime(now.Add(-age))}}
+		if len(expiresAt) > 0 {
+			s.Annotations = map[string]string{PULL_SECRET_EXPIRY_ANNOTATION: expiresAt}
+		}
+		return s
+	}
+	cl := fake.NewClientBuilder().
+		WithIndex(&corev1.ServiceAccount{}, "metadata.name", func(obj client.Object) []string { return []string{obj.GetName()} }).
+		WithObjects(
+			sa("test-namespace", "pipeline", "quay", "registry"),
+			sa("test-namespace", "appstudio-pipeline", "quay"),
+			sa("test-namespace", "default", "ignored"),
+			&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "linked-namespace", Name: "pipeline"}, Secrets: []corev1.ObjectReference{{Name: "pipeline-dockercfg"}}},
+			secret("linked-namespace", "pipeline-dockercfg", time.Hour, ""),
+			sa("other-namespace", "pipeline", "missing"),
+			secret("test-namespace", "quay", 24*time.Hour, now.Add(24*time.Hour).Format(time.RFC3339)),
+			secret("test-namespace", "registry", 90*24*time.Hour, now.Add(30*24*time.Hour).Format(time.RFC3339)),
+			secret("test-namespace", "ignored", 365*24*time.Hour, now.Add(-time.Hour).Format(time.RFC3339)),
+		).Build()
+	registry := prometheus.NewPedanticRegistry()
+	c := NewPullSecretCollector(registry, cl)
+	defer c.Close()
+
+	assert.NoError(t, c.rollup(context.TODO(), now))
+	families := gatherFamilies(t, registry)
+	// the namespace whose only pull secret is missing has no series
+	assert.Equal(t, map[string]float64{"test-namespace/": (90 * 24 * time.Hour).Seconds(), "linked-namespace/": time.Hour.Seconds()},
+		gaugesByLabels(families["pipeline_service_pull_secret_oldest_age_seconds"]))
+	assert.Equal(t, map[string]float64{"test-namespace/": 1, "linked-namespace/": 0}, gaugesByLabels(families["pipeline_service_expiring_pull_secrets"]))
+
+	// a namespace no longer tracked is dropped
+	assert.NoError(t, cl.Delete(context.TODO(), secret("linked-namespace", "pipeline-dockercfg", 0, "")))
+	assert.NoError(t, c.rollup(context.TODO(), now))
+	assert.Equal(t, map[string]float64{"test-namespace/": 1}, gaugesByLabels(gatherFamilies(t, registry)["pipeline_service_expiring_pull_secrets"]))
+}
+
+func TestPullSecretExpiring(t *testing.T) {
+	now := time.Now()
+	secret := func(annotations map[string]string) *metav1.PartialObjectMetadata {
+		return &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}}
+	}
+	assert.True(t, pullSecretExpiring(secret(map[string]string{PULL_SECRET_EXPIRY_ANNOTATION: now.Add(-time.Hour).Format(time.RFC3339)}), now))
+	assert.False(t, pullSecretExpiring(secret(map[string]string{PULL_SECRET_EXPIRY_ANNOTATION: now.Add(time.Hour).Format(time.RFC3339)}), now))
+	assert.False(t, pullSecretExpiring(secret(map[string]string{PULL_SECRET_EXPIRY_ANNOTATION: "next week"}), now))
+	assert.False(t, pullSecretExpiring(secret(nil), now))
+}
@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+)
+
+const (
+	// RecentRunsEnvName is how many completed PipelineRuns the overhead collector keeps its results for,
+	// DefaultRecentRuns when not set
+	RecentRunsEnvName = "RECENT_RUNS"
+	DefaultRecentRuns = 100
+	// RecentRunsPath is where the recent runs are served as JSON on the admin listener
+	RecentRunsPath = "/debug/recent-runs"
+)
+
+// RecentRun is what the overhead collector made of a completed PipelineRun, kept in memory so it can be looked at
+// after an alert even if the PipelineRun and its TaskRuns have since been pruned
+type RecentRun struct {
+	Namespace string
+	Name      string
+	UID       types.UID
+	// Status is the status label value of the PipelineRun
+	Status         string
+	CompletionTime time.Time
+	// ObservedTime is when the overhead collector reconciled the completed PipelineRun
+	ObservedTime time.Time
+	Gaps         GapResult
+	// Decisions is whether the overhead collector recorded the PipelineRun, and if not, why, like with Explain
+	Decisions    []Decision
+	Observations []Observation
+}
+
+func newRecentRun(pr *v1.PipelineRun, succeedCondition *apis.Condition) *RecentRun {
+	run := &RecentRun{
+		Namespace:    pr.Namespace,
+		Name:         pr.Name,
+		UID:          pr.UID,
+		Status:       runStatus(succeedCondition),
+		ObservedTime: exporterClock.Now(),
+		Gaps:         GapResult{Entries: []GapEntry{}},
+		Decisions:    []Decision{},
+		Observations: []Observation{},
+	}
+	if pr.Status.CompletionTime != nil {
+		run.CompletionTime = pr.Status.CompletionTime.Time
+	}
+	return run
+}
+
+// decide records the overhead collector's decision, recorded when there is no reason it was not
+func (run *RecentRun) decide(reason string) {
+	run.Decisions = append(run.Decisions, Decision{Collector: CollectorOverhead, Run: run.Name, Recorded: len(reason) == 0, Reason: reason})
+}
+
+func (run *RecentRun) observe(metric string, labels map[string]string, value float64) {
+	run.Observations = append(run.Observations, Observation{Metric: metric, Labels: labels, Value: value})
+}
+
+// recentRunBuffer is a ring buffer of the most recent runs, dropping the oldest once full
+type recentRunBuffer struct {
+	lock sync.Mutex
+	runs []RecentRun
+	next int
+	full bool
+}
+
+func newRecentRunBuffer(size int) *recentRunBuffer {
+	if size <= 0 {
+		size = DefaultRecentRuns
+	}
+	return &recentRunBuffer{runs: make([]RecentRun, size)}
+}
+
+func (b *recentRunBuffer) add(run *RecentRun) {
+	if b == nil || run == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.runs[b.next] = *run
+	b.next = (b.next + 1) % len(b.runs)
+	b.full = b.full || b.next == 0
+}
+
+// list is the runs in the namespace, with the name, if set, newest first
+func (b *recentRunBuffer) list(namespace, name string) []RecentRun {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	count := b.next
+	if b.full {
+		count = len(b.runs)
+	}
+	runs := []RecentRun{}
+	for i := 1; i <= count; i++ {
+		run := b.runs[(b.next-i+len(b.runs))%len(b.runs)]
+		if (len(namespace) > 0 && run.Namespace != namespace) || (len(name) > 0 && run.Name != name) {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// ServeHTTP returns the runs as JSON, newest first, optionally limited to those matching the namespace and name
+// query parameters, and to the limit query parameter's number of runs
+func (b *recentRunBuffer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	runs := b.list(query.Get("namespace"), query.Get("name"))
+	if limit := query.Get("limit"); len(limit) > 0 {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		if n < len(runs) {
+			runs = runs[:n]
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(runs); err != nil {
+		controllerLog.Info("unable to write the recent runs: " + err.Error())
+	}
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func runNames(runs []RecentRun) []string {
+	n := []string{}
+	for _, run := range runs {
+		n = append(n, run.Namespace+"/"+run.Name)
+	}
+	return n
+}
+
+func TestRecentRunBuffer(t *testing.T) {
+	b := newRecentRunBuffer(3)
+	assert.Empty(t, b.list("", ""))
+	for i := 1; i <= 4; i++ {
+		b.add(&RecentRun{Namespace: fmt.Sprintf("ns-%d", i%2), Name: fmt.Sprintf("test-%d", i)})
+	}
+	// test-1 was dropped once the buffer wrapped
+	assert.Equal(t, []string{"ns-0/test-4", "ns-1/test-3", "ns-0/test-2"}, runNames(b.list("", "")))
+	assert.Equal(t, []string{"ns-0/test-4", "ns-0/test-2"}, runNames(b.list("ns-0", "")))
+	assert.Equal(t, []string{"ns-1/test-3"}, runNames(b.list("", "test-3")))
+	assert.Empty(t, b.list("ns-1", "test-1"))
+
+	var unset *recentRunBuffer
+	unset.add(&RecentRun{Name: "test-1"})
+}
+
+func TestRecentRunBuffer_ServeHTTP(t *testing.T) {
+	b := newRecentRunBuffer(0)
+	for i := 1; i <= 3; i++ {
+		b.add(&RecentRun{Namespace: "test-namespace", Name: fmt.Sprintf("test-%d", i)})
+	}
+	get := func(query string) (int, []RecentRun) {
+		w := httptest.NewRecorder()
+		b.ServeHTTP(w, httptest.NewRequest(http.MethodGet, RecentRunsPath+query, nil))
+		runs := []RecentRun{}
+		if w.Code == http.StatusOK {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &runs))
+		}
+		return w.Code, runs
+	}
+	code, runs := get("?namespace=test-namespace&limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"test-namespace/test-3", "test-namespace/test-2"}, runNames(runs))
+	_, runs = get("?name=test-1")
+	assert.Equal(t, []string{"test-namespace/test-1"}, runNames(runs))
+	code, _ = get("?limit=-1")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestReconcileOverhead_RecentRuns(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	// the fake client round trips the times at second precision
+	now := time.Now().UTC().Truncate(time.Second)
+	pr := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "no-taskruns", Namespace: "test-namespace", UID: "test-uid", CreationTimestamp: metav1.NewTime(now)},
+		Status: v1.PipelineRunStatus{
+			Status: duckv1.Status{Conditions: duckv1.Conditions{{Type: "Succeeded", Status: corev1.ConditionTrue}}},
+			PipelineRunStatusFields: v1.PipelineRunStatusFields{
+				StartTime:      &metav1.Time{Time: now},
+				CompletionTime: &metav1.Time{Time: now.Add(time.Minute)},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr).Build()
+	overheadReconciler := buildReconciler(c, nil, nil)
+	defer overheadReconciler.Close()
+	_, err := overheadReconciler.ReconcileOverhead(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}})
+	assert.NoError(t, err)
+
+	runs := overheadReconciler.recentRuns.list("test-namespace", "no-taskruns")
+	assert.Len(t, runs, 1)
+	assert.Equal(t, types.UID("test-uid"), runs[0].UID)
+	assert.Equal(t, SUCCEEDED, runs[0].Status)
+	assert.True(t, runs[0].CompletionTime.Equal(now.Add(time.Minute)))
+	assert.Equal(t, GapSkipNoTaskRuns, runs[0].Gaps.Reason)
+	assert.Equal(t, []Decision{{Collector: CollectorOverhead, Run: "no-taskruns", Reason: GapSkipNoTaskRuns}}, runs[0].Decisions)
+	assert.Empty(t, runs[0].Observations)
+}
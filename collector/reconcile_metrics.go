@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	COLLECTOR_LABEL = "collector"
+	OUTCOME_LABEL   = "outcome"
+
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+	OutcomeRequeue = "requeue"
+)
+
+// ReconcileMetricsCollector times the reconcile of each collector with one, and counts their outcomes, as
+// controller-runtime's own reconcile metrics are per controller, covering all the collectors at once
+type ReconcileMetricsCollector struct {
+	registerer *collectorRegisterer
+	duration   *prometheus.HistogramVec
+	outcomes   *prometheus.CounterVec
+}
+
+func NewReconcileMetricsCollector(registerer prometheus.Registerer) *ReconcileMetricsCollector {
+	reg := newCollectorRegisterer(registerer)
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipeline_service_exporter_reconcile_duration_seconds",
+		Help:    "Duration in seconds of the reconcile of each collector",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30},
+	}, []string{COLLECTOR_LABEL})
+	outcomes := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_reconcile_total",
+		Help: "Number of reconciles of each collector, by whether they succeeded, failed, or requeued the object",
+	}, []string{COLLECTOR_LABEL, OUTCOME_LABEL})
+	reg.MustRegister(duration, outcomes)
+	return &ReconcileMetricsCollector{registerer: reg, duration: duration, outcomes: outcomes}
+}
+
+// Close unregisters the metrics of the collector
+func (c *ReconcileMetricsCollector) Close() {
+	c.registerer.Close()
+}
+
+// observe runs the reconcile of the named collector, recording how long it took and how it turned out
+func (c *ReconcileMetricsCollector) observe(ctx context.Context, name string, request reconcile.Request,
+	reconcileFunc func(context.Context, reconcile.Request) (reconcile.Result, error)) (reconcile.Result, error) {
+	start := exporterClock.Now()
+	result, err := reconcileFunc(ctx, request)
+	c.duration.With(prometheus.Labels{COLLECTOR_LABEL: name}).Observe(exporterClock.Since(start).Seconds())
+	outcome := OutcomeSuccess
+	switch {
+	case err != nil:
+		outcome = OutcomeError
+	case result.Requeue || result.RequeueAfter > 0:
+		outcome = OutcomeRequeue
+	}
+	c.outcomes.With(prometheus.Labels{COLLECTOR_LABEL: name, OUTCOME_LABEL: outcome}).Inc()
+	return result, err
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileMetrics(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	defer setClock(fakeClock)()
+	registry := prometheus.NewRegistry()
+	c := NewReconcileMetricsCollector(registry)
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "test-1"}}
+
+	slow := func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+		fakeClock.Step(2 * time.Second)
+		return reconcile.Result{}, nil
+	}
+	requeue := func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+		return reconcile.Result{RequeueAfter: time.Second}, nil
+	}
+	failing := func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+		return reconcile.Result{}, fmt.Errorf("failed")
+	}
+	_, err := c.observe(context.Background(), CollectorTaskRunGaps, request, slow)
+	assert.NoError(t, err)
+	result, err := c.observe(context.Background(), CollectorOverhead, request, requeue)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, result.RequeueAfter)
+	_, err = c.observe(context.Background(), CollectorOverhead, request, failing)
+	assert.Error(t, err)
+
+	observer, err := c.duration.GetMetricWith(prometheus.Labels{COLLECTOR_LABEL: CollectorTaskRunGaps})
+	assert.NoError(t, err)
+	metric := &dto.Metric{}
+	assert.NoError(t, observer.(prometheus.Metric).Write(metric))
+	assert.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(2), metric.GetHistogram().GetSampleSum())
+	validateCounterVec(t, c.outcomes, prometheus.Labels{COLLECTOR_LABEL: CollectorTaskRunGaps, OUTCOME_LABEL: OutcomeSuccess}, float64(1))
+	validateCounterVec(t, c.outcomes, prometheus.Labels{COLLECTOR_LABEL: CollectorOverhead, OUTCOME_LABEL: OutcomeRequeue}, float64(1))
+	validateCounterVec(t, c.outcomes, prometheus.Labels{COLLECTOR_LABEL: CollectorOverhead, OUTCOME_LABEL: OutcomeError}, float64(1))
+
+	c.Close()
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 0)
+}
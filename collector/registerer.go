@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectorRegisterer registers a collector's metrics with the registerer injected into it, keeping track of them so
+// that Close can unregister them all, and the collector can then be constructed again against the same registry
+type collectorRegisterer struct {
+	lock       sync.Mutex
+	registerer prometheus.Registerer
+	registered []prometheus.Collector
+	// suspended is true while the metrics are unregistered by suspend
+	suspended bool
+}
+
+func newCollectorRegisterer(registerer prometheus.Registerer) *collectorRegisterer {
+	return &collectorRegisterer{registerer: registerer}
+}
+
+func (r *collectorRegisterer) Register(c prometheus.Collector) error {
+	err := r.registerer.Register(c)
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.registered = append(r.registered, c)
+	return nil
+}
+
+func (r *collectorRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *collectorRegisterer) Unregister(c prometheus.Collector) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, registered := range r.registered {
+		if registered == c {
+			r.registered = append(r.registered[:i], r.registered[i+1:]...)
+			break
+		}
+	}
+	return r.registerer.Unregister(c)
+}
+
+// Close unregisters everything registered through r
+func (r *collectorRegisterer) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, c := range r.registered {
+		r.registerer.Unregister(c)
+	}
+	r.registered = nil
+	r.suspended = false
+}
+
+// suspend unregisters everything registered through r, so its series are no longer gathered, but keeps track of it
+// for resume
+func (r *collectorRegisterer) suspend() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.suspended {
+		return
+	}
+	for _, c := range r.registered {
+		r.registerer.Unregister(c)
+	}
+	r.suspended = true
+}
+
+// resume registers again everything suspend unregistered
+func (r *collectorRegisterer) resume() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.suspended {
+		return
+	}
+	r.suspended = false
+	for _, c := range r.registered {
+		if err := r.registerer.Register(c); err != nil {
+			controllerLog.Info("unable to register a metric again: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestCollectorRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	reg := newCollectorRegisterer(registry)
+	first := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_registerer_first", Help: "test"})
+	second := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_registerer_second", Help: "test"})
+	reg.MustRegister(first, second)
+	assert.Error(t, reg.Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "test_registerer_first", Help: "test"})))
+	assert.True(t, reg.Unregister(second))
+	assert.Len(t, reg.registered, 1)
+	reg.Close()
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 0)
+	// closing twice is fine
+	reg.Close()
+}
+
+func TestRepeatedConstruction(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	for i := 0; i < 2; i++ {
+		overhead := NewOverheadCollector(registry)
+		lifecycle := NewNamespaceLifecycleCollector(registry)
+		overhead.Close()
+		lifecycle.Close()
+	}
+	// the reconciler closes all of its collectors
+	c := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()
+	for i := 0; i < 2; i++ {
+		r := buildReconciler(c, nil, nil)
+		r.Close()
+	}
+}
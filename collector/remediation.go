@@ -0,0 +1,279 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RemediationActionsEnvName is a comma separated list of <detector name>=<action> pairs; remediation is opt-in,
+	// so no detector has an action unless listed here
+	RemediationActionsEnvName = "REMEDIATION_ACTIONS"
+	// RemediationKillSwitchEnvName halts all remediation, regardless of what is configured in REMEDIATION_ACTIONS
+	RemediationKillSwitchEnvName = "REMEDIATION_KILL_SWITCH"
+	RemediationWebhookEnvName    = "REMEDIATION_WEBHOOK_URL"
+
+	RemediationActionAnnotate  = "annotate"
+	RemediationActionDeletePod = "delete-pod"
+	RemediationActionWebhook   = "webhook"
+
+	DEADLOCK_DETECTED_ANNOTATION = "pipelineservice.appstudio.io/deadlock-detected"
+)
+
+// Remediator is the action taken on an object once a deadlock detector has flagged it across two consecutive scans
+type Remediator interface {
+	Action() string
+	Remediate(ctx context.Context, c client.Client, detector string, obj client.Object) error
+}
+
+type annotateRemediator struct{}
+
+func (a *annotateRemediator) Action() string {
+	return RemediationActionAnnotate
+}
+
+func (a *annotateRemediator) Remediate(ctx context.Context, c client.Client, detector string, obj client.Object) error {
+	if _, ok := obj.GetAnnotations()[DEADLOCK_DETECTED_ANNOTATION]; ok {
+		return nil
+	}
+	changed := obj.DeepCopyObject().(client.Object)
+	annotations := changed.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[DEADLOCK_DETECTED_ANNOTATION] = detector
+	changed.SetAnnotations(annotations)
+	return c.Patch(ctx, changed, client.MergeFrom(obj))
+}
+
+// podlessDetectors are the built-in detectors whose flagged objects have no pod yet, the TaskRuns whose pod creation
+// was never attempted and the PipelineRuns not kicked off, so the delete-pod action has nothing to delete for them
+var podlessDetectors = map[string]struct{}{
+	PodCreateAttemptDetectorName:   {},
+	PipelineRunKickoffDetectorName: {},
+}
+
+// actionApplies is false for the actions which could never do anything for the objects the detector flags
+func actionApplies(detector, action string) bool {
+	if action != RemediationActionDeletePod {
+		return true
+	}
+	_, podless := podlessDetectors[detector]
+	return !podless
+}
+
+type deletePodRemediator struct{}
+
+func (d *deletePodRemediator) Action() string {
+	return RemediationActionDeletePod
+}
+
+func (d *deletePodRemediator) Remediate(ctx context.Context, c client.Client, detector string, obj client.Object) error {
+	var pod client.Object
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		pod = o
+	case *v1.TaskRun:
+		if len(o.Status.PodName) == 0 {
+			return fmt.Errorf("taskrun %s:%s has no pod to delete", o.Namespace, o.Name)
+		}
+		pod = &corev1.Pod{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: o.Namespace, Name: o.Status.PodName}, pod)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("action %s does not apply to %T", RemediationActionDeletePod, obj)
+	}
+	err := c.Delete(ctx, pod)
+	if err != nil && errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+type webhookRemediator struct {
+	url    string
+	client *http.Client
+}
+
+type webhookPayload struct {
+	Detector  string    `json:"detector"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	UID       string    `json:"uid"`
+	Time      time.Time `json:"time"`
+}
+
+func (w *webhookRemediator) Action() string {
+	return RemediationActionWebhook
+}
+
+func (w *webhookRemediator) Remediate(ctx context.Context, c client.Client, detector string, obj client.Object) error {
+	payload := webhookPayload{
+		Detector:  detector,
+		Kind:      resourceOf(obj),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		UID:       string(obj.GetUID()),
+		Time:      exporterClock.Now(),
+	}
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rsp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s returned status %d", w.url, rsp.StatusCode)
+	}
+	return nil
+}
+
+func newRemediator(action string) (Remediator, error) {
+	switch action {
+	case RemediationActionAnnotate:
+		return &annotateRemediator{}, nil
+	case RemediationActionDeletePod:
+		return &deletePodRemediator{}, nil
+	case RemediationActionWebhook:
+		webhookURL := settings.RemediationWebhookURL
+		if len(webhookURL) == 0 {
+			return nil, fmt.Errorf("remediation action %s requires %s to be set", RemediationActionWebhook, RemediationWebhookEnvName)
+		}
+		return &webhookRemediator{url: webhookURL, client: &http.Client{Timeout: 10 * time.Second}}, nil
+	}
+	return nil, fmt.Errorf("unknown remediation action %s", action)
+}
+
+// remediationTracker makes sure we only remediate an object once while it stays flagged, as the poll loop
+// will flag a stuck object on every scan until it is no longer stuck
+type remediationTracker struct {
+	detector    string
+	remediator  Remediator
+	previousHit map[string]time.Time
+	currentHit  map[string]time.Time
+}
+
+func remediationTrackers() map[string]*remediationTracker {
+	trackers := map[string]*remediationTracker{}
+	env := settings.RemediationActions
+	if len(strings.TrimSpace(env)) == 0 {
+		return trackers
+	}
+	for _, pair := range strings.Split(env, ",") {
+		detectorAndAction := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(detectorAndAction) != 2 {
+			controllerLog.Info(fmt.Sprintf("ignoring malformed %s entry %q", RemediationActionsEnvName, pair))
+			continue
+		}
+		detector, action := strings.TrimSpace(detectorAndAction[0]), strings.TrimSpace(detectorAndAction[1])
+		if !actionApplies(detector, action) {
+			controllerLog.Info(fmt.Sprintf("ignoring remediation action %s for detector %s, whose flagged objects have no pod", action, detector))
+			continue
+		}
+		remediator, err := newRemediator(action)
+		if err != nil {
+			controllerLog.Error(err, fmt.Sprintf("ignoring remediation for detector %s", detector))
+			continue
+		}
+		trackers[detector] = &remediationTracker{
+			detector:    detector,
+			remediator:  remediator,
+			previousHit: map[string]time.Time{},
+			currentHit:  map[string]time.Time{},
+		}
+	}
+	return trackers
+}
+
+func (t *remediationTracker) remediate(ctx context.Context, c client.Client, obj client.Object) {
+	if t == nil {
+		return
+	}
+	key := obj.GetNamespace() + "/" + obj.GetName()
+	if _, done := t.previousHit[key]; done {
+		t.currentHit[key] = exporterClock.Now()
+		return
+	}
+	oldValue, newValue := remediationChange(t.remediator.Action(), t.detector, obj)
+	rec := AuditRecord{
+		Who:    "detector/" + t.detector,
+		What:   "remediation/" + t.remediator.Action(),
+		Target: fmt.Sprintf("%s %s uid %s", resourceOf(obj), key, obj.GetUID()),
+		Old:    oldValue,
+		New:    newValue,
+	}
+	if settings.RemediationKillSwitch {
+		rec.Outcome, rec.Err = AuditOutcomeSkipped, fmt.Errorf("%s is set", RemediationKillSwitchEnvName)
+		Audit(rec)
+		return
+	}
+	err := t.remediator.Remediate(ctx, c, t.detector, obj)
+	if err != nil {
+		// don't mark as done so we try again on the next scan
+		rec.Outcome, rec.Err = AuditOutcomeFailure, err
+		Audit(rec)
+		return
+	}
+	t.currentHit[key] = exporterClock.Now()
+	rec.Outcome = AuditOutcomeSuccess
+	Audit(rec)
+}
+
+// remediationChange is what the action changes about obj, for its audit record
+func remediationChange(action, detector string, obj client.Object) (string, string) {
+	switch action {
+	case RemediationActionAnnotate:
+		return DEADLOCK_DETECTED_ANNOTATION + "=" + obj.GetAnnotations()[DEADLOCK_DETECTED_ANNOTATION], DEADLOCK_DETECTED_ANNOTATION + "=" + detector
+	case RemediationActionDeletePod:
+		pod := obj.GetName()
+		if tr, ok := obj.(*v1.TaskRun); ok {
+			pod = tr.Status.PodName
+		}
+		return "pod " + pod, "deleted"
+	case RemediationActionWebhook:
+		// the URL can carry credentials, in its user info or query, which are not for the audit log
+		u, err := url.Parse(settings.RemediationWebhookURL)
+		if err != nil {
+			return "", "notified the webhook"
+		}
+		return "", "notified " + u.Scheme + "://" + u.Host + u.Path
+	}
+	return "", ""
+}
+
+// finishScan drops the objects that were not flagged on this scan, so if they get stuck again later, they
+// are remediated again; past the size limit, the objects over it are dropped too, and so remediated again on the
+// next scan
+func (t *remediationTracker) finishScan() {
+	if t == nil {
+		return
+	}
+	for _, key := range evictKeys(StoreRemediations+t.detector, t.currentHit, exporterClock.Now()) {
+		delete(t.currentHit, key)
+	}
+	t.previousHit = t.currentHit
+	t.currentHit = map[string]time.Time{}
+}
@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8sscheme "k8s.io/client-go/kubernetes/scheme"
+	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestRemediationTrackers(t *testing.T) {
+	defer setSettings(Settings{})()
+	assert.Len(t, remediationTrackers(), 0)
+	// webhook without a url is ignored, as are unknown actions and malformed entries
+	settings.RemediationActions = "pipelinerun-kickoff=annotate, pod-create-attempt=webhook,foo=bar,garbage"
+	trackers := remediationTrackers()
+	assert.Len(t, trackers, 1)
+	assert.Equal(t, RemediationActionAnnotate, trackers[PipelineRunKickoffDetectorName].remediator.Action())
+	settings.RemediationWebhookURL = "http://localhost:8080"
+	trackers = remediationTrackers()
+	assert.Len(t, trackers, 2)
+	assert.Equal(t, RemediationActionWebhook, trackers[PodCreateAttemptDetectorName].remediator.Action())
+	// the built-in detectors flag objects without pods, so delete-pod is only for registered ones
+	settings.RemediationActions = "pod-create-attempt=delete-pod,pipelinerun-kickoff=delete-pod,custom=delete-pod"
+	trackers = remediationTrackers()
+	assert.Len(t, trackers, 1)
+	assert.Equal(t, RemediationActionDeletePod, trackers["custom"].remediator.Action())
+}
+
+func TestPipelineRunKickoffRemediation(t *testing.T) {
+	defer setSettings(Settings{})()
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	assert.NoError(t, c.Create(ctx, pr))
+
+	settings.RemediationActions = "pipelinerun-kickoff=annotate"
+	settings.RemediationKillSwitch = true
+	reconciler := buildReconciler(c, nil, nil)
+	reconciler.resetPipelineRunKickoffStats(ctx)
+	reconciler.resetPipelineRunKickoffStats(ctx)
+	updated := &v1.PipelineRun{}
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}, updated))
+	_, annotated := updated.Annotations[DEADLOCK_DETECTED_ANNOTATION]
+	assert.False(t, annotated)
+
+	// with the kill switch off, the next scan remediates
+	settings.RemediationKillSwitch = false
+	reconciler.resetPipelineRunKickoffStats(ctx)
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}, updated))
+	assert.Equal(t, PipelineRunKickoffDetectorName, updated.Annotations[DEADLOCK_DETECTED_ANNOTATION])
+	reconciler.Close()
+}
+
+func TestDeletePodRemediator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	_ = k8sscheme.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1-pod"}}
+	assert.NoError(t, c.Create(ctx, pod))
+	tr := &v1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"},
+		Status: v1.TaskRunStatus{
+			TaskRunStatusFields: v1.TaskRunStatusFields{PodName: "test-1-pod"},
+		},
+	}
+	remediator := &deletePodRemediator{}
+	assert.NoError(t, remediator.Remediate(ctx, c, "test", tr))
+	err := c.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &corev1.Pod{})
+	assert.Error(t, err)
+	assert.Error(t, remediator.Remediate(ctx, c, "test", &v1.PipelineRun{}))
+}
+
+func TestWebhookRemediator(t *testing.T) {
+	payloads := []webhookPayload{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := webhookPayload{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+	}))
+	defer srv.Close()
+	remediator := &webhookRemediator{url: srv.URL, client: srv.Client()}
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	assert.NoError(t, remediator.Remediate(context.TODO(), nil, PipelineRunKickoffDetectorName, pr))
+	assert.Len(t, payloads, 1)
+	assert.Equal(t, PipelineRunKickoffDetectorName, payloads[0].Detector)
+	assert.Equal(t, "test-namespace", payloads[0].Namespace)
+	assert.Equal(t, "test-1", payloads[0].Name)
+	assert.Equal(t, "PipelineRun", payloads[0].Kind)
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"knative.dev/pkg/apis"
+)
+
+// Replayer records the run level metrics, the scheduling durations, gaps, and overheads, for completed runs read
+// from an archive, like Tekton Results or the API server audit log, vs. watched, so SLOs can be computed after the
+// fact; it uses the package wide settings like the live collectors do
+type Replayer struct {
+	prScheduled *prometheus.HistogramVec
+	trScheduled *prometheus.HistogramVec
+	overhead    *OverheadCollector
+	gaps        *PipelineRunTaskRunGapCollector
+	registerer  *collectorRegisterer
+}
+
+func NewReplayer(registerer prometheus.Registerer) *Replayer {
+	reg := newCollectorRegisterer(registerer)
+	return &Replayer{
+		prScheduled: NewPipelineRunScheduledMetric(reg),
+		trScheduled: NewTaskRunScheduledMetric(reg),
+		overhead:    NewOverheadCollector(registerer),
+		gaps:        NewPipelineRunTaskRunGapCollector(registerer),
+		registerer:  reg,
+	}
+}
+
+// Close unregisters the metrics of the replayer
+func (r *Replayer) Close() {
+	r.registerer.Close()
+	r.overhead.Close()
+	r.gaps.Close()
+}
+
+// ObservePipelineRun records the metrics of a completed PipelineRun, with its TaskRuns for the gaps and execution
+// overhead; it is false if the PipelineRun was not completed, or never started
+func (r *Replayer) ObservePipelineRun(pr *v1.PipelineRun, taskRuns []*v1.TaskRun) bool {
+	succeedCondition := pr.Status.GetCondition(apis.ConditionSucceeded)
+	if succeedCondition == nil || succeedCondition.IsUnknown() || pr.Status.StartTime == nil || pr.Status.CompletionTime == nil {
+		return false
+	}
+	bumpPipelineRunScheduledDuration(calculateScheduledDurationPipelineRun(pr), pr, r.prScheduled)
+	// like the live event filters, the throttled PipelineRuns are left out of the gaps and overheads
+	if _, throttled := pr.Labels[THROTTLED_LABEL]; throttled {
+		return true
+	}
+
+	labels := withRunLabels(withTenantLabel(map[string]string{NS_LABEL: pr.Namespace, STATUS_LABEL: runStatus(succeedCondition)}, pr.Namespace), pr)
+	totalDuration := float64(pr.Status.CompletionTime.Time.Sub(pr.Status.StartTime.Time).Milliseconds())
+	gaps := CalculateGaps(pr, taskRuns)
+	if gaps.Calculated() {
+		r.gaps.observeGaps(pr, gaps.Entries)
+		if overhead, ok := gaps.Overhead(); ok {
+			r.overhead.execution.With(labels).Observe(overhead)
+		}
+	}
+	if _, overhead, ok := schedulingOverhead(pr, totalDuration); ok {
+		r.overhead.scheduling.With(labels).Observe(overhead)
+	}
+	return true
+}
+
+// ObserveTaskRun records the scheduling duration of a completed TaskRun; it is false if the TaskRun was not
+// completed, or never started
+func (r *Replayer) ObserveTaskRun(tr *v1.TaskRun) bool {
+	if !tr.IsDone() || tr.Status.StartTime == nil {
+		return false
+	}
+	bumpTaskRunScheduledDuration(calculateScheduledDurationTaskRun(tr), tr, r.trScheduled)
+	return true
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"testing"
+	"time"
+)
+
+func TestReplayer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	r := NewReplayer(registry)
+	defer r.Close()
+
+	base := time.Now()
+	at := func(tens int) *metav1.Time {
+		return &metav1.Time{Time: base.Add(time.Duration(tens) * 10 * time.Second)}
+	}
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1", CreationTimestamp: *at(0)},
+		Spec: v1.PipelineRunSpec{PipelineRef: &v1.PipelineRef{Name: "build-pipeline"}}}
+	tr := &v1.TaskRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1-clone", CreationTimestamp: *at(1),
+		Labels: map[string]string{"tekton.dev/pipelineTask": "clone"}}}
+	tr.Status.StartTime = at(1)
+
+	// still running
+	pr.Status.StartTime = at(1)
+	assert.False(t, r.ObservePipelineRun(pr, []*v1.TaskRun{tr}))
+	assert.False(t, r.ObserveTaskRun(tr))
+
+	pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+	pr.Status.CompletionTime = at(100)
+	tr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+	tr.Status.CompletionTime = at(90)
+	assert.True(t, r.ObservePipelineRun(pr, []*v1.TaskRun{tr}))
+	assert.True(t, r.ObserveTaskRun(tr))
+
+	labels := prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED}
+	validateHistogramVec(t, r.prScheduled, labels, false)
+	validateHistogramVec(t, r.trScheduled, labels, false)
+	validateHistogramVec(t, r.gaps.trGaps, prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED, PHASE_LABEL: GapPhaseKickoff}, false)
+	validateHistogramVec(t, r.overhead.execution, labels, false)
+	validateHistogramVec(t, r.overhead.scheduling, labels, false)
+
+	// throttled PipelineRuns only get their scheduling duration
+	throttled := pr.DeepCopy()
+	throttled.Namespace = "throttled-namespace"
+	throttled.Labels = map[string]string{THROTTLED_LABEL: "test-1-clone"}
+	assert.True(t, r.ObservePipelineRun(throttled, []*v1.TaskRun{tr}))
+	labels = prometheus.Labels{NS_LABEL: "throttled-namespace", STATUS_LABEL: SUCCEEDED}
+	validateHistogramVec(t, r.prScheduled, labels, false)
+	validateHistogramVecZeroCount(t, r.overhead.execution, labels)
+
+	r.Close()
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 0)
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	// ReasonResourceVerificationFailed is the reason of the succeeded condition of PipelineRuns and TaskRuns whose
+	// pipeline or task failed the trusted resources verification
+	ReasonResourceVerificationFailed = "ResourceVerificationFailed"
+
+	KIND_LABEL   = "kind"
+	POLICY_LABEL = "policy"
+
+	KindPipelineRun = "pipelinerun"
+	KindTaskRun     = "taskrun"
+	// PolicyUnknown is the policy label value when the condition message does not name the VerificationPolicy, which
+	// is the case for the messages of Tekton up to at least 0.45
+	PolicyUnknown = "unknown"
+)
+
+// verificationPolicyPattern picks the name of the VerificationPolicy out of the condition message, for the Tekton
+// versions that quote it, like `... fails verification against policy "<name>"`; only quoted names are taken, as the
+// messages also wrap errors like "failed to get verifiers from policy: ..."
+var verificationPolicyPattern = regexp.MustCompile(`[Pp]olicy "([a-z0-9]([-a-z0-9.]*[a-z0-9])?)"`)
+
+func NewResourceVerificationFailuresMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	failures := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_resource_verification_failures_total",
+		Help: "Number of PipelineRuns and TaskRuns that failed as their pipeline or task did not pass the trusted resources verification",
+	}, withTenantLabelName([]string{NS_LABEL, KIND_LABEL, POLICY_LABEL}))
+	registerer.MustRegister(failures)
+	return failures
+}
+
+func verificationPolicy(message string) string {
+	match := verificationPolicyPattern.FindStringSubmatch(message)
+	if match == nil {
+		return PolicyUnknown
+	}
+	return match[1]
+}
+
+// resourceVerificationFailed is the succeeded condition of a run that failed the trusted resources verification,
+// nil otherwise
+func resourceVerificationFailed(condition *apis.Condition) *apis.Condition {
+	if condition == nil || !condition.IsFalse() || condition.Reason != ReasonResourceVerificationFailed {
+		return nil
+	}
+	return condition
+}
+
+// resourceVerificationFilter counts the PipelineRuns and TaskRuns failing the trusted resources verification as they
+// become done, so turning on trusted resources across the fleet can be watched for breakage
+type resourceVerificationFilter struct {
+	metric *prometheus.CounterVec
+}
+
+func (f *resourceVerificationFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *resourceVerificationFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *resourceVerificationFilter) Update(e event.UpdateEvent) bool {
+	var condition *apis.Condition
+	kind := ""
+	switch newRun := e.ObjectNew.(type) {
+	case *v1.PipelineRun:
+		oldRun, ok := e.ObjectOld.(*v1.PipelineRun)
+		if ok && !oldRun.IsDone() && newRun.IsDone() {
+			condition, kind = resourceVerificationFailed(newRun.Status.GetCondition(apis.ConditionSucceeded)), KindPipelineRun
+		}
+	case *v1.TaskRun:
+		oldRun, ok := e.ObjectOld.(*v1.TaskRun)
+		if ok && !oldRun.IsDone() && newRun.IsDone() {
+			condition, kind = resourceVerificationFailed(newRun.Status.GetCondition(apis.ConditionSucceeded)), KindTaskRun
+		}
+	}
+	if condition == nil {
+		return false
+	}
+	ns := e.ObjectNew.GetNamespace()
+	f.metric.With(withTenantLabel(map[string]string{NS_LABEL: ns, KIND_LABEL: kind, POLICY_LABEL: verificationPolicy(condition.Message)}, ns)).Inc()
+	return false
+}
+
+func (f *resourceVerificationFilter) Generic(event.GenericEvent) bool {
+	return false
+}
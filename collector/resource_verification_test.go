@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestVerificationPolicy(t *testing.T) {
+	assert.Equal(t, PolicyUnknown, verificationPolicy("PipelineRun test-namespace/test-1 referred pipeline failed signature verification"))
+	assert.Equal(t, PolicyUnknown, verificationPolicy("GetVerifiedTaskFunc failed: resource verification failed: failed to get verifiers from policy: not found"))
+	assert.Equal(t, "konflux-tasks", verificationPolicy(`resource build in namespace test-namespace fails verification against policy "konflux-tasks"`))
+}
+
+func TestResourceVerificationFilter(t *testing.T) {
+	metric := NewResourceVerificationFailuresMetric(prometheus.NewRegistry())
+	filter := &resourceVerificationFilter{metric: metric}
+	running := duckv1.Status{Conditions: duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionUnknown}}}
+	failed := duckv1.Status{Conditions: duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse,
+		Reason: ReasonResourceVerificationFailed, Message: `fails verification against policy "konflux-tasks"`}}}
+	otherFailure := duckv1.Status{Conditions: duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, Reason: "Failed"}}}
+	meta := metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}
+	pr := func(status duckv1.Status) *v1.PipelineRun {
+		return &v1.PipelineRun{ObjectMeta: meta, Status: v1.PipelineRunStatus{Status: status}}
+	}
+	tr := func(status duckv1.Status) *v1.TaskRun {
+		return &v1.TaskRun{ObjectMeta: meta, Status: v1.TaskRunStatus{Status: status}}
+	}
+
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pr(running), ObjectNew: pr(failed)}))
+	// only the transition to done is counted
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pr(failed), ObjectNew: pr(failed)}))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pr(running), ObjectNew: pr(otherFailure)}))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: tr(running), ObjectNew: tr(failed)}))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: tr(running), ObjectNew: tr(failed)}))
+
+	validateCounterVec(t, metric, prometheus.Labels{NS_LABEL: "test-namespace", KIND_LABEL: KindPipelineRun, POLICY_LABEL: "konflux-tasks"}, float64(1))
+	validateCounterVec(t, metric, prometheus.Labels{NS_LABEL: "test-namespace", KIND_LABEL: KindTaskRun, POLICY_LABEL: "konflux-tasks"}, float64(2))
+}
@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RHTAPRunLabelsEnvName is a comma separated list of the RHTAP run labels, build_type, component, and application,
+	// to add to the run level metrics, so regressions can be attributed to a family of pipelines
+	RHTAPRunLabelsEnvName = "RHTAP_RUN_LABELS"
+
+	BUILD_TYPE_LABEL  = "build_type"
+	COMPONENT_LABEL   = "component"
+	APPLICATION_LABEL = "application"
+
+	RHTAPComponentKey   = "appstudio.openshift.io/component"
+	RHTAPApplicationKey = "appstudio.openshift.io/application"
+	// the build pipelines of RHTAP carry the labels of the pipeline definitions they were created from
+	PipelineStrategyKey = "pipelines.openshift.io/strategy"
+	PipelineRuntimeKey  = "pipelines.openshift.io/runtime"
+
+	BuildTypeDocker = "docker-build"
+	BuildTypeFBC    = "fbc"
+	BuildTypeJava   = "java"
+	BuildTypeOther  = "other"
+
+	// RHTAPMaxLabelValues is how many distinct components, and applications, get their own label value, at most; the
+	// others share RHTAPOverflowValue, so the series stay bounded on busy clusters
+	RHTAPMaxLabelValues = 100
+	// RHTAPOverflowValue cannot be a Kubernetes label value, which starts with an alphanumeric, so it never collides
+	// with a component or application of that name
+	RHTAPOverflowValue = "_other"
+)
+
+// rhtapBuildPipelines are the build types of the RHTAP build pipelines, by the exact name of the pipeline, for the runs
+// without the strategy and runtime labels
+var rhtapBuildPipelines = map[string]string{
+	"docker-build":                       BuildTypeDocker,
+	"docker-build-oci-ta":                BuildTypeDocker,
+	"docker-build-multi-platform-oci-ta": BuildTypeDocker,
+	"fbc-builder":                        BuildTypeFBC,
+	"java-builder":                       BuildTypeJava,
+}
+
+// RHTAPRunLabelNames are the label names RHTAPRunLabelsEnvName can list
+var RHTAPRunLabelNames = []string{BUILD_TYPE_LABEL, COMPONENT_LABEL, APPLICATION_LABEL}
+
+// buildType is the family of the pipeline the run is part of, from its strategy and runtime labels, or else the exact
+// name of its pipeline among rhtapBuildPipelines; runs without any of those are not builds, and get the empty value
+func buildType(run client.Object) string {
+	labels := run.GetLabels()
+	strategy, runtime, name := labels[PipelineStrategyKey], labels[PipelineRuntimeKey], labels[pipeline.PipelineLabelKey]
+	switch {
+	case strategy == "fbc":
+		return BuildTypeFBC
+	case runtime == "java":
+		return BuildTypeJava
+	case strategy == "docker":
+		return BuildTypeDocker
+	case len(strategy) == 0 && len(runtime) == 0 && len(name) == 0:
+		return ""
+	}
+	if known, ok := rhtapBuildPipelines[name]; ok && len(strategy) == 0 && len(runtime) == 0 {
+		return known
+	}
+	return BuildTypeOther
+}
+
+// labelValueGuard bounds each label to max values: a value hashes to one of max slots, and keeps its own value if it
+// is the lowest of those seen in its slot, or else gets RHTAPOverflowValue; so which values keep theirs only depends
+// on the values seen, vs. the order they were seen in, and restarts and replicas that saw the same runs agree; a value
+// loses its own to a lower one showing up in its slot later
+type labelValueGuard struct {
+	lock sync.Mutex
+	max  int
+	// owners are the values keeping their own, by label name and slot
+	owners map[string]map[uint32]string
+}
+
+func (g *labelValueGuard) admit(name, value string) string {
+	if len(value) == 0 {
+		return value
+	}
+	h := fnv.New32a()
+	h.Write([]byte(value))
+	slot := h.Sum32() % uint32(g.max)
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	owners, ok := g.owners[name]
+	if !ok {
+		owners = map[uint32]string{}
+		g.owners[name] = owners
+	}
+	if owner, taken := owners[slot]; taken && owner <= value {
+		if owner == value {
+			return value
+		}
+		return RHTAPOverflowValue
+	}
+	owners[slot] = value
+	return value
+}
+
+// RHTAPLabelProvider derives the RHTAP run labels from the well known labels of the PipelineRuns and TaskRuns, with
+// the components and applications going through the labelValueGuard
+type RHTAPLabelProvider struct {
+	guard *labelValueGuard
+}
+
+func NewRHTAPLabelProvider() *RHTAPLabelProvider {
+	return &RHTAPLabelProvider{guard: &labelValueGuard{max: RHTAPMaxLabelValues, owners: map[string]map[uint32]string{}}}
+}
+
+func (p *RHTAPLabelProvider) Labels(run client.Object) map[string]string {
+	return map[string]string{
+		BUILD_TYPE_LABEL:  buildType(run),
+		COMPONENT_LABEL:   p.guard.admit(COMPONENT_LABEL, run.GetLabels()[RHTAPComponentKey]),
+		APPLICATION_LABEL: p.guard.admit(APPLICATION_LABEL, run.GetLabels()[RHTAPApplicationKey]),
+	}
+}
+
+// combinedLabelProvider merges the labels of the providers, where the later providers win
+type combinedLabelProvider []LabelProvider
+
+func (c combinedLabelProvider) Labels(run client.Object) map[string]string {
+	labels := map[string]string{}
+	for _, provider := range c {
+		for name, value := range provider.Labels(run) {
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+func isRHTAPRunLabel(name string) bool {
+	for _, known := range RHTAPRunLabelNames {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// withRHTAPRunLabels adds the RHTAP run labels of the settings to the label provider and allowed names of the options,
+// leaving out the names that are not RHTAP run labels
+func withRHTAPRunLabels(provider LabelProvider, allowed []string, names []string) (LabelProvider, []string) {
+	rhtapNames := []string{}
+	for _, name := range names {
+		if isRHTAPRunLabel(name) {
+			rhtapNames = append(rhtapNames, name)
+		}
+	}
+	if len(rhtapNames) == 0 {
+		return provider, allowed
+	}
+	// a label of the same name from the options' provider is the one kept
+	var rhtap LabelProvider = NewRHTAPLabelProvider()
+	if provider != nil {
+		rhtap = combinedLabelProvider{rhtap, provider}
+	}
+	return rhtap, append(append([]string{}, allowed...), rhtapNames...)
+}
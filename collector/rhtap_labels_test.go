@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func rhtapRun(labels map[string]string) *v1.PipelineRun {
+	return &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1", Labels: labels}}
+}
+
+func TestBuildType(t *testing.T) {
+	assert.Equal(t, BuildTypeFBC, buildType(rhtapRun(map[string]string{PipelineStrategyKey: "fbc"})))
+	assert.Equal(t, BuildTypeFBC, buildType(rhtapRun(map[string]string{pipeline.PipelineLabelKey: "fbc-builder"})))
+	assert.Equal(t, BuildTypeJava, buildType(rhtapRun(map[string]string{PipelineRuntimeKey: "java", PipelineStrategyKey: "s2i"})))
+	assert.Equal(t, BuildTypeDocker, buildType(rhtapRun(map[string]string{pipeline.PipelineLabelKey: "docker-build"})))
+	assert.Equal(t, BuildTypeOther, buildType(rhtapRun(map[string]string{pipeline.PipelineLabelKey: "nodejs-builder"})))
+	// the pipeline name has to match in full
+	assert.Equal(t, BuildTypeOther, buildType(rhtapRun(map[string]string{pipeline.PipelineLabelKey: "not-a-docker-build"})))
+	assert.Equal(t, BuildTypeOther, buildType(rhtapRun(map[string]string{pipeline.PipelineLabelKey: "javascript-builder"})))
+	assert.Equal(t, "", buildType(rhtapRun(nil)))
+}
+
+func TestRHTAPLabelProvider(t *testing.T) {
+	p := NewRHTAPLabelProvider()
+	for i := 0; i < 3*RHTAPMaxLabelValues; i++ {
+		p.Labels(rhtapRun(map[string]string{RHTAPComponentKey: fmt.Sprintf("component-%d", i)}))
+	}
+	// once all the values have been seen, at most the limit keep their own
+	admitted := map[string]struct{}{}
+	for i := 0; i < 3*RHTAPMaxLabelValues; i++ {
+		admitted[p.Labels(rhtapRun(map[string]string{RHTAPComponentKey: fmt.Sprintf("component-%d", i)}))[COMPONENT_LABEL]] = struct{}{}
+	}
+	assert.LessOrEqual(t, len(admitted), RHTAPMaxLabelValues+1)
+	assert.Contains(t, admitted, RHTAPOverflowValue)
+	labels := p.Labels(rhtapRun(map[string]string{pipeline.PipelineLabelKey: "docker-build", RHTAPApplicationKey: "app"}))
+	assert.Equal(t, map[string]string{BUILD_TYPE_LABEL: BuildTypeDocker, COMPONENT_LABEL: "", APPLICATION_LABEL: "app"}, labels)
+}
+
+func TestLabelValueGuard_OrderIndependent(t *testing.T) {
+	values := []string{}
+	for i := 0; i < 3*RHTAPMaxLabelValues; i++ {
+		values = append(values, fmt.Sprintf("component-%d", i))
+	}
+	forward := &labelValueGuard{max: RHTAPMaxLabelValues, owners: map[string]map[uint32]string{}}
+	backward := &labelValueGuard{max: RHTAPMaxLabelValues, owners: map[string]map[uint32]string{}}
+	for i := range values {
+		forward.admit(COMPONENT_LABEL, values[i])
+		backward.admit(COMPONENT_LABEL, values[len(values)-1-i])
+	}
+	for _, value := range values {
+		assert.Equal(t, forward.admit(COMPONENT_LABEL, value), backward.admit(COMPONENT_LABEL, value))
+	}
+}
+
+func TestWithRHTAPRunLabels(t *testing.T) {
+	provider, allowed := withRHTAPRunLabels(nil, nil, []string{"unknown"})
+	assert.Nil(t, provider)
+	assert.Empty(t, allowed)
+
+	provider, allowed = withRHTAPRunLabels(ObjectLabelProvider{"component": "example.dev/component", "team": "example.dev/team"},
+		[]string{"component", "team"}, []string{BUILD_TYPE_LABEL, COMPONENT_LABEL})
+	assert.Equal(t, []string{"component", "team", BUILD_TYPE_LABEL, COMPONENT_LABEL}, allowed)
+	labels := provider.Labels(rhtapRun(map[string]string{"example.dev/component": "mine", RHTAPComponentKey: "rhtap", PipelineStrategyKey: "fbc"}))
+	assert.Equal(t, "mine", labels[COMPONENT_LABEL])
+	assert.Equal(t, BuildTypeFBC, labels[BUILD_TYPE_LABEL])
+
+	defer runLabels.configure(nil, nil)
+	runLabels.configure(provider, allowed)
+	assert.Equal(t, []string{NS_LABEL, "component", "team", BUILD_TYPE_LABEL}, withRunLabelNames([]string{NS_LABEL}))
+}
@@ -0,0 +1,519 @@
+package collector
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// maxSaneFilterThreshold is an hour in milliseconds; above it, the overhead of most runs would not be recorded
+const maxSaneFilterThreshold = float64(60 * 60 * 1000)
+
+// SettingsProblem is an invalid or suspicious setting; warnings are applied as described, while errors leave the
+// setting, or the offending entries of a list setting, at their defaults
+type SettingsProblem struct {
+	EnvName string
+	Message string
+	Warning bool
+}
+
+func (p SettingsProblem) Error() string {
+	return fmt.Sprintf("%s: %s", p.EnvName, p.Message)
+}
+
+// SettingsEnvNames are the environment variables the exporter binary reads its settings from, sorted
+func SettingsEnvNames() []string {
+	names := []string{
+		ClusterNameEnvName,
+		TenantLabelEnvName,
+		TenantNamespaceLabelEnvName,
+		TenantMetricsEndpointEnvName,
+		FederationEndpointEnvName,
+		ActiveNamespaceWindowEnvName,
+		RemediationActionsEnvName,
+		RemediationWebhookEnvName,
+		RemediationKillSwitchEnvName,
+		DetectorSeveritiesEnvName,
+		PodCreateFilterEnvName,
+		PipelineRunKickoffFilterEnvName,
+		TriggerTimeAnnotationsEnvName,
+		QueueAnnotationsEnvName,
+		ResolvingPipelineRefReasonsEnvName,
+		ResolvingTaskRefReasonsEnvName,
+		ThrottleLabelServerSideApplyEnvName,
+		ReasonStatusEnvName,
+		ConfigProfileEnvName,
+		LogRateLimitsEnvName,
+		LogSamplingEnvName,
+		RecentRunsEnvName,
+		ObservationSampleRateEnvName,
+		ObservationSmoothingWindowEnvName,
+		CollectorFailureThresholdEnvName,
+		APIWriteBudgetEnvName,
+		MarkerTTLEnvName,
+		StoreTTLEnvName,
+		StoreMaxEntriesEnvName,
+		CustomRunStuckAfterEnvName,
+		TektonNamespaceEnvName,
+		TektonControlPlanesEnvName,
+		NodePoolLabelEnvName,
+		PullSecretAgingEnvName,
+		PipelineServiceAccountsEnvName,
+		PullSecretExpiryWarningEnvName,
+		RHTAPRunLabelsEnvName,
+		FILTER_THRESHOLD,
+		RunLabelsEnvName,
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseSettings builds the Settings from the environment variables, as returned by getenv, like os.Getenv; the
+// problems are only those keeping a value from being parsed, with Validate checking the parsed values
+func ParseSettings(getenv func(string) string) (Settings, []SettingsProblem) {
+	problems := []SettingsProblem{}
+	enabled := func(name string) bool {
+		env := getenv(name)
+		if len(env) == 0 {
+			return false
+		}
+		// any setting other than one parsing as false is treated as true
+		value, err := strconv.ParseBool(env)
+		if err != nil {
+			problems = append(problems, SettingsProblem{EnvName: name, Warning: true,
+				Message: fmt.Sprintf("%q is not a boolean, so it is treated as true", env)})
+			return true
+		}
+		return value
+	}
+	list := func(name string) []string {
+		return splitEntries(getenv(name))
+	}
+	s := Settings{
+		ClusterName:                       getenv(ClusterNameEnvName),
+		TenantLabel:                       enabled(TenantLabelEnvName),
+		TenantNamespaceLabel:              getenv(TenantNamespaceLabelEnvName),
+		TenantMetricsEndpoint:             enabled(TenantMetricsEndpointEnvName),
+		FederationEndpoint:                enabled(FederationEndpointEnvName),
+		RemediationActions:                getenv(RemediationActionsEnvName),
+		RemediationWebhookURL:             getenv(RemediationWebhookEnvName),
+		RemediationKillSwitch:             enabled(RemediationKillSwitchEnvName),
+		DetectorSeverities:                getenv(DetectorSeveritiesEnvName),
+		PodCreateNamespaceFilter:          list(PodCreateFilterEnvName),
+		PipelineRunKickoffNamespaceFilter: list(PipelineRunKickoffFilterEnvName),
+		TriggerTimeAnnotations:            list(TriggerTimeAnnotationsEnvName),
+		QueueAnnotations:                  list(QueueAnnotationsEnvName),
+		ResolvingPipelineRefReasons:       list(ResolvingPipelineRefReasonsEnvName),
+		ResolvingTaskRefReasons:           list(ResolvingTaskRefReasonsEnvName),
+		ThrottleLabelServerSideApply:      enabled(ThrottleLabelServerSideApplyEnvName),
+		ReasonStatusLabels:                enabled(ReasonStatusEnvName),
+		TektonNamespace:                   getenv(TektonNamespaceEnvName),
+		TektonControlPlanes:               list(TektonControlPlanesEnvName),
+		NodePoolLabel:                     getenv(NodePoolLabelEnvName),
+		PullSecretAging:                   enabled(PullSecretAgingEnvName),
+		PipelineServiceAccounts:           list(PipelineServiceAccountsEnvName),
+		RHTAPRunLabels:                    list(RHTAPRunLabelsEnvName),
+		ConfigProfile:                     getenv(ConfigProfileEnvName),
+		LogRateLimits:                     getenv(LogRateLimitsEnvName),
+		LogSampling:                       getenv(LogSamplingEnvName),
+	}
+	if env := getenv(ActiveNamespaceWindowEnvName); len(env) > 0 {
+		window, err := time.ParseDuration(env)
+		if err != nil || window <= 0 {
+			problems = append(problems, SettingsProblem{EnvName: ActiveNamespaceWindowEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be a positive duration like 24h", env)})
+		} else {
+			s.ActiveNamespaceWindow = window
+		}
+	}
+	if env := getenv(RecentRunsEnvName); len(env) > 0 {
+		size, err := strconv.Atoi(env)
+		if err != nil || size <= 0 {
+			problems = append(problems, SettingsProblem{EnvName: RecentRunsEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be a positive integer", env)})
+		} else {
+			s.RecentRuns = size
+		}
+	}
+	if env := getenv(ObservationSampleRateEnvName); len(env) > 0 {
+		rate, err := strconv.ParseFloat(env, 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			problems = append(problems, SettingsProblem{EnvName: ObservationSampleRateEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be above 0 and up to 1", env)})
+		} else {
+			s.ObservationSampleRate = rate
+		}
+	}
+	if env := getenv(ObservationSmoothingWindowEnvName); len(env) > 0 {
+		window, err := time.ParseDuration(env)
+		if err != nil || window < 0 {
+			problems = append(problems, SettingsProblem{EnvName: ObservationSmoothingWindowEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be a duration like 5m, or 0 to turn it off", env)})
+		} else {
+			s.ObservationSmoothingWindow = window
+		}
+	}
+	if env := getenv(CollectorFailureThresholdEnvName); len(env) > 0 {
+		threshold, err := strconv.Atoi(env)
+		if err != nil || threshold < 0 {
+			problems = append(problems, SettingsProblem{EnvName: CollectorFailureThresholdEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be a non-negative integer, 0 to never disable the collectors", env)})
+		} else {
+			s.CollectorFailureThreshold = threshold
+		}
+	}
+	if env := getenv(APIWriteBudgetEnvName); len(env) > 0 {
+		budget, err := strconv.Atoi(env)
+		if err != nil || budget < 0 {
+			problems = append(problems, SettingsProblem{EnvName: APIWriteBudgetEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be a non-negative integer, 0 to not limit the writes", env)})
+		} else {
+			s.APIWriteBudget = budget
+		}
+	}
+	if env := getenv(MarkerTTLEnvName); len(env) > 0 {
+		ttl, err := time.ParseDuration(env)
+		if err != nil || ttl < 0 {
+			problems = append(problems, SettingsProblem{EnvName: MarkerTTLEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be a duration like 168h, or 0 to never remove the markers", env)})
+		} else {
+			s.MarkerTTL = ttl
+		}
+	}
+	if env := getenv(StoreTTLEnvName); len(env) > 0 {
+		ttl, err := time.ParseDuration(env)
+		if err != nil || ttl <= 0 {
+			problems = append(problems, SettingsProblem{EnvName: StoreTTLEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be a positive duration like 24h", env)})
+		} else {
+			s.StoreTTL = ttl
+		}
+	}
+	if env := getenv(StoreMaxEntriesEnvName); len(env) > 0 {
+		size, err := strconv.Atoi(env)
+		if err != nil || size <= 0 {
+			problems = append(problems, SettingsProblem{EnvName: StoreMaxEntriesEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be a positive integer", env)})
+		} else {
+			s.StoreMaxEntries = size
+		}
+	}
+	if env := getenv(CustomRunStuckAfterEnvName); len(env) > 0 {
+		after, err := time.ParseDuration(env)
+		if err != nil || after <= 0 {
+			problems = append(problems, SettingsProblem{EnvName: CustomRunStuckAfterEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be a positive duration like 1h", env)})
+		} else {
+			s.CustomRunStuckAfter = after
+		}
+	}
+	if env := getenv(PullSecretExpiryWarningEnvName); len(env) > 0 {
+		warning, err := time.ParseDuration(env)
+		if err != nil || warning <= 0 {
+			problems = append(problems, SettingsProblem{EnvName: PullSecretExpiryWarningEnvName,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be a positive duration like 72h", env)})
+		} else {
+			s.PullSecretExpiryWarning = warning
+		}
+	}
+	if env := getenv(FILTER_THRESHOLD); len(env) > 0 {
+		threshold, err := strconv.ParseFloat(env, 64)
+		if err != nil {
+			problems = append(problems, SettingsProblem{EnvName: FILTER_THRESHOLD,
+				Message: fmt.Sprintf("ignoring invalid setting %q: %s", env, err.Error())})
+		} else if threshold < 0 {
+			problems = append(problems, SettingsProblem{EnvName: FILTER_THRESHOLD,
+				Message: fmt.Sprintf("ignoring invalid setting %q, it must be non-negative, 0 to filter nothing", env)})
+		} else {
+			s.FilterThreshold = &threshold
+		}
+	}
+	return s, problems
+}
+
+// ApplySettings configures the collectors' calculations with the settings, for callers running them directly, like
+// the exporter's subcommands, vs. through NewCollector, which applies those of its options
+func ApplySettings(s Settings) {
+	settings = s
+}
+
+// Validate checks the parsed settings the way the collectors will use them, without needing a cluster
+func (s Settings) Validate() []SettingsProblem {
+	problems := []SettingsProblem{}
+	add := func(name string, warning bool, format string, args ...interface{}) {
+		problems = append(problems, SettingsProblem{EnvName: name, Warning: warning, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if threshold := s.EffectiveFilterThreshold(); threshold < 0 {
+		add(FILTER_THRESHOLD, false, "%v is negative, which filters nothing like 0 does; use 0 to turn the filter off", threshold)
+	} else if threshold > maxSaneFilterThreshold {
+		add(FILTER_THRESHOLD, true, "%vms is over an hour, so the overhead of most runs will not be recorded", threshold)
+	}
+	if len(s.TenantNamespaceLabel) > 0 {
+		for _, msg := range validation.IsQualifiedName(s.TenantNamespaceLabel) {
+			add(TenantNamespaceLabelEnvName, false, "%q is not a valid label key: %s", s.TenantNamespaceLabel, msg)
+		}
+		if !s.TenantLabel {
+			add(TenantNamespaceLabelEnvName, true, "has no effect unless %s is true", TenantLabelEnvName)
+		}
+	}
+	if len(s.TektonNamespace) > 0 {
+		for _, msg := range validation.IsDNS1123Label(s.TektonNamespace) {
+			add(TektonNamespaceEnvName, false, "%q can not be a namespace: %s", s.TektonNamespace, msg)
+		}
+		if len(s.TektonControlPlanes) > 0 {
+			add(TektonNamespaceEnvName, true, "has no effect when %s is set", TektonControlPlanesEnvName)
+		}
+	}
+	planeNames := map[string]struct{}{}
+	for _, entry := range s.TektonControlPlanes {
+		plane, err := ParseTektonControlPlane(entry)
+		if err != nil {
+			add(TektonControlPlanesEnvName, true, "ignoring the entry: %s", err.Error())
+			continue
+		}
+		if _, dup := planeNames[plane.Name]; dup {
+			add(TektonControlPlanesEnvName, true, "ignoring the entry %q, as the control plane %s is already configured", entry, plane.Name)
+		}
+		planeNames[plane.Name] = struct{}{}
+	}
+	if len(s.NodePoolLabel) > 0 {
+		for _, msg := range validation.IsQualifiedName(s.NodePoolLabel) {
+			add(NodePoolLabelEnvName, false, "%q is not a valid label key: %s", s.NodePoolLabel, msg)
+		}
+	}
+	for _, name := range s.RHTAPRunLabels {
+		if !isRHTAPRunLabel(name) {
+			add(RHTAPRunLabelsEnvName, true, "ignoring %q, as it is not one of %s", name, strings.Join(RHTAPRunLabelNames, ", "))
+		}
+	}
+	if !s.PullSecretAging {
+		if len(s.PipelineServiceAccounts) > 0 {
+			add(PipelineServiceAccountsEnvName, true, "has no effect unless %s is true", PullSecretAgingEnvName)
+		}
+		if s.PullSecretExpiryWarning > 0 {
+			add(PullSecretExpiryWarningEnvName, true, "has no effect unless %s is true", PullSecretAgingEnvName)
+		}
+	}
+	for _, filter := range []struct {
+		name       string
+		namespaces []string
+	}{
+		{name: PodCreateFilterEnvName, namespaces: s.PodCreateNamespaceFilter},
+		{name: PipelineRunKickoffFilterEnvName, namespaces: s.PipelineRunKickoffNamespaceFilter},
+	} {
+		for _, ns := range filter.namespaces {
+			for _, msg := range validation.IsDNS1123Label(ns) {
+				add(filter.name, true, "%q can not be a namespace, so it never matches: %s", ns, msg)
+			}
+		}
+	}
+	for _, annotation := range s.TriggerTimeAnnotations {
+		for _, msg := range validation.IsQualifiedName(annotation) {
+			add(TriggerTimeAnnotationsEnvName, true, "%q can not be an annotation, so it never matches: %s", annotation, msg)
+		}
+	}
+	for _, entry := range s.QueueAnnotations {
+		annotation, _, _ := strings.Cut(entry, "=")
+		for _, msg := range validation.IsQualifiedName(annotation) {
+			add(QueueAnnotationsEnvName, true, "%q can not be an annotation, so it never matches: %s", annotation, msg)
+		}
+	}
+
+	webhook := false
+	for _, pair := range splitEntries(s.RemediationActions) {
+		detector, action, found := strings.Cut(pair, "=")
+		detector, action = strings.TrimSpace(detector), strings.TrimSpace(action)
+		if !found || len(detector) == 0 {
+			add(RemediationActionsEnvName, false, "ignoring malformed entry %q, it must be detector=action", pair)
+			continue
+		}
+		switch action {
+		case RemediationActionAnnotate, RemediationActionDeletePod:
+		case RemediationActionWebhook:
+			webhook = true
+		default:
+			add(RemediationActionsEnvName, false, "ignoring unknown action %q for detector %s", action, detector)
+			continue
+		}
+		if !actionApplies(detector, action) {
+			add(RemediationActionsEnvName, false, "ignoring action %s for detector %s, whose flagged objects have no pod to delete", action, detector)
+			continue
+		}
+		if !knownDetector(detector) {
+			add(RemediationActionsEnvName, true, "%s is not a built-in detector, so it needs to be registered with RegisterDetector", detector)
+		}
+	}
+	if webhook && len(s.RemediationWebhookURL) == 0 {
+		add(RemediationWebhookEnvName, false, "is required by the %s remediation action", RemediationActionWebhook)
+	}
+	if len(s.RemediationWebhookURL) > 0 {
+		if u, err := url.Parse(s.RemediationWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			add(RemediationWebhookEnvName, false, "%q is not an http or https URL", s.RemediationWebhookURL)
+		}
+	}
+
+	for _, pair := range splitEntries(s.DetectorSeverities) {
+		detector, severity, found := strings.Cut(pair, "=")
+		detector, severity = strings.TrimSpace(detector), strings.ToLower(strings.TrimSpace(severity))
+		if !found || len(detector) == 0 {
+			add(DetectorSeveritiesEnvName, false, "ignoring malformed entry %q, it must be detector=severity", pair)
+			continue
+		}
+		valid := false
+		for _, known := range severities {
+			valid = valid || known == severity
+		}
+		if !valid {
+			add(DetectorSeveritiesEnvName, false, "ignoring unknown severity %q for detector %s, it must be one of %s", severity, detector, strings.Join(severities, ", "))
+		}
+	}
+	_, logProblems := parseLogLimits(s.LogRateLimits, s.LogSampling)
+	problems = append(problems, logProblems...)
+	return problems
+}
+
+// ValidateRunLabels checks the RunLabelsEnvName setting, which is not part of Settings, as it configures the label
+// provider
+func ValidateRunLabels(setting string) []SettingsProblem {
+	problems := []SettingsProblem{}
+	seen := map[string]struct{}{}
+	for _, entry := range splitEntries(setting) {
+		name, key, found := strings.Cut(entry, "=")
+		name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+		if !found || len(name) == 0 || len(key) == 0 {
+			problems = append(problems, SettingsProblem{EnvName: RunLabelsEnvName, Message: fmt.Sprintf("ignoring malformed entry %q, it must be label=key", entry)})
+			continue
+		}
+		_, reserved := reservedLabelNames[name]
+		_, dup := seen[name]
+		seen[name] = struct{}{}
+		switch {
+		case !model.LabelName(name).IsValid():
+			problems = append(problems, SettingsProblem{EnvName: RunLabelsEnvName, Message: fmt.Sprintf("ignoring %q, as it is not a valid metric label name", name)})
+		case reserved || dup:
+			problems = append(problems, SettingsProblem{EnvName: RunLabelsEnvName, Message: fmt.Sprintf("ignoring %q, as it is already used", name)})
+		}
+		for _, msg := range validation.IsQualifiedName(key) {
+			problems = append(problems, SettingsProblem{EnvName: RunLabelsEnvName, Warning: true,
+				Message: fmt.Sprintf("%q is not a valid label or annotation key, so %s is always empty: %s", key, name, msg)})
+		}
+	}
+	return problems
+}
+
+// EffectiveEnv is what each setting ends up as, by environment variable, with the defaults filled in and the lists
+// normalized, as strings like they would be set
+func (s Settings) EffectiveEnv() map[string]string {
+	threshold := s.EffectiveFilterThreshold()
+	tenantNamespaceLabel := s.TenantNamespaceLabel
+	if len(tenantNamespaceLabel) == 0 {
+		tenantNamespaceLabel = DEFAULT_TENANT_NS_LABEL
+	}
+	profile := s.ConfigProfile
+	if len(profile) == 0 {
+		profile = DefaultConfigProfile
+	}
+	window := s.ActiveNamespaceWindow
+	if window <= 0 {
+		window = defaultActiveNamespaceWindow
+	}
+	recentRuns := s.RecentRuns
+	if recentRuns <= 0 {
+		recentRuns = DefaultRecentRuns
+	}
+	sampleRate := s.ObservationSampleRate
+	if sampleRate <= 0 {
+		sampleRate = 1
+	}
+	ttl := s.StoreTTL
+	if ttl <= 0 {
+		ttl = DefaultStoreTTL
+	}
+	maxEntries := s.StoreMaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultStoreMaxEntries
+	}
+	stuckAfter := s.CustomRunStuckAfter
+	if stuckAfter <= 0 {
+		stuckAfter = DefaultCustomRunStuckAfter
+	}
+	tektonNamespace := s.TektonNamespace
+	if len(tektonNamespace) == 0 {
+		tektonNamespace = DefaultTektonNamespace
+	}
+	poolLabel := s.NodePoolLabel
+	if len(poolLabel) == 0 {
+		poolLabel = strings.Join(DefaultNodePoolLabels, ",")
+	}
+	queueAnnotations := s.QueueAnnotations
+	if len(queueAnnotations) == 0 {
+		queueAnnotations = DefaultQueueAnnotations
+	}
+	serviceAccounts := s.PipelineServiceAccounts
+	if len(serviceAccounts) == 0 {
+		serviceAccounts = DefaultPipelineServiceAccounts
+	}
+	expiryWarning := s.PullSecretExpiryWarning
+	if expiryWarning <= 0 {
+		expiryWarning = DefaultPullSecretExpiryWarning
+	}
+	return map[string]string{
+		ClusterNameEnvName:                  s.ClusterName,
+		TenantLabelEnvName:                  strconv.FormatBool(s.TenantLabel),
+		TenantNamespaceLabelEnvName:         tenantNamespaceLabel,
+		TenantMetricsEndpointEnvName:        strconv.FormatBool(s.TenantMetricsEndpoint),
+		FederationEndpointEnvName:           strconv.FormatBool(s.FederationEndpoint),
+		ActiveNamespaceWindowEnvName:        window.String(),
+		RemediationActionsEnvName:           strings.Join(splitEntries(s.RemediationActions), ","),
+		RemediationWebhookEnvName:           s.RemediationWebhookURL,
+		RemediationKillSwitchEnvName:        strconv.FormatBool(s.RemediationKillSwitch),
+		DetectorSeveritiesEnvName:           strings.Join(splitEntries(s.DetectorSeverities), ","),
+		PodCreateFilterEnvName:              strings.Join(s.PodCreateNamespaceFilter, ","),
+		PipelineRunKickoffFilterEnvName:     strings.Join(s.PipelineRunKickoffNamespaceFilter, ","),
+		TriggerTimeAnnotationsEnvName:       strings.Join(s.TriggerTimeAnnotations, ","),
+		QueueAnnotationsEnvName:             strings.Join(queueAnnotations, ","),
+		ResolvingPipelineRefReasonsEnvName:  strings.Join(s.ResolvingPipelineRefReasons, ","),
+		ResolvingTaskRefReasonsEnvName:      strings.Join(s.ResolvingTaskRefReasons, ","),
+		ThrottleLabelServerSideApplyEnvName: strconv.FormatBool(s.ThrottleLabelServerSideApply),
+		ReasonStatusEnvName:                 strconv.FormatBool(s.ReasonStatusLabels),
+		ConfigProfileEnvName:                profile,
+		LogRateLimitsEnvName:                strings.Join(splitEntries(s.LogRateLimits), ","),
+		LogSamplingEnvName:                  strings.Join(splitEntries(s.LogSampling), ","),
+		RecentRunsEnvName:                   strconv.Itoa(recentRuns),
+		ObservationSampleRateEnvName:        strconv.FormatFloat(sampleRate, 'f', -1, 64),
+		ObservationSmoothingWindowEnvName:   s.ObservationSmoothingWindow.String(),
+		CollectorFailureThresholdEnvName:    strconv.Itoa(s.CollectorFailureThreshold),
+		APIWriteBudgetEnvName:               strconv.Itoa(s.APIWriteBudget),
+		MarkerTTLEnvName:                    s.MarkerTTL.String(),
+		StoreTTLEnvName:                     ttl.String(),
+		StoreMaxEntriesEnvName:              strconv.Itoa(maxEntries),
+		CustomRunStuckAfterEnvName:          stuckAfter.String(),
+		TektonNamespaceEnvName:              tektonNamespace,
+		TektonControlPlanesEnvName:          strings.Join(s.TektonControlPlanes, ","),
+		NodePoolLabelEnvName:                poolLabel,
+		PullSecretAgingEnvName:              strconv.FormatBool(s.PullSecretAging),
+		PipelineServiceAccountsEnvName:      strings.Join(serviceAccounts, ","),
+		PullSecretExpiryWarningEnvName:      expiryWarning.String(),
+		RHTAPRunLabelsEnvName:               strings.Join(s.RHTAPRunLabels, ","),
+		FILTER_THRESHOLD:                    strconv.FormatFloat(threshold, 'f', -1, 64),
+	}
+}
+
+// splitEntries are the trimmed, non empty entries of a comma separated setting
+func splitEntries(setting string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(setting, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) > 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
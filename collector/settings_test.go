@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/pointer"
+)
+
+func envOf(env map[string]string) func(string) string {
+	return func(name string) string {
+		return env[name]
+	}
+}
+
+func problemsOf(problems []SettingsProblem) map[string][]SettingsProblem {
+	byName := map[string][]SettingsProblem{}
+	for _, p := range problems {
+		byName[p.EnvName] = append(byName[p.EnvName], p)
+	}
+	return byName
+}
+
+func TestParseSettings(t *testing.T) {
+	s, problems := ParseSettings(envOf(map[string]string{
+		TenantLabelEnvName:           "yes",
+		FederationEndpointEnvName:    "false",
+		PodCreateFilterEnvName:       " ns-1, ,ns-2 ",
+		ActiveNamespaceWindowEnvName: "-1h",
+		FILTER_THRESHOLD:             "60000",
+	}))
+	assert.True(t, s.TenantLabel)
+	assert.False(t, s.FederationEndpoint)
+	assert.Equal(t, []string{"ns-1", "ns-2"}, s.PodCreateNamespaceFilter)
+	assert.Equal(t, time.Duration(0), s.ActiveNamespaceWindow)
+	assert.Equal(t, float64(60000), s.EffectiveFilterThreshold())
+	byName := problemsOf(problems)
+	assert.Len(t, problems, 2)
+	assert.True(t, byName[TenantLabelEnvName][0].Warning)
+	assert.False(t, byName[ActiveNamespaceWindowEnvName][0].Warning)
+
+	_, problems = ParseSettings(envOf(map[string]string{FILTER_THRESHOLD: "five minutes"}))
+	assert.Len(t, problems, 1)
+	// the default only applies when not set, as 0 turns the filter off
+	s, _ = ParseSettings(envOf(map[string]string{}))
+	assert.Equal(t, DEFAULT_THRESHOLD, s.EffectiveFilterThreshold())
+	s, _ = ParseSettings(envOf(map[string]string{FILTER_THRESHOLD: "0"}))
+	assert.Equal(t, float64(0), s.EffectiveFilterThreshold())
+	// a negative threshold is ignored like any invalid setting, leaving the default
+	s, problems = ParseSettings(envOf(map[string]string{FILTER_THRESHOLD: "-5"}))
+	assert.Len(t, problems, 1)
+	assert.Equal(t, DEFAULT_THRESHOLD, s.EffectiveFilterThreshold())
+}
+
+func TestValidateSettings(t *testing.T) {
+	assert.Empty(t, Settings{}.Validate())
+	assert.Empty(t, Settings{
+		FilterThreshold:       pointer.Float64(600000),
+		TenantLabel:           true,
+		TenantNamespaceLabel:  "example.com/tenant",
+		RemediationActions:    "pod-create-attempt=annotate, pvc-quota=webhook",
+		RemediationWebhookURL: "https://example.com/hook",
+		DetectorSeverities:    "pvc-quota=Critical",
+	}.Validate())
+
+	byName := problemsOf(Settings{
+		FilterThreshold:          pointer.Float64(-1),
+		TenantNamespaceLabel:     "not a label",
+		PodCreateNamespaceFilter: []string{"Not_A_Namespace"},
+		TriggerTimeAnnotations:   []string{"not an annotation"},
+		RemediationActions:       "pod-create-attempt=restart,custom=annotate,pvc-quota=webhook,malformed",
+		DetectorSeverities:       "pvc-quota=urgent",
+	}.Validate())
+	assert.False(t, byName[FILTER_THRESHOLD][0].Warning)
+	// an invalid key, and no effect without the tenant label
+	assert.Len(t, byName[TenantNamespaceLabelEnvName], 2)
+	assert.True(t, byName[PodCreateFilterEnvName][0].Warning)
+	assert.True(t, byName[TriggerTimeAnnotationsEnvName][0].Warning)
+	// the unknown action, unknown detector warning, and malformed entry
+	assert.Len(t, byName[RemediationActionsEnvName], 3)
+	assert.Contains(t, byName[RemediationWebhookEnvName][0].Message, "is required")
+	assert.Len(t, byName[DetectorSeveritiesEnvName], 1)
+	// the TaskRuns flagged for their pod creation have no pod to delete
+	byName = problemsOf(Settings{RemediationActions: "pod-create-attempt=delete-pod"}.Validate())
+	assert.False(t, byName[RemediationActionsEnvName][0].Warning)
+
+	byName = problemsOf(Settings{FilterThreshold: pointer.Float64(2 * maxSaneFilterThreshold), RemediationWebhookURL: "example.com"}.Validate())
+	assert.True(t, byName[FILTER_THRESHOLD][0].Warning)
+	assert.Contains(t, byName[RemediationWebhookEnvName][0].Message, "not an http or https URL")
+}
+
+func TestValidateRunLabels(t *testing.T) {
+	assert.Empty(t, ValidateRunLabels("application=appstudio.openshift.io/application, component=appstudio.openshift.io/component"))
+	problems := ValidateRunLabels("namespace=foo,bad-name=foo,app=foo,app=bar,missing,key=not a key")
+	// the reserved, invalid, and duplicate names, the malformed entry, and the invalid key
+	assert.Len(t, problems, 5)
+}
+
+func TestEffectiveEnv(t *testing.T) {
+	env := Settings{}.EffectiveEnv()
+	assert.Equal(t, "300000", env[FILTER_THRESHOLD])
+	assert.Equal(t, "0", Settings{FilterThreshold: pointer.Float64(0)}.EffectiveEnv()[FILTER_THRESHOLD])
+	assert.Equal(t, DEFAULT_TENANT_NS_LABEL, env[TenantNamespaceLabelEnvName])
+	assert.Equal(t, "168h0m0s", env[ActiveNamespaceWindowEnvName])
+	assert.Equal(t, "false", env[TenantLabelEnvName])
+
+	env = Settings{RemediationActions: " pvc-quota=annotate, ,", PodCreateNamespaceFilter: []string{"a", "b"}}.EffectiveEnv()
+	assert.Equal(t, "pvc-quota=annotate", env[RemediationActionsEnvName])
+	assert.Equal(t, "a,b", env[PodCreateFilterEnvName])
+	for _, name := range SettingsEnvNames() {
+		if name == RunLabelsEnvName {
+			continue
+		}
+		_, ok := env[name]
+		assert.True(t, ok, name)
+	}
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// TaskRunReasonResolvingStepActionRef is the reason of the succeeded condition of a TaskRun while the StepActions
+// referenced by its steps are resolved; StepActions came with Tekton 0.54, so the API types this exporter is built
+// against do not have the constant, nor the step references, only the condition reason makes it through
+const TaskRunReasonResolvingStepActionRef = "ResolvingStepActionRef"
+
+func NewStepActionReferenceWaitTimeMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	labelNames := withControlPlaneLabelName([]string{NS_LABEL})
+	waitMetric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "taskrun_stepaction_resolution_wait_milliseconds",
+		Help:    "Duration in milliseconds for the resolution requests for the step action references needed by a taskrun to be recognized as complete by the taskrun reconciler in the tekton controller. ",
+		Buckets: prometheus.ExponentialBuckets(float64(100), float64(5), 6),
+	}, labelNames)
+	registerer.MustRegister(waitMetric)
+	return waitMetric
+}
+
+// stepActionRefWaitTimeFilter is the taskRefWaitTimeFilter for the StepAction resolution of a TaskRun, which follows
+// the resolution of its task; TaskRuns that never wait on a StepAction are not observed, as, unlike the task reference,
+// whether a step references a StepAction cannot be told from the TaskRun.
+//
+// There is deliberately no per StepAction usage count next to the wait: the name of a StepAction is only in the ref of
+// the step, which the TaskRun types of Tekton 0.45 do not have, so the informer drops it when decoding the TaskRun,
+// and getting each TaskRun again unstructured would be an API call per TaskRun from the event handler. The count can
+// come with the move to a Tekton API with StepActions, reading the ref of the steps of the TaskRun's status.taskSpec
+type stepActionRefWaitTimeFilter struct {
+	waitDuration *prometheus.HistogramVec
+}
+
+func (f *stepActionRefWaitTimeFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *stepActionRefWaitTimeFilter) Generic(event.GenericEvent) bool {
+	return false
+}
+
+func (f *stepActionRefWaitTimeFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *stepActionRefWaitTimeFilter) Update(e event.UpdateEvent) bool {
+	oldTR, okold := e.ObjectOld.(*v1.TaskRun)
+	newTR, oknew := e.ObjectNew.(*v1.TaskRun)
+	if !okold || !oknew {
+		return false
+	}
+	oldSucceedCondition := oldTR.Status.GetCondition(apis.ConditionSucceeded)
+	newSucceedCondition := newTR.Status.GetCondition(apis.ConditionSucceeded)
+	if oldSucceedCondition == nil || newSucceedCondition == nil {
+		return false
+	}
+	// a failed resolution ends the wait as well, so unlike the task reference wait this is observed on the transition to done
+	if oldSucceedCondition.Reason == TaskRunReasonResolvingStepActionRef && newSucceedCondition.Reason != TaskRunReasonResolvingStepActionRef {
+		labels := withControlPlaneLabel(map[string]string{NS_LABEL: newTR.Namespace}, newTR.Namespace)
+		originalTime := oldSucceedCondition.LastTransitionTime.Inner
+		f.waitDuration.With(labels).Observe(float64(newSucceedCondition.LastTransitionTime.Inner.Sub(originalTime.Time).Milliseconds()))
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestStepActionRefWaitTimeFilter_Update(t *testing.T) {
+	metric := NewStepActionReferenceWaitTimeMetric(prometheus.NewRegistry())
+	filter := &stepActionRefWaitTimeFilter{waitDuration: metric}
+	now := time.Now()
+	tr := func(status corev1.ConditionStatus, reason string, transition time.Time) *v1.TaskRun {
+		return &v1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"},
+			Status: v1.TaskRunStatus{Status: duckv1.Status{Conditions: duckv1.Conditions{{
+				Type:               apis.ConditionSucceeded,
+				Status:             status,
+				Reason:             reason,
+				LastTransitionTime: apis.VolatileTime{Inner: metav1.NewTime(transition)},
+			}}}},
+		}
+	}
+	resolvingTask := tr(corev1.ConditionUnknown, v1.TaskRunReasonResolvingTaskRef, now)
+	resolving := tr(corev1.ConditionUnknown, TaskRunReasonResolvingStepActionRef, now)
+	running := tr(corev1.ConditionUnknown, v1.TaskRunReasonRunning.String(), now.Add(2*time.Second))
+	failed := tr(corev1.ConditionFalse, v1.TaskRunReasonFailed.String(), now.Add(3*time.Second))
+
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: resolvingTask, ObjectNew: resolving}))
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: resolving, ObjectNew: resolving}))
+	validateHistogramVecZeroCount(t, metric, prometheus.Labels{NS_LABEL: "test-namespace"})
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: resolving, ObjectNew: running}))
+	// a failed resolution ends the wait too
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: resolving, ObjectNew: failed}))
+
+	observer, err := metric.GetMetricWith(prometheus.Labels{NS_LABEL: "test-namespace"})
+	assert.NoError(t, err)
+	m := &dto.Metric{}
+	assert.NoError(t, observer.(prometheus.Histogram).Write(m))
+	assert.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(5000), m.GetHistogram().GetSampleSum())
+}
@@ -0,0 +1,166 @@
+package collector
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// StoreTTLEnvName is how long an entry of the in-memory stores is kept after it was last updated, DefaultStoreTTL
+	// when not set; the stores normally forget their entries when the PipelineRun completes or is deleted, so this only
+	// catches the entries whose events were missed
+	StoreTTLEnvName = "STORE_TTL"
+	// StoreMaxEntriesEnvName is how many entries each in-memory store keeps, DefaultStoreMaxEntries when not set, with
+	// the least recently updated evicted first
+	StoreMaxEntriesEnvName = "STORE_MAX_ENTRIES"
+	DefaultStoreTTL        = 24 * time.Hour
+	DefaultStoreMaxEntries = 100000
+
+	STORE_LABEL = "store"
+
+	StoreThrottles        = "throttles"
+	StoreChildTaskRunWait = "child-taskrun-wait"
+	StoreDuplicateRuns    = "duplicate-runs"
+	StoreTimings          = "timings"
+	StoreTenants          = "tenants"
+	StoreNamespaces       = "namespace-activity"
+	// StoreRemediations is suffixed with the detector, as each has its own tracker
+	StoreRemediations = "remediations-"
+
+	EvictionExpired   = "expired"
+	EvictionOverLimit = "over-limit"
+
+	storePruneEvery = 10 * time.Minute
+)
+
+func storeTTL() time.Duration {
+	if settings.StoreTTL <= 0 {
+		return DefaultStoreTTL
+	}
+	return settings.StoreTTL
+}
+
+func storeMaxEntries() int {
+	if settings.StoreMaxEntries <= 0 {
+		return DefaultStoreMaxEntries
+	}
+	return settings.StoreMaxEntries
+}
+
+// storeTracker counts the entries evicted from the in-memory stores, and how many each holds after a prune, so a
+// long-running exporter whose stores hit their limits is visible vs. silently forgetting state
+type storeTracker struct {
+	lock      sync.Mutex
+	evictions *prometheus.CounterVec
+	entries   *prometheus.GaugeVec
+}
+
+var storeRetention = &storeTracker{}
+
+func NewStoreEvictionsMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	evictions := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_store_evictions_total",
+		Help: "Number of entries evicted from the exporter's in-memory stores, by store and reason",
+	}, []string{STORE_LABEL, REASON_LABEL})
+	registerer.MustRegister(evictions)
+	return evictions
+}
+
+func NewStoreEntriesMetric(registerer prometheus.Registerer) *prometheus.GaugeVec {
+	entries := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_exporter_store_entries",
+		Help: "Number of entries in each of the exporter's in-memory stores as of the most recent prune",
+	}, []string{STORE_LABEL})
+	registerer.MustRegister(entries)
+	return entries
+}
+
+func (t *storeTracker) enable(evictions *prometheus.CounterVec, entries *prometheus.GaugeVec) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.evictions = evictions
+	t.entries = entries
+}
+
+func (t *storeTracker) evicted(store, reason string, count int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.evictions == nil || count == 0 {
+		return
+	}
+	t.evictions.With(map[string]string{STORE_LABEL: store, REASON_LABEL: reason}).Add(float64(count))
+}
+
+func (t *storeTracker) size(store string, count int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.entries == nil {
+		return
+	}
+	t.entries.With(map[string]string{STORE_LABEL: store}).Set(float64(count))
+}
+
+// evictKeys returns the keys of the store to remove, those last updated longer than the TTL ago, then the least
+// recently updated ones over the size limit, counting them; the caller removes them, holding the store's lock
+func evictKeys(store string, updated map[string]time.Time, now time.Time) []string {
+	return evictKeysAfter(store, updated, now, storeTTL())
+}
+
+// evictKeysAfter is evictKeys with a TTL of the store's own, for those whose entries must outlive STORE_TTL
+func evictKeysAfter(store string, updated map[string]time.Time, now time.Time, ttl time.Duration) []string {
+	evicted := []string{}
+	kept := []string{}
+	for key, at := range updated {
+		if now.Sub(at) > ttl {
+			evicted = append(evicted, key)
+			continue
+		}
+		kept = append(kept, key)
+	}
+	storeRetention.evicted(store, EvictionExpired, len(evicted))
+	if over := len(kept) - storeMaxEntries(); over > 0 {
+		sort.Slice(kept, func(i, j int) bool {
+			return updated[kept[i]].Before(updated[kept[j]])
+		})
+		evicted = append(evicted, kept[:over]...)
+		kept = kept[over:]
+		storeRetention.evicted(store, EvictionOverLimit, over)
+	}
+	storeRetention.size(store, len(kept))
+	return evicted
+}
+
+// storePruner applies the retention to the stores that are not pruned on their own
+type storePruner struct {
+	client      client.Client
+	childWait   *childTaskRunWait
+	timings     *timingStore
+	nsLifecycle *NamespaceLifecycleCollector
+}
+
+func (p *storePruner) prune(ctx context.Context, now time.Time) {
+	inMemoryThrottles.pruneMissing(ctx, p.client, now)
+	inMemoryThrottles.prune(now)
+	p.childWait.prune(now)
+	p.timings.prune(now)
+	p.nsLifecycle.prune(now)
+	tenants.prune(now)
+}
+
+func (p *storePruner) Start(ctx context.Context) error {
+	ticker := exporterClock.NewTicker(storePruneEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			p.prune(ctx, exporterClock.Now())
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	testclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func enableTestStoreRetention() (*prometheus.CounterVec, *prometheus.GaugeVec) {
+	evictions := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_store_evictions_total", Help: "test"},
+		[]string{STORE_LABEL, REASON_LABEL})
+	entries := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_store_entries", Help: "test"}, []string{STORE_LABEL})
+	storeRetention.enable(evictions, entries)
+	return evictions, entries
+}
+
+func TestEvictKeys(t *testing.T) {
+	defer setSettings(Settings{StoreTTL: time.Hour, StoreMaxEntries: 2})()
+	evictions, entries := enableTestStoreRetention()
+	defer storeRetention.enable(nil, nil)
+	now := time.Now()
+	updated := map[string]time.Time{
+		"expired": now.Add(-2 * time.Hour),
+		"oldest":  now.Add(-30 * time.Minute),
+		"older":   now.Add(-20 * time.Minute),
+		"newest":  now,
+	}
+	assert.ElementsMatch(t, []string{"expired", "oldest"}, evictKeys("test", updated, now))
+	validateCounterVec(t, evictions, prometheus.Labels{STORE_LABEL: "test", REASON_LABEL: EvictionExpired}, float64(1))
+	validateCounterVec(t, evictions, prometheus.Labels{STORE_LABEL: "test", REASON_LABEL: EvictionOverLimit}, float64(1))
+	validateGaugeVec(t, entries, prometheus.Labels{STORE_LABEL: "test"}, float64(2))
+}
+
+func TestStorePruner(t *testing.T) {
+	defer setSettings(Settings{StoreTTL: time.Hour, StoreMaxEntries: 2})()
+	evictions, entries := enableTestStoreRetention()
+	defer storeRetention.enable(nil, nil)
+	fakeClock := testclock.NewFakeClock(time.Now())
+	defer setClock(fakeClock)()
+	origThrottles := inMemoryThrottles
+	inMemoryThrottles = &throttleStore{throttled: map[string]string{}, marked: map[string]time.Time{}}
+	defer func() { inMemoryThrottles = origThrottles }()
+	childWait := NewChildTaskRunWait(prometheus.NewRegistry())
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	pruner := &storePruner{client: c, childWait: childWait}
+	ctx := context.TODO()
+
+	prs := []*v1.PipelineRun{}
+	for i := 0; i < 3; i++ {
+		pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: fmt.Sprintf("test-%d", i)}}
+		assert.NoError(t, c.Create(ctx, pr))
+		prs = append(prs, pr)
+		inMemoryThrottles.mark(pr, pr.Name+"-build")
+		childWait.requeue(pr)
+		fakeClock.Step(time.Minute)
+	}
+	// over the limit, one of the PipelineRuns goes; those still running are seen as of the prune, so which one does
+	// not matter
+	pruner.prune(ctx, fakeClock.Now())
+	assert.Len(t, inMemoryThrottles.throttled, 2)
+	assert.Len(t, childWait.attempts, 2)
+	validateCounterVec(t, evictions, prometheus.Labels{STORE_LABEL: StoreThrottles, REASON_LABEL: EvictionOverLimit}, float64(1))
+	validateCounterVec(t, evictions, prometheus.Labels{STORE_LABEL: StoreChildTaskRunWait, REASON_LABEL: EvictionOverLimit}, float64(1))
+	validateGaugeVec(t, entries, prometheus.Labels{STORE_LABEL: StoreThrottles}, float64(2))
+
+	// once past the TTL, the throttled PipelineRuns still running are kept, as their TTL runs from the last sighting
+	fakeClock.Step(2 * time.Hour)
+	pruner.prune(ctx, fakeClock.Now())
+	assert.Len(t, inMemoryThrottles.throttled, 2)
+	assert.Empty(t, childWait.attempts)
+	assert.Empty(t, childWait.requeued)
+	validateCounterVec(t, evictions, prometheus.Labels{STORE_LABEL: StoreChildTaskRunWait, REASON_LABEL: EvictionExpired}, float64(2))
+	validateGaugeVec(t, entries, prometheus.Labels{STORE_LABEL: StoreChildTaskRunWait}, float64(0))
+
+	// and those that can no longer be seen expire
+	for key := range inMemoryThrottles.marked {
+		inMemoryThrottles.marked[key] = fakeClock.Now().Add(-2 * time.Hour)
+	}
+	for _, pr := range prs {
+		assert.NoError(t, client.IgnoreNotFound(c.Delete(ctx, pr)))
+	}
+	inMemoryThrottles.prune(fakeClock.Now())
+	assert.Empty(t, inMemoryThrottles.throttled)
+	validateCounterVec(t, evictions, prometheus.Labels{STORE_LABEL: StoreThrottles, REASON_LABEL: EvictionExpired}, float64(2))
+}
+
+func TestStorePruner_TenantsNamespacesAndRemediations(t *testing.T) {
+	defer setSettings(Settings{StoreTTL: time.Hour, StoreMaxEntries: 2, ActiveNamespaceWindow: 3 * time.Hour})()
+	evictions, _ := enableTestStoreRetention()
+	defer storeRetention.enable(nil, nil)
+	fakeClock := testclock.NewFakeClock(time.Now())
+	defer setClock(fakeClock)()
+	origTenants := tenants
+	tenants = &tenantResolver{cache: map[string]string{}, cached: map[string]time.Time{}}
+	defer func() { tenants = origTenants }()
+	nsLifecycle := NewNamespaceLifecycleCollector(prometheus.NewRegistry())
+	pruner := &storePruner{client: fake.NewClientBuilder().Build(), childWait: NewChildTaskRunWait(prometheus.NewRegistry()), nsLifecycle: nsLifecycle}
+
+	tenants.cache["test-namespace"], tenants.cached["test-namespace"] = "test-tenant", fakeClock.Now()
+	nsLifecycle.observe(&v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test", CreationTimestamp: metav1.NewTime(fakeClock.Now())}})
+
+	// the namespaces are kept past the TTL while within the active namespace window, the tenants are looked up again
+	fakeClock.Step(2 * time.Hour)
+	pruner.prune(context.TODO(), fakeClock.Now())
+	assert.Empty(t, tenants.cache)
+	assert.Contains(t, nsLifecycle.activity, "test-namespace")
+	validateCounterVec(t, evictions, prometheus.Labels{STORE_LABEL: StoreTenants, REASON_LABEL: EvictionExpired}, float64(1))
+
+	fakeClock.Step(2 * time.Hour)
+	pruner.prune(context.TODO(), fakeClock.Now())
+	assert.Empty(t, nsLifecycle.activity)
+	validateCounterVec(t, evictions, prometheus.Labels{STORE_LABEL: StoreNamespaces, REASON_LABEL: EvictionExpired}, float64(1))
+
+	// the remediated objects over the limit are dropped at the end of the scan
+	tracker := &remediationTracker{detector: PipelineRunKickoffDetectorName, remediator: &annotateRemediator{}, previousHit: map[string]time.Time{}, currentHit: map[string]time.Time{}}
+	for i := 0; i < 3; i++ {
+		tracker.currentHit[fmt.Sprintf("test-namespace/test-%d", i)] = fakeClock.Now()
+	}
+	tracker.finishScan()
+	assert.Len(t, tracker.previousHit, 2)
+	validateCounterVec(t, evictions, prometheus.Labels{STORE_LABEL: StoreRemediations + PipelineRunKickoffDetectorName, REASON_LABEL: EvictionOverLimit}, float64(1))
+}
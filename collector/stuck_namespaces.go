@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DetectorSeveritiesEnvName is a comma separated list of <detector name>=<severity> pairs; detectors not listed
+	// default to the warning severity
+	DetectorSeveritiesEnvName = "DETECTOR_SEVERITIES"
+	PVCQuotaDetectorName      = "pvc-quota"
+
+	SEVERITY_LABEL   = "severity"
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+var severities = []string{SeverityCritical, SeverityWarning, SeverityInfo}
+
+type StuckNamespacesCollector struct {
+	registerer      *collectorRegisterer
+	stuckNamespaces *prometheus.GaugeVec
+}
+
+func NewStuckNamespacesCollector(registerer prometheus.Registerer) *StuckNamespacesCollector {
+	reg := newCollectorRegisterer(registerer)
+	labelNames := []string{SEVERITY_LABEL}
+	stuckNamespaces := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_stuck_namespaces",
+		Help: "Number of namespaces flagged by at least one deadlock or poll detector of the given severity during the most recent scan",
+	}, labelNames)
+	reg.MustRegister(stuckNamespaces)
+	return &StuckNamespacesCollector{registerer: reg, stuckNamespaces: stuckNamespaces}
+}
+
+// Close unregisters the metrics of the collector
+func (c *StuckNamespacesCollector) Close() {
+	c.registerer.Close()
+}
+
+func detectorSeverities() map[string]string {
+	detectorSeverity := map[string]string{}
+	env := settings.DetectorSeverities
+	if len(strings.TrimSpace(env)) == 0 {
+		return detectorSeverity
+	}
+	for _, pair := range strings.Split(env, ",") {
+		detectorAndSeverity := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(detectorAndSeverity) != 2 {
+			controllerLog.Info(fmt.Sprintf("ignoring malformed %s entry %q", DetectorSeveritiesEnvName, pair))
+			continue
+		}
+		severity := strings.ToLower(strings.TrimSpace(detectorAndSeverity[1]))
+		valid := false
+		for _, s := range severities {
+			if s == severity {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			controllerLog.Info(fmt.Sprintf("ignoring %s entry %q with unknown severity", DetectorSeveritiesEnvName, pair))
+			continue
+		}
+		detectorSeverity[strings.TrimSpace(detectorAndSeverity[0])] = severity
+	}
+	return detectorSeverity
+}
+
+func (r *ExporterReconcile) severityFor(detector string) string {
+	severity, ok := r.detectorSeverity[detector]
+	if !ok {
+		return SeverityWarning
+	}
+	return severity
+}
+
+// rollupStuckNamespaces is called after all the poll style detectors have completed their scan; a namespace
+// flagged by several detectors of the same severity is only counted once for that severity
+func (r *ExporterReconcile) rollupStuckNamespaces() {
+	nsBySeverity := map[string]map[string]struct{}{}
+	for _, s := range severities {
+		nsBySeverity[s] = map[string]struct{}{}
+	}
+	for detector, flagged := range r.flaggedByDetector {
+		severity := r.severityFor(detector)
+		for ns := range flagged {
+			nsBySeverity[severity][ns] = struct{}{}
+		}
+	}
+	for severity, namespaces := range nsBySeverity {
+		labels := map[string]string{SEVERITY_LABEL: severity}
+		r.stuckNSCollector.stuckNamespaces.With(labels).Set(float64(len(namespaces)))
+	}
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/reconciler/volumeclaim"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestDetectorSeverities(t *testing.T) {
+	defer setSettings(Settings{DetectorSeverities: "pipelinerun-kickoff=Critical, pvc-quota=info,pod-create-attempt=bogus,garbage"})()
+	severity := detectorSeverities()
+	assert.Len(t, severity, 2)
+	assert.Equal(t, SeverityCritical, severity[PipelineRunKickoffDetectorName])
+	assert.Equal(t, SeverityInfo, severity[PVCQuotaDetectorName])
+}
+
+func TestRollupStuckNamespaces(t *testing.T) {
+	defer setSettings(Settings{DetectorSeverities: "pipelinerun-kickoff=critical"})()
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	mockPipelineRuns := []*v1.PipelineRun{
+		// never kicked off
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace-2", Name: "test-1"}},
+		// failed on pvc quota
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace-3", Name: "test-1"},
+			Status: v1.PipelineRunStatus{
+				Status: duckv1.Status{
+					Conditions: duckv1.Conditions{
+						apis.Condition{
+							Type:    apis.ConditionSucceeded,
+							Status:  corev1.ConditionFalse,
+							Reason:  volumeclaim.ReasonCouldntCreateWorkspacePVC,
+							Message: "exceeded quota",
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, pr := range mockPipelineRuns {
+		assert.NoError(t, c.Create(ctx, pr))
+	}
+
+	reconciler := buildReconciler(c, nil, nil)
+	for i := 0; i < 2; i++ {
+		reconciler.resetPVCStats(ctx)
+		reconciler.resetPipelineRunKickoffStats(ctx)
+		reconciler.rollupStuckNamespaces()
+	}
+	validateGaugeVec(t, reconciler.stuckNSCollector.stuckNamespaces, prometheus.Labels{SEVERITY_LABEL: SeverityCritical}, float64(2))
+	validateGaugeVec(t, reconciler.stuckNSCollector.stuckNamespaces, prometheus.Labels{SEVERITY_LABEL: SeverityWarning}, float64(1))
+	validateGaugeVec(t, reconciler.stuckNSCollector.stuckNamespaces, prometheus.Labels{SEVERITY_LABEL: SeverityInfo}, float64(0))
+	reconciler.Close()
+}
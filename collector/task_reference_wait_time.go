@@ -6,19 +6,17 @@ import (
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/pkg/apis"
-	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
-func NewTaskReferenceWaitTimeMetric() *prometheus.HistogramVec {
-	labelNames := []string{NS_LABEL}
+func NewTaskReferenceWaitTimeMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	labelNames := withControlPlaneLabelName([]string{NS_LABEL})
 	waitMetric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "taskrun_task_resolution_wait_milliseconds",
 		Help:    "Duration in milliseconds for a resolution request for a task reference needed by a taskrun to be recognized as complete by the taskrun reconciler in the tekton controller. ",
 		Buckets: prometheus.ExponentialBuckets(float64(100), float64(5), 6),
 	}, labelNames)
-	metrics.Registry.MustRegister(waitMetric)
+	registerer.MustRegister(waitMetric)
 	return waitMetric
 }
 
@@ -29,6 +27,7 @@ type taskRefWaitTimeFilter struct {
 	// message so the condition should not change on any multiple calls.  That said, we'll add a log that captures that, and
 	// if it is occuring, we'll need to track the original transition time either via state in this filter, or as a label/annotation
 	// on the taskrun
+	resolvingReasons map[string]struct{}
 }
 
 func (f *taskRefWaitTimeFilter) Create(event.CreateEvent) bool {
@@ -55,7 +54,7 @@ func (f *taskRefWaitTimeFilter) Update(e event.UpdateEvent) bool {
 		if !oldTR.IsDone() && newTR.IsDone() {
 			// if we did not use some sort of resolve, set metric to 0
 			if newTR.Spec.TaskRef == nil {
-				labels := map[string]string{NS_LABEL: newTR.Namespace}
+				labels := withControlPlaneLabel(map[string]string{NS_LABEL: newTR.Namespace}, newTR.Namespace)
 				f.waitDuration.With(labels).Observe(float64(0))
 			}
 			return false
@@ -67,10 +66,10 @@ func (f *taskRefWaitTimeFilter) Update(e event.UpdateEvent) bool {
 		if oldSucceedCondtition == nil {
 			return false
 		}
-		oldReason := oldSucceedCondtition.Reason
-		newReason := newSucceedCondition.Reason
-		if oldReason == v1.TaskRunReasonResolvingTaskRef && newReason != v1.TaskRunReasonResolvingTaskRef {
-			labels := map[string]string{NS_LABEL: newTR.Namespace}
+		oldResolving := isResolvingReason(f.resolvingReasons, v1.TaskRunReasonResolvingTaskRef, oldSucceedCondtition.Reason)
+		newResolving := isResolvingReason(f.resolvingReasons, v1.TaskRunReasonResolvingTaskRef, newSucceedCondition.Reason)
+		if oldResolving && !newResolving {
+			labels := withControlPlaneLabel(map[string]string{NS_LABEL: newTR.Namespace}, newTR.Namespace)
 			originalTime := oldSucceedCondtition.LastTransitionTime.Inner
 			f.waitDuration.With(labels).Observe(float64(newSucceedCondition.LastTransitionTime.Inner.Sub(originalTime.Time).Milliseconds()))
 			return false
@@ -80,9 +79,9 @@ func (f *taskRefWaitTimeFilter) Update(e event.UpdateEvent) bool {
 		// but if that changes, this log should be a warning;
 		// after running with the log below for a few weeks, the difference has only ever been 1 second, so coverting to debug
 		// log from info log
-		if oldReason == v1.TaskRunReasonResolvingTaskRef && newReason == v1.TaskRunReasonResolvingTaskRef &&
+		if oldResolving && newResolving &&
 			!oldSucceedCondtition.LastTransitionTime.Inner.Equal(&newSucceedCondition.LastTransitionTime.Inner) {
-			ctrl.Log.V(6).Info(fmt.Sprintf("WARNING resolving condition for taskrun %s:%s changed from %#v to %#v",
+			controllerLog.V(6).Info(fmt.Sprintf("WARNING resolving condition for taskrun %s:%s changed from %#v to %#v",
 				newTR.Namespace,
 				newTR.Name,
 				oldSucceedCondtition,
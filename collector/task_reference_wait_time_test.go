@@ -16,7 +16,7 @@ import (
 )
 
 func TestTaskRefWaitTimeFilter_Update(t *testing.T) {
-	filter := &taskRefWaitTimeFilter{waitDuration: NewTaskReferenceWaitTimeMetric()}
+	filter := &taskRefWaitTimeFilter{waitDuration: NewTaskReferenceWaitTimeMetric(prometheus.NewRegistry())}
 	now := time.Now()
 	for _, tc := range []struct {
 		name                  string
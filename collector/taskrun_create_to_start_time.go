@@ -5,7 +5,6 @@ import (
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	"knative.dev/pkg/apis"
 	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 /*
@@ -33,17 +32,18 @@ So,
 is "perhaps" the sum of those two
 */
 
-func NewTaskRunScheduledMetric() *prometheus.HistogramVec {
-	labelNames := []string{NS_LABEL, STATUS_LABEL}
+func NewTaskRunScheduledMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	labelNames := withRunLabelNames(withTenantLabelName([]string{NS_LABEL, STATUS_LABEL}))
+	durationScheduledHelp := "Duration in seconds for a TaskRun to be 'scheduled', meaning it has been received by the Tekton controller.  This is an indication of how quickly create events from the API server are arriving to the Tekton controller."
 	durationScheduled := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "taskrun_duration_scheduled_seconds",
-		Help: "Duration in seconds for a TaskRun to be 'scheduled', meaning it has been received by the Tekton controller.  This is an indication of how quickly create events from the API server are arriving to the Tekton controller.",
+		Help: deprecatedHelp(durationScheduledHelp, "pipeline_service_taskrun_scheduled_duration_seconds"),
 		// reminder: exponential buckets need a start value greater than 0
 		// the results in buckets of 0.1, 0.5, 2.5, 12.5, 62.5, 312.5 seconds
 		Buckets: prometheus.ExponentialBuckets(0.1, 5, 6),
 	}, labelNames)
 
-	metrics.Registry.MustRegister(durationScheduled)
+	registerer.MustRegister(withStableName(durationScheduled, "pipeline_service_taskrun_scheduled_duration_seconds", durationScheduledHelp, labelNames))
 
 	return durationScheduled
 
@@ -71,6 +71,9 @@ func (f *trStartTimeEventFilter) Update(e event.UpdateEvent) bool {
 	newTR, oknew := e.ObjectNew.(*v1.TaskRun)
 	if okold && oknew {
 		if !oldTR.IsDone() && newTR.IsDone() {
+			if startTimeMissing("taskrun-scheduled", newTR, newTR.Status.StartTime) {
+				return false
+			}
 			bumpTaskRunScheduledDuration(calculateScheduledDurationTaskRun(newTR), newTR, f.metric)
 			return false
 		}
@@ -80,14 +83,13 @@ func (f *trStartTimeEventFilter) Update(e event.UpdateEvent) bool {
 
 func bumpTaskRunScheduledDuration(scheduleDuration float64, tr *v1.TaskRun, metric *prometheus.HistogramVec) {
 	succeedCondition := tr.Status.GetCondition(apis.ConditionSucceeded)
-	status := SUCCEEDED
-	if succeedCondition.IsFalse() {
-		status = FAILED
-	}
-	labels := map[string]string{NS_LABEL: tr.Namespace, STATUS_LABEL: status}
+	status := runStatus(succeedCondition)
+	labels := withRunLabels(withTenantLabel(map[string]string{NS_LABEL: tr.Namespace, STATUS_LABEL: status}, tr.Namespace), tr)
 	metric.With(labels).Observe(scheduleDuration)
 }
 
+// calculateScheduledDurationTaskRun is in seconds, like calculateScheduledDurationPipelineRun, vs. the milliseconds of
+// calculateScheduledDuration
 func calculateScheduledDurationTaskRun(taskrun *v1.TaskRun) float64 {
-	return calculateScheduledDuration(taskrun.CreationTimestamp.Time, taskrun.Status.StartTime.Time)
+	return calculateScheduledDuration(taskrun.CreationTimestamp.Time, taskrun.Status.StartTime.Time) / 1000
 }
@@ -9,7 +9,6 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 	"sigs.k8s.io/controller-runtime/pkg/event"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"testing"
 	"time"
 )
@@ -76,12 +75,11 @@ func TestTaskRunStartTimeEventFilter_Update(t *testing.T) {
 			ObjectNew: tc.newTR,
 		}
 		rc := filter.Update(ev)
-		filter.metric = NewTaskRunScheduledMetric()
+		filter.metric = NewTaskRunScheduledMetric(prometheus.NewRegistry())
 
 		if rc != tc.expectedRC {
 			t.Errorf(fmt.Sprintf("tc %s expected %v but got %v", tc.name, tc.expectedRC, rc))
 		}
-		metrics.Registry.Unregister(filter.metric)
 	}
 
 }
@@ -134,11 +132,10 @@ func TestTaskRunScheduledCollection(t *testing.T) {
 	}
 
 	for _, tr := range mockTaskRuns {
-		metric := NewTaskRunScheduledMetric()
+		metric := NewTaskRunScheduledMetric(prometheus.NewRegistry())
 		label := prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: SUCCEEDED}
 		bumpTaskRunScheduledDuration(calculateScheduledDurationTaskRun(tr), tr, metric)
 		validateHistogramVec(t, metric, label, false)
-		metrics.Registry.Unregister(metric)
 	}
 }
 
@@ -149,7 +146,7 @@ func TestCalculateTaskRunScheduledDuration(t *testing.T) {
 		tr          *v1.TaskRun
 	}{
 		{
-			expectedAmt: 5000,
+			expectedAmt: 5,
 			tr: &v1.TaskRun{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:              "test-taskrun-1",
@@ -173,7 +170,7 @@ func TestCalculateTaskRunScheduledDuration(t *testing.T) {
 			},
 		},
 		{
-			expectedAmt: 5000,
+			expectedAmt: 5,
 			tr: &v1.TaskRun{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:              "test-taskrun-1",
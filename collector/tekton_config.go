@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TektonNamespaceEnvName is the namespace of the tekton controller, whose ConfigMaps the tekton-config collector
+	// reads, DefaultTektonNamespace when not set
+	TektonNamespaceEnvName = "TEKTON_NAMESPACE"
+	DefaultTektonNamespace = "openshift-pipelines"
+
+	CONFIGMAP_LABEL = "configmap"
+	KEY_LABEL       = "key"
+	VALUE_LABEL     = "value"
+
+	ConfigMapFeatureFlags   = "feature-flags"
+	ConfigMapConfigDefaults = "config-defaults"
+)
+
+// tektonConfigKeys are the keys of the tekton controller ConfigMaps that have explained shifts in overhead before,
+// or change how runs are scheduled; the other keys only count towards the changes
+var tektonConfigKeys = map[string][]string{
+	ConfigMapFeatureFlags: {
+		"enable-api-fields",
+		"disable-affinity-assistant",
+		"await-sidecar-readiness",
+		"running-in-environment-with-injected-sidecars",
+		"require-git-ssh-secret-known-hosts",
+		"send-cloudevents-for-runs",
+		"resource-verification-mode",
+		"enable-provenance-in-status",
+	},
+	ConfigMapConfigDefaults: {
+		"default-timeout-minutes",
+		"default-service-account",
+		"default-managed-by-label-value",
+	},
+}
+
+func tektonNamespace() string {
+	if len(settings.TektonNamespace) == 0 {
+		return DefaultTektonNamespace
+	}
+	return settings.TektonNamespace
+}
+
+// TektonConfigCollector polls the feature-flags and config-defaults ConfigMaps of the tekton controller, exposing the
+// values of the tektonConfigKeys and counting the changes to the ConfigMaps, as the flags can change without anybody
+// noticing; the ConfigMaps are read directly, vs. caching all the ConfigMaps of the cluster for a watch, so changes
+// made and reverted between two polls are missed.  With TektonControlPlanesEnvName set, the ConfigMaps of each control
+// plane are polled, and told apart by the control_plane label, so the flags of one installation are not blended with
+// those of another.
+type TektonConfigCollector struct {
+	registerer *collectorRegisterer
+	reader     client.Reader
+	// planes are the configured control planes, or a single unnamed one in tektonNamespace(), without the label
+	planes   []TektonControlPlane
+	labelled bool
+	// data is keyed by control plane, then ConfigMap
+	data    map[string]map[string]map[string]string
+	values  *prometheus.GaugeVec
+	changes *prometheus.CounterVec
+}
+
+func NewTektonConfigCollector(registerer prometheus.Registerer, reader client.Reader) *TektonConfigCollector {
+	reg := newCollectorRegisterer(registerer)
+	planes := tektonControlPlanes()
+	labelled := len(planes) > 0
+	if !labelled {
+		planes = []TektonControlPlane{{Namespace: tektonNamespace()}}
+	}
+	valueLabels := []string{CONFIGMAP_LABEL, KEY_LABEL, VALUE_LABEL}
+	changeLabels := []string{CONFIGMAP_LABEL}
+	if labelled {
+		valueLabels = append(valueLabels, CONTROL_PLANE_LABEL)
+		changeLabels = append(changeLabels, CONTROL_PLANE_LABEL)
+	}
+	values := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "pipeline_service_tekton_config_value",
+		Help: "Set to 1 for the current value of each of the tracked keys of the tekton controller ConfigMaps",
+	}, valueLabels)
+	changes := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_tekton_config_changes_total",
+		Help: "Number of times the data of the tekton controller ConfigMaps was seen to change since the exporter started",
+	}, changeLabels)
+	reg.MustRegister(values, changes)
+	return &TektonConfigCollector{
+		registerer: reg,
+		reader:     reader,
+		planes:     planes,
+		labelled:   labelled,
+		data:       map[string]map[string]map[string]string{},
+		values:     values,
+		changes:    changes,
+	}
+}
+
+// Close unregisters the metrics of the collector
+func (c *TektonConfigCollector) Close() {
+	c.registerer.Close()
+}
+
+// withPlane adds the control_plane label when control planes are configured
+func (c *TektonConfigCollector) withPlane(labels prometheus.Labels, plane TektonControlPlane) prometheus.Labels {
+	if c.labelled {
+		labels[CONTROL_PLANE_LABEL] = plane.Name
+	}
+	return labels
+}
+
+// observe records the data of the ConfigMap of the control plane, nil when it does not exist; the first time a
+// ConfigMap is seen is its baseline, not a change
+func (c *TektonConfigCollector) observe(plane TektonControlPlane, name string, data map[string]string) {
+	planeData, ok := c.data[plane.Name]
+	if !ok {
+		planeData = map[string]map[string]string{}
+		c.data[plane.Name] = planeData
+	}
+	previous, seen := planeData[name]
+	if seen && !reflect.DeepEqual(previous, data) {
+		c.changes.With(c.withPlane(prometheus.Labels{CONFIGMAP_LABEL: name}, plane)).Inc()
+	}
+	planeData[name] = data
+	c.values.DeletePartialMatch(c.withPlane(prometheus.Labels{CONFIGMAP_LABEL: name}, plane))
+	for _, key := range tektonConfigKeys[name] {
+		if value, ok := data[key]; ok {
+			c.values.With(c.withPlane(prometheus.Labels{CONFIGMAP_LABEL: name, KEY_LABEL: key, VALUE_LABEL: value}, plane)).Set(1)
+		}
+	}
+}
+
+func (c *TektonConfigCollector) poll(ctx context.Context) {
+	for _, plane := range c.planes {
+		for _, name := range []string{ConfigMapFeatureFlags, ConfigMapConfigDefaults} {
+			cm := &corev1.ConfigMap{}
+			err := c.reader.Get(ctx, types.NamespacedName{Namespace: plane.Namespace, Name: name}, cm)
+			switch {
+			case err == nil:
+				c.observe(plane, name, cm.Data)
+			case errors.IsNotFound(err):
+				c.observe(plane, name, nil)
+			default:
+				// the values are no longer known, so they are dropped vs. reported as current; the data is kept so a
+				// change made meanwhile is still counted once the ConfigMap can be read again
+				controllerLog.Info(fmt.Sprintf("could not get the ConfigMap %s/%s: %s", plane.Namespace, name, err.Error()))
+				c.values.DeletePartialMatch(c.withPlane(prometheus.Labels{CONFIGMAP_LABEL: name}, plane))
+			}
+		}
+	}
+}
+
+func (c *TektonConfigCollector) Start(ctx context.Context) error {
+	c.poll(ctx)
+	eventTicker := exporterClock.NewTicker(2 * time.Minute)
+	defer eventTicker.Stop()
+	for {
+		select {
+		case <-eventTicker.C():
+			c.poll(ctx)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestTektonConfigCollector(t *testing.T) {
+	defer setSettings(Settings{TektonNamespace: "tekton-pipelines"})()
+	flags := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tekton-pipelines", Name: ConfigMapFeatureFlags},
+		Data:       map[string]string{"enable-api-fields": "stable", "disable-affinity-assistant": "false", "untracked": "x"},
+	}
+	cl := fake.NewClientBuilder().WithObjects(flags).Build()
+	registry := prometheus.NewPedanticRegistry()
+	c := NewTektonConfigCollector(registry, cl)
+	defer c.Close()
+
+	c.poll(context.TODO())
+	families := gatherFamilies(t, registry)
+	assert.Equal(t, map[string]float64{"feature-flags/disable-affinity-assistant/false/": 1, "feature-flags/enable-api-fields/stable/": 1},
+		gaugesByLabels(families["pipeline_service_tekton_config_value"]))
+	// the first poll is the baseline
+	assert.NotContains(t, families, "pipeline_service_tekton_config_changes_total")
+
+	c.poll(context.TODO())
+	assert.NotContains(t, gatherFamilies(t, registry), "pipeline_service_tekton_config_changes_total")
+
+	flags.Data = map[string]string{"enable-api-fields": "beta", "untracked": "x"}
+	assert.NoError(t, cl.Update(context.TODO(), flags))
+	c.poll(context.TODO())
+	assert.NoError(t, cl.Create(context.TODO(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tekton-pipelines", Name: ConfigMapConfigDefaults},
+		Data:       map[string]string{"default-timeout-minutes": "120"},
+	}))
+	c.poll(context.TODO())
+	families = gatherFamilies(t, registry)
+	assert.Equal(t, map[string]float64{"config-defaults/default-timeout-minutes/120/": 1, "feature-flags/enable-api-fields/beta/": 1},
+		gaugesByLabels(families["pipeline_service_tekton_config_value"]))
+	validateCounterVec(t, c.changes, prometheus.Labels{CONFIGMAP_LABEL: ConfigMapFeatureFlags}, float64(1))
+	validateCounterVec(t, c.changes, prometheus.Labels{CONFIGMAP_LABEL: ConfigMapConfigDefaults}, float64(1))
+
+	// the values of a ConfigMap that can no longer be read are unknown, so they are dropped
+	c.reader = &forbiddenReader{Reader: cl}
+	c.poll(context.TODO())
+	assert.NotContains(t, gatherFamilies(t, registry), "pipeline_service_tekton_config_value")
+	c.reader = cl
+	c.poll(context.TODO())
+	assert.Len(t, gaugesByLabels(gatherFamilies(t, registry)["pipeline_service_tekton_config_value"]), 2)
+	validateCounterVec(t, c.changes, prometheus.Labels{CONFIGMAP_LABEL: ConfigMapFeatureFlags}, float64(1))
+}
+
+// forbiddenReader fails every get like a missing Role would
+type forbiddenReader struct {
+	client.Reader
+}
+
+func (r *forbiddenReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return errors.NewForbidden(corev1.Resource("configmaps"), key.Name, fmt.Errorf("forbidden"))
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TenantLabelEnvName turns on the optional tenant label for the overhead, throttle, and duration metrics
+	TenantLabelEnvName = "TENANT_LABEL_ENABLED"
+	// TenantNamespaceLabelEnvName overrides which namespace label holds the RHTAP workspace / tenant name
+	TenantNamespaceLabelEnvName = "TENANT_NAMESPACE_LABEL"
+	DEFAULT_TENANT_NS_LABEL     = "appstudio.redhat.com/workspace_name"
+	TENANT_LABEL                = "tenant"
+)
+
+// tenants is configured in SetupController before any of the metrics are created, since whether or not the tenant
+// label is enabled dictates the label names of those metrics
+var tenants = &tenantResolver{cache: map[string]string{}, cached: map[string]time.Time{}}
+
+type tenantResolver struct {
+	enabled bool
+	nsLabel string
+	client  client.Client
+	lock    sync.RWMutex
+	cache   map[string]string
+	cached  map[string]time.Time
+}
+
+func (t *tenantResolver) configure(c client.Client) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.enabled = settings.TenantLabel
+	t.nsLabel = strings.TrimSpace(settings.TenantNamespaceLabel)
+	if len(t.nsLabel) == 0 {
+		t.nsLabel = DEFAULT_TENANT_NS_LABEL
+	}
+	t.client = c
+	t.cache = map[string]string{}
+	t.cached = map[string]time.Time{}
+}
+
+func (t *tenantResolver) tenant(ns string) string {
+	t.lock.RLock()
+	tenant, ok := t.cache[ns]
+	t.lock.RUnlock()
+	if ok {
+		return tenant
+	}
+	namespace := &corev1.Namespace{}
+	err := t.client.Get(context.Background(), types.NamespacedName{Name: ns}, namespace)
+	if err != nil {
+		// don't cache, so we try again on the next observation
+		controllerLog.V(4).Info(fmt.Sprintf("could not get namespace %s for its tenant: %s", ns, err.Error()))
+		return ""
+	}
+	tenant = namespace.Labels[t.nsLabel]
+	t.lock.Lock()
+	t.cache[ns] = tenant
+	t.cached[ns] = exporterClock.Now()
+	t.lock.Unlock()
+	return tenant
+}
+
+// forget drops the cached tenant of a deleted namespace, so the cache only holds namespaces that still exist
+func (t *tenantResolver) forget(ns string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.cache, ns)
+	delete(t.cached, ns)
+}
+
+// prune drops the tenants cached longer than the TTL ago, which are then looked up again, so a relabelled namespace
+// is eventually picked up, and those over the size limit, for when the delete of their namespace was missed
+func (t *tenantResolver) prune(now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, ns := range evictKeys(StoreTenants, t.cached, now) {
+		delete(t.cache, ns)
+		delete(t.cached, ns)
+	}
+}
+
+// onNamespaceDelete calls forget with the name of each namespace deleted from the cluster, as seen by the
+// namespace informer
+func onNamespaceDelete(ctx context.Context, informers informerSource, forget func(ns string)) error {
+	informer, err := informers.GetInformer(ctx, &corev1.Namespace{})
+	if err != nil {
+		return err
+	}
+	_, err = informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			defer recoverHandler("namespace-delete", obj)
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if ns, ok := obj.(client.Object); ok {
+				forget(ns.GetName())
+			}
+		},
+	})
+	return err
+}
+
+// withTenantLabelName should be used when defining the label names of any metric that supports the tenant label
+func withTenantLabelName(labelNames []string) []string {
+	if !tenants.enabled {
+		return labelNames
+	}
+	return append(labelNames, TENANT_LABEL)
+}
+
+// withTenantLabel should be used when building the labels to observe a metric whose label names came from withTenantLabelName
+func withTenantLabel(labels map[string]string, ns string) map[string]string {
+	if !tenants.enabled {
+		return labels
+	}
+	labels[TENANT_LABEL] = tenants.tenant(ns)
+	return labels
+}
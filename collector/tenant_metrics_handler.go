@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// TenantMetricsEndpointEnvName turns on the /metrics/tenant/<name> paths on the metrics listener
+	TenantMetricsEndpointEnvName = "TENANT_METRICS_ENDPOINT_ENABLED"
+	TenantMetricsPathPrefix      = "/metrics/tenant/"
+)
+
+// tenantGatherer only returns the series whose namespace belongs to the tenant; series without a namespace label,
+// which are cluster level aggregates, are never returned, as they would leak information about other tenants
+type tenantGatherer struct {
+	gatherer prometheus.Gatherer
+	tenant   string
+}
+
+func (g *tenantGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	filtered := []*dto.MetricFamily{}
+	for _, mf := range families {
+		kept := []*dto.Metric{}
+		for _, m := range mf.Metric {
+			if g.belongsToTenant(m) {
+				kept = append(kept, m)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		mf.Metric = kept
+		filtered = append(filtered, mf)
+	}
+	return filtered, nil
+}
+
+func (g *tenantGatherer) belongsToTenant(m *dto.Metric) bool {
+	ns := ""
+	for _, lp := range m.Label {
+		switch lp.GetName() {
+		case TENANT_LABEL:
+			return lp.GetValue() == g.tenant
+		case NS_LABEL:
+			ns = lp.GetValue()
+		}
+	}
+	if len(ns) == 0 {
+		return false
+	}
+	return tenants.tenant(ns) == g.tenant
+}
+
+type tenantMetricsHandler struct {
+	gatherer prometheus.Gatherer
+}
+
+func (h *tenantMetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenant := strings.Trim(strings.TrimPrefix(r.URL.Path, TenantMetricsPathPrefix), "/")
+	if len(tenant) == 0 || strings.Contains(tenant, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	promhttp.HandlerFor(&tenantGatherer{gatherer: h.gatherer, tenant: tenant}, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.HTTPErrorOnError,
+	}).ServeHTTP(w, r)
+}
+
+func newTenantMetricsHandler() http.Handler {
+	return &tenantMetricsHandler{gatherer: ServedGatherer}
+}
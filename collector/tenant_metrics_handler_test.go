@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sscheme "k8s.io/client-go/kubernetes/scheme"
+	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestTenantMetricsHandler(t *testing.T) {
+	defer tenants.configure(nil)
+	scheme := runtime.NewScheme()
+	_ = k8sscheme.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	for ns, tenant := range map[string]string{"test-namespace": "workspace-1", "test-namespace-2": "workspace-2"} {
+		assert.NoError(t, c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   ns,
+			Labels: map[string]string{DEFAULT_TENANT_NS_LABEL: tenant},
+		}}))
+	}
+	tenants.configure(c)
+
+	registry := prometheus.NewRegistry()
+	byNamespace := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_by_namespace", Help: "test"}, []string{NS_LABEL})
+	byTenant := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_by_tenant", Help: "test"}, []string{TENANT_LABEL})
+	clusterWide := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_cluster_wide", Help: "test"})
+	registry.MustRegister(byNamespace, byTenant, clusterWide)
+	byNamespace.With(prometheus.Labels{NS_LABEL: "test-namespace"}).Set(1)
+	byNamespace.With(prometheus.Labels{NS_LABEL: "test-namespace-2"}).Set(2)
+	byTenant.With(prometheus.Labels{TENANT_LABEL: "workspace-1"}).Set(3)
+	byTenant.With(prometheus.Labels{TENANT_LABEL: "workspace-2"}).Set(4)
+	clusterWide.Set(5)
+
+	handler := &tenantMetricsHandler{gatherer: registry}
+	rsp := httptest.NewRecorder()
+	handler.ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, TenantMetricsPathPrefix+"workspace-1", nil))
+	assert.Equal(t, http.StatusOK, rsp.Code)
+	body := rsp.Body.String()
+	assert.Contains(t, body, `test_by_namespace{namespace="test-namespace"} 1`)
+	assert.Contains(t, body, `test_by_tenant{tenant="workspace-1"} 3`)
+	assert.NotContains(t, body, "test-namespace-2")
+	assert.NotContains(t, body, "workspace-2")
+	assert.NotContains(t, body, "test_cluster_wide")
+
+	rsp = httptest.NewRecorder()
+	handler.ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, TenantMetricsPathPrefix, nil))
+	assert.Equal(t, http.StatusNotFound, rsp.Code)
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestTenantResolver(t *testing.T) {
+	defer setSettings(Settings{})()
+	scheme := runtime.NewScheme()
+	_ = k8sscheme.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	assert.NoError(t, c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "test-namespace",
+		Labels: map[string]string{DEFAULT_TENANT_NS_LABEL: "workspace-1", "custom/tenant": "custom-1"},
+	}}))
+
+	resolver := &tenantResolver{}
+	resolver.configure(c)
+	assert.False(t, resolver.enabled)
+
+	settings.TenantLabel = true
+	resolver.configure(c)
+	assert.True(t, resolver.enabled)
+	assert.Equal(t, "workspace-1", resolver.tenant("test-namespace"))
+	// missing namespaces have no tenant
+	assert.Equal(t, "", resolver.tenant("test-namespace-2"))
+	// cached lookups survive the namespace going away
+	assert.NoError(t, c.Delete(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-namespace"}}))
+	assert.Equal(t, "workspace-1", resolver.tenant("test-namespace"))
+
+	settings.TenantNamespaceLabel = "custom/tenant"
+	assert.NoError(t, c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "test-namespace",
+		Labels: map[string]string{DEFAULT_TENANT_NS_LABEL: "workspace-1", "custom/tenant": "custom-1"},
+	}}))
+	resolver.configure(c)
+	assert.Equal(t, "custom-1", resolver.tenant("test-namespace"))
+}
+
+func TestWithTenantLabel(t *testing.T) {
+	restore := setSettings(Settings{})
+	defer func() {
+		restore()
+		tenants.configure(nil)
+	}()
+	scheme := runtime.NewScheme()
+	_ = k8sscheme.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	assert.NoError(t, c.Create(context.TODO(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "test-namespace",
+		Labels: map[string]string{DEFAULT_TENANT_NS_LABEL: "workspace-1"},
+	}}))
+
+	tenants.configure(c)
+	assert.Equal(t, []string{NS_LABEL}, withTenantLabelName([]string{NS_LABEL}))
+	assert.Equal(t, map[string]string{NS_LABEL: "test-namespace"}, withTenantLabel(map[string]string{NS_LABEL: "test-namespace"}, "test-namespace"))
+
+	settings.TenantLabel = true
+	tenants.configure(c)
+	assert.Equal(t, []string{NS_LABEL, TENANT_LABEL}, withTenantLabelName([]string{NS_LABEL}))
+	assert.Equal(t, map[string]string{NS_LABEL: "test-namespace", TENANT_LABEL: "workspace-1"}, withTenantLabel(map[string]string{NS_LABEL: "test-namespace"}, "test-namespace"))
+}
+
+func TestTenantForgetOnNamespaceDelete(t *testing.T) {
+	defer setSettings(Settings{TenantLabel: true})()
+	scheme := runtime.NewScheme()
+	_ = k8sscheme.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "test-namespace",
+		Labels: map[string]string{DEFAULT_TENANT_NS_LABEL: "workspace-1"},
+	}}
+	assert.NoError(t, c.Create(context.TODO(), ns))
+	resolver := &tenantResolver{}
+	resolver.configure(c)
+	assert.Equal(t, "workspace-1", resolver.tenant("test-namespace"))
+	assert.Len(t, resolver.cache, 1)
+
+	informers := &informertest.FakeInformers{Scheme: scheme}
+	assert.NoError(t, onNamespaceDelete(context.TODO(), informers, resolver.forget))
+	informer, err := informers.FakeInformerFor(&corev1.Namespace{})
+	assert.NoError(t, err)
+	informer.Delete(ns)
+	assert.Len(t, resolver.cache, 0)
+}
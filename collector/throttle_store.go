@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// throttleStore is the in-memory alternative to labelling PipelineRuns with THROTTLED_LABEL, used when the exporter
+// runs in read-only mode; the label is still honored if present, say from prior runs of the exporter in normal mode
+type throttleStore struct {
+	lock      sync.RWMutex
+	throttled map[string]string
+	marked    map[string]time.Time
+}
+
+var inMemoryThrottles = &throttleStore{throttled: map[string]string{}, marked: map[string]time.Time{}}
+
+func throttleKey(pr *v1.PipelineRun) string {
+	return pr.Namespace + "/" + pr.Name
+}
+
+func (s *throttleStore) mark(pr *v1.PipelineRun, trName string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.throttled[throttleKey(pr)] = trName
+	s.marked[throttleKey(pr)] = exporterClock.Now()
+}
+
+// seen refreshes the mark of a PipelineRun still tracked, so the TTL runs from when it was last seen running vs. from
+// when it was throttled, and a long-running PipelineRun is not evicted while it still runs
+func (s *throttleStore) seen(pr *v1.PipelineRun, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, tracked := s.marked[throttleKey(pr)]; tracked {
+		s.marked[throttleKey(pr)] = now
+	}
+}
+
+func (s *throttleStore) forget(pr *v1.PipelineRun) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.throttled, throttleKey(pr))
+	delete(s.marked, throttleKey(pr))
+}
+
+// prune drops the PipelineRuns whose completion or deletion was missed, as they are otherwise only forgotten then
+func (s *throttleStore) prune(now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, key := range evictKeys(StoreThrottles, s.marked, now) {
+		delete(s.throttled, key)
+		delete(s.marked, key)
+	}
+}
+
+// pruneMissing drops the PipelineRuns that are gone, or done, as of the exporter's cache, for when the events of their
+// completion or deletion were missed and the entries would otherwise linger until they expire; those still running
+// are seen as of now
+func (s *throttleStore) pruneMissing(ctx context.Context, oc client.Client, now time.Time) {
+	s.lock.RLock()
+	keys := make([]string, 0, len(s.throttled))
+	for key := range s.throttled {
+		keys = append(keys, key)
+	}
+	s.lock.RUnlock()
+	for _, key := range keys {
+		ns, name, _ := strings.Cut(key, "/")
+		pr := &v1.PipelineRun{}
+		err := oc.Get(ctx, types.NamespacedName{Namespace: ns, Name: name}, pr)
+		switch {
+		case err == nil && !pr.IsDone():
+			s.seen(pr, now)
+			continue
+		case err != nil && !errors.IsNotFound(err):
+			controllerLog.V(4).Info(fmt.Sprintf("could not get throttled pipelinerun %s: %s", key, err.Error()))
+			continue
+		}
+		s.lock.Lock()
+		delete(s.throttled, key)
+		delete(s.marked, key)
+		s.lock.Unlock()
+	}
+}
+
+// throttledBy returns the name of the TaskRun that was throttled for the PipelineRun, if any
+func (s *throttleStore) throttledBy(pr *v1.PipelineRun) (string, bool) {
+	trName, labelled := pr.Labels[THROTTLED_LABEL]
+	if labelled {
+		return trName, true
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	trName, tracked := s.throttled[throttleKey(pr)]
+	return trName, tracked
+}
+
+func trackPipelineRunsWithTaskRunsGettingThrottled(pr *v1.PipelineRun, oc client.Client, ctx context.Context) error {
+	throttled, throttledTaskRun, err := isPipelineRunThrottled(pr, oc, ctx)
+	if err != nil {
+		return err
+	}
+	_, previouslyTracked := inMemoryThrottles.throttledBy(pr)
+	if previouslyTracked {
+		inMemoryThrottles.seen(pr, exporterClock.Now())
+	}
+	if throttled && !previouslyTracked {
+		controllerLog.Info(fmt.Sprintf("Tracking PipelineRun %s:%s as throttled because of %s", pr.Namespace, pr.Name, throttledTaskRun))
+		inMemoryThrottles.mark(pr, throttledTaskRun)
+	}
+	return nil
+}
+
+// kickoffThrottledDuration is how long, in milliseconds, the TaskRun a PipelineRun was throttled by waited on its pod,
+// from its creation to the creation of its pod, when it was among the first TaskRuns of the PipelineRun; it is false
+// if the PipelineRun was not throttled, was throttled on a later TaskRun, or the TaskRun or its pod are gone
+func kickoffThrottledDuration(ctx context.Context, oc client.Client, pr *v1.PipelineRun) (float64, bool) {
+	trName, throttled := inMemoryThrottles.throttledBy(pr)
+	if !throttled {
+		return 0, false
+	}
+	sortedTaskRunsByCreateTimes, _, abortReason := sortTaskRunsForGapCalculations(pr, oc, ctx, nil)
+	if len(abortReason) > 0 || len(sortedTaskRunsByCreateTimes) == 0 {
+		return 0, false
+	}
+	first := sortedTaskRunsByCreateTimes[0]
+	for _, tr := range sortedTaskRunsByCreateTimes {
+		if tr.Name != trName {
+			continue
+		}
+		// parallel TaskRuns starting off the PipelineRun are created together, any of them may have been throttled
+		if tr.CreationTimestamp.Time.After(first.CreationTimestamp.Time) || len(tr.Status.PodName) == 0 {
+			return 0, false
+		}
+		pod := &corev1.Pod{}
+		err := oc.Get(ctx, types.NamespacedName{Namespace: tr.Namespace, Name: tr.Status.PodName}, pod)
+		if err != nil {
+			controllerLog.Info(fmt.Sprintf("could not get pod %s:%s of throttled taskrun %s: %s", tr.Namespace, tr.Status.PodName, tr.Name, err.Error()))
+			return 0, false
+		}
+		throttledDuration := float64(pod.CreationTimestamp.Time.Sub(tr.CreationTimestamp.Time).Milliseconds())
+		return throttledDuration, throttledDuration >= 0
+	}
+	return 0, false
+}
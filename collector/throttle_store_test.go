@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/pod"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyThrottleTracking(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	pr := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"},
+		Status: v1.PipelineRunStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{
+					apis.Condition{
+						Type:   apis.ConditionSucceeded,
+						Status: corev1.ConditionUnknown,
+					},
+				},
+			},
+			PipelineRunStatusFields: v1.PipelineRunStatusFields{
+				ChildReferences: []v1.ChildStatusReference{
+					{
+						TypeMeta: runtime.TypeMeta{Kind: "TaskRun"},
+						Name:     "test-1-tr",
+					},
+				},
+			},
+		},
+	}
+	tr := &v1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1-tr"},
+		Status: v1.TaskRunStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{
+					apis.Condition{
+						Type:   apis.ConditionSucceeded,
+						Status: corev1.ConditionUnknown,
+						Reason: pod.ReasonExceededResourceQuota,
+					},
+				},
+			},
+		},
+	}
+	assert.NoError(t, c.Create(ctx, pr))
+	assert.NoError(t, c.Create(ctx, tr))
+
+	reconciler := buildReconciler(c, nil, nil)
+	reconciler.readOnly = true
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: pr.Namespace, Name: pr.Name}}
+	_, err := reconciler.ReconcileOverhead(ctx, request)
+	assert.NoError(t, err)
+
+	// nothing written to the cluster
+	updated := &v1.PipelineRun{}
+	assert.NoError(t, c.Get(ctx, request.NamespacedName, updated))
+	_, labelled := updated.Labels[THROTTLED_LABEL]
+	assert.False(t, labelled)
+
+	// but we still remember it is throttled
+	trName, throttled := inMemoryThrottles.throttledBy(updated)
+	assert.True(t, throttled)
+	assert.Equal(t, "test-1-tr", trName)
+	filter := &overheadGapEventFilter{client: c}
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: updated, ObjectNew: updated}))
+
+	// once done, the gap reconcile forgets about it
+	updated.Status.Conditions[0].Status = corev1.ConditionTrue
+	assert.NoError(t, c.Status().Update(ctx, updated))
+	_, err = reconciler.ReconcilePipelineRunTaskRunGap(ctx, request)
+	assert.NoError(t, err)
+	_, throttled = inMemoryThrottles.throttledBy(updated)
+	assert.False(t, throttled)
+	reconciler.Close()
+}
+
+func TestThrottleStorePruneMissing(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.TODO()
+	store := &throttleStore{throttled: map[string]string{}, marked: map[string]time.Time{}}
+	running := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "running"}}
+	done := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "done"},
+		Status: v1.PipelineRunStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{
+					apis.Condition{
+						Type:   apis.ConditionSucceeded,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		},
+	}
+	deleted := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "deleted"}}
+	assert.NoError(t, c.Create(ctx, running))
+	assert.NoError(t, c.Create(ctx, done))
+	for _, pr := range []*v1.PipelineRun{running, done, deleted} {
+		store.mark(pr, pr.Name+"-build")
+	}
+
+	store.pruneMissing(ctx, c, time.Now())
+	_, tracked := store.throttledBy(running)
+	assert.True(t, tracked)
+	_, tracked = store.throttledBy(done)
+	assert.False(t, tracked)
+	_, tracked = store.throttledBy(deleted)
+	assert.False(t, tracked)
+	assert.Len(t, store.marked, 1)
+}
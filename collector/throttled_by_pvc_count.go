@@ -6,11 +6,11 @@ import (
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	"github.com/tektoncd/pipeline/pkg/reconciler/volumeclaim"
 	"knative.dev/pkg/apis"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"strings"
 )
 
 type ThrottledByPVCQuotaCollector struct {
+	registerer  *collectorRegisterer
 	pvcThrottle *prometheus.GaugeVec
 }
 
@@ -59,28 +59,40 @@ func (r *ExporterReconcile) resetPVCStats(ctx context.Context) {
 			}
 			r.pvcCollector.ZeroCollector(pr.Namespace)
 		}
+	} else {
+		controllerLog.Error(err, "pipeline run query for the PVC quota scan failed with an error")
 	}
+	// like the other detectors, nothing is flagged when the scan failed, vs. reporting the prior scan's namespaces
+	// as if they were still current
+	r.flaggedByDetector[PVCQuotaDetectorName] = nsWithPVCThrottle
 }
 
-func NewPVCThrottledCollector() *ThrottledByPVCQuotaCollector {
-	labelNames := []string{NS_LABEL}
+func NewPVCThrottledCollector(registerer prometheus.Registerer) *ThrottledByPVCQuotaCollector {
+	reg := newCollectorRegisterer(registerer)
+	labelNames := withTenantLabelName([]string{NS_LABEL})
 	pvcThrottled := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "pipelinerun_failed_by_pvc_quota_count",
 		Help: "Number of PipelineRuns who were marked failed because PVC Resource Quotas prevented the creation of required PVCs",
 	}, labelNames)
 	pvcThrottledCollector := &ThrottledByPVCQuotaCollector{
+		registerer:  reg,
 		pvcThrottle: pvcThrottled,
 	}
-	metrics.Registry.MustRegister(pvcThrottled)
+	reg.MustRegister(pvcThrottled)
 	return pvcThrottledCollector
 }
 
+// Close unregisters the metrics of the collector
+func (c *ThrottledByPVCQuotaCollector) Close() {
+	c.registerer.Close()
+}
+
 func (c *ThrottledByPVCQuotaCollector) IncCollector(ns string) {
-	labels := map[string]string{NS_LABEL: ns}
+	labels := withTenantLabel(map[string]string{NS_LABEL: ns}, ns)
 	c.pvcThrottle.With(labels).Inc()
 }
 
 func (c *ThrottledByPVCQuotaCollector) ZeroCollector(ns string) {
-	labels := map[string]string{NS_LABEL: ns}
+	labels := withTenantLabel(map[string]string{NS_LABEL: ns}, ns)
 	c.pvcThrottle.With(labels).Set(float64(0))
 }
@@ -72,5 +72,13 @@ func TestResetPVCStats(t *testing.T) {
 	assert.NoError(t, err)
 	pvcReconciler.resetPVCStats(ctx)
 	validateGaugeVec(t, pvcReconciler.pvcCollector.pvcThrottle, label, float64(1))
-	unregisterStats(pvcReconciler)
+	assert.Contains(t, pvcReconciler.flaggedByDetector[PVCQuotaDetectorName], "test-namespace")
+	pvcReconciler.Close()
+
+	// a failed list, here from a scheme without the tekton types, must not leave the prior scan's namespaces flagged
+	errReconciler := buildReconciler(fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build(), nil, nil)
+	errReconciler.flaggedByDetector[PVCQuotaDetectorName] = map[string]struct{}{"test-namespace": {}}
+	errReconciler.resetPVCStats(ctx)
+	assert.Empty(t, errReconciler.flaggedByDetector[PVCQuotaDetectorName])
+	errReconciler.Close()
 }
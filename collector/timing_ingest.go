@@ -0,0 +1,246 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	// IngestTimingsPath is where external systems post the milestones of PipelineRuns on the admin listener
+	IngestTimingsPath = "/ingest/timings"
+
+	MILESTONE_LABEL = "milestone"
+
+	TimingResultAccepted  = "accepted"
+	TimingResultRejected  = "rejected"
+	TimingResultDuplicate = "duplicate"
+
+	// maxTimingMilestones bounds the distinct milestone names, as each is a label value
+	maxTimingMilestones = 20
+	// maxTimingBody bounds the size of a post
+	maxTimingBody = 1 << 20
+)
+
+// milestoneName keeps the milestones to short, label friendly names, like pac-webhook-received
+var milestoneName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// TimingMilestone is when something an external system did for a PipelineRun happened, like Pipelines as Code
+// receiving the webhook which led to its creation, or a release service picking up its results
+type TimingMilestone struct {
+	// UID is the uid of the PipelineRun
+	UID       types.UID `json:"uid"`
+	Milestone string    `json:"milestone"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func NewExtendedDurationMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	extended := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "pipelinerun_extended_duration_milliseconds",
+		Help: "Duration in milliseconds of a pipelinerun extended to an external milestone, from the earlier of its creation and the milestone to the later of its completion and the milestone",
+		// reminder: exponential buckets need a start value greater than 0
+		// the results in buckets of 1s, 4s, 16s, ~1m, ~4m, ~17m, ~68m, ~4.5h
+		Buckets: prometheus.ExponentialBuckets(float64(1000), float64(4), 8),
+	}, withTenantLabelName([]string{NS_LABEL, MILESTONE_LABEL}))
+	registerer.MustRegister(extended)
+	return extended
+}
+
+func NewIngestedTimingsMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	ingested := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_ingested_timings_total",
+		Help: "Number of PipelineRun milestones posted by external systems, by whether they were accepted, rejected, or already posted for the PipelineRun",
+	}, []string{RESULT_LABEL})
+	registerer.MustRegister(ingested)
+	return ingested
+}
+
+// completedRun is what the extended durations need of a completed PipelineRun
+type completedRun struct {
+	namespace string
+	created   time.Time
+	completed time.Time
+}
+
+// timingStore merges the milestones posted by external systems with the PipelineRuns the exporter watches; milestones
+// posted before the PipelineRun completes wait for its completion, and the completed PipelineRuns are kept, for the
+// store's TTL, for the milestones posted after, like those of systems acting on the results
+type timingStore struct {
+	lock sync.Mutex
+	// milestones are the times of the milestones posted for each PipelineRun, by name, whether observed or waiting for
+	// the PipelineRun to complete, so a retried post is not observed twice; as the names are bounded, so is each map
+	milestones map[types.UID]map[string]time.Time
+	runs       map[types.UID]completedRun
+	updated    map[string]time.Time
+	names      map[string]struct{}
+	extended   *prometheus.HistogramVec
+	ingested   *prometheus.CounterVec
+}
+
+func newTimingStore(extended *prometheus.HistogramVec, ingested *prometheus.CounterVec) *timingStore {
+	return &timingStore{
+		milestones: map[types.UID]map[string]time.Time{},
+		runs:       map[types.UID]completedRun{},
+		updated:    map[string]time.Time{},
+		names:      map[string]struct{}{},
+		extended:   extended,
+		ingested:   ingested,
+	}
+}
+
+func (s *timingStore) observe(run completedRun, milestone string, timestamp time.Time) {
+	start := run.created
+	if timestamp.Before(start) {
+		start = timestamp
+	}
+	end := run.completed
+	if timestamp.After(end) {
+		end = timestamp
+	}
+	labels := map[string]string{NS_LABEL: run.namespace, MILESTONE_LABEL: milestone}
+	s.extended.With(withTenantLabel(labels, run.namespace)).Observe(float64(end.Sub(start).Milliseconds()))
+}
+
+// validate checks the milestone, adding its name to names when it is not one of the label values yet; the caller
+// holds the lock
+func (s *timingStore) validate(m TimingMilestone, names map[string]struct{}) error {
+	if len(m.UID) == 0 {
+		return fmt.Errorf("the uid of the pipelinerun is missing")
+	}
+	if !milestoneName.MatchString(m.Milestone) {
+		return fmt.Errorf("milestone %q must be lowercase alphanumerics and dashes, up to 63 characters", m.Milestone)
+	}
+	if m.Timestamp.IsZero() {
+		return fmt.Errorf("the timestamp of milestone %s is missing", m.Milestone)
+	}
+	if _, ok := s.names[m.Milestone]; !ok {
+		names[m.Milestone] = struct{}{}
+		if len(s.names)+len(names) > maxTimingMilestones {
+			return fmt.Errorf("milestone %s is over the limit of %d distinct milestones", m.Milestone, maxTimingMilestones)
+		}
+	}
+	return nil
+}
+
+// add takes all of the milestones, or none of them when any is invalid, so the names of a rejected post are not
+// counted against the limit; the milestones already posted for their PipelineRun are skipped, as retries
+func (s *timingStore) add(milestones []TimingMilestone) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	names := map[string]struct{}{}
+	for _, m := range milestones {
+		if err := s.validate(m, names); err != nil {
+			s.ingested.With(prometheus.Labels{RESULT_LABEL: TimingResultRejected}).Add(float64(len(milestones)))
+			return err
+		}
+	}
+	for name := range names {
+		s.names[name] = struct{}{}
+	}
+	now := exporterClock.Now()
+	for _, m := range milestones {
+		posted, ok := s.milestones[m.UID]
+		if !ok {
+			posted = map[string]time.Time{}
+			s.milestones[m.UID] = posted
+		}
+		if _, duplicate := posted[m.Milestone]; duplicate {
+			s.ingested.With(prometheus.Labels{RESULT_LABEL: TimingResultDuplicate}).Inc()
+			continue
+		}
+		posted[m.Milestone] = m.Timestamp
+		s.updated[string(m.UID)] = now
+		if run, ok := s.runs[m.UID]; ok {
+			s.observe(run, m.Milestone, m.Timestamp)
+		}
+		s.ingested.With(prometheus.Labels{RESULT_LABEL: TimingResultAccepted}).Inc()
+	}
+	return nil
+}
+
+// completed observes the milestones posted so far for the PipelineRun, and keeps it for those posted later
+func (s *timingStore) completed(pr *v1.PipelineRun) {
+	if pr.Status.CompletionTime == nil {
+		return
+	}
+	run := completedRun{namespace: pr.Namespace, created: pr.CreationTimestamp.Time, completed: pr.Status.CompletionTime.Time}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.runs[pr.UID]; ok {
+		return
+	}
+	for milestone, timestamp := range s.milestones[pr.UID] {
+		s.observe(run, milestone, timestamp)
+	}
+	s.runs[pr.UID] = run
+	s.updated[string(pr.UID)] = exporterClock.Now()
+}
+
+func (s *timingStore) prune(now time.Time) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, key := range evictKeys(StoreTimings, s.updated, now) {
+		delete(s.milestones, types.UID(key))
+		delete(s.runs, types.UID(key))
+		delete(s.updated, key)
+	}
+}
+
+// ServeHTTP takes a JSON array of milestones, rejecting all of them when any is invalid
+func (s *timingStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	milestones := []TimingMilestone{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTimingBody)).Decode(&milestones); err != nil {
+		http.Error(w, "the body must be a JSON array of milestones: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.add(milestones); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// timingIngestFilter hands the PipelineRuns to the timing store as they complete; nothing is reconciled
+type timingIngestFilter struct {
+	store *timingStore
+}
+
+func (f *timingIngestFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *timingIngestFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *timingIngestFilter) Update(e event.UpdateEvent) bool {
+	oldPR, okold := e.ObjectOld.(*v1.PipelineRun)
+	newPR, oknew := e.ObjectNew.(*v1.PipelineRun)
+	if !okold || !oknew {
+		return false
+	}
+	if !oldPR.IsDone() && newPR.IsDone() {
+		f.store.completed(newPR)
+	}
+	return false
+}
+
+func (f *timingIngestFilter) Generic(event.GenericEvent) bool {
+	return false
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func postTimings(h http.Handler, method, body string) int {
+	r := httptest.NewRequest(method, IngestTimingsPath, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestTimingIngest(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	store := newTimingStore(NewExtendedDurationMetric(registry), NewIngestedTimingsMetric(registry))
+	filter := &timingIngestFilter{store: store}
+	created := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, postTimings(store, http.MethodGet, ""))
+	assert.Equal(t, http.StatusBadRequest, postTimings(store, http.MethodPost, `{"uid": "1"}`))
+	assert.Equal(t, http.StatusBadRequest, postTimings(store, http.MethodPost, `[{"uid": "1", "milestone": "Not A Label", "timestamp": "2024-01-02T14:59:00Z"}]`))
+	// the names of a rejected post are not held against the limit
+	assert.Equal(t, http.StatusBadRequest, postTimings(store, http.MethodPost, `[{"uid": "1", "milestone": "build-queued", "timestamp": "2024-01-02T14:59:00Z"}, {"uid": "", "milestone": "build-queued", "timestamp": "2024-01-02T14:59:00Z"}]`))
+	assert.Empty(t, store.names)
+	assert.Equal(t, http.StatusAccepted, postTimings(store, http.MethodPost, `[{"uid": "1", "milestone": "pac-webhook-received", "timestamp": "2024-01-02T14:59:30Z"}]`))
+	// a retried post is not observed twice
+	assert.Equal(t, http.StatusAccepted, postTimings(store, http.MethodPost, `[{"uid": "1", "milestone": "pac-webhook-received", "timestamp": "2024-01-02T14:59:30Z"}]`))
+	validateCounterVec(t, store.ingested, prometheus.Labels{RESULT_LABEL: TimingResultRejected}, float64(3))
+	validateCounterVec(t, store.ingested, prometheus.Labels{RESULT_LABEL: TimingResultAccepted}, float64(1))
+	validateCounterVec(t, store.ingested, prometheus.Labels{RESULT_LABEL: TimingResultDuplicate}, float64(1))
+
+	running := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1", UID: "1", CreationTimestamp: metav1.NewTime(created)}}
+	done := running.DeepCopy()
+	done.Status.CompletionTime = &metav1.Time{Time: created.Add(2 * time.Minute)}
+	done.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: running, ObjectNew: done}))
+
+	// the milestones after the completion are observed as they are posted
+	assert.Equal(t, http.StatusAccepted, postTimings(store, http.MethodPost, `[{"uid": "1", "milestone": "release-done", "timestamp": "2024-01-02T15:05:00Z"}]`))
+	assert.Equal(t, http.StatusAccepted, postTimings(store, http.MethodPost, `[{"uid": "1", "milestone": "release-done", "timestamp": "2024-01-02T15:05:00Z"}]`))
+
+	extended := func(milestone string) *dto.Histogram {
+		observer, err := store.extended.GetMetricWith(prometheus.Labels{NS_LABEL: "test-namespace", MILESTONE_LABEL: milestone})
+		assert.NoError(t, err)
+		m := &dto.Metric{}
+		assert.NoError(t, observer.(prometheus.Histogram).Write(m))
+		return m.GetHistogram()
+	}
+	assert.Equal(t, uint64(1), extended("pac-webhook-received").GetSampleCount())
+	assert.Equal(t, float64(150000), extended("pac-webhook-received").GetSampleSum())
+	assert.Equal(t, uint64(1), extended("release-done").GetSampleCount())
+	assert.Equal(t, float64(300000), extended("release-done").GetSampleSum())
+
+	store.prune(exporterClock.Now().Add(DefaultStoreTTL + time.Minute))
+	assert.Empty(t, store.runs)
+	assert.Empty(t, store.milestones)
+}
+
+func TestTimingIngestLeaderOnly(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	store := newTimingStore(NewExtendedDurationMetric(registry), NewIngestedTimingsMetric(registry))
+	elected := make(chan struct{})
+	h := leaderOnly(elected, store)
+	body := `[{"uid": "1", "milestone": "pac-webhook-received", "timestamp": "2024-01-02T14:59:30Z"}]`
+
+	// the other replicas would take the milestones without ever seeing the PipelineRuns complete
+	assert.Equal(t, http.StatusServiceUnavailable, postTimings(h, http.MethodPost, body))
+	assert.Empty(t, store.milestones)
+	close(elected)
+	assert.Equal(t, http.StatusAccepted, postTimings(h, http.MethodPost, body))
+}
@@ -20,19 +20,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	"github.com/tektoncd/pipeline/pkg/pod"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 	"knative.dev/pkg/apis"
-	"os"
-	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -45,9 +48,43 @@ const (
 	STATUS_LABEL      = "status"
 	SUCCEEDED         = "succeded"
 	FAILED            = "failed"
+	CANCELLED         = "cancelled"
+	TIMED_OUT         = "timedout"
+	STOPPED           = "stopped"
 	THROTTLED_LABEL   = "pipelineservice.appstudio.io/throttled"
+
+	// ThrottleLabelServerSideApplyEnvName switches the throttle label patch from a merge patch to server side apply
+	ThrottleLabelServerSideApplyEnvName = "THROTTLE_LABEL_SERVER_SIDE_APPLY"
+	// ReasonStatusEnvName opts in to cancelled, timed out, and stopped runs getting their own status label values;
+	// by default they keep the failed status, for compatibility with existing dashboards and alerts
+	ReasonStatusEnvName = "REASON_STATUS_LABELS_ENABLED"
+	exporterFieldOwner  = "pipeline-service-exporter"
+
+	REASON_LABEL           = "reason"
+	GapAbortGetFailed      = "get-failed"
+	GapAbortOwnerMismatch  = "owner-mismatch"
+	GapAbortTaskRunDeleted = "taskrun-deleted"
 )
 
+// runStatus provides the status label value for a completed PipelineRun or TaskRun from its succeeded condition
+func runStatus(succeedCondition *apis.Condition) string {
+	if !succeedCondition.IsFalse() {
+		return SUCCEEDED
+	}
+	if !settings.ReasonStatusLabels {
+		return FAILED
+	}
+	switch succeedCondition.Reason {
+	case v1.PipelineRunReasonCancelled.String(), v1.PipelineRunReasonCancelledRunningFinally.String(), v1.TaskRunReasonCancelled.String():
+		return CANCELLED
+	case v1.PipelineRunReasonTimedOut.String(), v1.TaskRunReasonTimedOut.String():
+		return TIMED_OUT
+	case v1.PipelineRunReasonStoppedRunningFinally.String():
+		return STOPPED
+	}
+	return FAILED
+}
+
 func pipelineRunPipelineRef(pr *v1.PipelineRun) string {
 	val := ""
 	ref := pr.Spec.PipelineRef
@@ -89,81 +126,81 @@ func taskRef(labels map[string]string) string {
 	return ""
 }
 
-func optionalMetricEnabled(envVarName string) bool {
-	env := os.Getenv(envVarName)
-	enabled := len(env) > 0
-	// any random setting means true
-	if enabled {
-		// allow for users to turn off by setting to false
-		bv, err := strconv.ParseBool(env)
-		if err == nil && !bv {
-			return false
-		}
-		return true
-	}
-	return false
-}
-
 const PodCreateFilterEnvName = "POD_CREATE_METRIC_NAMESPACE_FILTER"
 
-func podCreateNameSpaceFilter() map[string]struct{} {
-	namespaceFilter := map[string]struct{}{}
-	env := os.Getenv(PodCreateFilterEnvName)
-	namespaces := strings.Split(env, ",")
-	for _, ns := range namespaces {
-		namespaceFilter[ns] = struct{}{}
-	}
-	return namespaceFilter
-}
-
 const PipelineRunKickoffFilterEnvName = "PIPELINERUN_KICKOFF_METRIC_NAMESPACE_FILTER"
 
-func pipelineRunKickoffNameSpaceFilter() map[string]struct{} {
+const (
+	// TriggerTimeAnnotationsEnvName is a comma separated list of annotations, in addition to TRIGGER_TIME_ANNOTATION,
+	// holding when the external trigger of a PipelineRun, like the webhook of a push, was received
+	TriggerTimeAnnotationsEnvName = "PIPELINERUN_TRIGGER_TIME_ANNOTATIONS"
+	// TRIGGER_TIME_ANNOTATION is for the trigger templates to set to the time they received the event, as Pipelines as
+	// Code does not record it on the PipelineRun, nor Tekton Triggers unless its event IDs are time based
+	TRIGGER_TIME_ANNOTATION = "pipelineservice.appstudio.io/trigger-time"
+	// TRIGGERS_EVENT_ID_LABEL is the ID of the event Tekton Triggers created the PipelineRun for, which has the time the
+	// event was received when it is a time based UUID
+	TRIGGERS_EVENT_ID_LABEL = "triggers.tekton.dev/triggers-eventid"
+)
+
+func namespaceFilter(namespaces []string) map[string]struct{} {
 	namespaceFilter := map[string]struct{}{}
-	env := os.Getenv(PipelineRunKickoffFilterEnvName)
-	namespaces := strings.Split(env, ",")
 	for _, ns := range namespaces {
 		namespaceFilter[ns] = struct{}{}
 	}
 	return namespaceFilter
 }
 
-func calculateScheduledDuration(created, started time.Time) float64 {
-	if created.IsZero() || started.IsZero() {
-		return 0
+const (
+	ResolvingPipelineRefReasonsEnvName = "RESOLVING_PIPELINE_REF_REASONS"
+	ResolvingTaskRefReasonsEnvName     = "RESOLVING_TASK_REF_REASONS"
+	// at our current tekton version, the PipelineRun constant is in the reconciler package vs. the api package
+	ReasonResolvingPipelineRef = "ResolvingPipelineRef"
+)
+
+// resolvingReasons is the default reason plus any configured ones, so that a tekton upgrade renaming the reason can
+// be handled with configuration until we bump our tekton dependency
+func resolvingReasons(configured []string, defaultReason string) map[string]struct{} {
+	reasons := map[string]struct{}{defaultReason: {}}
+	for _, reason := range configured {
+		reason = strings.TrimSpace(reason)
+		if len(reason) > 0 {
+			reasons[reason] = struct{}{}
+		}
 	}
-	return float64(started.Sub(created).Milliseconds())
+	return reasons
 }
 
-func skipPipelineRun(pr *v1.PipelineRun) bool {
-	if len(pr.Status.ChildReferences) < 1 {
-		return true
+var unknownResolvingReasons sync.Map
+
+// isResolvingReason checks the reason against reasons, or just the default reason when reasons is nil; so we notice
+// when tekton starts using a reason we do not know about, any other "Resolving" reason is logged the first time it is seen;
+// the StepAction reason has its own metric
+func isResolvingReason(reasons map[string]struct{}, defaultReason, reason string) bool {
+	known := reason == defaultReason
+	if reasons != nil {
+		_, known = reasons[reason]
 	}
-	// in case there are gaps between a pipelinerun being marked done but the complete timestamp is not set, with the
-	// understanding that the complete timestamp is not processed before any completed taskrun complete timestamps have been processed
-	if pr.Status.CompletionTime == nil {
-		return true
+	if !known && strings.HasPrefix(reason, "Resolving") && reason != TaskRunReasonResolvingStepActionRef {
+		if _, seen := unknownResolvingReasons.LoadOrStore(reason, struct{}{}); !seen {
+			controllerLog.Info(fmt.Sprintf("WARNING: condition reason %s is not one of the configured resolving reasons, see %s and %s",
+				reason, ResolvingPipelineRefReasonsEnvName, ResolvingTaskRefReasonsEnvName))
+		}
 	}
+	return known
+}
 
-	// we've seen a few times now that quota/node throttling artificially inflates our execution overhead,
-	// vs. concurrency contention in our controller;
-	// with our separate throttle metrics, we can alert on those if need be; our controller runtime filter
-	// should prevent a Reconcile when this label is set, but just in case, let's check here as well
-	trName, throttled := pr.Labels[THROTTLED_LABEL]
-	if throttled {
-		ctrl.Log.Info(fmt.Sprintf("Skipping overhead for pipelinerun %s:%s because taskrun %s was throttled", pr.Namespace, pr.Name, trName))
-		return true
+func calculateScheduledDuration(created, started time.Time) float64 {
+	if created.IsZero() || started.IsZero() {
+		return 0
 	}
-	return false
+	return float64(started.Sub(created).Milliseconds())
 }
 
-func sortTaskRunsForGapCalculations(pr *v1.PipelineRun, oc client.Client, ctx context.Context) ([]*v1.TaskRun, []*v1.TaskRun, bool) {
-	sortedTaskRunsByCreateTimes := []*v1.TaskRun{}
-	reverseOrderSortedTaskRunsByCompletionTimes := []*v1.TaskRun{}
-	// prior testing in staging proved that with enough concurrency, this array is minimally not sorted based on when
-	// the task runs were created, so we explicitly sort for that; also, this sorting will allow us to effectively
-	// address parallel taskruns vs. taskrun dependencies and ordering (where tekton does not create a taskrun until its dependencies
-	// have completed).
+// sortTaskRunsForGapCalculations aborts if any referenced TaskRun cannot be retrieved or does not belong to the PipelineRun,
+// returning the reason for the abort, or the empty string if it did not abort; gapAborts counts those aborts by reason,
+// and may be nil for callers that leave the counting to the gap reconcile
+func sortTaskRunsForGapCalculations(pr *v1.PipelineRun, oc client.Client, ctx context.Context, gapAborts *prometheus.CounterVec) ([]*v1.TaskRun, []*v1.TaskRun, string) {
+	taskRuns := []*v1.TaskRun{}
 	for _, kidRef := range pr.Status.ChildReferences {
 		if kidRef.Kind != "TaskRun" {
 			continue
@@ -171,10 +208,33 @@ func sortTaskRunsForGapCalculations(pr *v1.PipelineRun, oc client.Client, ctx co
 		kid := &v1.TaskRun{}
 		err := oc.Get(ctx, types.NamespacedName{Namespace: pr.Namespace, Name: kidRef.Name}, kid)
 		if err != nil {
-			ctrl.Log.Info(fmt.Sprintf("could not calculate gap for taskrun %s:%s: %s", pr.Namespace, kidRef.Name, err.Error()))
-			return nil, nil, true
+			controllerLog.Info(fmt.Sprintf("could not calculate gap for taskrun %s:%s: %s", pr.Namespace, kidRef.Name, err.Error()))
+			reason := GapAbortGetFailed
+			// pruned before we got to it
+			if errors.IsNotFound(err) {
+				reason = GapAbortTaskRunDeleted
+			}
+			bumpGapAbort(gapAborts, pr, reason)
+			return nil, nil, reason
+		}
+		// a TaskRun of the same name from a prior, deleted PipelineRun of the same name would give us bogus gaps
+		if !ownedByPipelineRun(kid, pr) {
+			controllerLog.Info(fmt.Sprintf("could not calculate gap for taskrun %s:%s: it is not owned by pipelinerun %s", pr.Namespace, kidRef.Name, pr.Name))
+			bumpGapAbort(gapAborts, pr, GapAbortOwnerMismatch)
+			return nil, nil, GapAbortOwnerMismatch
 		}
 
+		taskRuns = append(taskRuns, kid)
+	}
+	sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes := sortTaskRuns(taskRuns)
+	return sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes, ""
+}
+
+// sortTaskRuns orders the TaskRuns by creation, and the completed ones among them by completion, latest first
+func sortTaskRuns(taskRuns []*v1.TaskRun) ([]*v1.TaskRun, []*v1.TaskRun) {
+	sortedTaskRunsByCreateTimes := []*v1.TaskRun{}
+	reverseOrderSortedTaskRunsByCompletionTimes := []*v1.TaskRun{}
+	for _, kid := range taskRuns {
 		sortedTaskRunsByCreateTimes = append(sortedTaskRunsByCreateTimes, kid)
 		// don't add taskruns that did not complete i.e. presumably timed out of failed; any taskruns that dependended
 		// on should not have even been created
@@ -183,13 +243,59 @@ func sortTaskRunsForGapCalculations(pr *v1.PipelineRun, oc client.Client, ctx co
 
 		}
 	}
+	// prior testing in staging proved that with enough concurrency, this array is minimally not sorted based on when
+	// the task runs were created, so we explicitly sort for that; also, this sorting will allow us to effectively
+	// address parallel taskruns vs. taskrun dependencies and ordering (where tekton does not create a taskrun until its dependencies
+	// have completed).
+	// creation timestamps only have second granularity, so ties are common, and are broken by start time, then name,
+	// so the same gaps are computed regardless of the order of the child references
 	sort.SliceStable(sortedTaskRunsByCreateTimes, func(i, j int) bool {
-		return sortedTaskRunsByCreateTimes[i].CreationTimestamp.Time.Before(sortedTaskRunsByCreateTimes[j].CreationTimestamp.Time)
+		return taskRunCreatedBefore(sortedTaskRunsByCreateTimes[i], sortedTaskRunsByCreateTimes[j])
 	})
 	sort.SliceStable(reverseOrderSortedTaskRunsByCompletionTimes, func(i, j int) bool {
-		return reverseOrderSortedTaskRunsByCompletionTimes[i].Status.CompletionTime.Time.After(reverseOrderSortedTaskRunsByCompletionTimes[j].Status.CompletionTime.Time)
+		a, b := reverseOrderSortedTaskRunsByCompletionTimes[i], reverseOrderSortedTaskRunsByCompletionTimes[j]
+		if !a.Status.CompletionTime.Time.Equal(b.Status.CompletionTime.Time) {
+			return a.Status.CompletionTime.Time.After(b.Status.CompletionTime.Time)
+		}
+		return a.Name > b.Name
 	})
-	return sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes, false
+	return sortedTaskRunsByCreateTimes, reverseOrderSortedTaskRunsByCompletionTimes
+}
+
+func taskRunCreatedBefore(a, b *v1.TaskRun) bool {
+	if !a.CreationTimestamp.Time.Equal(b.CreationTimestamp.Time) {
+		return a.CreationTimestamp.Time.Before(b.CreationTimestamp.Time)
+	}
+	aStarted, bStarted := a.Status.StartTime != nil, b.Status.StartTime != nil
+	switch {
+	case aStarted && bStarted && !a.Status.StartTime.Time.Equal(b.Status.StartTime.Time):
+		return a.Status.StartTime.Time.Before(b.Status.StartTime.Time)
+	case aStarted && !bStarted:
+		return true
+	case !aStarted && bStarted:
+		return false
+	}
+	return a.Name < b.Name
+}
+
+// ownedByPipelineRun is lenient when UIDs are not available, like with PipelineRuns that have not been persisted
+func ownedByPipelineRun(tr *v1.TaskRun, pr *v1.PipelineRun) bool {
+	if len(pr.UID) == 0 {
+		return true
+	}
+	for _, ref := range tr.OwnerReferences {
+		if ref.Kind == "PipelineRun" {
+			return ref.UID == pr.UID
+		}
+	}
+	return len(tr.OwnerReferences) == 0
+}
+
+func bumpGapAbort(gapAborts *prometheus.CounterVec, pr *v1.PipelineRun, reason string) {
+	if gapAborts == nil {
+		return
+	}
+	gapAborts.With(withControlPlaneLabel(map[string]string{NS_LABEL: pr.Namespace, REASON_LABEL: reason}, pr.Namespace)).Inc()
 }
 
 func isPipelineRunThrottled(pr *v1.PipelineRun, oc client.Client, ctx context.Context) (bool, string, error) {
@@ -203,7 +309,7 @@ func isPipelineRunThrottled(pr *v1.PipelineRun, oc client.Client, ctx context.Co
 		kid := &v1.TaskRun{}
 		err = oc.Get(ctx, types.NamespacedName{Namespace: pr.Namespace, Name: kidRef.Name}, kid)
 		if err != nil && !errors.IsNotFound(err) {
-			ctrl.Log.Info(fmt.Sprintf("could not get taskrun %s:%s: %s", pr.Namespace, kidRef.Name, err.Error()))
+			controllerLog.Info(fmt.Sprintf("could not get taskrun %s:%s: %s", pr.Namespace, kidRef.Name, err.Error()))
 			return false, "", err
 		}
 		succeedCondition := kid.Status.GetCondition(apis.ConditionSucceeded)
@@ -233,7 +339,7 @@ func isPipelineRunGoing(pr *v1.PipelineRun, oc client.Client, ctx context.Contex
 	return false
 }
 
-func tagPipelineRunsWithTaskRunsGettingThrottled(pr *v1.PipelineRun, oc client.Client, ctx context.Context) error {
+func tagPipelineRunsWithTaskRunsGettingThrottled(pr *v1.PipelineRun, oc client.Client, ctx context.Context, patchFailures *prometheus.CounterVec) error {
 	throttled, throttledTaskRun, err := isPipelineRunThrottled(pr, oc, ctx)
 	if err != nil {
 		return err
@@ -244,136 +350,103 @@ func tagPipelineRunsWithTaskRunsGettingThrottled(pr *v1.PipelineRun, oc client.C
 	}
 	_, previouslyLabelled := pr.Labels[THROTTLED_LABEL]
 	if throttled && !previouslyLabelled {
-		changedPR := pr.DeepCopy()
-		if changedPR.Labels == nil {
-			changedPR.Labels = map[string]string{}
+		controllerLog.Info(fmt.Sprintf("Tagging PipelineRun %s:%s as throttled because of %s", pr.Namespace, pr.Name, throttledTaskRun))
+		err = retry.OnError(retry.DefaultBackoff, retriablePatchError, func() error {
+			return patchThrottledLabel(ctx, oc, pr, throttledTaskRun)
+		})
+		if err == nil || errors.IsNotFound(err) {
+			return nil
 		}
-		changedPR.Labels[THROTTLED_LABEL] = throttledTaskRun
-		ctrl.Log.Info(fmt.Sprintf("Tagging PipelineRun %s:%s as throttled because of %s", pr.Namespace, pr.Name, throttledTaskRun))
-		err = oc.Patch(ctx, changedPR, client.MergeFrom(pr))
-		if err != nil && errors.IsNotFound(err) {
+		// over the write budget is not a failure, the label is patched once the PipelineRun is requeued, so we
+		// only remember it in memory meanwhile
+		if writeBudgetExceeded(err) {
+			controllerLog.V(4).Info(fmt.Sprintf("deferring the throttled label of PipelineRun %s:%s, as the API write budget is exhausted", pr.Namespace, pr.Name))
+			inMemoryThrottles.mark(pr, throttledTaskRun)
 			return err
 		}
+		// a lost label means the overhead of this PipelineRun gets counted, so we at least remember it in memory,
+		// and return the error so the Reconcile is retried
+		controllerLog.Info(fmt.Sprintf("could not tag PipelineRun %s:%s as throttled: %s", pr.Namespace, pr.Name, err.Error()))
+		patchFailures.With(map[string]string{NS_LABEL: pr.Namespace}).Inc()
+		inMemoryThrottles.mark(pr, throttledTaskRun)
+		return err
 	}
 	return nil
 }
 
-type GapEntry struct {
-	status    string
-	pipeline  string
-	completed string
-	upcoming  string
-	gap       float64
+func retriablePatchError(err error) bool {
+	return errors.IsConflict(err) ||
+		errors.IsServerTimeout(err) ||
+		errors.IsTimeout(err) ||
+		errors.IsTooManyRequests(err) ||
+		errors.IsServiceUnavailable(err) ||
+		errors.IsInternalError(err)
 }
 
-func calculateGaps(ctx context.Context, pr *v1.PipelineRun, oc client.Client, sortedTaskRunsByCreateTimes []*v1.TaskRun, reverseOrderSortedTaskRunsByCompletionTimes []*v1.TaskRun) []GapEntry {
-	gapEntries := []GapEntry{}
-	prRef := pipelineRunPipelineRef(pr)
-	for index, tr := range sortedTaskRunsByCreateTimes {
-		succeedCondition := pr.Status.GetCondition(apis.ConditionSucceeded)
-		if succeedCondition == nil {
-			ctrl.Log.Info(fmt.Sprintf("WARNING: pipielinerun %s:%s marked done but has nil succeed condition", pr.Namespace, pr.Name))
-			continue
-		}
-		if succeedCondition.IsUnknown() {
-			ctrl.Log.Info(fmt.Sprintf("WARNING: pipielinerun %s:%s marked done but has unknown succeed condition", pr.Namespace, pr.Name))
-			continue
-		}
-		gapEntry := GapEntry{}
-		status := SUCCEEDED
-		if succeedCondition.IsFalse() {
-			status = FAILED
+func patchThrottledLabel(ctx context.Context, oc client.Client, pr *v1.PipelineRun, throttledTaskRun string) error {
+	if settings.ThrottleLabelServerSideApply {
+		// we only own the one label, and apply it to whichever API version we are watching
+		applied := &unstructured.Unstructured{}
+		applied.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("PipelineRun"))
+		if watchV1Beta1() {
+			applied.SetGroupVersionKind(v1beta1.SchemeGroupVersion.WithKind("PipelineRun"))
 		}
-		gapEntry.status = status
-		gapEntry.pipeline = prRef
-
-		if index == 0 {
-			// our first task is simple, just work off of the pipelinerun
-			gapEntry.gap = float64(tr.CreationTimestamp.Time.Sub(pr.CreationTimestamp.Time).Milliseconds())
-			gapEntry.completed = prRef
-			gapEntry.upcoming = taskRef(tr.Labels)
-			gapEntries = append(gapEntries, gapEntry)
-			ctrl.Log.V(6).Info(fmt.Sprintf("first task %s for pipeline %s has gap %v", taskRef(tr.Labels), prRef, gapEntry.gap))
-			continue
-		}
-
-		firstKid := sortedTaskRunsByCreateTimes[0]
-
-		// so using the first taskrun completion time addresses sequential / chaining dependencies;
-		// for parallel, if the first taskrun's completion time is not after this taskrun's create time,
-		// that means parallel taskruns, and we work off of the pipelinerun; NOTE: this focuses on "top level" parallel task runs
-		// with absolutely no dependencies.  Once any sort of dependency is established, there are no more top level parallel taskruns.
-		if firstKid.Status.CompletionTime != nil && firstKid.Status.CompletionTime.Time.After(tr.CreationTimestamp.Time) {
-			ctrl.Log.V(4).Info(fmt.Sprintf("task %s considered parallel for pipeline %s", taskRef(tr.Labels), prRef))
-			gapEntry.gap = float64(tr.CreationTimestamp.Time.Sub(pr.CreationTimestamp.Time).Milliseconds())
-			gapEntry.completed = prRef
-			gapEntry.upcoming = taskRef(tr.Labels)
-			gapEntries = append(gapEntries, gapEntry)
-			continue
-		}
-
-		// Conversely, task run chains can run in parallel, and a taskrun can depend on multiple chains or threads of taskruns. We want to find the chain
-		// that finished last, but before we are created.  We traverse through our reverse sorted on completion time list to determine that.  But yes, we don't reproduce the DAG
-		// graph (there is no clean dependency import path in tekton for that) to confirm the edges.  This approximation is sufficient.
-
-		// get whatever completed first
-		timeToCalculateWith := time.Time{}
-		trToCalculateWith := &v1.TaskRun{}
-		completedID := prRef
-		if len(reverseOrderSortedTaskRunsByCompletionTimes) > 0 {
-			trToCalculateWith = reverseOrderSortedTaskRunsByCompletionTimes[len(reverseOrderSortedTaskRunsByCompletionTimes)-1]
-			completedID = taskRef(trToCalculateWith.Labels)
-			timeToCalculateWith = trToCalculateWith.Status.CompletionTime.Time
-		} else {
-			// if no taskruns completed, that means any taskruns created were created as part of the initial pipelinerun creation,
-			// so use the pipelinerun creation time
-			timeToCalculateWith = pr.CreationTimestamp.Time
-		}
-		for _, tr2 := range reverseOrderSortedTaskRunsByCompletionTimes {
-			if tr2.Name == tr.Name {
-				continue
-			}
-			ctrl.Log.V(8).Info(fmt.Sprintf("comparing candidate %s to current task %s", taskRef(tr2.Labels), taskRef(tr.Labels)))
-			if !tr2.Status.CompletionTime.Time.After(tr.CreationTimestamp.Time) {
-				ctrl.Log.V(8).Info(fmt.Sprintf("%s did not complete after so use it to compute gap for current task %s", taskRef(tr2.Labels), taskRef(tr.Labels)))
-				trToCalculateWith = tr2
-				completedID = taskRef(trToCalculateWith.Labels)
-				timeToCalculateWith = tr2.Status.CompletionTime.Time
-				break
-			}
-			ctrl.Log.V(8).Info(fmt.Sprintf("skipping %s as a gap candidate for current task %s is OK", taskRef(tr2.Labels), taskRef(tr.Labels)))
-		}
-		gapEntry.gap = float64(tr.CreationTimestamp.Time.Sub(timeToCalculateWith).Milliseconds())
-		gapEntry.completed = completedID
-		gapEntry.upcoming = taskRef(tr.Labels)
-		ctrl.Log.V(6).Info(fmt.Sprintf("gap entry completed %s upcoming %s gap %v", gapEntry.completed, gapEntry.upcoming, gapEntry.gap))
-		gapEntries = append(gapEntries, gapEntry)
+		applied.SetNamespace(pr.Namespace)
+		applied.SetName(pr.Name)
+		applied.SetLabels(map[string]string{THROTTLED_LABEL: throttledTaskRun})
+		return oc.Patch(ctx, applied, client.Apply, client.FieldOwner(exporterFieldOwner), client.ForceOwnership)
 	}
-	return gapEntries
+	// a plain merge patch of only our label, vs. an optimistic lock, which conflicts with the status updates the Tekton
+	// controller keeps making to a running PipelineRun, and needs another get on each retry
+	data, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{THROTTLED_LABEL: throttledTaskRun},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	patched := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: pr.Namespace, Name: pr.Name}}
+	return oc.Patch(ctx, patched, client.RawPatch(types.MergePatchType, data))
 }
 
+// The outcomes of filter; only FilterOutcomeShortDuration filters the overhead out, but a zero overhead is told
+// apart from the other recorded ones, as it is the other half of the filter's condition
+const (
+	FilterOutcomeShortDuration = "short-duration"
+	FilterOutcomeZeroOverhead  = "zero-overhead"
+	FilterOutcomeRecorded      = "recorded"
+)
+
 func filter(numerator, denominator float64) bool {
-	threshold := DEFAULT_THRESHOLD
-	thresholdStr := os.Getenv(FILTER_THRESHOLD)
-	if len(thresholdStr) > 0 {
-		thresholdOverride, err := strconv.ParseFloat(thresholdStr, 64)
-		if err != nil {
-			ctrl.Log.V(6).Info(fmt.Sprintf("error parsing %s env of %s: %s", FILTER_THRESHOLD, thresholdStr, err.Error()))
-		} else {
-			threshold = thresholdOverride
-		}
+	return filterAt(numerator, denominator, settings.EffectiveFilterThreshold())
+}
+
+// filterAt is filter with a threshold other than the configured one, where 0 filters nothing
+func filterAt(numerator, denominator, threshold float64) bool {
+	return filterOutcomeAt(numerator, denominator, threshold) == FilterOutcomeShortDuration
+}
+
+// filterOutcome is which of the FilterOutcome constants filter hits for an overhead
+func filterOutcome(numerator, denominator float64) string {
+	return filterOutcomeAt(numerator, denominator, settings.EffectiveFilterThreshold())
+}
+
+func filterOutcomeAt(numerator, denominator, threshold float64) string {
+	if numerator <= 0 {
+		return FilterOutcomeZeroOverhead
 	}
 	// if overhead is non-zero, but total duration is less that 40 seconds,
 	// this is a simpler, most likely user defined pipeline which does not fall
 	// under our image building based overhead concerns
-	if numerator > 0 && denominator < threshold {
-		return true
+	if denominator < threshold {
+		return FilterOutcomeShortDuration
 	}
 	//TODO we don't have a sense for it yet, but at some point we may get an idea
 	// of what is unacceptable overhead regardless of total duration, where we don't
 	// try to mitigate the tekton controller and user pipelineruns sharing the same
 	// cluster resources
-	return false
+	return FilterOutcomeRecorded
 }
 
 func createJSONFormattedString(o any) string {
@@ -414,11 +487,11 @@ func zeroOutPriorHitNamespacesThatAreNowEmpty(collector PollCollector, lastScan
 	}
 }
 
-type DeadlockDetector func() bool
-
 type DeadlockTracker struct {
-	collector         PollCollector
-	deadlocked        DeadlockDetector
+	collector  PollCollector
+	deadlocked func() bool
+	// flagged, if set, is called when an object is deadlocked for more than one scan
+	flagged           func()
 	filter            map[string]struct{}
 	flaggedNamespaces map[string]struct{}
 	lastScan          map[string]map[string]struct{}
@@ -450,6 +523,9 @@ func (d *DeadlockTracker) PerformDeadlockDetection(name, ns string) {
 		if objHitLastTime {
 			d.collector.IncCollector(ns)
 			d.flaggedNamespaces[ns] = struct{}{}
+			if d.flagged != nil {
+				d.flagged()
+			}
 		}
 	}
 
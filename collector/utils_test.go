@@ -2,6 +2,7 @@ package collector
 
 import (
 	"context"
+	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
@@ -10,24 +11,35 @@ import (
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	"github.com/tektoncd/pipeline/pkg/pod"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/clock"
+	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"testing"
+	"time"
 )
 
-func unregisterStats(r *ExporterReconcile) {
-	metrics.Registry.Unregister(r.overheadCollector.execution)
-	metrics.Registry.Unregister(r.overheadCollector.scheduling)
-	metrics.Registry.Unregister(r.prGapCollector.trGaps)
-	metrics.Registry.Unregister(r.pvcCollector.pvcThrottle)
-	metrics.Registry.Unregister(r.waitPodCollector.waitPodCreate)
+// setSettings replaces the package settings, returning a func restoring the previous ones
+func setSettings(s Settings) func() {
+	previous := settings
+	settings = s
+	return func() {
+		settings = previous
+	}
+}
 
+// setClock replaces the exporter clock, returning a func restoring the real one
+func setClock(c clock.WithTicker) func() {
+	exporterClock = c
+	return func() {
+		exporterClock = clock.RealClock{}
+	}
 }
 
 func validateHistogramVec(t *testing.T, h *prometheus.HistogramVec, labels prometheus.Labels, checkMax bool) {
@@ -73,6 +85,17 @@ func validateGaugeVec(t *testing.T, g *prometheus.GaugeVec, labels prometheus.La
 	assert.Equal(t, count, *metric.Gauge.Value)
 }
 
+func validateCounterVec(t *testing.T, c *prometheus.CounterVec, labels prometheus.Labels, count float64) {
+	counter, err := c.GetMetricWith(labels)
+	assert.NoError(t, err)
+	assert.NotNil(t, counter)
+	metric := &dto.Metric{}
+	counter.Write(metric)
+	assert.NotNil(t, metric.Counter)
+	assert.NotNil(t, metric.Counter.Value)
+	assert.Equal(t, count, *metric.Counter.Value)
+}
+
 // For now at least, we are keeping these as v1beta1 to have some element of regression testing, now that we've flipped
 // the "default" to v1.
 func pipelineRunFromActualRHTAPYaml() ([]v1beta1.PipelineRun, error) {
@@ -244,6 +267,7 @@ func TestTaskRef(t *testing.T) {
 }
 
 func TestDetectThrottledPipelineRun(t *testing.T) {
+	patchFailures := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_patch_failures_total", Help: "test"}, []string{NS_LABEL})
 	for _, test := range []struct {
 		name        string
 		expectLabel bool
@@ -461,7 +485,7 @@ func TestDetectThrottledPipelineRun(t *testing.T) {
 			err = c.Create(ctx, &tr)
 			assert.NoError(t, err)
 		}
-		err = tagPipelineRunsWithTaskRunsGettingThrottled(test.pr, c, ctx)
+		err = tagPipelineRunsWithTaskRunsGettingThrottled(test.pr, c, ctx, patchFailures)
 		assert.NoError(t, err)
 		pr := &v1.PipelineRun{}
 		err = c.Get(ctx, types.NamespacedName{Namespace: test.pr.Namespace, Name: test.pr.Name}, pr)
@@ -472,3 +496,175 @@ func TestDetectThrottledPipelineRun(t *testing.T) {
 		}
 	}
 }
+
+// flakyPatchClient fails the first patches with the given error before delegating to the real client
+type flakyPatchClient struct {
+	client.Client
+	failures int
+	err      error
+	patches  int
+}
+
+func (c *flakyPatchClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patches++
+	if c.patches <= c.failures {
+		return c.err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestTagThrottledPipelineRunRetries(t *testing.T) {
+	patchFailures := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_patch_failures_total", Help: "test"}, []string{NS_LABEL})
+	pr := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "test1", Namespace: "test1"},
+		Status: v1.PipelineRunStatus{
+			PipelineRunStatusFields: v1.PipelineRunStatusFields{
+				ChildReferences: []v1.ChildStatusReference{{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "test1"}},
+			},
+		},
+	}
+	tr := &v1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "test1", Namespace: "test1"},
+		Status: v1.TaskRunStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{
+					{Type: "Succeeded", Status: corev1.ConditionUnknown, Reason: pod.ReasonExceededResourceQuota},
+				},
+			},
+		},
+	}
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	ctx := context.TODO()
+	conflict := errors.NewConflict(v1.Resource("pipelineruns"), "test1", fmt.Errorf("the object has been modified"))
+
+	// conflicts are retried
+	c := &flakyPatchClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr.DeepCopy(), tr.DeepCopy()).Build(), failures: 2, err: conflict}
+	assert.NoError(t, tagPipelineRunsWithTaskRunsGettingThrottled(pr.DeepCopy(), c, ctx, patchFailures))
+	assert.Equal(t, 3, c.patches)
+	labelled := &v1.PipelineRun{}
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "test1", Name: "test1"}, labelled))
+	assert.Equal(t, "test1", labelled.Labels[THROTTLED_LABEL])
+
+	// once retries are exhausted, we count the failure and fall back to tracking in memory
+	c = &flakyPatchClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr.DeepCopy(), tr.DeepCopy()).Build(), failures: 100, err: conflict}
+	assert.Error(t, tagPipelineRunsWithTaskRunsGettingThrottled(pr.DeepCopy(), c, ctx, patchFailures))
+	validateCounterVec(t, patchFailures, prometheus.Labels{NS_LABEL: "test1"}, float64(1))
+	trName, throttled := inMemoryThrottles.throttledBy(pr)
+	assert.True(t, throttled)
+	assert.Equal(t, "test1", trName)
+	inMemoryThrottles.forget(pr)
+
+	// errors that are not transient are not retried
+	c = &flakyPatchClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr.DeepCopy(), tr.DeepCopy()).Build(), failures: 100,
+		err: errors.NewForbidden(v1.Resource("pipelineruns"), "test1", fmt.Errorf("forbidden"))}
+	assert.Error(t, tagPipelineRunsWithTaskRunsGettingThrottled(pr.DeepCopy(), c, ctx, patchFailures))
+	assert.Equal(t, 1, c.patches)
+	validateCounterVec(t, patchFailures, prometheus.Labels{NS_LABEL: "test1"}, float64(2))
+	inMemoryThrottles.forget(pr)
+}
+
+func TestSortTaskRunsForGapCalculationsTieBreaking(t *testing.T) {
+	gapAborts := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_gap_aborts_total", Help: "test"}, []string{NS_LABEL, REASON_LABEL})
+	created := metav1.NewTime(time.Now().Truncate(time.Second))
+	started := metav1.NewTime(created.Add(2 * time.Second))
+	startedLater := metav1.NewTime(created.Add(3 * time.Second))
+	completed := metav1.NewTime(created.Add(10 * time.Second))
+	owner := []metav1.OwnerReference{{Kind: "PipelineRun", Name: "test1", UID: "pr-uid"}}
+	trs := []*v1.TaskRun{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "test1", CreationTimestamp: created, OwnerReferences: owner},
+			Status:     v1.TaskRunStatus{TaskRunStatusFields: v1.TaskRunStatusFields{StartTime: &startedLater, CompletionTime: &completed}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "test1", CreationTimestamp: created, OwnerReferences: owner},
+			Status:     v1.TaskRunStatus{TaskRunStatusFields: v1.TaskRunStatusFields{StartTime: &started, CompletionTime: &completed}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "test1", CreationTimestamp: created, OwnerReferences: owner},
+			Status:     v1.TaskRunStatus{TaskRunStatusFields: v1.TaskRunStatusFields{StartTime: &started, CompletionTime: &completed}},
+		},
+	}
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "test1", Namespace: "test1", UID: "pr-uid"}}
+	objs := []client.Object{}
+	for _, tr := range trs {
+		objs = append(objs, tr)
+		pr.Status.ChildReferences = append(pr.Status.ChildReferences, v1.ChildStatusReference{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: tr.Name})
+	}
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	ctx := context.TODO()
+
+	byCreate, byCompletion, abortReason := sortTaskRunsForGapCalculations(pr, c, ctx, gapAborts)
+	assert.Empty(t, abortReason)
+	names := []string{}
+	for _, tr := range byCreate {
+		names = append(names, tr.Name)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+	names = []string{}
+	for _, tr := range byCompletion {
+		names = append(names, tr.Name)
+	}
+	assert.Equal(t, []string{"c", "b", "a"}, names)
+
+	// a TaskRun owned by some other PipelineRun of the same name
+	stale := &v1.TaskRun{}
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "test1", Name: "b"}, stale))
+	stale.OwnerReferences = []metav1.OwnerReference{{Kind: "PipelineRun", Name: "test1", UID: "old-pr-uid"}}
+	assert.NoError(t, c.Update(ctx, stale))
+	_, _, abortReason = sortTaskRunsForGapCalculations(pr, c, ctx, gapAborts)
+	assert.Equal(t, GapAbortOwnerMismatch, abortReason)
+	validateCounterVec(t, gapAborts, prometheus.Labels{NS_LABEL: "test1", REASON_LABEL: GapAbortOwnerMismatch}, float64(1))
+
+	pr.Status.ChildReferences = append(pr.Status.ChildReferences, v1.ChildStatusReference{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "missing"})
+	stale.OwnerReferences = owner
+	assert.NoError(t, c.Update(ctx, stale))
+	_, _, abortReason = sortTaskRunsForGapCalculations(pr, c, ctx, gapAborts)
+	assert.Equal(t, GapAbortTaskRunDeleted, abortReason)
+	validateCounterVec(t, gapAborts, prometheus.Labels{NS_LABEL: "test1", REASON_LABEL: GapAbortTaskRunDeleted}, float64(1))
+}
+
+func TestRunStatus(t *testing.T) {
+	defer setSettings(Settings{})()
+	for _, tc := range []struct {
+		name      string
+		condition *apis.Condition
+		enabled   bool
+		expected  string
+	}{
+		{name: "succeeded", condition: &apis.Condition{Status: corev1.ConditionTrue}, enabled: true, expected: SUCCEEDED},
+		{name: "failed", condition: &apis.Condition{Status: corev1.ConditionFalse, Reason: v1.PipelineRunReasonFailed.String()}, enabled: true, expected: FAILED},
+		{name: "cancelled compat", condition: &apis.Condition{Status: corev1.ConditionFalse, Reason: v1.PipelineRunReasonCancelled.String()}, expected: FAILED},
+		{name: "cancelled", condition: &apis.Condition{Status: corev1.ConditionFalse, Reason: v1.PipelineRunReasonCancelled.String()}, enabled: true, expected: CANCELLED},
+		{name: "cancelled running finally", condition: &apis.Condition{Status: corev1.ConditionFalse, Reason: v1.PipelineRunReasonCancelledRunningFinally.String()}, enabled: true, expected: CANCELLED},
+		{name: "taskrun cancelled", condition: &apis.Condition{Status: corev1.ConditionFalse, Reason: v1.TaskRunReasonCancelled.String()}, enabled: true, expected: CANCELLED},
+		{name: "pipelinerun timeout", condition: &apis.Condition{Status: corev1.ConditionFalse, Reason: v1.PipelineRunReasonTimedOut.String()}, enabled: true, expected: TIMED_OUT},
+		{name: "taskrun timeout", condition: &apis.Condition{Status: corev1.ConditionFalse, Reason: v1.TaskRunReasonTimedOut.String()}, enabled: true, expected: TIMED_OUT},
+		{name: "stopped", condition: &apis.Condition{Status: corev1.ConditionFalse, Reason: v1.PipelineRunReasonStoppedRunningFinally.String()}, enabled: true, expected: STOPPED},
+	} {
+		settings.ReasonStatusLabels = tc.enabled
+		assert.Equal(t, tc.expected, runStatus(tc.condition), tc.name)
+	}
+}
+
+func TestFilterOutcomeAt(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		numerator   float64
+		denominator float64
+		expected    string
+	}{
+		{name: "short with overhead", numerator: 1000, denominator: DEFAULT_THRESHOLD - 1, expected: FilterOutcomeShortDuration},
+		{name: "short without overhead", numerator: 0, denominator: DEFAULT_THRESHOLD - 1, expected: FilterOutcomeZeroOverhead},
+		{name: "long without overhead", numerator: 0, denominator: DEFAULT_THRESHOLD, expected: FilterOutcomeZeroOverhead},
+		{name: "long with overhead", numerator: 1000, denominator: DEFAULT_THRESHOLD, expected: FilterOutcomeRecorded},
+	} {
+		outcome := filterOutcomeAt(tc.numerator, tc.denominator, DEFAULT_THRESHOLD)
+		assert.Equal(t, tc.expected, outcome, tc.name)
+		assert.Equal(t, outcome == FilterOutcomeShortDuration, filterAt(tc.numerator, tc.denominator, DEFAULT_THRESHOLD), tc.name)
+	}
+	// a threshold of 0 filters nothing
+	assert.Equal(t, FilterOutcomeRecorded, filterOutcomeAt(1000, 1, 0))
+}
@@ -0,0 +1,237 @@
+package collector
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// servedTektonAPI holds the served versions of the PipelineRun CRD, captured once the CRD is found; it is set by the
+// deferred setup while the heartbeat and the cache audit may already be running, hence the lock
+type servedTektonAPI struct {
+	lock     sync.RWMutex
+	versions []string
+	v1beta1  bool
+}
+
+var tektonAPI = &servedTektonAPI{versions: []string{}}
+
+func (s *servedTektonAPI) set(served []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.versions = served
+	s.v1beta1 = useV1Beta1(served)
+}
+
+func (s *servedTektonAPI) served() []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.versions
+}
+
+// watchV1Beta1 is true when the cluster does not serve the v1 Tekton API, as is the case with older OpenShift
+// Pipelines releases; we then watch the v1beta1 PipelineRuns and TaskRuns, and convert them to v1, so all the metrics
+// code can stay on v1
+func watchV1Beta1() bool {
+	tektonAPI.lock.RLock()
+	defer tektonAPI.lock.RUnlock()
+	return tektonAPI.v1beta1
+}
+
+func useV1Beta1(served []string) bool {
+	v1Served, v1beta1Served := false, false
+	for _, v := range served {
+		switch v {
+		case "v1":
+			v1Served = true
+		case "v1beta1":
+			v1beta1Served = true
+		}
+	}
+	return !v1Served && v1beta1Served
+}
+
+func watchedPipelineRun() client.Object {
+	if watchV1Beta1() {
+		return &v1beta1.PipelineRun{}
+	}
+	return &v1.PipelineRun{}
+}
+
+func watchedTaskRun() client.Object {
+	if watchV1Beta1() {
+		return &v1beta1.TaskRun{}
+	}
+	return &v1.TaskRun{}
+}
+
+func convertPipelineRun(ctx context.Context, beta *v1beta1.PipelineRun, pr *v1.PipelineRun) error {
+	err := beta.ConvertTo(ctx, pr)
+	if err != nil {
+		return err
+	}
+	// ConvertTo does not carry over the type meta
+	pr.APIVersion = v1.SchemeGroupVersion.String()
+	pr.Kind = "PipelineRun"
+	return nil
+}
+
+func convertTaskRun(ctx context.Context, beta *v1beta1.TaskRun, tr *v1.TaskRun) error {
+	err := beta.ConvertTo(ctx, tr)
+	if err != nil {
+		return err
+	}
+	tr.APIVersion = v1.SchemeGroupVersion.String()
+	tr.Kind = "TaskRun"
+	return nil
+}
+
+// toV1 returns the v1 version of v1beta1 PipelineRuns and TaskRuns, and any other object as is
+func toV1(obj client.Object) client.Object {
+	ctx := context.Background()
+	switch beta := obj.(type) {
+	case *v1beta1.PipelineRun:
+		pr := &v1.PipelineRun{}
+		if err := convertPipelineRun(ctx, beta, pr); err != nil {
+			controllerLog.Error(err, "conversion of v1beta1 PipelineRun failed", "namespace", beta.Namespace, "name", beta.Name)
+			return obj
+		}
+		return pr
+	case *v1beta1.TaskRun:
+		tr := &v1.TaskRun{}
+		if err := convertTaskRun(ctx, beta, tr); err != nil {
+			controllerLog.Error(err, "conversion of v1beta1 TaskRun failed", "namespace", beta.Namespace, "name", beta.Name)
+			return obj
+		}
+		return tr
+	}
+	return obj
+}
+
+// v1beta1ConvertingFilter converts the objects in the events from the v1beta1 watches before our predicates see them
+type v1beta1ConvertingFilter struct {
+	inner predicate.Predicate
+}
+
+func (f *v1beta1ConvertingFilter) Create(e event.CreateEvent) bool {
+	e.Object = toV1(e.Object)
+	return f.inner.Create(e)
+}
+
+func (f *v1beta1ConvertingFilter) Delete(e event.DeleteEvent) bool {
+	e.Object = toV1(e.Object)
+	return f.inner.Delete(e)
+}
+
+func (f *v1beta1ConvertingFilter) Update(e event.UpdateEvent) bool {
+	e.ObjectOld = toV1(e.ObjectOld)
+	e.ObjectNew = toV1(e.ObjectNew)
+	return f.inner.Update(e)
+}
+
+func (f *v1beta1ConvertingFilter) Generic(e event.GenericEvent) bool {
+	e.Object = toV1(e.Object)
+	return f.inner.Generic(e)
+}
+
+// v1beta1FallbackClient serves gets, lists, and patches of v1 PipelineRuns and TaskRuns from their v1beta1 versions
+type v1beta1FallbackClient struct {
+	client.Client
+}
+
+func exporterClient(c client.Client) client.Client {
+	if !watchV1Beta1() {
+		return c
+	}
+	return &v1beta1FallbackClient{Client: c}
+}
+
+func (c *v1beta1FallbackClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	switch o := obj.(type) {
+	case *v1.PipelineRun:
+		beta := &v1beta1.PipelineRun{}
+		if err := c.Client.Get(ctx, key, beta, opts...); err != nil {
+			return err
+		}
+		return convertPipelineRun(ctx, beta, o)
+	case *v1.TaskRun:
+		beta := &v1beta1.TaskRun{}
+		if err := c.Client.Get(ctx, key, beta, opts...); err != nil {
+			return err
+		}
+		return convertTaskRun(ctx, beta, o)
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *v1beta1FallbackClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	switch l := list.(type) {
+	case *v1.PipelineRunList:
+		betaList := &v1beta1.PipelineRunList{}
+		if err := c.Client.List(ctx, betaList, opts...); err != nil {
+			return err
+		}
+		l.ListMeta = betaList.ListMeta
+		l.Items = []v1.PipelineRun{}
+		for i := range betaList.Items {
+			pr := v1.PipelineRun{}
+			if err := convertPipelineRun(ctx, &betaList.Items[i], &pr); err != nil {
+				return err
+			}
+			l.Items = append(l.Items, pr)
+		}
+		return nil
+	case *v1.TaskRunList:
+		betaList := &v1beta1.TaskRunList{}
+		if err := c.Client.List(ctx, betaList, opts...); err != nil {
+			return err
+		}
+		l.ListMeta = betaList.ListMeta
+		l.Items = []v1.TaskRun{}
+		for i := range betaList.Items {
+			tr := v1.TaskRun{}
+			if err := convertTaskRun(ctx, &betaList.Items[i], &tr); err != nil {
+				return err
+			}
+			l.Items = append(l.Items, tr)
+		}
+		return nil
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+// Patch only needs to handle the metadata patches we make, like labels and annotations, which are the same for both versions
+func (c *v1beta1FallbackClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	var beta client.Object
+	switch obj.(type) {
+	case *v1.PipelineRun:
+		beta = &v1beta1.PipelineRun{}
+	case *v1.TaskRun:
+		beta = &v1beta1.TaskRun{}
+	default:
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	beta.SetNamespace(obj.GetNamespace())
+	beta.SetName(obj.GetName())
+	err = c.Client.Patch(ctx, beta, client.RawPatch(patch.Type(), data), opts...)
+	if err != nil {
+		return err
+	}
+	// like the regular client, update obj with what the API server returned
+	switch o := obj.(type) {
+	case *v1.PipelineRun:
+		return convertPipelineRun(ctx, beta.(*v1beta1.PipelineRun), o)
+	case *v1.TaskRun:
+		return convertTaskRun(ctx, beta.(*v1beta1.TaskRun), o)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"testing"
+)
+
+func TestUseV1Beta1(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		served   []string
+		expected bool
+	}{
+		{name: "v1 and v1beta1", served: []string{"v1", "v1beta1"}, expected: false},
+		{name: "v1 only", served: []string{"v1"}, expected: false},
+		{name: "v1beta1 only", served: []string{"v1beta1"}, expected: true},
+		{name: "nothing", served: []string{}, expected: false},
+	} {
+		assert.Equal(t, tc.expected, useV1Beta1(tc.served), tc.name)
+	}
+}
+
+type recordingPredicate struct {
+	updated event.UpdateEvent
+}
+
+func (p *recordingPredicate) Create(event.CreateEvent) bool   { return false }
+func (p *recordingPredicate) Delete(event.DeleteEvent) bool   { return false }
+func (p *recordingPredicate) Generic(event.GenericEvent) bool { return false }
+func (p *recordingPredicate) Update(e event.UpdateEvent) bool {
+	p.updated = e
+	return true
+}
+
+func TestV1Beta1Fallback(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1beta1.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	ctx := context.TODO()
+	betaPR := &v1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"},
+		Status: v1beta1.PipelineRunStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{
+					apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue},
+				},
+			},
+			PipelineRunStatusFields: v1beta1.PipelineRunStatusFields{
+				ChildReferences: []v1beta1.ChildStatusReference{
+					{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "test-1-tr"},
+				},
+			},
+		},
+	}
+	betaTR := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1-tr"}}
+	c := &v1beta1FallbackClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(betaPR, betaTR).Build()}
+
+	pr := &v1.PipelineRun{}
+	assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "test-namespace", Name: "test-1"}, pr))
+	assert.True(t, pr.IsDone())
+	assert.Len(t, pr.Status.ChildReferences, 1)
+	assert.Equal(t, "test-1-tr", pr.Status.ChildReferences[0].Name)
+
+	trList := &v1.TaskRunList{}
+	assert.NoError(t, c.List(ctx, trList))
+	assert.Len(t, trList.Items, 1)
+
+	changed := pr.DeepCopy()
+	changed.Labels = map[string]string{THROTTLED_LABEL: "test-1-tr"}
+	assert.NoError(t, c.Patch(ctx, changed, client.MergeFrom(pr)))
+	patched := &v1beta1.PipelineRun{}
+	assert.NoError(t, c.Client.Get(ctx, types.NamespacedName{Namespace: "test-namespace", Name: "test-1"}, patched))
+	assert.Equal(t, "test-1-tr", patched.Labels[THROTTLED_LABEL])
+	assert.Equal(t, "test-1-tr", changed.Labels[THROTTLED_LABEL])
+
+	inner := &recordingPredicate{}
+	filter := &v1beta1ConvertingFilter{inner: inner}
+	assert.True(t, filter.Update(event.UpdateEvent{ObjectOld: betaPR, ObjectNew: betaPR}))
+	converted, ok := inner.updated.ObjectNew.(*v1.PipelineRun)
+	assert.True(t, ok)
+	assert.Equal(t, "test-1", converted.Name)
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	FILTER_LABEL = "filter"
+
+	SkipReasonPanic            = "panic"
+	SkipReasonMissingStartTime = "missing-start-time"
+)
+
+// skippedEventTracker counts the events a filter or reconcile could not process because the object was only
+// partially populated, say a run marked done without a start time, or because processing it panicked
+type skippedEventTracker struct {
+	lock    sync.Mutex
+	skipped *prometheus.CounterVec
+}
+
+var eventSkips = &skippedEventTracker{}
+
+func NewSkippedEventsMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	skipped := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_skipped_events_total",
+		Help: "Number of events not recorded in the metrics because the object was incomplete or processing it failed, by filter and reason",
+	}, []string{FILTER_LABEL, REASON_LABEL})
+	registerer.MustRegister(skipped)
+	return skipped
+}
+
+func (s *skippedEventTracker) enable(skipped *prometheus.CounterVec) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.skipped = skipped
+}
+
+func (s *skippedEventTracker) skip(filter, reason string, obj client.Object) {
+	ns, name := "", ""
+	if obj != nil {
+		ns, name = obj.GetNamespace(), obj.GetName()
+	}
+	limitLog(controllerLog, LogCategorySkippedEvents).Info(fmt.Sprintf("WARNING: %s skipped %s:%s: %s", filter, ns, name, reason))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.skipped == nil {
+		return
+	}
+	s.skipped.With(map[string]string{FILTER_LABEL: filter, REASON_LABEL: reason}).Inc()
+}
+
+// startTimeMissing returns true, after counting the skip, if a run marked done does not have its start time set
+func startTimeMissing(filter string, obj client.Object, startTime *metav1.Time) bool {
+	if startTime != nil {
+		return false
+	}
+	eventSkips.skip(filter, SkipReasonMissingStartTime, obj)
+	return true
+}
+
+// safeUpdate keeps one predicate panicking on an unexpected object from taking down the exporter, or from keeping the
+// remaining predicates from seeing the event
+func safeUpdate(p predicate.Predicate, e event.UpdateEvent) bool {
+	return safePredicate(p, e.ObjectNew, func() bool { return p.Update(e) })
+}
+
+// safePredicate recovers a panic of the predicate p seeing the event for obj, like safeUpdate, for any kind of event
+func safePredicate(p predicate.Predicate, obj client.Object, call func() bool) (result bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			ns, name := objectKey(obj)
+			recoveredPanics.recovered(fmt.Sprintf("%T", p), ns, name, r)
+			eventSkips.skip(fmt.Sprintf("%T", p), SkipReasonPanic, obj)
+			result = false
+		}
+	}()
+	return call()
+}
@@ -0,0 +1,202 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"testing"
+	"time"
+)
+
+type panickingPredicate struct {
+	recordingPredicate
+}
+
+func (p *panickingPredicate) Update(event.UpdateEvent) bool {
+	var pr *v1.PipelineRun
+	return pr.IsDone()
+}
+
+func enableTestSkips() *prometheus.CounterVec {
+	skipped := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_skipped_events_total", Help: "test"},
+		[]string{FILTER_LABEL, REASON_LABEL})
+	eventSkips.enable(skipped)
+	return skipped
+}
+
+func TestSafeUpdate(t *testing.T) {
+	skipped := enableTestSkips()
+	defer eventSkips.enable(nil)
+	recorder := &recordingPredicate{}
+	f := &ExporterFilter{
+		noReconcile:  []predicate.Predicate{&panickingPredicate{}},
+		yesReconcile: []predicate.Predicate{recorder},
+	}
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	assert.True(t, f.Update(event.UpdateEvent{ObjectOld: pr, ObjectNew: pr}))
+	assert.Equal(t, pr, recorder.updated.ObjectNew)
+	validateCounterVec(t, skipped, prometheus.Labels{FILTER_LABEL: "*collector.panickingPredicate", REASON_LABEL: SkipReasonPanic}, float64(1))
+}
+
+func TestStartTimeMissing(t *testing.T) {
+	skipped := enableTestSkips()
+	defer eventSkips.enable(nil)
+	metric := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_scheduled_duration", Help: "test"},
+		withTenantLabelName([]string{NS_LABEL, STATUS_LABEL}))
+	f := &startTimeEventFilter{metric: metric}
+	oldPR := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	newPR := oldPR.DeepCopy()
+	newPR.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionFalse, Reason: v1.PipelineRunReasonCancelled.String()})
+	assert.False(t, f.Update(event.UpdateEvent{ObjectOld: oldPR, ObjectNew: newPR}))
+	validateCounterVec(t, skipped, prometheus.Labels{FILTER_LABEL: "pipelinerun-scheduled", REASON_LABEL: SkipReasonMissingStartTime}, float64(1))
+	validateHistogramVecZeroCount(t, metric, prometheus.Labels{NS_LABEL: "test-namespace", STATUS_LABEL: FAILED})
+
+	// the overhead reconcile skips it as well
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	newPR.Status.ChildReferences = []v1.ChildStatusReference{{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "test-1-tr"}}
+	newPR.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newPR).Build()
+	r := buildReconciler(c, nil, nil)
+	defer r.Close()
+	_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: newPR.Namespace, Name: newPR.Name}})
+	assert.NoError(t, err)
+	validateCounterVec(t, skipped, prometheus.Labels{FILTER_LABEL: "overhead", REASON_LABEL: SkipReasonMissingStartTime}, float64(1))
+}
+
+// fuzzTime sets the timestamp when its bit is set, at an offset from base which may well be before base
+func fuzzTime(bits uint32, bit uint, base time.Time, offset int64) *metav1.Time {
+	if bits&(1<<bit) == 0 {
+		return nil
+	}
+	return &metav1.Time{Time: base.Add(time.Duration(offset) * time.Second)}
+}
+
+func fuzzCondition(bits uint32, bit uint, reason string) *apis.Condition {
+	if bits&(1<<bit) == 0 {
+		return nil
+	}
+	status := corev1.ConditionUnknown
+	switch {
+	case bits&(1<<(bit+1)) != 0:
+		status = corev1.ConditionTrue
+	case bits&(1<<(bit+2)) != 0:
+		status = corev1.ConditionFalse
+	}
+	return &apis.Condition{Type: apis.ConditionSucceeded, Status: status, Reason: reason}
+}
+
+func fuzzPipelineRun(bits uint32, offset int64, reason string) *v1.PipelineRun {
+	base := time.Now()
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	if created := fuzzTime(bits, 0, base, 0); created != nil {
+		pr.CreationTimestamp = *created
+	}
+	pr.Status.StartTime = fuzzTime(bits, 1, base, offset)
+	pr.Status.CompletionTime = fuzzTime(bits, 2, base, 2*offset)
+	if c := fuzzCondition(bits, 3, reason); c != nil {
+		pr.Status.SetCondition(c)
+	}
+	if bits&(1<<6) != 0 {
+		pr.Status.ChildReferences = []v1.ChildStatusReference{{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "test-1-tr"}}
+	}
+	if bits&(1<<7) != 0 {
+		pr.Labels = map[string]string{"tekton.dev/pipeline": reason}
+	}
+	return pr
+}
+
+func fuzzTaskRun(bits uint32, offset int64, reason string) *v1.TaskRun {
+	base := time.Now()
+	tr := &v1.TaskRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1-tr"}}
+	if created := fuzzTime(bits, 0, base, 0); created != nil {
+		tr.CreationTimestamp = *created
+	}
+	tr.Status.StartTime = fuzzTime(bits, 1, base, offset)
+	tr.Status.CompletionTime = fuzzTime(bits, 2, base, 2*offset)
+	if c := fuzzCondition(bits, 3, reason); c != nil {
+		tr.Status.SetCondition(c)
+	}
+	return tr
+}
+
+func fuzzPod(bits uint32, offset int64) *corev1.Pod {
+	base := time.Now()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1-tr-pod"}}
+	if created := fuzzTime(bits, 0, base, 0); created != nil {
+		pod.CreationTimestamp = *created
+	}
+	pod.Status.StartTime = fuzzTime(bits, 1, base, offset)
+	if bits&(1<<2) != 0 {
+		status := corev1.ContainerStatus{Name: "step-build"}
+		switch {
+		case bits&(1<<3) != 0:
+			status.State.Terminated = &corev1.ContainerStateTerminated{}
+			if finished := fuzzTime(bits, 4, base, 2*offset); finished != nil {
+				status.State.Terminated.FinishedAt = *finished
+			}
+		case bits&(1<<5) != 0:
+			status.State.Running = &corev1.ContainerStateRunning{}
+			if started := fuzzTime(bits, 4, base, 2*offset); started != nil {
+				status.State.Running.StartedAt = *started
+			}
+		}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{status}
+	}
+	return pod
+}
+
+func fuzzHistogram(name string, labelNames []string) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: "test"}, labelNames)
+}
+
+// FuzzFilterUpdate feeds partially populated PipelineRuns, TaskRuns, and Pods to the filters directly, vs. via
+// ExporterFilter, so that any panic fails the test instead of being counted as a skip
+func FuzzFilterUpdate(f *testing.F) {
+	f.Add(uint32(0), uint32(0xffff), int64(5), v1.PipelineRunReasonSuccessful.String())
+	f.Add(uint32(0), uint32(0x19), int64(0), v1.PipelineRunReasonCancelled.String())
+	f.Add(uint32(0x8), uint32(0x29), int64(-5), v1.PipelineRunReasonTimedOut.String())
+	f.Add(uint32(0x1), uint32(0x1d), int64(3600), "ResolvingPipelineRef")
+	f.Add(uint32(0x7), uint32(0x3f), int64(-3600), "")
+
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	// unregistered metrics, as nothing is validated against them
+	statusLabels := withTenantLabelName([]string{NS_LABEL, STATUS_LABEL})
+	nsLabels := []string{NS_LABEL}
+	filters := []predicate.Predicate{
+		&startTimeEventFilter{metric: fuzzHistogram("test_fuzz_pr_scheduled", statusLabels)},
+		&trStartTimeEventFilter{metric: fuzzHistogram("test_fuzz_tr_scheduled", statusLabels)},
+		&pipelineRefWaitTimeFilter{waitDuration: fuzzHistogram("test_fuzz_pr_ref_wait", nsLabels)},
+		&taskRefWaitTimeFilter{waitDuration: fuzzHistogram("test_fuzz_tr_ref_wait", nsLabels)},
+		&taskRunGapEventFilter{},
+		&overheadGapEventFilter{client: fake.NewClientBuilder().WithScheme(scheme).Build()},
+		&podCreateToCompleteFilter{duration: fuzzHistogram("test_fuzz_pod_complete", withTenantLabelName(nsLabels))},
+		&createKubeletLatencyFilter{metric: fuzzHistogram("test_fuzz_kubelet_ack", nsLabels)},
+		&kubeletContainerLatencyFilter{metric: fuzzHistogram("test_fuzz_container_start", nsLabels)},
+	}
+	f.Fuzz(func(t *testing.T, oldBits, newBits uint32, offset int64, reason string) {
+		// keep the offsets within what time.Duration can represent
+		offset = offset % (100 * 365 * 24 * 3600)
+		events := []event.UpdateEvent{
+			{ObjectOld: fuzzPipelineRun(oldBits, offset, reason), ObjectNew: fuzzPipelineRun(newBits, offset, reason)},
+			{ObjectOld: fuzzTaskRun(oldBits, offset, reason), ObjectNew: fuzzTaskRun(newBits, offset, reason)},
+			{ObjectOld: fuzzPod(oldBits, offset), ObjectNew: fuzzPod(newBits, offset)},
+		}
+		for _, e := range events {
+			for _, p := range filters {
+				p.Update(e)
+			}
+		}
+	})
+}
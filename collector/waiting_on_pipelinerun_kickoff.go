@@ -2,40 +2,160 @@ package collector
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	"knative.dev/pkg/apis"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 type WaitingOnPipelineRunKickoffCollector struct {
+	registerer             *collectorRegisterer
 	waitPipelineRunKickoff *prometheus.GaugeVec
+	// triggerToCreation is the first leg of the end to end latency, before the PipelineRun even exists
+	triggerToCreation *prometheus.HistogramVec
 }
 
-func NewWaitingOnPipelineRunKickoffCollector() *WaitingOnPipelineRunKickoffCollector {
-	labelNames := []string{NS_LABEL}
+func NewWaitingOnPipelineRunKickoffCollector(registerer prometheus.Registerer) *WaitingOnPipelineRunKickoffCollector {
+	reg := newCollectorRegisterer(registerer)
+	labelNames := withControlPlaneLabelName([]string{NS_LABEL})
 	waitPipelineRunKickoff := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "pipelinerun_kickoff_not_attempted_count",
 		Help: "Number of PipelineRuns where the Tekton Controller has yet to attempt to process its correctly defined Task specifications for multiple scan iterations",
 	}, labelNames)
+	triggerToCreation := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipelinerun_trigger_to_creation_milliseconds",
+		Help:    "Duration in milliseconds between the external trigger of a PipelineRun, like a webhook, being received and the PipelineRun creation time, for the PipelineRuns with a trigger time annotation, or a time based Tekton Triggers event ID, in the namespaces not filtered out of the PipelineRun kickoff detection.",
+		Buckets: prometheus.ExponentialBuckets(float64(100), float64(5), 6),
+	}, labelNames)
 	waitPipelineRunKickoffCollector := &WaitingOnPipelineRunKickoffCollector{
+		registerer:             reg,
 		waitPipelineRunKickoff: waitPipelineRunKickoff,
+		triggerToCreation:      triggerToCreation,
 	}
-	metrics.Registry.Register(waitPipelineRunKickoff)
+	reg.Register(waitPipelineRunKickoff)
+	reg.Register(triggerToCreation)
 	return waitPipelineRunKickoffCollector
 }
 
+// Close unregisters the metrics of the collector
+func (c *WaitingOnPipelineRunKickoffCollector) Close() {
+	c.registerer.Close()
+}
+
 func (c *WaitingOnPipelineRunKickoffCollector) IncCollector(ns string) {
-	labels := map[string]string{NS_LABEL: ns}
+	labels := withControlPlaneLabel(map[string]string{NS_LABEL: ns}, ns)
 	c.waitPipelineRunKickoff.With(labels).Inc()
 }
 
 func (c *WaitingOnPipelineRunKickoffCollector) ZeroCollector(ns string) {
-	labels := map[string]string{NS_LABEL: ns}
+	labels := withControlPlaneLabel(map[string]string{NS_LABEL: ns}, ns)
 	c.waitPipelineRunKickoff.With(labels).Set(float64(0))
 }
 
+// pipelineRunTriggerLatencyFilter observes the time from the trigger of the PipelineRuns to their creation when Tekton
+// first picks them up, setting their start time, vs. on their create events, which are replayed for all the existing
+// PipelineRuns when the exporter starts
+type pipelineRunTriggerLatencyFilter struct {
+	collector   *WaitingOnPipelineRunKickoffCollector
+	filter      map[string]struct{}
+	annotations []string
+}
+
+func newPipelineRunTriggerLatencyFilter(collector *WaitingOnPipelineRunKickoffCollector, filter map[string]struct{}) *pipelineRunTriggerLatencyFilter {
+	return &pipelineRunTriggerLatencyFilter{
+		collector:   collector,
+		filter:      filter,
+		annotations: append([]string{TRIGGER_TIME_ANNOTATION}, settings.TriggerTimeAnnotations...),
+	}
+}
+
+func (f *pipelineRunTriggerLatencyFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *pipelineRunTriggerLatencyFilter) Generic(event.GenericEvent) bool {
+	return false
+}
+
+func (f *pipelineRunTriggerLatencyFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *pipelineRunTriggerLatencyFilter) Update(e event.UpdateEvent) bool {
+	oldPR, okold := e.ObjectOld.(*v1.PipelineRun)
+	newPR, oknew := e.ObjectNew.(*v1.PipelineRun)
+	if !okold || !oknew || oldPR.Status.StartTime != nil || newPR.Status.StartTime == nil {
+		return false
+	}
+	if _, filtered := f.filter[newPR.Namespace]; filtered {
+		return false
+	}
+	triggered, annotation, ok := f.triggerTime(newPR)
+	if !ok {
+		return false
+	}
+	// the creation time has a one second resolution, so a trigger in the same second would be after it otherwise,
+	// leaving out the fastest triggers
+	delta := newPR.CreationTimestamp.Time.Sub(triggered.Truncate(time.Second))
+	if delta < 0 {
+		// the trigger and API server clocks are skewed, which would skew the histogram too
+		controllerLog.V(1).Info(fmt.Sprintf("ignoring the trigger time %s of pipelinerun %s:%s, it is in a second after its creation time",
+			triggered.Format(time.RFC3339Nano), newPR.Namespace, newPR.Name), "source", annotation)
+		return false
+	}
+	labels := withControlPlaneLabel(map[string]string{NS_LABEL: newPR.Namespace}, newPR.Namespace)
+	f.collector.triggerToCreation.With(labels).Observe(float64(delta.Milliseconds()))
+	return false
+}
+
+// triggerTime is the time of the first trigger time annotation of the PipelineRun that parses, as an RFC 3339 time,
+// or unix seconds, which is what the trigger templates have on hand, else the time of its Tekton Triggers event ID
+func (f *pipelineRunTriggerLatencyFilter) triggerTime(pr *v1.PipelineRun) (time.Time, string, bool) {
+	for _, annotation := range f.annotations {
+		value, ok := pr.Annotations[annotation]
+		if !ok {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t, annotation, true
+		}
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			return time.Unix(0, int64(seconds*float64(time.Second))), annotation, true
+		}
+		controllerLog.V(1).Info(fmt.Sprintf("ignoring the trigger time %q of pipelinerun %s:%s, it is neither an RFC 3339 time nor unix seconds",
+			value, pr.Namespace, pr.Name), "annotation", annotation)
+	}
+	if t, ok := eventIDTime(pr.Labels[TRIGGERS_EVENT_ID_LABEL]); ok {
+		return t, TRIGGERS_EVENT_ID_LABEL, true
+	}
+	return time.Time{}, "", false
+}
+
+// eventIDTime is the time an event ID was issued at, when it is a time based UUID, version 1 or 7; the random ones,
+// version 4, carry no time
+func eventIDTime(id string) (time.Time, bool) {
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch u.Version() {
+	case 1:
+		sec, nsec := u.Time().UnixTime()
+		return time.Unix(sec, nsec), true
+	case 7:
+		// the first 48 bits are the unix milliseconds
+		ms := int64(binary.BigEndian.Uint64(append([]byte{0, 0}, u[:6]...)))
+		return time.UnixMilli(ms), true
+	}
+	return time.Time{}, false
+}
+
 func (r *ExporterReconcile) resetPipelineRunKickoffStats(ctx context.Context) {
 	cacheCopy := buildLastScanCopy(r.waitPRKickoffCollector, r.waitPRKickoffCache)
 
@@ -52,8 +172,12 @@ func (r *ExporterReconcile) resetPipelineRunKickoffStats(ctx context.Context) {
 		lastScan:          cacheCopy,
 		currentScan:       r.waitPRKickoffCache,
 	}
+	remediation := r.remediations[PipelineRunKickoffDetectorName]
 	if err == nil {
 		for _, pr := range prList.Items {
+			deadlockTracker.flagged = func() {
+				remediation.remediate(ctx, r.client, &pr)
+			}
 			deadlockTracker.deadlocked = func() bool {
 				if pr.IsDone() || pr.IsCancelled() || pr.IsGracefullyCancelled() || pr.IsGracefullyStopped() || pr.IsPending() {
 					return false
@@ -82,6 +206,8 @@ func (r *ExporterReconcile) resetPipelineRunKickoffStats(ctx context.Context) {
 	} else {
 		controllerLog.Error(err, "pipeline run query for kickoff attempts failed with an error")
 	}
+	remediation.finishScan()
+	r.flaggedByDetector[PipelineRunKickoffDetectorName] = deadlockTracker.flaggedNamespaces
 
 	// if a namespace is in the cache, but not our most recent scan, zero it out too, as the namespace is either
 	// deleted or has all its PipelineRuns pruned.
@@ -2,7 +2,11 @@ package collector
 
 import (
 	"context"
+	"encoding/binary"
+	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -10,9 +14,10 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
-	"os"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -25,7 +30,7 @@ func TestPipelineRunKickoffStats(t *testing.T) {
 	_ = v1.AddToScheme(scheme)
 	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
 
-	os.Setenv(PipelineRunKickoffFilterEnvName, "test-namespace-2")
+	defer setSettings(Settings{PipelineRunKickoffNamespaceFilter: []string{"test-namespace-2"}})()
 
 	mockPipelineRuns := []*v1.PipelineRun{
 		{
@@ -217,5 +222,55 @@ func TestPipelineRunKickoffStats(t *testing.T) {
 	assert.NoError(t, err)
 	reconciler.resetPipelineRunKickoffStats(ctx)
 	validateGaugeVec(t, reconciler.waitPRKickoffCollector.waitPipelineRunKickoff, label, float64(0))
-	unregisterStats(reconciler)
+	reconciler.Close()
+}
+
+func TestPipelineRunTriggerLatencyFilter(t *testing.T) {
+	defer setSettings(Settings{TriggerTimeAnnotations: []string{"example.com/received"}})()
+	collector := NewWaitingOnPipelineRunKickoffCollector(prometheus.NewRegistry())
+	defer collector.Close()
+	filter := newPipelineRunTriggerLatencyFilter(collector, namespaceFilter([]string{"test-namespace-2"}))
+	created := time.Now().Truncate(time.Second)
+	run := func(ns string, annotations map[string]string, started bool) *v1.PipelineRun {
+		pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "test-1", Annotations: annotations, CreationTimestamp: metav1.Time{Time: created}}}
+		if started {
+			pr.Status.StartTime = &metav1.Time{Time: created.Add(time.Second)}
+		}
+		return pr
+	}
+	start := func(ns string, annotations map[string]string) event.UpdateEvent {
+		return event.UpdateEvent{ObjectOld: run(ns, annotations, false), ObjectNew: run(ns, annotations, true)}
+	}
+
+	assert.False(t, filter.Update(start("test-namespace", map[string]string{TRIGGER_TIME_ANNOTATION: created.Add(-1500 * time.Millisecond).Format(time.RFC3339Nano)})))
+	// the configured annotations take unix seconds too
+	filter.Update(start("test-namespace", map[string]string{"example.com/received": strconv.FormatInt(created.Add(-3*time.Second).Unix(), 10)}))
+	// no trigger time, a malformed one, one after the creation, a filtered namespace, or no start, are not observed
+	filter.Update(start("test-namespace", nil))
+	filter.Update(start("test-namespace", map[string]string{TRIGGER_TIME_ANNOTATION: "yesterday"}))
+	filter.Update(start("test-namespace", map[string]string{TRIGGER_TIME_ANNOTATION: created.Add(time.Minute).Format(time.RFC3339)}))
+	filter.Update(start("test-namespace-2", map[string]string{TRIGGER_TIME_ANNOTATION: created.Format(time.RFC3339)}))
+	annotated := map[string]string{TRIGGER_TIME_ANNOTATION: created.Format(time.RFC3339)}
+	filter.Update(event.UpdateEvent{ObjectOld: run("test-namespace", annotated, true), ObjectNew: run("test-namespace", annotated, true)})
+	// a trigger in the same second as the creation is observed, as the creation time is truncated to seconds
+	filter.Update(start("test-namespace", map[string]string{TRIGGER_TIME_ANNOTATION: created.Add(300 * time.Millisecond).Format(time.RFC3339Nano)}))
+
+	// without an annotation, the time of a time based Tekton Triggers event ID is the trigger time
+	triggered := func(id string) event.UpdateEvent {
+		e := start("test-namespace", nil)
+		e.ObjectOld.SetLabels(map[string]string{TRIGGERS_EVENT_ID_LABEL: id})
+		e.ObjectNew.SetLabels(map[string]string{TRIGGERS_EVENT_ID_LABEL: id})
+		return e
+	}
+	id := uuid.UUID{}
+	binary.BigEndian.PutUint64(id[:8], uint64(created.Add(-5*time.Second).UnixMilli())<<16)
+	id[6], id[8] = 0x70, 0x80
+	filter.Update(triggered(id.String()))
+	filter.Update(triggered(uuid.NewString()))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(collector.triggerToCreation))
+	metric := &dto.Metric{}
+	assert.NoError(t, collector.triggerToCreation.With(prometheus.Labels{NS_LABEL: "test-namespace"}).(prometheus.Histogram).Write(metric))
+	assert.Equal(t, uint64(4), metric.Histogram.GetSampleCount())
+	assert.Equal(t, float64(10000), metric.Histogram.GetSampleSum())
 }
@@ -4,40 +4,117 @@ import (
 	"context"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
 	v1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	"github.com/tektoncd/pipeline/pkg/pod"
 	corev1 "k8s.io/api/core/v1"
 	"knative.dev/pkg/apis"
-	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 type WaitingOnPodCreateAttemptCollector struct {
+	registerer    *collectorRegisterer
 	waitPodCreate *prometheus.GaugeVec
 }
 
-func NewWaitingOnPodCreateAttemptCollector() *WaitingOnPodCreateAttemptCollector {
-	labelNames := []string{NS_LABEL}
+func NewWaitingOnPodCreateAttemptCollector(registerer prometheus.Registerer) *WaitingOnPodCreateAttemptCollector {
+	reg := newCollectorRegisterer(registerer)
+	labelNames := withControlPlaneLabelName([]string{NS_LABEL})
 	waitPodCreate := prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "taskrun_pod_create_not_attempted_or_pending_count",
 		Help: "Number of TaskRuns where the Tekton Controller has yet to attempt to create its underlying Pod, or the TaskRun is still in Pending state for multiple scan iterations",
 	}, labelNames)
 	waitPodCreateCollector := &WaitingOnPodCreateAttemptCollector{
+		registerer:    reg,
 		waitPodCreate: waitPodCreate,
 	}
-	metrics.Registry.Register(waitPodCreate)
+	reg.Register(waitPodCreate)
 	return waitPodCreateCollector
 }
 
+// Close unregisters the metrics of the collector
+func (c *WaitingOnPodCreateAttemptCollector) Close() {
+	c.registerer.Close()
+}
+
 func (c *WaitingOnPodCreateAttemptCollector) IncCollector(ns string) {
-	labels := map[string]string{NS_LABEL: ns}
+	labels := withControlPlaneLabel(map[string]string{NS_LABEL: ns}, ns)
 	c.waitPodCreate.With(labels).Inc()
 }
 
 func (c *WaitingOnPodCreateAttemptCollector) ZeroCollector(ns string) {
-	labels := map[string]string{NS_LABEL: ns}
+	labels := withControlPlaneLabel(map[string]string{NS_LABEL: ns}, ns)
 	c.waitPodCreate.With(labels).Set(float64(0))
 }
 
+// NewPodScheduledMetric quantifies the scheduler pressure the pod create attempt gauge only flags, once the pods are
+// created
+func NewPodScheduledMetric(registerer prometheus.Registerer) *prometheus.HistogramVec {
+	labelNames := withControlPlaneLabelName([]string{NS_LABEL})
+	metric := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "taskrun_pod_duration_scheduled_milliseconds",
+		Help: "Duration in milliseconds between the pod creation time and the last transition time of its PodScheduled condition to true, for the TaskRun pods of the namespaces not filtered out of the pod create attempt detection.",
+		// both times have a one second resolution, so there are no buckets below a second
+		Buckets: []float64{1000, 2500, 12500, 62500, 312500},
+	}, labelNames)
+	registerer.MustRegister(metric)
+	return metric
+}
+
+// podScheduledLatencyFilter observes how long the TaskRun pods took to be scheduled, when their PodScheduled condition
+// turns true, for the namespaces not in the namespace filter of the pod create attempt detection
+type podScheduledLatencyFilter struct {
+	metric *prometheus.HistogramVec
+	filter map[string]struct{}
+}
+
+func (f *podScheduledLatencyFilter) Create(event.CreateEvent) bool {
+	return false
+}
+
+func (f *podScheduledLatencyFilter) Generic(event.GenericEvent) bool {
+	return false
+}
+
+func (f *podScheduledLatencyFilter) Delete(event.DeleteEvent) bool {
+	return false
+}
+
+func (f *podScheduledLatencyFilter) Update(e event.UpdateEvent) bool {
+	oldpod, okold := e.ObjectOld.(*corev1.Pod)
+	newpod, oknew := e.ObjectNew.(*corev1.Pod)
+	if !okold || !oknew {
+		return false
+	}
+	if _, filtered := f.filter[newpod.Namespace]; filtered {
+		return false
+	}
+	if _, taskRunPod := newpod.Labels[pipeline.TaskRunLabelKey]; !taskRunPod {
+		return false
+	}
+	if podScheduledCondition(oldpod) != nil {
+		return false
+	}
+	scheduled := podScheduledCondition(newpod)
+	if scheduled == nil || scheduled.LastTransitionTime.IsZero() {
+		return false
+	}
+	labels := withControlPlaneLabel(map[string]string{NS_LABEL: newpod.Namespace}, newpod.Namespace)
+	f.metric.With(labels).Observe(float64(scheduled.LastTransitionTime.Time.Sub(newpod.CreationTimestamp.Time).Milliseconds()))
+	return false
+}
+
+// podScheduledCondition is the PodScheduled condition of the pod when it is true, nil otherwise
+func podScheduledCondition(pod *corev1.Pod) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		condition := &pod.Status.Conditions[i]
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionTrue {
+			return condition
+		}
+	}
+	return nil
+}
+
 func (r *ExporterReconcile) resetPodCreateAttemptedStats(ctx context.Context) {
 	cacheCopy := buildLastScanCopy(r.pvcCollector, r.waitPodNSCache)
 
@@ -54,8 +131,12 @@ func (r *ExporterReconcile) resetPodCreateAttemptedStats(ctx context.Context) {
 		lastScan:          cacheCopy,
 		currentScan:       r.waitPodNSCache,
 	}
+	remediation := r.remediations[PodCreateAttemptDetectorName]
 	if err == nil {
 		for _, tr := range trList.Items {
+			deadlockTracker.flagged = func() {
+				remediation.remediate(ctx, r.client, &tr)
+			}
 			deadlockTracker.deadlocked = func() bool {
 				if len(tr.Status.PodName) > 0 {
 					return false
@@ -93,6 +174,8 @@ func (r *ExporterReconcile) resetPodCreateAttemptedStats(ctx context.Context) {
 	} else {
 		controllerLog.Error(err, "task run query for pod create attempts failed with an error")
 	}
+	remediation.finishScan()
+	r.flaggedByDetector[PodCreateAttemptDetectorName] = deadlockTracker.flaggedNamespaces
 
 	// if a namespace is in the cache, but not our most recent scan, zero it out too, as the namespace is either
 	// deleted or has all its TaskRuns pruned.
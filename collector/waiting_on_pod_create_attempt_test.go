@@ -3,7 +3,10 @@ package collector
 import (
 	"context"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	"github.com/tektoncd/pipeline/pkg/pod"
 	corev1 "k8s.io/api/core/v1"
@@ -11,10 +14,11 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
-	"os"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"testing"
+	"time"
 )
 
 func TestResetPodCreateAttemptedStats(t *testing.T) {
@@ -23,7 +27,7 @@ func TestResetPodCreateAttemptedStats(t *testing.T) {
 	_ = v1.AddToScheme(scheme)
 	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
 
-	os.Setenv(PodCreateFilterEnvName, "test-namespace-2")
+	defer setSettings(Settings{PodCreateNamespaceFilter: []string{"test-namespace-2"}})()
 
 	mockTaskRuns := []*v1.TaskRun{
 		{
@@ -140,5 +144,38 @@ func TestResetPodCreateAttemptedStats(t *testing.T) {
 	assert.NoError(t, err)
 	reconciler.resetPodCreateAttemptedStats(ctx)
 	validateGaugeVec(t, reconciler.waitPodCollector.waitPodCreate, label, float64(0))
-	unregisterStats(reconciler)
+	reconciler.Close()
+}
+
+func TestPodScheduledLatencyFilter(t *testing.T) {
+	metric := NewPodScheduledMetric(prometheus.NewRegistry())
+	filter := &podScheduledLatencyFilter{metric: metric, filter: namespaceFilter([]string{"test-namespace-2"})}
+	created := time.Now().Add(-3 * time.Second)
+	pending := func(ns string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "test-pod", Labels: map[string]string{pipeline.TaskRunLabelKey: "test-taskrun"}, CreationTimestamp: metav1.Time{Time: created}},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable}}}}
+	}
+	scheduled := func(ns string) *corev1.Pod {
+		pod := pending(ns)
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Time{Time: created.Add(2 * time.Second)}}}
+		return pod
+	}
+
+	assert.False(t, filter.Update(event.UpdateEvent{ObjectOld: pending("test-namespace"), ObjectNew: scheduled("test-namespace")}))
+	// only the transition is observed
+	filter.Update(event.UpdateEvent{ObjectOld: scheduled("test-namespace"), ObjectNew: scheduled("test-namespace")})
+	filter.Update(event.UpdateEvent{ObjectOld: pending("test-namespace"), ObjectNew: pending("test-namespace")})
+	// the namespaces of the filter are left out, like they are of the gauge
+	filter.Update(event.UpdateEvent{ObjectOld: pending("test-namespace-2"), ObjectNew: scheduled("test-namespace-2")})
+	// as are the pods of anything but TaskRuns
+	other := scheduled("test-namespace")
+	other.Labels = nil
+	filter.Update(event.UpdateEvent{ObjectOld: pending("test-namespace"), ObjectNew: other})
+
+	assert.Equal(t, 1, testutil.CollectAndCount(metric))
+	validateHistogramVec(t, metric, prometheus.Labels{NS_LABEL: "test-namespace"}, false)
+	observed := &dto.Metric{}
+	assert.NoError(t, metric.With(prometheus.Labels{NS_LABEL: "test-namespace"}).(prometheus.Histogram).Write(observed))
+	assert.Equal(t, uint64(1), observed.Histogram.GetSampleCount())
+	assert.Equal(t, float64(2000), observed.Histogram.GetSampleSum())
 }
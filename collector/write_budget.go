@@ -0,0 +1,180 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// APIWriteBudgetEnvName is how many writes a minute the exporter issues to the API server, counting the patches of
+	// the throttled label and of remediation, the pods deleted by remediation, and the events; not set, or 0, does not
+	// limit them
+	APIWriteBudgetEnvName = "API_WRITE_BUDGET"
+
+	WriteResultAllowed = "allowed"
+	WriteResultDenied  = "denied"
+
+	WriteVerbCreate = "create"
+	WriteVerbUpdate = "update"
+	WriteVerbPatch  = "patch"
+	WriteVerbDelete = "delete"
+	WriteVerbEvent  = "event"
+
+	// writeBudgetRequeueAfter is the least time a reconcile whose write was denied is retried after, the time the
+	// budget refills in
+	writeBudgetRequeueAfter = time.Minute
+)
+
+// errWriteBudgetExceeded is not one of the errors the patches are retried on right away; the callers requeue the
+// reconcile after writeBudgetRequeue instead, without an error, which is the backpressure keeping the exporter
+// within its budget, without it counting against the health of the collector
+var errWriteBudgetExceeded = fmt.Errorf("the exporter's API write budget of %s is exhausted", APIWriteBudgetEnvName)
+
+// writeBudgetExceeded is true when err is, or wraps, the denial of a write over the budget
+func writeBudgetExceeded(err error) bool {
+	return errors.Is(err, errWriteBudgetExceeded)
+}
+
+// writeBudgetRequeue is when a reconcile whose write was denied is retried, between writeBudgetRequeueAfter and
+// twice that, so the writes denied together are spread out as the budget refills vs. retried, and mostly denied,
+// together again
+func writeBudgetRequeue() time.Duration {
+	return wait.Jitter(writeBudgetRequeueAfter, 1.0)
+}
+
+// writeBudget counts the writes the exporter issues, and denies those over the budget, so the exporter never becomes
+// a meaningful writer load on an API server which is already struggling
+type writeBudget struct {
+	lock    sync.Mutex
+	writes  *prometheus.CounterVec
+	limiter flowcontrol.RateLimiter
+}
+
+var apiWrites = &writeBudget{}
+
+func NewAPIWritesMetric(registerer prometheus.Registerer) *prometheus.CounterVec {
+	writes := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_api_writes_total",
+		Help: "Number of writes the exporter issued to the API server, or denied for being over the write budget, by verb and resource",
+	}, []string{VERB_LABEL, RESOURCE_LABEL, RESULT_LABEL})
+	registerer.MustRegister(writes)
+	return writes
+}
+
+// enable counts the writes, limiting them to the budget a minute when it is set, with up to a minute's worth at once;
+// nil stops counting and limiting them
+func (b *writeBudget) enable(writes *prometheus.CounterVec, perMinute int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.writes = writes
+	b.limiter = nil
+	if writes != nil && perMinute > 0 {
+		b.limiter = flowcontrol.NewTokenBucketRateLimiter(float32(perMinute)/60, perMinute)
+	}
+}
+
+// allow counts the write, returning false when it is over the budget
+func (b *writeBudget) allow(verb, resource string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	allowed := b.limiter == nil || b.limiter.TryAccept()
+	if b.writes != nil {
+		result := WriteResultAllowed
+		if !allowed {
+			result = WriteResultDenied
+		}
+		b.writes.With(prometheus.Labels{VERB_LABEL: verb, RESOURCE_LABEL: resource, RESULT_LABEL: result}).Inc()
+	}
+	return allowed
+}
+
+// resourceOf is the kind of the object written, from its type, or its GVK for the unstructured objects of the
+// server-side apply
+func resourceOf(obj runtime.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; len(kind) > 0 {
+		return kind
+	}
+	t := reflect.TypeOf(obj)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// budgetedClient checks the writes of the client against the write budget; reads go straight through
+type budgetedClient struct {
+	client.Client
+}
+
+func budgetWrites(c client.Client) client.Client {
+	return &budgetedClient{Client: c}
+}
+
+func (c *budgetedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if !apiWrites.allow(WriteVerbCreate, resourceOf(obj)) {
+		return errWriteBudgetExceeded
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *budgetedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if !apiWrites.allow(WriteVerbUpdate, resourceOf(obj)) {
+		return errWriteBudgetExceeded
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *budgetedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if !apiWrites.allow(WriteVerbPatch, resourceOf(obj)) {
+		return errWriteBudgetExceeded
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *budgetedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if !apiWrites.allow(WriteVerbDelete, resourceOf(obj)) {
+		return errWriteBudgetExceeded
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+// budgetedRecorder drops the events over the write budget, as they are informational
+type budgetedRecorder struct {
+	record.EventRecorder
+}
+
+func budgetEvents(recorder record.EventRecorder) record.EventRecorder {
+	if recorder == nil {
+		return nil
+	}
+	return &budgetedRecorder{EventRecorder: recorder}
+}
+
+func (r *budgetedRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if apiWrites.allow(WriteVerbEvent, resourceOf(object)) {
+		r.EventRecorder.Event(object, eventtype, reason, message)
+	}
+}
+
+func (r *budgetedRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	if apiWrites.allow(WriteVerbEvent, resourceOf(object)) {
+		r.EventRecorder.Eventf(object, eventtype, reason, messageFmt, args...)
+	}
+}
+
+func (r *budgetedRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	if apiWrites.allow(WriteVerbEvent, resourceOf(object)) {
+		r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+	}
+}
@@ -0,0 +1,101 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"github.com/tektoncd/pipeline/pkg/pod"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestWriteBudget(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	pr := &v1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "test-1"}}
+	c := budgetWrites(fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr).Build())
+	recorder := record.NewFakeRecorder(10)
+	events := budgetEvents(recorder)
+	writes := NewAPIWritesMetric(prometheus.NewRegistry())
+	// a budget of 2 a minute allows 2 writes at once, and the next one about 30 seconds later
+	apiWrites.enable(writes, 2)
+	defer apiWrites.enable(nil, 0)
+	ctx := context.TODO()
+
+	changed := pr.DeepCopy()
+	changed.Labels = map[string]string{"test": "test"}
+	assert.NoError(t, c.Patch(ctx, changed, client.MergeFrom(pr)))
+	events.Eventf(pr, "Normal", "Test", "test %s", pr.Name)
+	// over the budget, the patch fails and the event is dropped
+	assert.ErrorIs(t, c.Patch(ctx, changed, client.MergeFrom(pr)), errWriteBudgetExceeded)
+	events.Eventf(pr, "Normal", "Test", "test %s", pr.Name)
+	assert.Len(t, recorder.Events, 1)
+	// reads are not writes
+	assert.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(pr), &v1.PipelineRun{}))
+	validateCounterVec(t, writes, prometheus.Labels{VERB_LABEL: WriteVerbPatch, RESOURCE_LABEL: "PipelineRun", RESULT_LABEL: WriteResultAllowed}, float64(1))
+	validateCounterVec(t, writes, prometheus.Labels{VERB_LABEL: WriteVerbPatch, RESOURCE_LABEL: "PipelineRun", RESULT_LABEL: WriteResultDenied}, float64(1))
+	validateCounterVec(t, writes, prometheus.Labels{VERB_LABEL: WriteVerbEvent, RESOURCE_LABEL: "PipelineRun", RESULT_LABEL: WriteResultDenied}, float64(1))
+
+	// without a budget, the writes are only counted
+	apiWrites.enable(writes, 0)
+	assert.NoError(t, c.Delete(ctx, pr))
+	validateCounterVec(t, writes, prometheus.Labels{VERB_LABEL: WriteVerbDelete, RESOURCE_LABEL: "PipelineRun", RESULT_LABEL: WriteResultAllowed}, float64(1))
+}
+
+func TestWriteBudgetBackpressure(t *testing.T) {
+	defer setSettings(Settings{CollectorFailureThreshold: 1})()
+	collectorHealth.enable(NewCollectorDisabledMetric(prometheus.NewRegistry()))
+	defer collectorHealth.enable(nil)
+	pr := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "test1", Namespace: "test1"},
+		Status: v1.PipelineRunStatus{
+			PipelineRunStatusFields: v1.PipelineRunStatusFields{
+				ChildReferences: []v1.ChildStatusReference{{TypeMeta: runtime.TypeMeta{Kind: "TaskRun"}, Name: "test1"}},
+			},
+		},
+	}
+	tr := &v1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "test1", Namespace: "test1"},
+		Status: v1.TaskRunStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{
+					{Type: "Succeeded", Status: corev1.ConditionUnknown, Reason: pod.ReasonExceededResourceQuota},
+				},
+			},
+		},
+	}
+	scheme := runtime.NewScheme()
+	_ = v1.AddToScheme(scheme)
+	c := budgetWrites(fake.NewClientBuilder().WithScheme(scheme).WithObjects(pr, tr).Build())
+	apiWrites.enable(NewAPIWritesMetric(prometheus.NewRegistry()), 1)
+	defer apiWrites.enable(nil, 0)
+	// the one write of the budget is spent elsewhere
+	assert.True(t, apiWrites.allow(WriteVerbPatch, "Pod"))
+	r := buildReconciler(c, nil, nil)
+	defer r.Close()
+	defer inMemoryThrottles.forget(pr)
+
+	// the denied label patch requeues the PipelineRun without an error, so the overhead collector stays enabled
+	reconcileOverhead := safeReconcile(CollectorOverhead, r.ReconcileOverhead)
+	for i := 0; i < 3; i++ {
+		result, err := reconcileOverhead(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(pr)})
+		assert.NoError(t, err)
+		// spread out between 1 and 2 minutes
+		assert.GreaterOrEqual(t, result.RequeueAfter, writeBudgetRequeueAfter)
+		assert.Less(t, result.RequeueAfter, 2*writeBudgetRequeueAfter)
+	}
+	assert.False(t, collectorHealth.isDisabled(CollectorOverhead))
+	_, throttled := inMemoryThrottles.throttledBy(pr)
+	assert.True(t, throttled)
+	assert.Equal(t, 0, testutil.CollectAndCount(r.overheadCollector.patchFailures))
+}
@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basicAuthHandler sets the http_server_config headers, and requires the clients to authenticate as one of the
+// basic_auth_users of the current web configuration, letting all of them through when it has none, so headers and users
+// added to the file take effect on reload
+type basicAuthHandler struct {
+	next http.Handler
+	cfg  *TLSServerConfig
+	lock sync.Mutex
+	// verified are the credentials that matched their hash, by hash of user, password and hash, as bcrypt is too slow
+	// to run on every scrape; a rotated hash makes for a new key
+	verified map[[sha256.Size]byte]struct{}
+}
+
+func newBasicAuthHandler(next http.Handler, cfg *TLSServerConfig) *basicAuthHandler {
+	return &basicAuthHandler{next: next, cfg: cfg, verified: map[[sha256.Size]byte]struct{}{}}
+}
+
+func (h *basicAuthHandler) verify(user, password, hash string) bool {
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + hash))
+	h.lock.Lock()
+	_, ok := h.verified[key]
+	h.lock.Unlock()
+	if ok {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if len(h.verified) >= maxMetricsAuthCacheEntries {
+		h.verified = map[[sha256.Size]byte]struct{}{}
+	}
+	h.verified[key] = struct{}{}
+	return true
+}
+
+func (h *basicAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cfg := h.cfg.current()
+	for name, value := range cfg.Headers {
+		w.Header().Set(name, value)
+	}
+	users := cfg.BasicAuthUsers
+	if len(users) == 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	user, password, ok := r.BasicAuth()
+	hash, known := users[user]
+	if !ok || !known || !h.verify(user, password, hash) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="pipeline-service-exporter"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	identify(r, user)
+	h.next.ServeHTTP(w, r)
+}
@@ -0,0 +1,89 @@
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func basicAuth(h http.Handler, user, password string) (int, string) {
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if len(user) > 0 {
+		r.SetBasicAuth(user, password)
+	}
+	s := &scraper{}
+	r = r.WithContext(contextWithScraper(r, s))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code, s.name
+}
+
+func TestBasicAuthHandler(t *testing.T) {
+	dir := t.TempDir()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	rotated, err := bcrypt.GenerateFromPassword([]byte("rotated"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	path := writeFile(t, dir, "web.yaml", []byte("tls_server_config: {cert_file: a, key_file: b}"))
+	cfg, err := LoadWebConfig(path)
+	assert.NoError(t, err)
+	now := time.Now()
+	cfg.reloader.now = func() time.Time { return now }
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := newBasicAuthHandler(next, cfg)
+
+	// without users, everybody gets through
+	code, _ := basicAuth(h, "", "")
+	assert.Equal(t, http.StatusOK, code)
+
+	writeFile(t, dir, "web.yaml", []byte("tls_server_config: {cert_file: a, key_file: b}\nbasic_auth_users: {prometheus: "+string(hash)+"}"))
+	now = now.Add(webConfigReloadInterval)
+	// the TLS files of the new config do not exist, so it is kept only once they do
+	code, _ = basicAuth(h, "", "")
+	assert.Equal(t, http.StatusOK, code)
+
+	ca := newTestCert(t, "test-ca", nil, false)
+	server := newTestCert(t, "exporter", ca, true)
+	certFile, keyFile := writeFile(t, dir, "tls.crt", server.pem), writeFile(t, dir, "tls.key", server.keyPEM(t))
+	writeFile(t, dir, "web.yaml", []byte("tls_server_config: {cert_file: "+certFile+", key_file: "+keyFile+"}\nbasic_auth_users: {prometheus: "+string(hash)+"}"))
+	now = now.Add(webConfigReloadInterval)
+	code, _ = basicAuth(h, "", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = basicAuth(h, "prometheus", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = basicAuth(h, "someone", "secret")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, name := basicAuth(h, "prometheus", "secret")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "prometheus", name)
+	assert.Len(t, h.verified, 1)
+
+	// the rotated password replaces the old one without a restart
+	writeFile(t, dir, "web.yaml", []byte("tls_server_config: {cert_file: "+certFile+", key_file: "+keyFile+"}\nbasic_auth_users: {prometheus: "+string(rotated)+"}"))
+	now = now.Add(webConfigReloadInterval)
+	code, _ = basicAuth(h, "prometheus", "secret")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = basicAuth(h, "prometheus", "rotated")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestBasicAuthAndTokenAuth(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	tlsCfg := &TLSServerConfig{CertFile: "a", KeyFile: "b", BasicAuthUsers: map[string]string{"prometheus": string(hash)}}
+	_, err = New(Config{}).listener("admin", &ListenerConfig{BindAddress: ":6061", TLS: tlsCfg, Auth: &MetricsAuthConfig{}})
+	assert.Error(t, err)
+}
+
+func TestBasicAuthHandlerHeaders(t *testing.T) {
+	cfg := &TLSServerConfig{Headers: map[string]string{"X-Content-Type-Options": "nosniff"}}
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	w := httptest.NewRecorder()
+	newBasicAuthHandler(next, cfg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+}
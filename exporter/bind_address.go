@@ -0,0 +1,95 @@
+package exporter
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// NormalizeBindAddress returns addr as host:port, with a bare port, like 9117, binding all the interfaces; IPv6 hosts
+// need brackets, like [::]:9117 or [::1]:9117, as they are ambiguous otherwise
+func NormalizeBindAddress(addr string) (string, error) {
+	if _, err := strconv.Atoi(addr); err == nil {
+		addr = ":" + addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a [host]:port address, with IPv6 hosts in brackets like [::]:9117: %w", addr, err)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return "", fmt.Errorf("%q does not have a port number", addr)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// listenerOff is whether the address of a listener turns it off, as "0" does for controller-runtime's listeners
+func listenerOff(addr string) bool {
+	return len(addr) == 0 || addr == "0"
+}
+
+func wildcardHost(host string) bool {
+	switch host {
+	case "", "0.0.0.0", "::":
+		return true
+	}
+	return false
+}
+
+// conflicting is whether the two normalized addresses cannot both be bound, as they have the same port and either the
+// same host or a host binding all the interfaces
+func conflicting(a, b string) bool {
+	aHost, aPort, _ := net.SplitHostPort(a)
+	bHost, bPort, _ := net.SplitHostPort(b)
+	if aPort != bPort || aPort == "0" {
+		return false
+	}
+	return aHost == bHost || wildcardHost(aHost) || wildcardHost(bHost)
+}
+
+// normalizeBindAddresses normalizes the addresses of the listeners that are on, and fails on the ones that are not
+// valid or would collide, vs. the exporter failing to bind one of them after it started
+func (e *Exporter) normalizeBindAddresses() error {
+	addrs := map[string]*string{}
+	if e.cfg.MetricsBindAddress != "0" {
+		if len(e.cfg.MetricsBindAddress) == 0 {
+			e.cfg.MetricsBindAddress = DefaultMetricsBindAddress
+		}
+		addrs["metrics"] = &e.cfg.MetricsBindAddress
+	}
+	if !listenerOff(e.cfg.HealthProbeBindAddress) {
+		addrs["health probe"] = &e.cfg.HealthProbeBindAddress
+	}
+	// the listener configs are the caller's, so they are copied vs. updated
+	if e.cfg.Debug != nil {
+		debug := *e.cfg.Debug
+		e.cfg.Debug = &debug
+		addrs["debug"] = &debug.BindAddress
+	}
+	if e.cfg.Admin != nil {
+		admin := *e.cfg.Admin
+		e.cfg.Admin = &admin
+		addrs["admin"] = &admin.BindAddress
+	}
+	names := []string{"metrics", "health probe", "debug", "admin"}
+	for _, name := range names {
+		addr, ok := addrs[name]
+		if !ok {
+			continue
+		}
+		normalized, err := NormalizeBindAddress(*addr)
+		if err != nil {
+			return fmt.Errorf("invalid %s address: %w", name, err)
+		}
+		*addr = normalized
+	}
+	for i, name := range names {
+		for _, other := range names[i+1:] {
+			a, aOK := addrs[name]
+			b, bOK := addrs[other]
+			if aOK && bOK && conflicting(*a, *b) {
+				return fmt.Errorf("the %s address %s and the %s address %s cannot both be bound", name, *a, other, *b)
+			}
+		}
+	}
+	return nil
+}
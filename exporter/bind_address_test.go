@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeBindAddress(t *testing.T) {
+	for addr, expected := range map[string]string{
+		"9117":           ":9117",
+		":9117":          ":9117",
+		"[::]:9117":      "[::]:9117",
+		"[::1]:6060":     "[::1]:6060",
+		"localhost:6060": "localhost:6060",
+		"0.0.0.0:8081":   "0.0.0.0:8081",
+		":0":             ":0",
+	} {
+		normalized, err := NormalizeBindAddress(addr)
+		assert.NoError(t, err, addr)
+		assert.Equal(t, expected, normalized, addr)
+	}
+	for _, addr := range []string{".9117", "::1", "::9117", "localhost", "localhost:http", ":70000", ""} {
+		_, err := NormalizeBindAddress(addr)
+		assert.Error(t, err, addr)
+	}
+}
+
+func TestNormalizeBindAddresses(t *testing.T) {
+	debug := &ListenerConfig{BindAddress: "6060"}
+	e := New(Config{HealthProbeBindAddress: "[::]:8081", Debug: debug, Admin: &ListenerConfig{BindAddress: "localhost:6061"}})
+	assert.NoError(t, e.normalizeBindAddresses())
+	assert.Equal(t, DefaultMetricsBindAddress, e.cfg.MetricsBindAddress)
+	assert.Equal(t, "[::]:8081", e.cfg.HealthProbeBindAddress)
+	assert.Equal(t, ":6060", e.cfg.Debug.BindAddress)
+	assert.Equal(t, "localhost:6061", e.cfg.Admin.BindAddress)
+	// the caller's config is left alone
+	assert.Equal(t, "6060", debug.BindAddress)
+
+	// the listeners that are off are neither checked nor changed
+	e = New(Config{MetricsBindAddress: "0", HealthProbeBindAddress: "0"})
+	assert.NoError(t, e.normalizeBindAddresses())
+	assert.Equal(t, "0", e.cfg.MetricsBindAddress)
+	assert.Equal(t, "0", e.cfg.HealthProbeBindAddress)
+
+	for name, cfg := range map[string]Config{
+		"invalid":            {MetricsBindAddress: ".9117"},
+		"same":               {MetricsBindAddress: ":8081", HealthProbeBindAddress: "8081"},
+		"wildcard and host":  {MetricsBindAddress: "[::]:9117", Debug: &ListenerConfig{BindAddress: "localhost:9117"}},
+		"debug and admin":    {Debug: &ListenerConfig{BindAddress: "localhost:6060"}, Admin: &ListenerConfig{BindAddress: "localhost:6060"}},
+		"default and health": {HealthProbeBindAddress: "9117"},
+	} {
+		assert.Error(t, New(cfg).normalizeBindAddresses(), name)
+	}
+	// different hosts on the same port
+	assert.NoError(t, New(Config{Debug: &ListenerConfig{BindAddress: "127.0.0.1:6060"}, Admin: &ListenerConfig{BindAddress: "[::1]:6060"}}).normalizeBindAddresses())
+}
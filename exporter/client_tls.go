@@ -0,0 +1,46 @@
+package exporter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	ca, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("%s has no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// ClientTLSConfig is the TLS config of a client of the exporter's TLS listeners, like the top subcommand, trusting
+// the CA of caFile vs. the system's when set, and presenting the certificate of certFile and keyFile when set, for
+// the listeners requiring client certificates
+func ClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if (len(certFile) == 0) != (len(keyFile) == 0) {
+		return nil, errors.New("the client certificate and key need to be set together")
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(caFile) > 0 {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if len(certFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
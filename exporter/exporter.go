@@ -0,0 +1,349 @@
+/*
+ Copyright 2023 The Pipeline Service Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package exporter runs the whole exporter, its controller-runtime manager, collectors, and metrics and health
+// endpoints, so it can be started from another process, like the pipeline-service operator, as well as from the binary
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// DefaultMetricsBindAddress is where the metrics are served when Config.MetricsBindAddress is empty
+	DefaultMetricsBindAddress = ":9117"
+	// DefaultMetricsPath is where the metrics are served when Config.MetricsPath is empty
+	DefaultMetricsPath = "/metrics"
+)
+
+// Config is what the exporter needs to know about its environment; everything about what it collects is set with
+// the Exporter's With methods
+type Config struct {
+	// RestConfig is how the exporter talks to the API server
+	RestConfig *rest.Config
+	// MetricsBindAddress is where the metrics, and any tenant or federation subsets of them, are served, as host:port,
+	// [::]:9117 for IPv6, or a bare port; "0" turns the listener off, say when the embedding process already serves
+	// controller-runtime's registry
+	MetricsBindAddress string
+	// MetricsPath is the path of the metrics on MetricsBindAddress; the tenant and federation subsets keep theirs
+	MetricsPath string
+	// HealthProbeBindAddress is where the healthz and readyz endpoints are served, if set, always over plain HTTP, so
+	// the kubelet can probe them, and a NetworkPolicy can open only that port to it, with the metrics requiring TLS
+	HealthProbeBindAddress string
+	// PeerClusters are the other member clusters, by name, watched to detect duplicate runs
+	PeerClusters map[string]*rest.Config
+	// TextfilePath is where the metrics are also written in the text format, for node_exporter's textfile collector,
+	// if set; set MetricsBindAddress to "0" as well when nothing scrapes the exporter
+	TextfilePath string
+	// TextfileInterval is how often the textfile is written, DefaultTextfileInterval when 0
+	TextfileInterval time.Duration
+	// Watchdog are the limits the exporter checks itself against, with a breach failing the healthz check, which is
+	// only served with HealthProbeBindAddress set, or stopping the exporter
+	Watchdog WatchdogConfig
+	// MetricsTLS serves the metrics on MetricsBindAddress over TLS, optionally requiring client certificates, vs. plain
+	// HTTP; see LoadWebConfig
+	MetricsTLS *TLSServerConfig
+	// MetricsAuth requires the scrapers to send a bearer token the API server authenticates and authorizes, so
+	// kube-rbac-proxy is not needed in front of the exporter; it needs MetricsTLS, so the tokens are not sent in clear
+	MetricsAuth *MetricsAuthConfig
+	// MetricsCompression are the encodings the responses of the metrics listener are compressed with, in order of
+	// preference, of those the scraper accepts; DefaultMetricsCompression when nil, and none when empty
+	MetricsCompression []string
+	// MetricsMaxResponseBytes fails the responses of the metrics listener larger than this, uncompressed, when positive
+	MetricsMaxResponseBytes int64
+	// MetricsServer are the timeouts and limits of the metrics listener
+	MetricsServer ServerConfig
+	// Debug serves pprof when set, and the admin APIs too unless Admin is set
+	Debug *ListenerConfig
+	// Admin serves the recent runs and daily aggregates APIs when set
+	Admin *ListenerConfig
+}
+
+// ListenerConfig is where the debug or admin listener binds, and how it is secured, independently of the metrics
+type ListenerConfig struct {
+	// BindAddress is where the listener binds, like localhost:6060 or [::1]:6060; a bare port binds all the interfaces
+	BindAddress string
+	// TLS serves the listener over TLS, optionally requiring client certificates, vs. plain HTTP
+	TLS *TLSServerConfig
+	// Auth requires the callers to send a bearer token the API server authenticates and authorizes, which needs TLS
+	Auth *MetricsAuthConfig
+	// Server are the timeouts and limits of the listener, like those of the metrics listener
+	Server ServerConfig
+}
+
+// Exporter is built with New and the With methods, then run with Run
+type Exporter struct {
+	cfg        Config
+	collectors []string
+	opts       []collector.Option
+}
+
+func New(cfg Config) *Exporter {
+	return &Exporter{cfg: cfg}
+}
+
+// WithCollectors limits the exporter to the named collectors, like collector.WithCollectors
+func (e *Exporter) WithCollectors(names ...string) *Exporter {
+	e.collectors = append(e.collectors, names...)
+	return e
+}
+
+// WithOptions passes the options on to the collectors
+func (e *Exporter) WithOptions(opts ...collector.Option) *Exporter {
+	e.opts = append(e.opts, opts...)
+	return e
+}
+
+func (e *Exporter) options() []collector.Option {
+	opts := append([]collector.Option{}, e.opts...)
+	if len(e.collectors) > 0 {
+		opts = append(opts, collector.WithCollectors(e.collectors...))
+	}
+	return opts
+}
+
+// managerOptions are the options of the manager, with the metrics listener as the manager alone would serve it, on
+// MetricsBindAddress over plain HTTP, and not at all over TLS, which it cannot be given; Run hands the listener over
+// to metricsServer either way
+func (e *Exporter) managerOptions() ctrl.Options {
+	mopts := ctrl.Options{
+		MetricsBindAddress:     e.cfg.MetricsBindAddress,
+		Port:                   9443,
+		HealthProbeBindAddress: e.cfg.HealthProbeBindAddress,
+	}
+	if len(mopts.MetricsBindAddress) == 0 {
+		mopts.MetricsBindAddress = DefaultMetricsBindAddress
+	}
+	if e.cfg.MetricsTLS != nil {
+		mopts.MetricsBindAddress = "0"
+	}
+	return mopts
+}
+
+// metricsServer is nil when the metrics are not served; its scrape metrics are registered with registerer
+func (e *Exporter) metricsServer(registerer prometheus.Registerer) (*metricsServer, error) {
+	if e.cfg.MetricsBindAddress == "0" {
+		return nil, nil
+	}
+	addr := e.cfg.MetricsBindAddress
+	if len(addr) == 0 {
+		addr = DefaultMetricsBindAddress
+	}
+	path := e.cfg.MetricsPath
+	if len(path) == 0 {
+		path = DefaultMetricsPath
+	}
+	s, err := newMetricsServer(addr, path, e.cfg.MetricsTLS, e.cfg.MetricsServer)
+	if err != nil {
+		return nil, err
+	}
+	scrapes, err := newScrapeMetrics(registerer)
+	if err != nil {
+		return nil, err
+	}
+	encodings := e.cfg.MetricsCompression
+	if encodings == nil {
+		encodings = DefaultMetricsCompression
+	}
+	s.handler, err = newResponseEncoder(s.handler, s.mux, encodings, e.cfg.MetricsMaxResponseBytes, scrapes.oversized)
+	if err != nil {
+		return nil, err
+	}
+	if e.cfg.MetricsTLS != nil {
+		s.handler = newBasicAuthHandler(s.handler, e.cfg.MetricsTLS)
+	}
+	if e.cfg.MetricsAuth != nil {
+		wrap, err := e.authWrap(e.cfg.MetricsAuth)
+		if err != nil {
+			return nil, err
+		}
+		s.handler = wrap(s.handler)
+	}
+	s.handler = scrapes.handler(s.handler, s.mux)
+	return s, nil
+}
+
+func (e *Exporter) authWrap(cfg *MetricsAuthConfig) (func(http.Handler) http.Handler, error) {
+	client, err := kubernetes.NewForConfig(e.cfg.RestConfig)
+	if err != nil {
+		return nil, err
+	}
+	return func(next http.Handler) http.Handler {
+		return newMetricsAuthHandler(next, cfg, client.AuthenticationV1().TokenReviews(), client.AuthorizationV1().SubjectAccessReviews())
+	}, nil
+}
+
+// listener turns the config of the debug or admin listener into the collector's
+func (e *Exporter) listener(name string, cfg *ListenerConfig) (*collector.Listener, error) {
+	if cfg.Auth != nil && cfg.TLS == nil {
+		return nil, fmt.Errorf("the %s auth requires the %s TLS", name, name)
+	}
+	if cfg.Auth != nil && len(cfg.TLS.current().BasicAuthUsers) > 0 {
+		return nil, fmt.Errorf("the %s auth and basic_auth_users both need the Authorization header, use one or the other", name)
+	}
+	if cfg.Auth != nil {
+		cfg.TLS.forbidBasicAuth("the " + name + " auth")
+	}
+	server := cfg.Server
+	if cfg.TLS != nil {
+		server.HTTP2 = server.HTTP2 && !cfg.TLS.DisableHTTP2
+	}
+	l := &collector.Listener{Address: cfg.BindAddress, Server: server.httpServer}
+	if cfg.TLS != nil {
+		tlsConfig, err := cfg.TLS.tlsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("unable to set up the %s TLS: %w", name, err)
+		}
+		l.TLSConfig = tlsConfig
+	}
+	if cfg.Auth != nil {
+		wrap, err := e.authWrap(cfg.Auth)
+		if err != nil {
+			return nil, err
+		}
+		l.Wrap = wrap
+	}
+	if cfg.TLS != nil {
+		wrap := l.Wrap
+		l.Wrap = func(next http.Handler) http.Handler {
+			var h http.Handler = newBasicAuthHandler(next, cfg.TLS)
+			if wrap != nil {
+				h = wrap(h)
+			}
+			return h
+		}
+	}
+	return l, nil
+}
+
+// listenerOptions are the collector options for the debug and admin listeners that are set
+func (e *Exporter) listenerOptions() ([]collector.Option, error) {
+	opts := []collector.Option{}
+	if e.cfg.Debug != nil {
+		l, err := e.listener("debug", e.cfg.Debug)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, collector.WithDebugListener(*l))
+	}
+	if e.cfg.Admin != nil {
+		l, err := e.listener("admin", e.cfg.Admin)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, collector.WithAdminListener(*l))
+	}
+	return opts, nil
+}
+
+// textfileWriter gathers from controller-runtime's registry, which is where the collectors register unless given
+// another registerer with collector.WithRegisterer, and the default prometheus registry, see collector.ServedGatherer
+func (e *Exporter) textfileWriter() *textfileWriter {
+	if len(e.cfg.TextfilePath) == 0 {
+		return nil
+	}
+	interval := e.cfg.TextfileInterval
+	if interval <= 0 {
+		interval = DefaultTextfileInterval
+	}
+	return &textfileWriter{path: e.cfg.TextfilePath, interval: interval, gatherer: collector.ServedGatherer}
+}
+
+// Run creates the manager, sets up the collectors, and blocks serving the metrics until ctx is done; as the metrics
+// are registered process wide, Run is only called once per process, unless each call is given its own registerer
+// with collector.WithRegisterer
+func (e *Exporter) Run(ctx context.Context) error {
+	if e.cfg.RestConfig == nil {
+		return fmt.Errorf("the exporter requires a rest config")
+	}
+	if e.cfg.MetricsAuth != nil && e.cfg.MetricsTLS == nil {
+		return fmt.Errorf("the metrics auth requires the metrics TLS")
+	}
+	if e.cfg.MetricsAuth != nil && len(e.cfg.MetricsTLS.current().BasicAuthUsers) > 0 {
+		return fmt.Errorf("the metrics auth and basic_auth_users both need the Authorization header, use one or the other")
+	}
+	if e.cfg.MetricsAuth != nil {
+		e.cfg.MetricsTLS.forbidBasicAuth("the metrics auth")
+	}
+	if err := e.normalizeBindAddresses(); err != nil {
+		return err
+	}
+	// a breach would otherwise only fail a healthz check nothing serves, and go unnoticed
+	if e.cfg.Watchdog.enabled() && !e.cfg.Watchdog.Exit && listenerOff(e.cfg.HealthProbeBindAddress) {
+		return fmt.Errorf("the watchdog needs the health probe address, or Exit set, for a breached limit to restart the exporter")
+	}
+	listenerOpts, err := e.listenerOptions()
+	if err != nil {
+		return err
+	}
+	mopts := e.managerOptions()
+	// the metrics are served by metricsServer, which counts the scrapes by scraper, vs. the manager
+	mopts.MetricsBindAddress = "0"
+	mgr, err := collector.NewManager(e.cfg.RestConfig, mopts, append(e.options(), listenerOpts...)...)
+	if err != nil {
+		return fmt.Errorf("unable to create the controller-runtime manager: %w", err)
+	}
+	if err = collector.AddPeerClusters(mgr, e.cfg.PeerClusters); err != nil {
+		return fmt.Errorf("unable to watch peer clusters: %w", err)
+	}
+	// the extra handlers depend on the settings, which are in place once the manager is created
+	s, err := e.metricsServer(metrics.Registry)
+	if err != nil {
+		return fmt.Errorf("unable to set up the metrics server: %w", err)
+	}
+	if s != nil {
+		if err = mgr.Add(s); err != nil {
+			return fmt.Errorf("unable to set up the metrics server: %w", err)
+		}
+	}
+	if w := e.textfileWriter(); w != nil {
+		if err = mgr.Add(w); err != nil {
+			return fmt.Errorf("unable to set up the metrics textfile: %w", err)
+		}
+	}
+	var w *watchdog
+	if e.cfg.Watchdog.enabled() {
+		w = newWatchdog(e.cfg.Watchdog, metrics.Registry)
+		if err = mgr.Add(w); err != nil {
+			return fmt.Errorf("unable to set up the watchdog: %w", err)
+		}
+	}
+	if len(e.cfg.HealthProbeBindAddress) > 0 {
+		if err = mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+			return fmt.Errorf("unable to set up health check: %w", err)
+		}
+		if w != nil {
+			if err = mgr.AddHealthzCheck("watchdog", w.healthz); err != nil {
+				return fmt.Errorf("unable to set up watchdog health check: %w", err)
+			}
+		}
+		if err = mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+			return fmt.Errorf("unable to set up ready check: %w", err)
+		}
+	}
+	return mgr.Start(ctx)
+}
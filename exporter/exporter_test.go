@@ -0,0 +1,75 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/rest"
+)
+
+func TestBuilder(t *testing.T) {
+	e := New(Config{}).
+		WithCollectors(collector.CollectorOverhead).
+		WithOptions(collector.WithReadOnly(true)).
+		WithCollectors(collector.CollectorTaskRunGaps)
+	assert.Equal(t, []string{collector.CollectorOverhead, collector.CollectorTaskRunGaps}, e.collectors)
+	// the collector selection goes after any options, so it is added to vs. replaced
+	assert.Len(t, e.options(), 2)
+	assert.Len(t, New(Config{}).options(), 0)
+}
+
+func TestManagerOptions(t *testing.T) {
+	mopts := New(Config{}).managerOptions()
+	assert.Equal(t, DefaultMetricsBindAddress, mopts.MetricsBindAddress)
+	assert.Empty(t, mopts.HealthProbeBindAddress)
+
+	mopts = New(Config{MetricsBindAddress: "0", HealthProbeBindAddress: ":8081"}).managerOptions()
+	assert.Equal(t, "0", mopts.MetricsBindAddress)
+	assert.Equal(t, ":8081", mopts.HealthProbeBindAddress)
+}
+
+func TestRunRequiresRestConfig(t *testing.T) {
+	assert.Error(t, New(Config{}).Run(context.Background()))
+}
+
+func TestRunRequiresWatchdogRestart(t *testing.T) {
+	// a breach could neither fail a served healthz check nor exit
+	err := New(Config{RestConfig: &rest.Config{Host: "https://localhost:6443"}, HealthProbeBindAddress: "0",
+		Watchdog: WatchdogConfig{MaxGoroutines: 5000}}).Run(context.Background())
+	assert.ErrorContains(t, err, "the watchdog needs the health probe address")
+}
+
+func TestListenerOptions(t *testing.T) {
+	e := New(Config{RestConfig: &rest.Config{Host: "https://localhost:6443"}})
+	opts, err := e.listenerOptions()
+	assert.NoError(t, err)
+	assert.Empty(t, opts)
+
+	l, err := e.listener("debug", &ListenerConfig{BindAddress: ":6060"})
+	assert.NoError(t, err)
+	assert.Equal(t, ":6060", l.Address)
+	assert.Nil(t, l.TLSConfig)
+	assert.Nil(t, l.Wrap)
+	assert.NotNil(t, l.Server)
+
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil, false)
+	server := newTestCert(t, "exporter", ca, true)
+	tlsCfg := &TLSServerConfig{CertFile: writeFile(t, dir, "tls.crt", server.pem), KeyFile: writeFile(t, dir, "tls.key", server.keyPEM(t))}
+	l, err = e.listener("admin", &ListenerConfig{BindAddress: "localhost:6061", TLS: tlsCfg, Auth: &MetricsAuthConfig{}})
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:6061", l.Address)
+	assert.NotNil(t, l.TLSConfig)
+	assert.NotNil(t, l.Wrap)
+
+	// the bearer tokens are not sent in clear
+	_, err = e.listener("admin", &ListenerConfig{BindAddress: "localhost:6061", Auth: &MetricsAuthConfig{}})
+	assert.Error(t, err)
+
+	e = New(Config{Debug: &ListenerConfig{BindAddress: "localhost:6060"}, Admin: &ListenerConfig{BindAddress: "localhost:6061", TLS: tlsCfg}})
+	opts, err = e.listenerOptions()
+	assert.NoError(t, err)
+	assert.Len(t, opts, 2)
+}
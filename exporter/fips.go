@@ -0,0 +1,28 @@
+package exporter
+
+import "crypto/tls"
+
+// fipsMode is whether the exporter is built with a FIPS 140 validated crypto module, with GOEXPERIMENT=boringcrypto,
+// which restricts the TLS settings of every listener, and client, to the FIPS approved ones
+var fipsMode = false
+
+// FIPSMode is whether the exporter is built in FIPS mode, see fipsMode
+func FIPSMode() bool {
+	return fipsMode
+}
+
+// fipsCipherSuites and fipsCurves are what the TLS handshakes are restricted to in FIPS mode, so the web configuration
+// files asking for anything else fail to load vs. failing every handshake
+var (
+	fipsCipherSuites = map[uint16]bool{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: true,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: true,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   true,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   true,
+	}
+	fipsCurves = map[tls.CurveID]bool{
+		tls.CurveP256: true,
+		tls.CurveP384: true,
+		tls.CurveP521: true,
+	}
+)
@@ -0,0 +1,12 @@
+//go:build boringcrypto
+
+package exporter
+
+import (
+	// restricts crypto/tls to the FIPS approved settings, in the clients of the exporter too
+	_ "crypto/tls/fipsonly"
+)
+
+func init() {
+	fipsMode = true
+}
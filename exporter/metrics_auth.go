@@ -0,0 +1,191 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authenticationclient "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// metricsAuthCacheTTL is how long the outcome of a review is reused, as every scrape would otherwise cost two
+	// requests to the API server
+	metricsAuthCacheTTL = time.Minute
+	// metricsAuthDeniedCacheTTL is how long a token that is not authenticated, or not allowed the path, is refused
+	// without a review, short so a newly granted scraper is let in soon, but long enough that a misconfigured scraper
+	// retrying in a loop does not turn every attempt into two requests to the API server
+	metricsAuthDeniedCacheTTL = 10 * time.Second
+	// maxMetricsAuthCacheEntries bounds the cache, which is cleared when full
+	maxMetricsAuthCacheEntries = 1000
+)
+
+// MetricsAuthConfig authenticates the scrapers of the metrics with a TokenReview of their bearer token, and authorizes
+// them with a SubjectAccessReview, like kube-rbac-proxy does
+type MetricsAuthConfig struct {
+	// Resource is what the scraper needs to be allowed to get, like the exporter's service; when nil, the request path
+	// is checked as a non-resource URL, which is kube-rbac-proxy's default
+	Resource *authorizationv1.ResourceAttributes
+}
+
+// ParseResourceAttributes parses [<namespace>/]<resource>[.<group>][/<name>], like
+// openshift-pipelines/services/pipeline-service-exporter; the verb is always get
+func ParseResourceAttributes(spec string) (*authorizationv1.ResourceAttributes, error) {
+	parts := strings.Split(spec, "/")
+	for _, part := range parts {
+		if len(part) == 0 {
+			return nil, fmt.Errorf("%q is not [<namespace>/]<resource>[.<group>][/<name>]", spec)
+		}
+	}
+	attributes := &authorizationv1.ResourceAttributes{Verb: "get"}
+	switch len(parts) {
+	case 1:
+		attributes.Resource = parts[0]
+	case 2:
+		attributes.Namespace, attributes.Resource = parts[0], parts[1]
+	case 3:
+		attributes.Namespace, attributes.Resource, attributes.Name = parts[0], parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("%q is not [<namespace>/]<resource>[.<group>][/<name>]", spec)
+	}
+	attributes.Resource, attributes.Group, _ = strings.Cut(attributes.Resource, ".")
+	return attributes, nil
+}
+
+type metricsAuthHandler struct {
+	next     http.Handler
+	tokens   authenticationclient.TokenReviewInterface
+	reviews  authorizationclient.SubjectAccessReviewInterface
+	resource *authorizationv1.ResourceAttributes
+	lock     sync.Mutex
+	// reviewed are the outcomes of the reviews, by hash of token, verb and path
+	reviewed map[[sha256.Size]byte]reviewOutcome
+	now      func() time.Time
+}
+
+func newMetricsAuthHandler(next http.Handler, cfg *MetricsAuthConfig, tokens authenticationclient.TokenReviewInterface, reviews authorizationclient.SubjectAccessReviewInterface) *metricsAuthHandler {
+	return &metricsAuthHandler{
+		next:     next,
+		tokens:   tokens,
+		reviews:  reviews,
+		resource: cfg.Resource,
+		reviewed: map[[sha256.Size]byte]reviewOutcome{},
+		now:      time.Now,
+	}
+}
+
+// reviewOutcome is who a token belongs to, and the status code of the requests with it, http.StatusOK when allowed
+type reviewOutcome struct {
+	username string
+	code     int
+	expiry   time.Time
+}
+
+// cached is the outcome of the last review of the token, verb and path, when it has not expired
+func (h *metricsAuthHandler) cached(key [sha256.Size]byte) (reviewOutcome, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	outcome, ok := h.reviewed[key]
+	return outcome, ok && h.now().Before(outcome.expiry)
+}
+
+// remember keeps the outcome for metricsAuthCacheTTL when allowed, and metricsAuthDeniedCacheTTL when not; the
+// reviews that failed are not remembered, as the API server being unavailable says nothing about the token
+func (h *metricsAuthHandler) remember(key [sha256.Size]byte, username string, code int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if len(h.reviewed) >= maxMetricsAuthCacheEntries {
+		h.reviewed = map[[sha256.Size]byte]reviewOutcome{}
+	}
+	ttl := metricsAuthCacheTTL
+	if code != http.StatusOK {
+		ttl = metricsAuthDeniedCacheTTL
+	}
+	h.reviewed[key] = reviewOutcome{username: username, code: code, expiry: h.now().Add(ttl)}
+}
+
+// identify names the scraper of the request, when the request is counted by scraper
+func identify(r *http.Request, username string) {
+	if s := scraperFrom(r); s != nil {
+		s.name = username
+	}
+}
+
+// nonResourceVerb is the verb a request is authorized with as a non-resource URL, the lowercase HTTP method like the
+// API server does, so being allowed to get a path, say the metrics, does not allow posting to it
+func nonResourceVerb(r *http.Request) string {
+	if r.Method == http.MethodHead || len(r.Method) == 0 {
+		return "get"
+	}
+	return strings.ToLower(r.Method)
+}
+
+func (h *metricsAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if len(token) == 0 || token == header {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	verb := nonResourceVerb(r)
+	key := sha256.Sum256([]byte(token + "\x00" + verb + "\x00" + r.URL.Path))
+	if outcome, ok := h.cached(key); ok {
+		identify(r, outcome.username)
+		if outcome.code != http.StatusOK {
+			http.Error(w, http.StatusText(outcome.code), outcome.code)
+			return
+		}
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	log := ctrl.Log.WithName("metrics")
+	tr, err := h.tokens.Create(r.Context(), &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}, metav1.CreateOptions{})
+	if err != nil {
+		log.Error(err, "unable to review the token of a metrics request")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !tr.Status.Authenticated {
+		h.remember(key, "", http.StatusUnauthorized)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user := tr.Status.User
+	identify(r, user.Username)
+	sar := &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:   user.Username,
+		Groups: user.Groups,
+		UID:    user.UID,
+		Extra:  map[string]authorizationv1.ExtraValue{},
+	}}
+	for k, v := range user.Extra {
+		sar.Spec.Extra[k] = authorizationv1.ExtraValue(v)
+	}
+	if h.resource != nil {
+		sar.Spec.ResourceAttributes = h.resource.DeepCopy()
+	} else {
+		sar.Spec.NonResourceAttributes = &authorizationv1.NonResourceAttributes{Path: r.URL.Path, Verb: verb}
+	}
+	sar, err = h.reviews.Create(r.Context(), sar, metav1.CreateOptions{})
+	if err != nil {
+		log.Error(err, "unable to review the access of a metrics request", "user", user.Username)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if !sar.Status.Allowed {
+		log.V(4).Info("denied a metrics request", "user", user.Username, "path", r.URL.Path, "reason", sar.Status.Reason)
+		h.remember(key, user.Username, http.StatusForbidden)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	h.remember(key, user.Username, http.StatusOK)
+	h.next.ServeHTTP(w, r)
+}
@@ -0,0 +1,118 @@
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// reviewsFor authenticates the tokens as the users of the map, and allows the users of allowed
+func reviewsFor(users map[string]string, allowed map[string]bool, reviews *[]*authorizationv1.SubjectAccessReview) *kubefake.Clientset {
+	kube := kubefake.NewSimpleClientset()
+	kube.PrependReactor("create", "tokenreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		user, ok := users[review.Spec.Token]
+		review.Status.Authenticated = ok
+		review.Status.User = authenticationv1.UserInfo{Username: user, Groups: []string{"system:authenticated"}}
+		return true, review, nil
+	})
+	kube.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		*reviews = append(*reviews, review.DeepCopy())
+		review.Status.Allowed = allowed[review.Spec.User]
+		return true, review, nil
+	})
+	return kube
+}
+
+func scrape(h http.Handler, token string) int {
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if len(token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestMetricsAuthHandler(t *testing.T) {
+	reviews := []*authorizationv1.SubjectAccessReview{}
+	kube := reviewsFor(map[string]string{"good": "prometheus", "other": "someone"}, map[string]bool{"prometheus": true}, &reviews)
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := newMetricsAuthHandler(next, &MetricsAuthConfig{}, kube.AuthenticationV1().TokenReviews(), kube.AuthorizationV1().SubjectAccessReviews())
+	now := time.Now()
+	h.now = func() time.Time { return now }
+
+	assert.Equal(t, http.StatusUnauthorized, scrape(h, ""))
+	assert.Equal(t, http.StatusUnauthorized, scrape(h, "bad"))
+	assert.Equal(t, http.StatusForbidden, scrape(h, "other"))
+	assert.Equal(t, http.StatusOK, scrape(h, "good"))
+	assert.Len(t, reviews, 2)
+	assert.Equal(t, &authorizationv1.NonResourceAttributes{Path: "/metrics", Verb: "get"}, reviews[1].Spec.NonResourceAttributes)
+	assert.Nil(t, reviews[1].Spec.ResourceAttributes)
+
+	// the allowed token is not reviewed again until the cache expires
+	assert.Equal(t, http.StatusOK, scrape(h, "good"))
+	assert.Len(t, reviews, 2)
+	now = now.Add(metricsAuthCacheTTL)
+	assert.Equal(t, http.StatusOK, scrape(h, "good"))
+	assert.Len(t, reviews, 3)
+
+	// the denied token is only reviewed again once its shorter cache expires, so a new binding takes effect soon
+	assert.Equal(t, http.StatusForbidden, scrape(h, "other"))
+	assert.Len(t, reviews, 4)
+	assert.Equal(t, http.StatusForbidden, scrape(h, "other"))
+	assert.Equal(t, http.StatusUnauthorized, scrape(h, "bad"))
+	assert.Len(t, reviews, 4)
+	now = now.Add(metricsAuthDeniedCacheTTL)
+	assert.Equal(t, http.StatusForbidden, scrape(h, "other"))
+	assert.Len(t, reviews, 5)
+
+	// posting is reviewed separately from getting, with the post verb
+	r := httptest.NewRequest(http.MethodPost, "/metrics", nil)
+	r.Header.Set("Authorization", "Bearer good")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, reviews, 6)
+	assert.Equal(t, &authorizationv1.NonResourceAttributes{Path: "/metrics", Verb: "post"}, reviews[5].Spec.NonResourceAttributes)
+}
+
+func TestMetricsAuthHandlerResource(t *testing.T) {
+	reviews := []*authorizationv1.SubjectAccessReview{}
+	kube := reviewsFor(map[string]string{"good": "prometheus"}, map[string]bool{"prometheus": true}, &reviews)
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	resource, err := ParseResourceAttributes("openshift-pipelines/services/pipeline-service-exporter")
+	assert.NoError(t, err)
+	h := newMetricsAuthHandler(next, &MetricsAuthConfig{Resource: resource}, kube.AuthenticationV1().TokenReviews(), kube.AuthorizationV1().SubjectAccessReviews())
+
+	assert.Equal(t, http.StatusOK, scrape(h, "good"))
+	assert.Len(t, reviews, 1)
+	assert.Equal(t, resource, reviews[0].Spec.ResourceAttributes)
+	assert.Nil(t, reviews[0].Spec.NonResourceAttributes)
+	assert.Equal(t, []string{"system:authenticated"}, reviews[0].Spec.Groups)
+}
+
+func TestParseResourceAttributes(t *testing.T) {
+	for spec, expected := range map[string]*authorizationv1.ResourceAttributes{
+		"services":                            {Verb: "get", Resource: "services"},
+		"openshift-pipelines/services":        {Verb: "get", Namespace: "openshift-pipelines", Resource: "services"},
+		"ns/pipelineruns.tekton.dev/exporter": {Verb: "get", Namespace: "ns", Resource: "pipelineruns", Group: "tekton.dev", Name: "exporter"},
+	} {
+		actual, err := ParseResourceAttributes(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, expected, actual, spec)
+	}
+	for _, spec := range []string{"", "ns/", "a/b/c/d", "/services"} {
+		_, err := ParseResourceAttributes(spec)
+		assert.Error(t, err, spec)
+	}
+}
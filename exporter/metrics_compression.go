@@ -0,0 +1,231 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// DefaultMetricsCompression is what the metrics are compressed with when Config.MetricsCompression is nil, which is
+// what promhttp does on its own
+var DefaultMetricsCompression = []string{EncodingGzip}
+
+// ParseMetricsCompression parses a comma separated list of encodings, in order of preference; none, or an empty list,
+// turns compression off
+func ParseMetricsCompression(list string) ([]string, error) {
+	encodings := []string{}
+	for _, encoding := range strings.Split(list, ",") {
+		switch encoding = strings.TrimSpace(encoding); encoding {
+		case EncodingGzip, EncodingZstd:
+			encodings = append(encodings, encoding)
+		case "", "none":
+		default:
+			return nil, fmt.Errorf("%q is not a supported encoding, only %s and %s are", encoding, EncodingGzip, EncodingZstd)
+		}
+	}
+	return encodings, nil
+}
+
+// acceptedEncodings are the encodings of the Accept-Encoding header, minus those with a q of 0
+func acceptedEncodings(header string) map[string]bool {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		encoding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if value, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil && value == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(encoding))] = true
+	}
+	return accepted
+}
+
+// bufferedResponse holds the response of the wrapped handler, up to limit bytes of body when limit is positive
+type bufferedResponse struct {
+	header   http.Header
+	code     int
+	body     bytes.Buffer
+	limit    int64
+	exceeded bool
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	b.code = code
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errResponseTooLarge
+	}
+	if b.limit > 0 && int64(b.body.Len()+len(p)) > b.limit {
+		b.exceeded = true
+		b.body.Reset()
+		return 0, errResponseTooLarge
+	}
+	return b.body.Write(p)
+}
+
+var errResponseTooLarge = errors.New("the response is larger than the limit")
+
+// responseEncoder compresses the responses of the metrics listener with the encodings the scraper accepts, and fails
+// the responses larger than maxBytes uncompressed, so runaway cardinality gets an error vs. a body large enough to
+// knock over the scraping Prometheus.  The responses are streamed through pooled compressors, and only buffered,
+// uncompressed, when maxBytes is set, as the limit has to be enforced before anything is sent
+type responseEncoder struct {
+	next      http.Handler
+	mux       *http.ServeMux
+	encodings []string
+	maxBytes  int64
+	oversized *prometheus.CounterVec
+	// compressors are the pools of the compressors, by encoding
+	compressors map[string]*sync.Pool
+}
+
+// compressor is a gzip.Writer or a zstd.Encoder
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+func newResponseEncoder(next http.Handler, mux *http.ServeMux, encodings []string, maxBytes int64, oversized *prometheus.CounterVec) (*responseEncoder, error) {
+	e := &responseEncoder{next: next, mux: mux, encodings: encodings, maxBytes: maxBytes, oversized: oversized, compressors: map[string]*sync.Pool{}}
+	for _, encoding := range encodings {
+		switch encoding {
+		case EncodingGzip:
+			e.compressors[encoding] = &sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+		case EncodingZstd:
+			// a pooled encoder serves one scrape at a time, so it needs none of the goroutines of a concurrent one
+			newZstd := func() (*zstd.Encoder, error) {
+				return zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+			}
+			enc, err := newZstd()
+			if err != nil {
+				return nil, err
+			}
+			pool := &sync.Pool{New: func() interface{} {
+				enc, _ := newZstd()
+				return enc
+			}}
+			pool.Put(enc)
+			e.compressors[encoding] = pool
+		}
+	}
+	return e, nil
+}
+
+// encoding is the most preferred of the encodings the request accepts, empty for none
+func (e *responseEncoder) encoding(r *http.Request) string {
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	for _, encoding := range e.encodings {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// encodedResponse writes the body through a pooled compressor of the encoding, if any, taken once the status code is
+// written, and given back by close
+type encodedResponse struct {
+	http.ResponseWriter
+	pool        *sync.Pool
+	encoding    string
+	compressor  compressor
+	wroteHeader bool
+}
+
+func (w *encodedResponse) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.Header().Add("Vary", "Accept-Encoding")
+	// the responses without a body stay without one
+	if len(w.encoding) > 0 && code != http.StatusNoContent && code != http.StatusNotModified {
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Encoding", w.encoding)
+		w.compressor = w.pool.Get().(compressor)
+		w.compressor.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *encodedResponse) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.compressor == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.compressor.Write(p)
+}
+
+// close flushes the compressor, and puts it back in its pool
+func (w *encodedResponse) close() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.compressor == nil {
+		return
+	}
+	if err := w.compressor.Close(); err != nil {
+		ctrl.Log.WithName("metrics").V(2).Info("unable to finish a compressed response", "encoding", w.encoding, "error", err.Error())
+	}
+	w.compressor.Reset(nil)
+	w.pool.Put(w.compressor)
+	w.compressor = nil
+}
+
+func (e *responseEncoder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	encoding := e.encoding(r)
+	out := &encodedResponse{ResponseWriter: w, pool: e.compressors[encoding], encoding: encoding}
+	// the wrapped handlers, promhttp ones, would compress on their own otherwise
+	inner := r.Clone(r.Context())
+	inner.Header.Del("Accept-Encoding")
+	if e.maxBytes <= 0 {
+		e.next.ServeHTTP(out, inner)
+		out.close()
+		return
+	}
+
+	buf := &bufferedResponse{header: http.Header{}, code: http.StatusOK, limit: e.maxBytes}
+	e.next.ServeHTTP(buf, inner)
+	if buf.exceeded {
+		_, path := e.mux.Handler(r)
+		if len(path) == 0 {
+			path = pathUnknown
+		}
+		e.oversized.With(prometheus.Labels{PATH_LABEL: path}).Inc()
+		msg := fmt.Sprintf("the response to %s is larger than the limit of %d bytes, look for a metric with runaway cardinality", r.URL.Path, e.maxBytes)
+		ctrl.Log.WithName("metrics").Info(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+	for name, values := range buf.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(buf.body.Len()))
+	out.WriteHeader(buf.code)
+	_, _ = out.Write(buf.body.Bytes())
+	out.close()
+}
@@ -0,0 +1,135 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMetricsCompression(t *testing.T) {
+	encodings, err := ParseMetricsCompression("zstd, gzip")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{EncodingZstd, EncodingGzip}, encodings)
+	for _, list := range []string{"", "none"} {
+		encodings, err = ParseMetricsCompression(list)
+		assert.NoError(t, err)
+		assert.Empty(t, encodings)
+		assert.NotNil(t, encodings)
+	}
+	_, err = ParseMetricsCompression("gzip,br")
+	assert.Error(t, err)
+}
+
+func TestAcceptedEncodings(t *testing.T) {
+	assert.Equal(t, map[string]bool{"gzip": true, "identity": true}, acceptedEncodings("gzip, zstd;q=0, identity;q=0.5"))
+	assert.Equal(t, map[string]bool{"": true}, acceptedEncodings(""))
+}
+
+func encoded(t *testing.T, e *responseEncoder, acceptEncoding string) (*httptest.ResponseRecorder, string) {
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if len(acceptEncoding) > 0 {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, r)
+	var body io.Reader = w.Body
+	switch w.Header().Get("Content-Encoding") {
+	case EncodingGzip:
+		gz, err := gzip.NewReader(w.Body)
+		assert.NoError(t, err)
+		body = gz
+	case EncodingZstd:
+		zr, err := zstd.NewReader(w.Body)
+		assert.NoError(t, err)
+		defer zr.Close()
+		body = zr
+	}
+	buf, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	return w, string(buf)
+}
+
+func TestResponseEncoder(t *testing.T) {
+	metrics := strings.Repeat("pipeline_service_pipelinerun_total 1\n", 100)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the wrapped handler is never asked to compress
+		assert.Empty(t, r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(metrics))
+	}))
+	oversized := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "oversized"}, []string{PATH_LABEL})
+	e, err := newResponseEncoder(mux, mux, []string{EncodingZstd, EncodingGzip}, 0, oversized)
+	assert.NoError(t, err)
+
+	for acceptEncoding, expected := range map[string]string{
+		"gzip, zstd":      EncodingZstd,
+		"gzip":            EncodingGzip,
+		"zstd;q=0, gzip":  EncodingGzip,
+		"br":              "",
+		"":                "",
+		"deflate, gzip;q": EncodingGzip,
+	} {
+		w, body := encoded(t, e, acceptEncoding)
+		assert.Equal(t, http.StatusOK, w.Code, acceptEncoding)
+		assert.Equal(t, expected, w.Header().Get("Content-Encoding"), acceptEncoding)
+		assert.Equal(t, "text/plain", w.Header().Get("Content-Type"), acceptEncoding)
+		assert.Equal(t, metrics, body, acceptEncoding)
+	}
+
+	// the streamed responses cannot know their compressed length
+	w, _ := encoded(t, e, "gzip")
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+
+	// within the limit, the buffered responses go through the pooled compressors the same
+	e, err = newResponseEncoder(mux, mux, []string{EncodingZstd, EncodingGzip}, int64(len(metrics)), oversized)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		for _, acceptEncoding := range []string{"zstd", "gzip"} {
+			w, body := encoded(t, e, acceptEncoding)
+			assert.Equal(t, acceptEncoding, w.Header().Get("Content-Encoding"))
+			assert.Empty(t, w.Header().Get("Content-Length"))
+			assert.Equal(t, metrics, body)
+		}
+	}
+
+	// no compression
+	e, err = newResponseEncoder(mux, mux, []string{}, int64(len(metrics)), oversized)
+	assert.NoError(t, err)
+	w, body := encoded(t, e, "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, strconv.Itoa(len(metrics)), w.Header().Get("Content-Length"))
+	assert.Equal(t, metrics, body)
+
+	// one byte over the limit
+	e, err = newResponseEncoder(mux, mux, []string{EncodingGzip}, int64(len(metrics))-1, oversized)
+	assert.NoError(t, err)
+	w, body = encoded(t, e, "gzip")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, body, "runaway cardinality")
+	assert.Equal(t, 1.0, testutil.ToFloat64(oversized.With(prometheus.Labels{PATH_LABEL: "/metrics"})))
+}
+
+func TestResponseEncoderPromhttp(t *testing.T) {
+	// the limit stops promhttp from encoding the rest of the families
+	registry := prometheus.NewRegistry()
+	s, err := New(Config{MetricsMaxResponseBytes: 10}).metricsServer(registry)
+	assert.NoError(t, err)
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.False(t, bytes.Contains(w.Body.Bytes(), []byte("# HELP")))
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "pipeline_service_exporter_scrape_oversized_responses_total"))
+}
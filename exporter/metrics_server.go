@@ -0,0 +1,312 @@
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	SCRAPER_LABEL = "scraper"
+	PATH_LABEL    = "path"
+	CODE_LABEL    = "code"
+
+	// maxScrapers is how many distinct scrapers get their own label value; the ones seen after that share
+	// scraperOverflow, so a misbehaving client cycling through identities cannot blow up the series
+	maxScrapers     = 50
+	scraperOverflow = "other"
+	// scraperUnauthenticated is the scraper of the requests without an authenticated identity; their addresses are
+	// only logged, as pod IPs churn, and anyone can send requests from any number of them
+	scraperUnauthenticated = "unauthenticated"
+	// scraperIdleTTL is how long a scraper keeps its label value, and its series, without scraping
+	scraperIdleTTL = time.Hour
+	// pathUnknown is the path label of the requests for paths that are not served
+	pathUnknown = "unknown"
+)
+
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 30 * time.Second
+	// DefaultWriteTimeout leaves room for the scrapes of large responses over slow networks
+	DefaultWriteTimeout   = 2 * time.Minute
+	DefaultIdleTimeout    = 2 * time.Minute
+	DefaultMaxHeaderBytes = 64 << 10
+)
+
+// ServerConfig are the timeouts and limits of the metrics listener, so slow or idle clients cannot hold on to its
+// connections; the zero values get the defaults, and the negative timeouts are off
+type ServerConfig struct {
+	// ReadHeaderTimeout is how long the client has to send the request headers, DefaultReadHeaderTimeout when 0, and
+	// ReadTimeout when negative
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout is how long the client has to send the whole request, DefaultReadTimeout when 0, none when negative
+	ReadTimeout time.Duration
+	// WriteTimeout is how long the response has to be written, from the end of the request headers,
+	// DefaultWriteTimeout when 0, none when negative
+	WriteTimeout time.Duration
+	// IdleTimeout is how long a keep-alive connection is kept open waiting for the next request, DefaultIdleTimeout
+	// when 0, and ReadTimeout when negative
+	IdleTimeout time.Duration
+	// MaxHeaderBytes is the maximum size of the request headers, DefaultMaxHeaderBytes when 0
+	MaxHeaderBytes int
+	// HTTP2 serves HTTP/2 next to HTTP/1.1, negotiated with ALPN over TLS, and as h2c over plain HTTP
+	HTTP2 bool
+}
+
+// DisableZeroTimeouts turns the timeouts that are 0 off, for the flags, where 0 is what turns a timeout off, vs. the
+// zero value of the struct, which gets the defaults
+func (c ServerConfig) DisableZeroTimeouts() ServerConfig {
+	for _, timeout := range []*time.Duration{&c.ReadHeaderTimeout, &c.ReadTimeout, &c.WriteTimeout, &c.IdleTimeout} {
+		if *timeout == 0 {
+			*timeout = -1
+		}
+	}
+	return c
+}
+
+// orDefault is d, def when 0, and 0, which the http.Server takes as no timeout, when negative
+func orDefault(d, def time.Duration) time.Duration {
+	switch {
+	case d < 0:
+		return 0
+	case d == 0:
+		return def
+	}
+	return d
+}
+
+// httpServer is the http.Server for the config, with handler, and tlsConfig when set, which is updated to negotiate
+// HTTP/2 when it is on
+func (c ServerConfig) httpServer(handler http.Handler, tlsConfig *tls.Config) (*http.Server, error) {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: orDefault(c.ReadHeaderTimeout, DefaultReadHeaderTimeout),
+		ReadTimeout:       orDefault(c.ReadTimeout, DefaultReadTimeout),
+		WriteTimeout:      orDefault(c.WriteTimeout, DefaultWriteTimeout),
+		IdleTimeout:       orDefault(c.IdleTimeout, DefaultIdleTimeout),
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+		TLSConfig:         tlsConfig,
+	}
+	if srv.MaxHeaderBytes <= 0 {
+		srv.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	if !c.HTTP2 {
+		// without TLSNextProto set, the server would negotiate HTTP/2 on its own with a TLS config
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		return srv, nil
+	}
+	h2 := &http2.Server{IdleTimeout: srv.IdleTimeout}
+	if tlsConfig == nil {
+		srv.Handler = h2c.NewHandler(handler, h2)
+		return srv, nil
+	}
+	return srv, http2.ConfigureServer(srv, h2)
+}
+
+// metricsServer serves the metrics, and the extra handlers of the collectors, in place of the manager's metrics
+// listener, which can neither be given a TLS configuration nor tell who is scraping
+type metricsServer struct {
+	addr      string
+	tlsConfig *tls.Config
+	server    ServerConfig
+	mux       *http.ServeMux
+	handler   http.Handler
+}
+
+// newMetricsServer serves the metrics on path, over TLS when cfg is set, and plain HTTP when not
+func newMetricsServer(addr, path string, cfg *TLSServerConfig, server ServerConfig) (*metricsServer, error) {
+	s := &metricsServer{addr: addr, server: server, mux: http.NewServeMux()}
+	if cfg != nil {
+		tlsConfig, err := cfg.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		s.tlsConfig = tlsConfig
+		s.server.HTTP2 = s.server.HTTP2 && !cfg.DisableHTTP2
+	}
+	// the responses are compressed by the responseEncoder
+	s.mux.Handle(path, promhttp.HandlerFor(collector.ServedGatherer, promhttp.HandlerOpts{ErrorHandling: promhttp.HTTPErrorOnError, DisableCompression: true}))
+	for extra, handler := range collector.MetricsExtraHandlers() {
+		s.mux.Handle(extra, handler)
+	}
+	s.handler = s.mux
+	return s, nil
+}
+
+func (s *metricsServer) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.serve(ctx, ln)
+}
+
+func (s *metricsServer) serve(ctx context.Context, ln net.Listener) error {
+	log := ctrl.Log.WithName("metrics")
+	srv, err := s.server.httpServer(s.handler, s.tlsConfig)
+	if err != nil {
+		return err
+	}
+	if srv.TLSConfig != nil {
+		ln = tls.NewListener(ln, srv.TLSConfig)
+	}
+	go func() {
+		log.Info("serving the metrics", "addr", ln.Addr().String(), "tls", s.tlsConfig != nil,
+			"client certificates", s.tlsConfig != nil && s.tlsConfig.ClientAuth == tls.RequireAndVerifyClientCert, "http2", s.server.HTTP2)
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(err, "the metrics server stopped")
+		}
+	}()
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// NeedLeaderElection is false, so every replica serves its own metrics
+func (s *metricsServer) NeedLeaderElection() bool {
+	return false
+}
+
+// scraper is who sent a request to the metrics server; the auth handler names it after the user of the token
+type scraper struct {
+	name string
+}
+
+type scraperKey struct{}
+
+func contextWithScraper(r *http.Request, s *scraper) context.Context {
+	return context.WithValue(r.Context(), scraperKey{}, s)
+}
+
+func scraperFrom(r *http.Request) *scraper {
+	s, _ := r.Context().Value(scraperKey{}).(*scraper)
+	return s
+}
+
+// scraperIdentity is the user of the token, or of basic auth, when the scrapers authenticate with one, else the common
+// name of the verified client certificate, else scraperUnauthenticated
+func scraperIdentity(r *http.Request, s *scraper) string {
+	if len(s.name) > 0 {
+		return s.name
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		if cn := r.TLS.VerifiedChains[0][0].Subject.CommonName; len(cn) > 0 {
+			return cn
+		}
+	}
+	return scraperUnauthenticated
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// scrapeMetrics counts and times the requests to the metrics server by scraper, logging the scrapers the first time
+// they are seen, so rogue scrapers show up, and comparing the durations with the scrape_duration_seconds of the
+// scraping Prometheus tells the time spent in the exporter from the time spent in the network
+type scrapeMetrics struct {
+	requests  *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+	oversized *prometheus.CounterVec
+	lock      sync.Mutex
+	// scrapers are when each scraper with a label value of its own last scraped
+	scrapers map[string]time.Time
+	swept    time.Time
+	now      func() time.Time
+}
+
+func newScrapeMetrics(registerer prometheus.Registerer) (*scrapeMetrics, error) {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_scrape_requests_total",
+		Help: "Number of requests to the metrics server by scraper, path, and status code",
+	}, []string{SCRAPER_LABEL, PATH_LABEL, CODE_LABEL})
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pipeline_service_exporter_scrape_duration_seconds",
+		Help:    "Duration in seconds of serving the requests to the metrics server, from reading the request to writing the last byte of the response, by scraper and path",
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{SCRAPER_LABEL, PATH_LABEL})
+	oversized := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pipeline_service_exporter_scrape_oversized_responses_total",
+		Help: "Number of requests to the metrics server failed as their response was larger than the maximum response size, by path",
+	}, []string{PATH_LABEL})
+	for _, c := range []prometheus.Collector{requests, duration, oversized} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return &scrapeMetrics{requests: requests, duration: duration, oversized: oversized, scrapers: map[string]time.Time{}, swept: time.Now(), now: time.Now}, nil
+}
+
+// admit is the label value of the scraper, and whether it was not seen before, or since it was evicted
+func (m *scrapeMetrics) admit(name string) (string, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := m.now()
+	if now.Sub(m.swept) >= time.Minute || len(m.scrapers) >= maxScrapers {
+		m.evictIdle(now)
+	}
+	_, known := m.scrapers[name]
+	if !known && len(m.scrapers) >= maxScrapers {
+		return scraperOverflow, false
+	}
+	m.scrapers[name] = now
+	return name, !known
+}
+
+// evictIdle drops the scrapers that did not scrape within scraperIdleTTL, with their series, making room for new
+// ones; the lock is held by the caller
+func (m *scrapeMetrics) evictIdle(now time.Time) {
+	m.swept = now
+	for name, seen := range m.scrapers {
+		if now.Sub(seen) < scraperIdleTTL {
+			continue
+		}
+		delete(m.scrapers, name)
+		m.requests.DeletePartialMatch(prometheus.Labels{SCRAPER_LABEL: name})
+		m.duration.DeletePartialMatch(prometheus.Labels{SCRAPER_LABEL: name})
+	}
+}
+
+// handler wraps next, with the paths of the requests labelled by the pattern of mux they match
+func (m *scrapeMetrics) handler(next http.Handler, mux *http.ServeMux) http.Handler {
+	log := ctrl.Log.WithName("metrics")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		s := &scraper{}
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(contextWithScraper(r, s)))
+		elapsed := time.Since(start)
+
+		identity := scraperIdentity(r, s)
+		name, isNew := m.admit(identity)
+		if isNew {
+			log.Info("a new scraper is scraping the metrics", "scraper", identity, "remote", r.RemoteAddr, "path", r.URL.Path)
+		}
+		_, path := mux.Handler(r)
+		if len(path) == 0 {
+			path = pathUnknown
+		}
+		m.requests.With(prometheus.Labels{SCRAPER_LABEL: name, PATH_LABEL: path, CODE_LABEL: strconv.Itoa(rec.code)}).Inc()
+		m.duration.With(prometheus.Labels{SCRAPER_LABEL: name, PATH_LABEL: path}).Observe(elapsed.Seconds())
+		log.V(2).Info("scrape", "scraper", identity, "remote", r.RemoteAddr, "path", r.URL.Path, "code", rec.code, "duration", elapsed.String())
+	})
+}
@@ -0,0 +1,205 @@
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+func scrapeAs(h http.Handler, remote, path, token string, cert *x509.Certificate) int {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.RemoteAddr = remote
+	if len(token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if cert != nil {
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestScrapeMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	s, err := New(Config{}).metricsServer(registry)
+	assert.NoError(t, err)
+	assert.Nil(t, s.tlsConfig)
+	assert.Equal(t, http.StatusOK, scrapeAs(s.handler, "10.0.0.1:41000", "/metrics", "", nil))
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "pipeline_service_exporter_scrape_requests_total"))
+	// the scrape metrics are registered once per registry
+	_, err = New(Config{}).metricsServer(registry)
+	assert.Error(t, err)
+
+	m, err := newScrapeMetrics(prometheus.NewRegistry())
+	assert.NoError(t, err)
+	h := m.handler(s.mux, s.mux)
+	cert := newTestCert(t, "prometheus-k8s", nil, false).cert
+	assert.Equal(t, http.StatusOK, scrapeAs(h, "10.0.0.1:41000", "/metrics", "", nil))
+	assert.Equal(t, http.StatusOK, scrapeAs(h, "10.0.0.1:41001", "/metrics", "", nil))
+	assert.Equal(t, http.StatusOK, scrapeAs(h, "10.0.0.2:41000", "/metrics", "", cert))
+	assert.Equal(t, http.StatusNotFound, scrapeAs(h, "10.0.0.3:41000", "/nothing", "", nil))
+
+	// the addresses are not identities, the callers without one share a label value
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.With(prometheus.Labels{SCRAPER_LABEL: scraperUnauthenticated, PATH_LABEL: "/metrics", CODE_LABEL: "200"})))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.With(prometheus.Labels{SCRAPER_LABEL: "prometheus-k8s", PATH_LABEL: "/metrics", CODE_LABEL: "200"})))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.With(prometheus.Labels{SCRAPER_LABEL: scraperUnauthenticated, PATH_LABEL: pathUnknown, CODE_LABEL: "404"})))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.duration))
+}
+
+func TestScrapeMetricsAuth(t *testing.T) {
+	reviews := []*authorizationv1.SubjectAccessReview{}
+	kube := reviewsFor(map[string]string{"good": "system:serviceaccount:openshift-monitoring:prometheus-k8s", "other": "someone"},
+		map[string]bool{"system:serviceaccount:openshift-monitoring:prometheus-k8s": true}, &reviews)
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", next)
+	m, err := newScrapeMetrics(prometheus.NewRegistry())
+	assert.NoError(t, err)
+	h := m.handler(newMetricsAuthHandler(mux, &MetricsAuthConfig{}, kube.AuthenticationV1().TokenReviews(), kube.AuthorizationV1().SubjectAccessReviews()), mux)
+
+	assert.Equal(t, http.StatusOK, scrapeAs(h, "10.0.0.1:41000", "/metrics", "good", nil))
+	// the cached outcome still names the scraper
+	assert.Equal(t, http.StatusOK, scrapeAs(h, "10.0.0.1:41000", "/metrics", "good", nil))
+	assert.Equal(t, http.StatusForbidden, scrapeAs(h, "10.0.0.2:41000", "/metrics", "other", nil))
+	assert.Equal(t, http.StatusUnauthorized, scrapeAs(h, "10.0.0.3:41000", "/metrics", "", nil))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.With(prometheus.Labels{SCRAPER_LABEL: "system:serviceaccount:openshift-monitoring:prometheus-k8s", PATH_LABEL: "/metrics", CODE_LABEL: "200"})))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.With(prometheus.Labels{SCRAPER_LABEL: "someone", PATH_LABEL: "/metrics", CODE_LABEL: "403"})))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.With(prometheus.Labels{SCRAPER_LABEL: scraperUnauthenticated, PATH_LABEL: "/metrics", CODE_LABEL: "401"})))
+}
+
+func TestScrapeMetricsOverflow(t *testing.T) {
+	m, err := newScrapeMetrics(prometheus.NewRegistry())
+	assert.NoError(t, err)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	for i := 0; i < maxScrapers; i++ {
+		name, isNew := m.admit(fmt.Sprintf("scraper-%d", i))
+		assert.True(t, isNew)
+		assert.Equal(t, fmt.Sprintf("scraper-%d", i), name)
+		m.requests.With(prometheus.Labels{SCRAPER_LABEL: name, PATH_LABEL: "/metrics", CODE_LABEL: "200"}).Inc()
+	}
+	name, isNew := m.admit("scraper-new")
+	assert.False(t, isNew)
+	assert.Equal(t, scraperOverflow, name)
+	name, isNew = m.admit("scraper-1")
+	assert.False(t, isNew)
+	assert.Equal(t, "scraper-1", name)
+
+	// the idle scrapers are evicted, with their series, making room for the new ones
+	now = now.Add(scraperIdleTTL / 2)
+	m.admit("scraper-2")
+	now = now.Add(scraperIdleTTL / 2)
+	name, isNew = m.admit("scraper-new")
+	assert.True(t, isNew)
+	assert.Equal(t, "scraper-new", name)
+	assert.Len(t, m.scrapers, 2)
+	assert.Equal(t, 1, testutil.CollectAndCount(m.requests))
+	name, isNew = m.admit("scraper-0")
+	assert.True(t, isNew)
+	assert.Equal(t, "scraper-0", name)
+}
+
+func TestServerConfig(t *testing.T) {
+	srv, err := ServerConfig{}.httpServer(http.NotFoundHandler(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultReadHeaderTimeout, srv.ReadHeaderTimeout)
+	assert.Equal(t, DefaultReadTimeout, srv.ReadTimeout)
+	assert.Equal(t, DefaultWriteTimeout, srv.WriteTimeout)
+	assert.Equal(t, DefaultIdleTimeout, srv.IdleTimeout)
+	assert.Equal(t, DefaultMaxHeaderBytes, srv.MaxHeaderBytes)
+	assert.NotNil(t, srv.TLSNextProto)
+
+	srv, err = ServerConfig{ReadHeaderTimeout: time.Second, ReadTimeout: 2 * time.Second, WriteTimeout: 3 * time.Second, IdleTimeout: 4 * time.Second, MaxHeaderBytes: 1024}.httpServer(http.NotFoundHandler(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, srv.ReadHeaderTimeout)
+	assert.Equal(t, 2*time.Second, srv.ReadTimeout)
+	assert.Equal(t, 3*time.Second, srv.WriteTimeout)
+	assert.Equal(t, 4*time.Second, srv.IdleTimeout)
+	assert.Equal(t, 1024, srv.MaxHeaderBytes)
+
+	// the negative timeouts are off, and so are those set to 0 with the flags
+	srv, err = ServerConfig{ReadHeaderTimeout: -1, ReadTimeout: -1, WriteTimeout: -1, IdleTimeout: -1}.httpServer(http.NotFoundHandler(), nil)
+	assert.NoError(t, err)
+	assert.Zero(t, srv.ReadHeaderTimeout)
+	assert.Zero(t, srv.ReadTimeout)
+	assert.Zero(t, srv.WriteTimeout)
+	assert.Zero(t, srv.IdleTimeout)
+	assert.Equal(t, ServerConfig{ReadHeaderTimeout: -1, ReadTimeout: time.Second, WriteTimeout: -1, IdleTimeout: -1},
+		ServerConfig{ReadTimeout: time.Second}.DisableZeroTimeouts())
+}
+
+// serveMetrics serves the metrics until the test ends, returning the address
+func serveMetrics(t *testing.T, s *metricsServer) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return ln.Addr().String()
+}
+
+func TestMetricsServerH2C(t *testing.T) {
+	s, err := newMetricsServer("127.0.0.1:0", DefaultMetricsPath, nil, ServerConfig{HTTP2: true})
+	assert.NoError(t, err)
+	addr := serveMetrics(t, s)
+
+	// prior knowledge h2c
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err := client.Get("http://" + addr + "/metrics")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, resp.ProtoMajor)
+		resp.Body.Close()
+	}
+	resp, err = http.Get("http://" + addr + "/metrics")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, resp.ProtoMajor)
+		resp.Body.Close()
+	}
+}
+
+func TestMetricsServerHTTP2OverTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil, false)
+	server := newTestCert(t, "exporter", ca, true)
+	cfg := &TLSServerConfig{CertFile: writeFile(t, dir, "tls.crt", server.pem), KeyFile: writeFile(t, dir, "tls.key", server.keyPEM(t))}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	for _, enabled := range []bool{true, false} {
+		s, err := newMetricsServer("127.0.0.1:0", DefaultMetricsPath, cfg, ServerConfig{HTTP2: enabled})
+		assert.NoError(t, err)
+		addr := serveMetrics(t, s)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true}}
+		resp, err := client.Get("https://" + addr + "/metrics")
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, enabled, resp.ProtoMajor == 2)
+			resp.Body.Close()
+		}
+		client.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,432 @@
+package exporter
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	config_util "github.com/prometheus/common/config"
+	"github.com/prometheus/exporter-toolkit/web"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	ClientAuthNone             = "NoClientCert"
+	ClientAuthRequireAndVerify = "RequireAndVerifyClientCert"
+)
+
+// TLSServerConfig is the tls_server_config of an exporter-toolkit web configuration file, limited to what the
+// exporter supports, so the same file can be shared with the other exporters of a cluster; ClientAllowedCNs is the
+// exporter's own addition, as the client certificates of in-cluster callers often only have a common name
+type TLSServerConfig struct {
+	CertFile          string
+	KeyFile           string
+	ClientAuthType    string
+	ClientCAFile      string
+	ClientAllowedSans []string
+	ClientAllowedCNs  []string
+	// MinVersion and MaxVersion are TLS12 or TLS13, TLS12 and the latest Go supports when 0
+	MinVersion web.TLSVersion
+	MaxVersion web.TLSVersion
+	// CipherSuites are the TLS 1.2 cipher suites, Go's defaults when empty; the TLS 1.3 ones cannot be configured
+	CipherSuites []web.Cipher
+	// CurvePreferences are the key exchange curves, CurveP256, CurveP384, CurveP521 or X25519, Go's defaults when empty
+	CurvePreferences []web.Curve
+	// BasicAuthUsers are the bcrypt hashes of the passwords of the users the clients need to authenticate as, by user
+	// name, from the basic_auth_users of the web configuration file; none is needed when empty
+	BasicAuthUsers map[string]string
+	// Headers are set on every response, from the headers of the http_server_config of the web configuration file,
+	// limited to the security headers the exporter-toolkit allows
+	Headers map[string]string
+	// DisableHTTP2 turns HTTP/2 off, whatever the ServerConfig says, from an http2 false in the http_server_config of
+	// the web configuration file; it is only read when the listener starts
+	DisableHTTP2 bool
+	// reloader re-reads the web configuration file the config was loaded from, nil when not loaded from one
+	reloader *webConfigReloader
+}
+
+// tlsServerConfigFile is the exporter-toolkit's tls_server_config, parsed with its own types, plus the allowed
+// clients; client_allowed_sans only comes with later exporter-toolkit versions
+type tlsServerConfigFile struct {
+	web.TLSConfig     `yaml:",inline"`
+	ClientAllowedSans []string `yaml:"client_allowed_sans"`
+	ClientAllowedCNs  []string `yaml:"client_allowed_cns"`
+}
+
+type webConfig struct {
+	TLSServerConfig  *tlsServerConfigFile          `yaml:"tls_server_config"`
+	HTTPServerConfig web.HTTPConfig                `yaml:"http_server_config"`
+	BasicAuthUsers   map[string]config_util.Secret `yaml:"basic_auth_users"`
+}
+
+// allowedHeaders are the headers the http_server_config can set, with their allowed values, any when nil, as with the
+// exporter-toolkit, which does not export its list
+var allowedHeaders = map[string][]string{
+	"Strict-Transport-Security": nil,
+	"X-Content-Type-Options":    {"nosniff"},
+	"X-Frame-Options":           {"deny", "sameorigin"},
+	"X-XSS-Protection":          nil,
+	"Content-Security-Policy":   nil,
+}
+
+func validateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		values, ok := allowedHeaders[name]
+		if !ok {
+			return fmt.Errorf("http_server_config headers %s cannot be set", name)
+		}
+		if values == nil {
+			continue
+		}
+		allowed := false
+		for _, v := range values {
+			allowed = allowed || strings.EqualFold(v, value)
+		}
+		if !allowed {
+			return fmt.Errorf("http_server_config headers %s is %q, not one of %q", name, value, values)
+		}
+	}
+	return nil
+}
+
+func parseWebConfig(path string, buf []byte) (*TLSServerConfig, error) {
+	// http2 is on unless turned off, as with the exporter-toolkit
+	file := &webConfig{HTTPServerConfig: web.HTTPConfig{HTTP2: true}}
+	if err := yaml.UnmarshalStrict(buf, file); err != nil {
+		return nil, fmt.Errorf("%s is not a web configuration file: %w", path, err)
+	}
+	if file.TLSServerConfig == nil {
+		return nil, fmt.Errorf("%s has no tls_server_config", path)
+	}
+	// the relative paths are relative to the file, as with the exporter-toolkit
+	file.TLSServerConfig.SetDirectory(filepath.Dir(path))
+	tlsFile := file.TLSServerConfig
+	cfg := &TLSServerConfig{
+		CertFile:          tlsFile.TLSCertPath,
+		KeyFile:           tlsFile.TLSKeyPath,
+		ClientAuthType:    tlsFile.ClientAuth,
+		ClientCAFile:      tlsFile.ClientCAs,
+		ClientAllowedSans: tlsFile.ClientAllowedSans,
+		ClientAllowedCNs:  tlsFile.ClientAllowedCNs,
+		MinVersion:        tlsFile.MinVersion,
+		MaxVersion:        tlsFile.MaxVersion,
+		CipherSuites:      tlsFile.CipherSuites,
+		CurvePreferences:  tlsFile.CurvePreferences,
+		Headers:           file.HTTPServerConfig.Header,
+		DisableHTTP2:      !file.HTTPServerConfig.HTTP2,
+	}
+	if err := validateHeaders(cfg.Headers); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if fipsMode && len(file.BasicAuthUsers) > 0 {
+		return nil, fmt.Errorf("%s: basic_auth_users are not supported in FIPS mode, as bcrypt is not FIPS approved", path)
+	}
+	for user, hash := range file.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("%s: the password of basic_auth_users %s is not a bcrypt hash", path, user)
+		}
+		if cfg.BasicAuthUsers == nil {
+			cfg.BasicAuthUsers = map[string]string{}
+		}
+		cfg.BasicAuthUsers[user] = string(hash)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// LoadWebConfig reads the tls_server_config, http_server_config and basic_auth_users of an exporter-toolkit web
+// configuration file, with the exporter-toolkit's own types, and relative paths relative to the file; the settings the exporter does not
+// support are errors vs. ignored, as ignoring them would serve the metrics less protected than configured.  The file is
+// checked for changes every webConfigReloadInterval as it is used, so rotated users, CAs, and allowed clients, say from
+// a Secret, are picked up without a restart
+func LoadWebConfig(path string) (*TLSServerConfig, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseWebConfig(path, buf)
+	if err != nil {
+		return nil, err
+	}
+	loaded := *cfg
+	loaded.reloader = newWebConfigReloader(path, buf, cfg)
+	return &loaded, nil
+}
+
+// current is the config as last reloaded from its file, or the config itself when not loaded from one
+func (c *TLSServerConfig) current() *TLSServerConfig {
+	if c.reloader == nil {
+		return c
+	}
+	return c.reloader.config()
+}
+
+// forbidBasicAuth keeps the reloads of the file the config was loaded from from adding basic_auth_users, which
+// conflict with conflict, like --metrics-auth
+func (c *TLSServerConfig) forbidBasicAuth(conflict string) {
+	if c.reloader != nil {
+		c.reloader.forbidBasicAuth(conflict)
+	}
+}
+
+func (c *TLSServerConfig) requireClientCert() bool {
+	return c.ClientAuthType == ClientAuthRequireAndVerify
+}
+
+func (c *TLSServerConfig) validate() error {
+	if len(c.CertFile) == 0 || len(c.KeyFile) == 0 {
+		return errors.New("cert_file and key_file are required")
+	}
+	switch c.ClientAuthType {
+	case "", ClientAuthNone:
+		if len(c.ClientCAFile) > 0 || len(c.ClientAllowedSans) > 0 || len(c.ClientAllowedCNs) > 0 {
+			return fmt.Errorf("client_ca_file and the allowed clients need client_auth_type %s", ClientAuthRequireAndVerify)
+		}
+	case ClientAuthRequireAndVerify:
+		if len(c.ClientCAFile) == 0 {
+			return fmt.Errorf("client_auth_type %s needs client_ca_file", ClientAuthRequireAndVerify)
+		}
+	default:
+		return fmt.Errorf("client_auth_type %q is not supported, only %s and %s are", c.ClientAuthType, ClientAuthNone, ClientAuthRequireAndVerify)
+	}
+	return c.applyTLSSettings(&tls.Config{})
+}
+
+// applyTLSSettings sets the versions, cipher suites and curves of cfg; only TLS 1.2 and later, and the TLS 1.2 cipher
+// suites Go considers secure are supported, and in FIPS mode, only the FIPS approved ones
+func (c *TLSServerConfig) applyTLSSettings(cfg *tls.Config) error {
+	cfg.MinVersion = tls.VersionTLS12
+	if c.MinVersion != 0 {
+		if c.MinVersion < tls.VersionTLS12 {
+			return fmt.Errorf("min_version %s is not supported, only TLS12 and TLS13 are", yamlName(&c.MinVersion))
+		}
+		cfg.MinVersion = uint16(c.MinVersion)
+	}
+	if c.MaxVersion != 0 {
+		if uint16(c.MaxVersion) < cfg.MinVersion {
+			return fmt.Errorf("max_version %s is lower than min_version %s", yamlName(&c.MaxVersion), yamlName(&c.MinVersion))
+		}
+		cfg.MaxVersion = uint16(c.MaxVersion)
+	}
+	if fipsMode && cfg.MinVersion > tls.VersionTLS12 {
+		return fmt.Errorf("min_version %s is not supported in FIPS mode, which only negotiates TLS12", yamlName(&c.MinVersion))
+	}
+	cfg.CipherSuites = nil
+	for _, suite := range c.CipherSuites {
+		if !tls12CipherSuite(uint16(suite)) {
+			return fmt.Errorf("cipher_suites %s is not a secure TLS 1.2 cipher suite", yamlName(suite))
+		}
+		if fipsMode && !fipsCipherSuites[uint16(suite)] {
+			return fmt.Errorf("cipher_suites %s is not supported in FIPS mode", yamlName(suite))
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, uint16(suite))
+	}
+	cfg.CurvePreferences = nil
+	for i := range c.CurvePreferences {
+		curve := tls.CurveID(c.CurvePreferences[i])
+		if fipsMode && !fipsCurves[curve] {
+			return fmt.Errorf("curve_preferences %s is not supported in FIPS mode", yamlName(&c.CurvePreferences[i]))
+		}
+		cfg.CurvePreferences = append(cfg.CurvePreferences, curve)
+	}
+	return nil
+}
+
+// versionName is the name of the TLS version, empty when not set
+func versionName(version web.TLSVersion) string {
+	if version == 0 {
+		return ""
+	}
+	return yamlName(&version)
+}
+
+// tls12CipherSuite is whether the cipher suite is one of the secure TLS 1.2 ones
+func tls12CipherSuite(id uint16) bool {
+	for _, suite := range tls.CipherSuites() {
+		if suite.ID != id {
+			continue
+		}
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// yamlName is the name of a TLS version, cipher suite or curve in the web configuration file, for the errors and logs
+func yamlName(value yaml.Marshaler) string {
+	name, err := value.MarshalYAML()
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprint(name)
+}
+
+// allowed is whether the verified client certificate is one of the allowed clients, by common name or subject
+// alternative name; any certificate signed by the client CA is allowed when neither list is set
+func (c *TLSServerConfig) allowed(cert *x509.Certificate) bool {
+	if len(c.ClientAllowedCNs) == 0 && len(c.ClientAllowedSans) == 0 {
+		return true
+	}
+	for _, cn := range c.ClientAllowedCNs {
+		if cert.Subject.CommonName == cn {
+			return true
+		}
+	}
+	sans := append(append([]string{}, cert.DNSNames...), cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	for _, allowed := range c.ClientAllowedSans {
+		for _, san := range sans {
+			if san == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tlsConfig is the TLS config of the current config, for every handshake, when loaded from a web configuration file
+func (c *TLSServerConfig) tlsConfig() (*tls.Config, error) {
+	if c.reloader == nil {
+		return c.staticTLSConfig()
+	}
+	initial, err := c.reloader.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	// ClientAuth is only for the logs, the config of the handshake is the current one
+	cfg := &tls.Config{MinVersion: initial.MinVersion, ClientAuth: initial.ClientAuth}
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		current, err := c.reloader.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		// the protocols, like h2, are set up on the config the listener was given
+		current = current.Clone()
+		current.NextProtos = cfg.NextProtos
+		return current, nil
+	}
+	return cfg, nil
+}
+
+// staticTLSConfig serves the certificate of a keyPair, so a rotated certificate, like those of the OpenShift service
+// CA, is picked up without a restart; it is read up front to fail fast on a bad configuration
+func (c *TLSServerConfig) staticTLSConfig() (*tls.Config, error) {
+	keys, err := newKeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return keys.certificate(), nil
+		},
+	}
+	if err := c.applyTLSSettings(cfg); err != nil {
+		return nil, err
+	}
+	if !c.requireClientCert() {
+		return cfg, nil
+	}
+	pool, err := loadCertPool(c.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	cfg.ClientCAs = pool
+	cfg.VerifyPeerCertificate = func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 || !c.allowed(verifiedChains[0][0]) {
+			return errors.New("the client certificate is not one of the allowed clients")
+		}
+		return nil
+	}
+	return cfg, nil
+}
+
+// keyPair is the certificate of a cert_file and key_file, re-read at most every webConfigReloadInterval vs. on every
+// handshake, and only parsed again when either file changed; the handshakes never wait on the files, the one that
+// finds the certificate due for a check reads them while the others keep serving the current one
+type keyPair struct {
+	certFile string
+	keyFile  string
+	loaded   atomic.Pointer[loadedKeyPair]
+	checking sync.Mutex
+	now      func() time.Time
+}
+
+type loadedKeyPair struct {
+	cert    *tls.Certificate
+	certPEM []byte
+	keyPEM  []byte
+	checked time.Time
+}
+
+func newKeyPair(certFile, keyFile string) (*keyPair, error) {
+	k := &keyPair{certFile: certFile, keyFile: keyFile, now: time.Now}
+	loaded, err := k.load(&loadedKeyPair{})
+	if err != nil {
+		return nil, err
+	}
+	k.loaded.Store(loaded)
+	return k, nil
+}
+
+// load reads the files, and parses them again when they differ from those of current
+func (k *keyPair) load(current *loadedKeyPair) (*loadedKeyPair, error) {
+	certPEM, err := os.ReadFile(k.certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(k.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	loaded := *current
+	loaded.checked = k.now()
+	if current.cert != nil && bytes.Equal(certPEM, current.certPEM) && bytes.Equal(keyPEM, current.keyPEM) {
+		return &loaded, nil
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	loaded.cert, loaded.certPEM, loaded.keyPEM = &cert, certPEM, keyPEM
+	return &loaded, nil
+}
+
+// certificate is the current certificate; a certificate that cannot be reloaded, like one caught halfway through its
+// rotation, is retried at the next check while the current one keeps being served
+func (k *keyPair) certificate() *tls.Certificate {
+	current := k.loaded.Load()
+	if k.now().Sub(current.checked) < webConfigReloadInterval || !k.checking.TryLock() {
+		return current.cert
+	}
+	defer k.checking.Unlock()
+	loaded, err := k.load(current)
+	if err != nil {
+		ctrl.Log.WithName("metrics").Error(err, "unable to reload the certificate, keeping the current one", "cert_file", k.certFile, "key_file", k.keyFile)
+		retry := *current
+		retry.checked = k.now()
+		loaded = &retry
+	}
+	k.loaded.Store(loaded)
+	return loaded.cert
+}
@@ -0,0 +1,310 @@
+package exporter
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/exporter-toolkit/web"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, server bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	if server {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return &testCert{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCert(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.pem, c.keyPEM(t))
+	assert.NoError(t, err)
+	return cert
+}
+
+func writeFile(t *testing.T, dir, name string, content []byte) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, content, 0600))
+	return path
+}
+
+func TestLoadWebConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg, err := LoadWebConfig(writeFile(t, dir, "web.yaml", []byte(`
+tls_server_config:
+  cert_file: /tls/tls.crt
+  key_file: /tls/tls.key
+  client_auth_type: RequireAndVerifyClientCert
+  client_ca_file: /tls/ca.crt
+  client_allowed_cns: [prometheus-k8s]
+`)))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prometheus-k8s"}, cfg.ClientAllowedCNs)
+	assert.True(t, cfg.requireClientCert())
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	cfg, err = LoadWebConfig(writeFile(t, dir, "basic-auth.yaml", []byte("tls_server_config: {cert_file: a, key_file: b}\nbasic_auth_users: {prometheus: "+string(hash)+"}")))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"prometheus": string(hash)}, cfg.BasicAuthUsers)
+
+	// the exporter-toolkit's settings and relative paths are those of the exporter-toolkit
+	cfg, err = LoadWebConfig(writeFile(t, dir, "toolkit.yaml", []byte(`
+tls_server_config:
+  cert_file: tls/tls.crt
+  key_file: /tls/tls.key
+  min_version: TLS13
+  cipher_suites: [TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256]
+  curve_preferences: [CurveP384]
+  prefer_server_cipher_suites: true
+http_server_config:
+  http2: false
+  headers:
+    Strict-Transport-Security: max-age=31536000
+    X-Frame-Options: deny
+`)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, filepath.Join(dir, "tls/tls.crt"), cfg.CertFile)
+		assert.Equal(t, "/tls/tls.key", cfg.KeyFile)
+		assert.Equal(t, web.TLSVersion(tls.VersionTLS13), cfg.MinVersion)
+		assert.Equal(t, []web.Cipher{web.Cipher(tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)}, cfg.CipherSuites)
+		assert.Equal(t, []web.Curve{web.Curve(tls.CurveP384)}, cfg.CurvePreferences)
+		assert.Equal(t, map[string]string{"Strict-Transport-Security": "max-age=31536000", "X-Frame-Options": "deny"}, cfg.Headers)
+		assert.True(t, cfg.DisableHTTP2)
+	}
+
+	// bcrypt is not FIPS approved
+	defer func(previous bool) { fipsMode = previous }(fipsMode)
+	fipsMode = true
+	_, err = LoadWebConfig(filepath.Join(dir, "basic-auth.yaml"))
+	assert.Error(t, err)
+	fipsMode = false
+
+	for name, content := range map[string]string{
+		"not-bcrypt.yaml":   "tls_server_config: {cert_file: a, key_file: b}\nbasic_auth_users: {admin: hash}",
+		"no-tls.yaml":       "http_server_config: {http2: true}",
+		"no-ca.yaml":        "tls_server_config: {cert_file: a, key_file: b, client_auth_type: RequireAndVerifyClientCert}",
+		"ca-no-auth.yaml":   "tls_server_config: {cert_file: a, key_file: b, client_ca_file: c}",
+		"any-cert.yaml":     "tls_server_config: {cert_file: a, key_file: b, client_auth_type: RequireAnyClientCert}",
+		"unknown.yaml":      "tls_server_config: {cert_file: a, key_file: b, client_allowed_ous: [monitoring]}",
+		"tls10.yaml":        "tls_server_config: {cert_file: a, key_file: b, min_version: TLS10}",
+		"max-below.yaml":    "tls_server_config: {cert_file: a, key_file: b, min_version: TLS13, max_version: TLS12}",
+		"insecure.yaml":     "tls_server_config: {cert_file: a, key_file: b, cipher_suites: [TLS_RSA_WITH_RC4_128_SHA]}",
+		"tls13-suite.yaml":  "tls_server_config: {cert_file: a, key_file: b, cipher_suites: [TLS_AES_128_GCM_SHA256]}",
+		"curve.yaml":        "tls_server_config: {cert_file: a, key_file: b, curve_preferences: [CurveP224]}",
+		"header.yaml":       "tls_server_config: {cert_file: a, key_file: b}\nhttp_server_config: {headers: {Server: exporter}}",
+		"header-value.yaml": "tls_server_config: {cert_file: a, key_file: b}\nhttp_server_config: {headers: {X-Frame-Options: allow}}",
+	} {
+		_, err = LoadWebConfig(writeFile(t, dir, name, []byte(content)))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestTLSSettings(t *testing.T) {
+	defer func(previous bool) { fipsMode = previous }(fipsMode)
+	fipsMode = false
+	cfg := &tls.Config{}
+	assert.NoError(t, (&TLSServerConfig{}).applyTLSSettings(cfg))
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Zero(t, cfg.MaxVersion)
+	assert.Nil(t, cfg.CipherSuites)
+
+	settings := &TLSServerConfig{MinVersion: tls.VersionTLS12, MaxVersion: tls.VersionTLS13,
+		CipherSuites:     []web.Cipher{web.Cipher(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256), web.Cipher(tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256)},
+		CurvePreferences: []web.Curve{web.Curve(tls.X25519), web.Curve(tls.CurveP256)}}
+	assert.NoError(t, settings.applyTLSSettings(cfg))
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.MaxVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256}, cfg.CipherSuites)
+	assert.Equal(t, []tls.CurveID{tls.X25519, tls.CurveP256}, cfg.CurvePreferences)
+
+	// FIPS mode only takes the FIPS approved settings
+	fipsMode = true
+	assert.Error(t, settings.applyTLSSettings(cfg))
+	assert.Error(t, (&TLSServerConfig{CurvePreferences: []web.Curve{web.Curve(tls.X25519)}}).applyTLSSettings(cfg))
+	assert.Error(t, (&TLSServerConfig{MinVersion: tls.VersionTLS13}).applyTLSSettings(cfg))
+	assert.NoError(t, (&TLSServerConfig{CipherSuites: []web.Cipher{web.Cipher(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)},
+		CurvePreferences: []web.Curve{web.Curve(tls.CurveP384)}}).applyTLSSettings(cfg))
+}
+
+func TestTLSMetricsServer(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil, false)
+	server := newTestCert(t, "exporter", ca, true)
+	allowed := newTestCert(t, "prometheus-k8s", ca, false)
+	other := newTestCert(t, "someone-else", ca, false)
+	cfg := &TLSServerConfig{
+		CertFile:         writeFile(t, dir, "tls.crt", server.pem),
+		KeyFile:          writeFile(t, dir, "tls.key", server.keyPEM(t)),
+		ClientAuthType:   ClientAuthRequireAndVerify,
+		ClientCAFile:     writeFile(t, dir, "ca.crt", ca.pem),
+		ClientAllowedCNs: []string{"prometheus-k8s"},
+	}
+	s, err := newMetricsServer("127.0.0.1:0", DefaultMetricsPath, cfg, ServerConfig{})
+	assert.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.serve(ctx, ln) }()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certs ...tls.Certificate) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		return client.Get("https://" + ln.Addr().String() + "/metrics")
+	}
+	resp, err := get(allowed.tlsCert(t))
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	}
+	_, err = get(other.tlsCert(t))
+	assert.Error(t, err)
+	_, err = get()
+	assert.Error(t, err)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestKeyPairReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil, false)
+	first, second := newTestCert(t, "first", ca, true), newTestCert(t, "second", ca, true)
+	certFile, keyFile := writeFile(t, dir, "tls.crt", first.pem), writeFile(t, dir, "tls.key", first.keyPEM(t))
+	keys, err := newKeyPair(certFile, keyFile)
+	assert.NoError(t, err)
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	keys.loaded.Load().checked = now
+	cn := func() string {
+		cert, err := x509.ParseCertificate(keys.certificate().Certificate[0])
+		assert.NoError(t, err)
+		return cert.Subject.CommonName
+	}
+
+	// the files are not read again until they are due for a check
+	writeFile(t, dir, "tls.crt", second.pem)
+	writeFile(t, dir, "tls.key", second.keyPEM(t))
+	assert.Equal(t, "first", cn())
+	now = now.Add(webConfigReloadInterval)
+	assert.Equal(t, "second", cn())
+	parsed := keys.certificate()
+
+	// unchanged files are not parsed again, and a half rotated pair keeps the current certificate
+	now = now.Add(webConfigReloadInterval)
+	assert.Same(t, parsed, keys.certificate())
+	writeFile(t, dir, "tls.crt", first.pem)
+	now = now.Add(webConfigReloadInterval)
+	assert.Equal(t, "second", cn())
+	writeFile(t, dir, "tls.key", first.keyPEM(t))
+	now = now.Add(webConfigReloadInterval)
+	assert.Equal(t, "first", cn())
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil, false)
+	server := newTestCert(t, "exporter", ca, true)
+	client := newTestCert(t, "prometheus-k8s", ca, false)
+	caFile := writeFile(t, dir, "ca.crt", ca.pem)
+	cfg := &TLSServerConfig{
+		CertFile:         writeFile(t, dir, "tls.crt", server.pem),
+		KeyFile:          writeFile(t, dir, "tls.key", server.keyPEM(t)),
+		ClientAuthType:   ClientAuthRequireAndVerify,
+		ClientCAFile:     caFile,
+		ClientAllowedCNs: []string{"prometheus-k8s"},
+	}
+	s, err := newMetricsServer("127.0.0.1:0", "/pipeline-metrics", cfg, ServerConfig{})
+	assert.NoError(t, err)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.serve(ctx, ln) }()
+
+	get := func(tlsConfig *tls.Config, path string) (*http.Response, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		return client.Get("https://" + ln.Addr().String() + path)
+	}
+	tlsConfig, err := ClientTLSConfig(caFile, writeFile(t, dir, "client.crt", client.pem), writeFile(t, dir, "client.key", client.keyPEM(t)))
+	assert.NoError(t, err)
+	resp, err := get(tlsConfig, "/pipeline-metrics")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	}
+	resp, err = get(tlsConfig, "/metrics")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp.Body.Close()
+	}
+	// the server is trusted, but the listener requires the client certificate
+	tlsConfig, err = ClientTLSConfig(caFile, "", "")
+	assert.NoError(t, err)
+	_, err = get(tlsConfig, "/pipeline-metrics")
+	assert.Error(t, err)
+	_, err = ClientTLSConfig(caFile, "client.crt", "")
+	assert.Error(t, err)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestMetricsTLSManagerOptions(t *testing.T) {
+	e := New(Config{MetricsTLS: &TLSServerConfig{CertFile: "missing", KeyFile: "missing"}})
+	assert.Equal(t, "0", e.managerOptions().MetricsBindAddress)
+	_, err := e.metricsServer(prometheus.NewRegistry())
+	assert.Error(t, err)
+	s, err := New(Config{MetricsBindAddress: "0", MetricsTLS: &TLSServerConfig{}}).metricsServer(prometheus.NewRegistry())
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+}
@@ -0,0 +1,95 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// DefaultTextfileInterval is how often the textfile is written when Config.TextfileInterval is 0
+	DefaultTextfileInterval = 30 * time.Second
+)
+
+// runtimeFamilyPrefixes are the prefixes of the go, process, controller-runtime, and client-go families; node_exporter
+// reports its own go and process metrics, and would reject the textfile as a whole on the clash, and the others
+// describe this process rather than the pipelines, so only the exporter's own families are written
+var runtimeFamilyPrefixes = []string{"go_", "process_", "promhttp_", "controller_runtime_", "workqueue_",
+	"rest_client_", "leader_election_", "certwatcher_"}
+
+func runtimeFamily(name string) bool {
+	for _, prefix := range runtimeFamilyPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// textfileWriter periodically writes the exporter's own gathered metrics in the text format, for node_exporter's textfile collector
+type textfileWriter struct {
+	path     string
+	interval time.Duration
+	gatherer prometheus.Gatherer
+}
+
+// Start writes the metrics right away, then every interval until ctx is done; failed writes are logged vs. stopping
+// the exporter, as the next one may well succeed
+func (w *textfileWriter) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("textfile")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.write(); err != nil {
+			log.Error(err, "unable to write the metrics textfile", "path", w.path)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection is false, so every replica writes its own metrics
+func (w *textfileWriter) NeedLeaderElection() bool {
+	return false
+}
+
+// write goes through a temporary file and a rename, so the textfile collector never reads a partial file; the
+// temporary file does not end in .prom, so it is also never read on its own
+func (w *textfileWriter) write() error {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), "."+filepath.Base(w.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	for _, family := range families {
+		if runtimeFamily(family.GetName()) {
+			continue
+		}
+		if _, err = expfmt.MetricFamilyToText(tmp, family); err != nil {
+			tmp.Close()
+			return fmt.Errorf("unable to write %s: %w", family.GetName(), err)
+		}
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp uses 0600, but the textfile collector usually runs as another user
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), w.path)
+}
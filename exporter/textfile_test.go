@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTextfileWriter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_textfile_total", Help: "test"})
+	registry.MustRegister(counter, collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	workqueue := prometheus.NewGauge(prometheus.GaugeOpts{Name: "workqueue_depth", Help: "test"})
+	registry.MustRegister(workqueue)
+	counter.Add(3)
+	dir := t.TempDir()
+	w := &textfileWriter{path: filepath.Join(dir, "pipeline.prom"), interval: time.Hour, gatherer: registry}
+
+	assert.NoError(t, w.write())
+	buf, err := os.ReadFile(w.path)
+	assert.NoError(t, err)
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(strings.NewReader(string(buf)))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), families["test_textfile_total"].GetMetric()[0].GetCounter().GetValue())
+	// the runtime families are left to node_exporter's own and the metrics endpoint
+	for name := range families {
+		assert.False(t, runtimeFamily(name), name)
+	}
+	assert.NotContains(t, families, "workqueue_depth")
+	info, err := os.Stat(w.path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	// only the textfile is left behind
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Start writes right away, and returns once the context is done
+	counter.Add(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, w.Start(ctx))
+	buf, err = os.ReadFile(w.path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(buf), "test_textfile_total 4")
+
+	w.path = filepath.Join(dir, "missing", "pipeline.prom")
+	assert.Error(t, w.write())
+}
+
+func TestTextfileConfig(t *testing.T) {
+	assert.Nil(t, New(Config{}).textfileWriter())
+	w := New(Config{TextfilePath: "/tmp/pipeline.prom"}).textfileWriter()
+	assert.Equal(t, DefaultTextfileInterval, w.interval)
+	w = New(Config{TextfilePath: "/tmp/pipeline.prom", TextfileInterval: time.Minute}).textfileWriter()
+	assert.Equal(t, time.Minute, w.interval)
+}
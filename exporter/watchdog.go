@@ -0,0 +1,146 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// DefaultWatchdogInterval is how often the watchdog checks its limits when WatchdogConfig.Interval is 0
+	DefaultWatchdogInterval = 15 * time.Second
+	// longestRunningProcessorMetric is controller-runtime's workqueue gauge of how long the oldest reconcile in flight
+	// has been running, by controller
+	longestRunningProcessorMetric = "workqueue_longest_running_processor_seconds"
+)
+
+// WatchdogConfig are the limits the exporter checks itself against, so a leaking or wedged exporter gets restarted vs.
+// serving stale metrics; a limit of 0 is not checked, and the watchdog is off when none are set
+type WatchdogConfig struct {
+	// MaxGoroutines is the most goroutines the exporter may have
+	MaxGoroutines int
+	// MaxHeapBytes is the most heap the exporter may have allocated
+	MaxHeapBytes uint64
+	// MaxWorkqueueStaleness is the longest any reconcile may run, as a reconcile that never returns keeps its
+	// controller from ever processing the object again
+	MaxWorkqueueStaleness time.Duration
+	// Interval is how often the limits are checked, DefaultWatchdogInterval when 0
+	Interval time.Duration
+	// Exit stops the exporter on a breach, so its container is restarted even without a liveness probe on the healthz
+	// endpoint; otherwise a breach only fails the healthz check
+	Exit bool
+}
+
+func (c WatchdogConfig) enabled() bool {
+	return c.MaxGoroutines > 0 || c.MaxHeapBytes > 0 || c.MaxWorkqueueStaleness > 0
+}
+
+// watchdog is a Runnable checking the limits every interval, with the outcome of the last check served as a healthz
+// check; a breach that clears, say once a spike in heap is collected, makes the check pass again
+type watchdog struct {
+	cfg        WatchdogConfig
+	gatherer   prometheus.Gatherer
+	goroutines func() int
+	heapBytes  func() uint64
+
+	lock   sync.Mutex
+	breach error
+}
+
+func newWatchdog(cfg WatchdogConfig, gatherer prometheus.Gatherer) *watchdog {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultWatchdogInterval
+	}
+	return &watchdog{
+		cfg:        cfg,
+		gatherer:   gatherer,
+		goroutines: runtime.NumGoroutine,
+		heapBytes: func() uint64 {
+			stats := runtime.MemStats{}
+			runtime.ReadMemStats(&stats)
+			return stats.HeapAlloc
+		},
+	}
+}
+
+// check returns the first limit breached, if any
+func (w *watchdog) check() error {
+	if w.cfg.MaxGoroutines > 0 {
+		if n := w.goroutines(); n > w.cfg.MaxGoroutines {
+			return fmt.Errorf("%d goroutines is over the limit of %d", n, w.cfg.MaxGoroutines)
+		}
+	}
+	if w.cfg.MaxHeapBytes > 0 {
+		if heap := w.heapBytes(); heap > w.cfg.MaxHeapBytes {
+			return fmt.Errorf("%d bytes of heap is over the limit of %d", heap, w.cfg.MaxHeapBytes)
+		}
+	}
+	if w.cfg.MaxWorkqueueStaleness > 0 {
+		families, err := w.gatherer.Gather()
+		if err != nil {
+			// a gather error is not the exporter being wedged, so it is not a breach
+			return nil
+		}
+		for _, family := range families {
+			if family.GetName() != longestRunningProcessorMetric {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				running := time.Duration(m.GetGauge().GetValue() * float64(time.Second))
+				if running <= w.cfg.MaxWorkqueueStaleness {
+					continue
+				}
+				name := ""
+				for _, label := range m.GetLabel() {
+					if label.GetName() == "name" {
+						name = label.GetValue()
+					}
+				}
+				return fmt.Errorf("a reconcile of controller %q has been running for %s, over the limit of %s", name, running.Round(time.Second), w.cfg.MaxWorkqueueStaleness)
+			}
+		}
+	}
+	return nil
+}
+
+// Start checks the limits every interval until ctx is done, or until a breach when the watchdog is set to exit
+func (w *watchdog) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("watchdog")
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		breach := w.check()
+		w.lock.Lock()
+		w.breach = breach
+		w.lock.Unlock()
+		if breach != nil {
+			if w.cfg.Exit {
+				return fmt.Errorf("watchdog limit breached: %w", breach)
+			}
+			log.Info("WARNING: watchdog limit breached, failing the healthz check", "breach", breach.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection is false, as every replica watches itself
+func (w *watchdog) NeedLeaderElection() bool {
+	return false
+}
+
+// healthz is a healthz.Checker failing while the last check found a breach
+func (w *watchdog) healthz(_ *http.Request) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.breach
+}
@@ -0,0 +1,53 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdogCheck(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	longest := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: longestRunningProcessorMetric, Help: "test"}, []string{"name"})
+	registry.MustRegister(longest)
+	longest.With(prometheus.Labels{"name": "pipelinerun"}).Set(30)
+	w := newWatchdog(WatchdogConfig{MaxGoroutines: 100, MaxHeapBytes: 1024, MaxWorkqueueStaleness: time.Minute}, registry)
+	assert.Equal(t, DefaultWatchdogInterval, w.cfg.Interval)
+	w.goroutines = func() int { return 50 }
+	w.heapBytes = func() uint64 { return 512 }
+	assert.NoError(t, w.check())
+
+	w.goroutines = func() int { return 150 }
+	assert.ErrorContains(t, w.check(), "150 goroutines")
+	w.goroutines = func() int { return 50 }
+	w.heapBytes = func() uint64 { return 2048 }
+	assert.ErrorContains(t, w.check(), "2048 bytes of heap")
+	w.heapBytes = func() uint64 { return 512 }
+	longest.With(prometheus.Labels{"name": "taskrun"}).Set(90)
+	assert.ErrorContains(t, w.check(), `controller "taskrun" has been running for 1m30s`)
+
+	// only the limits set are checked
+	w = newWatchdog(WatchdogConfig{MaxGoroutines: 100}, registry)
+	w.goroutines = func() int { return 50 }
+	assert.NoError(t, w.check())
+	assert.False(t, WatchdogConfig{}.enabled())
+}
+
+func TestWatchdogStart(t *testing.T) {
+	w := newWatchdog(WatchdogConfig{MaxGoroutines: 100, Interval: time.Hour}, prometheus.NewRegistry())
+	w.goroutines = func() int { return 150 }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- w.Start(ctx)
+	}()
+	assert.Eventually(t, func() bool { return w.healthz(nil) != nil }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+
+	w.cfg.Exit = true
+	assert.ErrorContains(t, w.Start(context.Background()), "watchdog limit breached")
+}
@@ -0,0 +1,178 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// webConfigReloadInterval is how often, at most, a web configuration file is checked for changes
+const webConfigReloadInterval = 10 * time.Second
+
+// webConfigReloader holds the config last loaded from a web configuration file, re-reading the file, and the
+// certificate, key and client CA files it names, as the config is used vs. watching them, as the Secret volumes they
+// are mounted from are updated by swapping symlinks.  The handshakes and requests never wait on the files: the one
+// finding the config due for a check reads them while the others keep using the current config
+type webConfigReloader struct {
+	path     string
+	state    atomic.Pointer[webConfigState]
+	checking sync.Mutex
+	// basicAuthConflict is what the basic_auth_users of a reloaded file would conflict with, empty when nothing; it is
+	// guarded by checking
+	basicAuthConflict string
+	now               func() time.Time
+}
+
+// webConfigState is the config as of the last check of its files
+type webConfigState struct {
+	// digest is the hash of the file and of the files it names, as last loaded
+	digest [sha256.Size]byte
+	// failed is the digest of the files that last failed to load, so they are only logged and audited once
+	failed  [sha256.Size]byte
+	cfg     *TLSServerConfig
+	tls     *tls.Config
+	checked time.Time
+}
+
+func newWebConfigReloader(path string, raw []byte, cfg *TLSServerConfig) *webConfigReloader {
+	r := &webConfigReloader{path: path, now: time.Now}
+	r.state.Store(&webConfigState{digest: webConfigDigest(raw, cfg), cfg: cfg, checked: time.Now()})
+	return r
+}
+
+// webConfigDigest is the hash of the web configuration file, and of the certificate, key and client CA files of cfg,
+// the ones that cannot be read included as such; only of the file when it does not parse
+func webConfigDigest(raw []byte, cfg *TLSServerConfig) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(raw)
+	if cfg != nil {
+		for _, path := range []string{cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile} {
+			h.Write([]byte("\x00" + path + "\x00"))
+			if content, err := os.ReadFile(path); err == nil {
+				h.Write(content)
+			}
+		}
+	}
+	var digest [sha256.Size]byte
+	copy(digest[:], h.Sum(nil))
+	return digest
+}
+
+// forbidBasicAuth keeps the reloads from adding basic_auth_users, as they would conflict with conflict, like
+// --metrics-auth, which also uses the Authorization header
+func (r *webConfigReloader) forbidBasicAuth(conflict string) {
+	r.checking.Lock()
+	defer r.checking.Unlock()
+	r.basicAuthConflict = conflict
+}
+
+// current is the state as of the last check, checking the files first when they were not checked within
+// webConfigReloadInterval, unless another caller already is
+func (r *webConfigReloader) current() *webConfigState {
+	state := r.state.Load()
+	if r.now().Sub(state.checked) < webConfigReloadInterval || !r.checking.TryLock() {
+		return state
+	}
+	defer r.checking.Unlock()
+	state = r.reload(r.state.Load())
+	r.state.Store(state)
+	return state
+}
+
+// reload is the state of the files vs. current; the current config is kept when the files cannot be read, or are not
+// valid, so a bad edit does not lock the scrapers out; checking is held by the caller
+func (r *webConfigReloader) reload(current *webConfigState) *webConfigState {
+	checked := *current
+	checked.checked = r.now()
+	log := ctrl.Log.WithName("metrics")
+	buf, err := os.ReadFile(r.path)
+	if err != nil {
+		log.Error(err, "unable to reload the web configuration, keeping the current one", "path", r.path)
+		return &checked
+	}
+	cfg, err := parseWebConfig(r.path, buf)
+	digest := webConfigDigest(buf, cfg)
+	if digest == current.digest || digest == current.failed {
+		return &checked
+	}
+	if err == nil && len(r.basicAuthConflict) > 0 && len(cfg.BasicAuthUsers) > 0 {
+		err = fmt.Errorf("%s: basic_auth_users cannot be combined with %s, as both use the Authorization header", r.path, r.basicAuthConflict)
+	}
+	var tlsConfig *tls.Config
+	if err == nil {
+		tlsConfig, err = cfg.staticTLSConfig()
+	}
+	rec := collector.AuditRecord{Who: "web-config-file", What: "reload-web-config", Target: r.path, Old: current.cfg.summary()}
+	if err != nil {
+		log.Error(err, "unable to reload the web configuration, keeping the current one", "path", r.path)
+		rec.New, rec.Outcome, rec.Err = rec.Old, collector.AuditOutcomeFailure, err
+		collector.Audit(rec)
+		checked.failed = digest
+		return &checked
+	}
+	log.Info("reloaded the web configuration", "path", r.path, "basic auth users", len(cfg.BasicAuthUsers))
+	rec.New, rec.Outcome = cfg.summary(), collector.AuditOutcomeSuccess
+	collector.Audit(rec)
+	return &webConfigState{digest: digest, cfg: cfg, tls: tlsConfig, checked: checked.checked}
+}
+
+// summary is the config without any secrets, as the user names, but not the password hashes, for its audit records
+func (c *TLSServerConfig) summary() string {
+	users := make([]string, 0, len(c.BasicAuthUsers))
+	for user := range c.BasicAuthUsers {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	headers := make([]string, 0, len(c.Headers))
+	for name := range c.Headers {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+	suites := make([]string, 0, len(c.CipherSuites))
+	for _, suite := range c.CipherSuites {
+		suites = append(suites, yamlName(suite))
+	}
+	curves := make([]string, 0, len(c.CurvePreferences))
+	for i := range c.CurvePreferences {
+		curves = append(curves, yamlName(&c.CurvePreferences[i]))
+	}
+	return fmt.Sprintf("cert_file=%s key_file=%s client_auth_type=%s client_ca_file=%s client_allowed_sans=%s client_allowed_cns=%s min_version=%s max_version=%s cipher_suites=%s curve_preferences=%s headers=%s http2=%t basic_auth_users=%s",
+		c.CertFile, c.KeyFile, c.ClientAuthType, c.ClientCAFile, strings.Join(c.ClientAllowedSans, ","), strings.Join(c.ClientAllowedCNs, ","),
+		versionName(c.MinVersion), versionName(c.MaxVersion), strings.Join(suites, ","), strings.Join(curves, ","),
+		strings.Join(headers, ","), !c.DisableHTTP2, strings.Join(users, ","))
+}
+
+func (r *webConfigReloader) config() *TLSServerConfig {
+	return r.current().cfg
+}
+
+func (r *webConfigReloader) tlsConfig() (*tls.Config, error) {
+	state := r.current()
+	if state.tls != nil {
+		return state.tls, nil
+	}
+	// the config loaded on startup gets its TLS config on first use
+	r.checking.Lock()
+	defer r.checking.Unlock()
+	state = r.state.Load()
+	if state.tls == nil {
+		tlsConfig, err := state.cfg.staticTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		withTLS := *state
+		withTLS.tls = tlsConfig
+		state = &withTLS
+		r.state.Store(state)
+	}
+	return state.tls, nil
+}
@@ -0,0 +1,110 @@
+package exporter
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestWebConfigReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil, false)
+	server := newTestCert(t, "exporter", ca, true)
+	certFile, keyFile := writeFile(t, dir, "tls.crt", server.pem), writeFile(t, dir, "tls.key", server.keyPEM(t))
+	caFile := writeFile(t, dir, "ca.crt", ca.pem)
+	path := writeFile(t, dir, "web.yaml", []byte("tls_server_config: {cert_file: "+certFile+", key_file: "+keyFile+"}"))
+	cfg, err := LoadWebConfig(path)
+	assert.NoError(t, err)
+	now := time.Now()
+	cfg.reloader.now = func() time.Time { return now }
+
+	tlsConfig, err := cfg.tlsConfig()
+	assert.NoError(t, err)
+	current, err := tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, current.ClientAuth)
+
+	// the file is not re-read within the interval
+	writeFile(t, dir, "web.yaml", []byte("tls_server_config: {cert_file: "+certFile+", key_file: "+keyFile+", client_auth_type: RequireAndVerifyClientCert, client_ca_file: "+caFile+"}"))
+	assert.Empty(t, cfg.current().ClientAuthType)
+	now = now.Add(webConfigReloadInterval)
+	assert.Equal(t, ClientAuthRequireAndVerify, cfg.current().ClientAuthType)
+	tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	current, err = tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, current.ClientAuth)
+	assert.Equal(t, []string{"h2", "http/1.1"}, current.NextProtos)
+
+	// a bad edit keeps the current config
+	for _, content := range []string{"tls_server_config: {cert_file: " + certFile + "}", "tls_server_config: {cert_file: missing, key_file: missing}"} {
+		writeFile(t, dir, "web.yaml", []byte(content))
+		now = now.Add(webConfigReloadInterval)
+		assert.Equal(t, ClientAuthRequireAndVerify, cfg.current().ClientAuthType, content)
+		current, err = tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+		assert.NoError(t, err)
+		assert.Equal(t, tls.RequireAndVerifyClientCert, current.ClientAuth)
+	}
+
+	// a bad edit is only tried once until it changes
+	failed := cfg.reloader.state.Load().failed
+	assert.NotZero(t, failed)
+	now = now.Add(webConfigReloadInterval)
+	cfg.current()
+	assert.Equal(t, failed, cfg.reloader.state.Load().failed)
+
+	// the configs not loaded from a file are their own current config
+	static := &TLSServerConfig{CertFile: certFile, KeyFile: keyFile}
+	assert.Same(t, static, static.current())
+	tlsConfig, err = static.tlsConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig.GetConfigForClient)
+}
+
+func TestWebConfigReloaderReferencedFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca, other := newTestCert(t, "test-ca", nil, false), newTestCert(t, "other-ca", nil, false)
+	server := newTestCert(t, "exporter", ca, true)
+	certFile, keyFile := writeFile(t, dir, "tls.crt", server.pem), writeFile(t, dir, "tls.key", server.keyPEM(t))
+	caFile := writeFile(t, dir, "ca.crt", ca.pem)
+	path := writeFile(t, dir, "web.yaml", []byte("tls_server_config: {cert_file: "+certFile+", key_file: "+keyFile+
+		", client_auth_type: RequireAndVerifyClientCert, client_ca_file: "+caFile+"}"))
+	cfg, err := LoadWebConfig(path)
+	assert.NoError(t, err)
+	now := time.Now()
+	cfg.reloader.now = func() time.Time { return now }
+	tlsConfig, err := cfg.tlsConfig()
+	assert.NoError(t, err)
+	current, err := tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.Len(t, current.ClientCAs.Subjects(), 1)
+
+	// a rotated client CA is picked up, although the file naming it did not change
+	writeFile(t, dir, "ca.crt", append(append([]byte{}, ca.pem...), other.pem...))
+	now = now.Add(webConfigReloadInterval)
+	current, err = tlsConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.Len(t, current.ClientCAs.Subjects(), 2)
+
+	// the basic_auth_users that would conflict with the auth of the listener are not reloaded
+	cfg.forbidBasicAuth("the metrics auth")
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	writeFile(t, dir, "web.yaml", []byte("tls_server_config: {cert_file: "+certFile+", key_file: "+keyFile+"}\nbasic_auth_users: {prometheus: "+string(hash)+"}"))
+	now = now.Add(webConfigReloadInterval)
+	assert.Empty(t, cfg.current().BasicAuthUsers)
+	assert.Equal(t, ClientAuthRequireAndVerify, cfg.current().ClientAuthType)
+}
+
+func TestWebConfigSummary(t *testing.T) {
+	cfg := &TLSServerConfig{CertFile: "tls.crt", KeyFile: "tls.key", ClientAuthType: ClientAuthRequireAndVerify, ClientAllowedCNs: []string{"prometheus-k8s"},
+		BasicAuthUsers: map[string]string{"scraper": "$2y$10$hash", "admin": "$2y$10$other"}}
+	summary := cfg.summary()
+	assert.Contains(t, summary, "client_auth_type="+ClientAuthRequireAndVerify)
+	assert.Contains(t, summary, "client_allowed_cns=prometheus-k8s")
+	assert.Contains(t, summary, "basic_auth_users=admin,scraper")
+	// the password hashes are not for the audit records
+	assert.NotContains(t, summary, "$2y$")
+}
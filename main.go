@@ -19,18 +19,27 @@ package main
 import (
 	"flag"
 
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
-	_ "net/http/pprof"
+	"context"
+	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/openshift-pipelines/pipeline-service-exporter/cli"
 	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	"github.com/openshift-pipelines/pipeline-service-exporter/exporter"
 	"github.com/prometheus/common/promlog"
 	"github.com/prometheus/common/version"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
 )
 
 var (
@@ -42,16 +51,157 @@ func init() {
 	promlogConfig = &promlog.Config{}
 }
 
+// stringSliceFlag allows for a flag to be specified multiple times, like kubectl's --as-group
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// settingsFromEnv is the only place the collectors' tunables are read from the environment; invalid settings are
+// logged and left at their defaults
+func settingsFromEnv() collector.Settings {
+	s, problems := collector.ParseSettings(os.Getenv)
+	for _, problem := range problems {
+		mainLog.Info(problem.Error())
+	}
+	return s
+}
+
+// listenerFromFlags is the config of the debug or admin listener, nil when its address is not set
+func listenerFromFlags(name, addr, webConfigFile string, auth bool) *exporter.ListenerConfig {
+	if len(addr) == 0 {
+		if len(webConfigFile) > 0 || auth {
+			mainLog.Error(fmt.Errorf("the %s TLS and auth need the %s listener's address", name, name), "invalid listener")
+			os.Exit(1)
+		}
+		return nil
+	}
+	l := &exporter.ListenerConfig{BindAddress: addr}
+	if len(webConfigFile) > 0 {
+		var err error
+		l.TLS, err = exporter.LoadWebConfig(webConfigFile)
+		if err != nil {
+			mainLog.Error(err, "invalid web configuration", "listener", name)
+			os.Exit(1)
+		}
+	}
+	if auth {
+		if l.TLS == nil {
+			mainLog.Error(fmt.Errorf("--%s-auth needs --%s.web.config.file", name, name), "invalid listener")
+			os.Exit(1)
+		}
+		l.Auth = &exporter.MetricsAuthConfig{}
+	}
+	return l
+}
+
 func main() {
+	// the subcommands have their own flags, and print their results vs. serving metrics
+	if len(os.Args) > 1 {
+		if cmd, ok := cli.Lookup(os.Args[1]); ok {
+			mainLog = ctrl.Log.WithName("main")
+			cli.Settings = settingsFromEnv()
+			if err := cmd(context.Background(), os.Args[2:], os.Stdout); err != nil && err != flag.ErrHelp {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	var listenAddress string
 	var metricsPath string
 	var probeAddr string
 	var pprofAddr string
+	var readOnly bool
+	var kubeContext string
+	var impersonateUser string
+	var impersonateGroups stringSliceFlag
+	var peerContexts stringSliceFlag
+	var externalCollectors stringSliceFlag
+	var timingIngest bool
+	var externalCollectorInterval time.Duration
+	var externalCollectorTimeout time.Duration
+	var metricCompatLevel int
+	var output string
+	var textfileInterval time.Duration
+	var kubeAPIQPS float64
+	var kubeAPIBurst int
+	var watchdogMaxGoroutines int
+	var watchdogMaxHeap string
+	var watchdogMaxStaleness time.Duration
+	var watchdogExit bool
+	var aggregateStore string
+	var aggregateRetention time.Duration
+	var metricSnapshot string
+	var gapExport string
+	var gapExportEndpoint string
+	var gapExportRegion string
+	var gapExportInterval time.Duration
+	var webConfigFile string
+	var metricsAuth bool
+	var metricsAuthResource string
+	var metricsCompression string
+	var metricsServer exporter.ServerConfig
+	var metricsMaxResponseSize string
+	var debugWebConfigFile string
+	var debugAuth bool
+	var adminAddr string
+	var adminWebConfigFile string
+	var adminAuth bool
 
-	flag.StringVar(&listenAddress, "telemetry.address", ":9117", "Address at which pipeline-service metrics are exported.")
-	flag.StringVar(&metricsPath, "telemetry-path", "/metrics", "Path at which pipeline-service metrics are exported.")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.StringVar(&pprofAddr, "pprof-address", "", "The address the pprof endpoint binds to.")
+	flag.StringVar(&listenAddress, "telemetry.address", exporter.DefaultMetricsBindAddress, "Address at which pipeline-service metrics are exported, as host:port, [::]:9117 for IPv6, or a bare port.")
+	flag.DurationVar(&metricsServer.ReadHeaderTimeout, "telemetry.read-header-timeout", exporter.DefaultReadHeaderTimeout, "How long the scrapers have to send the request headers; 0 turns it off.")
+	flag.DurationVar(&metricsServer.ReadTimeout, "telemetry.read-timeout", exporter.DefaultReadTimeout, "How long the scrapers have to send the whole request; 0 turns it off.")
+	flag.DurationVar(&metricsServer.WriteTimeout, "telemetry.write-timeout", exporter.DefaultWriteTimeout, "How long the metrics responses have to be written, kept above the scrape timeout; 0 turns it off.")
+	flag.DurationVar(&metricsServer.IdleTimeout, "telemetry.idle-timeout", exporter.DefaultIdleTimeout, "How long the keep-alive connections of the scrapers are kept open between scrapes; 0 turns it off.")
+	flag.IntVar(&metricsServer.MaxHeaderBytes, "telemetry.max-header-bytes", exporter.DefaultMaxHeaderBytes, "The maximum size of the request headers of the scrapers.")
+	flag.BoolVar(&metricsServer.HTTP2, "telemetry.http2", false, "Serve HTTP/2 next to HTTP/1.1 on --telemetry.address, negotiated over TLS, and as h2c over plain HTTP.")
+	flag.StringVar(&metricsPath, "telemetry-path", exporter.DefaultMetricsPath, "Path at which pipeline-service metrics are exported.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the healthz and readyz endpoints bind to, always over plain HTTP, as host:port, [::]:8081 for IPv6, or a bare port; 0 turns them off.")
+	flag.StringVar(&pprofAddr, "pprof-address", "", "The address the debug listener, serving pprof, binds to, like localhost:6060; a bare port binds all the interfaces, and empty turns the listener off.")
+	flag.StringVar(&metricsCompression, "metrics-compression", strings.Join(exporter.DefaultMetricsCompression, ","), "The comma separated encodings the metrics responses are compressed with, in order of preference, of gzip and zstd, when the scraper accepts them; none turns compression off.")
+	flag.StringVar(&metricsMaxResponseSize, "metrics-max-response-size", "", "Fail the metrics responses larger than this quantity uncompressed, like 50Mi, with a 500 vs. sending them; empty turns the limit off.")
+	flag.StringVar(&debugWebConfigFile, "debug.web.config.file", "", "The path of an exporter-toolkit web configuration file whose tls_server_config serves the debug listener over TLS; empty serves plain HTTP.")
+	flag.BoolVar(&debugAuth, "debug-auth", false, "Require the callers of the debug listener to send a bearer token allowed to get the request path, like --metrics-auth; needs --debug.web.config.file.")
+	flag.StringVar(&adminAddr, "admin-address", "", "The address the admin listener, serving the recent runs and daily aggregates APIs, binds to; empty serves them on the debug listener.")
+	flag.StringVar(&adminWebConfigFile, "admin.web.config.file", "", "The path of an exporter-toolkit web configuration file whose tls_server_config serves the admin listener over TLS; empty serves plain HTTP.")
+	flag.BoolVar(&adminAuth, "admin-auth", false, "Require the callers of the admin listener to send a bearer token allowed to get the request path, like --metrics-auth; needs --admin.web.config.file.")
+	flag.BoolVar(&readOnly, "read-only", false, "Disables all writes to the API server, like the throttled label on PipelineRuns and events, so only get/list/watch permissions are needed.")
+	// FYI controller-runtime already registers the --kubeconfig flag
+	flag.StringVar(&kubeContext, "context", "", "The name of the kubeconfig context to use when running out of cluster.")
+	flag.StringVar(&impersonateUser, "as", "", "Username to impersonate when talking to the API server.")
+	flag.Var(&impersonateGroups, "as-group", "Group to impersonate when talking to the API server; can be repeated to specify multiple groups, and requires --as.")
+	flag.Var(&peerContexts, "peer-context", "The name of a kubeconfig context for another member cluster whose PipelineRuns are watched to detect duplicates during tenant migrations; can be repeated.")
+	flag.StringVar(&output, "output", "http", "Where the metrics go: http to serve them on --telemetry.address, or textfile:<path> to periodically write them to the file for node_exporter's textfile collector instead.")
+	flag.DurationVar(&textfileInterval, "textfile-interval", exporter.DefaultTextfileInterval, "How often the metrics are written with --output=textfile:<path>.")
+	flag.Float64Var(&kubeAPIQPS, "kube-api-qps", 50, "The queries per second the exporter's clients are limited to when talking to the API server.")
+	flag.IntVar(&kubeAPIBurst, "kube-api-burst", 50, "The burst of queries the exporter's clients are allowed above --kube-api-qps.")
+	flag.IntVar(&watchdogMaxGoroutines, "watchdog-max-goroutines", 0, "Fail the healthz check when the exporter has more goroutines than this; 0 turns the check off.")
+	flag.StringVar(&watchdogMaxHeap, "watchdog-max-heap", "", "Fail the healthz check when the exporter's heap is larger than this quantity, like 1Gi; empty turns the check off.")
+	flag.DurationVar(&watchdogMaxStaleness, "watchdog-max-workqueue-staleness", 0, "Fail the healthz check when a reconcile has been running for longer than this; 0 turns the check off.")
+	flag.BoolVar(&watchdogExit, "watchdog-exit", false, "Exit vs. failing the healthz check when a watchdog limit is breached, so the container is restarted without a liveness probe.")
+	flag.StringVar(&metricSnapshot, "metric-snapshot", "", "The path of a file the counters and histograms are written to every minute and on shutdown, and restored from on startup, so they do not reset on every deployment; empty turns the snapshot off.")
+	flag.StringVar(&aggregateStore, "aggregate-store", "", "The path of a bolt file persisting daily per namespace overhead aggregates across restarts, served on the admin listener; empty turns the store off.")
+	flag.DurationVar(&aggregateRetention, "aggregate-retention", collector.DefaultAggregateRetention, "How long the daily aggregates are kept in --aggregate-store.")
+	flag.StringVar(&gapExport, "gap-export", "", "Where the gap breakdown of every completed PipelineRun is written for offline analysis, as s3://<bucket>/<prefix>; empty turns the export off.  The credentials come from the standard AWS environment variables, shared config, or web identity.")
+	flag.StringVar(&gapExportEndpoint, "gap-export-endpoint", "", "The S3 compatible endpoint of --gap-export; defaults to the AWS S3 endpoint of --gap-export-region.")
+	flag.StringVar(&gapExportRegion, "gap-export-region", "us-east-1", "The region --gap-export requests are signed for.")
+	flag.DurationVar(&gapExportInterval, "gap-export-interval", collector.DefaultGapExportInterval, "How often the --gap-export records are written.")
+	flag.Var(&externalCollectors, "external-collector", "A collector run as a separate binary, as <name>=<command> [<args>...], which is given a JSON request on its stdin every --external-collector-interval and prints metrics in the Prometheus text format on its stdout, served with the exporter's; can be repeated.")
+	flag.DurationVar(&externalCollectorInterval, "external-collector-interval", collector.DefaultExternalCollectorInterval, "How often the --external-collector commands are run.")
+	flag.DurationVar(&externalCollectorTimeout, "external-collector-timeout", collector.DefaultExternalCollectorTimeout, "How long an --external-collector command may run before it is killed.")
+	flag.BoolVar(&timingIngest, "timing-ingest", false, "Accept the milestones of PipelineRuns posted by external systems, like Pipelines as Code, to /ingest/timings on the admin listener, merged into their extended durations; needs --admin-address with --admin-auth, so the posters have to be allowed to post to the path.")
+	flag.StringVar(&webConfigFile, "web.config.file", "", "The path of an exporter-toolkit web configuration file whose tls_server_config serves the metrics over TLS, optionally requiring client certificates from a CA and allowed clients, and whose basic_auth_users require basic auth; changes to the file are picked up without a restart, and empty serves plain HTTP.")
+	flag.BoolVar(&metricsAuth, "metrics-auth", false, "Require the scrapers to send a bearer token, authenticated with a TokenReview and authorized with a SubjectAccessReview, like kube-rbac-proxy; needs --web.config.file.")
+	flag.StringVar(&metricsAuthResource, "metrics-auth-resource", "", "The resource the --metrics-auth scrapers need to be allowed to get, as [<namespace>/]<resource>[.<group>][/<name>], like openshift-pipelines/services/pipeline-service-exporter; empty checks get on the request path as a non-resource URL.")
+	flag.IntVar(&metricCompatLevel, "metric-compat-level", collector.MetricCompatLegacy, "Which names renamed metrics are published under: 0 for only the legacy names, 1 for both the legacy and stable names, 2 for only the stable names.")
 
 	opts := zap.Options{}
 	opts.BindFlags(flag.CommandLine)
@@ -69,42 +219,197 @@ func main() {
 	mainLog = ctrl.Log.WithName("main")
 
 	mainLog.Info("Starting pipeline_service_exporter", "version", version.Info())
-	mainLog.Info("Build context", "build", version.BuildContext())
+	mainLog.Info("Build context", "build", version.BuildContext(), "fips", exporter.FIPSMode())
 	mainLog.Info("Starting Server: ", "listen_address", listenAddress)
 
 	ctx := ctrl.SetupSignalHandler()
-	restConfig := ctrl.GetConfigOrDie()
-	restConfig.QPS = 50
-	restConfig.Burst = 50
-	var mgr ctrl.Manager
-	var err error
-	mopts := ctrl.Options{
-		MetricsBindAddress:     listenAddress,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-	}
-
-	mgr, err = collector.NewManager(restConfig, mopts, pprofAddr)
+	restConfig, err := config.GetConfigWithContext(kubeContext)
 	if err != nil {
-		mainLog.Error(err, "unable to start controller-runtime manager")
+		mainLog.Error(err, "unable to get kubeconfig")
 		os.Exit(1)
 	}
-
-	//+kubebuilder:scaffold:builder
-
-	if err = mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		mainLog.Error(err, "unable to set up health check")
+	if len(impersonateGroups) > 0 && len(impersonateUser) == 0 {
+		mainLog.Error(fmt.Errorf("--as-group requires --as"), "invalid impersonation flags")
+		os.Exit(1)
+	}
+	if len(impersonateUser) > 0 {
+		mainLog.Info("Impersonating", "user", impersonateUser, "groups", impersonateGroups.String())
+		restConfig.Impersonate = rest.ImpersonationConfig{UserName: impersonateUser, Groups: impersonateGroups}
+	}
+	if metricCompatLevel < collector.MetricCompatLegacy || metricCompatLevel > collector.MetricCompatStable {
+		mainLog.Error(fmt.Errorf("--metric-compat-level must be 0, 1 or 2, not %d", metricCompatLevel), "invalid metric compat level")
 		os.Exit(1)
 	}
-	if err = mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		mainLog.Error(err, "unable to set up ready check")
+	textfilePath := ""
+	switch kind, path, _ := strings.Cut(output, ":"); {
+	case output == "http":
+	case kind == "textfile" && len(path) > 0:
+		textfilePath = path
+		// nothing scrapes the exporter when node_exporter collects the metrics
+		listenAddress = "0"
+	default:
+		mainLog.Error(fmt.Errorf("--output must be http or textfile:<path>, not %q", output), "invalid output")
 		os.Exit(1)
 	}
+	var metricsTLS *exporter.TLSServerConfig
+	if len(webConfigFile) > 0 {
+		metricsTLS, err = exporter.LoadWebConfig(webConfigFile)
+		if err != nil {
+			mainLog.Error(err, "invalid web configuration")
+			os.Exit(1)
+		}
+	}
+	var metricsAuthConfig *exporter.MetricsAuthConfig
+	if metricsAuth {
+		if metricsTLS == nil {
+			mainLog.Error(fmt.Errorf("--metrics-auth needs --web.config.file"), "invalid metrics auth")
+			os.Exit(1)
+		}
+		metricsAuthConfig = &exporter.MetricsAuthConfig{}
+		if len(metricsAuthResource) > 0 {
+			metricsAuthConfig.Resource, err = exporter.ParseResourceAttributes(metricsAuthResource)
+			if err != nil {
+				mainLog.Error(err, "invalid metrics auth resource")
+				os.Exit(1)
+			}
+		}
+	}
+	encodings, err := exporter.ParseMetricsCompression(metricsCompression)
+	if err != nil {
+		mainLog.Error(err, "invalid metrics compression")
+		os.Exit(1)
+	}
+	var maxResponseBytes int64
+	if len(metricsMaxResponseSize) > 0 {
+		maxResponseSize, err := resource.ParseQuantity(metricsMaxResponseSize)
+		if err != nil || maxResponseSize.Sign() <= 0 {
+			mainLog.Error(fmt.Errorf("--metrics-max-response-size must be a positive quantity like 50Mi, not %q", metricsMaxResponseSize), "invalid metrics response limit")
+			os.Exit(1)
+		}
+		maxResponseBytes = maxResponseSize.Value()
+	}
+	metricsServer = metricsServer.DisableZeroTimeouts()
+	debugListener := listenerFromFlags("debug", pprofAddr, debugWebConfigFile, debugAuth)
+	adminListener := listenerFromFlags("admin", adminAddr, adminWebConfigFile, adminAuth)
+	// the debug and admin listeners take the timeouts and limits of the metrics listener
+	for _, l := range []*exporter.ListenerConfig{debugListener, adminListener} {
+		if l != nil {
+			l.Server = metricsServer
+		}
+	}
+	collectorSettings := settingsFromEnv()
+	collectorSettings.MetricCompatLevel = metricCompatLevel
+	if kubeAPIQPS <= 0 || kubeAPIBurst <= 0 {
+		mainLog.Error(fmt.Errorf("--kube-api-qps and --kube-api-burst must be positive"), "invalid client rate limit")
+		os.Exit(1)
+	}
+	restConfig.QPS = float32(kubeAPIQPS)
+	restConfig.Burst = kubeAPIBurst
+	watchdog := exporter.WatchdogConfig{MaxGoroutines: watchdogMaxGoroutines, MaxWorkqueueStaleness: watchdogMaxStaleness, Exit: watchdogExit}
+	if len(watchdogMaxHeap) > 0 {
+		maxHeap, err := resource.ParseQuantity(watchdogMaxHeap)
+		if err != nil || maxHeap.Sign() <= 0 {
+			mainLog.Error(fmt.Errorf("--watchdog-max-heap must be a positive quantity like 1Gi, not %q", watchdogMaxHeap), "invalid watchdog limit")
+			os.Exit(1)
+		}
+		watchdog.MaxHeapBytes = uint64(maxHeap.Value())
+	}
+	collectorOpts := []collector.Option{
+		collector.WithReadOnly(readOnly),
+		collector.WithSettings(collectorSettings),
+	}
+	if len(gapExport) > 0 {
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(gapExport, "s3://"), "/")
+		if !strings.HasPrefix(gapExport, "s3://") || len(bucket) == 0 {
+			mainLog.Error(fmt.Errorf("--gap-export must be s3://<bucket>/<prefix>, not %q", gapExport), "invalid gap export")
+			os.Exit(1)
+		}
+		awsConfig, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(gapExportRegion))
+		if err != nil {
+			mainLog.Error(err, "unable to load the gap export credentials")
+			os.Exit(1)
+		}
+		if len(gapExportEndpoint) == 0 {
+			gapExportEndpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", gapExportRegion)
+		}
+		collectorOpts = append(collectorOpts, collector.WithGapExport(collector.GapExportConfig{
+			Endpoint:    gapExportEndpoint,
+			Bucket:      bucket,
+			Prefix:      prefix,
+			Region:      gapExportRegion,
+			Credentials: awsConfig.Credentials,
+			Interval:    gapExportInterval,
+		}))
+	}
+	if timingIngest {
+		// the posts write into the metrics, so they are never accepted from unauthenticated callers
+		if adminListener == nil || !adminAuth {
+			mainLog.Error(fmt.Errorf("--timing-ingest needs --admin-address with --admin-auth"), "invalid timing ingest")
+			os.Exit(1)
+		}
+		collectorOpts = append(collectorOpts, collector.WithTimingIngest())
+	}
+	for _, value := range externalCollectors {
+		external, err := collector.ParseExternalCollector(value)
+		if err != nil {
+			mainLog.Error(err, "invalid external collector")
+			os.Exit(1)
+		}
+		external.Interval = externalCollectorInterval
+		external.Timeout = externalCollectorTimeout
+		collectorOpts = append(collectorOpts, collector.WithExternalCollectors(external))
+	}
+	if len(metricSnapshot) > 0 {
+		collectorOpts = append(collectorOpts, collector.WithMetricSnapshot(metricSnapshot))
+	}
+	if len(aggregateStore) > 0 {
+		collectorOpts = append(collectorOpts, collector.WithAggregateStore(aggregateStore, aggregateRetention))
+	}
+	if runLabels := collector.ParseObjectLabelProvider(os.Getenv(collector.RunLabelsEnvName)); len(runLabels) > 0 {
+		names := []string{}
+		for name := range runLabels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		collectorOpts = append(collectorOpts, collector.WithLabelProvider(runLabels, names...))
+	}
+
+	peers := map[string]*rest.Config{}
+	for _, peerContext := range peerContexts {
+		peerConfig, err := config.GetConfigWithContext(peerContext)
+		if err != nil {
+			mainLog.Error(err, "unable to get kubeconfig for peer cluster", "context", peerContext)
+			os.Exit(1)
+		}
+		if len(impersonateUser) > 0 {
+			peerConfig.Impersonate = restConfig.Impersonate
+		}
+		peerConfig.QPS = restConfig.QPS
+		peerConfig.Burst = restConfig.Burst
+		peers[peerContext] = peerConfig
+	}
 
 	mainLog.Info("Starting controller-runtime manager")
 
-	if err = mgr.Start(ctx); err != nil {
-		mainLog.Error(err, "problem running controller-runtime manager")
+	err = exporter.New(exporter.Config{
+		RestConfig:              restConfig,
+		MetricsBindAddress:      listenAddress,
+		MetricsPath:             metricsPath,
+		HealthProbeBindAddress:  probeAddr,
+		PeerClusters:            peers,
+		TextfilePath:            textfilePath,
+		TextfileInterval:        textfileInterval,
+		Watchdog:                watchdog,
+		MetricsTLS:              metricsTLS,
+		MetricsAuth:             metricsAuthConfig,
+		MetricsCompression:      encodings,
+		MetricsMaxResponseBytes: maxResponseBytes,
+		MetricsServer:           metricsServer,
+		Debug:                   debugListener,
+		Admin:                   adminListener,
+	}).WithOptions(collectorOpts...).Run(ctx)
+	if err != nil {
+		mainLog.Error(err, "problem running the exporter")
 		os.Exit(1)
 	}
 
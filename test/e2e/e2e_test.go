@@ -0,0 +1,288 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openshift-pipelines/pipeline-service-exporter/collector"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	k8sscheme "k8s.io/client-go/kubernetes/scheme"
+	"knative.dev/pkg/apis"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+const (
+	exporterUser = "pipeline-service-exporter"
+	testNS       = "e2e-tenant"
+)
+
+// TestExporter boots the exporter the way main.go does, against an API server with the Tekton CRDs and with
+// the exporter only having the permissions from testdata/rbac.yaml, plays the part of the Tekton controller and
+// the kubelet for a PipelineRun with one TaskRun, and checks what is served on /metrics
+func TestExporter(t *testing.T) {
+	if len(os.Getenv("KUBEBUILDER_ASSETS")) == 0 {
+		t.Skip("KUBEBUILDER_ASSETS is not set; see the End to End Tests section of the README")
+	}
+	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("testdata", "crds")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		t.Fatalf("could not start the test environment: %s", err.Error())
+	}
+	defer testEnv.Stop()
+
+	scheme := runtime.NewScheme()
+	_ = k8sscheme.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	adminClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatalf("could not create the admin client: %s", err.Error())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	applyRBAC(ctx, t, adminClient)
+
+	user, err := testEnv.AddUser(envtest.User{Name: exporterUser}, cfg)
+	if err != nil {
+		t.Fatalf("could not add the exporter user: %s", err.Error())
+	}
+	metricsAddr := freeAddress(t)
+	mgr, err := collector.NewManager(user.Config(), ctrl.Options{
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: "0",
+	})
+	if err != nil {
+		t.Fatalf("could not create the manager: %s", err.Error())
+	}
+	mgrErr := make(chan error, 1)
+	go func() {
+		mgrErr <- mgr.Start(ctx)
+	}()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testNS}}
+	assert.NoError(t, adminClient.Create(ctx, ns))
+	runPipeline(ctx, t, adminClient)
+
+	expected := map[string]bool{
+		"pipelinerun_duration_scheduled_seconds":                        false,
+		"taskrun_duration_scheduled_seconds":                            false,
+		"pipelinerun_gap_between_taskruns_milliseconds":                 false,
+		"pipelinerun_gap_max_milliseconds":                              false,
+		"pipelinerun_pipeline_resolution_wait_milliseconds":             false,
+		"taskrun_task_resolution_wait_milliseconds":                     false,
+		"tekton_pods_create_to_complete_seconds":                        false,
+		"taskrun_pod_duration_kubelet_acknowledged_milliseconds":        false,
+		"taskrun_pod_duration_kubelet_to_container_start_milliseconds":  false,
+		"pipeline_service_namespace_last_pipelinerun_timestamp_seconds": false,
+	}
+	var families map[string]*dto.MetricFamily
+	err = wait.PollImmediate(time.Second, 2*time.Minute, func() (bool, error) {
+		select {
+		case err := <-mgrErr:
+			return false, fmt.Errorf("the manager exited: %v", err)
+		default:
+		}
+		families, err = scrape(metricsAddr)
+		if err != nil {
+			t.Logf("scrape failed: %s", err.Error())
+			return false, nil
+		}
+		for name := range expected {
+			expected[name] = hasNamespaceSeries(families[name], testNS)
+		}
+		for _, found := range expected {
+			if !found {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	for name, found := range expected {
+		assert.True(t, found, "no %s series for namespace %s", name, testNS)
+	}
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the collectors started right away, as the CRDs were established before the manager was created
+	waiting := families["collector_waiting_for_crd"]
+	if assert.NotNil(t, waiting) && assert.Len(t, waiting.Metric, 1) {
+		assert.Equal(t, float64(0), waiting.Metric[0].GetGauge().GetValue())
+	}
+	// nothing was skipped for lack of permissions or otherwise
+	for _, name := range []string{"pipeline_service_throttle_label_patch_failures_total", "pipeline_service_exporter_skipped_events_total"} {
+		if family, ok := families[name]; ok {
+			for _, m := range family.Metric {
+				assert.Zero(t, m.GetCounter().GetValue(), name)
+			}
+		}
+	}
+}
+
+func applyRBAC(ctx context.Context, t *testing.T, c client.Client) {
+	f, err := os.Open(filepath.Join("testdata", "rbac.yaml"))
+	if err != nil {
+		t.Fatalf("could not open the RBAC manifest: %s", err.Error())
+	}
+	defer f.Close()
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		err = decoder.Decode(&obj.Object)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("could not decode the RBAC manifest: %s", err.Error())
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if err = c.Create(ctx, obj); err != nil {
+			t.Fatalf("could not create %s %s: %s", obj.GetKind(), obj.GetName(), err.Error())
+		}
+	}
+}
+
+// runPipeline does what the Tekton controller and the kubelet would do for a PipelineRun with a single TaskRun,
+// with status updates spaced out so the durations are not all zero
+func runPipeline(ctx context.Context, t *testing.T, c client.Client) {
+	pr := &v1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNS, Name: "e2e-pipelinerun"},
+		Spec: v1.PipelineRunSpec{
+			PipelineRef: &v1.PipelineRef{Name: "e2e-pipeline"},
+		},
+	}
+	assert.NoError(t, c.Create(ctx, pr))
+	time.Sleep(time.Second)
+	pr.Status.StartTime = &metav1.Time{Time: time.Now()}
+	pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionUnknown, Reason: collector.ReasonResolvingPipelineRef})
+	assert.NoError(t, c.Status().Update(ctx, pr))
+	time.Sleep(time.Second)
+	pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionUnknown, Reason: v1.PipelineRunReasonRunning.String()})
+	assert.NoError(t, c.Status().Update(ctx, pr))
+
+	trLabels := map[string]string{
+		pipeline.PipelineLabelKey:     "e2e-pipeline",
+		pipeline.PipelineRunLabelKey:  pr.Name,
+		pipeline.PipelineTaskLabelKey: "build",
+	}
+	tr := &v1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       testNS,
+			Name:            "e2e-pipelinerun-build",
+			Labels:          trLabels,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(pr, v1.SchemeGroupVersion.WithKind("PipelineRun"))},
+		},
+		Spec: v1.TaskRunSpec{
+			TaskRef: &v1.TaskRef{Name: "build"},
+		},
+	}
+	assert.NoError(t, c.Create(ctx, tr))
+	pr.Status.ChildReferences = []v1.ChildStatusReference{{
+		TypeMeta:         runtime.TypeMeta{APIVersion: v1.SchemeGroupVersion.String(), Kind: "TaskRun"},
+		Name:             tr.Name,
+		PipelineTaskName: "build",
+	}}
+	assert.NoError(t, c.Status().Update(ctx, pr))
+	time.Sleep(time.Second)
+	tr.Status.StartTime = &metav1.Time{Time: time.Now()}
+	tr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionUnknown, Reason: v1.TaskRunReasonResolvingTaskRef})
+	assert.NoError(t, c.Status().Update(ctx, tr))
+	time.Sleep(time.Second)
+	tr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionUnknown, Reason: v1.TaskRunReasonRunning.String()})
+	assert.NoError(t, c.Status().Update(ctx, tr))
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       testNS,
+			Name:            "e2e-pipelinerun-build-pod",
+			Labels:          map[string]string{pipeline.PipelineLabelKey: "e2e-pipeline", pipeline.TaskRunLabelKey: tr.Name},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(tr, v1.SchemeGroupVersion.WithKind("TaskRun"))},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "step-build", Image: "registry.access.redhat.com/ubi9/ubi-minimal"}},
+		},
+	}
+	assert.NoError(t, c.Create(ctx, pod))
+	time.Sleep(time.Second)
+	pod.Status.StartTime = &metav1.Time{Time: time.Now()}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "step-build", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"}}}}
+	assert.NoError(t, c.Status().Update(ctx, pod))
+	time.Sleep(time.Second)
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.Now()}}
+	assert.NoError(t, c.Status().Update(ctx, pod))
+	time.Sleep(time.Second)
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.Now()}}
+	assert.NoError(t, c.Status().Update(ctx, pod))
+
+	tr.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	tr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue, Reason: v1.TaskRunReasonSuccessful.String()})
+	assert.NoError(t, c.Status().Update(ctx, tr))
+	time.Sleep(time.Second)
+	pr.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+	pr.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue, Reason: v1.PipelineRunReasonSuccessful.String()})
+	assert.NoError(t, c.Status().Update(ctx, pr))
+}
+
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not find a free port: %s", err.Error())
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func scrape(addr string) (map[string]*dto.MetricFamily, error) {
+	resp, err := http.Get(fmt.Sprintf("http://%s/metrics", addr))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scrape returned %s", resp.Status)
+	}
+	parser := expfmt.TextParser{}
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+func hasNamespaceSeries(family *dto.MetricFamily, ns string) bool {
+	if family == nil {
+		return false
+	}
+	for _, m := range family.Metric {
+		for _, l := range m.Label {
+			if l.GetName() == "namespace" && l.GetValue() == ns {
+				return true
+			}
+		}
+	}
+	return false
+}